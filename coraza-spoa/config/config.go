@@ -4,7 +4,6 @@ import (
 	"context"
 	"fmt"
 	"io"
-	"net/url"
 	"os"
 	"time"
 
@@ -12,6 +11,7 @@ import (
 	"gopkg.in/yaml.v3"
 
 	"github.com/HUAHUAI23/simple-waf/coraza-spoa/internal"
+	"github.com/HUAHUAI23/simple-waf/pkg/utils/network"
 )
 
 var ConfigPath string
@@ -54,13 +54,8 @@ type config struct {
 	} `yaml:"applications"`
 }
 
-func (c config) NetworkAddressFromBind() (network string, address string) {
-	bindUrl, err := url.Parse(c.Bind)
-	if err == nil {
-		return bindUrl.Scheme, bindUrl.Path
-	}
-
-	return "tcp", c.Bind
+func (c config) NetworkAddressFromBind() (string, string) {
+	return network.NetworkAddressFromBind(c.Bind)
 }
 
 func (c config) NewApplicationsWithContext(ctx context.Context, mongoConfig *internal.MongoConfig) (map[string]*internal.Application, error) {
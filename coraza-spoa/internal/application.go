@@ -80,11 +80,12 @@ func (a *Application) HandleRequest(ctx context.Context, writer *encoding.Action
 	for message.KV.Next(k) {
 		switch name := string(k.NameBytes()); name {
 		case "src-ip":
-			req.SrcIp = k.ValueAddr()
+			// 同时监听 IPv4 和 IPv6 时 IPv4 客户端以 ::ffff:a.b.c.d 形式传入，统一还原为 IPv4 地址
+			req.SrcIp = k.ValueAddr().Unmap()
 		case "src-port":
 			req.SrcPort = k.ValueInt()
 		case "dst-ip":
-			req.DstIp = k.ValueAddr()
+			req.DstIp = k.ValueAddr().Unmap()
 		case "dst-port":
 			req.DstPort = k.ValueInt()
 		case "method":
@@ -201,7 +202,7 @@ func (a *Application) HandleRequest(ctx context.Context, writer *encoding.Action
 			url.Write(req.Query)
 		}
 
-		tx.ProcessURI(url.String(), req.Method, "HTTP/"+req.Version)
+		tx.ProcessURI(url.String(), req.Method, httpProtocol(req.Version))
 	}
 
 	if err := readHeaders(req.Headers, tx.AddRequestHeader); err != nil {
@@ -250,6 +251,16 @@ func readHeaders(headers []byte, callback func(key string, value string)) error
 	return nil
 }
 
+// httpProtocol 把 HAProxy req.ver/res.ver 的版本号转换为 REQUEST_PROTOCOL 使用的协议名
+// HTTP/2 和 HTTP/3 的版本号为 2.0 和 3.0，缺失时按 HTTP/1.1 处理
+func httpProtocol(version string) string {
+	version = strings.TrimPrefix(strings.TrimSpace(version), "HTTP/")
+	if version == "" {
+		version = "1.1"
+	}
+	return "HTTP/" + version
+}
+
 type applicationResponse struct {
 	ID      string
 	Version string
@@ -352,7 +363,7 @@ func (a *Application) HandleResponse(ctx context.Context, writer *encoding.Actio
 		return fmt.Errorf("reading headers: %v", err)
 	}
 
-	if it := tx.ProcessResponseHeaders(int(res.Status), "HTTP/"+res.Version); it != nil {
+	if it := tx.ProcessResponseHeaders(int(res.Status), httpProtocol(res.Version)); it != nil {
 		return ErrInterrupted{it}
 	}
 
@@ -403,8 +414,8 @@ func buildRequestString(req *applicationRequest, headers []byte) string {
 		sb.WriteByte('?')
 		sb.Write(req.Query)
 	}
-	sb.WriteString(" HTTP/")
-	sb.WriteString(req.Version)
+	sb.WriteByte(' ')
+	sb.WriteString(httpProtocol(req.Version))
 	sb.WriteByte('\n')
 	sb.Write(headers)
 
@@ -431,6 +442,7 @@ func (a *Application) saveFirewallLog(matchedRules []types.MatchedRule, interrup
 		SrcPort:   int(req.SrcPort),
 		DstPort:   int(req.DstPort),
 		RequestID: req.ID,
+		Action:    interruption.Action,
 	}
 
 	// 遍历所有匹配的规则
@@ -5,13 +5,15 @@ import (
 )
 
 type Config struct {
-	Name            string        `bson:"name" json:"name"`
-	Engine          EngineConfig  `bson:"engine" json:"engine"`
-	Haproxy         HaproxyConfig `bson:"haproxy" json:"haproxy"`
-	CreatedAt       time.Time     `bson:"createdAt" json:"createdAt"`
-	UpdatedAt       time.Time     `bson:"updatedAt" json:"updatedAt"`
-	IsResponseCheck bool          `bson:"isResponseCheck" json:"isResponseCheck"`
-	IsDebug         bool          `bson:"isDebug" json:"isDebug"`
+	Name            string          `bson:"name" json:"name"`
+	Engine          EngineConfig    `bson:"engine" json:"engine"`
+	Haproxy         HaproxyConfig   `bson:"haproxy" json:"haproxy"`
+	SIEM            SIEMConfig      `bson:"siem" json:"siem"`
+	Retention       RetentionConfig `bson:"retention" json:"retention"`
+	CreatedAt       time.Time       `bson:"createdAt" json:"createdAt"`
+	UpdatedAt       time.Time       `bson:"updatedAt" json:"updatedAt"`
+	IsResponseCheck bool            `bson:"isResponseCheck" json:"isResponseCheck"`
+	IsDebug         bool            `bson:"isDebug" json:"isDebug"`
 }
 
 type EngineConfig struct {
@@ -38,6 +40,33 @@ type HaproxyConfig struct {
 	Thread        int    `bson:"thread" json:"thread"`
 }
 
+// SIEMConfig SIEM 转发配置，将 WAF 和 IDS 事件以 CEF/LEEF 格式通过 syslog 转发
+type SIEMConfig struct {
+	Enabled     bool   `bson:"enabled" json:"enabled"`
+	Format      string `bson:"format" json:"format"`           // cef 或 leef
+	Protocol    string `bson:"protocol" json:"protocol"`       // udp、tcp 或 tls
+	Address     string `bson:"address" json:"address"`         // syslog 接收端地址 host:port
+	TLSInsecure bool   `bson:"tlsInsecure" json:"tlsInsecure"` // tls 协议下是否跳过证书校验
+	Facility    int    `bson:"facility" json:"facility"`       // syslog facility，默认 16 (local0)
+	BufferSize  int    `bson:"bufferSize" json:"bufferSize"`   // 发送缓冲区可容纳的事件数
+	ForwardWAF  bool   `bson:"forwardWAF" json:"forwardWAF"`   // 是否转发 WAF 事件
+	ForwardIDS  bool   `bson:"forwardIDS" json:"forwardIDS"`   // 是否转发 Suricata 事件
+}
+
+// RetentionConfig 日志保留配置
+type RetentionConfig struct {
+	ArchiveDir  string            `bson:"archiveDir" json:"archiveDir"`   // 归档文件目录
+	RestoreDays int               `bson:"restoreDays" json:"restoreDays"` // 归档恢复数据的保留天数
+	Policies    []RetentionPolicy `bson:"policies" json:"policies"`       // 各集合的保留策略
+}
+
+// RetentionPolicy 单个集合的保留策略，通过 TTL 索引过期删除
+type RetentionPolicy struct {
+	Collection string `bson:"collection" json:"collection"` // 集合名称
+	Days       int    `bson:"days" json:"days"`             // 保留天数，0 表示永久保留
+	Archive    bool   `bson:"archive" json:"archive"`       // 过期前是否归档为压缩的 NDJSON 文件
+}
+
 func (c *Config) GetCollectionName() string {
 	return "config"
 }
@@ -28,6 +28,7 @@ type WAFLog struct {
 	Domain     string        `json:"domain" bson:"domain" example:"api.example.com"`                                                                                        // 目标域名
 	Logs       []Log         `json:"logs" bson:"logs"`                                                                                                                      // 关联的日志条目
 	Message    string        `json:"message" bson:"message" example:"恶意扫描器检测"`                                                                                              // 事件描述消息
+	Action     string        `json:"action" bson:"action" example:"deny"`                                                                                                   // 拦截动作
 	Request    string        `json:"request" bson:"request" example:"GET /api/v1/users HTTP/1.1\nHost: api.example.com\nUser-Agent: Scanner/1.0"`                           // 原始HTTP请求
 	Response   string        `json:"response" bson:"response" example:"HTTP/1.1 403 Forbidden\nContent-Type: text/html\nContent-Length: 146"`                               // 原始HTTP响应
 	CreatedAt  time.Time     `json:"createdAt" bson:"createdAt" example:"2024-03-18T08:12:33Z"`                                                                             // 事件发生时间戳
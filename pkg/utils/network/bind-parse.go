@@ -1,13 +1,14 @@
 package network
 
 import (
-	"net/url"
+	"strings"
 )
 
+// NetworkAddressFromBind 将 tcp://[::1]:9000、unix:///run/spoa.sock 或 :9000 形式的监听地址
+// 拆分为 net.Listen 使用的网络类型和地址，没有协议前缀时为 tcp，[::]:9000 和 localhost:9000 原样返回
 func NetworkAddressFromBind(bind string) (network string, address string) {
-	bindUrl, err := url.Parse(bind)
-	if err == nil {
-		return bindUrl.Scheme, bindUrl.Path
+	if scheme, rest, ok := strings.Cut(bind, "://"); ok && scheme != "" {
+		return scheme, rest
 	}
 
 	return "tcp", bind
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 
 	mongodb "github.com/HUAHUAI23/simple-waf/pkg/database/mongo"
@@ -26,6 +27,11 @@ type Config struct {
 	Log          LogConfig
 	DBConfig     DBConfig
 	JWT          JWTConfig
+	Alert        AlertConfig
+	Suricata     SuricataConfig
+	Incident     IncidentConfig
+	Ban          BanConfig
+	AccessLog    AccessLogConfig
 }
 
 // DBConfig 数据库配置
@@ -40,6 +46,40 @@ type JWTConfig struct {
 	ExpirationHrs int
 }
 
+// AlertConfig 告警引擎配置
+type AlertConfig struct {
+	EvalIntervalSec int // 告警规则评估间隔（秒）
+}
+
+// IncidentConfig 安全事件关联配置
+type IncidentConfig struct {
+	WindowMinutes int // 同一来源IP和目标的事件间隔不超过该时间时归入同一安全事件
+	IntervalSec   int // 关联扫描间隔（秒）
+}
+
+// BanConfig 自动封禁配置
+type BanConfig struct {
+	IntervalSec int      // 策略评估和到期检查间隔（秒）
+	ExemptCIDRs []string // 不会被自动封禁的网段
+}
+
+// AccessLogConfig HAProxy 访问日志接收配置
+type AccessLogConfig struct {
+	Enabled   bool   // 是否接收 HAProxy 访问日志，开启后生成的 HAProxy 配置会向该地址发送日志
+	Address   string // 接收地址，udp://127.0.0.1:5140 或 unix:///run/simple-waf/access.sock
+	BatchSize int    // 批量写入的日志数
+}
+
+// SuricataConfig Suricata 事件采集和规则管理配置
+type SuricataConfig struct {
+	IngestEnabled bool   // 是否采集 EVE 日志
+	EveFile       string // EVE JSON 日志文件路径
+	BatchSize     int    // 批量写入的事件数
+	Socket        string // Suricata unix 命令套接字路径
+	RulesDir      string // Suricata 规则文件目录
+	ConfigDir     string // 生成的抓包过滤和 include 文件目录
+}
+
 // InitConfig 从环境变量初始化配置
 func InitConfig() error {
 	// 加载.env文件
@@ -66,6 +106,30 @@ func InitConfig() error {
 			Secret:        "default-jwt-secret-key",
 			ExpirationHrs: 24,
 		},
+		Alert: AlertConfig{
+			EvalIntervalSec: 60,
+		},
+		Incident: IncidentConfig{
+			WindowMinutes: 30,
+			IntervalSec:   30,
+		},
+		Ban: BanConfig{
+			IntervalSec: 10,
+			ExemptCIDRs: []string{"127.0.0.0/8", "::1/128"},
+		},
+		Suricata: SuricataConfig{
+			IngestEnabled: true,
+			EveFile:       "/var/log/suricata/eve.json",
+			BatchSize:     500,
+			Socket:        "/var/run/suricata/suricata-command.socket",
+			RulesDir:      "/var/lib/suricata/rules",
+			ConfigDir:     "/var/lib/suricata/conf",
+		},
+		AccessLog: AccessLogConfig{
+			Enabled:   true,
+			Address:   "udp://127.0.0.1:5140",
+			BatchSize: 500,
+		},
 	}
 
 	// 从环境变量加载配置
@@ -106,6 +170,75 @@ func InitConfig() error {
 		}
 	}
 
+	// 告警配置
+	if env := os.Getenv("ALERT_EVAL_INTERVAL"); env != "" {
+		if sec, err := strconv.Atoi(env); err == nil && sec > 0 {
+			Global.Alert.EvalIntervalSec = sec
+		}
+	}
+
+	// Suricata 采集配置
+	if env := os.Getenv("SURICATA_INGEST_ENABLED"); env != "" {
+		Global.Suricata.IngestEnabled = env == "true"
+	}
+	if env := os.Getenv("SURICATA_EVE_FILE"); env != "" {
+		Global.Suricata.EveFile = env
+	}
+	if env := os.Getenv("SURICATA_INGEST_BATCH"); env != "" {
+		if size, err := strconv.Atoi(env); err == nil && size > 0 {
+			Global.Suricata.BatchSize = size
+		}
+	}
+	if env := os.Getenv("SURICATA_SOCKET"); env != "" {
+		Global.Suricata.Socket = env
+	}
+	if env := os.Getenv("SURICATA_RULES_DIR"); env != "" {
+		Global.Suricata.RulesDir = env
+	}
+	if env := os.Getenv("SURICATA_CONFIG_DIR"); env != "" {
+		Global.Suricata.ConfigDir = env
+	}
+
+	// 访问日志接收配置
+	if env := os.Getenv("ACCESS_LOG_ENABLED"); env != "" {
+		Global.AccessLog.Enabled = env == "true"
+	}
+	if env := os.Getenv("ACCESS_LOG_ADDRESS"); env != "" {
+		Global.AccessLog.Address = env
+	}
+	if env := os.Getenv("ACCESS_LOG_BATCH"); env != "" {
+		if size, err := strconv.Atoi(env); err == nil && size > 0 {
+			Global.AccessLog.BatchSize = size
+		}
+	}
+
+	// 安全事件关联配置
+	if env := os.Getenv("INCIDENT_WINDOW_MINUTES"); env != "" {
+		if minutes, err := strconv.Atoi(env); err == nil && minutes > 0 {
+			Global.Incident.WindowMinutes = minutes
+		}
+	}
+	if env := os.Getenv("INCIDENT_INTERVAL_SEC"); env != "" {
+		if sec, err := strconv.Atoi(env); err == nil && sec > 0 {
+			Global.Incident.IntervalSec = sec
+		}
+	}
+
+	// 自动封禁配置
+	if env := os.Getenv("BAN_INTERVAL_SEC"); env != "" {
+		if sec, err := strconv.Atoi(env); err == nil && sec > 0 {
+			Global.Ban.IntervalSec = sec
+		}
+	}
+	if env := os.Getenv("BAN_EXEMPT_CIDRS"); env != "" {
+		Global.Ban.ExemptCIDRs = nil
+		for _, cidr := range strings.Split(env, ",") {
+			if cidr = strings.TrimSpace(cidr); cidr != "" {
+				Global.Ban.ExemptCIDRs = append(Global.Ban.ExemptCIDRs, cidr)
+			}
+		}
+	}
+
 	// 初始化JWT
 	err = jwt.InitJWTSecret(Global.JWT.Secret)
 	if err != nil {
@@ -192,6 +325,24 @@ SecRuleUpdateTargetById 933120 !ARGS:json.engine.appConfig.0.directives`,
 			SpoeAgentPort: 2342,
 			Thread:        0,
 		},
+		SIEM: model.SIEMConfig{
+			Enabled:    false,
+			Format:     "cef",
+			Protocol:   "udp",
+			Facility:   16,
+			BufferSize: 10000,
+			ForwardWAF: true,
+			ForwardIDS: true,
+		},
+		Retention: model.RetentionConfig{
+			ArchiveDir:  "/simple-waf/archive",
+			RestoreDays: 7,
+			Policies: []model.RetentionPolicy{
+				{Collection: "waf_log", Days: 90, Archive: false},
+				{Collection: "suricata_events", Days: 30, Archive: false},
+				{Collection: "access_log", Days: 7, Archive: false},
+			},
+		},
 		CreatedAt:       now,
 		UpdatedAt:       now,
 		IsResponseCheck: false,
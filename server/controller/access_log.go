@@ -0,0 +1,76 @@
+package controller
+
+import (
+	"time"
+
+	"github.com/HUAHUAI23/simple-waf/server/config"
+	"github.com/HUAHUAI23/simple-waf/server/dto"
+	"github.com/HUAHUAI23/simple-waf/server/service"
+	"github.com/HUAHUAI23/simple-waf/server/utils/response"
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog"
+)
+
+// AccessLogController 访问日志控制器接口
+type AccessLogController interface {
+	GetAnalytics(ctx *gin.Context)
+}
+
+// AccessLogControllerImpl 访问日志控制器实现
+type AccessLogControllerImpl struct {
+	accessLogService service.AccessLogService
+	logger           zerolog.Logger
+}
+
+// NewAccessLogController 创建访问日志控制器
+func NewAccessLogController(accessLogService service.AccessLogService) AccessLogController {
+	logger := config.GetControllerLogger("access_log")
+	return &AccessLogControllerImpl{
+		accessLogService: accessLogService,
+		logger:           logger,
+	}
+}
+
+// GetAnalytics godoc
+//
+//	@Summary		获取站点访问流量统计
+//	@Description	根据 HAProxy 访问日志按站点统计请求量、状态码分布、耗时分位数（p50/p90/p99）和热门路径，并给出同期 WAF 检测数及其占请求量的比例，同时返回访问日志接收器状态
+//	@Tags			WAF安全日志
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Param			domain		query		string														false	"站点主域名，为空时统计全部站点"
+//	@Param			startTime	query		string														false	"查询起始时间 (ISO8601格式，默认24小时前)"
+//	@Param			endTime		query		string														false	"查询结束时间 (ISO8601格式，默认当前时间)"
+//	@Param			topN		query		integer														false	"每个站点返回的热门路径数量，最大50 (默认: 10)"
+//	@Success		200			{object}	model.SuccessResponse{data=dto.AccessLogAnalyticsResponse}	"获取访问流量统计成功"
+//	@Failure		400			{object}	model.ErrResponse											"请求参数错误"
+//	@Failure		401			{object}	model.ErrResponseDontShowError								"未授权访问"
+//	@Failure		403			{object}	model.ErrResponseDontShowError								"禁止访问"
+//	@Failure		500			{object}	model.ErrResponseDontShowError								"服务器内部错误"
+//	@Router			/api/v1/log/access/analytics [get]
+func (c *AccessLogControllerImpl) GetAnalytics(ctx *gin.Context) {
+	var req dto.AccessLogAnalyticsRequest
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		response.BadRequest(ctx, err, true)
+		return
+	}
+
+	if req.EndTime.IsZero() {
+		req.EndTime = time.Now().UTC()
+	}
+	if req.StartTime.IsZero() {
+		req.StartTime = req.EndTime.Add(-24 * time.Hour)
+	}
+	if req.TopN <= 0 {
+		req.TopN = 10
+	}
+
+	result, err := c.accessLogService.GetAnalytics(ctx, req)
+	if err != nil {
+		c.logger.Error().Err(err).Msg("获取访问流量统计失败")
+		response.InternalServerError(ctx, err, false)
+		return
+	}
+
+	response.Success(ctx, "获取访问流量统计成功", result)
+}
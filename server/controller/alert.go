@@ -0,0 +1,309 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/HUAHUAI23/simple-waf/server/config"
+	"github.com/HUAHUAI23/simple-waf/server/dto"
+	"github.com/HUAHUAI23/simple-waf/server/model"
+	"github.com/HUAHUAI23/simple-waf/server/service"
+	"github.com/HUAHUAI23/simple-waf/server/utils/response"
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// AlertController 告警控制器接口
+type AlertController interface {
+	CreateAlertRule(ctx *gin.Context)
+	GetAlertRules(ctx *gin.Context)
+	GetAlertRuleByID(ctx *gin.Context)
+	UpdateAlertRule(ctx *gin.Context)
+	DeleteAlertRule(ctx *gin.Context)
+	GetAlertHistory(ctx *gin.Context)
+	TestChannel(ctx *gin.Context)
+}
+
+// AlertControllerImpl 告警控制器实现
+type AlertControllerImpl struct {
+	alertService service.AlertService
+	logger       zerolog.Logger
+}
+
+// NewAlertController 创建告警控制器
+func NewAlertController(alertService service.AlertService) AlertController {
+	logger := config.GetControllerLogger("alert")
+	return &AlertControllerImpl{
+		alertService: alertService,
+		logger:       logger,
+	}
+}
+
+// CreateAlertRule 创建告警规则
+//
+//	@Summary		创建告警规则
+//	@Description	创建一条告警规则，支持IP阈值、规则触发、引擎状态和证书过期四种类型
+//	@Tags			告警管理
+//	@Accept			json
+//	@Produce		json
+//	@Param			rule	body	dto.CreateAlertRuleRequest	true	"告警规则信息"
+//	@Security		BearerAuth
+//	@Success		200	{object}	model.SuccessResponse{data=model.AlertRule}	"告警规则创建成功"
+//	@Failure		400	{object}	model.ErrResponse							"请求参数错误"
+//	@Failure		401	{object}	model.ErrResponseDontShowError				"未授权访问"
+//	@Failure		403	{object}	model.ErrResponseDontShowError				"禁止访问"
+//	@Failure		500	{object}	model.ErrResponseDontShowError				"服务器内部错误"
+//	@Router			/api/v1/alert/rule [post]
+func (c *AlertControllerImpl) CreateAlertRule(ctx *gin.Context) {
+	var req dto.CreateAlertRuleRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		c.logger.Warn().Err(err).Msg("请求参数绑定失败")
+		response.BadRequest(ctx, err, true)
+		return
+	}
+
+	rule, err := c.alertService.CreateAlertRule(ctx, &req)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidAlertRule) {
+			response.BadRequest(ctx, err, true)
+			return
+		}
+		c.logger.Error().Err(err).Msg("创建告警规则失败")
+		response.InternalServerError(ctx, err, false)
+		return
+	}
+
+	response.Success(ctx, "告警规则创建成功", rule)
+}
+
+// GetAlertRules 获取告警规则列表
+//
+//	@Summary		获取告警规则列表
+//	@Description	获取所有告警规则，支持分页
+//	@Tags			告警管理
+//	@Produce		json
+//	@Param			page	query	int	false	"页码"	default(1)
+//	@Param			size	query	int	false	"每页数量"	default(10)
+//	@Security		BearerAuth
+//	@Success		200	{object}	model.SuccessResponse{data=dto.AlertRuleListResponse}	"获取告警规则列表成功"
+//	@Failure		401	{object}	model.ErrResponseDontShowError							"未授权访问"
+//	@Failure		500	{object}	model.ErrResponseDontShowError							"服务器内部错误"
+//	@Router			/api/v1/alert/rule [get]
+func (c *AlertControllerImpl) GetAlertRules(ctx *gin.Context) {
+	page := ctx.DefaultQuery("page", "1")
+	size := ctx.DefaultQuery("size", "10")
+
+	rules, total, err := c.alertService.GetAlertRules(ctx, page, size)
+	if err != nil {
+		c.logger.Error().Err(err).Msg("获取告警规则列表失败")
+		response.InternalServerError(ctx, err, false)
+		return
+	}
+
+	response.Success(ctx, "获取告警规则列表成功", dto.AlertRuleListResponse{
+		Total: total,
+		Items: rules,
+	})
+}
+
+// GetAlertRuleByID 获取单个告警规则
+//
+//	@Summary		获取告警规则详情
+//	@Description	根据ID获取告警规则详情
+//	@Tags			告警管理
+//	@Produce		json
+//	@Param			id	path	string	true	"告警规则ID"
+//	@Security		BearerAuth
+//	@Success		200	{object}	model.SuccessResponse{data=model.AlertRule}	"获取告警规则详情成功"
+//	@Failure		400	{object}	model.ErrResponse							"请求参数错误"
+//	@Failure		401	{object}	model.ErrResponseDontShowError				"未授权访问"
+//	@Failure		404	{object}	model.ErrResponseDontShowError				"告警规则不存在"
+//	@Failure		500	{object}	model.ErrResponseDontShowError				"服务器内部错误"
+//	@Router			/api/v1/alert/rule/{id} [get]
+func (c *AlertControllerImpl) GetAlertRuleByID(ctx *gin.Context) {
+	id := ctx.Param("id")
+	objectID, err := bson.ObjectIDFromHex(id)
+	if err != nil {
+		c.logger.Error().Err(err).Str("id", id).Msg("无效的ID格式")
+		response.BadRequest(ctx, err, true)
+		return
+	}
+
+	rule, err := c.alertService.GetAlertRuleByID(ctx, objectID)
+	if err != nil {
+		if errors.Is(err, service.ErrAlertRuleNotFound) {
+			response.NotFound(ctx, err)
+			return
+		}
+		c.logger.Error().Err(err).Str("id", id).Msg("获取告警规则详情失败")
+		response.InternalServerError(ctx, err, false)
+		return
+	}
+
+	response.Success(ctx, "获取告警规则详情成功", rule)
+}
+
+// UpdateAlertRule 更新告警规则
+//
+//	@Summary		更新告警规则
+//	@Description	更新指定告警规则的配置
+//	@Tags			告警管理
+//	@Accept			json
+//	@Produce		json
+//	@Param			id		path	string						true	"告警规则ID"
+//	@Param			rule	body	dto.UpdateAlertRuleRequest	true	"告警规则更新信息"
+//	@Security		BearerAuth
+//	@Success		200	{object}	model.SuccessResponse{data=model.AlertRule}	"告警规则更新成功"
+//	@Failure		400	{object}	model.ErrResponse							"请求参数错误"
+//	@Failure		401	{object}	model.ErrResponseDontShowError				"未授权访问"
+//	@Failure		403	{object}	model.ErrResponseDontShowError				"禁止访问"
+//	@Failure		404	{object}	model.ErrResponseDontShowError				"告警规则不存在"
+//	@Failure		500	{object}	model.ErrResponseDontShowError				"服务器内部错误"
+//	@Router			/api/v1/alert/rule/{id} [put]
+func (c *AlertControllerImpl) UpdateAlertRule(ctx *gin.Context) {
+	id := ctx.Param("id")
+	var req dto.UpdateAlertRuleRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		c.logger.Warn().Err(err).Str("id", id).Msg("请求参数绑定失败")
+		response.BadRequest(ctx, err, true)
+		return
+	}
+
+	objectID, err := bson.ObjectIDFromHex(id)
+	if err != nil {
+		c.logger.Error().Err(err).Str("id", id).Msg("无效的ID格式")
+		response.BadRequest(ctx, err, true)
+		return
+	}
+
+	rule, err := c.alertService.UpdateAlertRule(ctx, objectID, &req)
+	if err != nil {
+		if errors.Is(err, service.ErrAlertRuleNotFound) {
+			response.NotFound(ctx, err)
+			return
+		} else if errors.Is(err, service.ErrInvalidAlertRule) {
+			response.BadRequest(ctx, err, true)
+			return
+		}
+		c.logger.Error().Err(err).Str("id", id).Msg("更新告警规则失败")
+		response.InternalServerError(ctx, err, false)
+		return
+	}
+
+	response.Success(ctx, "告警规则更新成功", rule)
+}
+
+// DeleteAlertRule 删除告警规则
+//
+//	@Summary		删除告警规则
+//	@Description	删除指定的告警规则，已有投递历史保留
+//	@Tags			告警管理
+//	@Produce		json
+//	@Param			id	path	string	true	"告警规则ID"
+//	@Security		BearerAuth
+//	@Success		200	{object}	model.SuccessResponseNoData		"告警规则删除成功"
+//	@Failure		400	{object}	model.ErrResponse				"请求参数错误"
+//	@Failure		401	{object}	model.ErrResponseDontShowError	"未授权访问"
+//	@Failure		403	{object}	model.ErrResponseDontShowError	"禁止访问"
+//	@Failure		404	{object}	model.ErrResponseDontShowError	"告警规则不存在"
+//	@Failure		500	{object}	model.ErrResponseDontShowError	"服务器内部错误"
+//	@Router			/api/v1/alert/rule/{id} [delete]
+func (c *AlertControllerImpl) DeleteAlertRule(ctx *gin.Context) {
+	id := ctx.Param("id")
+	objectID, err := bson.ObjectIDFromHex(id)
+	if err != nil {
+		c.logger.Error().Err(err).Str("id", id).Msg("无效的ID格式")
+		response.BadRequest(ctx, err, true)
+		return
+	}
+
+	if err := c.alertService.DeleteAlertRule(ctx, objectID); err != nil {
+		if errors.Is(err, service.ErrAlertRuleNotFound) {
+			response.NotFound(ctx, err)
+			return
+		}
+		c.logger.Error().Err(err).Str("id", id).Msg("删除告警规则失败")
+		response.InternalServerError(ctx, err, false)
+		return
+	}
+
+	response.Success(ctx, "告警规则删除成功", nil)
+}
+
+// GetAlertHistory 查询告警投递历史
+//
+//	@Summary		查询告警投递历史
+//	@Description	分页查询告警投递记录，支持按规则、投递结果和时间范围过滤
+//	@Tags			告警管理
+//	@Produce		json
+//	@Param			ruleId		query	string	false	"告警规则ID"
+//	@Param			success		query	boolean	false	"是否投递成功"
+//	@Param			startTime	query	string	false	"查询起始时间 (ISO8601格式)"
+//	@Param			endTime		query	string	false	"查询结束时间 (ISO8601格式)"
+//	@Param			page		query	integer	false	"当前页码 (默认: 1)"
+//	@Param			pageSize	query	integer	false	"每页记录数，最大100条 (默认: 10)"
+//	@Security		BearerAuth
+//	@Success		200	{object}	model.SuccessResponse{data=dto.AlertHistoryResponse}	"获取告警投递历史成功"
+//	@Failure		400	{object}	model.ErrResponse										"请求参数错误"
+//	@Failure		500	{object}	model.ErrResponseDontShowError							"服务器内部错误"
+//	@Router			/api/v1/alert/history [get]
+func (c *AlertControllerImpl) GetAlertHistory(ctx *gin.Context) {
+	var req dto.AlertHistoryRequest
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		response.BadRequest(ctx, err, true)
+		return
+	}
+
+	page := req.Page
+	if page <= 0 {
+		page = 1
+	}
+
+	pageSize := req.PageSize
+	if pageSize <= 0 {
+		pageSize = 10
+	} else if pageSize > 100 {
+		pageSize = 100
+	}
+
+	result, err := c.alertService.GetAlertHistory(ctx, req, page, pageSize)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidAlertRule) {
+			response.BadRequest(ctx, err, true)
+			return
+		}
+		response.InternalServerError(ctx, err, false)
+		return
+	}
+
+	response.Success(ctx, "获取告警投递历史成功", result)
+}
+
+// TestChannel 测试告警通道
+//
+//	@Summary		测试告警通道
+//	@Description	向指定通道发送一条测试告警，用于验证 webhook、SMTP 或 HTTP 通道配置
+//	@Tags			告警管理
+//	@Accept			json
+//	@Produce		json
+//	@Param			channel	body	dto.AlertChannelTestRequest	true	"待测试的通道"
+//	@Security		BearerAuth
+//	@Success		200	{object}	model.SuccessResponseNoData		"测试告警发送成功"
+//	@Failure		400	{object}	model.ErrResponse				"请求参数错误"
+//	@Failure		502	{object}	model.ErrResponse				"测试告警发送失败"
+//	@Router			/api/v1/alert/channel/test [post]
+func (c *AlertControllerImpl) TestChannel(ctx *gin.Context) {
+	var req dto.AlertChannelTestRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(ctx, err, true)
+		return
+	}
+
+	if err := c.alertService.TestChannel(ctx, &req); err != nil {
+		response.Error(ctx, model.NewAPIError(http.StatusBadGateway, "测试告警发送失败", err), true)
+		return
+	}
+
+	response.Success(ctx, "测试告警发送成功", nil)
+}
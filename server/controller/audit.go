@@ -0,0 +1,90 @@
+package controller
+
+import (
+	"github.com/HUAHUAI23/simple-waf/server/config"
+	"github.com/HUAHUAI23/simple-waf/server/dto"
+	"github.com/HUAHUAI23/simple-waf/server/model"
+	"github.com/HUAHUAI23/simple-waf/server/service"
+	"github.com/HUAHUAI23/simple-waf/server/utils/response"
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog"
+)
+
+// AuditController 审计控制器接口
+type AuditController interface {
+	GetAuditLogs(ctx *gin.Context)
+}
+
+// AuditControllerImpl 审计控制器实现
+type AuditControllerImpl struct {
+	auditService service.AuditService
+	logger       zerolog.Logger
+}
+
+// NewAuditController 创建审计控制器
+func NewAuditController(auditService service.AuditService) AuditController {
+	logger := config.GetControllerLogger("audit")
+	return &AuditControllerImpl{
+		auditService: auditService,
+		logger:       logger,
+	}
+}
+
+// GetAuditLogs 查询审计日志
+//
+//	@Summary		查询审计日志
+//	@Description	分页查询审计日志，支持按用户、操作类型和时间范围过滤
+//	@Tags			审计日志
+//	@Produce		json
+//	@Param			username	query	string	false	"操作用户名"
+//	@Param			action		query	string	false	"操作类型，如 waf_log.export"
+//	@Param			startTime	query	string	false	"查询起始时间 (ISO8601格式)"
+//	@Param			endTime		query	string	false	"查询结束时间 (ISO8601格式)"
+//	@Param			page		query	integer	false	"当前页码 (默认: 1)"
+//	@Param			pageSize	query	integer	false	"每页记录数，最大100条 (默认: 10)"
+//	@Security		BearerAuth
+//	@Success		200	{object}	model.SuccessResponse{data=dto.AuditLogResponse}	"获取审计日志成功"
+//	@Failure		400	{object}	model.ErrResponse									"请求参数错误"
+//	@Failure		500	{object}	model.ErrResponseDontShowError						"服务器内部错误"
+//	@Router			/api/v1/audit [get]
+func (c *AuditControllerImpl) GetAuditLogs(ctx *gin.Context) {
+	var req dto.AuditLogRequest
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		response.BadRequest(ctx, err, true)
+		return
+	}
+
+	page := req.Page
+	if page <= 0 {
+		page = 1
+	}
+
+	pageSize := req.PageSize
+	if pageSize <= 0 {
+		pageSize = 10
+	} else if pageSize > 100 {
+		pageSize = 100
+	}
+
+	result, err := c.auditService.GetAuditLogs(ctx, req, page, pageSize)
+	if err != nil {
+		c.logger.Error().Err(err).Msg("获取审计日志失败")
+		response.InternalServerError(ctx, err, false)
+		return
+	}
+
+	response.Success(ctx, "获取审计日志成功", result)
+}
+
+// newAuditLog 从请求上下文中提取操作者信息，构建审计记录
+func newAuditLog(ctx *gin.Context, action, resource string) *model.AuditLog {
+	return &model.AuditLog{
+		UserID:    ctx.GetString("userID"),
+		Username:  ctx.GetString("username"),
+		Role:      ctx.GetString("userRole"),
+		Action:    action,
+		Resource:  resource,
+		ClientIP:  ctx.ClientIP(),
+		RequestID: ctx.GetString("RequestID"),
+	}
+}
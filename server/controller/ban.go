@@ -0,0 +1,428 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/HUAHUAI23/simple-waf/server/config"
+	"github.com/HUAHUAI23/simple-waf/server/dto"
+	"github.com/HUAHUAI23/simple-waf/server/model"
+	"github.com/HUAHUAI23/simple-waf/server/service"
+	"github.com/HUAHUAI23/simple-waf/server/utils/response"
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// BanController 自动封禁控制器接口
+type BanController interface {
+	CreateBanPolicy(ctx *gin.Context)
+	GetBanPolicies(ctx *gin.Context)
+	GetBanPolicyByID(ctx *gin.Context)
+	UpdateBanPolicy(ctx *gin.Context)
+	DeleteBanPolicy(ctx *gin.Context)
+	ListBans(ctx *gin.Context)
+	GetBan(ctx *gin.Context)
+	CreateBan(ctx *gin.Context)
+	RevokeBan(ctx *gin.Context)
+}
+
+// BanControllerImpl 自动封禁控制器实现
+type BanControllerImpl struct {
+	banService   service.BanService
+	auditService service.AuditService
+	logger       zerolog.Logger
+}
+
+// NewBanController 创建自动封禁控制器
+func NewBanController(banService service.BanService, auditService service.AuditService) BanController {
+	logger := config.GetControllerLogger("ban")
+	return &BanControllerImpl{
+		banService:   banService,
+		auditService: auditService,
+		logger:       logger,
+	}
+}
+
+// CreateBanPolicy 创建封禁策略
+//
+//	@Summary		创建封禁策略
+//	@Description	创建一条自动封禁策略，同一IP在时间窗口内命中阈值条 WAF 拦截或 Suricata 告警时自动封禁
+//	@Tags			自动封禁
+//	@Accept			json
+//	@Produce		json
+//	@Param			policy	body	dto.CreateBanPolicyRequest	true	"封禁策略信息"
+//	@Security		BearerAuth
+//	@Success		200	{object}	model.SuccessResponse{data=model.BanPolicy}	"封禁策略创建成功"
+//	@Failure		400	{object}	model.ErrResponse							"请求参数错误"
+//	@Failure		401	{object}	model.ErrResponseDontShowError				"未授权访问"
+//	@Failure		403	{object}	model.ErrResponseDontShowError				"禁止访问"
+//	@Failure		500	{object}	model.ErrResponseDontShowError				"服务器内部错误"
+//	@Router			/api/v1/ban/policy [post]
+func (c *BanControllerImpl) CreateBanPolicy(ctx *gin.Context) {
+	var req dto.CreateBanPolicyRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		c.logger.Warn().Err(err).Msg("请求参数绑定失败")
+		response.BadRequest(ctx, err, true)
+		return
+	}
+
+	policy, err := c.banService.CreateBanPolicy(ctx, &req)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidBanPolicy) {
+			response.BadRequest(ctx, err, true)
+			return
+		}
+		c.logger.Error().Err(err).Msg("创建封禁策略失败")
+		response.InternalServerError(ctx, err, false)
+		return
+	}
+
+	response.Success(ctx, "封禁策略创建成功", policy)
+}
+
+// GetBanPolicies 获取封禁策略列表
+//
+//	@Summary		获取封禁策略列表
+//	@Description	获取所有封禁策略，支持分页
+//	@Tags			自动封禁
+//	@Produce		json
+//	@Param			page	query	int	false	"页码"	default(1)
+//	@Param			size	query	int	false	"每页数量"	default(10)
+//	@Security		BearerAuth
+//	@Success		200	{object}	model.SuccessResponse{data=dto.BanPolicyListResponse}	"获取封禁策略列表成功"
+//	@Failure		401	{object}	model.ErrResponseDontShowError							"未授权访问"
+//	@Failure		500	{object}	model.ErrResponseDontShowError							"服务器内部错误"
+//	@Router			/api/v1/ban/policy [get]
+func (c *BanControllerImpl) GetBanPolicies(ctx *gin.Context) {
+	page := ctx.DefaultQuery("page", "1")
+	size := ctx.DefaultQuery("size", "10")
+
+	policies, total, err := c.banService.GetBanPolicies(ctx, page, size)
+	if err != nil {
+		c.logger.Error().Err(err).Msg("获取封禁策略列表失败")
+		response.InternalServerError(ctx, err, false)
+		return
+	}
+
+	response.Success(ctx, "获取封禁策略列表成功", dto.BanPolicyListResponse{
+		Total: total,
+		Items: policies,
+	})
+}
+
+// GetBanPolicyByID 获取单个封禁策略
+//
+//	@Summary		获取封禁策略详情
+//	@Description	根据ID获取封禁策略详情
+//	@Tags			自动封禁
+//	@Produce		json
+//	@Param			id	path	string	true	"封禁策略ID"
+//	@Security		BearerAuth
+//	@Success		200	{object}	model.SuccessResponse{data=model.BanPolicy}	"获取封禁策略详情成功"
+//	@Failure		400	{object}	model.ErrResponse							"请求参数错误"
+//	@Failure		401	{object}	model.ErrResponseDontShowError				"未授权访问"
+//	@Failure		404	{object}	model.ErrResponseDontShowError				"封禁策略不存在"
+//	@Failure		500	{object}	model.ErrResponseDontShowError				"服务器内部错误"
+//	@Router			/api/v1/ban/policy/{id} [get]
+func (c *BanControllerImpl) GetBanPolicyByID(ctx *gin.Context) {
+	id := ctx.Param("id")
+	objectID, err := bson.ObjectIDFromHex(id)
+	if err != nil {
+		c.logger.Error().Err(err).Str("id", id).Msg("无效的ID格式")
+		response.BadRequest(ctx, err, true)
+		return
+	}
+
+	policy, err := c.banService.GetBanPolicyByID(ctx, objectID)
+	if err != nil {
+		if errors.Is(err, service.ErrBanPolicyNotFound) {
+			response.NotFound(ctx, err)
+			return
+		}
+		c.logger.Error().Err(err).Str("id", id).Msg("获取封禁策略详情失败")
+		response.InternalServerError(ctx, err, false)
+		return
+	}
+
+	response.Success(ctx, "获取封禁策略详情成功", policy)
+}
+
+// UpdateBanPolicy 更新封禁策略
+//
+//	@Summary		更新封禁策略
+//	@Description	更新指定封禁策略，只修改请求中出现的字段，新条件从下一轮评估开始生效
+//	@Tags			自动封禁
+//	@Accept			json
+//	@Produce		json
+//	@Param			id		path	string						true	"封禁策略ID"
+//	@Param			policy	body	dto.UpdateBanPolicyRequest	true	"封禁策略更新信息"
+//	@Security		BearerAuth
+//	@Success		200	{object}	model.SuccessResponse{data=model.BanPolicy}	"封禁策略更新成功"
+//	@Failure		400	{object}	model.ErrResponse							"请求参数错误"
+//	@Failure		401	{object}	model.ErrResponseDontShowError				"未授权访问"
+//	@Failure		403	{object}	model.ErrResponseDontShowError				"禁止访问"
+//	@Failure		404	{object}	model.ErrResponseDontShowError				"封禁策略不存在"
+//	@Failure		500	{object}	model.ErrResponseDontShowError				"服务器内部错误"
+//	@Router			/api/v1/ban/policy/{id} [put]
+func (c *BanControllerImpl) UpdateBanPolicy(ctx *gin.Context) {
+	id := ctx.Param("id")
+	var req dto.UpdateBanPolicyRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		c.logger.Warn().Err(err).Str("id", id).Msg("请求参数绑定失败")
+		response.BadRequest(ctx, err, true)
+		return
+	}
+
+	objectID, err := bson.ObjectIDFromHex(id)
+	if err != nil {
+		c.logger.Error().Err(err).Str("id", id).Msg("无效的ID格式")
+		response.BadRequest(ctx, err, true)
+		return
+	}
+
+	policy, err := c.banService.UpdateBanPolicy(ctx, objectID, &req)
+	if err != nil {
+		if errors.Is(err, service.ErrBanPolicyNotFound) {
+			response.NotFound(ctx, err)
+			return
+		} else if errors.Is(err, service.ErrInvalidBanPolicy) {
+			response.BadRequest(ctx, err, true)
+			return
+		}
+		c.logger.Error().Err(err).Str("id", id).Msg("更新封禁策略失败")
+		response.InternalServerError(ctx, err, false)
+		return
+	}
+
+	response.Success(ctx, "封禁策略更新成功", policy)
+}
+
+// DeleteBanPolicy 删除封禁策略
+//
+//	@Summary		删除封禁策略
+//	@Description	删除指定的封禁策略，已产生的封禁保持生效直到到期或手动解除
+//	@Tags			自动封禁
+//	@Produce		json
+//	@Param			id	path	string	true	"封禁策略ID"
+//	@Security		BearerAuth
+//	@Success		200	{object}	model.SuccessResponseNoData		"封禁策略删除成功"
+//	@Failure		400	{object}	model.ErrResponse				"请求参数错误"
+//	@Failure		401	{object}	model.ErrResponseDontShowError	"未授权访问"
+//	@Failure		403	{object}	model.ErrResponseDontShowError	"禁止访问"
+//	@Failure		404	{object}	model.ErrResponseDontShowError	"封禁策略不存在"
+//	@Failure		500	{object}	model.ErrResponseDontShowError	"服务器内部错误"
+//	@Router			/api/v1/ban/policy/{id} [delete]
+func (c *BanControllerImpl) DeleteBanPolicy(ctx *gin.Context) {
+	id := ctx.Param("id")
+	objectID, err := bson.ObjectIDFromHex(id)
+	if err != nil {
+		c.logger.Error().Err(err).Str("id", id).Msg("无效的ID格式")
+		response.BadRequest(ctx, err, true)
+		return
+	}
+
+	if err := c.banService.DeleteBanPolicy(ctx, objectID); err != nil {
+		if errors.Is(err, service.ErrBanPolicyNotFound) {
+			response.NotFound(ctx, err)
+			return
+		}
+		c.logger.Error().Err(err).Str("id", id).Msg("删除封禁策略失败")
+		response.InternalServerError(ctx, err, false)
+		return
+	}
+
+	response.Success(ctx, "封禁策略删除成功", nil)
+}
+
+// ListBans 查询封禁记录
+//
+//	@Summary		查询封禁记录
+//	@Description	分页查询封禁记录，支持按IP、来源、状态、策略和封禁时间过滤，列表中不包含触发事件
+//	@Tags			自动封禁
+//	@Produce		json
+//	@Param			ip			query	string	false	"被封禁的IP"
+//	@Param			source		query	string	false	"封禁来源 waf/ids/manual"
+//	@Param			active		query	boolean	false	"是否生效中"
+//	@Param			policyId	query	string	false	"封禁策略ID"
+//	@Param			startTime	query	string	false	"封禁开始时间起点 (ISO8601格式)"
+//	@Param			endTime		query	string	false	"封禁开始时间终点 (ISO8601格式)"
+//	@Param			page		query	integer	false	"当前页码 (默认: 1)"
+//	@Param			pageSize	query	integer	false	"每页记录数，最大100条 (默认: 10)"
+//	@Security		BearerAuth
+//	@Success		200	{object}	model.SuccessResponse{data=dto.BanListResponse}	"获取封禁记录成功"
+//	@Failure		400	{object}	model.ErrResponse								"请求参数错误"
+//	@Failure		500	{object}	model.ErrResponseDontShowError					"服务器内部错误"
+//	@Router			/api/v1/ban/record [get]
+func (c *BanControllerImpl) ListBans(ctx *gin.Context) {
+	var req dto.BanListRequest
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		response.BadRequest(ctx, err, true)
+		return
+	}
+
+	page := req.Page
+	if page <= 0 {
+		page = 1
+	}
+
+	pageSize := req.PageSize
+	if pageSize <= 0 {
+		pageSize = 10
+	} else if pageSize > 100 {
+		pageSize = 100
+	}
+
+	result, err := c.banService.ListBans(ctx, req, page, pageSize)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidBanRequest) {
+			response.BadRequest(ctx, err, true)
+			return
+		}
+		c.logger.Error().Err(err).Msg("获取封禁记录失败")
+		response.InternalServerError(ctx, err, false)
+		return
+	}
+
+	response.Success(ctx, "获取封禁记录成功", result)
+}
+
+// GetBan 获取封禁记录详情
+//
+//	@Summary		获取封禁记录详情
+//	@Description	根据ID获取封禁记录，包含触发封禁的 WAF 日志和 Suricata 告警
+//	@Tags			自动封禁
+//	@Produce		json
+//	@Param			id	path	string	true	"封禁记录ID"
+//	@Security		BearerAuth
+//	@Success		200	{object}	model.SuccessResponse{data=model.Ban}	"获取封禁记录详情成功"
+//	@Failure		400	{object}	model.ErrResponse						"请求参数错误"
+//	@Failure		404	{object}	model.ErrResponseDontShowError			"封禁记录不存在"
+//	@Failure		500	{object}	model.ErrResponseDontShowError			"服务器内部错误"
+//	@Router			/api/v1/ban/record/{id} [get]
+func (c *BanControllerImpl) GetBan(ctx *gin.Context) {
+	id := ctx.Param("id")
+	objectID, err := bson.ObjectIDFromHex(id)
+	if err != nil {
+		c.logger.Error().Err(err).Str("id", id).Msg("无效的ID格式")
+		response.BadRequest(ctx, err, true)
+		return
+	}
+
+	record, err := c.banService.GetBan(ctx, objectID)
+	if err != nil {
+		if errors.Is(err, service.ErrBanNotFound) {
+			response.NotFound(ctx, err)
+			return
+		}
+		c.logger.Error().Err(err).Str("id", id).Msg("获取封禁记录详情失败")
+		response.InternalServerError(ctx, err, false)
+		return
+	}
+
+	response.Success(ctx, "获取封禁记录详情成功", record)
+}
+
+// CreateBan 手动封禁
+//
+//	@Summary		手动封禁IP
+//	@Description	手动封禁一个IP或网段，立即同步到 HAProxy，durationMinutes 为 0 表示永久封禁
+//	@Tags			自动封禁
+//	@Accept			json
+//	@Produce		json
+//	@Param			ban	body	dto.CreateBanRequest	true	"封禁信息"
+//	@Security		BearerAuth
+//	@Success		200	{object}	model.SuccessResponse{data=model.Ban}	"封禁成功"
+//	@Failure		400	{object}	model.ErrResponse						"请求参数错误"
+//	@Failure		403	{object}	model.ErrResponseDontShowError			"禁止访问"
+//	@Failure		409	{object}	model.ErrResponseDontShowError			"该IP已被封禁"
+//	@Failure		500	{object}	model.ErrResponseDontShowError			"服务器内部错误"
+//	@Router			/api/v1/ban/record [post]
+func (c *BanControllerImpl) CreateBan(ctx *gin.Context) {
+	var req dto.CreateBanRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(ctx, err, true)
+		return
+	}
+
+	record, err := c.banService.CreateBan(ctx, &req, ctx.GetString("username"))
+
+	auditLog := newAuditLog(ctx, model.AuditActionBanCreate, req.IP)
+	auditLog.Detail = map[string]any{"durationMinutes": req.DurationMinutes, "reason": req.Reason}
+	if record != nil {
+		auditLog.Detail["banId"] = record.ID.Hex()
+	}
+	c.recordAudit(auditLog, err)
+
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrInvalidBanRequest):
+			response.BadRequest(ctx, err, true)
+		case errors.Is(err, service.ErrBanExists):
+			response.Error(ctx, model.NewAPIError(http.StatusConflict, "该IP已被封禁", err), false)
+		default:
+			c.logger.Error().Err(err).Str("ip", req.IP).Msg("手动封禁失败")
+			response.InternalServerError(ctx, err, false)
+		}
+		return
+	}
+
+	response.Success(ctx, "封禁成功", record)
+}
+
+// RevokeBan 解除封禁
+//
+//	@Summary		解除封禁
+//	@Description	手动解除一条生效中的封禁，立即从 HAProxy 封禁列表中移除
+//	@Tags			自动封禁
+//	@Produce		json
+//	@Param			id	path	string	true	"封禁记录ID"
+//	@Security		BearerAuth
+//	@Success		200	{object}	model.SuccessResponse{data=model.Ban}	"解除封禁成功"
+//	@Failure		400	{object}	model.ErrResponse						"请求参数错误"
+//	@Failure		403	{object}	model.ErrResponseDontShowError			"禁止访问"
+//	@Failure		404	{object}	model.ErrResponseDontShowError			"封禁记录不存在或已失效"
+//	@Failure		500	{object}	model.ErrResponseDontShowError			"服务器内部错误"
+//	@Router			/api/v1/ban/record/{id}/revoke [post]
+func (c *BanControllerImpl) RevokeBan(ctx *gin.Context) {
+	id := ctx.Param("id")
+	objectID, err := bson.ObjectIDFromHex(id)
+	if err != nil {
+		c.logger.Error().Err(err).Str("id", id).Msg("无效的ID格式")
+		response.BadRequest(ctx, err, true)
+		return
+	}
+
+	record, err := c.banService.RevokeBan(ctx, objectID, ctx.GetString("username"))
+
+	auditLog := newAuditLog(ctx, model.AuditActionBanRevoke, id)
+	if record != nil {
+		auditLog.Detail = map[string]any{"ip": record.IP, "source": record.Source}
+	}
+	c.recordAudit(auditLog, err)
+
+	if err != nil {
+		if errors.Is(err, service.ErrBanNotFound) {
+			response.NotFound(ctx, err)
+			return
+		}
+		c.logger.Error().Err(err).Str("id", id).Msg("解除封禁失败")
+		response.InternalServerError(ctx, err, false)
+		return
+	}
+
+	response.Success(ctx, "解除封禁成功", record)
+}
+
+// recordAudit 记录手动封禁操作的审计日志
+func (c *BanControllerImpl) recordAudit(auditLog *model.AuditLog, err error) {
+	auditLog.Success = err == nil
+	if err != nil {
+		auditLog.Error = err.Error()
+	}
+	auditCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_ = c.auditService.Record(auditCtx, auditLog)
+}
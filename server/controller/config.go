@@ -94,6 +94,10 @@ func (c *ConfigControllerImpl) PatchConfig(ctx *gin.Context) {
 		if errors.Is(err, service.ErrConfigNotFound) {
 			response.NotFound(ctx, err)
 			return
+		} else if errors.Is(err, service.ErrSIEMAddressRequired) || errors.Is(err, service.ErrDuplicateRetentionPolicy) ||
+			errors.Is(err, service.ErrArchiveDirRequired) {
+			response.BadRequest(ctx, err, true)
+			return
 		}
 		c.logger.Error().Err(err).Msg("更新配置失败")
 		response.InternalServerError(ctx, err, false)
@@ -137,10 +141,39 @@ func mapConfigToDTO(cfg *model.Config) dto.ConfigResponse {
 		Thread:        cfg.Haproxy.Thread,
 	}
 
+	// 转换SIEM转发配置
+	siemDTO := dto.SIEMDTO{
+		Enabled:     cfg.SIEM.Enabled,
+		Format:      cfg.SIEM.Format,
+		Protocol:    cfg.SIEM.Protocol,
+		Address:     cfg.SIEM.Address,
+		TLSInsecure: cfg.SIEM.TLSInsecure,
+		Facility:    cfg.SIEM.Facility,
+		BufferSize:  cfg.SIEM.BufferSize,
+		ForwardWAF:  cfg.SIEM.ForwardWAF,
+		ForwardIDS:  cfg.SIEM.ForwardIDS,
+	}
+
+	// 转换日志保留配置
+	retentionDTO := dto.RetentionDTO{
+		ArchiveDir:  cfg.Retention.ArchiveDir,
+		RestoreDays: cfg.Retention.RestoreDays,
+		Policies:    make([]dto.RetentionPolicyDTO, len(cfg.Retention.Policies)),
+	}
+	for i, p := range cfg.Retention.Policies {
+		retentionDTO.Policies[i] = dto.RetentionPolicyDTO{
+			Collection: p.Collection,
+			Days:       p.Days,
+			Archive:    p.Archive,
+		}
+	}
+
 	return dto.ConfigResponse{
 		Name:            cfg.Name,
 		Engine:          engineDTO,
 		Haproxy:         haproxyDTO,
+		SIEM:            siemDTO,
+		Retention:       retentionDTO,
 		CreatedAt:       cfg.CreatedAt,
 		UpdatedAt:       cfg.UpdatedAt,
 		IsResponseCheck: cfg.IsResponseCheck,
@@ -0,0 +1,243 @@
+package controller
+
+import (
+	"errors"
+
+	"github.com/HUAHUAI23/simple-waf/server/config"
+	"github.com/HUAHUAI23/simple-waf/server/dto"
+	"github.com/HUAHUAI23/simple-waf/server/service"
+	"github.com/HUAHUAI23/simple-waf/server/utils/response"
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// IncidentController 安全事件控制器接口
+type IncidentController interface {
+	ListIncidents(ctx *gin.Context)
+	GetIncident(ctx *gin.Context)
+	GetTimeline(ctx *gin.Context)
+	UpdateIncident(ctx *gin.Context)
+	AddComment(ctx *gin.Context)
+}
+
+// IncidentControllerImpl 安全事件控制器实现
+type IncidentControllerImpl struct {
+	incidentService service.IncidentService
+	logger          zerolog.Logger
+}
+
+// NewIncidentController 创建安全事件控制器
+func NewIncidentController(incidentService service.IncidentService) IncidentController {
+	logger := config.GetControllerLogger("incident")
+	return &IncidentControllerImpl{
+		incidentService: incidentService,
+		logger:          logger,
+	}
+}
+
+// ListIncidents 获取安全事件列表
+//
+//	@Summary		获取安全事件列表
+//	@Description	分页查询由 WAF 日志和 Suricata 告警关联而成的安全事件，按最近事件时间倒序
+//	@Tags			安全事件
+//	@Produce		json
+//	@Param			status		query	string	false	"处理状态"	Enums(open, acknowledged, false-positive, resolved)
+//	@Param			srcIp		query	string	false	"来源IP"
+//	@Param			target		query	string	false	"目标域名或IP"
+//	@Param			assignee	query	string	false	"负责人"
+//	@Param			startTime	query	string	false	"最近事件时间起点"	format(date-time)
+//	@Param			endTime		query	string	false	"最近事件时间终点"	format(date-time)
+//	@Param			page		query	int		false	"页码"	default(1)
+//	@Param			pageSize	query	int		false	"每页数量"	default(10)
+//	@Security		BearerAuth
+//	@Success		200	{object}	model.SuccessResponse{data=dto.IncidentListResponse}	"获取安全事件列表成功"
+//	@Failure		400	{object}	model.ErrResponse										"请求参数错误"
+//	@Failure		401	{object}	model.ErrResponseDontShowError							"未授权访问"
+//	@Failure		403	{object}	model.ErrResponseDontShowError							"禁止访问"
+//	@Failure		500	{object}	model.ErrResponseDontShowError							"服务器内部错误"
+//	@Router			/api/v1/incidents [get]
+func (c *IncidentControllerImpl) ListIncidents(ctx *gin.Context) {
+	var req dto.IncidentListRequest
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		c.logger.Warn().Err(err).Msg("请求参数绑定失败")
+		response.BadRequest(ctx, err, true)
+		return
+	}
+
+	result, err := c.incidentService.ListIncidents(ctx, req)
+	if err != nil {
+		c.logger.Error().Err(err).Msg("获取安全事件列表失败")
+		response.InternalServerError(ctx, err, false)
+		return
+	}
+
+	response.Success(ctx, "获取安全事件列表成功", result)
+}
+
+// GetIncident 获取安全事件详情
+//
+//	@Summary		获取安全事件详情
+//	@Description	根据ID获取安全事件详情，包含处理备注
+//	@Tags			安全事件
+//	@Produce		json
+//	@Param			id	path	string	true	"安全事件ID"
+//	@Security		BearerAuth
+//	@Success		200	{object}	model.SuccessResponse{data=model.Incident}	"获取安全事件详情成功"
+//	@Failure		400	{object}	model.ErrResponse							"请求参数错误"
+//	@Failure		401	{object}	model.ErrResponseDontShowError				"未授权访问"
+//	@Failure		404	{object}	model.ErrResponseDontShowError				"安全事件不存在"
+//	@Failure		500	{object}	model.ErrResponseDontShowError				"服务器内部错误"
+//	@Router			/api/v1/incidents/{id} [get]
+func (c *IncidentControllerImpl) GetIncident(ctx *gin.Context) {
+	objectID, ok := c.parseID(ctx)
+	if !ok {
+		return
+	}
+
+	incident, err := c.incidentService.GetIncident(ctx, objectID)
+	if err != nil {
+		c.handleError(ctx, err, "获取安全事件详情失败")
+		return
+	}
+
+	response.Success(ctx, "获取安全事件详情成功", incident)
+}
+
+// GetTimeline 获取安全事件时间线
+//
+//	@Summary		获取安全事件时间线
+//	@Description	按时间升序合并安全事件范围内的 WAF 日志和 Suricata 告警，统一字段名
+//	@Tags			安全事件
+//	@Produce		json
+//	@Param			id		path	string	true	"安全事件ID"
+//	@Param			limit	query	int		false	"最多返回的事件数"	default(200)
+//	@Security		BearerAuth
+//	@Success		200	{object}	model.SuccessResponse{data=dto.IncidentTimelineResponse}	"获取安全事件时间线成功"
+//	@Failure		400	{object}	model.ErrResponse											"请求参数错误"
+//	@Failure		401	{object}	model.ErrResponseDontShowError								"未授权访问"
+//	@Failure		404	{object}	model.ErrResponseDontShowError								"安全事件不存在"
+//	@Failure		500	{object}	model.ErrResponseDontShowError								"服务器内部错误"
+//	@Router			/api/v1/incidents/{id}/timeline [get]
+func (c *IncidentControllerImpl) GetTimeline(ctx *gin.Context) {
+	objectID, ok := c.parseID(ctx)
+	if !ok {
+		return
+	}
+
+	var req dto.IncidentTimelineRequest
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		c.logger.Warn().Err(err).Msg("请求参数绑定失败")
+		response.BadRequest(ctx, err, true)
+		return
+	}
+
+	timeline, err := c.incidentService.GetTimeline(ctx, objectID, req.Limit)
+	if err != nil {
+		c.handleError(ctx, err, "获取安全事件时间线失败")
+		return
+	}
+
+	response.Success(ctx, "获取安全事件时间线成功", timeline)
+}
+
+// UpdateIncident 更新安全事件
+//
+//	@Summary		更新安全事件
+//	@Description	修改处理状态（open/acknowledged/false-positive/resolved）或负责人
+//	@Tags			安全事件
+//	@Accept			json
+//	@Produce		json
+//	@Param			id			path	string						true	"安全事件ID"
+//	@Param			incident	body	dto.IncidentUpdateRequest	true	"更新内容"
+//	@Security		BearerAuth
+//	@Success		200	{object}	model.SuccessResponse{data=model.Incident}	"安全事件更新成功"
+//	@Failure		400	{object}	model.ErrResponse							"请求参数错误"
+//	@Failure		401	{object}	model.ErrResponseDontShowError				"未授权访问"
+//	@Failure		403	{object}	model.ErrResponseDontShowError				"禁止访问"
+//	@Failure		404	{object}	model.ErrResponseDontShowError				"安全事件不存在"
+//	@Failure		500	{object}	model.ErrResponseDontShowError				"服务器内部错误"
+//	@Router			/api/v1/incidents/{id} [patch]
+func (c *IncidentControllerImpl) UpdateIncident(ctx *gin.Context) {
+	objectID, ok := c.parseID(ctx)
+	if !ok {
+		return
+	}
+
+	var req dto.IncidentUpdateRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		c.logger.Warn().Err(err).Msg("请求参数绑定失败")
+		response.BadRequest(ctx, err, true)
+		return
+	}
+
+	incident, err := c.incidentService.UpdateIncident(ctx, objectID, &req)
+	if err != nil {
+		c.handleError(ctx, err, "更新安全事件失败")
+		return
+	}
+
+	response.Success(ctx, "安全事件更新成功", incident)
+}
+
+// AddComment 添加处理备注
+//
+//	@Summary		添加安全事件处理备注
+//	@Description	以当前登录用户身份追加一条处理备注
+//	@Tags			安全事件
+//	@Accept			json
+//	@Produce		json
+//	@Param			id		path	string						true	"安全事件ID"
+//	@Param			comment	body	dto.IncidentCommentRequest	true	"备注内容"
+//	@Security		BearerAuth
+//	@Success		200	{object}	model.SuccessResponse{data=model.Incident}	"备注添加成功"
+//	@Failure		400	{object}	model.ErrResponse							"请求参数错误"
+//	@Failure		401	{object}	model.ErrResponseDontShowError				"未授权访问"
+//	@Failure		403	{object}	model.ErrResponseDontShowError				"禁止访问"
+//	@Failure		404	{object}	model.ErrResponseDontShowError				"安全事件不存在"
+//	@Failure		500	{object}	model.ErrResponseDontShowError				"服务器内部错误"
+//	@Router			/api/v1/incidents/{id}/comments [post]
+func (c *IncidentControllerImpl) AddComment(ctx *gin.Context) {
+	objectID, ok := c.parseID(ctx)
+	if !ok {
+		return
+	}
+
+	var req dto.IncidentCommentRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		c.logger.Warn().Err(err).Msg("请求参数绑定失败")
+		response.BadRequest(ctx, err, true)
+		return
+	}
+
+	incident, err := c.incidentService.AddComment(ctx, objectID, ctx.GetString("userID"), ctx.GetString("username"), req.Content)
+	if err != nil {
+		c.handleError(ctx, err, "添加安全事件备注失败")
+		return
+	}
+
+	response.Success(ctx, "备注添加成功", incident)
+}
+
+func (c *IncidentControllerImpl) parseID(ctx *gin.Context) (bson.ObjectID, bool) {
+	id := ctx.Param("id")
+	objectID, err := bson.ObjectIDFromHex(id)
+	if err != nil {
+		c.logger.Error().Err(err).Str("id", id).Msg("无效的ID格式")
+		response.BadRequest(ctx, err, true)
+		return bson.ObjectID{}, false
+	}
+	return objectID, true
+}
+
+func (c *IncidentControllerImpl) handleError(ctx *gin.Context, err error, msg string) {
+	switch {
+	case errors.Is(err, service.ErrIncidentNotFound):
+		response.NotFound(ctx, err)
+	case errors.Is(err, service.ErrAssigneeNotFound):
+		response.BadRequest(ctx, err, true)
+	default:
+		c.logger.Error().Err(err).Msg(msg)
+		response.InternalServerError(ctx, err, false)
+	}
+}
@@ -0,0 +1,124 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/HUAHUAI23/simple-waf/server/config"
+	"github.com/HUAHUAI23/simple-waf/server/dto"
+	"github.com/HUAHUAI23/simple-waf/server/model"
+	"github.com/HUAHUAI23/simple-waf/server/service"
+	"github.com/HUAHUAI23/simple-waf/server/utils/response"
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog"
+)
+
+// RetentionController 日志保留控制器接口
+type RetentionController interface {
+	ListArchives(ctx *gin.Context)
+	RestoreArchive(ctx *gin.Context)
+}
+
+// RetentionControllerImpl 日志保留控制器实现
+type RetentionControllerImpl struct {
+	retentionService service.RetentionService
+	auditService     service.AuditService
+	logger           zerolog.Logger
+}
+
+// NewRetentionController 创建日志保留控制器
+func NewRetentionController(retentionService service.RetentionService, auditService service.AuditService) RetentionController {
+	logger := config.GetControllerLogger("retention")
+	return &RetentionControllerImpl{
+		retentionService: retentionService,
+		auditService:     auditService,
+		logger:           logger,
+	}
+}
+
+// ListArchives 列出归档文件
+//
+//	@Summary		列出归档文件
+//	@Description	列出指定集合在归档目录下的压缩 NDJSON 归档文件
+//	@Tags			日志保留
+//	@Produce		json
+//	@Param			collection	query	string	true	"集合名称"	Enums(waf_log, suricata_events, alert_history, audit_log, access_log)
+//	@Security		BearerAuth
+//	@Success		200	{object}	model.SuccessResponse{data=[]dto.ArchiveDTO}	"获取归档列表成功"
+//	@Failure		400	{object}	model.ErrResponse								"请求参数错误或未配置归档目录"
+//	@Failure		500	{object}	model.ErrResponseDontShowError					"服务器内部错误"
+//	@Router			/api/v1/retention/archives [get]
+func (c *RetentionControllerImpl) ListArchives(ctx *gin.Context) {
+	var req dto.ArchiveListRequest
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		response.BadRequest(ctx, err, true)
+		return
+	}
+
+	archives, err := c.retentionService.ListArchives(ctx, req.Collection)
+	if err != nil {
+		if errors.Is(err, service.ErrArchiveDirNotConfigured) {
+			response.BadRequest(ctx, err, true)
+			return
+		}
+		c.logger.Error().Err(err).Msg("获取归档列表失败")
+		response.InternalServerError(ctx, err, false)
+		return
+	}
+
+	response.Success(ctx, "获取归档列表成功", archives)
+}
+
+// RestoreArchive 恢复归档数据
+//
+//	@Summary		恢复归档数据
+//	@Description	将归档文件重新导入到 <collection>_restored 集合用于调查，恢复的数据按配置的天数自动过期
+//	@Tags			日志保留
+//	@Accept			json
+//	@Produce		json
+//	@Param			request	body	dto.ArchiveRestoreRequest	true	"归档恢复请求"
+//	@Security		BearerAuth
+//	@Success		200	{object}	model.SuccessResponse{data=dto.ArchiveRestoreResponse}	"恢复归档成功"
+//	@Failure		400	{object}	model.ErrResponse										"请求参数错误"
+//	@Failure		404	{object}	model.ErrResponse										"归档文件不存在"
+//	@Failure		500	{object}	model.ErrResponseDontShowError							"服务器内部错误"
+//	@Router			/api/v1/retention/restore [post]
+func (c *RetentionControllerImpl) RestoreArchive(ctx *gin.Context) {
+	var req dto.ArchiveRestoreRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(ctx, err, true)
+		return
+	}
+
+	result, err := c.retentionService.RestoreArchive(ctx, req)
+
+	auditLog := newAuditLog(ctx, model.AuditActionArchiveRestore, req.Collection)
+	auditLog.Detail = map[string]any{"archive": req.Name}
+	if result != nil {
+		auditLog.Detail["targetCollection"] = result.TargetCollection
+		auditLog.Detail["inserted"] = result.Inserted
+	}
+	auditLog.Success = err == nil
+	if err != nil {
+		auditLog.Error = err.Error()
+	}
+	auditCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_ = c.auditService.Record(auditCtx, auditLog)
+
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrArchiveDirNotConfigured), errors.Is(err, service.ErrInvalidArchiveName):
+			response.BadRequest(ctx, err, true)
+		case errors.Is(err, service.ErrArchiveNotFound):
+			response.NotFound(ctx, err)
+		default:
+			c.logger.Error().Err(err).Str("archive", req.Name).Msg("恢复归档失败")
+			response.InternalServerError(ctx, err, false)
+		}
+		return
+	}
+
+	response.Success(ctx, "恢复归档成功", result)
+}
@@ -1,10 +1,14 @@
 package controller
 
 import (
+	"context"
 	"errors"
+	"strconv"
+	"time"
 
 	"github.com/HUAHUAI23/simple-waf/server/config"
 	"github.com/HUAHUAI23/simple-waf/server/dto"
+	"github.com/HUAHUAI23/simple-waf/server/model"
 	"github.com/HUAHUAI23/simple-waf/server/service"
 	"github.com/HUAHUAI23/simple-waf/server/service/daemon"
 	"github.com/HUAHUAI23/simple-waf/server/utils/response"
@@ -16,35 +20,31 @@ import (
 type RunnerController interface {
 	GetStatus(ctx *gin.Context)
 	Control(ctx *gin.Context)
+	ListConfigVersions(ctx *gin.Context)
+	GetConfigVersion(ctx *gin.Context)
+	RollbackConfig(ctx *gin.Context)
 }
 
 // RunnerControllerImpl 运行器控制器实现
 type RunnerControllerImpl struct {
 	runnerService service.RunnerService
+	auditService  service.AuditService
 	logger        zerolog.Logger
 }
 
 // NewRunnerController 创建运行器控制器
-func NewRunnerController(runnerService service.RunnerService) RunnerController {
+func NewRunnerController(runnerService service.RunnerService, auditService service.AuditService) RunnerController {
 	logger := config.GetControllerLogger("runner")
 	return &RunnerControllerImpl{
 		runnerService: runnerService,
+		auditService:  auditService,
 		logger:        logger,
 	}
 }
 
 // getStateString 将ServiceState转换为字符串
 func getStateString(state daemon.ServiceState) string {
-	switch state {
-	case daemon.ServiceRunning:
-		return "running"
-	case daemon.ServiceStopped:
-		return "stopped"
-	case daemon.ServiceError:
-		return "error"
-	default:
-		return "unknown"
-	}
+	return state.String()
 }
 
 // isStateRunning 检查状态是否为运行中
@@ -182,3 +182,112 @@ func (c *RunnerControllerImpl) Control(ctx *gin.Context) {
 	c.logger.Info().Str("action", req.Action).Str("state", resp.State).Msg("运行器操作成功")
 	response.Success(ctx, "操作成功", resp)
 }
+
+// ListConfigVersions 获取HAProxy配置版本列表
+//	@Summary		获取HAProxy配置版本列表
+//	@Description	列出保留的已生效HAProxy配置版本，按版本号倒序，不包含差异内容
+//	@Tags			运行器管理
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Success		200	{object}	model.SuccessResponse{data=dto.HAProxyConfigVersionListResponse}	"获取配置版本列表成功"
+//	@Failure		403	{object}	model.ErrResponseDontShowError									"禁止访问"
+//	@Failure		500	{object}	model.ErrResponseDontShowError									"服务器内部错误"
+//	@Router			/api/runner/haproxy/versions [get]
+func (c *RunnerControllerImpl) ListConfigVersions(ctx *gin.Context) {
+	versions, err := c.runnerService.ListConfigVersions(ctx)
+	if err != nil {
+		c.logger.Error().Err(err).Msg("获取HAProxy配置版本列表失败")
+		response.InternalServerError(ctx, err, false)
+		return
+	}
+
+	response.Success(ctx, "获取配置版本列表成功", dto.HAProxyConfigVersionListResponse{
+		Total: len(versions),
+		Items: versions,
+	})
+}
+
+// GetConfigVersion 获取HAProxy配置版本详情
+//	@Summary		获取HAProxy配置版本详情
+//	@Description	获取指定版本的完整配置内容，以及相对上一个版本的统一格式差异
+//	@Tags			运行器管理
+//	@Produce		json
+//	@Param			version	path	int	true	"配置版本号"
+//	@Security		BearerAuth
+//	@Success		200	{object}	model.SuccessResponse{data=dto.HAProxyConfigVersionResponse}	"获取配置版本成功"
+//	@Failure		400	{object}	model.ErrResponse												"请求参数错误"
+//	@Failure		403	{object}	model.ErrResponseDontShowError									"禁止访问"
+//	@Failure		404	{object}	model.ErrResponseDontShowError									"配置版本不存在"
+//	@Failure		500	{object}	model.ErrResponseDontShowError									"服务器内部错误"
+//	@Router			/api/runner/haproxy/versions/{version} [get]
+func (c *RunnerControllerImpl) GetConfigVersion(ctx *gin.Context) {
+	version, err := strconv.Atoi(ctx.Param("version"))
+	if err != nil || version < 1 {
+		response.BadRequest(ctx, errors.New("无效的配置版本号"), true)
+		return
+	}
+
+	meta, content, err := c.runnerService.GetConfigVersion(ctx, version)
+	if err != nil {
+		if errors.Is(err, service.ErrConfigVersionNotFound) {
+			response.NotFound(ctx, err)
+			return
+		}
+		c.logger.Error().Err(err).Int("version", version).Msg("获取HAProxy配置版本失败")
+		response.InternalServerError(ctx, err, false)
+		return
+	}
+
+	response.Success(ctx, "获取配置版本成功", dto.HAProxyConfigVersionResponse{
+		ConfigVersion: *meta,
+		Content:       content,
+	})
+}
+
+// RollbackConfig 回滚HAProxy配置
+//	@Summary		回滚HAProxy配置
+//	@Description	校验目标版本后原子替换当前配置并重载HAProxy，重载失败时恢复原配置；下一次热重载会按数据库中的站点重新生成配置
+//	@Tags			运行器管理
+//	@Produce		json
+//	@Param			version	path	int	true	"目标配置版本号"
+//	@Security		BearerAuth
+//	@Success		200	{object}	model.SuccessResponse	"回滚成功"
+//	@Failure		400	{object}	model.ErrResponse		"请求参数错误或运行器未在运行"
+//	@Failure		403	{object}	model.ErrResponseDontShowError	"禁止访问"
+//	@Failure		404	{object}	model.ErrResponseDontShowError	"配置版本不存在"
+//	@Failure		500	{object}	model.ErrResponseDontShowError	"服务器内部错误"
+//	@Router			/api/runner/haproxy/versions/{version}/rollback [post]
+func (c *RunnerControllerImpl) RollbackConfig(ctx *gin.Context) {
+	version, err := strconv.Atoi(ctx.Param("version"))
+	if err != nil || version < 1 {
+		response.BadRequest(ctx, errors.New("无效的配置版本号"), true)
+		return
+	}
+
+	err = c.runnerService.RollbackConfig(ctx, version)
+
+	auditLog := newAuditLog(ctx, model.AuditActionHAProxyRollback, strconv.Itoa(version))
+	auditLog.Success = err == nil
+	if err != nil {
+		auditLog.Error = err.Error()
+	}
+	auditCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	_ = c.auditService.Record(auditCtx, auditLog)
+	cancel()
+
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrRunnerNotRunning):
+			response.BadRequest(ctx, err, true)
+		case errors.Is(err, service.ErrConfigVersionNotFound):
+			response.NotFound(ctx, err)
+		default:
+			c.logger.Error().Err(err).Int("version", version).Msg("回滚HAProxy配置失败")
+			response.InternalServerError(ctx, err, false)
+		}
+		return
+	}
+
+	c.logger.Info().Int("version", version).Msg("HAProxy配置回滚成功")
+	response.Success(ctx, "回滚成功", nil)
+}
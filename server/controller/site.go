@@ -3,12 +3,14 @@ package controller
 import (
 	"errors"
 	"net/http"
+	"strconv"
 
 	"github.com/HUAHUAI23/simple-waf/server/config"
 	"github.com/HUAHUAI23/simple-waf/server/dto"
 	"github.com/HUAHUAI23/simple-waf/server/model"
 	"github.com/HUAHUAI23/simple-waf/server/repository"
 	"github.com/HUAHUAI23/simple-waf/server/service"
+	"github.com/HUAHUAI23/simple-waf/server/service/daemon/haproxy"
 	"github.com/HUAHUAI23/simple-waf/server/utils/response"
 	"github.com/gin-gonic/gin"
 	"github.com/rs/zerolog"
@@ -22,20 +24,25 @@ type SiteController interface {
 	GetSiteByID(ctx *gin.Context)
 	UpdateSite(ctx *gin.Context)
 	DeleteSite(ctx *gin.Context)
+	GetSiteHealth(ctx *gin.Context)
+	GetServerState(ctx *gin.Context)
+	UpdateServerState(ctx *gin.Context)
 }
 
 // SiteControllerImpl 站点控制器实现
 type SiteControllerImpl struct {
-	siteService service.SiteService
-	logger      zerolog.Logger
+	siteService   service.SiteService
+	runnerService service.RunnerService
+	logger        zerolog.Logger
 }
 
 // NewSiteController 创建站点控制器
-func NewSiteController(siteService service.SiteService) SiteController {
+func NewSiteController(siteService service.SiteService, runnerService service.RunnerService) SiteController {
 	logger := config.GetControllerLogger("site")
 	return &SiteControllerImpl{
-		siteService: siteService,
-		logger:      logger,
+		siteService:   siteService,
+		runnerService: runnerService,
+		logger:        logger,
 	}
 }
 
@@ -70,6 +77,9 @@ func (c *SiteControllerImpl) CreateSite(ctx *gin.Context) {
 		if errors.Is(err, repository.ErrDomainPortExists) {
 			response.Error(ctx, model.NewAPIError(http.StatusConflict, "域名和端口组合已存在", err), false)
 			return
+		} else if errors.Is(err, service.ErrInvalidSite) {
+			response.BadRequest(ctx, err, true)
+			return
 		}
 		c.logger.Error().Err(err).Msg("创建站点失败")
 		response.InternalServerError(ctx, err, false)
@@ -196,6 +206,9 @@ func (c *SiteControllerImpl) UpdateSite(ctx *gin.Context) {
 		} else if errors.Is(err, repository.ErrDomainPortConflict) {
 			response.Error(ctx, model.NewAPIError(http.StatusConflict, "域名和端口组合已被其他站点使用", err), false)
 			return
+		} else if errors.Is(err, service.ErrInvalidSite) {
+			response.BadRequest(ctx, err, true)
+			return
 		}
 		c.logger.Error().Err(err).Str("id", id).Msg("更新站点失败")
 		response.InternalServerError(ctx, err, false)
@@ -244,3 +257,218 @@ func (c *SiteControllerImpl) DeleteSite(ctx *gin.Context) {
 	c.logger.Info().Str("id", id).Msg("站点删除成功")
 	response.Success(ctx, "站点删除成功", nil)
 }
+
+// GetSiteHealth 获取站点后端服务器的实时状态
+//
+//	@Summary		获取站点后端服务器状态
+//	@Description	通过 HAProxy 运行时 API 获取站点默认后端和路径路由后端中服务器的健康检查结果、权重和连接数
+//	@Tags			站点管理
+//	@Produce		json
+//	@Param			id	path	string	true	"站点ID"
+//	@Security		BearerAuth
+//	@Success		200	{object}	model.SuccessResponse{data=dto.SiteHealthResponse}	"获取站点服务器状态成功"
+//	@Failure		400	{object}	model.ErrResponse									"请求参数错误"
+//	@Failure		401	{object}	model.ErrResponseDontShowError						"未授权访问"
+//	@Failure		404	{object}	model.ErrResponseDontShowError						"站点不存在"
+//	@Failure		500	{object}	model.ErrResponseDontShowError						"服务器内部错误"
+//	@Router			/api/v1/site/{id}/health [get]
+func (c *SiteControllerImpl) GetSiteHealth(ctx *gin.Context) {
+	id := ctx.Param("id")
+
+	objectID, err := bson.ObjectIDFromHex(id)
+	if err != nil {
+		c.logger.Error().Err(err).Str("id", id).Msg("无效的ID格式")
+		response.BadRequest(ctx, err, true)
+		return
+	}
+	site, err := c.siteService.GetSiteByID(ctx, objectID)
+	if err != nil {
+		if errors.Is(err, repository.ErrSiteNotFound) {
+			response.Error(ctx, model.NewAPIError(http.StatusNotFound, "站点不存在", err), false)
+			return
+		}
+		c.logger.Error().Err(err).Str("id", id).Msg("获取站点详情失败")
+		response.InternalServerError(ctx, err, false)
+		return
+	}
+
+	result := dto.SiteHealthResponse{Servers: []haproxy.ServerHealth{}}
+	if c.runnerService == nil {
+		response.Success(ctx, "获取站点服务器状态成功", result)
+		return
+	}
+	servers, err := c.runnerService.GetSiteHealth(ctx, *site)
+	if err != nil {
+		if errors.Is(err, service.ErrRunnerNotRunning) {
+			response.Success(ctx, "获取站点服务器状态成功", result)
+			return
+		}
+		c.logger.Error().Err(err).Str("id", id).Msg("获取站点服务器状态失败")
+		response.InternalServerError(ctx, err, false)
+		return
+	}
+
+	result.Running = true
+	result.Servers = servers
+	response.Success(ctx, "获取站点服务器状态成功", result)
+}
+
+// GetServerState 获取站点后端服务器的状态
+//
+//	@Summary		获取后端服务器状态
+//	@Description	获取站点默认后端中指定服务器保存的管理状态、权重以及 HAProxy 中的实时状态和连接数
+//	@Tags			站点管理
+//	@Produce		json
+//	@Param			id		path	string	true	"站点ID"
+//	@Param			index	path	int		true	"服务器序号，从 0 开始"
+//	@Security		BearerAuth
+//	@Success		200	{object}	model.SuccessResponse{data=dto.ServerStateResponse}	"获取服务器状态成功"
+//	@Failure		400	{object}	model.ErrResponse									"请求参数错误"
+//	@Failure		401	{object}	model.ErrResponseDontShowError						"未授权访问"
+//	@Failure		404	{object}	model.ErrResponseDontShowError						"站点或服务器不存在"
+//	@Failure		500	{object}	model.ErrResponseDontShowError						"服务器内部错误"
+//	@Router			/api/v1/site/{id}/servers/{index} [get]
+func (c *SiteControllerImpl) GetServerState(ctx *gin.Context) {
+	id := ctx.Param("id")
+
+	objectID, err := bson.ObjectIDFromHex(id)
+	if err != nil {
+		c.logger.Error().Err(err).Str("id", id).Msg("无效的ID格式")
+		response.BadRequest(ctx, err, true)
+		return
+	}
+	index, err := strconv.Atoi(ctx.Param("index"))
+	if err != nil {
+		response.BadRequest(ctx, err, true)
+		return
+	}
+
+	site, err := c.siteService.GetSiteByID(ctx, objectID)
+	if err != nil {
+		if errors.Is(err, repository.ErrSiteNotFound) {
+			response.Error(ctx, model.NewAPIError(http.StatusNotFound, "站点不存在", err), false)
+			return
+		}
+		c.logger.Error().Err(err).Str("id", id).Msg("获取站点详情失败")
+		response.InternalServerError(ctx, err, false)
+		return
+	}
+	if index < 0 || index >= len(site.Backend.Servers) {
+		response.NotFound(ctx, service.ErrServerNotFound)
+		return
+	}
+
+	result, err := c.serverState(ctx, site, index)
+	if err != nil {
+		c.logger.Error().Err(err).Str("id", id).Msg("获取服务器状态失败")
+		response.InternalServerError(ctx, err, false)
+		return
+	}
+	response.Success(ctx, "获取服务器状态成功", result)
+}
+
+// UpdateServerState 修改站点后端服务器的管理状态和权重
+//
+//	@Summary		修改后端服务器状态
+//	@Description	将站点默认后端中指定服务器设置为 ready、drain 或 maint，或修改权重；保存到站点配置，运行器运行时立即通过 HAProxy 运行时 API 生效
+//	@Tags			站点管理
+//	@Accept			json
+//	@Produce		json
+//	@Param			id		path	string							true	"站点ID"
+//	@Param			index	path	int								true	"服务器序号，从 0 开始"
+//	@Param			request	body	dto.UpdateServerStateRequest	true	"服务器状态"
+//	@Security		BearerAuth
+//	@Success		200	{object}	model.SuccessResponse{data=dto.ServerStateResponse}	"服务器状态修改成功"
+//	@Failure		400	{object}	model.ErrResponse									"请求参数错误"
+//	@Failure		401	{object}	model.ErrResponseDontShowError						"未授权访问"
+//	@Failure		403	{object}	model.ErrResponseDontShowError						"禁止访问"
+//	@Failure		404	{object}	model.ErrResponseDontShowError						"站点或服务器不存在"
+//	@Failure		500	{object}	model.ErrResponseDontShowError						"服务器内部错误"
+//	@Router			/api/v1/site/{id}/servers/{index} [put]
+func (c *SiteControllerImpl) UpdateServerState(ctx *gin.Context) {
+	id := ctx.Param("id")
+
+	objectID, err := bson.ObjectIDFromHex(id)
+	if err != nil {
+		c.logger.Error().Err(err).Str("id", id).Msg("无效的ID格式")
+		response.BadRequest(ctx, err, true)
+		return
+	}
+	index, err := strconv.Atoi(ctx.Param("index"))
+	if err != nil {
+		response.BadRequest(ctx, err, true)
+		return
+	}
+	var req dto.UpdateServerStateRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		c.logger.Warn().Err(err).Msg("请求参数绑定失败")
+		response.BadRequest(ctx, err, true)
+		return
+	}
+
+	// 运行器未运行时只保存配置，下次启动时生效；应用失败时服务会恢复保存前的配置
+	apply := func(site model.Site) error {
+		if c.runnerService == nil {
+			return nil
+		}
+		if err := c.runnerService.ApplySite(ctx, site); err != nil && !errors.Is(err, service.ErrRunnerNotRunning) {
+			return err
+		}
+		return nil
+	}
+
+	site, err := c.siteService.UpdateServerState(ctx, objectID, index, &req, apply)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrSiteNotFound):
+			response.Error(ctx, model.NewAPIError(http.StatusNotFound, "站点不存在", err), false)
+		case errors.Is(err, service.ErrServerNotFound):
+			response.NotFound(ctx, err)
+		case errors.Is(err, service.ErrInvalidSite):
+			response.BadRequest(ctx, err, true)
+		default:
+			c.logger.Error().Err(err).Str("id", id).Msg("修改服务器状态失败")
+			response.InternalServerError(ctx, err, false)
+		}
+		return
+	}
+
+	result, err := c.serverState(ctx, site, index)
+	if err != nil {
+		c.logger.Error().Err(err).Str("id", id).Msg("获取服务器状态失败")
+		response.InternalServerError(ctx, err, false)
+		return
+	}
+
+	c.logger.Info().Str("id", id).Int("index", index).Str("state", req.State).Msg("服务器状态修改成功")
+	response.Success(ctx, "服务器状态修改成功", result)
+}
+
+// serverState 组合服务器保存的配置和 HAProxy 中的实时状态
+func (c *SiteControllerImpl) serverState(ctx *gin.Context, site *model.Site, index int) (dto.ServerStateResponse, error) {
+	result := dto.ServerStateResponse{
+		Index:  index,
+		Server: site.Backend.Servers[index],
+	}
+	if c.runnerService == nil {
+		return result, nil
+	}
+
+	servers, err := c.runnerService.GetSiteHealth(ctx, *site)
+	if err != nil {
+		if errors.Is(err, service.ErrRunnerNotRunning) {
+			return result, nil
+		}
+		return result, err
+	}
+
+	result.Running = true
+	name := haproxy.SiteServerName(*site, index)
+	for i := range servers {
+		if servers[i].Server == name {
+			result.Runtime = &servers[i]
+			break
+		}
+	}
+	return result, nil
+}
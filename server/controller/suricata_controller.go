@@ -1,61 +1,58 @@
 package controller
 
 import (
-    "net/http"
-    "time"
+	"strconv"
+	"time"
 
-    "github.com/HUAHUAI23/simple-waf/server/service"
-    "github.com/HUAHUAI23/simple-waf/server/utils/response"
-    "github.com/gin-gonic/gin"
+	"github.com/HUAHUAI23/simple-waf/server/service"
+	"github.com/HUAHUAI23/simple-waf/server/utils/response"
+	"github.com/gin-gonic/gin"
 )
 
 type SuricataController struct {
-    svc service.SuricataService
+	svc service.SuricataService
 }
 
 func NewSuricataController(svc service.SuricataService) *SuricataController {
-    return &SuricataController{svc: svc}
-}
-
-// 注册路由
-func RegisterSuricataRoutes(r *gin.Engine) {
-    repo := service.NewSuricataService(
-        repository.NewSuricataRepository(),
-    )
-    ctrl := NewSuricataController(repo)
-
-    api := r.Group("/api/v1/suricata")
-    api.GET("/events", ctrl.ListEvents)
+	return &SuricataController{svc: svc}
 }
 
 // 事件查询接口
 func (s *SuricataController) ListEvents(c *gin.Context) {
-    severity := c.Query("severity")
-    srcIP := c.Query("src_ip")
-    dstIP := c.Query("dst_ip")
-    limit := int64(100)
-
-    if l := c.Query("limit"); l != "" {
-        if parsed, err := ParseInt64(l); err == nil {
-            limit = parsed
-        }
-    }
-
-    // 时间范围
-    start, _ := time.Parse(time.RFC3339, c.DefaultQuery("start", time.Now().Add(-24*time.Hour).Format(time.RFC3339)))
-    end, _ := time.Parse(time.RFC3339, c.DefaultQuery("end", time.Now().Format(time.RFC3339)))
-
-    events, err := s.svc.GetEvents(c.Request.Context(), severity, srcIP, dstIP, start, end, limit)
-    if err != nil {
-        response.InternalServerError(c, err, true)
-        return
-    }
-
-    response.Success(c, "查询成功", events)
+	severity := c.Query("severity")
+	srcIP := c.Query("src_ip")
+	dstIP := c.Query("dst_ip")
+	eventType := c.Query("event_type")
+	limit := int64(100)
+
+	if l := c.Query("limit"); l != "" {
+		if parsed, err := strconv.ParseInt(l, 10, 64); err == nil && parsed > 0 {
+			limit = parsed
+		}
+	}
+
+	// 时间范围
+	start, _ := time.Parse(time.RFC3339, c.DefaultQuery("start", time.Now().Add(-24*time.Hour).Format(time.RFC3339)))
+	end, _ := time.Parse(time.RFC3339, c.DefaultQuery("end", time.Now().Format(time.RFC3339)))
+
+	events, err := s.svc.GetEvents(c.Request.Context(), severity, srcIP, dstIP, eventType, start, end, limit)
+	if err != nil {
+		response.InternalServerError(c, err, true)
+		return
+	}
+
+	response.Success(c, "查询成功", events)
 }
 
-func ParseInt64(s string) (int64, error) {
-    var i int64
-    _, err := fmt.Sscan(s, &i)
-    return i, err
+// IngestHealth EVE 采集状态接口
+//
+//	@Summary		获取 Suricata 事件采集状态
+//	@Description	返回 eve.json 采集器的运行状态、读取进度、积压字节数和错误统计
+//	@Tags			Suricata
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Success		200	{object}	model.SuccessResponse{data=dto.SuricataIngestHealth}	"获取采集状态成功"
+//	@Router			/api/v1/suricata/ingest/health [get]
+func (s *SuricataController) IngestHealth(c *gin.Context) {
+	response.Success(c, "获取采集状态成功", s.svc.GetIngestHealth(c.Request.Context()))
 }
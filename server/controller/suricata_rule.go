@@ -0,0 +1,344 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/HUAHUAI23/simple-waf/server/config"
+	"github.com/HUAHUAI23/simple-waf/server/dto"
+	"github.com/HUAHUAI23/simple-waf/server/model"
+	"github.com/HUAHUAI23/simple-waf/server/service"
+	"github.com/HUAHUAI23/simple-waf/server/service/daemon/suricata"
+	"github.com/HUAHUAI23/simple-waf/server/utils/response"
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// SuricataRuleController Suricata 规则管理控制器接口
+type SuricataRuleController interface {
+	ListRules(ctx *gin.Context)
+	SetRuleState(ctx *gin.Context)
+	CreateLocalRule(ctx *gin.Context)
+	UpdateLocalRule(ctx *gin.Context)
+	DeleteLocalRule(ctx *gin.Context)
+	ImportRuleset(ctx *gin.Context)
+	Reload(ctx *gin.Context)
+	GetStats(ctx *gin.Context)
+}
+
+// SuricataRuleControllerImpl Suricata 规则管理控制器实现
+type SuricataRuleControllerImpl struct {
+	ruleService service.SuricataRuleService
+	logger      zerolog.Logger
+}
+
+// NewSuricataRuleController 创建 Suricata 规则管理控制器
+func NewSuricataRuleController(ruleService service.SuricataRuleService) SuricataRuleController {
+	logger := config.GetControllerLogger("suricata_rule")
+	return &SuricataRuleControllerImpl{
+		ruleService: ruleService,
+		logger:      logger,
+	}
+}
+
+// ListRules 获取 Suricata 规则列表
+//
+//	@Summary		获取 Suricata 规则列表
+//	@Description	分页查询导入规则集和自定义规则，返回每条规则的当前启用状态
+//	@Tags			Suricata规则管理
+//	@Produce		json
+//	@Param			sid			query	int		false	"规则 SID"
+//	@Param			q			query	string	false	"按 msg 模糊搜索"
+//	@Param			source		query	string	false	"规则来源"	Enums(ruleset, local)
+//	@Param			enabled		query	bool	false	"是否启用"
+//	@Param			page		query	int		false	"页码"	default(1)
+//	@Param			pageSize	query	int		false	"每页数量"	default(20)
+//	@Security		BearerAuth
+//	@Success		200	{object}	model.SuccessResponse{data=dto.SuricataRuleListResponse}	"获取规则列表成功"
+//	@Failure		400	{object}	model.ErrResponse											"请求参数错误"
+//	@Failure		401	{object}	model.ErrResponseDontShowError								"未授权访问"
+//	@Failure		403	{object}	model.ErrResponseDontShowError								"禁止访问"
+//	@Failure		500	{object}	model.ErrResponseDontShowError								"服务器内部错误"
+//	@Router			/api/v1/suricata/rules [get]
+func (c *SuricataRuleControllerImpl) ListRules(ctx *gin.Context) {
+	var req dto.SuricataRuleListRequest
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		c.logger.Warn().Err(err).Msg("请求参数绑定失败")
+		response.BadRequest(ctx, err, true)
+		return
+	}
+
+	result, err := c.ruleService.ListRules(ctx, req)
+	if err != nil {
+		c.logger.Error().Err(err).Msg("获取 Suricata 规则列表失败")
+		response.InternalServerError(ctx, err, false)
+		return
+	}
+
+	response.Success(ctx, "获取规则列表成功", result)
+}
+
+// SetRuleState 设置规则启用状态
+//
+//	@Summary		启用或禁用 Suricata 规则
+//	@Description	按 SID 启用或禁用规则并重新加载，规则集规则的状态在重新导入后仍然保留
+//	@Tags			Suricata规则管理
+//	@Accept			json
+//	@Produce		json
+//	@Param			sid		path	int								true	"规则 SID"
+//	@Param			state	body	dto.SuricataRuleStateRequest	true	"启用状态"
+//	@Security		BearerAuth
+//	@Success		200	{object}	model.SuccessResponse{data=dto.SuricataRuleApplyResult}	"规则状态已更新"
+//	@Failure		400	{object}	model.ErrResponse										"请求参数错误"
+//	@Failure		401	{object}	model.ErrResponseDontShowError							"未授权访问"
+//	@Failure		403	{object}	model.ErrResponseDontShowError							"禁止访问"
+//	@Failure		404	{object}	model.ErrResponseDontShowError							"规则不存在"
+//	@Failure		422	{object}	model.ErrResponse										"Suricata 拒绝重新加载，已回滚"
+//	@Failure		503	{object}	model.ErrResponse										"规则已保存但重新加载失败"
+//	@Failure		500	{object}	model.ErrResponseDontShowError							"服务器内部错误"
+//	@Router			/api/v1/suricata/rules/{sid}/state [put]
+func (c *SuricataRuleControllerImpl) SetRuleState(ctx *gin.Context) {
+	sid, err := strconv.Atoi(ctx.Param("sid"))
+	if err != nil || sid <= 0 {
+		response.BadRequest(ctx, errors.New("无效的 SID"), true)
+		return
+	}
+
+	var req dto.SuricataRuleStateRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		c.logger.Warn().Err(err).Msg("请求参数绑定失败")
+		response.BadRequest(ctx, err, true)
+		return
+	}
+
+	if err := c.ruleService.SetRuleState(ctx, sid, *req.Enabled); err != nil {
+		c.handleError(ctx, err, "修改 Suricata 规则状态失败")
+		return
+	}
+
+	response.Success(ctx, "规则状态已更新", dto.SuricataRuleApplyResult{Reloaded: true})
+}
+
+// CreateLocalRule 创建自定义规则
+//
+//	@Summary		创建 Suricata 自定义规则
+//	@Description	语法预检查通过后写入 local.rules 并重新加载，sid 不能与规则集或其他自定义规则重复
+//	@Tags			Suricata规则管理
+//	@Accept			json
+//	@Produce		json
+//	@Param			rule	body	dto.SuricataLocalRuleRequest	true	"自定义规则"
+//	@Security		BearerAuth
+//	@Success		200	{object}	model.SuccessResponse{data=dto.SuricataRuleApplyResult}	"自定义规则创建成功"
+//	@Failure		400	{object}	model.ErrResponse										"规则语法错误"
+//	@Failure		401	{object}	model.ErrResponseDontShowError							"未授权访问"
+//	@Failure		403	{object}	model.ErrResponseDontShowError							"禁止访问"
+//	@Failure		409	{object}	model.ErrResponse										"SID 冲突"
+//	@Failure		422	{object}	model.ErrResponse										"Suricata 拒绝重新加载，已回滚"
+//	@Failure		503	{object}	model.ErrResponse										"规则已保存但重新加载失败"
+//	@Failure		500	{object}	model.ErrResponseDontShowError							"服务器内部错误"
+//	@Router			/api/v1/suricata/rules/local [post]
+func (c *SuricataRuleControllerImpl) CreateLocalRule(ctx *gin.Context) {
+	var req dto.SuricataLocalRuleRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		c.logger.Warn().Err(err).Msg("请求参数绑定失败")
+		response.BadRequest(ctx, err, true)
+		return
+	}
+
+	rule, err := c.ruleService.CreateLocalRule(ctx, &req)
+	if err != nil {
+		c.handleError(ctx, err, "创建 Suricata 自定义规则失败")
+		return
+	}
+
+	response.Success(ctx, "自定义规则创建成功", dto.SuricataRuleApplyResult{Reloaded: true, Rule: rule})
+}
+
+// UpdateLocalRule 更新自定义规则
+//
+//	@Summary		更新 Suricata 自定义规则
+//	@Description	语法预检查通过后更新 local.rules 并重新加载
+//	@Tags			Suricata规则管理
+//	@Accept			json
+//	@Produce		json
+//	@Param			id		path	string							true	"自定义规则ID"
+//	@Param			rule	body	dto.SuricataLocalRuleRequest	true	"自定义规则"
+//	@Security		BearerAuth
+//	@Success		200	{object}	model.SuccessResponse{data=dto.SuricataRuleApplyResult}	"自定义规则更新成功"
+//	@Failure		400	{object}	model.ErrResponse										"规则语法错误"
+//	@Failure		401	{object}	model.ErrResponseDontShowError							"未授权访问"
+//	@Failure		403	{object}	model.ErrResponseDontShowError							"禁止访问"
+//	@Failure		404	{object}	model.ErrResponseDontShowError							"规则不存在"
+//	@Failure		409	{object}	model.ErrResponse										"SID 冲突"
+//	@Failure		422	{object}	model.ErrResponse										"Suricata 拒绝重新加载，已回滚"
+//	@Failure		503	{object}	model.ErrResponse										"规则已保存但重新加载失败"
+//	@Failure		500	{object}	model.ErrResponseDontShowError							"服务器内部错误"
+//	@Router			/api/v1/suricata/rules/local/{id} [put]
+func (c *SuricataRuleControllerImpl) UpdateLocalRule(ctx *gin.Context) {
+	id := ctx.Param("id")
+	objectID, err := bson.ObjectIDFromHex(id)
+	if err != nil {
+		c.logger.Error().Err(err).Str("id", id).Msg("无效的ID格式")
+		response.BadRequest(ctx, err, true)
+		return
+	}
+
+	var req dto.SuricataLocalRuleRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		c.logger.Warn().Err(err).Msg("请求参数绑定失败")
+		response.BadRequest(ctx, err, true)
+		return
+	}
+
+	rule, err := c.ruleService.UpdateLocalRule(ctx, objectID, &req)
+	if err != nil {
+		c.handleError(ctx, err, "更新 Suricata 自定义规则失败")
+		return
+	}
+
+	response.Success(ctx, "自定义规则更新成功", dto.SuricataRuleApplyResult{Reloaded: true, Rule: rule})
+}
+
+// DeleteLocalRule 删除自定义规则
+//
+//	@Summary		删除 Suricata 自定义规则
+//	@Description	删除自定义规则并重新加载
+//	@Tags			Suricata规则管理
+//	@Produce		json
+//	@Param			id	path	string	true	"自定义规则ID"
+//	@Security		BearerAuth
+//	@Success		200	{object}	model.SuccessResponse{data=dto.SuricataRuleApplyResult}	"自定义规则删除成功"
+//	@Failure		400	{object}	model.ErrResponse										"请求参数错误"
+//	@Failure		401	{object}	model.ErrResponseDontShowError							"未授权访问"
+//	@Failure		403	{object}	model.ErrResponseDontShowError							"禁止访问"
+//	@Failure		404	{object}	model.ErrResponseDontShowError							"规则不存在"
+//	@Failure		422	{object}	model.ErrResponse										"Suricata 拒绝重新加载，已回滚"
+//	@Failure		503	{object}	model.ErrResponse										"规则已保存但重新加载失败"
+//	@Failure		500	{object}	model.ErrResponseDontShowError							"服务器内部错误"
+//	@Router			/api/v1/suricata/rules/local/{id} [delete]
+func (c *SuricataRuleControllerImpl) DeleteLocalRule(ctx *gin.Context) {
+	id := ctx.Param("id")
+	objectID, err := bson.ObjectIDFromHex(id)
+	if err != nil {
+		c.logger.Error().Err(err).Str("id", id).Msg("无效的ID格式")
+		response.BadRequest(ctx, err, true)
+		return
+	}
+
+	if err := c.ruleService.DeleteLocalRule(ctx, objectID); err != nil {
+		c.handleError(ctx, err, "删除 Suricata 自定义规则失败")
+		return
+	}
+
+	response.Success(ctx, "自定义规则删除成功", dto.SuricataRuleApplyResult{Reloaded: true})
+}
+
+// ImportRuleset 导入规则集
+//
+//	@Summary		导入 Suricata 规则集
+//	@Description	上传 tar 或 tar.gz 压缩包（如 emerging.rules.tar.gz），其中的 .rules 文件替换当前规则集
+//	@Tags			Suricata规则管理
+//	@Accept			multipart/form-data
+//	@Produce		json
+//	@Param			file	formData	file	true	"规则集压缩包"
+//	@Security		BearerAuth
+//	@Success		200	{object}	model.SuccessResponse{data=dto.SuricataRulesetImportResult}	"规则集导入成功"
+//	@Failure		400	{object}	model.ErrResponse											"压缩包无效"
+//	@Failure		401	{object}	model.ErrResponseDontShowError								"未授权访问"
+//	@Failure		403	{object}	model.ErrResponseDontShowError								"禁止访问"
+//	@Failure		409	{object}	model.ErrResponse											"SID 与自定义规则冲突"
+//	@Failure		422	{object}	model.ErrResponse											"Suricata 拒绝重新加载，已回滚"
+//	@Failure		503	{object}	model.ErrResponse											"规则已保存但重新加载失败"
+//	@Failure		500	{object}	model.ErrResponseDontShowError								"服务器内部错误"
+//	@Router			/api/v1/suricata/rules/import [post]
+func (c *SuricataRuleControllerImpl) ImportRuleset(ctx *gin.Context) {
+	header, err := ctx.FormFile("file")
+	if err != nil {
+		c.logger.Warn().Err(err).Msg("未上传规则集文件")
+		response.BadRequest(ctx, err, true)
+		return
+	}
+
+	file, err := header.Open()
+	if err != nil {
+		c.logger.Error().Err(err).Msg("打开上传的规则集文件失败")
+		response.InternalServerError(ctx, err, false)
+		return
+	}
+	defer file.Close()
+
+	result, err := c.ruleService.ImportRuleset(ctx, file)
+	if err != nil {
+		c.handleError(ctx, err, "导入 Suricata 规则集失败")
+		return
+	}
+
+	response.Success(ctx, "规则集导入成功", result)
+}
+
+// Reload 重新加载规则
+//
+//	@Summary		重新加载 Suricata 规则
+//	@Description	重新生成规则文件并通过命令套接字执行 reload-rules
+//	@Tags			Suricata规则管理
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Success		200	{object}	model.SuccessResponse{data=dto.SuricataRuleApplyResult}	"规则已重新加载"
+//	@Failure		401	{object}	model.ErrResponseDontShowError							"未授权访问"
+//	@Failure		403	{object}	model.ErrResponseDontShowError							"禁止访问"
+//	@Failure		503	{object}	model.ErrResponse										"重新加载失败"
+//	@Failure		500	{object}	model.ErrResponseDontShowError							"服务器内部错误"
+//	@Router			/api/v1/suricata/rules/reload [post]
+func (c *SuricataRuleControllerImpl) Reload(ctx *gin.Context) {
+	if err := c.ruleService.Reload(ctx); err != nil {
+		c.handleError(ctx, err, "重新加载 Suricata 规则失败")
+		return
+	}
+
+	response.Success(ctx, "规则已重新加载", dto.SuricataRuleApplyResult{Reloaded: true})
+}
+
+// GetStats 获取规则加载统计
+//
+//	@Summary		获取 Suricata 规则加载统计
+//	@Description	通过命令套接字执行 ruleset-stats，返回各检测引擎的规则加载数量
+//	@Tags			Suricata规则管理
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Success		200	{object}	model.SuccessResponse{data=dto.SuricataRulesetStats}	"获取规则统计成功"
+//	@Failure		401	{object}	model.ErrResponseDontShowError							"未授权访问"
+//	@Failure		403	{object}	model.ErrResponseDontShowError							"禁止访问"
+//	@Failure		503	{object}	model.ErrResponse										"Suricata 不可用"
+//	@Failure		500	{object}	model.ErrResponseDontShowError							"服务器内部错误"
+//	@Router			/api/v1/suricata/rules/stats [get]
+func (c *SuricataRuleControllerImpl) GetStats(ctx *gin.Context) {
+	stats, err := c.ruleService.GetStats(ctx)
+	if err != nil {
+		c.handleError(ctx, err, "获取 Suricata 规则统计失败")
+		return
+	}
+
+	response.Success(ctx, "获取规则统计成功", stats)
+}
+
+// handleError 将规则管理的业务错误映射为响应状态码
+func (c *SuricataRuleControllerImpl) handleError(ctx *gin.Context, err error, msg string) {
+	switch {
+	case errors.Is(err, service.ErrInvalidSuricataRule), errors.Is(err, suricata.ErrInvalidTarball):
+		response.BadRequest(ctx, err, true)
+	case errors.Is(err, service.ErrSuricataRuleNotFound):
+		response.NotFound(ctx, err)
+	case errors.Is(err, service.ErrSuricataSIDConflict):
+		response.Error(ctx, model.NewAPIError(http.StatusConflict, err.Error(), err), false)
+	case errors.Is(err, service.ErrSuricataReloadRejected):
+		response.Error(ctx, model.NewAPIError(http.StatusUnprocessableEntity, err.Error(), err), false)
+	case errors.Is(err, service.ErrSuricataReloadFailed), errors.Is(err, service.ErrSuricataSocketUnusable):
+		c.logger.Warn().Err(err).Msg(msg)
+		response.Error(ctx, model.NewAPIError(http.StatusServiceUnavailable, err.Error(), err), false)
+	default:
+		c.logger.Error().Err(err).Msg(msg)
+		response.InternalServerError(ctx, err, false)
+	}
+}
@@ -0,0 +1,52 @@
+package controller
+
+import (
+	"github.com/HUAHUAI23/simple-waf/server/config"
+	"github.com/HUAHUAI23/simple-waf/server/service"
+	"github.com/HUAHUAI23/simple-waf/server/utils/response"
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog"
+)
+
+// SystemController 系统管理控制器接口
+type SystemController interface {
+	GetStatus(ctx *gin.Context)
+}
+
+// SystemControllerImpl 系统管理控制器实现
+type SystemControllerImpl struct {
+	systemService service.SystemService
+	logger        zerolog.Logger
+}
+
+// NewSystemController 创建系统管理控制器
+func NewSystemController(systemService service.SystemService) SystemController {
+	logger := config.GetControllerLogger("system")
+	return &SystemControllerImpl{
+		systemService: systemService,
+		logger:        logger,
+	}
+}
+
+// GetStatus 获取系统状态
+//
+//	@Summary		获取系统状态和 HAProxy 运行时统计
+//	@Description	获取运行器状态；运行时通过 HAProxy 运行时 API 获取进程信息、端口前端的请求速率和流量，以及按站点汇总的会话、流量、4xx/5xx 响应数、服务器状态和排队长度
+//	@Tags			系统管理
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Success		200	{object}	model.SuccessResponse{data=dto.SystemStatusResponse}	"获取系统状态成功"
+//	@Failure		401	{object}	model.ErrResponseDontShowError							"未授权访问"
+//	@Failure		403	{object}	model.ErrResponseDontShowError							"禁止访问"
+//	@Failure		500	{object}	model.ErrResponseDontShowError							"服务器内部错误"
+//	@Router			/api/v1/system/status [get]
+func (c *SystemControllerImpl) GetStatus(ctx *gin.Context) {
+	status, err := c.systemService.GetStatus(ctx)
+	if err != nil {
+		c.logger.Error().Err(err).Msg("获取系统状态失败")
+		response.InternalServerError(ctx, err, false)
+		return
+	}
+
+	response.Success(ctx, "获取系统状态成功", status)
+}
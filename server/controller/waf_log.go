@@ -1,9 +1,16 @@
 package controller
 
 import (
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
 	"time"
 
+	"github.com/HUAHUAI23/simple-waf/server/config"
 	"github.com/HUAHUAI23/simple-waf/server/dto"
+	"github.com/HUAHUAI23/simple-waf/server/model"
 	"github.com/HUAHUAI23/simple-waf/server/service"
 	"github.com/HUAHUAI23/simple-waf/server/utils/response"
 	"github.com/gin-gonic/gin"
@@ -12,23 +19,26 @@ import (
 type WAFLogController interface {
 	GetAttackEvents(ctx *gin.Context)
 	GetAttackLogs(ctx *gin.Context)
+	ExportAttackLogs(ctx *gin.Context)
 }
 
 type WAFLogControllerImpl struct {
 	wafLogService service.WAFLogService
+	auditService  service.AuditService
 }
 
 // NewWAFLogController 创建新的WAF日志控制器实例
-func NewWAFLogController(wafLogService service.WAFLogService) WAFLogController {
+func NewWAFLogController(wafLogService service.WAFLogService, auditService service.AuditService) WAFLogController {
 	return &WAFLogControllerImpl{
 		wafLogService: wafLogService,
+		auditService:  auditService,
 	}
 }
 
 // GetAttackEvents godoc
 //
 //	@Summary		获取聚合攻击事件
-//	@Description	按来源IP、目标端口和域名聚合的攻击事件统计，支持多维度筛选和分页
+//	@Description	按来源IP、目标端口和域名聚合的攻击事件统计，支持多维度筛选，以及页码分页或游标分页
 //	@Tags			WAF安全日志
 //	@Accept			json
 //	@Produce		json
@@ -39,8 +49,20 @@ func NewWAFLogController(wafLogService service.WAFLogService) WAFLogController {
 //	@Param			dstPort		query		integer												false	"目标端口号，被攻击的服务端口"
 //	@Param			startTime	query		string												false	"查询起始时间 (ISO8601格式，如: 2024-03-17T00:00:00Z)"
 //	@Param			endTime		query		string												false	"查询结束时间 (ISO8601格式，如: 2024-03-18T23:59:59Z)"
+//	@Param			srcIps		query		[]string											false	"来源IP列表，可重复传递，与srcIp合并为in查询"	collectionFormat(multi)
+//	@Param			dstIps		query		[]string											false	"目标IP列表，可重复传递，与dstIp合并为in查询"	collectionFormat(multi)
+//	@Param			ruleIds		query		[]integer											false	"规则ID列表，可重复传递"							collectionFormat(multi)
+//	@Param			minSeverity	query		integer												false	"严重级别下限（含），0最严重"
+//	@Param			maxSeverity	query		integer												false	"严重级别上限（含）"
+//	@Param			phase		query		integer												false	"请求处理阶段 (1-5)"
+//	@Param			message		query		string												false	"事件描述关键字，不区分大小写"
+//	@Param			payload		query		string												false	"攻击载荷关键字，不区分大小写"
+//	@Param			uriPrefix	query		string												false	"请求URI前缀"
+//	@Param			action		query		string												false	"拦截动作，如 deny、drop、redirect"
 //	@Param			page		query		integer												false	"当前页码，从1开始计数 (默认: 1)"
 //	@Param			pageSize	query		integer												false	"每页记录数，最大100条 (默认: 10)"
+//	@Param			pagination	query		string												false	"分页方式 offset/cursor (默认: offset)"
+//	@Param			cursor		query		string												false	"游标分页时上一页响应中的 nextCursor，传入时自动使用游标分页"
 //	@Success		200			{object}	model.SuccessResponse{data=dto.AttackEventResponse}	"成功"
 //	@Failure		400			{object}	model.ErrResponse									"请求参数错误"
 //	@Failure		500			{object}	model.ErrResponseDontShowError						"服务器内部错误"
@@ -80,6 +102,10 @@ func (c *WAFLogControllerImpl) GetAttackEvents(ctx *gin.Context) {
 	// 调用服务
 	result, err := c.wafLogService.GetAttackEvents(ctx, req, page, pageSize)
 	if err != nil {
+		if errors.Is(err, service.ErrInvalidCursor) {
+			response.BadRequest(ctx, err, true)
+			return
+		}
 		response.InternalServerError(ctx, err, false)
 		return
 	}
@@ -90,7 +116,7 @@ func (c *WAFLogControllerImpl) GetAttackEvents(ctx *gin.Context) {
 // GetAttackLogs godoc
 //
 //	@Summary		获取详细攻击日志
-//	@Description	查询详细的WAF攻击日志记录，提供多条件筛选和分页功能，支持按规则ID、IP、域名、端口和时间范围过滤
+//	@Description	查询详细的WAF攻击日志记录，支持按规则ID、IP、域名、端口、严重级别、阶段、关键字、URI前缀、拦截动作和时间范围过滤，以及页码分页或游标分页
 //	@Tags			WAF安全日志
 //	@Accept			json
 //	@Produce		json
@@ -103,8 +129,20 @@ func (c *WAFLogControllerImpl) GetAttackEvents(ctx *gin.Context) {
 //	@Param			requestId	query		string												false	"请求ID，唯一标识HTTP请求的ID"
 //	@Param			startTime	query		string												false	"查询起始时间 (ISO8601格式，如: 2024-03-17T00:00:00Z)"
 //	@Param			endTime		query		string												false	"查询结束时间 (ISO8601格式，如: 2024-03-18T23:59:59Z)"
+//	@Param			srcIps		query		[]string											false	"来源IP列表，可重复传递，与srcIp合并为in查询"	collectionFormat(multi)
+//	@Param			dstIps		query		[]string											false	"目标IP列表，可重复传递，与dstIp合并为in查询"	collectionFormat(multi)
+//	@Param			ruleIds		query		[]integer											false	"规则ID列表，可重复传递"							collectionFormat(multi)
+//	@Param			minSeverity	query		integer												false	"严重级别下限（含），0最严重"
+//	@Param			maxSeverity	query		integer												false	"严重级别上限（含）"
+//	@Param			phase		query		integer												false	"请求处理阶段 (1-5)"
+//	@Param			message		query		string												false	"事件描述关键字，不区分大小写"
+//	@Param			payload		query		string												false	"攻击载荷关键字，不区分大小写"
+//	@Param			uriPrefix	query		string												false	"请求URI前缀"
+//	@Param			action		query		string												false	"拦截动作，如 deny、drop、redirect"
 //	@Param			page		query		integer												false	"当前页码，从1开始计数 (默认: 1)"
 //	@Param			pageSize	query		integer												false	"每页记录数，最大100条 (默认: 10)"
+//	@Param			pagination	query		string												false	"分页方式 offset/cursor (默认: offset)"
+//	@Param			cursor		query		string												false	"游标分页时上一页响应中的 nextCursor，传入时自动使用游标分页"
 //	@Success		200			{object}	model.SuccessResponse{data=dto.AttackLogResponse}	"成功"
 //	@Failure		400			{object}	model.ErrResponse									"请求参数错误"
 //	@Failure		500			{object}	model.ErrResponseDontShowError						"服务器内部错误"
@@ -144,9 +182,128 @@ func (c *WAFLogControllerImpl) GetAttackLogs(ctx *gin.Context) {
 	// 调用服务
 	result, err := c.wafLogService.GetAttackLogs(ctx, req, page, pageSize)
 	if err != nil {
+		if errors.Is(err, service.ErrInvalidCursor) {
+			response.BadRequest(ctx, err, true)
+			return
+		}
 		response.InternalServerError(ctx, err, false)
 		return
 	}
 
 	response.Success(ctx, "获取攻击日志成功", result)
 }
+
+// ExportAttackLogs godoc
+//
+//	@Summary		导出攻击日志
+//	@Description	使用与攻击日志查询相同的过滤条件，以流式方式导出完整结果集，支持CSV（可选列）、NDJSON、JSON格式及gzip压缩，导出操作会写入审计日志
+//	@Tags			WAF安全日志
+//	@Produce		text/csv
+//	@Produce		application/x-ndjson
+//	@Produce		json
+//	@Param			ruleId		query		integer							false	"规则ID，触发攻击检测的WAF规则标识"
+//	@Param			srcIp		query		string							false	"来源IP地址，攻击者地址"
+//	@Param			dstIp		query		string							false	"目标IP地址，被攻击的服务器地址"
+//	@Param			domain		query		string							false	"域名，被攻击的站点域名"
+//	@Param			srcPort		query		integer							false	"来源端口号，发起攻击的端口"
+//	@Param			dstPort		query		integer							false	"目标端口号，被攻击的服务端口"
+//	@Param			requestId	query		string							false	"请求ID，唯一标识HTTP请求的ID"
+//	@Param			startTime	query		string							false	"查询起始时间 (ISO8601格式，默认24小时前)"
+//	@Param			endTime		query		string							false	"查询结束时间 (ISO8601格式，默认当前时间)"
+//	@Param			format		query		string							false	"导出格式 csv/ndjson/json (默认: csv)"
+//	@Param			columns		query		string							false	"CSV导出列，逗号分隔"
+//	@Param			gzip		query		boolean							false	"是否gzip压缩"
+//	@Security		BearerAuth
+//	@Success		200			{file}		file							"导出文件"
+//	@Failure		400			{object}	model.ErrResponse				"请求参数错误"
+//	@Failure		500			{object}	model.ErrResponseDontShowError	"服务器内部错误"
+//	@Router			/api/v1/log/export [get]
+func (c *WAFLogControllerImpl) ExportAttackLogs(ctx *gin.Context) {
+	var req dto.AttackLogExportRequest
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		response.BadRequest(ctx, err, true)
+		return
+	}
+
+	if req.StartTime.IsZero() {
+		req.StartTime = time.Now().UTC().Add(-24 * time.Hour)
+	}
+	if req.EndTime.IsZero() {
+		req.EndTime = time.Now().UTC()
+	}
+	if req.Format == "" {
+		req.Format = "csv"
+	}
+
+	// 在写入响应前校验导出列，保证参数错误时仍能返回JSON错误
+	if req.Format == "csv" {
+		if _, err := service.ParseExportColumns(req.Columns); err != nil {
+			response.BadRequest(ctx, err, true)
+			return
+		}
+	}
+
+	contentType, ext := exportContentType(req.Format)
+	filename := fmt.Sprintf("waf-logs-%s.%s", time.Now().UTC().Format("20060102T150405Z"), ext)
+
+	var w io.Writer = ctx.Writer
+	var gz *gzip.Writer
+	if req.Gzip {
+		filename += ".gz"
+		contentType = "application/gzip"
+		gz = gzip.NewWriter(ctx.Writer)
+		w = gz
+	}
+
+	ctx.Header("Content-Type", contentType)
+	ctx.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	ctx.Status(200)
+
+	count, err := c.wafLogService.ExportAttackLogs(ctx, req, w)
+	// 导出中断时不关闭gzip流，使客户端能通过校验失败识别出不完整的文件
+	if gz != nil && err == nil {
+		err = gz.Close()
+	}
+
+	auditLog := newAuditLog(ctx, model.AuditActionLogExport, "waf_log")
+	auditLog.Detail = map[string]any{
+		"format":    req.Format,
+		"columns":   req.Columns,
+		"gzip":      req.Gzip,
+		"startTime": req.StartTime,
+		"endTime":   req.EndTime,
+		"filter":    req.AttackLogRequest,
+		"records":   count,
+	}
+	auditLog.Success = err == nil
+	if err != nil {
+		auditLog.Error = err.Error()
+	}
+	// 审计写入不使用请求上下文，客户端中断下载时同样需要记录
+	auditCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_ = c.auditService.Record(auditCtx, auditLog)
+
+	if err != nil {
+		config.Logger.Error().Err(err).Int64("records", count).Msg("导出攻击日志中断")
+		if !ctx.Writer.Written() {
+			ctx.Writer.Header().Del("Content-Type")
+			ctx.Writer.Header().Del("Content-Disposition")
+			response.InternalServerError(ctx, err, false)
+			return
+		}
+		ctx.Abort()
+	}
+}
+
+// exportContentType 返回导出格式对应的Content-Type和文件扩展名
+func exportContentType(format string) (string, string) {
+	switch format {
+	case "ndjson":
+		return "application/x-ndjson", "ndjson"
+	case "json":
+		return "application/json", "json"
+	default:
+		return "text/csv; charset=utf-8", "csv"
+	}
+}
@@ -0,0 +1,107 @@
+package dto
+
+import "time"
+
+// AccessLogAnalyticsRequest 访问流量统计查询请求
+// @Description 按站点统计访问日志的请求量、状态码分布、耗时分位数和热门路径，默认统计最近24小时
+type AccessLogAnalyticsRequest struct {
+	Domain    string    `json:"domain" form:"domain" binding:"omitempty" example:"example.com"`                                                   // 站点主域名，为空时统计全部站点
+	StartTime time.Time `json:"startTime" form:"startTime" binding:"omitempty" time_format:"2006-01-02T15:04:05Z" example:"2024-03-17T00:00:00Z"` // 查询起始时间，ISO8601格式
+	EndTime   time.Time `json:"endTime" form:"endTime" binding:"omitempty" time_format:"2006-01-02T15:04:05Z" example:"2024-03-18T23:59:59Z"`     // 查询结束时间，ISO8601格式
+	TopN      int       `json:"topN" form:"topN" binding:"omitempty,min=1,max=50" default:"10" example:"10"`                                      // 每个站点返回的热门路径数量，最大50
+}
+
+// AccessLogSiteSummary 按站点聚合的访问日志计数
+type AccessLogSiteSummary struct {
+	Domain         string  `bson:"_id"`
+	Total          int64   `bson:"total"`
+	Status1xx      int64   `bson:"status1xx"`
+	Status2xx      int64   `bson:"status2xx"`
+	Status3xx      int64   `bson:"status3xx"`
+	Status4xx      int64   `bson:"status4xx"`
+	Status5xx      int64   `bson:"status5xx"`
+	Bytes          int64   `bson:"bytes"`
+	AvgRequestTime float64 `bson:"avgRequestTime"`
+}
+
+// AccessLogLatencyBucket 按站点聚合的请求耗时直方图中的一格，Value 为该格的代表耗时（毫秒）
+type AccessLogLatencyBucket struct {
+	Domain string `bson:"domain"`
+	Value  int64  `bson:"value"`
+	Count  int64  `bson:"count"`
+}
+
+// AccessLogPathCount 按站点聚合的路径请求数
+type AccessLogPathCount struct {
+	Domain string `bson:"domain"`
+	Path   string `bson:"path"`
+	Count  int64  `bson:"count"`
+}
+
+// WAFDetectionCount 按主机名和目标端口聚合的 WAF 检测数
+type WAFDetectionCount struct {
+	Domain  string `bson:"domain"`
+	DstPort int    `bson:"dstPort"`
+	Count   int64  `bson:"count"`
+}
+
+// StatusDistribution 状态码分布
+// @Description 各类响应状态码的请求数，status 为0（客户端提前断开等）的请求不计入
+type StatusDistribution struct {
+	Status1xx int64 `json:"1xx" example:"0"`    // 1xx 请求数
+	Status2xx int64 `json:"2xx" example:"9500"` // 2xx 请求数
+	Status3xx int64 `json:"3xx" example:"200"`  // 3xx 请求数
+	Status4xx int64 `json:"4xx" example:"280"`  // 4xx 请求数
+	Status5xx int64 `json:"5xx" example:"20"`   // 5xx 请求数
+}
+
+// LatencyPercentiles 请求耗时分位数
+// @Description 请求总耗时（毫秒）的近似分位数，误差约为5%，未完成的请求不计入
+type LatencyPercentiles struct {
+	P50 int64   `json:"p50" example:"12"`   // 中位数
+	P90 int64   `json:"p90" example:"85"`   // 90分位
+	P99 int64   `json:"p99" example:"420"`  // 99分位
+	Avg float64 `json:"avg" example:"25.3"` // 平均耗时
+}
+
+// PathCount 路径请求数
+type PathCount struct {
+	Path  string `json:"path" example:"/api/v1/users"` // 请求路径，不含查询参数
+	Count int64  `json:"count" example:"1200"`         // 请求数
+}
+
+// SiteTrafficStats 单个站点的访问流量统计
+// @Description 站点在查询时间范围内的请求量、状态码分布、耗时分位数、热门路径，以及同期 WAF 检测数与请求量的比例
+type SiteTrafficStats struct {
+	Domain        string             `json:"domain" example:"example.com"`   // 站点主域名，无法归属到站点的请求为空
+	Requests      int64              `json:"requests" example:"10000"`       // 请求总数
+	Bytes         int64              `json:"bytes" example:"52428800"`       // 发送给客户端的字节数
+	Status        StatusDistribution `json:"status"`                         // 状态码分布
+	Latency       LatencyPercentiles `json:"latency"`                        // 请求耗时分位数
+	TopPaths      []PathCount        `json:"topPaths"`                       // 热门路径
+	WAFDetections int64              `json:"wafDetections" example:"35"`     // 同期 WAF 检测数
+	DetectionRate float64            `json:"detectionRate" example:"0.0035"` // WAF 检测数与请求总数的比例
+}
+
+// AccessLogReceiverStatus 访问日志接收器状态
+// @Description 内置 syslog 接收器的运行状态和计数
+type AccessLogReceiverStatus struct {
+	State            string     `json:"state" example:"running"`                          // 状态：disabled、stopped、running、error
+	Address          string     `json:"address" example:"udp://127.0.0.1:5140"`           // 接收地址
+	MessagesReceived uint64     `json:"messagesReceived" example:"100000"`                // 收到的消息数
+	LogsInserted     uint64     `json:"logsInserted" example:"99000"`                     // 写入的访问日志数
+	LogsSkipped      uint64     `json:"logsSkipped" example:"1000"`                       // 不是 HTTP 访问日志的消息数
+	LogsUnmatched    uint64     `json:"logsUnmatched" example:"50"`                       // 无法归属到站点的访问日志数
+	LogsDropped      uint64     `json:"logsDropped" example:"0"`                          // 长时间写入失败后丢弃的访问日志数
+	LastLogAt        *time.Time `json:"lastLogAt,omitempty"`                              // 最近收到访问日志的时间
+	LastError        string     `json:"lastError,omitempty" example:"connection refused"` // 最近的错误
+}
+
+// AccessLogAnalyticsResponse 访问流量统计响应
+// @Description 按请求量降序排列的站点流量统计和接收器状态
+type AccessLogAnalyticsResponse struct {
+	StartTime time.Time               `json:"startTime" example:"2024-03-17T00:00:00Z"` // 统计起始时间
+	EndTime   time.Time               `json:"endTime" example:"2024-03-18T00:00:00Z"`   // 统计结束时间
+	Sites     []SiteTrafficStats      `json:"sites"`                                    // 站点流量统计
+	Receiver  AccessLogReceiverStatus `json:"receiver"`                                 // 接收器状态
+}
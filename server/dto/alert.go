@@ -0,0 +1,120 @@
+package dto
+
+import (
+	"time"
+
+	"github.com/HUAHUAI23/simple-waf/server/model"
+)
+
+// CreateAlertRuleRequest 创建告警规则请求
+// @Description 创建告警规则的请求参数
+type CreateAlertRuleRequest struct {
+	Name            string            `json:"name" binding:"required" example:"ip-block-burst"`                                                      // 规则名称
+	Description     string            `json:"description" example:"同一IP五分钟内被拦截超过100次"`                                                               // 规则描述
+	Type            string            `json:"type" binding:"required,oneof=ip_threshold rule_fired engine_state cert_expiry" example:"ip_threshold"` // 规则类型
+	Enabled         bool              `json:"enabled" example:"true"`                                                                                // 是否启用
+	Threshold       int               `json:"threshold" binding:"omitempty,min=1" example:"100"`                                                     // 阈值
+	WindowMinutes   int               `json:"windowMinutes" binding:"omitempty,min=1,max=1440" example:"5"`                                          // 统计时间窗口（分钟）
+	RuleID          int               `json:"ruleId" binding:"omitempty,min=1" example:"942100"`                                                     // WAF规则ID
+	Domain          string            `json:"domain" binding:"omitempty" example:"example.com"`                                                      // 站点域名
+	EngineState     string            `json:"engineState" binding:"omitempty,oneof=running stopped error" example:"error"`                           // 目标引擎状态
+	ExpiryDays      int               `json:"expiryDays" binding:"omitempty,min=1,max=365" example:"14"`                                             // 证书剩余天数阈值
+	CooldownMinutes int               `json:"cooldownMinutes" binding:"omitempty,min=0" example:"30"`                                                // 冷却时间（分钟）
+	Channels        []AlertChannelDTO `json:"channels" binding:"required,min=1,dive"`                                                                // 通知通道
+}
+
+// UpdateAlertRuleRequest 更新告警规则请求
+// @Description 更新告警规则的请求参数
+type UpdateAlertRuleRequest struct {
+	Name            string            `json:"name,omitempty" binding:"omitempty" example:"ip-block-burst"`                                                      // 规则名称
+	Description     string            `json:"description,omitempty" example:"同一IP五分钟内被拦截超过100次"`                                                                // 规则描述
+	Type            string            `json:"type,omitempty" binding:"omitempty,oneof=ip_threshold rule_fired engine_state cert_expiry" example:"ip_threshold"` // 规则类型
+	Enabled         *bool             `json:"enabled,omitempty" example:"true"`                                                                                 // 是否启用，不传时保持不变
+	Threshold       int               `json:"threshold,omitempty" binding:"omitempty,min=1" example:"100"`                                                      // 阈值
+	WindowMinutes   int               `json:"windowMinutes,omitempty" binding:"omitempty,min=1,max=1440" example:"5"`                                           // 统计时间窗口（分钟）
+	RuleID          int               `json:"ruleId,omitempty" binding:"omitempty,min=1" example:"942100"`                                                      // WAF规则ID
+	Domain          *string           `json:"domain,omitempty" binding:"omitempty" example:"example.com"`                                                       // 站点域名
+	EngineState     string            `json:"engineState,omitempty" binding:"omitempty,oneof=running stopped error" example:"error"`                            // 目标引擎状态
+	ExpiryDays      int               `json:"expiryDays,omitempty" binding:"omitempty,min=1,max=365" example:"14"`                                              // 证书剩余天数阈值
+	CooldownMinutes *int              `json:"cooldownMinutes,omitempty" binding:"omitempty,min=0" example:"30"`                                                 // 冷却时间（分钟）
+	Channels        []AlertChannelDTO `json:"channels,omitempty" binding:"omitempty,dive"`                                                                      // 通知通道
+}
+
+// AlertChannelDTO 告警通道DTO
+type AlertChannelDTO struct {
+	Type    string            `json:"type" binding:"required,oneof=webhook smtp http" example:"webhook"`               // 通道类型
+	URL     string            `json:"url" binding:"required_unless=Type smtp" example:"https://hooks.example.com/xxx"` // webhook/http 地址
+	Method  string            `json:"method" binding:"omitempty,oneof=POST PUT" example:"POST"`                        // http 请求方法
+	Headers map[string]string `json:"headers,omitempty"`                                                               // http 自定义请求头
+	SMTP    *SMTPConfigDTO    `json:"smtp,omitempty" binding:"required_if=Type smtp"`                                  // 邮件配置
+}
+
+// SMTPConfigDTO 邮件配置DTO
+type SMTPConfigDTO struct {
+	Host     string   `json:"host" binding:"required" example:"smtp.example.com"`        // SMTP服务器地址
+	Port     int      `json:"port" binding:"required,min=1,max=65535" example:"587"`     // SMTP服务器端口
+	Username string   `json:"username" example:"alert@example.com"`                      // 用户名
+	Password string   `json:"password"`                                                  // 密码，更新时为空表示保留原密码
+	From     string   `json:"from" binding:"required,email" example:"alert@example.com"` // 发件人
+	To       []string `json:"to" binding:"required,min=1,dive,email"`                    // 收件人列表
+	StartTLS bool     `json:"startTLS" example:"true"`                                   // 是否使用 STARTTLS
+	Insecure bool     `json:"insecure" example:"false"`                                  // 是否跳过证书校验
+	Subject  string   `json:"subject" example:"[WAF]"`                                   // 邮件主题前缀
+}
+
+// AlertChannelTestRequest 告警通道测试请求
+// @Description 向指定通道发送一条测试告警
+type AlertChannelTestRequest struct {
+	Channel AlertChannelDTO `json:"channel" binding:"required"` // 待测试的通道
+}
+
+// AlertHistoryRequest 告警投递历史查询请求
+type AlertHistoryRequest struct {
+	RuleID    string    `json:"ruleId" form:"ruleId" binding:"omitempty" example:"65f1c2a4e4b0a1b2c3d4e5f6"`                                      // 告警规则ID
+	Success   *bool     `json:"success" form:"success" binding:"omitempty" example:"false"`                                                       // 是否投递成功
+	StartTime time.Time `json:"startTime" form:"startTime" binding:"omitempty" time_format:"2006-01-02T15:04:05Z" example:"2024-03-17T00:00:00Z"` // 查询起始时间
+	EndTime   time.Time `json:"endTime" form:"endTime" binding:"omitempty" time_format:"2006-01-02T15:04:05Z" example:"2024-03-18T23:59:59Z"`     // 查询结束时间
+	Page      int       `json:"page" form:"page" binding:"omitempty,min=1" default:"1" example:"1"`                                               // 当前页码
+	PageSize  int       `json:"pageSize" form:"pageSize" binding:"omitempty,min=1,max=100" default:"10" example:"10"`                             // 每页记录数
+}
+
+// AlertRuleListResponse 告警规则列表响应
+// @Description 告警规则列表响应
+type AlertRuleListResponse struct {
+	Total int64             `json:"total"` // 总数
+	Items []model.AlertRule `json:"items"` // 告警规则列表
+}
+
+// AlertHistoryResponse 告警投递历史响应
+// @Description 告警投递历史分页响应
+type AlertHistoryResponse struct {
+	Results     []model.AlertHistory `json:"results"`                 // 投递记录列表
+	TotalCount  int64                `json:"totalCount" example:"35"` // 总记录数
+	PageSize    int                  `json:"pageSize" example:"10"`   // 每页大小
+	CurrentPage int                  `json:"currentPage" example:"1"` // 当前页码
+	TotalPages  int                  `json:"totalPages" example:"4"`  // 总页数
+}
+
+// ToModel 将通道DTO转换为模型
+func (d AlertChannelDTO) ToModel() model.AlertChannel {
+	channel := model.AlertChannel{
+		Type:    model.AlertChannelType(d.Type),
+		URL:     d.URL,
+		Method:  d.Method,
+		Headers: d.Headers,
+	}
+	if d.SMTP != nil {
+		channel.SMTP = &model.SMTPConfig{
+			Host:     d.SMTP.Host,
+			Port:     d.SMTP.Port,
+			Username: d.SMTP.Username,
+			Password: d.SMTP.Password,
+			From:     d.SMTP.From,
+			To:       d.SMTP.To,
+			StartTLS: d.SMTP.StartTLS,
+			Insecure: d.SMTP.Insecure,
+			Subject:  d.SMTP.Subject,
+		}
+	}
+	return channel
+}
@@ -0,0 +1,27 @@
+package dto
+
+import (
+	"time"
+
+	"github.com/HUAHUAI23/simple-waf/server/model"
+)
+
+// AuditLogRequest 审计日志查询请求
+type AuditLogRequest struct {
+	Username  string    `json:"username" form:"username" binding:"omitempty" example:"admin"`                                                     // 操作用户名
+	Action    string    `json:"action" form:"action" binding:"omitempty" example:"waf_log.export"`                                                // 操作类型
+	StartTime time.Time `json:"startTime" form:"startTime" binding:"omitempty" time_format:"2006-01-02T15:04:05Z" example:"2024-03-17T00:00:00Z"` // 查询起始时间
+	EndTime   time.Time `json:"endTime" form:"endTime" binding:"omitempty" time_format:"2006-01-02T15:04:05Z" example:"2024-03-18T23:59:59Z"`     // 查询结束时间
+	Page      int       `json:"page" form:"page" binding:"omitempty,min=1" default:"1" example:"1"`                                               // 当前页码
+	PageSize  int       `json:"pageSize" form:"pageSize" binding:"omitempty,min=1,max=100" default:"10" example:"10"`                             // 每页记录数
+}
+
+// AuditLogResponse 审计日志分页响应
+// @Description 审计日志分页响应
+type AuditLogResponse struct {
+	Results     []model.AuditLog `json:"results"`                 // 审计记录列表
+	TotalCount  int64            `json:"totalCount" example:"35"` // 总记录数
+	PageSize    int              `json:"pageSize" example:"10"`   // 每页大小
+	CurrentPage int              `json:"currentPage" example:"1"` // 当前页码
+	TotalPages  int              `json:"totalPages" example:"4"`  // 总页数
+}
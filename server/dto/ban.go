@@ -0,0 +1,73 @@
+package dto
+
+import (
+	"time"
+
+	"github.com/HUAHUAI23/simple-waf/server/model"
+)
+
+// CreateBanPolicyRequest 创建封禁策略请求
+// @Description 同一IP在时间窗口内命中阈值条事件时自动封禁，durations 为重复封禁的时长阶梯（分钟），0 表示永久
+type CreateBanPolicyRequest struct {
+	Name            string   `json:"name" binding:"required,max=100" example:"waf-burst"`                                     // 策略名称
+	Description     string   `json:"description" binding:"max=500" example:"5分钟内被拦截20次封禁1小时"`                                 // 策略描述
+	Enabled         bool     `json:"enabled" example:"true"`                                                                  // 是否启用
+	Source          string   `json:"source" binding:"required,oneof=waf ids" example:"waf"`                                   // 事件来源
+	Threshold       int      `json:"threshold" binding:"required,min=1,max=10000" example:"20"`                               // 窗口内事件数阈值
+	WindowSeconds   int      `json:"windowSeconds" binding:"required,min=1,max=86400" example:"300"`                          // 统计时间窗口（秒）
+	Actions         []string `json:"actions" binding:"omitempty,dive,oneof=deny drop redirect" example:"deny,drop"`           // WAF 拦截动作过滤
+	MaxSeverity     int      `json:"maxSeverity" binding:"omitempty,min=1,max=255" example:"1"`                               // Suricata 告警严重级别上限
+	Durations       []int    `json:"durations" binding:"required,min=1,max=20,dive,min=0,max=525600" example:"60,360,1440,0"` // 封禁时长阶梯（分钟）
+	EscalationHours int      `json:"escalationHours" binding:"omitempty,min=1,max=8760" example:"24"`                         // 统计重复封禁的回溯时间（小时）
+}
+
+// UpdateBanPolicyRequest 更新封禁策略请求
+// @Description 只更新请求中出现的字段
+type UpdateBanPolicyRequest struct {
+	Name            *string  `json:"name,omitempty" binding:"omitempty,min=1,max=100" example:"waf-burst"`                         // 策略名称
+	Description     *string  `json:"description,omitempty" binding:"omitempty,max=500" example:"5分钟内被拦截20次封禁1小时"`                  // 策略描述
+	Enabled         *bool    `json:"enabled,omitempty" example:"true"`                                                             // 是否启用
+	Source          *string  `json:"source,omitempty" binding:"omitempty,oneof=waf ids" example:"waf"`                             // 事件来源
+	Threshold       *int     `json:"threshold,omitempty" binding:"omitempty,min=1,max=10000" example:"20"`                         // 窗口内事件数阈值
+	WindowSeconds   *int     `json:"windowSeconds,omitempty" binding:"omitempty,min=1,max=86400" example:"300"`                    // 统计时间窗口（秒）
+	Actions         []string `json:"actions,omitempty" binding:"omitempty,dive,oneof=deny drop redirect" example:"deny,drop"`      // WAF 拦截动作过滤
+	MaxSeverity     *int     `json:"maxSeverity,omitempty" binding:"omitempty,min=0,max=255" example:"1"`                          // Suricata 告警严重级别上限
+	Durations       []int    `json:"durations,omitempty" binding:"omitempty,max=20,dive,min=0,max=525600" example:"60,360,1440,0"` // 封禁时长阶梯（分钟）
+	EscalationHours *int     `json:"escalationHours,omitempty" binding:"omitempty,min=1,max=8760" example:"24"`                    // 统计重复封禁的回溯时间（小时）
+}
+
+// BanPolicyListResponse 封禁策略列表响应
+type BanPolicyListResponse struct {
+	Total int64             `json:"total" example:"3"` // 总数
+	Items []model.BanPolicy `json:"items"`             // 策略列表
+}
+
+// BanListRequest 封禁记录查询请求
+type BanListRequest struct {
+	IP        string    `json:"ip" form:"ip" binding:"omitempty" example:"203.0.113.7"`                                                           // 被封禁的IP
+	Source    string    `json:"source" form:"source" binding:"omitempty,oneof=waf ids manual" example:"waf"`                                      // 封禁来源
+	Active    *bool     `json:"active" form:"active" binding:"omitempty" example:"true"`                                                          // 是否生效中
+	PolicyID  string    `json:"policyId" form:"policyId" binding:"omitempty" example:"65f1c2a4e4b0a1b2c3d4e5f6"`                                  // 策略ID
+	StartTime time.Time `json:"startTime" form:"startTime" binding:"omitempty" time_format:"2006-01-02T15:04:05Z" example:"2024-03-17T00:00:00Z"` // 封禁开始时间起点
+	EndTime   time.Time `json:"endTime" form:"endTime" binding:"omitempty" time_format:"2006-01-02T15:04:05Z" example:"2024-03-18T23:59:59Z"`     // 封禁开始时间终点
+	Page      int       `json:"page" form:"page" binding:"omitempty,min=1" default:"1" example:"1"`                                               // 当前页码
+	PageSize  int       `json:"pageSize" form:"pageSize" binding:"omitempty,min=1,max=100" default:"10" example:"10"`                             // 每页记录数
+}
+
+// BanListResponse 封禁记录列表响应
+// @Description 封禁记录分页响应，列表中不包含触发事件
+type BanListResponse struct {
+	Results     []model.Ban `json:"results"`                 // 封禁记录
+	TotalCount  int64       `json:"totalCount" example:"12"` // 总记录数
+	PageSize    int         `json:"pageSize" example:"10"`   // 每页大小
+	CurrentPage int         `json:"currentPage" example:"1"` // 当前页码
+	TotalPages  int         `json:"totalPages" example:"2"`  // 总页数
+}
+
+// CreateBanRequest 手动封禁请求
+// @Description 手动封禁一个IP或网段，durationMinutes 为 0 表示永久封禁
+type CreateBanRequest struct {
+	IP              string `json:"ip" binding:"required,ip|cidr" example:"203.0.113.7"`     // IP或网段
+	DurationMinutes int    `json:"durationMinutes" binding:"min=0,max=525600" example:"60"` // 封禁时长（分钟）
+	Reason          string `json:"reason" binding:"max=500" example:"持续扫描后台登录页"`            // 封禁原因
+}
@@ -7,11 +7,13 @@ import (
 // ConfigPatchRequest 配置补丁更新请求
 // @Description 用于部分更新配置的请求参数
 type ConfigPatchRequest struct {
-	Name            *string          `json:"name,omitempty" binding:"omitempty" example:"AppConfig"`        // 配置名称
-	Engine          *EnginePatchDTO  `json:"engine,omitempty" binding:"omitempty"`                          // 引擎配置
-	Haproxy         *HaproxyPatchDTO `json:"haproxy,omitempty" binding:"omitempty"`                         // HAProxy配置
-	IsResponseCheck *bool            `json:"isResponseCheck,omitempty" binding:"omitempty" example:"false"` // 是否检查响应
-	IsDebug         *bool            `json:"isDebug,omitempty" binding:"omitempty" example:"false"`         // 是否开启调试模式
+	Name            *string            `json:"name,omitempty" binding:"omitempty" example:"AppConfig"`        // 配置名称
+	Engine          *EnginePatchDTO    `json:"engine,omitempty" binding:"omitempty"`                          // 引擎配置
+	Haproxy         *HaproxyPatchDTO   `json:"haproxy,omitempty" binding:"omitempty"`                         // HAProxy配置
+	SIEM            *SIEMPatchDTO      `json:"siem,omitempty" binding:"omitempty"`                            // SIEM转发配置
+	Retention       *RetentionPatchDTO `json:"retention,omitempty" binding:"omitempty"`                       // 日志保留配置
+	IsResponseCheck *bool              `json:"isResponseCheck,omitempty" binding:"omitempty" example:"false"` // 是否检查响应
+	IsDebug         *bool              `json:"isDebug,omitempty" binding:"omitempty" example:"false"`         // 是否开启调试模式
 }
 
 // EnginePatchDTO 引擎配置补丁DTO
@@ -41,17 +43,46 @@ type HaproxyPatchDTO struct {
 	Thread        *int    `json:"thread,omitempty" binding:"omitempty,min=0,max=256" example:"4"`    // 线程数
 }
 
+// SIEMPatchDTO SIEM转发配置补丁DTO
+type SIEMPatchDTO struct {
+	Enabled     *bool   `json:"enabled,omitempty" binding:"omitempty" example:"true"`                       // 是否启用转发
+	Format      *string `json:"format,omitempty" binding:"omitempty,oneof=cef leef" example:"cef"`          // 事件格式
+	Protocol    *string `json:"protocol,omitempty" binding:"omitempty,oneof=udp tcp tls" example:"tcp"`     // 传输协议
+	Address     *string `json:"address,omitempty" binding:"omitempty,hostname_port" example:"siem:514"`     // syslog 接收端地址
+	TLSInsecure *bool   `json:"tlsInsecure,omitempty" binding:"omitempty" example:"false"`                  // 是否跳过证书校验
+	Facility    *int    `json:"facility,omitempty" binding:"omitempty,min=1,max=23" example:"16"`           // syslog facility
+	BufferSize  *int    `json:"bufferSize,omitempty" binding:"omitempty,min=1,max=1000000" example:"10000"` // 发送缓冲区大小
+	ForwardWAF  *bool   `json:"forwardWAF,omitempty" binding:"omitempty" example:"true"`                    // 是否转发 WAF 事件
+	ForwardIDS  *bool   `json:"forwardIDS,omitempty" binding:"omitempty" example:"true"`                    // 是否转发 Suricata 事件
+}
+
+// RetentionPatchDTO 日志保留配置补丁DTO
+type RetentionPatchDTO struct {
+	ArchiveDir  *string              `json:"archiveDir,omitempty" binding:"omitempty" example:"/simple-waf/archive"` // 归档文件目录
+	RestoreDays *int                 `json:"restoreDays,omitempty" binding:"omitempty,min=1,max=365" example:"7"`    // 归档恢复数据的保留天数
+	Policies    []RetentionPolicyDTO `json:"policies,omitempty" binding:"omitempty,dive"`                            // 保留策略，提供时整体替换
+}
+
+// RetentionPolicyDTO 集合保留策略DTO
+type RetentionPolicyDTO struct {
+	Collection string `json:"collection" binding:"required,oneof=waf_log suricata_events alert_history audit_log access_log" example:"waf_log"` // 集合名称
+	Days       int    `json:"days" binding:"min=0,max=3650" example:"90"`                                                                       // 保留天数，0 表示永久保留
+	Archive    bool   `json:"archive" example:"true"`                                                                                           // 过期前是否归档
+}
+
 // ConfigResponse 配置响应
 // @Description 配置响应
 type ConfigResponse struct {
-	ID              string     `json:"id,omitempty"`    // 配置ID
-	Name            string     `json:"name"`            // 配置名称
-	Engine          EngineDTO  `json:"engine"`          // 引擎配置
-	Haproxy         HaproxyDTO `json:"haproxy"`         // HAProxy配置
-	CreatedAt       time.Time  `json:"createdAt"`       // 创建时间
-	UpdatedAt       time.Time  `json:"updatedAt"`       // 更新时间
-	IsResponseCheck bool       `json:"isResponseCheck"` // 是否检查响应
-	IsDebug         bool       `json:"isDebug"`         // 是否开启调试模式
+	ID              string       `json:"id,omitempty"`    // 配置ID
+	Name            string       `json:"name"`            // 配置名称
+	Engine          EngineDTO    `json:"engine"`          // 引擎配置
+	Haproxy         HaproxyDTO   `json:"haproxy"`         // HAProxy配置
+	SIEM            SIEMDTO      `json:"siem"`            // SIEM转发配置
+	Retention       RetentionDTO `json:"retention"`       // 日志保留配置
+	CreatedAt       time.Time    `json:"createdAt"`       // 创建时间
+	UpdatedAt       time.Time    `json:"updatedAt"`       // 更新时间
+	IsResponseCheck bool         `json:"isResponseCheck"` // 是否检查响应
+	IsDebug         bool         `json:"isDebug"`         // 是否开启调试模式
 }
 
 // EngineDTO 引擎配置DTO
@@ -81,6 +112,26 @@ type HaproxyDTO struct {
 	Thread        int    `json:"thread"`        // 线程数
 }
 
+// SIEMDTO SIEM转发配置DTO
+type SIEMDTO struct {
+	Enabled     bool   `json:"enabled"`     // 是否启用转发
+	Format      string `json:"format"`      // 事件格式 cef/leef
+	Protocol    string `json:"protocol"`    // 传输协议 udp/tcp/tls
+	Address     string `json:"address"`     // syslog 接收端地址
+	TLSInsecure bool   `json:"tlsInsecure"` // 是否跳过证书校验
+	Facility    int    `json:"facility"`    // syslog facility
+	BufferSize  int    `json:"bufferSize"`  // 发送缓冲区大小
+	ForwardWAF  bool   `json:"forwardWAF"`  // 是否转发 WAF 事件
+	ForwardIDS  bool   `json:"forwardIDS"`  // 是否转发 Suricata 事件
+}
+
+// RetentionDTO 日志保留配置DTO
+type RetentionDTO struct {
+	ArchiveDir  string               `json:"archiveDir"`  // 归档文件目录
+	RestoreDays int                  `json:"restoreDays"` // 归档恢复数据的保留天数
+	Policies    []RetentionPolicyDTO `json:"policies"`    // 保留策略
+}
+
 // 将 time.Duration 转换为毫秒表示的 int64
 func DurationToMillis(d time.Duration) int64 {
 	return int64(d / time.Millisecond)
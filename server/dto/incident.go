@@ -0,0 +1,74 @@
+package dto
+
+import (
+	"time"
+
+	"github.com/HUAHUAI23/simple-waf/server/model"
+)
+
+// IncidentListRequest 安全事件列表查询请求
+type IncidentListRequest struct {
+	Status    string    `json:"status" form:"status" binding:"omitempty,oneof=open acknowledged false-positive resolved" example:"open"`          // 处理状态
+	SrcIP     string    `json:"srcIp" form:"srcIp" binding:"omitempty,ip" example:"192.168.1.100"`                                                // 来源IP
+	Target    string    `json:"target" form:"target" binding:"omitempty" example:"example.com"`                                                   // 目标
+	Assignee  string    `json:"assignee" form:"assignee" binding:"omitempty" example:"admin"`                                                     // 负责人
+	StartTime time.Time `json:"startTime" form:"startTime" binding:"omitempty" time_format:"2006-01-02T15:04:05Z" example:"2024-03-17T00:00:00Z"` // 最近事件时间起点
+	EndTime   time.Time `json:"endTime" form:"endTime" binding:"omitempty" time_format:"2006-01-02T15:04:05Z" example:"2024-03-18T23:59:59Z"`     // 最近事件时间终点
+	Page      int       `json:"page" form:"page" binding:"omitempty,min=1" default:"1" example:"1"`                                               // 当前页码
+	PageSize  int       `json:"pageSize" form:"pageSize" binding:"omitempty,min=1,max=100" default:"10" example:"10"`                             // 每页记录数
+}
+
+// IncidentListResponse 安全事件列表响应
+// @Description 安全事件分页响应，列表中不包含处理备注
+type IncidentListResponse struct {
+	Results     []model.Incident `json:"results"`                 // 安全事件列表
+	TotalCount  int64            `json:"totalCount" example:"35"` // 总记录数
+	PageSize    int              `json:"pageSize" example:"10"`   // 每页大小
+	CurrentPage int              `json:"currentPage" example:"1"` // 当前页码
+	TotalPages  int              `json:"totalPages" example:"4"`  // 总页数
+}
+
+// IncidentUpdateRequest 更新安全事件请求
+// @Description 修改处理状态或负责人，负责人为空字符串时取消指派
+type IncidentUpdateRequest struct {
+	Status   *string `json:"status,omitempty" binding:"omitempty,oneof=open acknowledged false-positive resolved" example:"acknowledged"` // 处理状态
+	Assignee *string `json:"assignee,omitempty" binding:"omitempty,max=64" example:"admin"`                                               // 负责人用户名
+}
+
+// IncidentCommentRequest 添加处理备注请求
+type IncidentCommentRequest struct {
+	Content string `json:"content" binding:"required,max=4000" example:"已在防火墙封禁该IP"` // 备注内容
+}
+
+// IncidentTimelineRequest 安全事件时间线查询请求
+type IncidentTimelineRequest struct {
+	Limit int `json:"limit" form:"limit" binding:"omitempty,min=1,max=1000" default:"200" example:"200"` // 最多返回的事件数
+}
+
+// IncidentTimelineEntry 时间线中的单条事件
+// @Description WAF 日志、Suricata 告警和访问日志统一后的事件，字段名与来源无关
+type IncidentTimelineEntry struct {
+	Time      time.Time `json:"time" example:"2024-03-18T08:12:33Z"`                 // 事件时间
+	Source    string    `json:"source" example:"waf"`                                // 事件来源 waf/ids/access
+	EventID   string    `json:"eventId" example:"65f1c2a4e4b0a1b2c3d4e5f6"`          // 原始记录ID
+	SrcIP     string    `json:"srcIp" example:"192.168.1.100"`                       // 来源IP
+	SrcPort   int       `json:"srcPort,omitempty" example:"52134"`                   // 来源端口
+	DstIP     string    `json:"dstIp,omitempty" example:"10.0.0.1"`                  // 目标IP
+	DstPort   int       `json:"dstPort,omitempty" example:"443"`                     // 目标端口
+	Target    string    `json:"target" example:"example.com"`                        // 关联目标
+	RuleID    int       `json:"ruleId" example:"942100"`                             // WAF 规则ID或 Suricata SID
+	Message   string    `json:"message" example:"SQL Injection Attack Detected"`     // 事件描述
+	Severity  int       `json:"severity" example:"2"`                                // 来源原始严重级别，WAF 为 0-5，Suricata 为 1-3（1 最高）
+	Action    string    `json:"action,omitempty" example:"deny"`                     // 处置动作
+	Category  string    `json:"category,omitempty" example:"Web Application Attack"` // Suricata 规则分类
+	URI       string    `json:"uri,omitempty" example:"/login.php"`                  // 请求URI
+	Status    int       `json:"status,omitempty" example:"404"`                      // 访问日志的响应状态码
+	RequestID string    `json:"requestId,omitempty" example:"a1b2c3d4e5f6"`          // WAF 请求ID
+}
+
+// IncidentTimelineResponse 安全事件时间线响应
+// @Description 按时间升序合并的 WAF、IDS 和访问日志事件，超过 limit 时只保留最近的事件
+type IncidentTimelineResponse struct {
+	Entries   []IncidentTimelineEntry `json:"entries"`                   // 事件列表
+	Truncated bool                    `json:"truncated" example:"false"` // 是否因 limit 截断
+}
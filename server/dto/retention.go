@@ -0,0 +1,31 @@
+package dto
+
+import "time"
+
+// ArchiveListRequest 归档文件列表查询请求
+type ArchiveListRequest struct {
+	Collection string `json:"collection" form:"collection" binding:"required,oneof=waf_log suricata_events alert_history audit_log access_log" example:"waf_log"` // 集合名称
+}
+
+// ArchiveDTO 归档文件信息
+type ArchiveDTO struct {
+	Collection string    `json:"collection" example:"waf_log"`                                       // 集合名称
+	Name       string    `json:"name" example:"waf_log-20240301T000000Z-20240302T000000Z.ndjson.gz"` // 归档文件名
+	From       time.Time `json:"from" example:"2024-03-01T00:00:00Z"`                                // 覆盖的起始时间（含）
+	To         time.Time `json:"to" example:"2024-03-02T00:00:00Z"`                                  // 覆盖的结束时间（不含）
+	Size       int64     `json:"size" example:"1048576"`                                             // 文件大小（字节）
+}
+
+// ArchiveRestoreRequest 归档恢复请求
+type ArchiveRestoreRequest struct {
+	Collection string `json:"collection" binding:"required,oneof=waf_log suricata_events alert_history audit_log access_log" example:"waf_log"` // 集合名称
+	Name       string `json:"name" binding:"required" example:"waf_log-20240301T000000Z-20240302T000000Z.ndjson.gz"`                            // 归档文件名
+}
+
+// ArchiveRestoreResponse 归档恢复结果
+type ArchiveRestoreResponse struct {
+	TargetCollection string    `json:"targetCollection" example:"waf_log_restored"` // 恢复数据写入的集合
+	Total            int64     `json:"total" example:"1200"`                        // 归档中的文档数
+	Inserted         int64     `json:"inserted" example:"1200"`                     // 新写入的文档数，已恢复过的文档会被跳过
+	ExpireAt         time.Time `json:"expireAt" example:"2024-03-25T00:00:00Z"`     // 恢复数据的过期时间
+}
@@ -1,5 +1,7 @@
 package dto
 
+import "github.com/HUAHUAI23/simple-waf/server/service/daemon/haproxy"
+
 // RunnerControlRequest 运行器控制请求
 type RunnerControlRequest struct {
 	Action string `json:"action" binding:"required,oneof=start stop restart force_stop reload"` // 控制动作
@@ -18,3 +20,15 @@ type RunnerStatusResponse struct {
 	State     string `json:"state" example:"running"`  // 状态：running, stopped, error
 	IsRunning bool   `json:"isRunning" example:"true"` // 是否正在运行
 }
+
+// HAProxyConfigVersionListResponse HAProxy 配置版本列表响应
+type HAProxyConfigVersionListResponse struct {
+	Total int                     `json:"total" example:"5"` // 保留的版本数
+	Items []haproxy.ConfigVersion `json:"items"`             // 版本列表，按版本号倒序，不包含差异
+}
+
+// HAProxyConfigVersionResponse HAProxy 配置版本详情响应
+type HAProxyConfigVersionResponse struct {
+	haproxy.ConfigVersion
+	Content string `json:"content"` // 完整配置内容
+}
@@ -4,34 +4,59 @@ import (
 	"time"
 
 	"github.com/HUAHUAI23/simple-waf/server/model"
+	"github.com/HUAHUAI23/simple-waf/server/service/daemon/haproxy"
 )
 
 // CreateSiteRequest 创建站点请求
 // @Description 创建站点的请求参数
 type CreateSiteRequest struct {
-	Name         string          `json:"name" binding:"required" example:"my-site"`                                      // 站点名称
-	Domain       string          `json:"domain" binding:"required,domain" example:"example.com"`                         // 域名
-	ListenPort   int             `json:"listenPort" binding:"required,min=1,max=65535" example:"8080"`                   // 监听端口
-	EnableHTTPS  bool            `json:"enableHTTPS" example:"false"`                                                    // 是否启用HTTPS
-	Certificate  *CertificateDTO `json:"certificate,omitempty" binding:"omitempty,required_if=EnableHTTPS true"`         // 证书信息
-	Backend      BackendDTO      `json:"backend" binding:"required"`                                                     // 后端服务器配置
-	WAFEnabled   bool            `json:"wafEnabled" example:"false"`                                                     // 是否启用WAF
-	WAFMode      string          `json:"wafMode" binding:"omitempty,oneof=protection observation" example:"observation"` // WAF模式
-	ActiveStatus bool            `json:"activeStatus" example:"true"`                                                    // 站点状态
+	Name         string            `json:"name" binding:"required" example:"my-site"`                                      // 站点名称
+	Domain       string            `json:"domain" binding:"required,site_host" example:"example.com"`                      // 主域名，*.example.com 匹配所有子域名
+	Aliases      []string          `json:"aliases,omitempty" binding:"omitempty,max=20,dive,site_host"`                    // 别名
+	ListenPort   int               `json:"listenPort" binding:"required,min=1,max=65535" example:"8080"`                   // 监听端口
+	ListenAddrs  []string          `json:"listenAddrs,omitempty" binding:"omitempty,max=8,dive,ip"`                        // 监听地址，不传表示全部 IPv4 地址，:: 表示同时监听 IPv4 和 IPv6，同端口的站点必须相同
+	Proxy        *ProxyOptionsDTO  `json:"proxy,omitempty" binding:"omitempty"`                                            // PROXY 协议设置
+	Mode         string            `json:"mode,omitempty" binding:"omitempty,oneof=http tcp" example:"http"`               // 站点类型，默认 http
+	TCP          *TCPOptionsDTO    `json:"tcp,omitempty" binding:"omitempty"`                                              // TCP 站点的连接设置
+	EnableHTTPS  bool              `json:"enableHTTPS" example:"false"`                                                    // 是否启用HTTPS
+	Certificate  *CertificateDTO   `json:"certificate,omitempty" binding:"omitempty,required_if=EnableHTTPS true"`         // 证书信息
+	HTTP2        bool              `json:"http2" example:"false"`                                                          // 是否在 HTTPS 上启用 HTTP/2
+	HTTP3        bool              `json:"http3" example:"false"`                                                          // 是否启用基于 QUIC 的 HTTP/3，同时返回 alt-svc 响应头
+	Backend      BackendDTO        `json:"backend" binding:"required"`                                                     // 后端服务器配置
+	Locations    []LocationDTO     `json:"locations,omitempty" binding:"omitempty,max=50,dive"`                            // 路径路由规则
+	Headers      *HeaderConfigDTO  `json:"headers,omitempty" binding:"omitempty"`                                          // 请求头和响应头改写
+	Redirects    []RedirectRuleDTO `json:"redirects,omitempty" binding:"omitempty,max=50,dive"`                            // 跳转规则，按顺序匹配
+	Rewrites     []RewriteRuleDTO  `json:"rewrites,omitempty" binding:"omitempty,max=50,dive"`                             // 转发前的路径改写规则，按顺序执行
+	TLS          *TLSConfigDTO     `json:"tls,omitempty" binding:"omitempty"`                                              // TLS 策略，不传表示使用 HAProxy 默认设置
+	WAFEnabled   bool              `json:"wafEnabled" example:"false"`                                                     // 是否启用WAF
+	WAFMode      string            `json:"wafMode" binding:"omitempty,oneof=protection observation" example:"observation"` // WAF模式
+	ActiveStatus bool              `json:"activeStatus" example:"true"`                                                    // 站点状态
 }
 
 // UpdateSiteRequest 更新站点请求
 // @Description 更新站点的请求参数
 type UpdateSiteRequest struct {
-	Name         string          `json:"name,omitempty" binding:"omitempty" example:"my-site"`                           // 站点名称
-	Domain       string          `json:"domain,omitempty" binding:"omitempty,domain" example:"example.com"`              // 域名
-	ListenPort   int             `json:"listenPort,omitempty" binding:"omitempty,min=1,max=65535" example:"8080"`        // 监听端口
-	EnableHTTPS  bool            `json:"enableHTTPS" example:"false"`                                                    // 是否启用HTTPS
-	Certificate  *CertificateDTO `json:"certificate,omitempty" binding:"omitempty,required_if=EnableHTTPS true"`         // 证书信息
-	Backend      *BackendDTO     `json:"backend,omitempty" binding:"omitempty"`                                          // 后端服务器配置
-	WAFEnabled   bool            `json:"wafEnabled" example:"false"`                                                     // 是否启用WAF
-	WAFMode      string          `json:"wafMode" binding:"omitempty,oneof=protection observation" example:"observation"` // WAF模式
-	ActiveStatus bool            `json:"activeStatus" example:"true"`                                                    // 站点状态
+	Name         string             `json:"name,omitempty" binding:"omitempty" example:"my-site"`                           // 站点名称
+	Domain       string             `json:"domain,omitempty" binding:"omitempty,site_host" example:"example.com"`           // 主域名，*.example.com 匹配所有子域名
+	Aliases      *[]string          `json:"aliases,omitempty" binding:"omitempty,max=20,dive,site_host"`                    // 别名，传空数组表示清空
+	ListenPort   int                `json:"listenPort,omitempty" binding:"omitempty,min=1,max=65535" example:"8080"`        // 监听端口
+	ListenAddrs  *[]string          `json:"listenAddrs,omitempty" binding:"omitempty,max=8,dive,ip"`                        // 监听地址，不传表示不修改，传空数组表示全部 IPv4 地址
+	Proxy        *ProxyOptionsDTO   `json:"proxy,omitempty" binding:"omitempty"`                                            // PROXY 协议设置，不传表示不修改，传空对象表示关闭
+	Mode         string             `json:"mode,omitempty" binding:"omitempty,oneof=http tcp" example:"http"`               // 站点类型，不传表示不修改
+	TCP          *TCPOptionsDTO     `json:"tcp,omitempty" binding:"omitempty"`                                              // TCP 站点的连接设置，不传表示不修改
+	EnableHTTPS  bool               `json:"enableHTTPS" example:"false"`                                                    // 是否启用HTTPS
+	Certificate  *CertificateDTO    `json:"certificate,omitempty" binding:"omitempty,required_if=EnableHTTPS true"`         // 证书信息
+	HTTP2        bool               `json:"http2" example:"false"`                                                          // 是否在 HTTPS 上启用 HTTP/2
+	HTTP3        bool               `json:"http3" example:"false"`                                                          // 是否启用基于 QUIC 的 HTTP/3，同时返回 alt-svc 响应头
+	Backend      *BackendDTO        `json:"backend,omitempty" binding:"omitempty"`                                          // 后端服务器配置
+	Locations    *[]LocationDTO     `json:"locations,omitempty" binding:"omitempty,max=50,dive"`                            // 路径路由规则，传空数组表示清空
+	Headers      *HeaderConfigDTO   `json:"headers,omitempty" binding:"omitempty"`                                          // 请求头和响应头改写，不传表示不修改，传空对象表示清空
+	Redirects    *[]RedirectRuleDTO `json:"redirects,omitempty" binding:"omitempty,max=50,dive"`                            // 跳转规则，不传表示不修改，传空数组表示清空
+	Rewrites     *[]RewriteRuleDTO  `json:"rewrites,omitempty" binding:"omitempty,max=50,dive"`                             // 转发前的路径改写规则，不传表示不修改，传空数组表示清空
+	TLS          *TLSConfigDTO      `json:"tls,omitempty" binding:"omitempty"`                                              // TLS 策略，不传表示不修改，profile 传 default 表示恢复默认设置
+	WAFEnabled   bool               `json:"wafEnabled" example:"false"`                                                     // 是否启用WAF
+	WAFMode      string             `json:"wafMode" binding:"omitempty,oneof=protection observation" example:"observation"` // WAF模式
+	ActiveStatus bool               `json:"activeStatus" example:"true"`                                                    // 站点状态
 }
 
 // CertificateDTO 证书DTO
@@ -44,16 +69,126 @@ type CertificateDTO struct {
 	FingerPrint string    `json:"fingerPrint" binding:"required"`                        // 证书指纹
 }
 
+// TCPOptionsDTO TCP 站点连接设置DTO
+// @Description TCP 站点独占监听端口，不经过 Coraza，来源地址支持 IP 和 CIDR 网段
+type TCPOptionsDTO struct {
+	MaxConn       int      `json:"maxConn,omitempty" binding:"omitempty,min=1" example:"1000"`             // 最大并发连接数，不传表示不限制
+	Allow         []string `json:"allow,omitempty" binding:"omitempty,dive,cidr|ip" example:"10.0.0.0/8"`  // 允许连接的来源地址，不传表示不限制
+	Deny          []string `json:"deny,omitempty" binding:"omitempty,dive,cidr|ip" example:"192.168.1.10"` // 拒绝连接的来源地址，优先于 allow
+	ProxyProtocol string   `json:"proxyProtocol,omitempty" binding:"omitempty,oneof=v1 v2" example:"v2"`   // 向后端发送的 PROXY 协议版本，不传表示不发送
+	Inspect       bool     `json:"inspect" example:"true"`                                                 // 是否由 Suricata 检测该端口的流量
+}
+
+// ProxyOptionsDTO PROXY 协议设置DTO
+// @Description 监听只接受可信来源发送的 PROXY 协议头，同端口的站点可信来源必须相同
+type ProxyOptionsDTO struct {
+	Trusted []string `json:"trusted,omitempty" binding:"omitempty,max=50,dive,cidr|ip" example:"10.0.0.0/8"` // 允许发送 PROXY 协议头的上游负载均衡地址，不传表示不接受
+	Send    string   `json:"send,omitempty" binding:"omitempty,oneof=v1 v2" example:"v2"`                    // 向后端发送的 PROXY 协议版本，不传表示不发送
+}
+
+// TLSConfigDTO TLS 策略DTO
+// @Description modern 只允许 TLS 1.3，intermediate 允许 TLS 1.2 和 1.3，custom 使用填写的协议版本和密码套件
+type TLSConfigDTO struct {
+	Profile      string   `json:"profile" binding:"required,oneof=default modern intermediate custom" example:"intermediate"`       // 配置模板
+	MinVersion   string   `json:"minVersion,omitempty" binding:"omitempty,oneof=TLSv1.0 TLSv1.1 TLSv1.2 TLSv1.3" example:"TLSv1.2"` // 最低协议版本，仅 custom 有效
+	MaxVersion   string   `json:"maxVersion,omitempty" binding:"omitempty,oneof=TLSv1.0 TLSv1.1 TLSv1.2 TLSv1.3" example:"TLSv1.3"` // 最高协议版本，仅 custom 有效
+	Ciphers      string   `json:"ciphers,omitempty" example:"ECDHE-ECDSA-AES128-GCM-SHA256:ECDHE-RSA-AES128-GCM-SHA256"`            // TLS 1.2 及以下的密码套件，仅 custom 有效
+	Ciphersuites string   `json:"ciphersuites,omitempty" example:"TLS_AES_128_GCM_SHA256:TLS_AES_256_GCM_SHA384"`                   // TLS 1.3 的密码套件，仅 custom 有效
+	ALPN         []string `json:"alpn,omitempty" binding:"omitempty,max=2,dive,oneof=h2 http/1.1" example:"h2,http/1.1"`            // ALPN 协议，默认 h2,http/1.1
+	HSTS         *HSTSDTO `json:"hsts,omitempty" binding:"omitempty"`                                                               // HSTS 响应头，不传表示不发送
+	OCSPStapling bool     `json:"ocspStapling" example:"false"`                                                                     // 是否启用 OCSP 装订
+}
+
+// HSTSDTO HSTS 响应头配置DTO
+type HSTSDTO struct {
+	MaxAge            int  `json:"maxAge" binding:"min=0" example:"31536000"` // 有效期（秒）
+	IncludeSubDomains bool `json:"includeSubDomains" example:"true"`          // 是否包含子域名
+	Preload           bool `json:"preload" example:"false"`                   // 是否允许加入浏览器预加载列表，要求有效期不少于一年且包含子域名
+}
+
 // BackendDTO 后端服务器配置DTO
 type BackendDTO struct {
-	Servers []ServerDTO `json:"servers" binding:"required,min=1,dive"` // 服务器列表，至少需要一个服务器
+	Servers       []ServerDTO       `json:"servers" binding:"required,min=1,dive"`                                                            // 服务器列表，至少需要一个服务器
+	Balance       string            `json:"balance,omitempty" binding:"omitempty,oneof=roundrobin leastconn source uri" example:"roundrobin"` // 负载均衡算法，默认 roundrobin
+	HealthCheck   *HealthCheckDTO   `json:"healthCheck,omitempty" binding:"omitempty"`                                                        // 健康检查，不传表示不检查
+	StickySession *StickySessionDTO `json:"stickySession,omitempty" binding:"omitempty"`                                                      // 基于 Cookie 的会话保持，不传表示不启用
 }
 
 // ServerDTO 服务器DTO
 type ServerDTO struct {
-	Host  string `json:"host" binding:"required" example:"backend.example.com"` // 主机地址
-	Port  int    `json:"port" binding:"required,min=1,max=65535" example:"80"`  // 端口
-	IsSSL bool   `json:"isSSL" example:"false"`                                 // 是否启用SSL
+	Host    string `json:"host" binding:"required" example:"backend.example.com"`                       // 主机地址
+	Port    int    `json:"port" binding:"required,min=1,max=65535" example:"80"`                        // 端口
+	IsSSL   bool   `json:"isSSL" example:"false"`                                                       // 是否启用SSL
+	Weight  int    `json:"weight,omitempty" binding:"omitempty,min=1,max=256" example:"1"`              // 权重，默认 1
+	Backup  bool   `json:"backup" example:"false"`                                                      // 备用服务器
+	MaxConn int    `json:"maxConn,omitempty" binding:"omitempty,min=1" example:"1000"`                  // 最大并发连接数，不传表示不限制
+	State   string `json:"state,omitempty" binding:"omitempty,oneof=ready drain maint" example:"ready"` // 管理状态，默认 ready
+}
+
+// HealthCheckDTO 健康检查配置DTO
+type HealthCheckDTO struct {
+	Type         string `json:"type" binding:"required,oneof=tcp http" example:"http"`                    // 检查方式
+	Path         string `json:"path,omitempty" binding:"omitempty,startswith=/" example:"/health"`        // HTTP 检查的请求路径，默认 /
+	ExpectStatus int    `json:"expectStatus,omitempty" binding:"omitempty,min=100,max=599" example:"200"` // HTTP 检查期望的状态码，不传时 2xx 和 3xx 都视为健康
+	Interval     int    `json:"interval,omitempty" binding:"omitempty,min=1,max=3600" example:"2"`        // 检查间隔（秒），默认 2
+	Rise         int    `json:"rise,omitempty" binding:"omitempty,min=1,max=100" example:"2"`             // 连续成功多少次标记为健康，默认 2
+	Fall         int    `json:"fall,omitempty" binding:"omitempty,min=1,max=100" example:"3"`             // 连续失败多少次标记为不健康，默认 3
+}
+
+// StickySessionDTO 会话保持配置DTO
+type StickySessionDTO struct {
+	CookieName string `json:"cookieName,omitempty" example:"SERVERID"` // Cookie 名称，默认 SERVERID
+}
+
+// LocationDTO 路径路由规则DTO
+// @Description 按顺序匹配，路径、请求方法和请求头条件同时满足时转发到规则的后端
+type LocationDTO struct {
+	Path        string           `json:"path" binding:"required" example:"/api"`                                  // 匹配的路径或正则
+	MatchType   string           `json:"matchType" binding:"omitempty,oneof=prefix exact regex" example:"prefix"` // 匹配方式，默认 prefix
+	Methods     []string         `json:"methods,omitempty" binding:"omitempty,dive,alpha" example:"GET,POST"`     // 请求方法
+	Headers     []HeaderMatchDTO `json:"headers,omitempty" binding:"omitempty,max=10,dive"`                       // 请求头条件
+	Backend     BackendDTO       `json:"backend" binding:"required"`                                              // 后端服务器配置
+	StripPrefix bool             `json:"stripPrefix" example:"true"`                                              // 转发前去掉匹配的前缀
+	RewritePath string           `json:"rewritePath,omitempty" binding:"omitempty,startswith=/" example:"/v1"`    // 转发前改写路径
+	DisableWAF  bool             `json:"disableWAF" example:"false"`                                              // 命中的请求不经过 WAF 检测
+}
+
+// HeaderMatchDTO 请求头匹配条件DTO
+type HeaderMatchDTO struct {
+	Name  string `json:"name" binding:"required" example:"X-Canary"` // 请求头名称
+	Value string `json:"value,omitempty" example:"1"`                // 请求头的值，为空表示只要求存在
+}
+
+// HeaderConfigDTO 头部改写配置DTO
+// @Description 预置规则先于自定义规则执行，规则写在站点和路径路由的后端中，不影响 HAProxy 自身生成的响应
+type HeaderConfigDTO struct {
+	Presets []string        `json:"presets,omitempty" binding:"omitempty,dive,oneof=csp frame-options content-type-options referrer-policy permissions-policy hide-server forwarded-proto" example:"csp,hide-server"` // 预置规则
+	Rules   []HeaderRuleDTO `json:"rules,omitempty" binding:"omitempty,max=50,dive"`                                                                                                                                  // 自定义规则，按顺序执行
+}
+
+// HeaderRuleDTO 头部改写规则DTO
+type HeaderRuleDTO struct {
+	Direction string `json:"direction" binding:"required,oneof=request response" example:"response"` // 作用方向
+	Action    string `json:"action" binding:"required,oneof=add set del" example:"set"`              // 动作，add 追加、set 替换、del 删除
+	Name      string `json:"name" binding:"required" example:"X-Content-Type-Options"`               // 头部名称
+	Value     string `json:"value,omitempty" example:"nosniff"`                                      // 头部的值，del 时不传
+}
+
+// RedirectRuleDTO 跳转规则DTO
+// @Description https 跳转到 HTTPS；prefix 在原路径前加上目标，可用于主机名规范化；location 用目标替换正则匹配的路径，可引用 \1 到 \9 捕获组
+type RedirectRuleDTO struct {
+	Type   string   `json:"type" binding:"required,oneof=https prefix location" example:"location"` // 跳转方式
+	Hosts  []string `json:"hosts,omitempty" binding:"omitempty,max=20,dive,site_host"`              // 只对这些主机名生效，必须是站点的主域名或别名，为空表示全部
+	Path   string   `json:"path,omitempty" example:"^/old/(.*)$"`                                   // 匹配路径的正则，为空表示全部路径，location 必填
+	Target string   `json:"target,omitempty" example:"/new/\\1"`                                    // 跳转目标，以 / 或 http(s)://主机名 开头，https 不需要填写
+	Code   int      `json:"code,omitempty" binding:"omitempty,oneof=301 302 307 308" example:"301"` // 状态码，默认 301
+}
+
+// RewriteRuleDTO 路径改写规则DTO
+type RewriteRuleDTO struct {
+	Path   string `json:"path" binding:"required" example:"^/api/v1/(.*)$"` // 匹配的正则
+	Target string `json:"target" binding:"required" example:"/v1/\\1"`      // 改写结果，以 / 开头，可引用 \1 到 \9 捕获组
+	Query  bool   `json:"query" example:"false"`                            // 匹配和改写是否包括查询串
 }
 
 // SiteResponse 站点响应
@@ -68,3 +203,26 @@ type SiteListResponse struct {
 	Total int64        `json:"total"` // 总数
 	Items []model.Site `json:"items"` // 站点列表
 }
+
+// SiteHealthResponse 站点后端服务器实时状态响应
+// @Description 运行器未运行时 running 为 false，服务器列表为空
+type SiteHealthResponse struct {
+	Running bool                   `json:"running"` // 运行器是否在运行
+	Servers []haproxy.ServerHealth `json:"servers"` // 站点默认后端和路径路由后端中的服务器
+}
+
+// UpdateServerStateRequest 修改后端服务器管理状态和权重请求
+// @Description 运行器运行时立即通过 HAProxy 运行时 API 生效，同时保存到站点配置
+type UpdateServerStateRequest struct {
+	State  string `json:"state,omitempty" binding:"omitempty,oneof=ready drain maint" example:"drain"` // 管理状态，不传表示不修改
+	Weight *int   `json:"weight,omitempty" binding:"omitempty,min=1,max=256" example:"10"`             // 权重，不传表示不修改
+}
+
+// ServerStateResponse 后端服务器状态响应
+// @Description 保存的服务器配置和 HAProxy 中的实时状态，运行器未运行时 runtime 为空
+type ServerStateResponse struct {
+	Index   int                   `json:"index"`             // 服务器在站点后端中的序号
+	Server  model.Server          `json:"server"`            // 保存的服务器配置
+	Running bool                  `json:"running"`           // 运行器是否在运行
+	Runtime *haproxy.ServerHealth `json:"runtime,omitempty"` // HAProxy 中的实时状态和连接数
+}
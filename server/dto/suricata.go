@@ -0,0 +1,23 @@
+package dto
+
+import "time"
+
+// SuricataIngestHealth Suricata EVE 采集状态
+// @Description EVE 日志采集器的运行状态和统计信息，lag 为日志文件中尚未处理的字节数
+type SuricataIngestHealth struct {
+	State          string     `json:"state" example:"running"`                          // 采集状态 disabled/stopped/waiting/running/error
+	File           string     `json:"file" example:"/var/log/suricata/eve.json"`        // EVE 日志文件路径
+	Offset         int64      `json:"offset" example:"1048576"`                         // 已持久化的读取偏移量
+	FileSize       int64      `json:"fileSize" example:"1049600"`                       // 当前文件大小
+	Lag            int64      `json:"lag" example:"1024"`                               // 未处理的字节数
+	LinesRead      uint64     `json:"linesRead" example:"120000"`                       // 已读取行数
+	EventsInserted uint64     `json:"eventsInserted" example:"118000"`                  // 已写入的事件数
+	EventsSkipped  uint64     `json:"eventsSkipped" example:"1990"`                     // 跳过的不采集事件类型数
+	ParseErrors    uint64     `json:"parseErrors" example:"10"`                         // 解析失败行数
+	InsertErrors   uint64     `json:"insertErrors" example:"0"`                         // 写入失败次数
+	LastEventAt    *time.Time `json:"lastEventAt,omitempty"`                            // 最近一条事件的时间
+	LastInsertAt   *time.Time `json:"lastInsertAt,omitempty"`                           // 最近一次写入时间
+	LastError      string     `json:"lastError,omitempty" example:"connection refused"` // 最近一次错误
+	LastErrorAt    *time.Time `json:"lastErrorAt,omitempty"`                            // 最近一次错误时间
+	StartedAt      *time.Time `json:"startedAt,omitempty"`                              // 采集器启动时间
+}
@@ -0,0 +1,90 @@
+package dto
+
+import "github.com/HUAHUAI23/simple-waf/server/model"
+
+// Suricata 规则来源
+const (
+	SuricataRuleSourceRuleset = "ruleset" // 导入的规则集
+	SuricataRuleSourceLocal   = "local"   // 自定义规则
+)
+
+// SuricataRuleListRequest Suricata 规则列表查询请求
+type SuricataRuleListRequest struct {
+	SID      int    `json:"sid" form:"sid" binding:"omitempty,min=1" example:"2019401"`                           // 规则 SID
+	Query    string `json:"q" form:"q" binding:"omitempty" example:"SQL"`                                         // 按 msg 模糊搜索
+	Source   string `json:"source" form:"source" binding:"omitempty,oneof=ruleset local" example:"ruleset"`       // 规则来源
+	Enabled  *bool  `json:"enabled" form:"enabled" binding:"omitempty" example:"true"`                            // 是否启用
+	Page     int    `json:"page" form:"page" binding:"omitempty,min=1" default:"1" example:"1"`                   // 当前页码
+	PageSize int    `json:"pageSize" form:"pageSize" binding:"omitempty,min=1,max=200" default:"20" example:"20"` // 每页记录数
+}
+
+// SuricataRuleDTO Suricata 规则
+// @Description 导入规则集或自定义规则中的单条规则及其生效状态
+type SuricataRuleDTO struct {
+	SID        int    `json:"sid" example:"2019401"`                                         // 规则 SID
+	Rev        int    `json:"rev" example:"3"`                                               // 规则版本
+	Msg        string `json:"msg" example:"ET POLICY Suspicious inbound to MSSQL port 1433"` // 规则说明
+	Action     string `json:"action" example:"alert"`                                        // 规则动作
+	Source     string `json:"source" example:"ruleset"`                                      // 规则来源 ruleset/local
+	File       string `json:"file,omitempty" example:"emerging-policy.rules"`                // 所在规则集文件
+	ID         string `json:"id,omitempty" example:"65f1c2a4e4b0a1b2c3d4e5f6"`               // 自定义规则ID
+	Enabled    bool   `json:"enabled" example:"true"`                                        // 当前是否启用
+	Default    bool   `json:"default" example:"true"`                                        // 规则集中的默认启用状态
+	Overridden bool   `json:"overridden" example:"false"`                                    // 启用状态是否被覆盖
+	Rule       string `json:"rule"`                                                          // 规则原文
+}
+
+// SuricataRuleListResponse Suricata 规则列表响应
+// @Description Suricata 规则分页响应
+type SuricataRuleListResponse struct {
+	Results     []SuricataRuleDTO `json:"results"`                    // 规则列表
+	TotalCount  int64             `json:"totalCount" example:"35000"` // 总记录数
+	PageSize    int               `json:"pageSize" example:"20"`      // 每页大小
+	CurrentPage int               `json:"currentPage" example:"1"`    // 当前页码
+	TotalPages  int               `json:"totalPages" example:"1750"`  // 总页数
+}
+
+// SuricataRuleStateRequest 设置规则启用状态请求
+type SuricataRuleStateRequest struct {
+	Enabled *bool `json:"enabled" binding:"required" example:"false"` // 是否启用
+}
+
+// SuricataLocalRuleRequest 创建或更新自定义规则请求
+// @Description 自定义规则，保存前会做语法预检查，sid 由规则原文解析
+type SuricataLocalRuleRequest struct {
+	Rule        string `json:"rule" binding:"required" example:"alert http any any -> $HOME_NET any (msg:\"Block scanner\"; http.user_agent; content:\"sqlmap\"; sid:9000001; rev:1;)"` // 规则原文
+	Description string `json:"description" example:"拦截 sqlmap 扫描"`                                                                                                                      // 规则说明
+	Enabled     *bool  `json:"enabled" example:"true"`                                                                                                                                  // 是否启用，默认启用
+}
+
+// SuricataRuleApplyResult 规则变更结果
+// @Description 规则文件已写入，reloaded 表示 Suricata 是否已重新加载规则
+type SuricataRuleApplyResult struct {
+	Reloaded bool                     `json:"reloaded" example:"true"` // 是否已重新加载
+	Rule     *model.SuricataLocalRule `json:"rule,omitempty"`          // 创建或更新后的自定义规则
+}
+
+// SuricataRulesetImportResult 规则集导入结果
+// @Description 规则集导入结果
+type SuricataRulesetImportResult struct {
+	Files    int  `json:"files" example:"52"`      // 导入的规则文件数
+	Rules    int  `json:"rules" example:"48213"`   // 解析出的规则数
+	Reloaded bool `json:"reloaded" example:"true"` // 是否已重新加载
+}
+
+// SuricataRulesetStats 规则加载统计
+// @Description Suricata ruleset-stats 命令返回的规则加载统计
+type SuricataRulesetStats struct {
+	Engines      []SuricataEngineStats `json:"engines"`                      // 各检测引擎统计
+	RulesetRules int                   `json:"rulesetRules" example:"48213"` // 规则集规则数
+	LocalRules   int                   `json:"localRules" example:"3"`       // 自定义规则数
+	Overrides    int                   `json:"overrides" example:"12"`       // 启用状态覆盖数
+}
+
+// SuricataEngineStats 单个检测引擎的规则统计
+type SuricataEngineStats struct {
+	ID           int `json:"id" example:"0"`              // 检测引擎ID
+	RulesLoaded  int `json:"rulesLoaded" example:"40210"` // 已加载规则数
+	RulesFailed  int `json:"rulesFailed" example:"2"`     // 加载失败规则数
+	RulesSkipped int `json:"rulesSkipped" example:"0"`    // 跳过的规则数
+}
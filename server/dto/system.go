@@ -0,0 +1,11 @@
+package dto
+
+import "github.com/HUAHUAI23/simple-waf/server/service/daemon/haproxy"
+
+// SystemStatusResponse 系统状态响应
+// @Description 运行器未运行时 running 为 false，haproxy 为空
+type SystemStatusResponse struct {
+	State   string                `json:"state" example:"running"` // 运行器状态：running, stopped, error
+	Running bool                  `json:"running" example:"true"`  // 运行器是否在运行
+	HAProxy *haproxy.RuntimeStats `json:"haproxy,omitempty"`       // HAProxy 进程、端口前端和启用站点的实时统计
+}
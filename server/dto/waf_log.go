@@ -18,6 +18,8 @@ type AttackEventRequset struct {
 	EndTime   time.Time `json:"endTime" form:"endTime" binding:"omitempty" time_format:"2006-01-02T15:04:05Z" example:"2024-03-18T23:59:59Z"`     // 查询结束时间，ISO8601格式
 	Page      int       `json:"page" form:"page" binding:"omitempty,min=1" default:"1" example:"1"`                                               // 当前页码，从1开始
 	PageSize  int       `json:"pageSize" form:"pageSize" binding:"omitempty,min=1,max=100" default:"10" example:"10"`                             // 每页记录数，最大100条
+	AttackFilterOptions
+	CursorPagination
 }
 
 // AttackLogRequest 攻击日志查询请求
@@ -34,6 +36,44 @@ type AttackLogRequest struct {
 	EndTime   time.Time `json:"endTime" form:"endTime" binding:"omitempty" time_format:"2006-01-02T15:04:05Z" example:"2024-03-18T23:59:59Z"`     // 查询结束时间，ISO8601格式
 	Page      int       `json:"page" form:"page" binding:"omitempty,min=1" default:"1" example:"1"`                                               // 当前页码，从1开始
 	PageSize  int       `json:"pageSize" form:"pageSize" binding:"omitempty,min=1,max=100" default:"10" example:"10"`                             // 每页记录数，最大100条
+	AttackFilterOptions
+	CursorPagination
+}
+
+// AttackFilterOptions 攻击日志扩展过滤条件
+// @Description 攻击事件和攻击日志查询共用的扩展过滤条件，多值参数可重复传递（如 srcIps=1.1.1.1&srcIps=2.2.2.2），与对应的单值参数合并为 in 查询
+type AttackFilterOptions struct {
+	SrcIPs      []string `json:"srcIps,omitempty" form:"srcIps" binding:"omitempty,max=100" example:"192.168.1.100,192.168.1.101"` // 来源IP列表，匹配任一即可
+	DstIPs      []string `json:"dstIps,omitempty" form:"dstIps" binding:"omitempty,max=100" example:"10.0.0.5"`                    // 目标IP列表，匹配任一即可
+	RuleIDs     []int    `json:"ruleIds,omitempty" form:"ruleIds" binding:"omitempty,max=100" example:"942100,941100"`             // 规则ID列表，匹配任一即可
+	MinSeverity *int     `json:"minSeverity,omitempty" form:"minSeverity" binding:"omitempty,min=0,max=7" example:"0"`             // 严重级别下限（含），0最严重
+	MaxSeverity *int     `json:"maxSeverity,omitempty" form:"maxSeverity" binding:"omitempty,min=0,max=7" example:"2"`             // 严重级别上限（含）
+	Phase       int      `json:"phase,omitempty" form:"phase" binding:"omitempty,min=1,max=5" example:"2"`                         // 请求处理阶段
+	Message     string   `json:"message,omitempty" form:"message" binding:"omitempty,max=256" example:"SQL Injection"`             // 事件描述关键字，不区分大小写
+	Payload     string   `json:"payload,omitempty" form:"payload" binding:"omitempty,max=256" example:"union select"`              // 攻击载荷关键字，不区分大小写
+	URIPrefix   string   `json:"uriPrefix,omitempty" form:"uriPrefix" binding:"omitempty,max=1024" example:"/api/"`                // 请求URI前缀
+	Action      string   `json:"action,omitempty" form:"action" binding:"omitempty,max=32" example:"deny"`                         // 拦截动作，如 deny、drop、redirect
+}
+
+// CursorPagination 游标分页参数
+// @Description 游标分页按时间倒序翻页，不统计总数，适合大数据量查询；传入 cursor 时自动使用游标分页并忽略 page
+type CursorPagination struct {
+	Pagination string `json:"pagination,omitempty" form:"pagination" binding:"omitempty,oneof=offset cursor" example:"cursor"`             // 分页方式 offset/cursor，默认offset
+	Cursor     string `json:"cursor,omitempty" form:"cursor" binding:"omitempty,max=512" example:"eyJ0IjoiMjAyNC0wMy0xOFQwODoxMjozM1oifQ"` // 上一页响应中的 nextCursor
+}
+
+// UseCursor 是否使用游标分页
+func (p CursorPagination) UseCursor() bool {
+	return p.Cursor != "" || p.Pagination == "cursor"
+}
+
+// AttackLogExportRequest 攻击日志导出请求
+// @Description 使用与攻击日志查询相同的过滤条件导出完整结果集，分页参数会被忽略
+type AttackLogExportRequest struct {
+	AttackLogRequest
+	Format  string `json:"format" form:"format" binding:"omitempty,oneof=csv ndjson json" example:"csv"`    // 导出格式，默认csv
+	Columns string `json:"columns" form:"columns" binding:"omitempty" example:"createdAt,srcIp,ruleId,uri"` // CSV导出列，逗号分隔，默认导出常用列
+	Gzip    bool   `json:"gzip" form:"gzip" binding:"omitempty" example:"true"`                             // 是否gzip压缩
 }
 
 // AttackEventAggregateResult 攻击事件聚合结果
@@ -50,21 +90,33 @@ type AttackEventAggregateResult struct {
 }
 
 // AttackEventResponse 攻击事件响应
-// @Description 攻击事件查询的分页响应结构体，包含聚合结果列表及分页元数据，用于前端展示和翻页控制
+// @Description 攻击事件查询的分页响应结构体，包含聚合结果列表及分页元数据，用于前端展示和翻页控制；游标分页时不返回总数和页码
 type AttackEventResponse struct {
 	Results     []AttackEventAggregateResult `json:"results"`                 // 聚合结果列表，当前页的攻击事件记录
 	TotalCount  int64                        `json:"totalCount" example:"35"` // 总记录数，符合条件的攻击事件总数
 	PageSize    int                          `json:"pageSize" example:"10"`   // 每页大小，当前设置的每页记录数
 	CurrentPage int                          `json:"currentPage" example:"1"` // 当前页码，从1开始计数
 	TotalPages  int                          `json:"totalPages" example:"4"`  // 总页数，根据总记录数和每页大小计算
+	NextCursor  string                       `json:"nextCursor,omitempty"`    // 游标分页时下一页的游标
+	HasMore     bool                         `json:"hasMore"`                 // 是否还有更多记录
 }
 
 // AttackLogResponse 攻击日志响应
-// @Description 攻击日志查询的分页响应结构体，返回详细的WAF日志记录及分页信息，便于安全分析和事件追踪
+// @Description 攻击日志查询的分页响应结构体，返回详细的WAF日志记录及分页信息，便于安全分析和事件追踪；游标分页时不返回总数和页码
 type AttackLogResponse struct {
 	Results     []model.WAFLog `json:"results"`                  // 日志记录列表，当前页的WAF攻击日志详情
 	TotalCount  int64          `json:"totalCount" example:"128"` // 总记录数，符合查询条件的日志总数
 	PageSize    int            `json:"pageSize" example:"10"`    // 每页大小，当前设置的每页记录数
 	CurrentPage int            `json:"currentPage" example:"1"`  // 当前页码，从1开始计数
 	TotalPages  int            `json:"totalPages" example:"13"`  // 总页数，根据总记录数和每页大小计算
+	NextCursor  string         `json:"nextCursor,omitempty"`     // 游标分页时下一页的游标
+	HasMore     bool           `json:"hasMore"`                  // 是否还有更多记录
+}
+
+// SrcIPCountResult 按来源IP聚合的攻击计数
+// @Description 单个来源IP在查询范围内的命中次数
+type SrcIPCountResult struct {
+	SrcIP          string    `bson:"srcIp" json:"srcIp" example:"192.168.1.100"`                          // 来源IP地址
+	Count          int       `bson:"count" json:"count" example:"120"`                                    // 命中次数
+	LastAttackTime time.Time `bson:"lastAttackTime" json:"lastAttackTime" example:"2024-03-18T08:30:45Z"` // 最近一次命中时间
 }
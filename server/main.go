@@ -18,6 +18,13 @@ import (
 	_ "github.com/HUAHUAI23/simple-waf/server/docs" // 导入 swagger 文档
 	"github.com/HUAHUAI23/simple-waf/server/router"
 	"github.com/HUAHUAI23/simple-waf/server/service/daemon"
+	"github.com/HUAHUAI23/simple-waf/server/service/daemon/accesslog"
+	"github.com/HUAHUAI23/simple-waf/server/service/daemon/alert"
+	"github.com/HUAHUAI23/simple-waf/server/service/daemon/ban"
+	"github.com/HUAHUAI23/simple-waf/server/service/daemon/eve"
+	"github.com/HUAHUAI23/simple-waf/server/service/daemon/incident"
+	"github.com/HUAHUAI23/simple-waf/server/service/daemon/retention"
+	"github.com/HUAHUAI23/simple-waf/server/service/daemon/siem"
 	"github.com/HUAHUAI23/simple-waf/server/validator"
 )
 
@@ -77,6 +84,52 @@ func main() {
 		return
 	}
 
+	// 启动告警引擎
+	alertEngine := alert.NewAlertEngine(db, func() string {
+		return runner.GetState().String()
+	}, time.Duration(config.Global.Alert.EvalIntervalSec)*time.Second)
+	if err := alertEngine.Start(); err != nil {
+		config.Logger.Error().Err(err).Msg("Failed to start alert engine")
+	}
+
+	// 启动SIEM转发器
+	siemForwarder := siem.NewForwarder(db)
+	if err := siemForwarder.Start(); err != nil {
+		config.Logger.Error().Err(err).Msg("Failed to start SIEM forwarder")
+	}
+
+	// 启动 Suricata EVE 采集器
+	eveIngestor := eve.GetIngestor(db)
+	if err := eveIngestor.Start(); err != nil {
+		config.Logger.Error().Err(err).Msg("Failed to start Suricata EVE ingestor")
+	}
+
+	// 启动 HAProxy 访问日志接收器
+	accessLogReceiver := accesslog.GetReceiver(db)
+	if err := accessLogReceiver.Start(); err != nil {
+		config.Logger.Error().Err(err).Msg("Failed to start access log receiver")
+	}
+
+	// 启动日志保留管理器
+	retentionManager := retention.NewManager(db)
+	if err := retentionManager.Start(); err != nil {
+		config.Logger.Error().Err(err).Msg("Failed to start retention manager")
+	}
+
+	// 启动安全事件关联器
+	incidentCorrelator := incident.NewCorrelator(db,
+		time.Duration(config.Global.Incident.WindowMinutes)*time.Minute,
+		time.Duration(config.Global.Incident.IntervalSec)*time.Second)
+	if err := incidentCorrelator.Start(); err != nil {
+		config.Logger.Error().Err(err).Msg("Failed to start incident correlator")
+	}
+
+	// 启动自动封禁管理器
+	banManager := ban.GetManager(db)
+	if err := banManager.Start(); err != nil {
+		config.Logger.Error().Err(err).Msg("Failed to start ban manager")
+	}
+
 	// Set Gin mode based on configuration
 	if config.Global.IsProduction {
 		gin.SetMode(gin.ReleaseMode)
@@ -162,6 +215,41 @@ func main() {
 		config.Logger.Info().Msg("Server shutdown gracefully")
 	}
 
+	// 停止告警引擎
+	if err := alertEngine.Stop(); err != nil {
+		config.Logger.Error().Err(err).Msg("Failed to stop alert engine")
+	}
+
+	// 停止SIEM转发器
+	if err := siemForwarder.Stop(); err != nil {
+		config.Logger.Error().Err(err).Msg("Failed to stop SIEM forwarder")
+	}
+
+	// 停止 Suricata EVE 采集器
+	if err := eveIngestor.Stop(); err != nil {
+		config.Logger.Error().Err(err).Msg("Failed to stop Suricata EVE ingestor")
+	}
+
+	// 停止 HAProxy 访问日志接收器
+	if err := accessLogReceiver.Stop(); err != nil {
+		config.Logger.Error().Err(err).Msg("Failed to stop access log receiver")
+	}
+
+	// 停止日志保留管理器
+	if err := retentionManager.Stop(); err != nil {
+		config.Logger.Error().Err(err).Msg("Failed to stop retention manager")
+	}
+
+	// 停止安全事件关联器
+	if err := incidentCorrelator.Stop(); err != nil {
+		config.Logger.Error().Err(err).Msg("Failed to stop incident correlator")
+	}
+
+	// 停止自动封禁管理器
+	if err := banManager.Stop(); err != nil {
+		config.Logger.Error().Err(err).Msg("Failed to stop ban manager")
+	}
+
 	// 停止后台服务
 	err = runner.StopServices()
 	if err != nil {
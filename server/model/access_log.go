@@ -0,0 +1,39 @@
+package model
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// AccessLog 表示一条 HAProxy HTTP 访问日志
+//
+// 日志由 HAProxy 通过 syslog 发送到内置接收器，按请求的 Host 和监听端口归属到站点；
+// 无法归属的请求（未知主机名、站点已删除）Domain 为空。时间字段均为毫秒，-1 表示该阶段未完成。
+type AccessLog struct {
+	ID           bson.ObjectID `bson:"_id,omitempty" json:"id"`
+	Timestamp    time.Time     `bson:"timestamp" json:"timestamp"`                           // 请求被接收的时间
+	SiteID       string        `bson:"siteId,omitempty" json:"siteId,omitempty"`             // 站点ID
+	Domain       string        `bson:"domain,omitempty" json:"domain,omitempty"`             // 站点主域名
+	Host         string        `bson:"host,omitempty" json:"host,omitempty"`                 // 请求的 Host，去掉端口并转为小写
+	ListenPort   int           `bson:"listenPort" json:"listenPort"`                         // 监听端口
+	Frontend     string        `bson:"frontend" json:"frontend"`                             // 处理请求的前端
+	Backend      string        `bson:"backend" json:"backend"`                               // 处理请求的后端
+	Server       string        `bson:"server" json:"server"`                                 // 处理请求的服务器，未转发时为 <NOSRV>
+	ClientIP     string        `bson:"clientIp" json:"clientIp"`                             // 客户端地址
+	ClientPort   int           `bson:"clientPort" json:"clientPort"`                         // 客户端端口
+	Method       string        `bson:"method" json:"method"`                                 // 请求方法
+	Path         string        `bson:"path" json:"path"`                                     // 请求路径，不含查询参数
+	Protocol     string        `bson:"protocol,omitempty" json:"protocol,omitempty"`         // 协议版本，如 HTTP/1.1
+	Status       int           `bson:"status" json:"status"`                                 // 响应状态码
+	Bytes        int64         `bson:"bytes" json:"bytes"`                                   // 发送给客户端的字节数
+	RequestTime  int64         `bson:"requestTime" json:"requestTime"`                       // 请求总耗时
+	ResponseTime int64         `bson:"responseTime" json:"responseTime"`                     // 后端响应耗时
+	Termination  string        `bson:"termination,omitempty" json:"termination,omitempty"`   // 会话结束状态，如 ----、PH--
+	WAFRequestID string        `bson:"wafRequestId,omitempty" json:"wafRequestId,omitempty"` // Coraza 事务ID，与 WAF 日志的 requestId 对应
+}
+
+// GetCollectionName 返回集合名称
+func (l *AccessLog) GetCollectionName() string {
+	return "access_log"
+}
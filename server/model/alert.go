@@ -0,0 +1,148 @@
+package model
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// AlertRuleType 定义告警规则类型
+type AlertRuleType string
+
+const (
+	AlertRuleIPThreshold AlertRuleType = "ip_threshold" // 单个IP在时间窗口内被拦截次数超过阈值
+	AlertRuleRuleFired   AlertRuleType = "rule_fired"   // 指定规则在指定站点上触发
+	AlertRuleEngineState AlertRuleType = "engine_state" // 引擎运行状态变为指定状态
+	AlertRuleCertExpiry  AlertRuleType = "cert_expiry"  // 证书即将过期
+)
+
+// AlertChannelType 定义告警通道类型
+type AlertChannelType string
+
+const (
+	AlertChannelWebhook AlertChannelType = "webhook" // 聊天机器人类 Webhook（Slack、钉钉、飞书等）
+	AlertChannelSMTP    AlertChannelType = "smtp"    // 邮件
+	AlertChannelHTTP    AlertChannelType = "http"    // 通用 JSON HTTP 接口
+)
+
+// AlertRule 代表一条告警规则
+type AlertRule struct {
+	ID              bson.ObjectID  `bson:"_id,omitempty" json:"id,omitempty"`                          // 规则ID
+	Name            string         `bson:"name" json:"name"`                                           // 规则名称
+	Description     string         `bson:"description" json:"description"`                             // 规则描述
+	Type            AlertRuleType  `bson:"type" json:"type"`                                           // 规则类型
+	Enabled         bool           `bson:"enabled" json:"enabled"`                                     // 是否启用
+	Threshold       int            `bson:"threshold" json:"threshold"`                                 // 阈值，ip_threshold 使用
+	WindowMinutes   int            `bson:"windowMinutes" json:"windowMinutes"`                         // 统计时间窗口（分钟）
+	RuleID          int            `bson:"ruleId,omitempty" json:"ruleId,omitempty"`                   // WAF规则ID，rule_fired 使用
+	Domain          string         `bson:"domain,omitempty" json:"domain,omitempty"`                   // 站点域名，为空表示所有站点
+	EngineState     string         `bson:"engineState,omitempty" json:"engineState,omitempty"`         // 目标引擎状态，engine_state 使用
+	ExpiryDays      int            `bson:"expiryDays,omitempty" json:"expiryDays,omitempty"`           // 证书剩余天数阈值，cert_expiry 使用
+	CooldownMinutes int            `bson:"cooldownMinutes" json:"cooldownMinutes"`                     // 相同告警的冷却时间（分钟）
+	Channels        []AlertChannel `bson:"channels" json:"channels"`                                   // 通知通道
+	LastTriggeredAt time.Time      `bson:"lastTriggeredAt,omitempty" json:"lastTriggeredAt,omitempty"` // 最近触发时间
+	CreatedAt       time.Time      `bson:"createdAt" json:"createdAt"`
+	UpdatedAt       time.Time      `bson:"updatedAt" json:"updatedAt"`
+}
+
+// AlertChannel 代表一个通知通道
+type AlertChannel struct {
+	Type    AlertChannelType  `bson:"type" json:"type"`                           // 通道类型
+	URL     string            `bson:"url,omitempty" json:"url,omitempty"`         // webhook/http 地址
+	Method  string            `bson:"method,omitempty" json:"method,omitempty"`   // http 请求方法，默认 POST
+	Headers map[string]string `bson:"headers,omitempty" json:"headers,omitempty"` // http 自定义请求头
+	SMTP    *SMTPConfig       `bson:"smtp,omitempty" json:"smtp,omitempty"`       // 邮件配置
+}
+
+// SMTPConfig 邮件发送配置
+type SMTPConfig struct {
+	Host     string   `bson:"host" json:"host"`                           // SMTP服务器地址
+	Port     int      `bson:"port" json:"port"`                           // SMTP服务器端口
+	Username string   `bson:"username" json:"username"`                   // 用户名，为空表示不认证
+	Password string   `bson:"password" json:"-"`                          // 密码，只通过请求写入，不在响应中返回
+	From     string   `bson:"from" json:"from"`                           // 发件人
+	To       []string `bson:"to" json:"to"`                               // 收件人列表
+	StartTLS bool     `bson:"startTLS" json:"startTLS"`                   // 是否使用 STARTTLS
+	Insecure bool     `bson:"insecure" json:"insecure"`                   // 是否跳过证书校验
+	Subject  string   `bson:"subject,omitempty" json:"subject,omitempty"` // 邮件主题前缀
+}
+
+// AlertHistory 代表一次告警投递记录
+type AlertHistory struct {
+	ID          bson.ObjectID    `bson:"_id,omitempty" json:"id,omitempty"`      // 记录ID
+	RuleID      bson.ObjectID    `bson:"ruleId" json:"ruleId"`                   // 告警规则ID
+	RuleName    string           `bson:"ruleName" json:"ruleName"`               // 告警规则名称
+	RuleType    AlertRuleType    `bson:"ruleType" json:"ruleType"`               // 告警规则类型
+	Fingerprint string           `bson:"fingerprint" json:"fingerprint"`         // 去重指纹
+	Title       string           `bson:"title" json:"title"`                     // 告警标题
+	Message     string           `bson:"message" json:"message"`                 // 告警内容
+	Channel     AlertChannelType `bson:"channel" json:"channel"`                 // 投递通道类型
+	Target      string           `bson:"target" json:"target"`                   // 投递目标（URL或收件人）
+	Success     bool             `bson:"success" json:"success"`                 // 是否投递成功
+	Error       string           `bson:"error,omitempty" json:"error,omitempty"` // 投递失败原因
+	CreatedAt   time.Time        `bson:"createdAt" json:"createdAt"`             // 投递时间
+}
+
+// IsValidAlertRuleType 检查告警规则类型是否有效
+func IsValidAlertRuleType(t AlertRuleType) bool {
+	switch t {
+	case AlertRuleIPThreshold, AlertRuleRuleFired, AlertRuleEngineState, AlertRuleCertExpiry:
+		return true
+	}
+	return false
+}
+
+// NewAlertRule 创建一个新告警规则，设置默认值
+func NewAlertRule() *AlertRule {
+	now := time.Now()
+	return &AlertRule{
+		Enabled:         true,
+		WindowMinutes:   5,
+		CooldownMinutes: 30,
+		Channels:        make([]AlertChannel, 0),
+		CreatedAt:       now,
+		UpdatedAt:       now,
+	}
+}
+
+// ValidateAlertRule 校验告警规则并补全默认值
+func ValidateAlertRule(rule *AlertRule) error {
+	if !IsValidAlertRuleType(rule.Type) {
+		return ErrInvalidFormat
+	}
+	if rule.WindowMinutes <= 0 {
+		rule.WindowMinutes = 5
+	}
+	if rule.CooldownMinutes < 0 {
+		rule.CooldownMinutes = 0
+	}
+	switch rule.Type {
+	case AlertRuleIPThreshold:
+		if rule.Threshold <= 0 {
+			return ErrMissingRequiredField
+		}
+	case AlertRuleRuleFired:
+		if rule.RuleID <= 0 {
+			return ErrMissingRequiredField
+		}
+	case AlertRuleEngineState:
+		if rule.EngineState == "" {
+			rule.EngineState = "error"
+		}
+	case AlertRuleCertExpiry:
+		if rule.ExpiryDays <= 0 {
+			rule.ExpiryDays = 14
+		}
+	}
+	return nil
+}
+
+// GetCollectionName 返回集合名称
+func (r *AlertRule) GetCollectionName() string {
+	return "alert_rule"
+}
+
+// GetCollectionName 返回集合名称
+func (h *AlertHistory) GetCollectionName() string {
+	return "alert_history"
+}
@@ -0,0 +1,37 @@
+package model
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// 审计操作类型
+const (
+	AuditActionLogExport       = "waf_log.export"    // 导出攻击日志
+	AuditActionArchiveRestore  = "retention.restore" // 恢复归档数据
+	AuditActionBanCreate       = "ban.create"        // 手动封禁IP
+	AuditActionBanRevoke       = "ban.revoke"        // 手动解除封禁
+	AuditActionHAProxyRollback = "haproxy.rollback"  // 回滚HAProxy配置
+)
+
+// AuditLog 代表一条审计记录
+type AuditLog struct {
+	ID        bson.ObjectID  `bson:"_id,omitempty" json:"id,omitempty"`        // 记录ID
+	UserID    string         `bson:"userId" json:"userId"`                     // 操作用户ID
+	Username  string         `bson:"username" json:"username"`                 // 操作用户名
+	Role      string         `bson:"role" json:"role"`                         // 操作用户角色
+	Action    string         `bson:"action" json:"action"`                     // 操作类型
+	Resource  string         `bson:"resource" json:"resource"`                 // 操作对象
+	Detail    map[string]any `bson:"detail,omitempty" json:"detail,omitempty"` // 操作详情
+	Success   bool           `bson:"success" json:"success"`                   // 是否成功
+	Error     string         `bson:"error,omitempty" json:"error,omitempty"`   // 失败原因
+	ClientIP  string         `bson:"clientIp" json:"clientIp"`                 // 客户端IP
+	RequestID string         `bson:"requestId" json:"requestId"`               // 请求ID
+	CreatedAt time.Time      `bson:"createdAt" json:"createdAt"`               // 操作时间
+}
+
+// GetCollectionName 返回集合名称
+func (a *AuditLog) GetCollectionName() string {
+	return "audit_log"
+}
@@ -0,0 +1,139 @@
+package model
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// 封禁来源
+const (
+	BanSourceWAF    = "waf"    // WAF 拦截日志
+	BanSourceIDS    = "ids"    // Suricata 告警
+	BanSourceManual = "manual" // 手动封禁
+)
+
+// BanPolicy 自动封禁策略
+//
+// 同一IP在 WindowSeconds 内命中 Threshold 条符合条件的事件时触发封禁，封禁时长按阶梯递增：
+// 在 EscalationHours 内第 n 次被封禁的IP使用 Durations 的第 n 项，超出时使用最后一项，0 表示永久封禁。
+type BanPolicy struct {
+	ID              bson.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`          // 策略ID
+	Name            string        `bson:"name" json:"name"`                           // 策略名称
+	Description     string        `bson:"description" json:"description"`             // 策略描述
+	Enabled         bool          `bson:"enabled" json:"enabled"`                     // 是否启用
+	Source          string        `bson:"source" json:"source"`                       // 事件来源 waf/ids
+	Threshold       int           `bson:"threshold" json:"threshold"`                 // 窗口内事件数阈值
+	WindowSeconds   int           `bson:"windowSeconds" json:"windowSeconds"`         // 统计时间窗口（秒）
+	Actions         []string      `bson:"actions,omitempty" json:"actions,omitempty"` // WAF 拦截动作过滤，为空表示所有动作
+	MaxSeverity     int           `bson:"maxSeverity,omitempty" json:"maxSeverity"`   // 只统计严重级别不低于该值的 Suricata 告警（1最高），0 表示所有告警
+	Durations       []int         `bson:"durations" json:"durations"`                 // 封禁时长阶梯（分钟），0 表示永久
+	EscalationHours int           `bson:"escalationHours" json:"escalationHours"`     // 统计重复封禁的回溯时间（小时）
+	CreatedAt       time.Time     `bson:"createdAt" json:"createdAt"`
+	UpdatedAt       time.Time     `bson:"updatedAt" json:"updatedAt"`
+}
+
+// Ban 一条IP封禁记录
+type Ban struct {
+	ID         bson.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`                // 封禁ID
+	IP         string        `bson:"ip" json:"ip"`                                     // 被封禁的IP
+	Source     string        `bson:"source" json:"source"`                             // 封禁来源 waf/ids/manual
+	PolicyID   bson.ObjectID `bson:"policyId,omitempty" json:"policyId,omitempty"`     // 触发的策略ID，手动封禁为空
+	PolicyName string        `bson:"policyName,omitempty" json:"policyName,omitempty"` // 触发的策略名称
+	Reason     string        `bson:"reason" json:"reason"`                             // 封禁原因
+	Level      int           `bson:"level" json:"level"`                               // 阶梯级别，从1开始
+	Active     bool          `bson:"active" json:"active"`                             // 是否生效中
+	StartedAt  time.Time     `bson:"startedAt" json:"startedAt"`                       // 封禁开始时间
+	ExpiresAt  *time.Time    `bson:"expiresAt,omitempty" json:"expiresAt,omitempty"`   // 到期时间，为空表示永久
+	EventCount int           `bson:"eventCount" json:"eventCount"`                     // 触发封禁的事件总数
+	Events     []BanEvent    `bson:"events,omitempty" json:"events,omitempty"`         // 触发封禁的事件，最多保留最近的 MaxBanEvents 条
+	CreatedBy  string        `bson:"createdBy" json:"createdBy"`                       // 创建者，自动封禁为 system
+	RevokedAt  *time.Time    `bson:"revokedAt,omitempty" json:"revokedAt,omitempty"`   // 手动解封时间
+	RevokedBy  string        `bson:"revokedBy,omitempty" json:"revokedBy,omitempty"`   // 手动解封的用户
+	CreatedAt  time.Time     `bson:"createdAt" json:"createdAt"`
+	UpdatedAt  time.Time     `bson:"updatedAt" json:"updatedAt"`
+}
+
+// BanEvent 触发封禁的单条事件
+type BanEvent struct {
+	Source   string        `bson:"source" json:"source"`                 // 事件来源 waf/ids
+	EventID  bson.ObjectID `bson:"eventId" json:"eventId"`               // 原始记录ID
+	Time     time.Time     `bson:"time" json:"time"`                     // 事件时间
+	RuleID   int           `bson:"ruleId" json:"ruleId"`                 // WAF 规则ID或 Suricata SID
+	Severity int           `bson:"severity" json:"severity"`             // 来源原始严重级别
+	Message  string        `bson:"message" json:"message"`               // 事件描述
+	URI      string        `bson:"uri,omitempty" json:"uri,omitempty"`   // 请求URI
+	Host     string        `bson:"host,omitempty" json:"host,omitempty"` // 目标站点
+}
+
+// MaxBanEvents 每条封禁记录保存的触发事件上限
+const MaxBanEvents = 50
+
+// BanSystemUser 自动封禁的创建者
+const BanSystemUser = "system"
+
+// NewBanPolicy 创建一个新封禁策略，设置默认值
+func NewBanPolicy() *BanPolicy {
+	now := time.Now()
+	return &BanPolicy{
+		Enabled:         true,
+		Threshold:       20,
+		WindowSeconds:   300,
+		Durations:       []int{60},
+		EscalationHours: 24,
+		CreatedAt:       now,
+		UpdatedAt:       now,
+	}
+}
+
+// ValidateBanPolicy 校验封禁策略并补全默认值
+func ValidateBanPolicy(policy *BanPolicy) error {
+	if policy.Source != BanSourceWAF && policy.Source != BanSourceIDS {
+		return ErrInvalidFormat
+	}
+	if policy.Threshold <= 0 || policy.WindowSeconds <= 0 || len(policy.Durations) == 0 {
+		return ErrMissingRequiredField
+	}
+	for _, d := range policy.Durations {
+		if d < 0 {
+			return ErrInvalidFormat
+		}
+	}
+	if policy.MaxSeverity < 0 {
+		return ErrInvalidFormat
+	}
+	if policy.EscalationHours <= 0 {
+		policy.EscalationHours = 24
+	}
+	if policy.Source == BanSourceWAF {
+		policy.MaxSeverity = 0
+	} else {
+		policy.Actions = nil
+	}
+	return nil
+}
+
+// Duration 返回第 level 次封禁的时长，0 表示永久
+func (p *BanPolicy) Duration(level int) time.Duration {
+	if len(p.Durations) == 0 {
+		return 0
+	}
+	i := level - 1
+	if i < 0 {
+		i = 0
+	}
+	if i >= len(p.Durations) {
+		i = len(p.Durations) - 1
+	}
+	return time.Duration(p.Durations[i]) * time.Minute
+}
+
+// GetCollectionName 返回集合名称
+func (p *BanPolicy) GetCollectionName() string {
+	return "ban_policy"
+}
+
+// GetCollectionName 返回集合名称
+func (b *Ban) GetCollectionName() string {
+	return "ban"
+}
@@ -0,0 +1,68 @@
+package model
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestValidateBanPolicy(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(p *BanPolicy)
+		want   error
+	}{
+		{"valid waf", func(p *BanPolicy) { p.Source = BanSourceWAF }, nil},
+		{"valid ids", func(p *BanPolicy) { p.Source = BanSourceIDS; p.MaxSeverity = 2 }, nil},
+		{"manual source", func(p *BanPolicy) { p.Source = BanSourceManual }, ErrInvalidFormat},
+		{"zero threshold", func(p *BanPolicy) { p.Source = BanSourceWAF; p.Threshold = 0 }, ErrMissingRequiredField},
+		{"zero window", func(p *BanPolicy) { p.Source = BanSourceWAF; p.WindowSeconds = 0 }, ErrMissingRequiredField},
+		{"no durations", func(p *BanPolicy) { p.Source = BanSourceWAF; p.Durations = nil }, ErrMissingRequiredField},
+		{"negative duration", func(p *BanPolicy) { p.Source = BanSourceWAF; p.Durations = []int{60, -1} }, ErrInvalidFormat},
+		{"negative severity", func(p *BanPolicy) { p.Source = BanSourceIDS; p.MaxSeverity = -1 }, ErrInvalidFormat},
+	}
+	for _, tt := range tests {
+		p := NewBanPolicy()
+		tt.modify(p)
+		if err := ValidateBanPolicy(p); !errors.Is(err, tt.want) {
+			t.Errorf("%s: ValidateBanPolicy = %v, want %v", tt.name, err, tt.want)
+		}
+	}
+}
+
+func TestValidateBanPolicyNormalizes(t *testing.T) {
+	waf := &BanPolicy{Source: BanSourceWAF, Threshold: 5, WindowSeconds: 60, Durations: []int{10}, MaxSeverity: 2, Actions: []string{"deny"}}
+	if err := ValidateBanPolicy(waf); err != nil {
+		t.Fatal(err)
+	}
+	if waf.MaxSeverity != 0 || waf.EscalationHours != 24 || len(waf.Actions) != 1 {
+		t.Errorf("waf policy = %+v, want severity cleared and default escalation", waf)
+	}
+
+	ids := &BanPolicy{Source: BanSourceIDS, Threshold: 5, WindowSeconds: 60, Durations: []int{10}, MaxSeverity: 2, Actions: []string{"deny"}, EscalationHours: 48}
+	if err := ValidateBanPolicy(ids); err != nil {
+		t.Fatal(err)
+	}
+	if ids.Actions != nil || ids.MaxSeverity != 2 || ids.EscalationHours != 48 {
+		t.Errorf("ids policy = %+v, want actions cleared", ids)
+	}
+}
+
+func TestBanPolicyDuration(t *testing.T) {
+	p := &BanPolicy{Durations: []int{10, 60, 0}}
+	tests := map[int]time.Duration{
+		0: 10 * time.Minute,
+		1: 10 * time.Minute,
+		2: time.Hour,
+		3: 0,
+		9: 0,
+	}
+	for level, want := range tests {
+		if got := p.Duration(level); got != want {
+			t.Errorf("Duration(%d) = %s, want %s", level, got, want)
+		}
+	}
+	if got := (&BanPolicy{}).Duration(1); got != 0 {
+		t.Errorf("Duration without steps = %s, want 0", got)
+	}
+}
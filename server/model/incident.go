@@ -0,0 +1,85 @@
+package model
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// IncidentStatus 安全事件处理状态
+type IncidentStatus string
+
+const (
+	IncidentStatusOpen          IncidentStatus = "open"           // 待处理
+	IncidentStatusAcknowledged  IncidentStatus = "acknowledged"   // 已确认，处理中
+	IncidentStatusFalsePositive IncidentStatus = "false-positive" // 误报
+	IncidentStatusResolved      IncidentStatus = "resolved"       // 已解决
+)
+
+// 安全事件来源
+const (
+	IncidentSourceWAF    = "waf"    // WAF 拦截日志
+	IncidentSourceIDS    = "ids"    // Suricata 告警
+	IncidentSourceAccess = "access" // HAProxy 访问日志中的 4xx 请求
+)
+
+// IsValidIncidentStatus 检查安全事件状态是否有效
+func IsValidIncidentStatus(status IncidentStatus) bool {
+	switch status {
+	case IncidentStatusOpen, IncidentStatusAcknowledged, IncidentStatusFalsePositive, IncidentStatusResolved:
+		return true
+	}
+	return false
+}
+
+// IsActive 未关闭的安全事件会继续合并新的事件
+func (s IncidentStatus) IsActive() bool {
+	return s == IncidentStatusOpen || s == IncidentStatusAcknowledged
+}
+
+// Incident 安全事件，由同一来源IP针对同一目标、时间上相邻的 WAF、IDS 和访问日志事件关联而成
+type Incident struct {
+	ID           bson.ObjectID     `bson:"_id,omitempty" json:"id,omitempty"`          // 安全事件ID
+	SrcIP        string            `bson:"srcIp" json:"srcIp"`                         // 来源IP
+	Target       string            `bson:"target" json:"target"`                       // 目标，站点域名或目标IP
+	Status       IncidentStatus    `bson:"status" json:"status"`                       // 处理状态
+	Assignee     string            `bson:"assignee,omitempty" json:"assignee"`         // 负责人用户名
+	FirstSeen    time.Time         `bson:"firstSeen" json:"firstSeen"`                 // 首个事件时间
+	LastSeen     time.Time         `bson:"lastSeen" json:"lastSeen"`                   // 最近事件时间
+	WAFEvents    int64             `bson:"wafEvents" json:"wafEvents"`                 // WAF 事件数
+	IDSEvents    int64             `bson:"idsEvents" json:"idsEvents"`                 // IDS 告警数
+	AccessEvents int64             `bson:"accessEvents" json:"accessEvents"`           // 4xx 访问请求数
+	RuleIDs      []int             `bson:"ruleIds,omitempty" json:"ruleIds"`           // 触发的 WAF 规则ID
+	SignatureIDs []int             `bson:"signatureIds,omitempty" json:"signatureIds"` // 触发的 Suricata 规则 SID
+	Comments     []IncidentComment `bson:"comments,omitempty" json:"comments"`         // 处理备注
+	ClosedAt     *time.Time        `bson:"closedAt,omitempty" json:"closedAt"`         // 关闭时间（误报或已解决）
+	CreatedAt    time.Time         `bson:"createdAt" json:"createdAt"`
+	UpdatedAt    time.Time         `bson:"updatedAt" json:"updatedAt"`
+	Batches      []string          `bson:"batches,omitempty" json:"-"` // 最近合并过的扫描批次，用于重放批次时去重
+}
+
+// IncidentComment 安全事件处理备注
+type IncidentComment struct {
+	ID        bson.ObjectID `bson:"_id" json:"id"`              // 备注ID
+	UserID    string        `bson:"userId" json:"userId"`       // 作者ID
+	Username  string        `bson:"username" json:"username"`   // 作者用户名
+	Content   string        `bson:"content" json:"content"`     // 备注内容
+	CreatedAt time.Time     `bson:"createdAt" json:"createdAt"` // 创建时间
+}
+
+// IncidentCheckpoint 关联扫描进度，按事件来源记录已处理的最后一条记录ID
+type IncidentCheckpoint struct {
+	Source    string        `bson:"_id" json:"source"`
+	LastID    bson.ObjectID `bson:"lastId" json:"lastId"`
+	UpdatedAt time.Time     `bson:"updatedAt" json:"updatedAt"`
+}
+
+// GetCollectionName 返回集合名称
+func (i *Incident) GetCollectionName() string {
+	return "incident"
+}
+
+// GetCollectionName 返回集合名称
+func (c *IncidentCheckpoint) GetCollectionName() string {
+	return "incident_checkpoint"
+}
@@ -0,0 +1,17 @@
+package model
+
+import "time"
+
+// IngestCheckpoint 日志文件采集进度，重启后从记录的偏移量继续读取
+type IngestCheckpoint struct {
+	ID        string    `bson:"_id" json:"id"`              // 采集源标识
+	Path      string    `bson:"path" json:"path"`           // 文件路径
+	Inode     uint64    `bson:"inode" json:"inode"`         // 文件 inode，用于识别轮转后的新文件
+	Offset    int64     `bson:"offset" json:"offset"`       // 已处理到的字节偏移量
+	UpdatedAt time.Time `bson:"updatedAt" json:"updatedAt"` // 更新时间
+}
+
+// GetCollectionName 返回集合名称
+func (c *IngestCheckpoint) GetCollectionName() string {
+	return "ingest_checkpoint"
+}
@@ -43,6 +43,24 @@ const (
 
 	// WAF日志权限
 	PermWAFLogRead = "waf:log:read"
+
+	// 告警管理权限
+	PermAlertCreate = "alert:create"
+	PermAlertRead   = "alert:read"
+	PermAlertUpdate = "alert:update"
+	PermAlertDelete = "alert:delete"
+
+	// IDS规则管理权限
+	PermIDSRuleRead   = "ids:rule:read"
+	PermIDSRuleUpdate = "ids:rule:update"
+
+	// 安全事件权限
+	PermIncidentRead   = "incident:read"
+	PermIncidentUpdate = "incident:update"
+
+	// 自动封禁权限
+	PermBanRead   = "ban:read"
+	PermBanUpdate = "ban:update"
 )
 
 // Role 角色模型
@@ -67,6 +85,10 @@ func GetDefaultRolePermissions() map[string][]string {
 			PermSystemRestart, PermSystemStatus,
 			PermWAFLogRead,
 			PermCertCreate, PermCertRead, PermCertUpdate, PermCertDelete,
+			PermAlertCreate, PermAlertRead, PermAlertUpdate, PermAlertDelete,
+			PermIDSRuleRead, PermIDSRuleUpdate,
+			PermIncidentRead, PermIncidentUpdate,
+			PermBanRead, PermBanUpdate,
 		},
 		RoleAuditor: {
 			// 审计员可以查看用户、站点、配置和审计日志
@@ -77,6 +99,10 @@ func GetDefaultRolePermissions() map[string][]string {
 			PermSystemStatus,
 			PermWAFLogRead,
 			PermCertRead,
+			PermAlertRead,
+			PermIDSRuleRead,
+			PermIncidentRead,
+			PermBanRead,
 		},
 		RoleConfigurator: {
 			// 配置管理员可以管理站点和配置
@@ -85,6 +111,10 @@ func GetDefaultRolePermissions() map[string][]string {
 			PermSystemStatus,
 			PermWAFLogRead,
 			PermCertRead, PermCertUpdate, PermCertDelete,
+			PermAlertCreate, PermAlertRead, PermAlertUpdate, PermAlertDelete,
+			PermIDSRuleRead, PermIDSRuleUpdate,
+			PermIncidentRead, PermIncidentUpdate,
+			PermBanRead, PermBanUpdate,
 		},
 		RoleUser: {
 			// 普通用户只能查看站点和系统状态
@@ -1,7 +1,18 @@
 package model
 
 import (
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
 	"time"
+	"unicode"
 
 	"go.mongodb.org/mongo-driver/v2/bson"
 )
@@ -16,18 +27,30 @@ const (
 
 // Site 代表一个站点配置
 type Site struct {
-	ID           bson.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`                  // 站点ID
-	Name         string        `bson:"name" json:"name"`                                   // 站点名称
-	Domain       string        `bson:"domain" json:"domain"`                               // 域名，如 a.com
-	ListenPort   int           `bson:"listenPort" json:"listenPort"`                       // 监听端口，如 9000
-	EnableHTTPS  bool          `bson:"enableHTTPS" json:"enableHTTPS"`                     // 是否启用HTTPS
-	Certificate  Certificate   `bson:"certificate,omitempty" json:"certificate,omitempty"` // 证书信息
-	Backend      Backend       `bson:"backend" json:"backend"`                             // 后端服务器配置
-	WAFEnabled   bool          `bson:"wafEnabled" json:"wafEnabled"`                       // 是否启用WAF
-	WAFMode      WAFMode       `bson:"wafMode" json:"wafMode"`                             // WAF防护模式
-	CreatedAt    time.Time     `bson:"createdAt" json:"createdAt"`
-	UpdatedAt    time.Time     `bson:"updatedAt" json:"updatedAt"`
-	ActiveStatus bool          `bson:"activeStatus" json:"activeStatus"` // 站点是否激活
+	ID           bson.ObjectID  `bson:"_id,omitempty" json:"id,omitempty"`                  // 站点ID
+	Name         string         `bson:"name" json:"name"`                                   // 站点名称
+	Domain       string         `bson:"domain" json:"domain"`                               // 主域名，如 a.com，*.a.com 匹配 a.com 的所有子域名
+	Aliases      []string       `bson:"aliases,omitempty" json:"aliases,omitempty"`         // 别名，与主域名使用相同的匹配规则
+	ListenPort   int            `bson:"listenPort" json:"listenPort"`                       // 监听端口，如 9000
+	ListenAddrs  []string       `bson:"listenAddrs,omitempty" json:"listenAddrs,omitempty"` // 监听地址，为空表示全部 IPv4 地址，:: 表示同时监听 IPv4 和 IPv6
+	Proxy        *ProxyOptions  `bson:"proxy,omitempty" json:"proxy,omitempty"`             // PROXY 协议设置，为空表示监听不接受也不向后端发送
+	Mode         SiteMode       `bson:"mode,omitempty" json:"mode,omitempty"`               // 站点类型 http/tcp，为空表示 http
+	TCP          *TCPOptions    `bson:"tcp,omitempty" json:"tcp,omitempty"`                 // TCP 站点的连接设置
+	EnableHTTPS  bool           `bson:"enableHTTPS" json:"enableHTTPS"`                     // 是否启用HTTPS
+	Certificate  Certificate    `bson:"certificate,omitempty" json:"certificate,omitempty"` // 证书信息
+	TLS          *TLSConfig     `bson:"tls,omitempty" json:"tls,omitempty"`                 // TLS 策略，启用 HTTPS 时生效，为空时使用 HAProxy 默认设置
+	HTTP2        bool           `bson:"http2" json:"http2"`                                 // HTTPS 是否启用 HTTP/2，TLS 策略指定 ALPN 时由 ALPN 决定
+	HTTP3        bool           `bson:"http3" json:"http3"`                                 // HTTPS 是否同时通过 QUIC 提供 HTTP/3，并在响应中添加 alt-svc
+	Backend      Backend        `bson:"backend" json:"backend"`                             // 后端服务器配置
+	Locations    []Location     `bson:"locations,omitempty" json:"locations,omitempty"`     // 路径路由规则，按顺序匹配，未命中的请求转发到 Backend
+	Headers      *HeaderConfig  `bson:"headers,omitempty" json:"headers,omitempty"`         // 请求头和响应头改写，为空表示不改写
+	Redirects    []RedirectRule `bson:"redirects,omitempty" json:"redirects,omitempty"`     // 跳转规则，按顺序匹配，先于改写规则
+	Rewrites     []RewriteRule  `bson:"rewrites,omitempty" json:"rewrites,omitempty"`       // 转发前的路径和查询串改写，按顺序执行
+	WAFEnabled   bool           `bson:"wafEnabled" json:"wafEnabled"`                       // 是否启用WAF
+	WAFMode      WAFMode        `bson:"wafMode" json:"wafMode"`                             // WAF防护模式
+	CreatedAt    time.Time      `bson:"createdAt" json:"createdAt"`
+	UpdatedAt    time.Time      `bson:"updatedAt" json:"updatedAt"`
+	ActiveStatus bool           `bson:"activeStatus" json:"activeStatus"` // 站点是否激活
 }
 
 // Certificate 代表证书信息
@@ -42,14 +65,369 @@ type Certificate struct {
 
 // Backend 代表后端服务器配置
 type Backend struct {
-	Servers []Server `bson:"servers" json:"servers"` // 服务器列表
+	Servers       []Server       `bson:"servers" json:"servers"`                                 // 服务器列表
+	Balance       BalanceMethod  `bson:"balance,omitempty" json:"balance,omitempty"`             // 负载均衡算法，为空时使用 roundrobin
+	HealthCheck   *HealthCheck   `bson:"healthCheck,omitempty" json:"healthCheck,omitempty"`     // 健康检查，为空表示不检查
+	StickySession *StickySession `bson:"stickySession,omitempty" json:"stickySession,omitempty"` // 基于 Cookie 的会话保持，为空表示不启用
 }
 
 // Server 代表单个后端服务器
 type Server struct {
-	Host  string `bson:"host" json:"host"`   // 主机地址，如 IP 或域名
-	Port  int    `bson:"port" json:"port"`   // 端口
-	IsSSL bool   `bson:"isSSL" json:"isSSL"` // 是否启用SSL
+	Host    string      `bson:"host" json:"host"`                           // 主机地址，如 IP 或域名
+	Port    int         `bson:"port" json:"port"`                           // 端口
+	IsSSL   bool        `bson:"isSSL" json:"isSSL"`                         // 是否启用SSL
+	Weight  int         `bson:"weight,omitempty" json:"weight,omitempty"`   // 权重 1-256，为空时使用默认权重 1
+	Backup  bool        `bson:"backup" json:"backup"`                       // 备用服务器，所有主服务器不可用时才接收请求
+	MaxConn int         `bson:"maxConn,omitempty" json:"maxConn,omitempty"` // 最大并发连接数，为空表示不限制，超出的请求排队
+	State   ServerState `bson:"state,omitempty" json:"state,omitempty"`     // 管理状态，为空表示 ready
+}
+
+// ServerState 后端服务器的管理状态
+type ServerState string
+
+const (
+	ServerStateReady ServerState = "ready" // 正常接收请求
+	ServerStateDrain ServerState = "drain" // 不再接收新请求，会话保持的请求和已有连接继续处理
+	ServerStateMaint ServerState = "maint" // 维护状态，不接收任何请求
+)
+
+// BalanceMethod 负载均衡算法
+type BalanceMethod string
+
+const (
+	BalanceRoundRobin BalanceMethod = "roundrobin" // 按权重轮询
+	BalanceLeastConn  BalanceMethod = "leastconn"  // 最少连接
+	BalanceSource     BalanceMethod = "source"     // 按客户端IP哈希
+	BalanceURI        BalanceMethod = "uri"        // 按请求路径哈希
+)
+
+// HealthCheckType 健康检查方式
+type HealthCheckType string
+
+const (
+	HealthCheckTCP  HealthCheckType = "tcp"  // 只检查端口能否建立连接
+	HealthCheckHTTP HealthCheckType = "http" // 发送 HTTP 请求并检查响应状态码
+)
+
+// 健康检查参数的默认值和范围
+const (
+	DefaultHealthCheckInterval = 2 // 秒
+	DefaultHealthCheckRise     = 2
+	DefaultHealthCheckFall     = 3
+	MaxHealthCheckInterval     = 3600
+	MaxHealthCheckThreshold    = 100
+	MaxServerWeight            = 256
+)
+
+// DefaultStickyCookie 会话保持默认使用的 Cookie 名称
+const DefaultStickyCookie = "SERVERID"
+
+// ErrInvalidBackend 后端配置无效
+var ErrInvalidBackend = errors.New("无效的后端配置")
+
+var cookieNamePattern = regexp.MustCompile(`^[A-Za-z0-9!#$%&*+.^_|~-]+$`)
+
+// HealthCheck 后端服务器健康检查配置
+type HealthCheck struct {
+	Type         HealthCheckType `bson:"type" json:"type"`                                     // 检查方式 tcp/http
+	Path         string          `bson:"path,omitempty" json:"path,omitempty"`                 // HTTP 检查的请求路径，默认 /
+	ExpectStatus int             `bson:"expectStatus,omitempty" json:"expectStatus,omitempty"` // HTTP 检查期望的状态码，为空时 2xx 和 3xx 都视为健康
+	Interval     int             `bson:"interval" json:"interval"`                             // 检查间隔（秒）
+	Rise         int             `bson:"rise" json:"rise"`                                     // 连续成功多少次标记为健康
+	Fall         int             `bson:"fall" json:"fall"`                                     // 连续失败多少次标记为不健康
+}
+
+// StickySession 基于 Cookie 的会话保持配置
+type StickySession struct {
+	CookieName string `bson:"cookieName" json:"cookieName"` // HAProxy 插入的 Cookie 名称
+}
+
+// TLSProfile TLS 配置模板
+type TLSProfile string
+
+const (
+	TLSProfileModern       TLSProfile = "modern"       // 只允许 TLS 1.3
+	TLSProfileIntermediate TLSProfile = "intermediate" // 允许 TLS 1.2 和 1.3，兼容大多数客户端
+	TLSProfileCustom       TLSProfile = "custom"       // 自定义协议版本和密码套件
+)
+
+// TLS 协议版本，取值与 HAProxy 的 ssl-min-ver/ssl-max-ver 一致
+const (
+	TLSVersion10 = "TLSv1.0"
+	TLSVersion11 = "TLSv1.1"
+	TLSVersion12 = "TLSv1.2"
+	TLSVersion13 = "TLSv1.3"
+)
+
+// 配置模板使用的密码套件，参考 Mozilla 服务端 TLS 推荐配置
+const (
+	intermediateCiphers = "ECDHE-ECDSA-AES128-GCM-SHA256:ECDHE-RSA-AES128-GCM-SHA256:ECDHE-ECDSA-AES256-GCM-SHA384:ECDHE-RSA-AES256-GCM-SHA384:ECDHE-ECDSA-CHACHA20-POLY1305:ECDHE-RSA-CHACHA20-POLY1305:DHE-RSA-AES128-GCM-SHA256:DHE-RSA-AES256-GCM-SHA384"
+	tls13Ciphersuites   = "TLS_AES_128_GCM_SHA256:TLS_AES_256_GCM_SHA384:TLS_CHACHA20_POLY1305_SHA256"
+)
+
+// HSTSPreloadMinMaxAge 申请 HSTS 预加载要求的最小 max-age（一年）
+const HSTSPreloadMinMaxAge = 31536000
+
+// ErrInvalidHTTPVersion HTTP/2 或 HTTP/3 设置无效
+var ErrInvalidHTTPVersion = errors.New("无效的 HTTP 协议版本设置")
+
+// ErrInvalidTLS TLS 策略无效
+var ErrInvalidTLS = errors.New("无效的 TLS 策略")
+
+var (
+	tlsVersions   = []string{TLSVersion10, TLSVersion11, TLSVersion12, TLSVersion13}
+	alpnProtocols = []string{"h2", "http/1.1"}
+	cipherPattern = regexp.MustCompile(`^[A-Za-z0-9_:+!@=.-]+$`)
+)
+
+// TLSConfig 站点的 TLS 策略
+//
+// 使用 modern 或 intermediate 模板时，协议版本和密码套件由模板决定，ALPN 为空时默认 h2,http/1.1；
+// custom 模板使用填写的值，未填写的项使用 HAProxy 默认设置。
+type TLSConfig struct {
+	Profile      TLSProfile  `bson:"profile" json:"profile"`                               // 配置模板 modern/intermediate/custom
+	MinVersion   string      `bson:"minVersion,omitempty" json:"minVersion,omitempty"`     // 最低协议版本
+	MaxVersion   string      `bson:"maxVersion,omitempty" json:"maxVersion,omitempty"`     // 最高协议版本
+	Ciphers      string      `bson:"ciphers,omitempty" json:"ciphers,omitempty"`           // TLS 1.2 及以下的密码套件，OpenSSL 格式
+	Ciphersuites string      `bson:"ciphersuites,omitempty" json:"ciphersuites,omitempty"` // TLS 1.3 的密码套件
+	ALPN         []string    `bson:"alpn,omitempty" json:"alpn,omitempty"`                 // ALPN 协议，按优先级排列，可选 h2、http/1.1
+	HSTS         *HSTSConfig `bson:"hsts,omitempty" json:"hsts,omitempty"`                 // HSTS 响应头，为空表示不发送
+	OCSPStapling bool        `bson:"ocspStapling" json:"ocspStapling"`                     // 是否启用 OCSP 装订，证书需要包含 OCSP 地址和签发者证书
+}
+
+// HSTSConfig Strict-Transport-Security 响应头配置
+type HSTSConfig struct {
+	MaxAge            int  `bson:"maxAge" json:"maxAge"`                       // 有效期（秒）
+	IncludeSubDomains bool `bson:"includeSubDomains" json:"includeSubDomains"` // 是否包含子域名
+	Preload           bool `bson:"preload" json:"preload"`                     // 是否允许加入浏览器预加载列表
+}
+
+// HeaderValue 返回 Strict-Transport-Security 响应头的值
+func (h HSTSConfig) HeaderValue() string {
+	value := fmt.Sprintf("max-age=%d", h.MaxAge)
+	if h.IncludeSubDomains {
+		value += "; includeSubDomains"
+	}
+	if h.Preload {
+		value += "; preload"
+	}
+	return value
+}
+
+// SiteMode 站点类型
+type SiteMode string
+
+const (
+	SiteModeHTTP SiteMode = "http" // HTTP 站点，经过 Coraza 检测
+	SiteModeTCP  SiteMode = "tcp"  // 四层 TCP 转发，不经过 Coraza，可选由 Suricata 检测
+)
+
+// PROXY 协议版本
+const (
+	ProxyProtocolV1 = "v1"
+	ProxyProtocolV2 = "v2"
+)
+
+// ErrInvalidTCP TCP 站点配置无效
+var ErrInvalidTCP = errors.New("无效的 TCP 站点配置")
+
+// TCPOptions TCP 站点的连接设置
+//
+// TCP 站点独占监听端口，按来源地址过滤连接后转发到后端，不解析应用层协议。
+type TCPOptions struct {
+	MaxConn       int      `bson:"maxConn,omitempty" json:"maxConn,omitempty"`             // 最大并发连接数，0 表示不限制
+	Allow         []string `bson:"allow,omitempty" json:"allow,omitempty"`                 // 允许连接的来源 IP 或网段，为空表示不限制
+	Deny          []string `bson:"deny,omitempty" json:"deny,omitempty"`                   // 拒绝连接的来源 IP 或网段，优先于 Allow
+	ProxyProtocol string   `bson:"proxyProtocol,omitempty" json:"proxyProtocol,omitempty"` // 向后端发送的 PROXY 协议版本 v1/v2，为空表示不发送，与 Proxy.Send 相同
+	Inspect       bool     `bson:"inspect" json:"inspect"`                                 // 是否由 Suricata 检测该端口的流量
+}
+
+// ProxyOptions 站点的 PROXY 协议设置
+//
+// 部署在四层负载均衡之后时，监听只接受来自可信来源的 PROXY 协议头，其他来源的连接按普通连接处理，
+// 客户端无法伪造来源地址；解析出的客户端地址用于封禁、来源过滤和 WAF 检测。
+type ProxyOptions struct {
+	Trusted []string `bson:"trusted,omitempty" json:"trusted,omitempty"` // 允许发送 PROXY 协议头的上游负载均衡 IP 或网段，为空表示监听不接受 PROXY 协议
+	Send    string   `bson:"send,omitempty" json:"send,omitempty"`       // 向后端服务器发送的 PROXY 协议版本 v1/v2，为空表示不发送
+}
+
+// ErrInvalidProxy PROXY 协议设置无效
+var ErrInvalidProxy = errors.New("无效的 PROXY 协议设置")
+
+// MaxSiteAliases 每个站点的别名上限
+const MaxSiteAliases = 20
+
+// ErrInvalidHost 站点域名或别名无效
+var ErrInvalidHost = errors.New("无效的站点域名")
+
+// hostnamePattern 域名格式，与接口校验使用的规则一致
+var hostnamePattern = regexp.MustCompile(`^([a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?\.)+[a-z]{2,}$`)
+
+// MaxListenAddrs 每个站点的监听地址上限
+const MaxListenAddrs = 8
+
+// ErrInvalidListenAddr 站点监听地址无效
+var ErrInvalidListenAddr = errors.New("无效的监听地址")
+
+// LocationMatchType 路径匹配方式
+type LocationMatchType string
+
+const (
+	LocationMatchPrefix LocationMatchType = "prefix" // 前缀匹配，/api 匹配 /api 和 /api/...
+	LocationMatchExact  LocationMatchType = "exact"  // 完全匹配
+	LocationMatchRegex  LocationMatchType = "regex"  // 正则匹配
+)
+
+// MaxSiteLocations 每个站点的路径路由规则上限
+const MaxSiteLocations = 50
+
+// ErrInvalidLocation 路径路由规则无效
+var ErrInvalidLocation = errors.New("无效的路径路由规则")
+
+var (
+	// 头部名称原样写入 req.hdr() 等配置参数，只允许字母、数字、下划线和连字符，
+	// 不接受 HTTP 规范允许但在 HAProxy 配置中有特殊含义的 #、' 等字符
+	headerNamePattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+	methodPattern     = regexp.MustCompile(`^[A-Z]+$`)
+)
+
+// Location 站点下的一条路径路由规则
+//
+// 路径、请求方法和请求头条件同时满足时命中，请求转发到规则自己的后端；
+// 路径改写只作用于转发给后端的请求，WAF 检测使用原始路径。
+type Location struct {
+	Path        string            `bson:"path" json:"path"`                                   // 匹配的路径或正则
+	MatchType   LocationMatchType `bson:"matchType" json:"matchType"`                         // 匹配方式 prefix/exact/regex
+	Methods     []string          `bson:"methods,omitempty" json:"methods,omitempty"`         // 请求方法，为空表示不限
+	Headers     []HeaderMatch     `bson:"headers,omitempty" json:"headers,omitempty"`         // 请求头条件，全部满足时命中
+	Backend     Backend           `bson:"backend" json:"backend"`                             // 命中后转发的后端
+	StripPrefix bool              `bson:"stripPrefix" json:"stripPrefix"`                     // 转发前去掉匹配的前缀，只用于前缀匹配
+	RewritePath string            `bson:"rewritePath,omitempty" json:"rewritePath,omitempty"` // 转发前改写路径，前缀匹配替换前缀，正则匹配可用 \1 引用捕获组，完全匹配替换整个路径
+	DisableWAF  bool              `bson:"disableWAF" json:"disableWAF"`                       // 命中的请求不经过 WAF 检测
+}
+
+// HeaderMatch 请求头匹配条件
+type HeaderMatch struct {
+	Name  string `bson:"name" json:"name"`                       // 请求头名称
+	Value string `bson:"value,omitempty" json:"value,omitempty"` // 请求头的值，为空表示只要求请求头存在
+}
+
+// HeaderPreset 预置的请求头和响应头规则
+type HeaderPreset string
+
+const (
+	HeaderPresetCSP                HeaderPreset = "csp"                  // Content-Security-Policy，只允许加载同源资源
+	HeaderPresetFrameOptions       HeaderPreset = "frame-options"        // X-Frame-Options: SAMEORIGIN
+	HeaderPresetContentTypeOptions HeaderPreset = "content-type-options" // X-Content-Type-Options: nosniff
+	HeaderPresetReferrerPolicy     HeaderPreset = "referrer-policy"      // Referrer-Policy: strict-origin-when-cross-origin
+	HeaderPresetPermissionsPolicy  HeaderPreset = "permissions-policy"   // Permissions-Policy，禁用摄像头、麦克风和定位等浏览器功能
+	HeaderPresetHideServer         HeaderPreset = "hide-server"          // 删除响应中的 Server 和 X-Powered-By
+	HeaderPresetForwardedProto     HeaderPreset = "forwarded-proto"      // 向后端发送 X-Forwarded-Proto，值为 http 或 https
+)
+
+// HeaderDirection 头部改写作用的方向
+type HeaderDirection string
+
+const (
+	HeaderDirectionRequest  HeaderDirection = "request"  // 转发给后端的请求
+	HeaderDirectionResponse HeaderDirection = "response" // 返回给客户端的响应
+)
+
+// HeaderAction 头部改写动作
+type HeaderAction string
+
+const (
+	HeaderActionAdd HeaderAction = "add" // 追加，已有同名头部时保留原值
+	HeaderActionSet HeaderAction = "set" // 替换全部同名头部
+	HeaderActionDel HeaderAction = "del" // 删除全部同名头部
+)
+
+// MaxSiteHeaderRules 每个站点的自定义头部规则上限
+const MaxSiteHeaderRules = 50
+
+// ErrInvalidHeaders 头部改写设置无效
+var ErrInvalidHeaders = errors.New("无效的头部改写设置")
+
+var headerPresets = []HeaderPreset{
+	HeaderPresetCSP, HeaderPresetFrameOptions, HeaderPresetContentTypeOptions, HeaderPresetReferrerPolicy,
+	HeaderPresetPermissionsPolicy, HeaderPresetHideServer, HeaderPresetForwardedProto,
+}
+
+// HeaderConfig 站点的请求头和响应头改写
+//
+// 规则写在站点和路径路由的后端中，只作用于转发到后端的请求和后端返回的响应，
+// WAF 拦截等由 HAProxy 直接生成的响应不经过这些规则。预置规则先于自定义规则执行，
+// 自定义的 set 规则可以覆盖预置规则设置的值。
+type HeaderConfig struct {
+	Presets []HeaderPreset `bson:"presets,omitempty" json:"presets,omitempty"` // 预置规则
+	Rules   []HeaderRule   `bson:"rules,omitempty" json:"rules,omitempty"`     // 自定义规则，按顺序执行
+}
+
+// HeaderRule 一条头部改写规则
+type HeaderRule struct {
+	Direction HeaderDirection `bson:"direction" json:"direction"`             // 作用方向 request/response
+	Action    HeaderAction    `bson:"action" json:"action"`                   // 动作 add/set/del
+	Name      string          `bson:"name" json:"name"`                       // 头部名称
+	Value     string          `bson:"value,omitempty" json:"value,omitempty"` // 头部的值，按原样发送，del 时为空
+}
+
+// RedirectType 跳转方式
+type RedirectType string
+
+const (
+	RedirectHTTPS    RedirectType = "https"    // 跳转到 HTTPS，保留主机名、路径和查询串
+	RedirectPrefix   RedirectType = "prefix"   // 在原路径和查询串前加上 Target，Target 为 https://www.a.com 时可用于主机名规范化
+	RedirectLocation RedirectType = "location" // 用 Target 替换 Path 匹配的路径后跳转，Target 可引用 \1 到 \9 捕获组，保留查询串
+)
+
+// MaxSiteRedirectRules 每个站点的跳转规则上限
+const MaxSiteRedirectRules = 50
+
+// MaxSiteRewriteRules 每个站点的改写规则上限
+const MaxSiteRewriteRules = 50
+
+// ErrInvalidRedirect 跳转或改写规则无效
+var ErrInvalidRedirect = errors.New("无效的跳转或改写规则")
+
+var (
+	redirectCodes     = []int{301, 302, 307, 308}
+	captureRefPattern = regexp.MustCompile(`\\(.?)`)
+)
+
+// RedirectRule 站点的一条跳转规则
+//
+// Target 为以 / 开头的路径时跳转到同一主机名，为 http(s)://host[:port] 开头的地址时跳转到该主机。
+type RedirectRule struct {
+	Type   RedirectType `bson:"type" json:"type"`                         // 跳转方式 https/prefix/location
+	Hosts  []string     `bson:"hosts,omitempty" json:"hosts,omitempty"`   // 只对这些主机名生效，必须是站点的主域名或别名，为空表示全部
+	Path   string       `bson:"path,omitempty" json:"path,omitempty"`     // 匹配路径的正则，为空表示全部路径，location 必填
+	Target string       `bson:"target,omitempty" json:"target,omitempty"` // 跳转目标，https 不需要填写
+	Code   int          `bson:"code" json:"code"`                         // 状态码 301/302/307/308，默认 301
+}
+
+// SplitTarget 将跳转目标拆分为协议和主机部分与路径部分，目标为路径时协议和主机部分为空
+func (r RedirectRule) SplitTarget() (origin, path string) {
+	if strings.HasPrefix(r.Target, "/") {
+		return "", r.Target
+	}
+	scheme, rest, ok := strings.Cut(r.Target, "://")
+	if !ok {
+		return r.Target, ""
+	}
+	if i := strings.Index(rest, "/"); i >= 0 {
+		return scheme + "://" + rest[:i], rest[i:]
+	}
+	return r.Target, ""
+}
+
+// RewriteRule 转发前的一条路径改写规则
+//
+// Path 匹配时整个路径替换为 Target，Target 可引用 \1 到 \9 捕获组。
+// Query 为 true 时匹配和替换的是带查询串的路径，例如 ^/search\?q=(.*)$ 改写为 /find?term=\1。
+type RewriteRule struct {
+	Path   string `bson:"path" json:"path"`                       // 匹配的正则
+	Target string `bson:"target" json:"target"`                   // 改写结果，以 / 开头
+	Query  bool   `bson:"query,omitempty" json:"query,omitempty"` // 是否包括查询串
 }
 
 // IsValidWAFMode 检查WAF模式是否有效
@@ -83,14 +461,738 @@ func NewSite() *Site {
 	}
 }
 
+// IsTCP 判断站点是否为 TCP 站点
+func (s Site) IsTCP() bool {
+	return s.Mode == SiteModeTCP
+}
+
+// TrustedProxies 返回允许发送 PROXY 协议头的来源，为空表示监听不接受 PROXY 协议
+func (s Site) TrustedProxies() []string {
+	if s.Proxy == nil {
+		return nil
+	}
+	return s.Proxy.Trusted
+}
+
+// SendProxyVersion 返回向后端服务器发送的 PROXY 协议版本，为空表示不发送
+func (s Site) SendProxyVersion() string {
+	if s.Proxy != nil && s.Proxy.Send != "" {
+		return s.Proxy.Send
+	}
+	if s.IsTCP() && s.TCP != nil {
+		return s.TCP.ProxyProtocol
+	}
+	return ""
+}
+
+// ALPN 返回站点 HTTPS 在 TCP 连接上协商的 ALPN 协议，按优先级排列，为空表示只使用 HTTP/1.1
+// TLS 策略指定的 ALPN 优先于 HTTP/2 开关。h3 只能在 QUIC 上协商，不在此列出，
+// 开启 HTTP/3 的站点通过 alt-svc 和单独的 QUIC 绑定提供 h3
+func (s Site) ALPN() []string {
+	switch {
+	case s.TLS != nil && len(s.TLS.ALPN) > 0:
+		return slices.Clone(s.TLS.ALPN)
+	case s.HTTP2:
+		return slices.Clone(alpnProtocols)
+	case s.HTTP3:
+		return []string{"http/1.1"}
+	}
+	return nil
+}
+
+// Hosts 返回站点匹配的全部主机名，主域名在前
+func (s Site) Hosts() []string {
+	return append([]string{s.Domain}, s.Aliases...)
+}
+
+// IsWildcardHost 判断主机名是否为 *.a.com 形式的通配符
+func IsWildcardHost(host string) bool {
+	return strings.HasPrefix(host, "*.")
+}
+
+// HostsOverlap 判断两个主机名能否匹配同一个请求
+// 精确域名只匹配自身，通配符匹配任意层级的子域名但不匹配域名本身
+func HostsOverlap(a, b string) bool {
+	a, b = strings.ToLower(a), strings.ToLower(b)
+	if a == b {
+		return true
+	}
+	switch aw, bw := IsWildcardHost(a), IsWildcardHost(b); {
+	case aw && bw:
+		return strings.HasSuffix(a[1:], b[1:]) || strings.HasSuffix(b[1:], a[1:])
+	case aw:
+		return strings.HasSuffix(b, a[1:])
+	case bw:
+		return strings.HasSuffix(a, b[1:])
+	}
+	return false
+}
+
 // ValidateSite 验证站点配置有效性
 func ValidateSite(site *Site) error {
 	if !IsValidWAFMode(site.WAFMode) {
 		site.WAFMode = DefaultWAFMode()
 	}
+	if err := validateHosts(site); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidHost, err)
+	}
+	if err := validateListenAddrs(site); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidListenAddr, err)
+	}
+	if err := validateMode(site); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidTCP, err)
+	}
+	if err := validateProxy(site); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidProxy, err)
+	}
+	if err := validateBackend(&site.Backend); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidBackend, err)
+	}
+	if site.TLS != nil {
+		if net.ParseIP(site.Domain) != nil {
+			return fmt.Errorf("%w: IP 站点不支持 TLS 策略", ErrInvalidTLS)
+		}
+		if err := validateTLS(site.TLS, site.Certificate); err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidTLS, err)
+		}
+	}
+	if err := validateHTTPVersions(site); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidHTTPVersion, err)
+	}
+	if len(site.Locations) > 0 && net.ParseIP(site.Domain) != nil {
+		return fmt.Errorf("%w: IP 站点不支持路径路由", ErrInvalidLocation)
+	}
+	if len(site.Locations) > MaxSiteLocations {
+		return fmt.Errorf("%w: 每个站点最多 %d 条路径路由", ErrInvalidLocation, MaxSiteLocations)
+	}
+	for i := range site.Locations {
+		if err := validateLocation(&site.Locations[i]); err != nil {
+			return fmt.Errorf("%w: 第 %d 条规则: %v", ErrInvalidLocation, i+1, err)
+		}
+	}
+	if err := validateHeaders(site); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidHeaders, err)
+	}
+	if err := validateRedirects(site); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidRedirect, err)
+	}
 	return nil
 }
 
+// validateRedirects 校验跳转和改写规则，补齐默认状态码并规范化主机名
+// 正则、路径和跳转目标以单引号包裹写入 HAProxy 配置，不能包含空白字符和单引号
+func validateRedirects(site *Site) error {
+	if len(site.Redirects) == 0 && len(site.Rewrites) == 0 {
+		return nil
+	}
+	if site.IsTCP() {
+		return errors.New("TCP 站点不支持跳转和改写")
+	}
+	if net.ParseIP(site.Domain) != nil {
+		return errors.New("IP 站点不支持跳转和改写")
+	}
+	if len(site.Redirects) > MaxSiteRedirectRules {
+		return fmt.Errorf("每个站点最多 %d 条跳转规则", MaxSiteRedirectRules)
+	}
+	if len(site.Rewrites) > MaxSiteRewriteRules {
+		return fmt.Errorf("每个站点最多 %d 条改写规则", MaxSiteRewriteRules)
+	}
+
+	for i := range site.Redirects {
+		if err := validateRedirect(site, &site.Redirects[i]); err != nil {
+			return fmt.Errorf("第 %d 条跳转规则: %v", i+1, err)
+		}
+	}
+	for i, rule := range site.Rewrites {
+		groups, err := compilePathRegex(rule.Path)
+		if err != nil {
+			return fmt.Errorf("第 %d 条改写规则: %v", i+1, err)
+		}
+		if err := validateRedirectPath(rule.Target, groups, rule.Query); err != nil {
+			return fmt.Errorf("第 %d 条改写规则: %v", i+1, err)
+		}
+	}
+	return nil
+}
+
+func validateRedirect(site *Site, rule *RedirectRule) error {
+	if rule.Code == 0 {
+		rule.Code = 301
+	}
+	if !slices.Contains(redirectCodes, rule.Code) {
+		return fmt.Errorf("不支持的状态码 %d", rule.Code)
+	}
+
+	for i, host := range rule.Hosts {
+		host = strings.ToLower(host)
+		if !slices.ContainsFunc(site.Hosts(), func(h string) bool { return strings.EqualFold(h, host) }) {
+			return fmt.Errorf("主机名 %s 不是站点的主域名或别名", rule.Hosts[i])
+		}
+		rule.Hosts[i] = host
+	}
+	slices.Sort(rule.Hosts)
+	rule.Hosts = slices.Compact(rule.Hosts)
+
+	groups := 0
+	if rule.Path != "" {
+		n, err := compilePathRegex(rule.Path)
+		if err != nil {
+			return err
+		}
+		groups = n
+	}
+
+	switch rule.Type {
+	case RedirectHTTPS:
+		if !site.EnableHTTPS {
+			return errors.New("站点未启用 HTTPS")
+		}
+		if rule.Target != "" {
+			return errors.New("https 跳转不需要填写目标")
+		}
+	case RedirectPrefix:
+		// HAProxy 在前缀后直接拼接以 / 开头的原路径
+		rule.Target = strings.TrimSuffix(rule.Target, "/")
+		if rule.Target == "" {
+			return errors.New("跳转目标不能为空或 /")
+		}
+		if err := validateRedirectTarget(*rule, 0); err != nil {
+			return err
+		}
+	case RedirectLocation:
+		if rule.Path == "" {
+			return errors.New("location 跳转需要填写匹配路径的正则")
+		}
+		if err := validateRedirectTarget(*rule, groups); err != nil {
+			return err
+		}
+		if _, path := rule.SplitTarget(); path == "" {
+			return errors.New("跳转目标需要包含以 / 开头的路径")
+		}
+	default:
+		return fmt.Errorf("不支持的跳转方式 %s", rule.Type)
+	}
+	return nil
+}
+
+// validateRedirectTarget 校验跳转目标的协议、主机和路径部分，路径中最多引用 groups 个捕获组
+func validateRedirectTarget(rule RedirectRule, groups int) error {
+	origin, path := rule.SplitTarget()
+	if origin != "" {
+		u, err := url.Parse(origin)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" ||
+			u.User != nil || u.Path != "" || u.RawQuery != "" || u.Fragment != "" || !isConfigSafe(origin) {
+			return fmt.Errorf("跳转目标 %s 必须以 / 或 http(s)://主机名 开头", rule.Target)
+		}
+	}
+	if path == "" {
+		return nil
+	}
+	return validateRedirectPath(path, groups, false)
+}
+
+// validateRedirectPath 校验改写或跳转后的路径，query 为 false 时不能包含查询串
+func validateRedirectPath(path string, groups int, query bool) error {
+	if !strings.HasPrefix(path, "/") || !isConfigSafe(path) {
+		return errors.New("目标路径必须以 / 开头且不能包含空白字符和单引号")
+	}
+	if strings.Contains(path, "#") || (!query && strings.Contains(path, "?")) {
+		return errors.New("目标路径不能包含查询串和片段")
+	}
+	for _, ref := range captureRefPattern.FindAllStringSubmatch(path, -1) {
+		if len(ref[1]) != 1 || ref[1][0] < '1' || ref[1][0] > '9' {
+			return errors.New("反斜杠只能用于引用 \\1 到 \\9 捕获组")
+		}
+		if n := int(ref[1][0] - '0'); n > groups {
+			return fmt.Errorf("引用了不存在的捕获组 \\%d", n)
+		}
+	}
+	return nil
+}
+
+// compilePathRegex 校验匹配路径的正则，返回捕获组数量
+func compilePathRegex(expr string) (int, error) {
+	if expr == "" || !isConfigSafe(expr) {
+		return 0, errors.New("正则不能为空或包含空白字符和单引号")
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return 0, fmt.Errorf("正则表达式无效: %v", err)
+	}
+	return re.NumSubexp(), nil
+}
+
+// validateHeaders 校验头部改写设置，预置规则去重，没有任何规则时清空设置
+// 头部的值以双引号包裹写入 HAProxy 配置，不能包含双引号、反斜杠、$ 和控制字符
+func validateHeaders(site *Site) error {
+	headers := site.Headers
+	if headers == nil {
+		return nil
+	}
+	if len(headers.Presets) == 0 && len(headers.Rules) == 0 {
+		site.Headers = nil
+		return nil
+	}
+	if site.IsTCP() {
+		return errors.New("TCP 站点不支持头部改写")
+	}
+	if net.ParseIP(site.Domain) != nil {
+		return errors.New("IP 站点不支持头部改写")
+	}
+
+	for _, preset := range headers.Presets {
+		if !slices.Contains(headerPresets, preset) {
+			return fmt.Errorf("不支持的预置规则 %s", preset)
+		}
+	}
+	slices.Sort(headers.Presets)
+	headers.Presets = slices.Compact(headers.Presets)
+
+	if len(headers.Rules) > MaxSiteHeaderRules {
+		return fmt.Errorf("每个站点最多 %d 条自定义规则", MaxSiteHeaderRules)
+	}
+	for i, rule := range headers.Rules {
+		switch rule.Direction {
+		case HeaderDirectionRequest, HeaderDirectionResponse:
+		default:
+			return fmt.Errorf("第 %d 条规则: 不支持的作用方向 %s", i+1, rule.Direction)
+		}
+		if !headerNamePattern.MatchString(rule.Name) {
+			return fmt.Errorf("第 %d 条规则: 头部名称 %s 无效", i+1, rule.Name)
+		}
+		switch rule.Action {
+		case HeaderActionAdd, HeaderActionSet:
+			if rule.Value == "" {
+				return fmt.Errorf("第 %d 条规则: %s 需要填写头部的值", i+1, rule.Action)
+			}
+			if strings.ContainsFunc(rule.Value, func(r rune) bool {
+				return r < 0x20 || r > 0x7e || r == '"' || r == '\\' || r == '$'
+			}) {
+				return fmt.Errorf("第 %d 条规则: 头部的值只能包含可打印 ASCII 字符，且不能包含双引号、反斜杠和 $", i+1)
+			}
+		case HeaderActionDel:
+			if rule.Value != "" {
+				return fmt.Errorf("第 %d 条规则: del 不需要填写头部的值", i+1)
+			}
+		default:
+			return fmt.Errorf("第 %d 条规则: 不支持的动作 %s", i+1, rule.Action)
+		}
+	}
+	return nil
+}
+
+// validateLocation 校验路径路由规则并规范化匹配方式和请求方法
+// 路径、改写结果和请求头值会以单引号包裹写入 HAProxy 配置，不能包含空白字符和单引号
+func validateLocation(loc *Location) error {
+	if loc.MatchType == "" {
+		loc.MatchType = LocationMatchPrefix
+	}
+	if loc.Path == "" || !isConfigSafe(loc.Path) {
+		return errors.New("路径不能为空或包含空白字符和单引号")
+	}
+
+	switch loc.MatchType {
+	case LocationMatchPrefix, LocationMatchExact:
+		if !strings.HasPrefix(loc.Path, "/") {
+			return errors.New("路径必须以 / 开头")
+		}
+	case LocationMatchRegex:
+		if _, err := regexp.Compile(loc.Path); err != nil {
+			return fmt.Errorf("正则表达式无效: %v", err)
+		}
+	default:
+		return fmt.Errorf("不支持的匹配方式 %s", loc.MatchType)
+	}
+
+	if loc.StripPrefix && loc.MatchType != LocationMatchPrefix {
+		return errors.New("只有前缀匹配可以去掉前缀")
+	}
+	if loc.StripPrefix && loc.RewritePath != "" {
+		return errors.New("去掉前缀和改写路径不能同时设置")
+	}
+	if loc.RewritePath != "" && (!strings.HasPrefix(loc.RewritePath, "/") || !isConfigSafe(loc.RewritePath)) {
+		return errors.New("改写路径必须以 / 开头且不能包含空白字符和单引号")
+	}
+
+	for i, method := range loc.Methods {
+		method = strings.ToUpper(method)
+		if !methodPattern.MatchString(method) {
+			return fmt.Errorf("请求方法 %s 无效", loc.Methods[i])
+		}
+		loc.Methods[i] = method
+	}
+	slices.Sort(loc.Methods)
+	loc.Methods = slices.Compact(loc.Methods)
+
+	for _, header := range loc.Headers {
+		if !headerNamePattern.MatchString(header.Name) {
+			return fmt.Errorf("请求头名称 %s 无效", header.Name)
+		}
+		if !isConfigSafe(header.Value) {
+			return fmt.Errorf("请求头 %s 的值不能包含空白字符和单引号", header.Name)
+		}
+	}
+
+	if len(loc.Backend.Servers) == 0 {
+		return errors.New("至少需要一个后端服务器")
+	}
+	if err := validateBackend(&loc.Backend); err != nil {
+		return fmt.Errorf("后端配置无效: %v", err)
+	}
+	return nil
+}
+
+// validateBackend 校验后端的负载均衡、健康检查、会话保持和服务器参数，并补齐默认值
+func validateBackend(backend *Backend) error {
+	switch backend.Balance {
+	case "", BalanceRoundRobin, BalanceLeastConn, BalanceSource, BalanceURI:
+	default:
+		return fmt.Errorf("不支持的负载均衡算法 %s", backend.Balance)
+	}
+
+	if check := backend.HealthCheck; check != nil {
+		switch check.Type {
+		case HealthCheckTCP:
+			check.Path = ""
+			check.ExpectStatus = 0
+		case HealthCheckHTTP:
+			if check.Path == "" {
+				check.Path = "/"
+			}
+			if !strings.HasPrefix(check.Path, "/") || !isConfigSafe(check.Path) {
+				return errors.New("健康检查路径必须以 / 开头且不能包含空白字符和单引号")
+			}
+			if check.ExpectStatus != 0 && (check.ExpectStatus < 100 || check.ExpectStatus > 599) {
+				return fmt.Errorf("健康检查期望状态码 %d 无效", check.ExpectStatus)
+			}
+		default:
+			return fmt.Errorf("不支持的健康检查方式 %s", check.Type)
+		}
+		if check.Interval == 0 {
+			check.Interval = DefaultHealthCheckInterval
+		}
+		if check.Rise == 0 {
+			check.Rise = DefaultHealthCheckRise
+		}
+		if check.Fall == 0 {
+			check.Fall = DefaultHealthCheckFall
+		}
+		if check.Interval < 1 || check.Interval > MaxHealthCheckInterval {
+			return fmt.Errorf("健康检查间隔必须在 1-%d 秒之间", MaxHealthCheckInterval)
+		}
+		if check.Rise < 1 || check.Rise > MaxHealthCheckThreshold || check.Fall < 1 || check.Fall > MaxHealthCheckThreshold {
+			return fmt.Errorf("健康检查 rise/fall 必须在 1-%d 之间", MaxHealthCheckThreshold)
+		}
+	}
+
+	if sticky := backend.StickySession; sticky != nil {
+		if sticky.CookieName == "" {
+			sticky.CookieName = DefaultStickyCookie
+		}
+		if !cookieNamePattern.MatchString(sticky.CookieName) {
+			return fmt.Errorf("会话保持 Cookie 名称 %s 无效", sticky.CookieName)
+		}
+	}
+
+	backups := 0
+	for i, server := range backend.Servers {
+		// IPv6 地址去掉方括号并转为规范形式，与 HAProxy 配置中读回的地址一致
+		if addr, err := parseAddr(server.Host); err == nil {
+			backend.Servers[i].Host = addr.String()
+		}
+		if server.Weight < 0 || server.Weight > MaxServerWeight {
+			return fmt.Errorf("第 %d 个服务器的权重必须在 1-%d 之间", i+1, MaxServerWeight)
+		}
+		if server.MaxConn < 0 {
+			return fmt.Errorf("第 %d 个服务器的最大连接数不能为负数", i+1)
+		}
+		switch server.State {
+		case "", ServerStateReady, ServerStateDrain, ServerStateMaint:
+		default:
+			return fmt.Errorf("第 %d 个服务器的状态 %s 无效", i+1, server.State)
+		}
+		if server.Backup {
+			backups++
+		}
+	}
+	if len(backend.Servers) > 0 && backups == len(backend.Servers) {
+		return errors.New("至少需要一个非备用服务器")
+	}
+	return nil
+}
+
+// validateHosts 校验主域名和别名并统一转为小写，IP 站点只能使用 IP 本身
+// IPv6 站点去掉方括号并转为压缩形式，[2001:DB8:0::1] 与 2001:db8::1 是同一个站点
+func validateHosts(site *Site) error {
+	site.Domain = strings.ToLower(strings.TrimSpace(site.Domain))
+	if addr, err := parseAddr(site.Domain); err == nil {
+		if addr.Zone() != "" {
+			return errors.New("IP 站点不支持带区域的 IPv6 地址")
+		}
+		site.Domain = addr.Unmap().String()
+		if len(site.Aliases) > 0 {
+			return errors.New("IP 站点不支持别名")
+		}
+		return nil
+	}
+	if len(site.Aliases) > MaxSiteAliases {
+		return fmt.Errorf("每个站点最多 %d 个别名", MaxSiteAliases)
+	}
+
+	for i, alias := range site.Aliases {
+		site.Aliases[i] = strings.ToLower(strings.TrimSpace(alias))
+	}
+	hosts := site.Hosts()
+	for i, host := range hosts {
+		if !hostnamePattern.MatchString(strings.TrimPrefix(host, "*.")) {
+			return fmt.Errorf("域名 %s 格式无效", host)
+		}
+		if slices.Contains(hosts[:i], host) {
+			return fmt.Errorf("域名 %s 重复", host)
+		}
+	}
+	return nil
+}
+
+// validateListenAddrs 校验监听地址并转为规范形式后排序去重
+//
+// 同端口的站点共用监听，:: 在 HAProxy 中以 v4v6 方式同时接收 IPv4 和 IPv6 连接；
+// IPv6 站点未设置监听地址时默认监听 ::，否则至少需要一个 IPv6 监听地址。
+func validateListenAddrs(site *Site) error {
+	if len(site.ListenAddrs) > MaxListenAddrs {
+		return fmt.Errorf("每个站点最多 %d 个监听地址", MaxListenAddrs)
+	}
+	for i, address := range site.ListenAddrs {
+		addr, err := parseAddr(strings.TrimSpace(address))
+		if err != nil {
+			return fmt.Errorf("监听地址 %s 不是有效的 IP 地址", address)
+		}
+		if addr.Zone() != "" {
+			return fmt.Errorf("监听地址 %s 不能带区域", address)
+		}
+		site.ListenAddrs[i] = addr.Unmap().String()
+	}
+	slices.Sort(site.ListenAddrs)
+	site.ListenAddrs = slices.Compact(site.ListenAddrs)
+
+	if addr, err := netip.ParseAddr(site.Domain); err == nil && addr.Is6() {
+		if len(site.ListenAddrs) == 0 {
+			site.ListenAddrs = []string{"::"}
+		}
+		if !slices.ContainsFunc(site.ListenAddrs, IsIPv6Addr) {
+			return errors.New("IPv6 站点至少需要一个 IPv6 监听地址")
+		}
+	}
+	return nil
+}
+
+// parseAddr 解析 IP 地址，IPv6 地址可以带方括号
+func parseAddr(s string) (netip.Addr, error) {
+	if inner, ok := strings.CutPrefix(s, "["); ok {
+		if inner, ok = strings.CutSuffix(inner, "]"); ok {
+			s = inner
+		}
+	}
+	return netip.ParseAddr(s)
+}
+
+// IsIPv6Addr 判断规范形式的地址是否为 IPv6 地址
+func IsIPv6Addr(address string) bool {
+	return strings.Contains(address, ":")
+}
+
+// validateProxy 校验 PROXY 协议设置，可信来源转为规范形式后排序去重，同端口的站点据此比较是否一致
+func validateProxy(site *Site) error {
+	proxy := site.Proxy
+	if proxy == nil {
+		return nil
+	}
+	switch proxy.Send {
+	case "", ProxyProtocolV1, ProxyProtocolV2:
+	default:
+		return fmt.Errorf("不支持的 PROXY 协议版本 %s", proxy.Send)
+	}
+	if site.IsTCP() && site.TCP.ProxyProtocol != "" && proxy.Send != "" && site.TCP.ProxyProtocol != proxy.Send {
+		return errors.New("向后端发送的 PROXY 协议版本与 TCP 设置不一致")
+	}
+
+	for i, source := range proxy.Trusted {
+		if prefix, err := netip.ParsePrefix(source); err == nil {
+			proxy.Trusted[i] = prefix.Masked().String()
+			continue
+		}
+		addr, err := netip.ParseAddr(source)
+		if err != nil || addr.Zone() != "" {
+			return fmt.Errorf("可信来源 %s 不是有效的 IP 或网段", source)
+		}
+		proxy.Trusted[i] = addr.Unmap().String()
+	}
+	slices.Sort(proxy.Trusted)
+	proxy.Trusted = slices.Compact(proxy.Trusted)
+
+	if len(proxy.Trusted) == 0 && proxy.Send == "" {
+		site.Proxy = nil
+	}
+	return nil
+}
+
+// validateMode 校验站点类型，TCP 站点只能使用四层转发支持的设置
+func validateMode(site *Site) error {
+	switch site.Mode {
+	case "", SiteModeHTTP:
+		site.TCP = nil
+		return nil
+	case SiteModeTCP:
+	default:
+		return fmt.Errorf("不支持的站点类型 %s", site.Mode)
+	}
+
+	switch {
+	case site.EnableHTTPS || site.TLS != nil:
+		return errors.New("TCP 站点不支持 HTTPS 和 TLS 策略")
+	case len(site.Aliases) > 0:
+		return errors.New("TCP 站点不支持别名")
+	case len(site.Locations) > 0:
+		return errors.New("TCP 站点不支持路径路由")
+	case site.Backend.StickySession != nil:
+		return errors.New("TCP 站点不支持基于 Cookie 的会话保持")
+	}
+	if site.Backend.Balance == BalanceURI {
+		return errors.New("TCP 站点不支持按 URI 负载均衡")
+	}
+	// TCP 站点不经过 Coraza
+	site.WAFEnabled = false
+
+	if site.TCP == nil {
+		site.TCP = &TCPOptions{}
+	}
+	tcp := site.TCP
+	if tcp.MaxConn < 0 {
+		return errors.New("最大连接数不能为负数")
+	}
+	switch tcp.ProxyProtocol {
+	case "", ProxyProtocolV1, ProxyProtocolV2:
+	default:
+		return fmt.Errorf("不支持的 PROXY 协议版本 %s", tcp.ProxyProtocol)
+	}
+	for _, source := range slices.Concat(tcp.Allow, tcp.Deny) {
+		if net.ParseIP(source) == nil {
+			if _, _, err := net.ParseCIDR(source); err != nil {
+				return fmt.Errorf("来源地址 %s 不是有效的 IP 或网段", source)
+			}
+		}
+	}
+	return nil
+}
+
+// validateHTTPVersions 校验 HTTP/2 和 HTTP/3 开关，二者按 SNI 在 crt-list 中协商，只支持启用 HTTPS 的域名站点
+func validateHTTPVersions(site *Site) error {
+	if !site.EnableHTTPS {
+		if site.HTTP2 || site.HTTP3 {
+			return errors.New("HTTP/2 和 HTTP/3 需要启用 HTTPS")
+		}
+		return nil
+	}
+	if site.TLS != nil && len(site.TLS.ALPN) > 0 {
+		site.HTTP2 = slices.Contains(site.TLS.ALPN, "h2")
+	}
+	if (site.HTTP2 || site.HTTP3) && net.ParseIP(site.Domain) != nil {
+		return errors.New("IP 站点不支持 HTTP/2 和 HTTP/3")
+	}
+	return nil
+}
+
+// validateTLS 校验 TLS 策略，按模板填充协议版本和密码套件
+func validateTLS(tls *TLSConfig, cert Certificate) error {
+	switch tls.Profile {
+	case TLSProfileModern:
+		tls.MinVersion, tls.MaxVersion = TLSVersion13, ""
+		tls.Ciphers, tls.Ciphersuites = "", tls13Ciphersuites
+	case TLSProfileIntermediate:
+		tls.MinVersion, tls.MaxVersion = TLSVersion12, ""
+		tls.Ciphers, tls.Ciphersuites = intermediateCiphers, tls13Ciphersuites
+	case TLSProfileCustom:
+		for _, version := range []string{tls.MinVersion, tls.MaxVersion} {
+			if version != "" && !slices.Contains(tlsVersions, version) {
+				return fmt.Errorf("不支持的协议版本 %s", version)
+			}
+		}
+		if tls.MinVersion != "" && tls.MaxVersion != "" &&
+			slices.Index(tlsVersions, tls.MinVersion) > slices.Index(tlsVersions, tls.MaxVersion) {
+			return errors.New("最低协议版本不能高于最高协议版本")
+		}
+		for _, ciphers := range []string{tls.Ciphers, tls.Ciphersuites} {
+			if ciphers != "" && !cipherPattern.MatchString(ciphers) {
+				return fmt.Errorf("密码套件 %s 格式无效", ciphers)
+			}
+		}
+	default:
+		return fmt.Errorf("不支持的配置模板 %s", tls.Profile)
+	}
+
+	if len(tls.ALPN) == 0 && tls.Profile != TLSProfileCustom {
+		tls.ALPN = slices.Clone(alpnProtocols)
+	}
+	for _, proto := range tls.ALPN {
+		if !slices.Contains(alpnProtocols, proto) {
+			return fmt.Errorf("不支持的 ALPN 协议 %s", proto)
+		}
+	}
+	tls.ALPN = slices.Compact(tls.ALPN)
+
+	if hsts := tls.HSTS; hsts != nil {
+		if hsts.MaxAge < 0 {
+			return errors.New("HSTS 有效期不能为负数")
+		}
+		if hsts.Preload && (hsts.MaxAge < HSTSPreloadMinMaxAge || !hsts.IncludeSubDomains) {
+			return fmt.Errorf("HSTS 预加载要求有效期不少于 %d 秒且包含子域名", HSTSPreloadMinMaxAge)
+		}
+	}
+
+	if tls.OCSPStapling && cert.PublicKey != "" {
+		if err := checkOCSPCertificate(cert.PublicKey); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkOCSPCertificate 检查证书能否用于 OCSP 装订：站点证书包含 OCSP 地址，且证书链中包含签发者证书
+func checkOCSPCertificate(chain string) error {
+	var certs []*x509.Certificate
+	rest := []byte(chain)
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return fmt.Errorf("解析证书失败: %v", err)
+		}
+		certs = append(certs, cert)
+	}
+	if len(certs) == 0 {
+		return errors.New("证书内容无效")
+	}
+	if len(certs[0].OCSPServer) == 0 {
+		return errors.New("证书不包含 OCSP 地址，无法启用 OCSP 装订")
+	}
+	if len(certs) < 2 {
+		return errors.New("证书链中缺少签发者证书，无法启用 OCSP 装订")
+	}
+	return nil
+}
+
+// isConfigSafe 检查字符串能否安全地放进单引号写入 HAProxy 配置
+func isConfigSafe(value string) bool {
+	return !strings.ContainsFunc(value, unicode.IsSpace) && !strings.Contains(value, "'")
+}
+
 // WAFModeFromString 从字符串转换为WAFMode
 func WAFModeFromString(s string) WAFMode {
 	mode := WAFMode(s)
@@ -0,0 +1,519 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+)
+
+// testSite 返回一个有效的 HTTP 站点
+func testSite() *Site {
+	site := NewSite()
+	site.Name = "test"
+	site.Domain = "a.com"
+	site.ListenPort = 80
+	site.Backend.Servers = []Server{{Host: "10.0.0.1", Port: 8080}}
+	return site
+}
+
+func testLocation(path string) Location {
+	return Location{Path: path, Backend: Backend{Servers: []Server{{Host: "10.0.0.2", Port: 8080}}}}
+}
+
+func TestValidateSite(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(s *Site)
+		want   error
+	}{
+		{"valid", func(s *Site) {}, nil},
+		{"valid aliases", func(s *Site) { s.Aliases = []string{"*.a.com", " WWW.B.com "} }, nil},
+		{"valid locations", func(s *Site) {
+			api := testLocation("/api/")
+			api.StripPrefix = true
+			static := testLocation(`^/static/(.*)\.css$`)
+			static.MatchType = LocationMatchRegex
+			static.RewritePath = `/css/\1.css`
+			s.Locations = []Location{api, static}
+		}, nil},
+		{"invalid domain", func(s *Site) { s.Domain = "a_b.com" }, ErrInvalidHost},
+		{"wildcard in the middle", func(s *Site) { s.Domain = "a.*.com" }, ErrInvalidHost},
+		{"invalid alias", func(s *Site) { s.Aliases = []string{"www.a.com", "-b.com"} }, ErrInvalidHost},
+		{"duplicate alias", func(s *Site) { s.Aliases = []string{"WWW.a.com", "www.a.com"} }, ErrInvalidHost},
+		{"alias equals domain", func(s *Site) { s.Aliases = []string{"A.com"} }, ErrInvalidHost},
+		{"alias on IP site", func(s *Site) {
+			s.Domain = "10.0.0.10"
+			s.Aliases = []string{"a.com"}
+		}, ErrInvalidHost},
+		{"too many aliases", func(s *Site) {
+			for i := range MaxSiteAliases + 1 {
+				s.Aliases = append(s.Aliases, fmt.Sprintf("www%d.a.com", i))
+			}
+		}, ErrInvalidHost},
+		{"proxy protocol version", func(s *Site) { s.Proxy = &ProxyOptions{Send: "v3"} }, ErrInvalidProxy},
+		{"invalid trusted proxy", func(s *Site) { s.Proxy = &ProxyOptions{Trusted: []string{"10.0.0.0/33"}} }, ErrInvalidProxy},
+		{"conflicting proxy versions", func(s *Site) {
+			s.Mode = SiteModeTCP
+			s.TCP = &TCPOptions{ProxyProtocol: ProxyProtocolV1}
+			s.Proxy = &ProxyOptions{Send: ProxyProtocolV2}
+		}, ErrInvalidProxy},
+		{"valid headers", func(s *Site) {
+			s.Headers = &HeaderConfig{
+				Presets: []HeaderPreset{HeaderPresetHideServer},
+				Rules: []HeaderRule{
+					{Direction: HeaderDirectionRequest, Action: HeaderActionSet, Name: "X_Request-Id", Value: "%[unique-id]"},
+					{Direction: HeaderDirectionResponse, Action: HeaderActionDel, Name: "X-Powered-By"},
+				},
+			}
+		}, nil},
+		{"unknown header preset", func(s *Site) { s.Headers = &HeaderConfig{Presets: []HeaderPreset{"x-xss"}} }, ErrInvalidHeaders},
+		{"header rule name with comment", func(s *Site) {
+			s.Headers = &HeaderConfig{Rules: []HeaderRule{{Direction: HeaderDirectionRequest, Action: HeaderActionDel, Name: "X-A#b"}}}
+		}, ErrInvalidHeaders},
+		{"header rule name with quote", func(s *Site) {
+			s.Headers = &HeaderConfig{Rules: []HeaderRule{{Direction: HeaderDirectionResponse, Action: HeaderActionDel, Name: "X'x"}}}
+		}, ErrInvalidHeaders},
+		{"header rule value with double quote", func(s *Site) {
+			s.Headers = &HeaderConfig{Rules: []HeaderRule{{Direction: HeaderDirectionResponse, Action: HeaderActionSet, Name: "X-Env", Value: `a"b`}}}
+		}, ErrInvalidHeaders},
+		{"header rule set without value", func(s *Site) {
+			s.Headers = &HeaderConfig{Rules: []HeaderRule{{Direction: HeaderDirectionResponse, Action: HeaderActionSet, Name: "X-Env"}}}
+		}, ErrInvalidHeaders},
+		{"header rule del with value", func(s *Site) {
+			s.Headers = &HeaderConfig{Rules: []HeaderRule{{Direction: HeaderDirectionResponse, Action: HeaderActionDel, Name: "X-Env", Value: "a"}}}
+		}, ErrInvalidHeaders},
+		{"unknown header direction", func(s *Site) {
+			s.Headers = &HeaderConfig{Rules: []HeaderRule{{Direction: "both", Action: HeaderActionDel, Name: "X-Env"}}}
+		}, ErrInvalidHeaders},
+		{"unknown balance", func(s *Site) { s.Backend.Balance = "random" }, ErrInvalidBackend},
+		{"unknown health check", func(s *Site) { s.Backend.HealthCheck = &HealthCheck{Type: "icmp"} }, ErrInvalidBackend},
+		{"relative health check path", func(s *Site) {
+			s.Backend.HealthCheck = &HealthCheck{Type: HealthCheckHTTP, Path: "health"}
+		}, ErrInvalidBackend},
+		{"health check status", func(s *Site) {
+			s.Backend.HealthCheck = &HealthCheck{Type: HealthCheckHTTP, ExpectStatus: 600}
+		}, ErrInvalidBackend},
+		{"health check interval", func(s *Site) {
+			s.Backend.HealthCheck = &HealthCheck{Type: HealthCheckTCP, Interval: MaxHealthCheckInterval + 1}
+		}, ErrInvalidBackend},
+		{"health check fall", func(s *Site) {
+			s.Backend.HealthCheck = &HealthCheck{Type: HealthCheckTCP, Fall: -1}
+		}, ErrInvalidBackend},
+		{"sticky cookie name", func(s *Site) { s.Backend.StickySession = &StickySession{CookieName: "a;b"} }, ErrInvalidBackend},
+		{"server weight", func(s *Site) { s.Backend.Servers[0].Weight = MaxServerWeight + 1 }, ErrInvalidBackend},
+		{"negative maxconn", func(s *Site) { s.Backend.Servers[0].MaxConn = -1 }, ErrInvalidBackend},
+		{"server state", func(s *Site) { s.Backend.Servers[0].State = "paused" }, ErrInvalidBackend},
+		{"only backup servers", func(s *Site) { s.Backend.Servers[0].Backup = true }, ErrInvalidBackend},
+		{"invalid location backend", func(s *Site) {
+			loc := testLocation("/api")
+			loc.Backend.Balance = "random"
+			s.Locations = []Location{loc}
+		}, ErrInvalidLocation},
+		{"TLS on IP site", func(s *Site) {
+			s.Domain = "10.0.0.10"
+			s.TLS = &TLSConfig{Profile: TLSProfileModern}
+		}, ErrInvalidTLS},
+		{"unknown TLS profile", func(s *Site) { s.TLS = &TLSConfig{Profile: "old"} }, ErrInvalidTLS},
+		{"unknown TLS version", func(s *Site) {
+			s.TLS = &TLSConfig{Profile: TLSProfileCustom, MinVersion: "SSLv3"}
+		}, ErrInvalidTLS},
+		{"TLS versions reversed", func(s *Site) {
+			s.TLS = &TLSConfig{Profile: TLSProfileCustom, MinVersion: TLSVersion13, MaxVersion: TLSVersion12}
+		}, ErrInvalidTLS},
+		{"ciphers with space", func(s *Site) {
+			s.TLS = &TLSConfig{Profile: TLSProfileCustom, Ciphers: "ECDHE-RSA-AES128-GCM-SHA256 RC4"}
+		}, ErrInvalidTLS},
+		{"unknown ALPN", func(s *Site) { s.TLS = &TLSConfig{Profile: TLSProfileModern, ALPN: []string{"spdy/3"}} }, ErrInvalidTLS},
+		{"negative HSTS max-age", func(s *Site) {
+			s.TLS = &TLSConfig{Profile: TLSProfileModern, HSTS: &HSTSConfig{MaxAge: -1}}
+		}, ErrInvalidTLS},
+		{"HSTS preload without subdomains", func(s *Site) {
+			s.TLS = &TLSConfig{Profile: TLSProfileModern, HSTS: &HSTSConfig{MaxAge: HSTSPreloadMinMaxAge, Preload: true}}
+		}, ErrInvalidTLS},
+		{"HSTS preload short max-age", func(s *Site) {
+			s.TLS = &TLSConfig{Profile: TLSProfileModern, HSTS: &HSTSConfig{MaxAge: 86400, IncludeSubDomains: true, Preload: true}}
+		}, ErrInvalidTLS},
+		{"OCSP with invalid certificate", func(s *Site) {
+			s.Certificate.PublicKey = "not a certificate"
+			s.TLS = &TLSConfig{Profile: TLSProfileModern, OCSPStapling: true}
+		}, ErrInvalidTLS},
+		{"IPv6 site with zone", func(s *Site) { s.Domain = "fe80::1%eth0" }, ErrInvalidHost},
+		{"invalid listen address", func(s *Site) { s.ListenAddrs = []string{"0.0.0.0", "localhost"} }, ErrInvalidListenAddr},
+		{"listen address with zone", func(s *Site) { s.ListenAddrs = []string{"fe80::1%eth0"} }, ErrInvalidListenAddr},
+		{"IPv6 site on IPv4 listener", func(s *Site) {
+			s.Domain = "2001:db8::10"
+			s.ListenAddrs = []string{"0.0.0.0"}
+		}, ErrInvalidListenAddr},
+		{"unknown mode", func(s *Site) { s.Mode = "udp" }, ErrInvalidTCP},
+		{"TCP with HTTPS", func(s *Site) {
+			s.Mode = SiteModeTCP
+			s.EnableHTTPS = true
+		}, ErrInvalidTCP},
+		{"TCP with aliases", func(s *Site) {
+			s.Mode = SiteModeTCP
+			s.Aliases = []string{"www.a.com"}
+		}, ErrInvalidTCP},
+		{"TCP with locations", func(s *Site) {
+			s.Mode = SiteModeTCP
+			s.Locations = []Location{testLocation("/api")}
+		}, ErrInvalidTCP},
+		{"TCP with sticky session", func(s *Site) {
+			s.Mode = SiteModeTCP
+			s.Backend.StickySession = &StickySession{}
+		}, ErrInvalidTCP},
+		{"TCP with URI balance", func(s *Site) {
+			s.Mode = SiteModeTCP
+			s.Backend.Balance = BalanceURI
+		}, ErrInvalidTCP},
+		{"TCP negative maxconn", func(s *Site) {
+			s.Mode = SiteModeTCP
+			s.TCP = &TCPOptions{MaxConn: -1}
+		}, ErrInvalidTCP},
+		{"TCP proxy protocol version", func(s *Site) {
+			s.Mode = SiteModeTCP
+			s.TCP = &TCPOptions{ProxyProtocol: "v3"}
+		}, ErrInvalidTCP},
+		{"TCP invalid source", func(s *Site) {
+			s.Mode = SiteModeTCP
+			s.TCP = &TCPOptions{Allow: []string{"10.0.0.0/8"}, Deny: []string{"10.0.0.300"}}
+		}, ErrInvalidTCP},
+		{"valid redirects", func(s *Site) {
+			s.EnableHTTPS = true
+			s.Aliases = []string{"www.a.com"}
+			s.Redirects = []RedirectRule{
+				{Type: RedirectHTTPS},
+				{Type: RedirectPrefix, Hosts: []string{"WWW.a.com"}, Target: "https://a.com/"},
+				{Type: RedirectLocation, Path: `^/blog/(\d+)$`, Target: `/posts/\1`, Code: 302},
+			}
+			s.Rewrites = []RewriteRule{{Path: `^/s\?q=(.*)$`, Target: `/search?term=\1`, Query: true}}
+		}, nil},
+		{"redirect on TCP site", func(s *Site) {
+			s.Mode = SiteModeTCP
+			s.Redirects = []RedirectRule{{Type: RedirectPrefix, Target: "https://a.com"}}
+		}, ErrInvalidRedirect},
+		{"redirect on IP site", func(s *Site) {
+			s.Domain = "10.0.0.10"
+			s.Rewrites = []RewriteRule{{Path: "^/a$", Target: "/b"}}
+		}, ErrInvalidRedirect},
+		{"redirect code", func(s *Site) {
+			s.Redirects = []RedirectRule{{Type: RedirectPrefix, Target: "https://a.com", Code: 303}}
+		}, ErrInvalidRedirect},
+		{"redirect foreign host", func(s *Site) {
+			s.Redirects = []RedirectRule{{Type: RedirectPrefix, Hosts: []string{"b.com"}, Target: "https://a.com"}}
+		}, ErrInvalidRedirect},
+		{"https redirect without HTTPS", func(s *Site) { s.Redirects = []RedirectRule{{Type: RedirectHTTPS}} }, ErrInvalidRedirect},
+		{"https redirect with target", func(s *Site) {
+			s.EnableHTTPS = true
+			s.Redirects = []RedirectRule{{Type: RedirectHTTPS, Target: "https://a.com"}}
+		}, ErrInvalidRedirect},
+		{"prefix redirect to root", func(s *Site) { s.Redirects = []RedirectRule{{Type: RedirectPrefix, Target: "/"}} }, ErrInvalidRedirect},
+		{"prefix redirect scheme", func(s *Site) {
+			s.Redirects = []RedirectRule{{Type: RedirectPrefix, Target: "ftp://a.com"}}
+		}, ErrInvalidRedirect},
+		{"prefix redirect with user", func(s *Site) {
+			s.Redirects = []RedirectRule{{Type: RedirectPrefix, Target: "https://user@a.com"}}
+		}, ErrInvalidRedirect},
+		{"prefix redirect with quote", func(s *Site) {
+			s.Redirects = []RedirectRule{{Type: RedirectPrefix, Target: "https://a.com/x'y"}}
+		}, ErrInvalidRedirect},
+		{"location redirect without path", func(s *Site) {
+			s.Redirects = []RedirectRule{{Type: RedirectLocation, Target: "/new"}}
+		}, ErrInvalidRedirect},
+		{"location redirect without target path", func(s *Site) {
+			s.Redirects = []RedirectRule{{Type: RedirectLocation, Path: "^/old$", Target: "https://b.com"}}
+		}, ErrInvalidRedirect},
+		{"location redirect with query", func(s *Site) {
+			s.Redirects = []RedirectRule{{Type: RedirectLocation, Path: "^/old$", Target: "/new?a=1"}}
+		}, ErrInvalidRedirect},
+		{"location redirect missing group", func(s *Site) {
+			s.Redirects = []RedirectRule{{Type: RedirectLocation, Path: "^/old/(.*)$", Target: `/new/\2`}}
+		}, ErrInvalidRedirect},
+		{"unknown redirect type", func(s *Site) { s.Redirects = []RedirectRule{{Type: "refresh", Target: "/a"}} }, ErrInvalidRedirect},
+		{"invalid rewrite regex", func(s *Site) { s.Rewrites = []RewriteRule{{Path: "^/(a", Target: "/b"}} }, ErrInvalidRedirect},
+		{"rewrite with fragment", func(s *Site) { s.Rewrites = []RewriteRule{{Path: "^/a$", Target: "/b#c"}} }, ErrInvalidRedirect},
+		{"rewrite query without flag", func(s *Site) { s.Rewrites = []RewriteRule{{Path: "^/a$", Target: "/b?c=1"}} }, ErrInvalidRedirect},
+		{"rewrite invalid escape", func(s *Site) { s.Rewrites = []RewriteRule{{Path: "^/(a)$", Target: `/b\n`}} }, ErrInvalidRedirect},
+		{"too many rewrites", func(s *Site) {
+			for range MaxSiteRewriteRules + 1 {
+				s.Rewrites = append(s.Rewrites, RewriteRule{Path: "^/a$", Target: "/b"})
+			}
+		}, ErrInvalidRedirect},
+		{"location on IP site", func(s *Site) {
+			s.Domain = "10.0.0.10"
+			s.Locations = []Location{testLocation("/api")}
+		}, ErrInvalidLocation},
+		{"too many locations", func(s *Site) {
+			for range MaxSiteLocations + 1 {
+				s.Locations = append(s.Locations, testLocation("/api"))
+			}
+		}, ErrInvalidLocation},
+		{"empty location path", func(s *Site) { s.Locations = []Location{testLocation("")} }, ErrInvalidLocation},
+		{"location path with space", func(s *Site) { s.Locations = []Location{testLocation("/a b")} }, ErrInvalidLocation},
+		{"location path with quote", func(s *Site) { s.Locations = []Location{testLocation("/a'b")} }, ErrInvalidLocation},
+		{"relative prefix", func(s *Site) { s.Locations = []Location{testLocation("api")} }, ErrInvalidLocation},
+		{"invalid regex", func(s *Site) {
+			loc := testLocation("^/(api")
+			loc.MatchType = LocationMatchRegex
+			s.Locations = []Location{loc}
+		}, ErrInvalidLocation},
+		{"unknown match type", func(s *Site) {
+			loc := testLocation("/api")
+			loc.MatchType = "glob"
+			s.Locations = []Location{loc}
+		}, ErrInvalidLocation},
+		{"strip prefix on exact match", func(s *Site) {
+			loc := testLocation("/api")
+			loc.MatchType = LocationMatchExact
+			loc.StripPrefix = true
+			s.Locations = []Location{loc}
+		}, ErrInvalidLocation},
+		{"strip prefix with rewrite", func(s *Site) {
+			loc := testLocation("/api")
+			loc.StripPrefix = true
+			loc.RewritePath = "/v1"
+			s.Locations = []Location{loc}
+		}, ErrInvalidLocation},
+		{"relative rewrite", func(s *Site) {
+			loc := testLocation("/api")
+			loc.RewritePath = "v1"
+			s.Locations = []Location{loc}
+		}, ErrInvalidLocation},
+		{"invalid method", func(s *Site) {
+			loc := testLocation("/api")
+			loc.Methods = []string{"GET", "PO ST"}
+			s.Locations = []Location{loc}
+		}, ErrInvalidLocation},
+		{"header value with quote", func(s *Site) {
+			loc := testLocation("/api")
+			loc.Headers = []HeaderMatch{{Name: "X-Env", Value: "a'b"}}
+			s.Locations = []Location{loc}
+		}, ErrInvalidLocation},
+		{"header name with space", func(s *Site) {
+			loc := testLocation("/api")
+			loc.Headers = []HeaderMatch{{Name: "X Env"}}
+			s.Locations = []Location{loc}
+		}, ErrInvalidLocation},
+		{"header name with comment", func(s *Site) {
+			loc := testLocation("/api")
+			loc.Headers = []HeaderMatch{{Name: "X-A#b"}}
+			s.Locations = []Location{loc}
+		}, ErrInvalidLocation},
+		{"header name with quote", func(s *Site) {
+			loc := testLocation("/api")
+			loc.Headers = []HeaderMatch{{Name: "X'x"}}
+			s.Locations = []Location{loc}
+		}, ErrInvalidLocation},
+		{"location without servers", func(s *Site) {
+			loc := testLocation("/api")
+			loc.Backend.Servers = nil
+			s.Locations = []Location{loc}
+		}, ErrInvalidLocation},
+	}
+	for _, tt := range tests {
+		site := testSite()
+		tt.modify(site)
+		if err := ValidateSite(site); !errors.Is(err, tt.want) {
+			t.Errorf("%s: ValidateSite = %v, want %v", tt.name, err, tt.want)
+		}
+	}
+}
+
+func TestValidateSiteNormalizesLocations(t *testing.T) {
+	site := testSite()
+	loc := testLocation("/api")
+	loc.Methods = []string{"post", "GET", "Post"}
+	site.Locations = []Location{loc}
+	if err := ValidateSite(site); err != nil {
+		t.Fatal(err)
+	}
+	got := site.Locations[0]
+	if got.MatchType != LocationMatchPrefix {
+		t.Errorf("matchType = %q, want prefix", got.MatchType)
+	}
+	if !slices.Equal(got.Methods, []string{"GET", "POST"}) {
+		t.Errorf("methods = %v, want [GET POST]", got.Methods)
+	}
+}
+
+func TestValidateSiteBackendDefaults(t *testing.T) {
+	site := testSite()
+	site.Backend.HealthCheck = &HealthCheck{Type: HealthCheckHTTP}
+	site.Backend.StickySession = &StickySession{}
+	if err := ValidateSite(site); err != nil {
+		t.Fatal(err)
+	}
+	check := site.Backend.HealthCheck
+	if check.Path != "/" || check.Interval != DefaultHealthCheckInterval ||
+		check.Rise != DefaultHealthCheckRise || check.Fall != DefaultHealthCheckFall {
+		t.Errorf("health check = %+v, want defaults", check)
+	}
+	if site.Backend.StickySession.CookieName != DefaultStickyCookie {
+		t.Errorf("cookie = %q, want %q", site.Backend.StickySession.CookieName, DefaultStickyCookie)
+	}
+
+	// TCP 检查清除 HTTP 检查的参数
+	site.Backend.HealthCheck = &HealthCheck{Type: HealthCheckTCP, Path: "/health", ExpectStatus: 200}
+	if err := ValidateSite(site); err != nil {
+		t.Fatal(err)
+	}
+	if site.Backend.HealthCheck.Path != "" || site.Backend.HealthCheck.ExpectStatus != 0 {
+		t.Errorf("tcp check = %+v, want http fields cleared", site.Backend.HealthCheck)
+	}
+}
+
+func TestValidateSiteTLSProfiles(t *testing.T) {
+	site := testSite()
+	site.TLS = &TLSConfig{Profile: TLSProfileModern, MinVersion: TLSVersion10, Ciphers: "RC4"}
+	if err := ValidateSite(site); err != nil {
+		t.Fatal(err)
+	}
+	// 模板覆盖自定义的协议版本和密码套件，ALPN 默认 h2,http/1.1
+	tls := site.TLS
+	if tls.MinVersion != TLSVersion13 || tls.Ciphers != "" || tls.Ciphersuites != tls13Ciphersuites {
+		t.Errorf("modern = %+v", tls)
+	}
+	if !slices.Equal(tls.ALPN, []string{"h2", "http/1.1"}) {
+		t.Errorf("alpn = %v, want [h2 http/1.1]", tls.ALPN)
+	}
+
+	site.TLS = &TLSConfig{Profile: TLSProfileCustom, MinVersion: TLSVersion12, ALPN: []string{"http/1.1", "http/1.1"}}
+	if err := ValidateSite(site); err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(site.TLS.ALPN, []string{"http/1.1"}) {
+		t.Errorf("custom alpn = %v, want [http/1.1]", site.TLS.ALPN)
+	}
+}
+
+func TestValidateSiteNormalizesHosts(t *testing.T) {
+	site := testSite()
+	site.Domain = " A.com "
+	site.Aliases = []string{"*.A.com", "www.B.com"}
+	if err := ValidateSite(site); err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(site.Hosts(), []string{"a.com", "*.a.com", "www.b.com"}) {
+		t.Errorf("hosts = %v", site.Hosts())
+	}
+}
+
+func TestHostsOverlap(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want bool
+	}{
+		{"a.com", "a.com", true},
+		{"a.com", "A.COM", true},
+		{"a.com", "b.com", false},
+		{"a.com", "www.a.com", false},
+		// 通配符不匹配域名本身
+		{"*.a.com", "a.com", false},
+		{"*.a.com", "www.a.com", true},
+		{"*.a.com", "x.y.a.com", true},
+		{"www.a.com", "*.a.com", true},
+		{"*.a.com", "wwwa.com", false},
+		{"*.a.com", "*.a.com", true},
+		{"*.a.com", "*.b.a.com", true},
+		{"*.b.a.com", "*.a.com", true},
+		{"*.a.com", "*.b.com", false},
+	}
+	for _, tt := range tests {
+		if got := HostsOverlap(tt.a, tt.b); got != tt.want {
+			t.Errorf("HostsOverlap(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestValidateSiteTCPDefaults(t *testing.T) {
+	site := testSite()
+	site.Mode = SiteModeTCP
+	site.WAFEnabled = true
+	if err := ValidateSite(site); err != nil {
+		t.Fatal(err)
+	}
+	// TCP 站点不经过 Coraza
+	if site.WAFEnabled || site.TCP == nil {
+		t.Errorf("tcp site = waf %v options %v", site.WAFEnabled, site.TCP)
+	}
+
+	site = testSite()
+	site.TCP = &TCPOptions{Inspect: true}
+	if err := ValidateSite(site); err != nil {
+		t.Fatal(err)
+	}
+	if site.TCP != nil {
+		t.Errorf("http site kept TCP options %+v", site.TCP)
+	}
+}
+
+func TestValidateSiteNormalizesAddrs(t *testing.T) {
+	site := testSite()
+	site.Domain = "[2001:DB8:0::10]"
+	if err := ValidateSite(site); err != nil {
+		t.Fatal(err)
+	}
+	// IPv6 站点默认以 v4v6 方式监听 ::
+	if site.Domain != "2001:db8::10" || !slices.Equal(site.ListenAddrs, []string{"::"}) {
+		t.Errorf("site = %s listen %v", site.Domain, site.ListenAddrs)
+	}
+
+	site = testSite()
+	site.ListenAddrs = []string{"[::1]", "127.0.0.1", "::ffff:127.0.0.1", "0:0::1"}
+	if err := ValidateSite(site); err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(site.ListenAddrs, []string{"127.0.0.1", "::1"}) {
+		t.Errorf("listen = %v, want [127.0.0.1 ::1]", site.ListenAddrs)
+	}
+}
+
+func TestValidateSiteNormalizesHeaders(t *testing.T) {
+	site := testSite()
+	site.Headers = &HeaderConfig{Presets: []HeaderPreset{HeaderPresetHideServer, HeaderPresetCSP, HeaderPresetHideServer}}
+	if err := ValidateSite(site); err != nil {
+		t.Fatal(err)
+	}
+	if want := []HeaderPreset{HeaderPresetCSP, HeaderPresetHideServer}; !slices.Equal(site.Headers.Presets, want) {
+		t.Errorf("presets = %v, want %v", site.Headers.Presets, want)
+	}
+
+	// 没有任何规则时清空设置
+	site.Headers = &HeaderConfig{}
+	if err := ValidateSite(site); err != nil {
+		t.Fatal(err)
+	}
+	if site.Headers != nil {
+		t.Errorf("headers = %+v, want nil", site.Headers)
+	}
+}
+
+func TestValidateSiteNormalizesRedirects(t *testing.T) {
+	site := testSite()
+	site.Aliases = []string{"www.a.com"}
+	site.Redirects = []RedirectRule{{Type: RedirectPrefix, Hosts: []string{"WWW.A.com", "www.a.com", "a.com"}, Target: "https://a.com/"}}
+	if err := ValidateSite(site); err != nil {
+		t.Fatal(err)
+	}
+	rule := site.Redirects[0]
+	if rule.Code != 301 || rule.Target != "https://a.com" || !slices.Equal(rule.Hosts, []string{"a.com", "www.a.com"}) {
+		t.Errorf("redirect = %+v", rule)
+	}
+}
+
+func TestRedirectSplitTarget(t *testing.T) {
+	tests := []struct {
+		target, origin, path string
+	}{
+		{"/new", "", "/new"},
+		{"https://a.com", "https://a.com", ""},
+		{"https://a.com/new/\\1", "https://a.com", "/new/\\1"},
+		{"https://a.com:8443/", "https://a.com:8443", "/"},
+	}
+	for _, tt := range tests {
+		origin, path := RedirectRule{Target: tt.target}.SplitTarget()
+		if origin != tt.origin || path != tt.path {
+			t.Errorf("SplitTarget(%q) = %q, %q, want %q, %q", tt.target, origin, path, tt.origin, tt.path)
+		}
+	}
+}
@@ -1,18 +1,108 @@
 package model
 
 import (
-    "time"
+	"time"
 
-    "go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// Suricata EVE 事件类型
+const (
+	SuricataEventAlert = "alert"
+	SuricataEventHTTP  = "http"
+	SuricataEventTLS   = "tls"
+	SuricataEventDNS   = "dns"
+	SuricataEventFlow  = "flow"
 )
 
 // SuricataEvent 表示一条 Suricata 日志事件
+//
+// 顶层字段为所有事件类型共有的五元组信息；告警事件额外展开 severity、signature_id 和 msg，
+// 便于按严重级别查询和 SIEM 转发。各事件类型的详细字段保存在对应的子文档中。
 type SuricataEvent struct {
-    ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
-    Timestamp time.Time          `bson:"timestamp" json:"timestamp"`
-    Severity  string             `bson:"severity" json:"severity"`
-    SrcIP     string             `bson:"src_ip,omitempty" json:"src_ip"`
-    DstIP     string             `bson:"dst_ip,omitempty" json:"dst_ip"`
-    Msg       string             `bson:"msg" json:"msg"`
-    // 如果需要更多字段，可自行扩展
+	ID          bson.ObjectID `bson:"_id,omitempty" json:"id"`
+	Timestamp   time.Time     `bson:"timestamp" json:"timestamp"`
+	EventType   string        `bson:"event_type,omitempty" json:"event_type,omitempty"`
+	FlowID      int64         `bson:"flow_id,omitempty" json:"flow_id,omitempty"`
+	InIface     string        `bson:"in_iface,omitempty" json:"in_iface,omitempty"`
+	AppProto    string        `bson:"app_proto,omitempty" json:"app_proto,omitempty"`
+	Severity    string        `bson:"severity" json:"severity"`
+	SignatureID int           `bson:"signature_id,omitempty" json:"signature_id,omitempty"`
+	SrcIP       string        `bson:"src_ip,omitempty" json:"src_ip"`
+	SrcPort     int           `bson:"src_port,omitempty" json:"src_port,omitempty"`
+	DstIP       string        `bson:"dst_ip,omitempty" json:"dst_ip"`
+	DstPort     int           `bson:"dst_port,omitempty" json:"dst_port,omitempty"`
+	Proto       string        `bson:"proto,omitempty" json:"proto,omitempty"`
+	Msg         string        `bson:"msg" json:"msg"`
+
+	Alert *SuricataAlert `bson:"alert,omitempty" json:"alert,omitempty"`
+	HTTP  *SuricataHTTP  `bson:"http,omitempty" json:"http,omitempty"`
+	TLS   *SuricataTLS   `bson:"tls,omitempty" json:"tls,omitempty"`
+	DNS   *SuricataDNS   `bson:"dns,omitempty" json:"dns,omitempty"`
+	Flow  *SuricataFlow  `bson:"flow,omitempty" json:"flow,omitempty"`
+}
+
+// SuricataAlert 告警事件详情
+type SuricataAlert struct {
+	Action      string `bson:"action" json:"action"`                         // allowed / blocked
+	GID         int    `bson:"gid" json:"gid"`                               // 规则组ID
+	SignatureID int    `bson:"signature_id" json:"signature_id"`             // 规则ID
+	Rev         int    `bson:"rev" json:"rev"`                               // 规则版本
+	Signature   string `bson:"signature" json:"signature"`                   // 规则描述
+	Category    string `bson:"category,omitempty" json:"category,omitempty"` // 规则分类
+	Severity    int    `bson:"severity" json:"severity"`                     // 优先级，1最高
+}
+
+// SuricataHTTP HTTP 事件详情
+type SuricataHTTP struct {
+	Hostname        string `bson:"hostname,omitempty" json:"hostname,omitempty"`
+	URL             string `bson:"url,omitempty" json:"url,omitempty"`
+	HTTPUserAgent   string `bson:"http_user_agent,omitempty" json:"http_user_agent,omitempty"`
+	HTTPContentType string `bson:"http_content_type,omitempty" json:"http_content_type,omitempty"`
+	HTTPMethod      string `bson:"http_method,omitempty" json:"http_method,omitempty"`
+	Protocol        string `bson:"protocol,omitempty" json:"protocol,omitempty"`
+	Status          int    `bson:"status,omitempty" json:"status,omitempty"`
+	Length          int64  `bson:"length,omitempty" json:"length,omitempty"`
+}
+
+// SuricataTLS TLS 事件详情
+type SuricataTLS struct {
+	Subject     string `bson:"subject,omitempty" json:"subject,omitempty"`
+	IssuerDN    string `bson:"issuerdn,omitempty" json:"issuerdn,omitempty"`
+	Serial      string `bson:"serial,omitempty" json:"serial,omitempty"`
+	Fingerprint string `bson:"fingerprint,omitempty" json:"fingerprint,omitempty"`
+	SNI         string `bson:"sni,omitempty" json:"sni,omitempty"`
+	Version     string `bson:"version,omitempty" json:"version,omitempty"`
+	NotBefore   string `bson:"notbefore,omitempty" json:"notbefore,omitempty"`
+	NotAfter    string `bson:"notafter,omitempty" json:"notafter,omitempty"`
+	JA3Hash     string `bson:"ja3_hash,omitempty" json:"ja3_hash,omitempty"`
+}
+
+// SuricataDNS DNS 事件详情
+type SuricataDNS struct {
+	Type    string   `bson:"type,omitempty" json:"type,omitempty"` // query / answer
+	ID      int      `bson:"id,omitempty" json:"id,omitempty"`
+	RRName  string   `bson:"rrname,omitempty" json:"rrname,omitempty"`
+	RRType  string   `bson:"rrtype,omitempty" json:"rrtype,omitempty"`
+	RCode   string   `bson:"rcode,omitempty" json:"rcode,omitempty"`
+	Answers []string `bson:"answers,omitempty" json:"answers,omitempty"`
+}
+
+// SuricataFlow 流事件详情
+type SuricataFlow struct {
+	PktsToServer  int64     `bson:"pkts_toserver" json:"pkts_toserver"`
+	PktsToClient  int64     `bson:"pkts_toclient" json:"pkts_toclient"`
+	BytesToServer int64     `bson:"bytes_toserver" json:"bytes_toserver"`
+	BytesToClient int64     `bson:"bytes_toclient" json:"bytes_toclient"`
+	Start         time.Time `bson:"start" json:"start"`
+	End           time.Time `bson:"end" json:"end"`
+	Age           int64     `bson:"age" json:"age"`
+	State         string    `bson:"state,omitempty" json:"state,omitempty"`
+	Reason        string    `bson:"reason,omitempty" json:"reason,omitempty"`
+	Alerted       bool      `bson:"alerted" json:"alerted"`
+}
+
+// GetCollectionName 返回集合名称
+func (e *SuricataEvent) GetCollectionName() string {
+	return "suricata_events"
 }
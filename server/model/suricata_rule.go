@@ -0,0 +1,35 @@
+package model
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// SuricataLocalRule 用户自定义的 Suricata 规则，渲染到 local.rules
+type SuricataLocalRule struct {
+	ID          bson.ObjectID `bson:"_id,omitempty" json:"id,omitempty"` // 规则记录ID
+	SID         int           `bson:"sid" json:"sid"`                    // 规则 SID，与导入的规则集不能重复
+	Rule        string        `bson:"rule" json:"rule"`                  // 规则原文
+	Description string        `bson:"description" json:"description"`    // 规则说明
+	Enabled     bool          `bson:"enabled" json:"enabled"`            // 是否启用
+	CreatedAt   time.Time     `bson:"createdAt" json:"createdAt"`
+	UpdatedAt   time.Time     `bson:"updatedAt" json:"updatedAt"`
+}
+
+// SuricataRuleOverride 导入规则集中单条规则的启用状态覆盖，重新导入规则集后仍然生效
+type SuricataRuleOverride struct {
+	SID       int       `bson:"_id" json:"sid"`             // 规则 SID
+	Enabled   bool      `bson:"enabled" json:"enabled"`     // 是否启用
+	UpdatedAt time.Time `bson:"updatedAt" json:"updatedAt"` // 更新时间
+}
+
+// GetCollectionName 返回集合名称
+func (r *SuricataLocalRule) GetCollectionName() string {
+	return "suricata_local_rules"
+}
+
+// GetCollectionName 返回集合名称
+func (o *SuricataRuleOverride) GetCollectionName() string {
+	return "suricata_rule_overrides"
+}
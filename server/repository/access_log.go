@@ -0,0 +1,226 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/HUAHUAI23/simple-waf/server/config"
+	"github.com/HUAHUAI23/simple-waf/server/dto"
+	"github.com/HUAHUAI23/simple-waf/server/model"
+	"github.com/rs/zerolog"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// latencyBucketScale 耗时直方图的对数刻度，每格相差约5%，1分钟以内的耗时不超过230格
+const latencyBucketScale = 20
+
+// AccessLogRepository 访问日志仓库接口
+type AccessLogRepository interface {
+	InsertAccessLogs(ctx context.Context, logs []model.AccessLog) error
+	AggregateSiteSummary(ctx context.Context, filter bson.D) ([]dto.AccessLogSiteSummary, error)
+	AggregateLatencyBuckets(ctx context.Context, filter bson.D) ([]dto.AccessLogLatencyBucket, error)
+	AggregateTopPaths(ctx context.Context, filter bson.D, limit int) ([]dto.AccessLogPathCount, error)
+	FindAccessLogs(ctx context.Context, filter bson.D, limit int64) ([]model.AccessLog, error)
+	FindClientErrorsAfterID(ctx context.Context, afterID bson.ObjectID, limit int64) ([]model.AccessLog, error)
+}
+
+// MongoAccessLogRepository MongoDB实现的访问日志仓库
+type MongoAccessLogRepository struct {
+	collection *mongo.Collection
+	logger     zerolog.Logger
+}
+
+// NewAccessLogRepository 创建访问日志仓库
+func NewAccessLogRepository(db *mongo.Database) AccessLogRepository {
+	var accessLog model.AccessLog
+	collection := db.Collection(accessLog.GetCollectionName())
+	logger := config.GetRepositoryLogger("access_log")
+
+	// 创建统计查询使用的索引，timestamp 的 TTL 索引由保留策略管理器维护
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "domain", Value: 1}, {Key: "timestamp", Value: -1}}},
+		{Keys: bson.D{{Key: "wafRequestId", Value: 1}}},
+		{Keys: bson.D{{Key: "clientIp", Value: 1}, {Key: "timestamp", Value: -1}}},
+	})
+	if err != nil {
+		logger.Error().Err(err).Msg("创建访问日志索引失败")
+	}
+
+	return &MongoAccessLogRepository{
+		collection: collection,
+		logger:     logger,
+	}
+}
+
+// InsertAccessLogs 批量写入访问日志，单条失败不影响其余日志
+func (r *MongoAccessLogRepository) InsertAccessLogs(ctx context.Context, logs []model.AccessLog) error {
+	if len(logs) == 0 {
+		return nil
+	}
+	docs := make([]any, len(logs))
+	for i := range logs {
+		docs[i] = logs[i]
+	}
+	_, err := r.collection.InsertMany(ctx, docs, options.InsertMany().SetOrdered(false))
+	return err
+}
+
+// FindAccessLogs 按请求时间倒序查询访问日志
+func (r *MongoAccessLogRepository) FindAccessLogs(ctx context.Context, filter bson.D, limit int64) ([]model.AccessLog, error) {
+	findOpts := options.Find().
+		SetSort(bson.D{{Key: "timestamp", Value: -1}, {Key: "_id", Value: -1}}).
+		SetLimit(limit)
+	return r.find(ctx, filter, findOpts)
+}
+
+// FindClientErrorsAfterID 按 _id 升序查询 afterID 之后写入的 4xx 访问日志，供安全事件关联增量扫描
+func (r *MongoAccessLogRepository) FindClientErrorsAfterID(ctx context.Context, afterID bson.ObjectID, limit int64) ([]model.AccessLog, error) {
+	filter := bson.D{{Key: "status", Value: bson.D{{Key: "$gte", Value: 400}, {Key: "$lt", Value: 500}}}}
+	if !afterID.IsZero() {
+		filter = append(filter, bson.E{Key: "_id", Value: bson.D{{Key: "$gt", Value: afterID}}})
+	}
+	findOpts := options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}).SetLimit(limit)
+	return r.find(ctx, filter, findOpts)
+}
+
+func (r *MongoAccessLogRepository) find(ctx context.Context, filter bson.D, findOpts *options.FindOptionsBuilder) ([]model.AccessLog, error) {
+	cursor, err := r.collection.Find(ctx, filter, findOpts)
+	if err != nil {
+		r.logger.Error().Err(err).Msg("查询访问日志时出错")
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var results []model.AccessLog
+	if err = cursor.All(ctx, &results); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// AggregateSiteSummary 按站点统计请求数、状态码分布、字节数和平均耗时
+func (r *MongoAccessLogRepository) AggregateSiteSummary(ctx context.Context, filter bson.D) ([]dto.AccessLogSiteSummary, error) {
+	statusClass := func(min int) bson.D {
+		return bson.D{{Key: "$sum", Value: bson.D{{Key: "$cond", Value: bson.A{
+			bson.D{{Key: "$and", Value: bson.A{
+				bson.D{{Key: "$gte", Value: bson.A{"$status", min}}},
+				bson.D{{Key: "$lt", Value: bson.A{"$status", min + 100}}},
+			}}},
+			1, 0,
+		}}}}}
+	}
+
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: filter}},
+		{{Key: "$group", Value: bson.D{
+			{Key: "_id", Value: "$domain"},
+			{Key: "total", Value: bson.D{{Key: "$sum", Value: 1}}},
+			{Key: "status1xx", Value: statusClass(100)},
+			{Key: "status2xx", Value: statusClass(200)},
+			{Key: "status3xx", Value: statusClass(300)},
+			{Key: "status4xx", Value: statusClass(400)},
+			{Key: "status5xx", Value: statusClass(500)},
+			{Key: "bytes", Value: bson.D{{Key: "$sum", Value: "$bytes"}}},
+			// $avg 忽略 null，未完成的请求（-1）不计入平均耗时
+			{Key: "avgRequestTime", Value: bson.D{{Key: "$avg", Value: bson.D{{Key: "$cond", Value: bson.A{
+				bson.D{{Key: "$gte", Value: bson.A{"$requestTime", 0}}}, "$requestTime", nil,
+			}}}}}},
+		}}},
+		{{Key: "$sort", Value: bson.D{{Key: "total", Value: -1}}}},
+	}
+
+	var results []dto.AccessLogSiteSummary
+	if err := r.aggregate(ctx, pipeline, &results); err != nil {
+		return nil, fmt.Errorf("统计站点访问量失败: %w", err)
+	}
+	return results, nil
+}
+
+// AggregateLatencyBuckets 按站点统计请求总耗时的对数直方图，用于计算近似分位数
+//
+// 不同 MongoDB 版本都支持，无需 $percentile。每格的代表耗时为该格对数中点对应的毫秒数。
+func (r *MongoAccessLogRepository) AggregateLatencyBuckets(ctx context.Context, filter bson.D) ([]dto.AccessLogLatencyBucket, error) {
+	match := append(bson.D{}, filter...)
+	match = append(match, bson.E{Key: "requestTime", Value: bson.D{{Key: "$gte", Value: 0}}})
+
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: match}},
+		{{Key: "$group", Value: bson.D{
+			{Key: "_id", Value: bson.D{
+				{Key: "domain", Value: "$domain"},
+				{Key: "bucket", Value: bson.D{{Key: "$round", Value: bson.A{
+					bson.D{{Key: "$multiply", Value: bson.A{
+						bson.D{{Key: "$ln", Value: bson.D{{Key: "$add", Value: bson.A{"$requestTime", 1}}}}},
+						latencyBucketScale,
+					}}},
+					0,
+				}}}},
+			}},
+			{Key: "count", Value: bson.D{{Key: "$sum", Value: 1}}},
+		}}},
+		{{Key: "$project", Value: bson.D{
+			{Key: "_id", Value: 0},
+			{Key: "domain", Value: "$_id.domain"},
+			{Key: "value", Value: bson.D{{Key: "$toLong", Value: bson.D{{Key: "$round", Value: bson.A{
+				bson.D{{Key: "$subtract", Value: bson.A{
+					bson.D{{Key: "$exp", Value: bson.D{{Key: "$divide", Value: bson.A{"$_id.bucket", latencyBucketScale}}}}},
+					1,
+				}}},
+				0,
+			}}}}}},
+			{Key: "count", Value: 1},
+		}}},
+		{{Key: "$sort", Value: bson.D{{Key: "domain", Value: 1}, {Key: "value", Value: 1}}}},
+	}
+
+	var results []dto.AccessLogLatencyBucket
+	if err := r.aggregate(ctx, pipeline, &results); err != nil {
+		return nil, fmt.Errorf("统计访问耗时失败: %w", err)
+	}
+	return results, nil
+}
+
+// AggregateTopPaths 按站点统计请求数最多的 limit 个路径
+func (r *MongoAccessLogRepository) AggregateTopPaths(ctx context.Context, filter bson.D, limit int) ([]dto.AccessLogPathCount, error) {
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: filter}},
+		{{Key: "$group", Value: bson.D{
+			{Key: "_id", Value: bson.D{{Key: "domain", Value: "$domain"}, {Key: "path", Value: "$path"}}},
+			{Key: "count", Value: bson.D{{Key: "$sum", Value: 1}}},
+		}}},
+		{{Key: "$sort", Value: bson.D{{Key: "count", Value: -1}}}},
+		{{Key: "$group", Value: bson.D{
+			{Key: "_id", Value: "$_id.domain"},
+			{Key: "paths", Value: bson.D{{Key: "$push", Value: bson.D{{Key: "path", Value: "$_id.path"}, {Key: "count", Value: "$count"}}}}},
+		}}},
+		{{Key: "$project", Value: bson.D{{Key: "paths", Value: bson.D{{Key: "$slice", Value: bson.A{"$paths", limit}}}}}}},
+		{{Key: "$unwind", Value: "$paths"}},
+		{{Key: "$project", Value: bson.D{
+			{Key: "_id", Value: 0},
+			{Key: "domain", Value: "$_id"},
+			{Key: "path", Value: "$paths.path"},
+			{Key: "count", Value: "$paths.count"},
+		}}},
+	}
+
+	var results []dto.AccessLogPathCount
+	if err := r.aggregate(ctx, pipeline, &results); err != nil {
+		return nil, fmt.Errorf("统计热门路径失败: %w", err)
+	}
+	return results, nil
+}
+
+func (r *MongoAccessLogRepository) aggregate(ctx context.Context, pipeline mongo.Pipeline, results any) error {
+	cursor, err := r.collection.Aggregate(ctx, pipeline, options.Aggregate().SetAllowDiskUse(true))
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+	return cursor.All(ctx, results)
+}
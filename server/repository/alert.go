@@ -0,0 +1,293 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/HUAHUAI23/simple-waf/server/config"
+	"github.com/HUAHUAI23/simple-waf/server/model"
+	"github.com/rs/zerolog"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+var (
+	ErrAlertRuleNotFound = errors.New("告警规则不存在")
+)
+
+// AlertRuleRepository 告警规则仓库
+type AlertRuleRepository interface {
+	CreateAlertRule(ctx context.Context, rule *model.AlertRule) error
+	GetAlertRules(ctx context.Context, page, size int64) ([]model.AlertRule, int64, error)
+	GetEnabledAlertRules(ctx context.Context) ([]model.AlertRule, error)
+	GetAlertRuleByID(ctx context.Context, id bson.ObjectID) (*model.AlertRule, error)
+	UpdateAlertRule(ctx context.Context, rule *model.AlertRule) error
+	UpdateLastTriggered(ctx context.Context, id bson.ObjectID, t time.Time) error
+	DeleteAlertRule(ctx context.Context, id bson.ObjectID) error
+}
+
+// AlertHistoryRepository 告警投递历史仓库
+type AlertHistoryRepository interface {
+	CreateAlertHistory(ctx context.Context, history *model.AlertHistory) error
+	FindAlertHistory(ctx context.Context, filter bson.D, skip, limit int64) ([]model.AlertHistory, error)
+	CountAlertHistory(ctx context.Context, filter bson.D) (int64, error)
+	GetLastDeliveredAt(ctx context.Context, fingerprint string) (time.Time, error)
+}
+
+// MongoAlertRuleRepository MongoDB实现的告警规则仓库
+type MongoAlertRuleRepository struct {
+	collection *mongo.Collection
+	logger     zerolog.Logger
+}
+
+// MongoAlertHistoryRepository MongoDB实现的告警投递历史仓库
+type MongoAlertHistoryRepository struct {
+	collection *mongo.Collection
+	logger     zerolog.Logger
+}
+
+// NewAlertRuleRepository 创建告警规则仓库
+func NewAlertRuleRepository(db *mongo.Database) AlertRuleRepository {
+	var rule model.AlertRule
+	collection := db.Collection(rule.GetCollectionName())
+	logger := config.GetRepositoryLogger("alert_rule")
+
+	return &MongoAlertRuleRepository{
+		collection: collection,
+		logger:     logger,
+	}
+}
+
+// NewAlertHistoryRepository 创建告警投递历史仓库
+func NewAlertHistoryRepository(db *mongo.Database) AlertHistoryRepository {
+	var history model.AlertHistory
+	collection := db.Collection(history.GetCollectionName())
+	logger := config.GetRepositoryLogger("alert_history")
+
+	// 创建索引
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// 按指纹和时间查询最近一次投递，用于去重和冷却判断
+	_, err := collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "fingerprint", Value: 1}, {Key: "createdAt", Value: -1}}},
+		{Keys: bson.D{{Key: "ruleId", Value: 1}, {Key: "createdAt", Value: -1}}},
+	})
+	if err != nil {
+		logger.Error().Err(err).Msg("创建告警历史索引失败")
+	}
+
+	return &MongoAlertHistoryRepository{
+		collection: collection,
+		logger:     logger,
+	}
+}
+
+// CreateAlertRule 创建告警规则
+func (r *MongoAlertRuleRepository) CreateAlertRule(ctx context.Context, rule *model.AlertRule) error {
+	now := time.Now()
+	rule.CreatedAt = now
+	rule.UpdatedAt = now
+
+	result, err := r.collection.InsertOne(ctx, rule)
+	if err != nil {
+		r.logger.Error().Err(err).Str("name", rule.Name).Msg("插入告警规则时出错")
+		return err
+	}
+
+	if id, ok := result.InsertedID.(bson.ObjectID); ok {
+		rule.ID = id
+	}
+
+	return nil
+}
+
+// GetAlertRules 获取告警规则列表
+func (r *MongoAlertRuleRepository) GetAlertRules(ctx context.Context, page, size int64) ([]model.AlertRule, int64, error) {
+	skip := (page - 1) * size
+
+	findOptions := options.Find().
+		SetSkip(skip).
+		SetLimit(size).
+		SetSort(bson.D{{Key: "createdAt", Value: -1}})
+
+	cursor, err := r.collection.Find(ctx, bson.D{}, findOptions)
+	if err != nil {
+		r.logger.Error().Err(err).Msg("查询告警规则列表时出错")
+		return nil, 0, err
+	}
+	defer cursor.Close(ctx)
+
+	var rules []model.AlertRule
+	if err = cursor.All(ctx, &rules); err != nil {
+		r.logger.Error().Err(err).Msg("解析告警规则列表时出错")
+		return nil, 0, err
+	}
+
+	total, err := r.collection.CountDocuments(ctx, bson.D{})
+	if err != nil {
+		r.logger.Error().Err(err).Msg("获取告警规则总数时出错")
+		return nil, 0, err
+	}
+
+	return rules, total, nil
+}
+
+// GetEnabledAlertRules 获取所有启用的告警规则
+func (r *MongoAlertRuleRepository) GetEnabledAlertRules(ctx context.Context) ([]model.AlertRule, error) {
+	cursor, err := r.collection.Find(ctx, bson.D{{Key: "enabled", Value: true}})
+	if err != nil {
+		r.logger.Error().Err(err).Msg("查询启用的告警规则时出错")
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var rules []model.AlertRule
+	if err = cursor.All(ctx, &rules); err != nil {
+		r.logger.Error().Err(err).Msg("解析启用的告警规则时出错")
+		return nil, err
+	}
+
+	return rules, nil
+}
+
+// GetAlertRuleByID 根据ID获取告警规则
+func (r *MongoAlertRuleRepository) GetAlertRuleByID(ctx context.Context, id bson.ObjectID) (*model.AlertRule, error) {
+	var rule model.AlertRule
+	err := r.collection.FindOne(ctx, bson.D{{Key: "_id", Value: id}}).Decode(&rule)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, ErrAlertRuleNotFound
+		}
+		r.logger.Error().Err(err).Str("id", id.Hex()).Msg("查询告警规则时出错")
+		return nil, err
+	}
+
+	return &rule, nil
+}
+
+// UpdateAlertRule 更新告警规则
+func (r *MongoAlertRuleRepository) UpdateAlertRule(ctx context.Context, rule *model.AlertRule) error {
+	rule.UpdatedAt = time.Now()
+	result, err := r.collection.ReplaceOne(
+		ctx,
+		bson.D{{Key: "_id", Value: rule.ID}},
+		rule,
+	)
+	if err != nil {
+		r.logger.Error().Err(err).Str("id", rule.ID.Hex()).Msg("更新告警规则时出错")
+		return err
+	}
+
+	if result.MatchedCount == 0 {
+		return ErrAlertRuleNotFound
+	}
+
+	return nil
+}
+
+// UpdateLastTriggered 更新告警规则最近触发时间
+func (r *MongoAlertRuleRepository) UpdateLastTriggered(ctx context.Context, id bson.ObjectID, t time.Time) error {
+	_, err := r.collection.UpdateOne(
+		ctx,
+		bson.D{{Key: "_id", Value: id}},
+		bson.D{{Key: "$set", Value: bson.D{{Key: "lastTriggeredAt", Value: t}}}},
+	)
+	if err != nil {
+		r.logger.Error().Err(err).Str("id", id.Hex()).Msg("更新告警规则触发时间时出错")
+		return err
+	}
+
+	return nil
+}
+
+// DeleteAlertRule 删除告警规则
+func (r *MongoAlertRuleRepository) DeleteAlertRule(ctx context.Context, id bson.ObjectID) error {
+	result, err := r.collection.DeleteOne(ctx, bson.D{{Key: "_id", Value: id}})
+	if err != nil {
+		r.logger.Error().Err(err).Str("id", id.Hex()).Msg("删除告警规则时出错")
+		return err
+	}
+
+	if result.DeletedCount == 0 {
+		return ErrAlertRuleNotFound
+	}
+
+	return nil
+}
+
+// CreateAlertHistory 写入一条告警投递记录
+func (r *MongoAlertHistoryRepository) CreateAlertHistory(ctx context.Context, history *model.AlertHistory) error {
+	if history.CreatedAt.IsZero() {
+		history.CreatedAt = time.Now()
+	}
+
+	result, err := r.collection.InsertOne(ctx, history)
+	if err != nil {
+		r.logger.Error().Err(err).Str("fingerprint", history.Fingerprint).Msg("插入告警投递记录时出错")
+		return err
+	}
+
+	if id, ok := result.InsertedID.(bson.ObjectID); ok {
+		history.ID = id
+	}
+
+	return nil
+}
+
+// FindAlertHistory 查询告警投递记录
+func (r *MongoAlertHistoryRepository) FindAlertHistory(ctx context.Context, filter bson.D, skip, limit int64) ([]model.AlertHistory, error) {
+	findOptions := options.Find().
+		SetSkip(skip).
+		SetLimit(limit).
+		SetSort(bson.D{{Key: "createdAt", Value: -1}})
+
+	cursor, err := r.collection.Find(ctx, filter, findOptions)
+	if err != nil {
+		r.logger.Error().Err(err).Msg("查询告警投递记录时出错")
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var results []model.AlertHistory
+	if err = cursor.All(ctx, &results); err != nil {
+		r.logger.Error().Err(err).Msg("解析告警投递记录时出错")
+		return nil, err
+	}
+
+	return results, nil
+}
+
+// CountAlertHistory 统计告警投递记录数
+func (r *MongoAlertHistoryRepository) CountAlertHistory(ctx context.Context, filter bson.D) (int64, error) {
+	total, err := r.collection.CountDocuments(ctx, filter)
+	if err != nil {
+		r.logger.Error().Err(err).Msg("统计告警投递记录时出错")
+		return 0, err
+	}
+	return total, nil
+}
+
+// GetLastDeliveredAt 获取指定指纹最近一次成功投递的时间，不存在时返回零值
+func (r *MongoAlertHistoryRepository) GetLastDeliveredAt(ctx context.Context, fingerprint string) (time.Time, error) {
+	var history model.AlertHistory
+	err := r.collection.FindOne(
+		ctx,
+		bson.D{
+			{Key: "fingerprint", Value: fingerprint},
+			{Key: "success", Value: true},
+		},
+		options.FindOne().SetSort(bson.D{{Key: "createdAt", Value: -1}}),
+	).Decode(&history)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return time.Time{}, nil
+		}
+		r.logger.Error().Err(err).Str("fingerprint", fingerprint).Msg("查询最近投递记录时出错")
+		return time.Time{}, err
+	}
+
+	return history.CreatedAt, nil
+}
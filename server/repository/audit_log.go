@@ -0,0 +1,103 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/HUAHUAI23/simple-waf/server/config"
+	"github.com/HUAHUAI23/simple-waf/server/model"
+	"github.com/rs/zerolog"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// AuditLogRepository 审计日志仓库
+type AuditLogRepository interface {
+	CreateAuditLog(ctx context.Context, auditLog *model.AuditLog) error
+	FindAuditLogs(ctx context.Context, filter bson.D, skip, limit int64) ([]model.AuditLog, error)
+	CountAuditLogs(ctx context.Context, filter bson.D) (int64, error)
+}
+
+// MongoAuditLogRepository MongoDB实现的审计日志仓库
+type MongoAuditLogRepository struct {
+	collection *mongo.Collection
+	logger     zerolog.Logger
+}
+
+// NewAuditLogRepository 创建审计日志仓库
+func NewAuditLogRepository(db *mongo.Database) AuditLogRepository {
+	var auditLog model.AuditLog
+	collection := db.Collection(auditLog.GetCollectionName())
+	logger := config.GetRepositoryLogger("audit_log")
+
+	// 创建索引
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "createdAt", Value: -1}}},
+		{Keys: bson.D{{Key: "username", Value: 1}, {Key: "createdAt", Value: -1}}},
+		{Keys: bson.D{{Key: "action", Value: 1}, {Key: "createdAt", Value: -1}}},
+	})
+	if err != nil {
+		logger.Error().Err(err).Msg("创建审计日志索引失败")
+	}
+
+	return &MongoAuditLogRepository{
+		collection: collection,
+		logger:     logger,
+	}
+}
+
+// CreateAuditLog 写入一条审计记录
+func (r *MongoAuditLogRepository) CreateAuditLog(ctx context.Context, auditLog *model.AuditLog) error {
+	if auditLog.CreatedAt.IsZero() {
+		auditLog.CreatedAt = time.Now()
+	}
+
+	result, err := r.collection.InsertOne(ctx, auditLog)
+	if err != nil {
+		r.logger.Error().Err(err).Str("action", auditLog.Action).Msg("插入审计记录时出错")
+		return err
+	}
+
+	if id, ok := result.InsertedID.(bson.ObjectID); ok {
+		auditLog.ID = id
+	}
+
+	return nil
+}
+
+// FindAuditLogs 查询审计记录
+func (r *MongoAuditLogRepository) FindAuditLogs(ctx context.Context, filter bson.D, skip, limit int64) ([]model.AuditLog, error) {
+	findOptions := options.Find().
+		SetSkip(skip).
+		SetLimit(limit).
+		SetSort(bson.D{{Key: "createdAt", Value: -1}})
+
+	cursor, err := r.collection.Find(ctx, filter, findOptions)
+	if err != nil {
+		r.logger.Error().Err(err).Msg("查询审计记录时出错")
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var results []model.AuditLog
+	if err = cursor.All(ctx, &results); err != nil {
+		r.logger.Error().Err(err).Msg("解析审计记录时出错")
+		return nil, err
+	}
+
+	return results, nil
+}
+
+// CountAuditLogs 统计审计记录数
+func (r *MongoAuditLogRepository) CountAuditLogs(ctx context.Context, filter bson.D) (int64, error) {
+	total, err := r.collection.CountDocuments(ctx, filter)
+	if err != nil {
+		r.logger.Error().Err(err).Msg("统计审计记录时出错")
+		return 0, err
+	}
+	return total, nil
+}
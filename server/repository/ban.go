@@ -0,0 +1,377 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/HUAHUAI23/simple-waf/server/config"
+	"github.com/HUAHUAI23/simple-waf/server/model"
+	"github.com/rs/zerolog"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+var (
+	ErrBanPolicyNotFound = errors.New("封禁策略不存在")
+	ErrBanNotFound       = errors.New("封禁记录不存在")
+	ErrBanExists         = errors.New("该IP已被封禁")
+)
+
+// BanPolicyRepository 封禁策略仓库
+type BanPolicyRepository interface {
+	CreateBanPolicy(ctx context.Context, policy *model.BanPolicy) error
+	GetBanPolicies(ctx context.Context, page, size int64) ([]model.BanPolicy, int64, error)
+	GetEnabledBanPolicies(ctx context.Context) ([]model.BanPolicy, error)
+	GetBanPolicyByID(ctx context.Context, id bson.ObjectID) (*model.BanPolicy, error)
+	UpdateBanPolicy(ctx context.Context, policy *model.BanPolicy) error
+	DeleteBanPolicy(ctx context.Context, id bson.ObjectID) error
+}
+
+// BanRepository 封禁记录仓库
+type BanRepository interface {
+	CreateBan(ctx context.Context, ban *model.Ban) error
+	GetBanByID(ctx context.Context, id bson.ObjectID) (*model.Ban, error)
+	FindBans(ctx context.Context, filter bson.D, skip, limit int64) ([]model.Ban, int64, error)
+	GetActiveBans(ctx context.Context) ([]model.Ban, error)
+	GetLastBanTimes(ctx context.Context, since time.Time) (map[string]time.Time, error)
+	CountBansSince(ctx context.Context, ip string, since time.Time) (int64, error)
+	ExpireBans(ctx context.Context, now time.Time) (int64, error)
+	RevokeBan(ctx context.Context, id bson.ObjectID, revokedBy string) (*model.Ban, error)
+}
+
+// MongoBanPolicyRepository MongoDB实现的封禁策略仓库
+type MongoBanPolicyRepository struct {
+	collection *mongo.Collection
+	logger     zerolog.Logger
+}
+
+// MongoBanRepository MongoDB实现的封禁记录仓库
+type MongoBanRepository struct {
+	collection *mongo.Collection
+	logger     zerolog.Logger
+}
+
+// NewBanPolicyRepository 创建封禁策略仓库
+func NewBanPolicyRepository(db *mongo.Database) BanPolicyRepository {
+	var policy model.BanPolicy
+	return &MongoBanPolicyRepository{
+		collection: db.Collection(policy.GetCollectionName()),
+		logger:     config.GetRepositoryLogger("ban_policy"),
+	}
+}
+
+// NewBanRepository 创建封禁记录仓库
+func NewBanRepository(db *mongo.Database) BanRepository {
+	var ban model.Ban
+	collection := db.Collection(ban.GetCollectionName())
+	logger := config.GetRepositoryLogger("ban")
+
+	// 创建索引
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// 同一IP同时只允许一条生效中的封禁，防止并发触发时重复封禁
+	_, err := collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "ip", Value: 1}},
+			Options: options.Index().SetUnique(true).SetPartialFilterExpression(bson.D{{Key: "active", Value: true}}),
+		},
+		{Keys: bson.D{{Key: "active", Value: 1}, {Key: "expiresAt", Value: 1}}},
+		{Keys: bson.D{{Key: "ip", Value: 1}, {Key: "startedAt", Value: -1}}},
+		{Keys: bson.D{{Key: "startedAt", Value: -1}}},
+	})
+	if err != nil {
+		logger.Error().Err(err).Msg("创建封禁记录索引失败")
+	}
+
+	return &MongoBanRepository{
+		collection: collection,
+		logger:     logger,
+	}
+}
+
+// CreateBanPolicy 创建封禁策略
+func (r *MongoBanPolicyRepository) CreateBanPolicy(ctx context.Context, policy *model.BanPolicy) error {
+	now := time.Now()
+	policy.CreatedAt = now
+	policy.UpdatedAt = now
+
+	result, err := r.collection.InsertOne(ctx, policy)
+	if err != nil {
+		r.logger.Error().Err(err).Str("name", policy.Name).Msg("插入封禁策略时出错")
+		return err
+	}
+
+	if id, ok := result.InsertedID.(bson.ObjectID); ok {
+		policy.ID = id
+	}
+
+	return nil
+}
+
+// GetBanPolicies 获取封禁策略列表
+func (r *MongoBanPolicyRepository) GetBanPolicies(ctx context.Context, page, size int64) ([]model.BanPolicy, int64, error) {
+	findOptions := options.Find().
+		SetSkip((page - 1) * size).
+		SetLimit(size).
+		SetSort(bson.D{{Key: "createdAt", Value: -1}})
+
+	cursor, err := r.collection.Find(ctx, bson.D{}, findOptions)
+	if err != nil {
+		r.logger.Error().Err(err).Msg("查询封禁策略列表时出错")
+		return nil, 0, err
+	}
+	defer cursor.Close(ctx)
+
+	var policies []model.BanPolicy
+	if err = cursor.All(ctx, &policies); err != nil {
+		r.logger.Error().Err(err).Msg("解析封禁策略列表时出错")
+		return nil, 0, err
+	}
+
+	total, err := r.collection.CountDocuments(ctx, bson.D{})
+	if err != nil {
+		r.logger.Error().Err(err).Msg("获取封禁策略总数时出错")
+		return nil, 0, err
+	}
+
+	return policies, total, nil
+}
+
+// GetEnabledBanPolicies 获取所有启用的封禁策略
+func (r *MongoBanPolicyRepository) GetEnabledBanPolicies(ctx context.Context) ([]model.BanPolicy, error) {
+	cursor, err := r.collection.Find(ctx, bson.D{{Key: "enabled", Value: true}})
+	if err != nil {
+		r.logger.Error().Err(err).Msg("查询启用的封禁策略时出错")
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var policies []model.BanPolicy
+	if err = cursor.All(ctx, &policies); err != nil {
+		r.logger.Error().Err(err).Msg("解析启用的封禁策略时出错")
+		return nil, err
+	}
+
+	return policies, nil
+}
+
+// GetBanPolicyByID 根据ID获取封禁策略
+func (r *MongoBanPolicyRepository) GetBanPolicyByID(ctx context.Context, id bson.ObjectID) (*model.BanPolicy, error) {
+	var policy model.BanPolicy
+	err := r.collection.FindOne(ctx, bson.D{{Key: "_id", Value: id}}).Decode(&policy)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, ErrBanPolicyNotFound
+		}
+		r.logger.Error().Err(err).Str("id", id.Hex()).Msg("查询封禁策略时出错")
+		return nil, err
+	}
+
+	return &policy, nil
+}
+
+// UpdateBanPolicy 更新封禁策略
+func (r *MongoBanPolicyRepository) UpdateBanPolicy(ctx context.Context, policy *model.BanPolicy) error {
+	policy.UpdatedAt = time.Now()
+	result, err := r.collection.ReplaceOne(ctx, bson.D{{Key: "_id", Value: policy.ID}}, policy)
+	if err != nil {
+		r.logger.Error().Err(err).Str("id", policy.ID.Hex()).Msg("更新封禁策略时出错")
+		return err
+	}
+
+	if result.MatchedCount == 0 {
+		return ErrBanPolicyNotFound
+	}
+
+	return nil
+}
+
+// DeleteBanPolicy 删除封禁策略，已产生的封禁记录不受影响
+func (r *MongoBanPolicyRepository) DeleteBanPolicy(ctx context.Context, id bson.ObjectID) error {
+	result, err := r.collection.DeleteOne(ctx, bson.D{{Key: "_id", Value: id}})
+	if err != nil {
+		r.logger.Error().Err(err).Str("id", id.Hex()).Msg("删除封禁策略时出错")
+		return err
+	}
+
+	if result.DeletedCount == 0 {
+		return ErrBanPolicyNotFound
+	}
+
+	return nil
+}
+
+// CreateBan 创建封禁记录，IP已有生效中的封禁时返回 ErrBanExists
+func (r *MongoBanRepository) CreateBan(ctx context.Context, ban *model.Ban) error {
+	now := time.Now()
+	ban.CreatedAt = now
+	ban.UpdatedAt = now
+
+	result, err := r.collection.InsertOne(ctx, ban)
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return ErrBanExists
+		}
+		r.logger.Error().Err(err).Str("ip", ban.IP).Msg("插入封禁记录时出错")
+		return err
+	}
+
+	if id, ok := result.InsertedID.(bson.ObjectID); ok {
+		ban.ID = id
+	}
+
+	return nil
+}
+
+// GetBanByID 根据ID获取封禁记录
+func (r *MongoBanRepository) GetBanByID(ctx context.Context, id bson.ObjectID) (*model.Ban, error) {
+	var ban model.Ban
+	err := r.collection.FindOne(ctx, bson.D{{Key: "_id", Value: id}}).Decode(&ban)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, ErrBanNotFound
+		}
+		r.logger.Error().Err(err).Str("id", id.Hex()).Msg("查询封禁记录时出错")
+		return nil, err
+	}
+
+	return &ban, nil
+}
+
+// FindBans 按开始时间倒序分页查询封禁记录，列表中不返回触发事件
+func (r *MongoBanRepository) FindBans(ctx context.Context, filter bson.D, skip, limit int64) ([]model.Ban, int64, error) {
+	total, err := r.collection.CountDocuments(ctx, filter)
+	if err != nil {
+		r.logger.Error().Err(err).Msg("获取封禁记录总数时出错")
+		return nil, 0, err
+	}
+
+	findOptions := options.Find().
+		SetSort(bson.D{{Key: "startedAt", Value: -1}, {Key: "_id", Value: -1}}).
+		SetSkip(skip).
+		SetLimit(limit).
+		SetProjection(bson.D{{Key: "events", Value: 0}})
+
+	cursor, err := r.collection.Find(ctx, filter, findOptions)
+	if err != nil {
+		r.logger.Error().Err(err).Msg("查询封禁记录时出错")
+		return nil, 0, err
+	}
+	defer cursor.Close(ctx)
+
+	var bans []model.Ban
+	if err = cursor.All(ctx, &bans); err != nil {
+		r.logger.Error().Err(err).Msg("解析封禁记录时出错")
+		return nil, 0, err
+	}
+
+	return bans, total, nil
+}
+
+// GetActiveBans 获取所有生效中的封禁，不返回触发事件
+func (r *MongoBanRepository) GetActiveBans(ctx context.Context) ([]model.Ban, error) {
+	cursor, err := r.collection.Find(ctx,
+		bson.D{{Key: "active", Value: true}},
+		options.Find().SetProjection(bson.D{{Key: "events", Value: 0}}),
+	)
+	if err != nil {
+		r.logger.Error().Err(err).Msg("查询生效中的封禁时出错")
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var bans []model.Ban
+	if err = cursor.All(ctx, &bans); err != nil {
+		r.logger.Error().Err(err).Msg("解析生效中的封禁时出错")
+		return nil, err
+	}
+
+	return bans, nil
+}
+
+// GetLastBanTimes 获取 since 之后每个IP最近一次封禁的开始时间
+func (r *MongoBanRepository) GetLastBanTimes(ctx context.Context, since time.Time) (map[string]time.Time, error) {
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.D{{Key: "startedAt", Value: bson.D{{Key: "$gte", Value: since}}}}}},
+		{{Key: "$group", Value: bson.D{
+			{Key: "_id", Value: "$ip"},
+			{Key: "startedAt", Value: bson.D{{Key: "$max", Value: "$startedAt"}}},
+		}}},
+	}
+
+	cursor, err := r.collection.Aggregate(ctx, pipeline)
+	if err != nil {
+		r.logger.Error().Err(err).Msg("聚合最近封禁时间时出错")
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var rows []struct {
+		IP        string    `bson:"_id"`
+		StartedAt time.Time `bson:"startedAt"`
+	}
+	if err = cursor.All(ctx, &rows); err != nil {
+		return nil, err
+	}
+
+	result := make(map[string]time.Time, len(rows))
+	for _, row := range rows {
+		result[row.IP] = row.StartedAt
+	}
+	return result, nil
+}
+
+// CountBansSince 统计IP在 since 之后的封禁次数，用于计算阶梯级别
+func (r *MongoBanRepository) CountBansSince(ctx context.Context, ip string, since time.Time) (int64, error) {
+	return r.collection.CountDocuments(ctx, bson.D{
+		{Key: "ip", Value: ip},
+		{Key: "startedAt", Value: bson.D{{Key: "$gte", Value: since}}},
+	})
+}
+
+// ExpireBans 将已到期的封禁标记为失效，返回失效的数量
+func (r *MongoBanRepository) ExpireBans(ctx context.Context, now time.Time) (int64, error) {
+	result, err := r.collection.UpdateMany(ctx,
+		bson.D{
+			{Key: "active", Value: true},
+			{Key: "expiresAt", Value: bson.D{{Key: "$lte", Value: now}}},
+		},
+		bson.D{{Key: "$set", Value: bson.D{
+			{Key: "active", Value: false},
+			{Key: "updatedAt", Value: now},
+		}}},
+	)
+	if err != nil {
+		r.logger.Error().Err(err).Msg("更新到期封禁时出错")
+		return 0, err
+	}
+	return result.ModifiedCount, nil
+}
+
+// RevokeBan 手动解除生效中的封禁
+func (r *MongoBanRepository) RevokeBan(ctx context.Context, id bson.ObjectID, revokedBy string) (*model.Ban, error) {
+	now := time.Now()
+	var ban model.Ban
+	err := r.collection.FindOneAndUpdate(ctx,
+		bson.D{{Key: "_id", Value: id}, {Key: "active", Value: true}},
+		bson.D{{Key: "$set", Value: bson.D{
+			{Key: "active", Value: false},
+			{Key: "revokedAt", Value: now},
+			{Key: "revokedBy", Value: revokedBy},
+			{Key: "updatedAt", Value: now},
+		}}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&ban)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, ErrBanNotFound
+		}
+		r.logger.Error().Err(err).Str("id", id.Hex()).Msg("解除封禁时出错")
+		return nil, err
+	}
+
+	return &ban, nil
+}
@@ -27,6 +27,7 @@ type CertificateRepository interface {
 	UpdateCertificate(ctx context.Context, certificate *model.CertificateStore) error
 	DeleteCertificate(ctx context.Context, id bson.ObjectID) error
 	CheckCertificateNameExists(ctx context.Context, name string, excludeID bson.ObjectID) (bool, error)
+	GetExpiringCertificates(ctx context.Context, before time.Time) ([]model.CertificateStore, error)
 }
 
 // MongoCertificateRepository MongoDB实现的证书仓库
@@ -181,3 +182,24 @@ func (r *MongoCertificateRepository) CheckCertificateNameExists(ctx context.Cont
 
 	return count > 0, nil
 }
+
+// GetExpiringCertificates 获取在指定时间之前过期的证书
+func (r *MongoCertificateRepository) GetExpiringCertificates(ctx context.Context, before time.Time) ([]model.CertificateStore, error) {
+	filter := bson.D{{Key: "expireDate", Value: bson.D{{Key: "$lte", Value: before}}}}
+	findOptions := options.Find().SetSort(bson.D{{Key: "expireDate", Value: 1}})
+
+	cursor, err := r.collection.Find(ctx, filter, findOptions)
+	if err != nil {
+		r.logger.Error().Err(err).Msg("查询即将过期证书时出错")
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var certificates []model.CertificateStore
+	if err = cursor.All(ctx, &certificates); err != nil {
+		r.logger.Error().Err(err).Msg("解析即将过期证书时出错")
+		return nil, err
+	}
+
+	return certificates, nil
+}
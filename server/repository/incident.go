@@ -0,0 +1,271 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/HUAHUAI23/simple-waf/server/config"
+	"github.com/HUAHUAI23/simple-waf/server/model"
+	"github.com/rs/zerolog"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+var ErrIncidentNotFound = errors.New("安全事件不存在")
+
+// incidentBatchHistory 安全事件上保留的最近扫描批次数
+const incidentBatchHistory = 16
+
+// IncidentDelta 一批事件合并到已有安全事件时的增量
+type IncidentDelta struct {
+	FirstSeen    time.Time
+	LastSeen     time.Time
+	WAFEvents    int64
+	IDSEvents    int64
+	AccessEvents int64
+	RuleIDs      []int
+	SignatureIDs []int
+}
+
+// IncidentRepository 安全事件仓库接口
+type IncidentRepository interface {
+	Create(ctx context.Context, incident *model.Incident, batch string) (bool, error)
+	FindActive(ctx context.Context, srcIP, target string, since time.Time) (*model.Incident, error)
+	MergeEvents(ctx context.Context, id bson.ObjectID, batch string, delta IncidentDelta) error
+	GetByID(ctx context.Context, id bson.ObjectID) (*model.Incident, error)
+	List(ctx context.Context, filter bson.D, skip, limit int64) ([]model.Incident, int64, error)
+	Update(ctx context.Context, id bson.ObjectID, set bson.D) (*model.Incident, error)
+	AddComment(ctx context.Context, id bson.ObjectID, comment model.IncidentComment) (*model.Incident, error)
+	GetCheckpoint(ctx context.Context, source string) (bson.ObjectID, error)
+	SaveCheckpoint(ctx context.Context, source string, lastID bson.ObjectID) error
+}
+
+// MongoIncidentRepository MongoDB实现的安全事件仓库
+type MongoIncidentRepository struct {
+	collection           *mongo.Collection
+	checkpointCollection *mongo.Collection
+	logger               zerolog.Logger
+}
+
+// NewIncidentRepository 创建安全事件仓库
+func NewIncidentRepository(db *mongo.Database) IncidentRepository {
+	var incident model.Incident
+	var checkpoint model.IncidentCheckpoint
+	collection := db.Collection(incident.GetCollectionName())
+	logger := config.GetRepositoryLogger("incident")
+
+	// 创建索引
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "srcIp", Value: 1}, {Key: "target", Value: 1}, {Key: "lastSeen", Value: -1}}},
+		{Keys: bson.D{{Key: "status", Value: 1}, {Key: "lastSeen", Value: -1}}},
+		{Keys: bson.D{{Key: "lastSeen", Value: -1}}},
+	})
+	if err != nil {
+		logger.Error().Err(err).Msg("创建安全事件索引失败")
+	}
+
+	return &MongoIncidentRepository{
+		collection:           collection,
+		checkpointCollection: db.Collection(checkpoint.GetCollectionName()),
+		logger:               logger,
+	}
+}
+
+// Create 创建安全事件，batch 为产生该安全事件的扫描批次
+//
+// 重放同一批次时，若已存在该批次创建的相同安全事件则不再创建，返回 false
+func (r *MongoIncidentRepository) Create(ctx context.Context, incident *model.Incident, batch string) (bool, error) {
+	filter := bson.D{
+		{Key: "srcIp", Value: incident.SrcIP},
+		{Key: "target", Value: incident.Target},
+		{Key: "firstSeen", Value: incident.FirstSeen},
+		{Key: "batches", Value: batch},
+	}
+	count, err := r.collection.CountDocuments(ctx, filter, options.Count().SetLimit(1))
+	if err != nil {
+		return false, err
+	}
+	if count > 0 {
+		return false, nil
+	}
+
+	now := time.Now()
+	incident.CreatedAt = now
+	incident.UpdatedAt = now
+	incident.Batches = []string{batch}
+
+	result, err := r.collection.InsertOne(ctx, incident)
+	if err != nil {
+		r.logger.Error().Err(err).Str("srcIp", incident.SrcIP).Str("target", incident.Target).Msg("创建安全事件失败")
+		return false, err
+	}
+	if id, ok := result.InsertedID.(bson.ObjectID); ok {
+		incident.ID = id
+	}
+	return true, nil
+}
+
+// FindActive 查找同一来源IP和目标、最近事件不早于 since 的未关闭安全事件
+func (r *MongoIncidentRepository) FindActive(ctx context.Context, srcIP, target string, since time.Time) (*model.Incident, error) {
+	filter := bson.D{
+		{Key: "srcIp", Value: srcIP},
+		{Key: "target", Value: target},
+		{Key: "status", Value: bson.D{{Key: "$in", Value: bson.A{model.IncidentStatusOpen, model.IncidentStatusAcknowledged}}}},
+		{Key: "lastSeen", Value: bson.D{{Key: "$gte", Value: since}}},
+	}
+
+	var incident model.Incident
+	err := r.collection.FindOne(ctx, filter, options.FindOne().SetSort(bson.D{{Key: "lastSeen", Value: -1}})).Decode(&incident)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &incident, nil
+}
+
+// MergeEvents 将一批事件合并到已有安全事件
+//
+// 合并和批次记录在同一次更新中完成，已合并过该批次的安全事件不会再次累加计数，
+// 因此扫描进度保存失败后重放同一批次是安全的
+func (r *MongoIncidentRepository) MergeEvents(ctx context.Context, id bson.ObjectID, batch string, delta IncidentDelta) error {
+	update := bson.D{
+		{Key: "$inc", Value: bson.D{
+			{Key: "wafEvents", Value: delta.WAFEvents},
+			{Key: "idsEvents", Value: delta.IDSEvents},
+			{Key: "accessEvents", Value: delta.AccessEvents},
+		}},
+		{Key: "$min", Value: bson.D{{Key: "firstSeen", Value: delta.FirstSeen}}},
+		{Key: "$max", Value: bson.D{{Key: "lastSeen", Value: delta.LastSeen}}},
+		{Key: "$set", Value: bson.D{{Key: "updatedAt", Value: time.Now()}}},
+		{Key: "$push", Value: bson.D{{Key: "batches", Value: bson.D{
+			{Key: "$each", Value: bson.A{batch}},
+			{Key: "$slice", Value: -incidentBatchHistory},
+		}}}},
+	}
+
+	addToSet := bson.D{}
+	if len(delta.RuleIDs) > 0 {
+		addToSet = append(addToSet, bson.E{Key: "ruleIds", Value: bson.D{{Key: "$each", Value: delta.RuleIDs}}})
+	}
+	if len(delta.SignatureIDs) > 0 {
+		addToSet = append(addToSet, bson.E{Key: "signatureIds", Value: bson.D{{Key: "$each", Value: delta.SignatureIDs}}})
+	}
+	if len(addToSet) > 0 {
+		update = append(update, bson.E{Key: "$addToSet", Value: addToSet})
+	}
+
+	filter := bson.D{
+		{Key: "_id", Value: id},
+		{Key: "batches", Value: bson.D{{Key: "$ne", Value: batch}}},
+	}
+	_, err := r.collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		r.logger.Error().Err(err).Str("id", id.Hex()).Msg("合并安全事件失败")
+	}
+	return err
+}
+
+// GetByID 根据ID获取安全事件
+func (r *MongoIncidentRepository) GetByID(ctx context.Context, id bson.ObjectID) (*model.Incident, error) {
+	var incident model.Incident
+	err := r.collection.FindOne(ctx, bson.D{{Key: "_id", Value: id}}).Decode(&incident)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, ErrIncidentNotFound
+		}
+		return nil, err
+	}
+	return &incident, nil
+}
+
+// List 按最近事件时间倒序分页查询安全事件，列表中不返回备注
+func (r *MongoIncidentRepository) List(ctx context.Context, filter bson.D, skip, limit int64) ([]model.Incident, int64, error) {
+	total, err := r.collection.CountDocuments(ctx, filter)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	findOpts := options.Find().
+		SetSort(bson.D{{Key: "lastSeen", Value: -1}, {Key: "_id", Value: -1}}).
+		SetSkip(skip).
+		SetLimit(limit).
+		SetProjection(bson.D{{Key: "comments", Value: 0}, {Key: "batches", Value: 0}})
+
+	cursor, err := r.collection.Find(ctx, filter, findOpts)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer cursor.Close(ctx)
+
+	var incidents []model.Incident
+	if err := cursor.All(ctx, &incidents); err != nil {
+		return nil, 0, err
+	}
+	return incidents, total, nil
+}
+
+// Update 更新安全事件字段并返回更新后的文档
+func (r *MongoIncidentRepository) Update(ctx context.Context, id bson.ObjectID, set bson.D) (*model.Incident, error) {
+	set = append(set, bson.E{Key: "updatedAt", Value: time.Now()})
+	return r.findOneAndUpdate(ctx, id, bson.D{{Key: "$set", Value: set}})
+}
+
+// AddComment 追加处理备注
+func (r *MongoIncidentRepository) AddComment(ctx context.Context, id bson.ObjectID, comment model.IncidentComment) (*model.Incident, error) {
+	return r.findOneAndUpdate(ctx, id, bson.D{
+		{Key: "$push", Value: bson.D{{Key: "comments", Value: comment}}},
+		{Key: "$set", Value: bson.D{{Key: "updatedAt", Value: time.Now()}}},
+	})
+}
+
+func (r *MongoIncidentRepository) findOneAndUpdate(ctx context.Context, id bson.ObjectID, update bson.D) (*model.Incident, error) {
+	var incident model.Incident
+	err := r.collection.FindOneAndUpdate(
+		ctx,
+		bson.D{{Key: "_id", Value: id}},
+		update,
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&incident)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, ErrIncidentNotFound
+		}
+		r.logger.Error().Err(err).Str("id", id.Hex()).Msg("更新安全事件失败")
+		return nil, err
+	}
+	return &incident, nil
+}
+
+// GetCheckpoint 获取关联扫描进度，尚未扫描时返回零值
+func (r *MongoIncidentRepository) GetCheckpoint(ctx context.Context, source string) (bson.ObjectID, error) {
+	var checkpoint model.IncidentCheckpoint
+	err := r.checkpointCollection.FindOne(ctx, bson.D{{Key: "_id", Value: source}}).Decode(&checkpoint)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return bson.ObjectID{}, nil
+		}
+		return bson.ObjectID{}, err
+	}
+	return checkpoint.LastID, nil
+}
+
+// SaveCheckpoint 保存关联扫描进度
+func (r *MongoIncidentRepository) SaveCheckpoint(ctx context.Context, source string, lastID bson.ObjectID) error {
+	_, err := r.checkpointCollection.UpdateOne(
+		ctx,
+		bson.D{{Key: "_id", Value: source}},
+		bson.D{{Key: "$set", Value: bson.D{
+			{Key: "lastId", Value: lastID},
+			{Key: "updatedAt", Value: time.Now()},
+		}}},
+		options.UpdateOne().SetUpsert(true),
+	)
+	return err
+}
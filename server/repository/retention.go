@@ -0,0 +1,240 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/HUAHUAI23/simple-waf/server/config"
+	"github.com/rs/zerolog"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// retentionCheckpointCollection 归档进度集合
+const retentionCheckpointCollection = "retention_checkpoint"
+
+// RetentionRepository 日志保留仓库，负责 TTL 索引维护、归档进度和归档数据读写
+type RetentionRepository interface {
+	EnsureTTLIndex(ctx context.Context, collection, field string, expireAfter time.Duration) error
+	GetArchiveCheckpoint(ctx context.Context, collection string) (time.Time, error)
+	SetArchiveCheckpoint(ctx context.Context, collection string, archivedUntil time.Time) error
+	GetOldestTime(ctx context.Context, collection, field string) (time.Time, error)
+	StreamRange(ctx context.Context, collection, field string, from, to time.Time, fn func(bson.Raw) error) error
+	InsertDocuments(ctx context.Context, collection string, docs []any) (int64, error)
+}
+
+// MongoRetentionRepository MongoDB实现的日志保留仓库
+type MongoRetentionRepository struct {
+	db     *mongo.Database
+	logger zerolog.Logger
+}
+
+// NewRetentionRepository 创建日志保留仓库
+func NewRetentionRepository(db *mongo.Database) RetentionRepository {
+	return &MongoRetentionRepository{
+		db:     db,
+		logger: config.GetRepositoryLogger("retention"),
+	}
+}
+
+// ttlIndexName 返回集合 TTL 索引的名称
+func ttlIndexName(field string) string {
+	return "ttl_" + field
+}
+
+// EnsureTTLIndex 创建或更新集合的 TTL 索引，expireAfter 为 0 时删除 TTL 索引
+func (r *MongoRetentionRepository) EnsureTTLIndex(ctx context.Context, collection, field string, expireAfter time.Duration) error {
+	coll := r.db.Collection(collection)
+	name := ttlIndexName(field)
+
+	cursor, err := coll.Indexes().List(ctx)
+	if err != nil {
+		return err
+	}
+	var indexes []bson.M
+	if err := cursor.All(ctx, &indexes); err != nil {
+		return err
+	}
+
+	var existing bson.M
+	for _, idx := range indexes {
+		if idx["name"] == name {
+			existing = idx
+			break
+		}
+	}
+
+	seconds := int32(expireAfter / time.Second)
+
+	if seconds <= 0 {
+		if existing != nil {
+			if err := coll.Indexes().DropOne(ctx, name); err != nil {
+				return err
+			}
+			r.logger.Info().Str("collection", collection).Msg("已删除 TTL 索引")
+		}
+		return nil
+	}
+
+	if existing == nil {
+		_, err := coll.Indexes().CreateOne(ctx, mongo.IndexModel{
+			Keys:    bson.D{{Key: field, Value: 1}},
+			Options: options.Index().SetName(name).SetExpireAfterSeconds(seconds),
+		})
+		if err != nil {
+			return err
+		}
+		r.logger.Info().Str("collection", collection).Int32("expireAfterSeconds", seconds).Msg("已创建 TTL 索引")
+		return nil
+	}
+
+	if current, ok := toInt64(existing["expireAfterSeconds"]); ok && current == int64(seconds) {
+		return nil
+	}
+
+	// 已存在的 TTL 索引通过 collMod 修改过期时间，无需重建
+	err = r.db.RunCommand(ctx, bson.D{
+		{Key: "collMod", Value: collection},
+		{Key: "index", Value: bson.D{
+			{Key: "name", Value: name},
+			{Key: "expireAfterSeconds", Value: seconds},
+		}},
+	}).Err()
+	if err != nil {
+		return err
+	}
+	r.logger.Info().Str("collection", collection).Int32("expireAfterSeconds", seconds).Msg("已更新 TTL 索引")
+	return nil
+}
+
+// GetArchiveCheckpoint 获取集合已归档到的时间点，不存在时返回零值
+func (r *MongoRetentionRepository) GetArchiveCheckpoint(ctx context.Context, collection string) (time.Time, error) {
+	var checkpoint struct {
+		ArchivedUntil time.Time `bson:"archivedUntil"`
+	}
+	err := r.db.Collection(retentionCheckpointCollection).
+		FindOne(ctx, bson.D{{Key: "_id", Value: collection}}).
+		Decode(&checkpoint)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return time.Time{}, nil
+		}
+		return time.Time{}, err
+	}
+	return checkpoint.ArchivedUntil, nil
+}
+
+// SetArchiveCheckpoint 记录集合已归档到的时间点
+func (r *MongoRetentionRepository) SetArchiveCheckpoint(ctx context.Context, collection string, archivedUntil time.Time) error {
+	_, err := r.db.Collection(retentionCheckpointCollection).UpdateOne(
+		ctx,
+		bson.D{{Key: "_id", Value: collection}},
+		bson.D{{Key: "$set", Value: bson.D{
+			{Key: "archivedUntil", Value: archivedUntil},
+			{Key: "updatedAt", Value: time.Now()},
+		}}},
+		options.UpdateOne().SetUpsert(true),
+	)
+	return err
+}
+
+// GetOldestTime 获取集合中最早一条记录的时间，集合为空时返回零值
+func (r *MongoRetentionRepository) GetOldestTime(ctx context.Context, collection, field string) (time.Time, error) {
+	var doc bson.Raw
+	err := r.db.Collection(collection).FindOne(
+		ctx,
+		bson.D{{Key: field, Value: bson.D{{Key: "$type", Value: "date"}}}},
+		options.FindOne().
+			SetSort(bson.D{{Key: field, Value: 1}}).
+			SetProjection(bson.D{{Key: field, Value: 1}}),
+	).Decode(&doc)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return time.Time{}, nil
+		}
+		return time.Time{}, err
+	}
+
+	value, err := doc.LookupErr(field)
+	if err != nil {
+		return time.Time{}, err
+	}
+	dt, ok := value.DateTimeOK()
+	if !ok {
+		return time.Time{}, nil
+	}
+	return time.UnixMilli(dt), nil
+}
+
+// StreamRange 按时间升序遍历 [from, to) 范围内的原始文档
+func (r *MongoRetentionRepository) StreamRange(ctx context.Context, collection, field string, from, to time.Time, fn func(bson.Raw) error) error {
+	filter := bson.D{{Key: field, Value: bson.D{
+		{Key: "$gte", Value: from},
+		{Key: "$lt", Value: to},
+	}}}
+
+	cursor, err := r.db.Collection(collection).Find(
+		ctx,
+		filter,
+		options.Find().SetSort(bson.D{{Key: field, Value: 1}}).SetBatchSize(1000),
+	)
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		if err := fn(cursor.Current); err != nil {
+			return err
+		}
+	}
+	return cursor.Err()
+}
+
+// InsertDocuments 批量写入文档，忽略重复主键错误，返回实际写入数量
+func (r *MongoRetentionRepository) InsertDocuments(ctx context.Context, collection string, docs []any) (int64, error) {
+	if len(docs) == 0 {
+		return 0, nil
+	}
+
+	result, err := r.db.Collection(collection).InsertMany(ctx, docs, options.InsertMany().SetOrdered(false))
+	inserted := int64(0)
+	if result != nil {
+		inserted = int64(len(result.InsertedIDs))
+	}
+	if err != nil {
+		var bulkErr mongo.BulkWriteException
+		if errors.As(err, &bulkErr) && onlyDuplicateKeyErrors(bulkErr) {
+			return int64(len(docs) - len(bulkErr.WriteErrors)), nil
+		}
+		return inserted, err
+	}
+	return inserted, nil
+}
+
+func onlyDuplicateKeyErrors(err mongo.BulkWriteException) bool {
+	if err.WriteConcernError != nil {
+		return false
+	}
+	for _, we := range err.WriteErrors {
+		if we.Code != 11000 {
+			return false
+		}
+	}
+	return true
+}
+
+func toInt64(v any) (int64, bool) {
+	switch n := v.(type) {
+	case int32:
+		return int64(n), true
+	case int64:
+		return n, true
+	case float64:
+		return int64(n), true
+	default:
+		return 0, false
+	}
+}
@@ -3,6 +3,8 @@ package repository
 import (
 	"context"
 	"errors"
+	"fmt"
+	"slices"
 	"time"
 
 	"github.com/HUAHUAI23/simple-waf/server/config"
@@ -25,6 +27,7 @@ type SiteRepository interface {
 	CreateSite(ctx context.Context, site *model.Site) error
 	GetSites(ctx context.Context, page, size int64) ([]model.Site, int64, error)
 	GetSiteByID(ctx context.Context, id bson.ObjectID) (*model.Site, error)
+	GetAllSites(ctx context.Context) ([]model.Site, error)
 	UpdateSite(ctx context.Context, site *model.Site) error
 	DeleteSite(ctx context.Context, id bson.ObjectID) error
 	CheckDomainPortExists(ctx context.Context, site *model.Site) error
@@ -152,40 +155,83 @@ func (r *MongoSiteRepository) DeleteSite(ctx context.Context, id bson.ObjectID)
 }
 
 func (r *MongoSiteRepository) CheckDomainPortExists(ctx context.Context, site *model.Site) error {
-	// 检查域名和端口组合是否已存在
-	filter := bson.D{
-		{Key: "domain", Value: site.Domain},
-		{Key: "listenPort", Value: site.ListenPort},
-	}
-	count, err := r.collection.CountDocuments(ctx, filter)
+	// 检查域名和别名是否与同端口的站点重叠
+	conflict, err := r.findHostConflict(ctx, site)
 	if err != nil {
 		r.logger.Error().Err(err).Msg("检查站点域名和端口是否存在时出错")
 		return err
 	}
-	if count > 0 {
-		r.logger.Error().Msg("站点域名和端口组合已存在")
-		return ErrDomainPortExists
+	if conflict != "" {
+		r.logger.Error().Str("conflict", conflict).Msg("站点域名和端口组合已存在")
+		return fmt.Errorf("%w: %s", ErrDomainPortExists, conflict)
 	}
 	return nil
 }
 
 func (r *MongoSiteRepository) CheckDomainPortConflict(ctx context.Context, site *model.Site) error {
-	// 检查域名和端口组合是否与其他站点冲突
+	// 检查域名和别名是否与同端口的其他站点重叠
+	conflict, err := r.findHostConflict(ctx, site)
+	if err != nil {
+		r.logger.Error().Err(err).Msg("检查站点域名和端口冲突时出错")
+		return err
+	}
+	if conflict != "" {
+		r.logger.Error().Str("conflict", conflict).Msg("更新站点失败，站点域名和端口组合已存在")
+		return fmt.Errorf("%w: %s", ErrDomainPortConflict, conflict)
+	}
+	return nil
+}
+
+// findHostConflict 查找同端口其他站点中与站点主机名重叠的域名，返回冲突说明
+// 通配符与它覆盖的域名也视为冲突，避免请求命中哪个站点取决于规则顺序；TCP 站点与同端口的任何站点冲突。
+// 同端口的站点共用监听，监听地址或 PROXY 协议可信来源不同也视为冲突
+func (r *MongoSiteRepository) findHostConflict(ctx context.Context, site *model.Site) (string, error) {
 	filter := bson.D{
 		{Key: "_id", Value: bson.D{{Key: "$ne", Value: site.ID}}},
-		{Key: "domain", Value: site.Domain},
 		{Key: "listenPort", Value: site.ListenPort},
 	}
-	count, err := r.collection.CountDocuments(ctx, filter)
+	cursor, err := r.collection.Find(ctx, filter, options.Find().SetProjection(bson.D{
+		{Key: "name", Value: 1},
+		{Key: "domain", Value: 1},
+		{Key: "aliases", Value: 1},
+		{Key: "mode", Value: 1},
+		{Key: "listenAddrs", Value: 1},
+		{Key: "proxy.trusted", Value: 1},
+	}))
 	if err != nil {
-		r.logger.Error().Err(err).Msg("检查站点域名和端口冲突时出错")
-		return err
+		return "", err
 	}
-	if count > 0 {
-		r.logger.Error().Msg("更新站点失败，站点域名和端口组合已存在")
-		return ErrDomainPortConflict
+	defer cursor.Close(ctx)
+
+	var others []model.Site
+	if err := cursor.All(ctx, &others); err != nil {
+		return "", err
 	}
-	return nil
+	for _, other := range others {
+		// TCP 站点独占监听端口
+		if site.IsTCP() || other.IsTCP() {
+			return fmt.Sprintf("端口 %d 已被站点 %s 使用，TCP 站点不能与其他站点共用端口", site.ListenPort, other.Name), nil
+		}
+		if !slices.Equal(site.ListenAddrs, other.ListenAddrs) {
+			return fmt.Sprintf("端口 %d 上的站点 %s 使用不同的监听地址，同端口的站点必须使用相同的监听地址", site.ListenPort, other.Name), nil
+		}
+		if !slices.Equal(site.TrustedProxies(), other.TrustedProxies()) {
+			return fmt.Sprintf("端口 %d 上的站点 %s 使用不同的 PROXY 协议可信来源，同端口的站点必须相同", site.ListenPort, other.Name), nil
+		}
+		for _, host := range site.Hosts() {
+			for _, otherHost := range other.Hosts() {
+				if model.HostsOverlap(host, otherHost) {
+					return fmt.Sprintf("%s 与站点 %s 的 %s 重叠", host, other.Name, otherHost), nil
+				}
+			}
+		}
+	}
+	return "", nil
+}
+
+// GetAllSites 获取所有站点，不分页
+func (r *MongoSiteRepository) GetAllSites(ctx context.Context) ([]model.Site, error) {
+	return GetAllSites(ctx, r.collection)
 }
 
 // GetAllSites 获取所有站点，不分页
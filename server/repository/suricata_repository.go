@@ -1,41 +1,160 @@
 package repository
 
 import (
-    "context"
-    "time"
+	"context"
+	"errors"
+	"time"
 
-    "github.com/kwrum1/waf/server/config"
-    "github.com/kwrum1/waf/server/model"
-    "go.mongodb.org/mongo-driver/bson"
-    "go.mongodb.org/mongo-driver/mongo/options"
+	"github.com/HUAHUAI23/simple-waf/server/config"
+	"github.com/HUAHUAI23/simple-waf/server/model"
+	"github.com/rs/zerolog"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
 )
 
 type SuricataRepository interface {
-    FindEvents(ctx context.Context, filter bson.M, limit int64) ([]model.SuricataEvent, error)
+	FindEvents(ctx context.Context, filter bson.D, limit int64) ([]model.SuricataEvent, error)
+	FindEventsAfterID(ctx context.Context, afterID bson.ObjectID, limit int64) ([]model.SuricataEvent, error)
+	GetLatestEventID(ctx context.Context) (bson.ObjectID, error)
+	InsertEvents(ctx context.Context, events []model.SuricataEvent) error
+	GetIngestCheckpoint(ctx context.Context, source string) (*model.IngestCheckpoint, error)
+	SaveIngestCheckpoint(ctx context.Context, checkpoint *model.IngestCheckpoint) error
 }
 
-type suricataRepositoryImpl struct{}
+type MongoSuricataRepository struct {
+	collection           *mongo.Collection
+	checkpointCollection *mongo.Collection
+	logger               zerolog.Logger
+}
+
+// NewSuricataRepository 创建 Suricata 事件仓库
+func NewSuricataRepository(db *mongo.Database) SuricataRepository {
+	var event model.SuricataEvent
+	collection := db.Collection(event.GetCollectionName())
+	logger := config.GetRepositoryLogger("suricata_event")
+
+	// 创建查询索引，timestamp 上的 TTL 索引由日志保留管理器维护
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
 
-func NewSuricataRepository() SuricataRepository {
-    return &suricataRepositoryImpl{}
+	_, err := collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "timestamp", Value: -1}}},
+		{Keys: bson.D{{Key: "src_ip", Value: 1}, {Key: "timestamp", Value: -1}}},
+		{Keys: bson.D{{Key: "event_type", Value: 1}, {Key: "timestamp", Value: -1}}},
+	})
+	if err != nil {
+		logger.Error().Err(err).Msg("创建 Suricata 事件索引失败")
+	}
+
+	var checkpoint model.IngestCheckpoint
+	return &MongoSuricataRepository{
+		collection:           collection,
+		checkpointCollection: db.Collection(checkpoint.GetCollectionName()),
+		logger:               logger,
+	}
 }
 
-func (r *suricataRepositoryImpl) FindEvents(ctx context.Context, filter bson.M, limit int64) ([]model.SuricataEvent, error) {
-    collection := config.GetMongoClient().
-        Database(config.DBName).
-        Collection("suricata_events")
+// FindEvents 按时间倒序查询 Suricata 事件
+func (r *MongoSuricataRepository) FindEvents(ctx context.Context, filter bson.D, limit int64) ([]model.SuricataEvent, error) {
+	findOpts := options.Find().SetSort(bson.D{{Key: "timestamp", Value: -1}}).SetLimit(limit)
 
-    findOpts := options.Find().SetSort(bson.M{"timestamp": -1}).SetLimit(limit)
+	cursor, err := r.collection.Find(ctx, filter, findOpts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
 
-    cursor, err := collection.Find(ctx, filter, findOpts)
-    if err != nil {
-        return nil, err
-    }
-    defer cursor.Close(ctx)
+	var results []model.SuricataEvent
+	if err = cursor.All(ctx, &results); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// FindEventsAfterID 按 _id 升序查询指定ID之后写入的事件，afterID 为零值时从头开始
+func (r *MongoSuricataRepository) FindEventsAfterID(ctx context.Context, afterID bson.ObjectID, limit int64) ([]model.SuricataEvent, error) {
+	filter := bson.D{}
+	if !afterID.IsZero() {
+		filter = bson.D{{Key: "_id", Value: bson.D{{Key: "$gt", Value: afterID}}}}
+	}
+	findOpts := options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}).SetLimit(limit)
+
+	cursor, err := r.collection.Find(ctx, filter, findOpts)
+	if err != nil {
+		r.logger.Error().Err(err).Msg("增量查询 Suricata 事件时出错")
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var results []model.SuricataEvent
+	if err = cursor.All(ctx, &results); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// GetLatestEventID 获取最新写入事件的ID，集合为空时返回零值
+func (r *MongoSuricataRepository) GetLatestEventID(ctx context.Context) (bson.ObjectID, error) {
+	var event model.SuricataEvent
+	err := r.collection.FindOne(
+		ctx,
+		bson.D{},
+		options.FindOne().SetSort(bson.D{{Key: "_id", Value: -1}}).SetProjection(bson.D{{Key: "_id", Value: 1}}),
+	).Decode(&event)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return bson.ObjectID{}, nil
+		}
+		return bson.ObjectID{}, err
+	}
+	return event.ID, nil
+}
+
+// InsertEvents 批量写入 Suricata 事件，重复主键的事件会被跳过
+func (r *MongoSuricataRepository) InsertEvents(ctx context.Context, events []model.SuricataEvent) error {
+	if len(events) == 0 {
+		return nil
+	}
+
+	docs := make([]any, len(events))
+	for i := range events {
+		docs[i] = events[i]
+	}
+
+	// 事件 _id 由调用方生成，重试时已写入的事件会产生重复主键错误，可以忽略
+	_, err := r.collection.InsertMany(ctx, docs, options.InsertMany().SetOrdered(false))
+	if err != nil {
+		var bulkErr mongo.BulkWriteException
+		if errors.As(err, &bulkErr) && onlyDuplicateKeyErrors(bulkErr) {
+			return nil
+		}
+		return err
+	}
+	return nil
+}
+
+// GetIngestCheckpoint 获取采集进度，不存在时返回 nil
+func (r *MongoSuricataRepository) GetIngestCheckpoint(ctx context.Context, source string) (*model.IngestCheckpoint, error) {
+	var checkpoint model.IngestCheckpoint
+	err := r.checkpointCollection.FindOne(ctx, bson.D{{Key: "_id", Value: source}}).Decode(&checkpoint)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &checkpoint, nil
+}
 
-    var results []model.SuricataEvent
-    if err = cursor.All(ctx, &results); err != nil {
-        return nil, err
-    }
-    return results, nil
+// SaveIngestCheckpoint 保存采集进度
+func (r *MongoSuricataRepository) SaveIngestCheckpoint(ctx context.Context, checkpoint *model.IngestCheckpoint) error {
+	checkpoint.UpdatedAt = time.Now()
+	_, err := r.checkpointCollection.ReplaceOne(
+		ctx,
+		bson.D{{Key: "_id", Value: checkpoint.ID}},
+		checkpoint,
+		options.Replace().SetUpsert(true),
+	)
+	return err
 }
@@ -0,0 +1,197 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/HUAHUAI23/simple-waf/server/config"
+	"github.com/HUAHUAI23/simple-waf/server/model"
+	"github.com/rs/zerolog"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+var (
+	ErrSuricataRuleNotFound = errors.New("自定义规则不存在")
+	ErrDuplicateSID         = errors.New("规则SID已存在")
+)
+
+// SuricataRuleRepository Suricata 自定义规则和启用状态仓库
+type SuricataRuleRepository interface {
+	CreateLocalRule(ctx context.Context, rule *model.SuricataLocalRule) error
+	UpdateLocalRule(ctx context.Context, rule *model.SuricataLocalRule) error
+	DeleteLocalRule(ctx context.Context, id bson.ObjectID) error
+	GetLocalRuleByID(ctx context.Context, id bson.ObjectID) (*model.SuricataLocalRule, error)
+	GetLocalRuleBySID(ctx context.Context, sid int) (*model.SuricataLocalRule, error)
+	ListLocalRules(ctx context.Context) ([]model.SuricataLocalRule, error)
+	SetOverride(ctx context.Context, sid int, enabled bool) error
+	ListOverrides(ctx context.Context) (map[int]bool, error)
+}
+
+// MongoSuricataRuleRepository MongoDB实现的 Suricata 规则仓库
+type MongoSuricataRuleRepository struct {
+	localCollection    *mongo.Collection
+	overrideCollection *mongo.Collection
+	logger             zerolog.Logger
+}
+
+// NewSuricataRuleRepository 创建 Suricata 规则仓库
+func NewSuricataRuleRepository(db *mongo.Database) SuricataRuleRepository {
+	var localRule model.SuricataLocalRule
+	var override model.SuricataRuleOverride
+	localCollection := db.Collection(localRule.GetCollectionName())
+	logger := config.GetRepositoryLogger("suricata_rule")
+
+	// 创建索引
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := localCollection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "sid", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		logger.Error().Err(err).Msg("创建自定义规则索引失败")
+	}
+
+	return &MongoSuricataRuleRepository{
+		localCollection:    localCollection,
+		overrideCollection: db.Collection(override.GetCollectionName()),
+		logger:             logger,
+	}
+}
+
+// CreateLocalRule 创建自定义规则
+func (r *MongoSuricataRuleRepository) CreateLocalRule(ctx context.Context, rule *model.SuricataLocalRule) error {
+	now := time.Now()
+	rule.CreatedAt = now
+	rule.UpdatedAt = now
+
+	result, err := r.localCollection.InsertOne(ctx, rule)
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return ErrDuplicateSID
+		}
+		r.logger.Error().Err(err).Int("sid", rule.SID).Msg("插入自定义规则时出错")
+		return err
+	}
+
+	if id, ok := result.InsertedID.(bson.ObjectID); ok {
+		rule.ID = id
+	}
+	return nil
+}
+
+// UpdateLocalRule 更新自定义规则
+func (r *MongoSuricataRuleRepository) UpdateLocalRule(ctx context.Context, rule *model.SuricataLocalRule) error {
+	rule.UpdatedAt = time.Now()
+
+	result, err := r.localCollection.UpdateOne(
+		ctx,
+		bson.D{{Key: "_id", Value: rule.ID}},
+		bson.D{{Key: "$set", Value: bson.D{
+			{Key: "sid", Value: rule.SID},
+			{Key: "rule", Value: rule.Rule},
+			{Key: "description", Value: rule.Description},
+			{Key: "enabled", Value: rule.Enabled},
+			{Key: "updatedAt", Value: rule.UpdatedAt},
+		}}},
+	)
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return ErrDuplicateSID
+		}
+		r.logger.Error().Err(err).Str("id", rule.ID.Hex()).Msg("更新自定义规则时出错")
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrSuricataRuleNotFound
+	}
+	return nil
+}
+
+// DeleteLocalRule 删除自定义规则
+func (r *MongoSuricataRuleRepository) DeleteLocalRule(ctx context.Context, id bson.ObjectID) error {
+	result, err := r.localCollection.DeleteOne(ctx, bson.D{{Key: "_id", Value: id}})
+	if err != nil {
+		r.logger.Error().Err(err).Str("id", id.Hex()).Msg("删除自定义规则时出错")
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return ErrSuricataRuleNotFound
+	}
+	return nil
+}
+
+// GetLocalRuleByID 根据ID获取自定义规则
+func (r *MongoSuricataRuleRepository) GetLocalRuleByID(ctx context.Context, id bson.ObjectID) (*model.SuricataLocalRule, error) {
+	return r.findOne(ctx, bson.D{{Key: "_id", Value: id}})
+}
+
+// GetLocalRuleBySID 根据SID获取自定义规则
+func (r *MongoSuricataRuleRepository) GetLocalRuleBySID(ctx context.Context, sid int) (*model.SuricataLocalRule, error) {
+	return r.findOne(ctx, bson.D{{Key: "sid", Value: sid}})
+}
+
+func (r *MongoSuricataRuleRepository) findOne(ctx context.Context, filter bson.D) (*model.SuricataLocalRule, error) {
+	var rule model.SuricataLocalRule
+	err := r.localCollection.FindOne(ctx, filter).Decode(&rule)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, ErrSuricataRuleNotFound
+		}
+		return nil, err
+	}
+	return &rule, nil
+}
+
+// ListLocalRules 按 SID 升序获取所有自定义规则
+func (r *MongoSuricataRuleRepository) ListLocalRules(ctx context.Context) ([]model.SuricataLocalRule, error) {
+	cursor, err := r.localCollection.Find(ctx, bson.D{}, options.Find().SetSort(bson.D{{Key: "sid", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var rules []model.SuricataLocalRule
+	if err := cursor.All(ctx, &rules); err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+// SetOverride 设置导入规则集中某条规则的启用状态
+func (r *MongoSuricataRuleRepository) SetOverride(ctx context.Context, sid int, enabled bool) error {
+	_, err := r.overrideCollection.UpdateOne(
+		ctx,
+		bson.D{{Key: "_id", Value: sid}},
+		bson.D{{Key: "$set", Value: bson.D{
+			{Key: "enabled", Value: enabled},
+			{Key: "updatedAt", Value: time.Now()},
+		}}},
+		options.UpdateOne().SetUpsert(true),
+	)
+	return err
+}
+
+// ListOverrides 获取所有启用状态覆盖，键为 SID
+func (r *MongoSuricataRuleRepository) ListOverrides(ctx context.Context) (map[int]bool, error) {
+	cursor, err := r.overrideCollection.Find(ctx, bson.D{})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var overrides []model.SuricataRuleOverride
+	if err := cursor.All(ctx, &overrides); err != nil {
+		return nil, err
+	}
+
+	result := make(map[int]bool, len(overrides))
+	for _, o := range overrides {
+		result[o.SID] = o.Enabled
+	}
+	return result, nil
+}
@@ -21,6 +21,11 @@ type WAFLogRepository interface {
 	CountAggregateAttackEvents(ctx context.Context, pipeline mongo.Pipeline) (int64, error)
 	FindAttackLogs(ctx context.Context, filter bson.D, skip int64, limit int64) ([]model.WAFLog, error)
 	CountAttackLogs(ctx context.Context, filter bson.D) (int64, error)
+	AggregateSrcIPCounts(ctx context.Context, filter bson.D, minCount int) ([]dto.SrcIPCountResult, error)
+	AggregateDomainPortCounts(ctx context.Context, filter bson.D) ([]dto.WAFDetectionCount, error)
+	StreamAttackLogs(ctx context.Context, filter bson.D, fn func(*model.WAFLog) error) error
+	FindAttackLogsAfterID(ctx context.Context, afterID bson.ObjectID, limit int64) ([]model.WAFLog, error)
+	GetLatestAttackLogID(ctx context.Context) (bson.ObjectID, error)
 }
 
 type MongoWAFLogRepository struct {
@@ -34,6 +39,23 @@ func NewWAFLogRepository(db *mongo.Database) WAFLogRepository {
 	collection := db.Collection(wafLog.GetCollectionName())
 	logger := config.GetRepositoryLogger("waf_log")
 
+	// Create the indexes used by buildAttackLogFilter and the attack event aggregations.
+	// The TTL index on createdAt is managed separately by the retention manager.
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "createdAt", Value: -1}, {Key: "_id", Value: -1}}},
+		{Keys: bson.D{{Key: "srcIp", Value: 1}, {Key: "createdAt", Value: -1}}},
+		{Keys: bson.D{{Key: "domain", Value: 1}, {Key: "createdAt", Value: -1}}},
+		{Keys: bson.D{{Key: "ruleId", Value: 1}, {Key: "createdAt", Value: -1}}},
+		{Keys: bson.D{{Key: "requestId", Value: 1}}},
+		{Keys: bson.D{{Key: "uri", Value: 1}}},
+	})
+	if err != nil {
+		logger.Error().Err(err).Msg("Failed to create waf_log indexes")
+	}
+
 	return &MongoWAFLogRepository{
 		collection: collection,
 		logger:     logger,
@@ -145,7 +167,7 @@ func (r *MongoWAFLogRepository) FindAttackLogs(
 	findOptions := options.Find().
 		SetSkip(skip).
 		SetLimit(limit).
-		SetSort(bson.D{{Key: "createdAt", Value: -1}}) // 最近的优先
+		SetSort(bson.D{{Key: "createdAt", Value: -1}, {Key: "_id", Value: -1}}) // 最近的优先，_id 保证顺序稳定
 
 	cursor, err := r.collection.Find(ctx, filter, findOptions)
 	if err != nil {
@@ -161,6 +183,40 @@ func (r *MongoWAFLogRepository) FindAttackLogs(
 	return results, nil
 }
 
+// StreamAttackLogs iterates over every attack log matching the filter, newest first,
+// calling fn for each document without loading the result set into memory
+func (r *MongoWAFLogRepository) StreamAttackLogs(
+	ctx context.Context,
+	filter bson.D,
+	fn func(*model.WAFLog) error,
+) error {
+	findOptions := options.Find().
+		SetBatchSize(500).
+		SetSort(bson.D{{Key: "createdAt", Value: -1}})
+
+	cursor, err := r.collection.Find(ctx, filter, findOptions)
+	if err != nil {
+		return fmt.Errorf("error executing find query: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var wafLog model.WAFLog
+		if err := cursor.Decode(&wafLog); err != nil {
+			return fmt.Errorf("error decoding attack log: %w", err)
+		}
+		if err := fn(&wafLog); err != nil {
+			return err
+		}
+	}
+
+	if err := cursor.Err(); err != nil {
+		return fmt.Errorf("cursor error: %w", err)
+	}
+
+	return nil
+}
+
 // CountAttackLogs counts the total number of attack logs matching the filter
 func (r *MongoWAFLogRepository) CountAttackLogs(ctx context.Context, filter bson.D) (int64, error) {
 	total, err := r.collection.CountDocuments(ctx, filter)
@@ -170,6 +226,78 @@ func (r *MongoWAFLogRepository) CountAttackLogs(ctx context.Context, filter bson
 	return total, nil
 }
 
+// AggregateSrcIPCounts groups matching attack logs by source IP and returns
+// the IPs whose hit count is at least minCount, highest first
+func (r *MongoWAFLogRepository) AggregateSrcIPCounts(
+	ctx context.Context,
+	filter bson.D,
+	minCount int,
+) ([]dto.SrcIPCountResult, error) {
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: filter}},
+		{{Key: "$group", Value: bson.D{
+			{Key: "_id", Value: "$srcIp"},
+			{Key: "count", Value: bson.D{{Key: "$sum", Value: 1}}},
+			{Key: "lastAttackTime", Value: bson.D{{Key: "$max", Value: "$createdAt"}}},
+		}}},
+		{{Key: "$match", Value: bson.D{{Key: "count", Value: bson.D{{Key: "$gte", Value: minCount}}}}}},
+		{{Key: "$project", Value: bson.D{
+			{Key: "_id", Value: 0},
+			{Key: "srcIp", Value: "$_id"},
+			{Key: "count", Value: 1},
+			{Key: "lastAttackTime", Value: 1},
+		}}},
+		{{Key: "$sort", Value: bson.D{{Key: "count", Value: -1}}}},
+	}
+
+	cursor, err := r.collection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, fmt.Errorf("error executing source ip aggregation: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	var results []dto.SrcIPCountResult
+	if err := cursor.All(ctx, &results); err != nil {
+		return nil, fmt.Errorf("error decoding source ip aggregation: %w", err)
+	}
+
+	return results, nil
+}
+
+// AggregateDomainPortCounts groups matching attack logs by domain and
+// destination port, which together identify the site that handled the request
+func (r *MongoWAFLogRepository) AggregateDomainPortCounts(
+	ctx context.Context,
+	filter bson.D,
+) ([]dto.WAFDetectionCount, error) {
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: filter}},
+		{{Key: "$group", Value: bson.D{
+			{Key: "_id", Value: bson.D{{Key: "domain", Value: "$domain"}, {Key: "dstPort", Value: "$dstPort"}}},
+			{Key: "count", Value: bson.D{{Key: "$sum", Value: 1}}},
+		}}},
+		{{Key: "$project", Value: bson.D{
+			{Key: "_id", Value: 0},
+			{Key: "domain", Value: "$_id.domain"},
+			{Key: "dstPort", Value: "$_id.dstPort"},
+			{Key: "count", Value: 1},
+		}}},
+	}
+
+	cursor, err := r.collection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, fmt.Errorf("error executing domain aggregation: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	var results []dto.WAFDetectionCount
+	if err := cursor.All(ctx, &results); err != nil {
+		return nil, fmt.Errorf("error decoding domain aggregation: %w", err)
+	}
+
+	return results, nil
+}
+
 // calculateAttackDuration calculates the duration of a continuous attack
 // by finding the longest sequence of attacks with gaps no larger than 5 minutes
 func (r *MongoWAFLogRepository) calculateAttackDuration(attackTimes []time.Time) float64 {
@@ -227,3 +355,49 @@ func (r *MongoWAFLogRepository) calculateAttackDuration(attackTimes []time.Time)
 	// 返回整数分钟数（向上取整）
 	return math.Ceil(duration)
 }
+
+// FindAttackLogsAfterID returns attack logs inserted after afterID in _id order,
+// starting from the beginning of the collection when afterID is zero
+func (r *MongoWAFLogRepository) FindAttackLogsAfterID(ctx context.Context, afterID bson.ObjectID, limit int64) ([]model.WAFLog, error) {
+	filter := bson.D{}
+	if !afterID.IsZero() {
+		filter = bson.D{{Key: "_id", Value: bson.D{{Key: "$gt", Value: afterID}}}}
+	}
+	findOptions := options.Find().
+		SetLimit(limit).
+		SetSort(bson.D{{Key: "_id", Value: 1}})
+
+	cursor, err := r.collection.Find(ctx, filter, findOptions)
+	if err != nil {
+		return nil, fmt.Errorf("error executing find query: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	var results []model.WAFLog
+	if err := cursor.All(ctx, &results); err != nil {
+		return nil, fmt.Errorf("error decoding query results: %w", err)
+	}
+
+	return results, nil
+}
+
+// GetLatestAttackLogID returns the _id of the most recently inserted attack log,
+// or a zero ObjectID when the collection is empty
+func (r *MongoWAFLogRepository) GetLatestAttackLogID(ctx context.Context) (bson.ObjectID, error) {
+	var wafLog model.WAFLog
+	err := r.collection.FindOne(
+		ctx,
+		bson.D{},
+		options.FindOne().
+			SetSort(bson.D{{Key: "_id", Value: -1}}).
+			SetProjection(bson.D{{Key: "_id", Value: 1}}),
+	).Decode(&wafLog)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return bson.ObjectID{}, nil
+		}
+		return bson.ObjectID{}, fmt.Errorf("error finding latest attack log: %w", err)
+	}
+
+	return wafLog.ID, nil
+}
@@ -4,12 +4,15 @@ import (
     "errors"
     "strings"
 
-    "github.com/kwrum1/waf/server/controller"
-    "github.com/kwrum1/waf/server/middleware"
-    "github.com/kwrum1/waf/server/model"
-    "github.com/kwrum1/waf/server/repository"
-    "github.com/kwrum1/waf/server/service"
-    "github.com/kwrum1/waf/server/utils/response"
+    "github.com/HUAHUAI23/simple-waf/server/controller"
+    "github.com/HUAHUAI23/simple-waf/server/middleware"
+    "github.com/HUAHUAI23/simple-waf/server/model"
+    "github.com/HUAHUAI23/simple-waf/server/repository"
+    "github.com/HUAHUAI23/simple-waf/server/service"
+    "github.com/HUAHUAI23/simple-waf/server/service/daemon/accesslog"
+    "github.com/HUAHUAI23/simple-waf/server/service/daemon/ban"
+    "github.com/HUAHUAI23/simple-waf/server/service/daemon/eve"
+    "github.com/HUAHUAI23/simple-waf/server/utils/response"
 
     "github.com/gin-gonic/gin"
     "go.mongodb.org/mongo-driver/v2/mongo"
@@ -30,6 +33,11 @@ func Setup(route *gin.Engine, db *mongo.Database) {
     wafLogRepo := repository.NewWAFLogRepository(db)
     certRepo := repository.NewCertificateRepository(db)
     configRepo := repository.NewConfigRepository(db)
+    auditLogRepo := repository.NewAuditLogRepository(db)
+    alertRuleRepo := repository.NewAlertRuleRepository(db)
+    alertHistoryRepo := repository.NewAlertHistoryRepository(db)
+    retentionRepo := repository.NewRetentionRepository(db)
+    accessLogRepo := repository.NewAccessLogRepository(db)
 
     // 创建服务
     authService := service.NewAuthService(userRepo, roleRepo)
@@ -38,14 +46,24 @@ func Setup(route *gin.Engine, db *mongo.Database) {
     certService := service.NewCertificateService(certRepo)
     runnerService, _ := service.NewRunnerService()
     configService := service.NewConfigService(configRepo)
+    auditService := service.NewAuditService(auditLogRepo)
+    alertService := service.NewAlertService(alertRuleRepo, alertHistoryRepo)
+    retentionService := service.NewRetentionService(configRepo, retentionRepo)
+    systemService := service.NewSystemService(siteRepo, runnerService)
+    accessLogService := service.NewAccessLogService(accessLogRepo, wafLogRepo, siteRepo, accesslog.GetReceiver(db))
 
     // 创建控制器
     authController := controller.NewAuthController(authService)
-    siteController := controller.NewSiteController(siteService)
-    wafLogController := controller.NewWAFLogController(wafLogService)
+    siteController := controller.NewSiteController(siteService, runnerService)
+    wafLogController := controller.NewWAFLogController(wafLogService, auditService)
     certController := controller.NewCertificateController(certService)
-    runnerController := controller.NewRunnerController(runnerService)
+    runnerController := controller.NewRunnerController(runnerService, auditService)
     configController := controller.NewConfigController(configService)
+    auditController := controller.NewAuditController(auditService)
+    alertController := controller.NewAlertController(alertService)
+    retentionController := controller.NewRetentionController(retentionService, auditService)
+    systemController := controller.NewSystemController(systemService)
+    accessLogController := controller.NewAccessLogController(accessLogService)
 
     // 将仓库添加到上下文中，供中间件使用
     route.Use(func(c *gin.Context) {
@@ -104,6 +122,9 @@ func Setup(route *gin.Engine, db *mongo.Database) {
         siteRoutes.POST("", middleware.HasPermission(model.PermSiteCreate), siteController.CreateSite)
         siteRoutes.GET("", middleware.HasPermission(model.PermSiteRead), siteController.GetSites)
         siteRoutes.GET("/:id", middleware.HasPermission(model.PermSiteRead), siteController.GetSiteByID)
+        siteRoutes.GET("/:id/health", middleware.HasPermission(model.PermSiteRead), siteController.GetSiteHealth)
+        siteRoutes.GET("/:id/servers/:index", middleware.HasPermission(model.PermSiteRead), siteController.GetServerState)
+        siteRoutes.PUT("/:id/servers/:index", middleware.HasPermission(model.PermSiteUpdate), siteController.UpdateServerState)
         siteRoutes.PUT("/:id", middleware.HasPermission(model.PermSiteUpdate), siteController.UpdateSite)
         siteRoutes.DELETE("/:id", middleware.HasPermission(model.PermSiteDelete), siteController.DeleteSite)
     }
@@ -123,6 +144,8 @@ func Setup(route *gin.Engine, db *mongo.Database) {
     {
         wafLogRoutes.GET("/event", middleware.HasPermission(model.PermWAFLogRead), wafLogController.GetAttackEvents)
         wafLogRoutes.GET("", middleware.HasPermission(model.PermWAFLogRead), wafLogController.GetAttackLogs)
+        wafLogRoutes.GET("/export", middleware.HasPermission(model.PermWAFLogRead), wafLogController.ExportAttackLogs)
+        wafLogRoutes.GET("/access/analytics", middleware.HasPermission(model.PermWAFLogRead), accessLogController.GetAnalytics)
     }
 
     // 配置管理模块
@@ -130,6 +153,9 @@ func Setup(route *gin.Engine, db *mongo.Database) {
     {
         runnerRoutes.GET("/status", middleware.HasPermission(model.PermConfigRead), runnerController.GetStatus)
         runnerRoutes.POST("/control", middleware.HasPermission(model.PermConfigUpdate), runnerController.Control)
+        runnerRoutes.GET("/haproxy/versions", middleware.HasPermission(model.PermConfigRead), runnerController.ListConfigVersions)
+        runnerRoutes.GET("/haproxy/versions/:version", middleware.HasPermission(model.PermConfigRead), runnerController.GetConfigVersion)
+        runnerRoutes.POST("/haproxy/versions/:version/rollback", middleware.HasPermission(model.PermConfigUpdate), runnerController.RollbackConfig)
     }
     configRoutes := authenticated.Group("/config")
     {
@@ -137,23 +163,88 @@ func Setup(route *gin.Engine, db *mongo.Database) {
         configRoutes.PATCH("", middleware.HasPermission(model.PermConfigUpdate), configController.PatchConfig)
     }
 
+    // 日志保留模块
+    retentionRoutes := authenticated.Group("/retention")
+    {
+        retentionRoutes.GET("/archives", middleware.HasPermission(model.PermConfigRead), retentionController.ListArchives)
+        retentionRoutes.POST("/restore", middleware.HasPermission(model.PermConfigUpdate), retentionController.RestoreArchive)
+    }
+
+    // 告警管理模块
+    alertRoutes := authenticated.Group("/alert")
+    {
+        alertRoutes.POST("/rule", middleware.HasPermission(model.PermAlertCreate), alertController.CreateAlertRule)
+        alertRoutes.GET("/rule", middleware.HasPermission(model.PermAlertRead), alertController.GetAlertRules)
+        alertRoutes.GET("/rule/:id", middleware.HasPermission(model.PermAlertRead), alertController.GetAlertRuleByID)
+        alertRoutes.PUT("/rule/:id", middleware.HasPermission(model.PermAlertUpdate), alertController.UpdateAlertRule)
+        alertRoutes.DELETE("/rule/:id", middleware.HasPermission(model.PermAlertDelete), alertController.DeleteAlertRule)
+        alertRoutes.GET("/history", middleware.HasPermission(model.PermAlertRead), alertController.GetAlertHistory)
+        alertRoutes.POST("/channel/test", middleware.HasPermission(model.PermAlertUpdate), alertController.TestChannel)
+    }
+
     // Suricata 事件查询路由
-    suriRepo := repository.NewSuricataRepository()
-    suriSvc  := service.NewSuricataService(suriRepo)
+    suriRepo := repository.NewSuricataRepository(db)
+    suriSvc  := service.NewSuricataService(suriRepo, eve.GetIngestor(db))
     suriCtrl := controller.NewSuricataController(suriSvc)
     suriAPI  := api.Group("/suricata")
     suriAPI.GET("/events", suriCtrl.ListEvents)
+    authenticated.GET("/suricata/ingest/health", middleware.HasPermission(model.PermConfigRead), suriCtrl.IngestHealth)
+
+    // Suricata 规则管理模块
+    suriRuleRepo := repository.NewSuricataRuleRepository(db)
+    suriRuleSvc := service.NewSuricataRuleService(suriRuleRepo)
+    suriRuleCtrl := controller.NewSuricataRuleController(suriRuleSvc)
+    suriRuleRoutes := authenticated.Group("/suricata/rules")
+    {
+        suriRuleRoutes.GET("", middleware.HasPermission(model.PermIDSRuleRead), suriRuleCtrl.ListRules)
+        suriRuleRoutes.GET("/stats", middleware.HasPermission(model.PermIDSRuleRead), suriRuleCtrl.GetStats)
+        suriRuleRoutes.PUT("/:sid/state", middleware.HasPermission(model.PermIDSRuleUpdate), suriRuleCtrl.SetRuleState)
+        suriRuleRoutes.POST("/local", middleware.HasPermission(model.PermIDSRuleUpdate), suriRuleCtrl.CreateLocalRule)
+        suriRuleRoutes.PUT("/local/:id", middleware.HasPermission(model.PermIDSRuleUpdate), suriRuleCtrl.UpdateLocalRule)
+        suriRuleRoutes.DELETE("/local/:id", middleware.HasPermission(model.PermIDSRuleUpdate), suriRuleCtrl.DeleteLocalRule)
+        suriRuleRoutes.POST("/import", middleware.HasPermission(model.PermIDSRuleUpdate), suriRuleCtrl.ImportRuleset)
+        suriRuleRoutes.POST("/reload", middleware.HasPermission(model.PermIDSRuleUpdate), suriRuleCtrl.Reload)
+    }
+
+    // 安全事件模块
+    incidentRepo := repository.NewIncidentRepository(db)
+    incidentSvc := service.NewIncidentService(incidentRepo, wafLogRepo, suriRepo, accessLogRepo, userRepo)
+    incidentCtrl := controller.NewIncidentController(incidentSvc)
+    incidentRoutes := authenticated.Group("/incidents")
+    {
+        incidentRoutes.GET("", middleware.HasPermission(model.PermIncidentRead), incidentCtrl.ListIncidents)
+        incidentRoutes.GET("/:id", middleware.HasPermission(model.PermIncidentRead), incidentCtrl.GetIncident)
+        incidentRoutes.GET("/:id/timeline", middleware.HasPermission(model.PermIncidentRead), incidentCtrl.GetTimeline)
+        incidentRoutes.PATCH("/:id", middleware.HasPermission(model.PermIncidentUpdate), incidentCtrl.UpdateIncident)
+        incidentRoutes.POST("/:id/comments", middleware.HasPermission(model.PermIncidentUpdate), incidentCtrl.AddComment)
+    }
+
+    // 自动封禁模块
+    banSvc := service.NewBanService(repository.NewBanPolicyRepository(db), repository.NewBanRepository(db), ban.GetManager(db))
+    banCtrl := controller.NewBanController(banSvc, auditService)
+    banRoutes := authenticated.Group("/ban")
+    {
+        banRoutes.POST("/policy", middleware.HasPermission(model.PermBanUpdate), banCtrl.CreateBanPolicy)
+        banRoutes.GET("/policy", middleware.HasPermission(model.PermBanRead), banCtrl.GetBanPolicies)
+        banRoutes.GET("/policy/:id", middleware.HasPermission(model.PermBanRead), banCtrl.GetBanPolicyByID)
+        banRoutes.PUT("/policy/:id", middleware.HasPermission(model.PermBanUpdate), banCtrl.UpdateBanPolicy)
+        banRoutes.DELETE("/policy/:id", middleware.HasPermission(model.PermBanUpdate), banCtrl.DeleteBanPolicy)
+        banRoutes.GET("/record", middleware.HasPermission(model.PermBanRead), banCtrl.ListBans)
+        banRoutes.POST("/record", middleware.HasPermission(model.PermBanUpdate), banCtrl.CreateBan)
+        banRoutes.GET("/record/:id", middleware.HasPermission(model.PermBanRead), banCtrl.GetBan)
+        banRoutes.POST("/record/:id/revoke", middleware.HasPermission(model.PermBanUpdate), banCtrl.RevokeBan)
+    }
 
     // 审计日志模块
     auditRoutes := authenticated.Group("/audit")
     {
-        auditRoutes.GET("", middleware.HasPermission(model.PermAuditRead), nil)
+        auditRoutes.GET("", middleware.HasPermission(model.PermAuditRead), auditController.GetAuditLogs)
     }
 
     // 系统管理模块
     systemRoutes := authenticated.Group("/system")
     {
-        systemRoutes.GET("/status", middleware.HasPermission(model.PermSystemStatus), nil)
+        systemRoutes.GET("/status", middleware.HasPermission(model.PermSystemStatus), systemController.GetStatus)
         systemRoutes.POST("/restart", middleware.HasPermission(model.PermSystemRestart), nil)
     }
 
@@ -0,0 +1,205 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/HUAHUAI23/simple-waf/server/config"
+	"github.com/HUAHUAI23/simple-waf/server/dto"
+	"github.com/HUAHUAI23/simple-waf/server/repository"
+	"github.com/HUAHUAI23/simple-waf/server/service/daemon/accesslog"
+	"github.com/rs/zerolog"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// AccessLogService 访问流量统计服务接口
+type AccessLogService interface {
+	GetAnalytics(ctx context.Context, req dto.AccessLogAnalyticsRequest) (*dto.AccessLogAnalyticsResponse, error)
+}
+
+// AccessLogServiceImpl 访问流量统计服务实现
+type AccessLogServiceImpl struct {
+	accessLogRepo repository.AccessLogRepository
+	wafLogRepo    repository.WAFLogRepository
+	siteRepo      repository.SiteRepository
+	receiver      accesslog.Receiver
+	logger        zerolog.Logger
+}
+
+// NewAccessLogService 创建访问流量统计服务
+func NewAccessLogService(
+	accessLogRepo repository.AccessLogRepository,
+	wafLogRepo repository.WAFLogRepository,
+	siteRepo repository.SiteRepository,
+	receiver accesslog.Receiver,
+) AccessLogService {
+	logger := config.GetServiceLogger("access_log")
+	return &AccessLogServiceImpl{
+		accessLogRepo: accessLogRepo,
+		wafLogRepo:    wafLogRepo,
+		siteRepo:      siteRepo,
+		receiver:      receiver,
+		logger:        logger,
+	}
+}
+
+// GetAnalytics 按站点统计访问流量，并与同期 WAF 检测数对比
+//
+// WAF 日志记录的是请求的主机名和目标端口，按接收器相同的规则归属到站点后再与请求量对比。
+func (s *AccessLogServiceImpl) GetAnalytics(ctx context.Context, req dto.AccessLogAnalyticsRequest) (*dto.AccessLogAnalyticsResponse, error) {
+	filter := bson.D{{Key: "timestamp", Value: bson.D{
+		{Key: "$gte", Value: req.StartTime.UTC()},
+		{Key: "$lte", Value: req.EndTime.UTC()},
+	}}}
+	if req.Domain != "" {
+		filter = append(filter, bson.E{Key: "domain", Value: req.Domain})
+	}
+
+	summaries, err := s.accessLogRepo.AggregateSiteSummary(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	buckets, err := s.accessLogRepo.AggregateLatencyBuckets(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	paths, err := s.accessLogRepo.AggregateTopPaths(ctx, filter, req.TopN)
+	if err != nil {
+		return nil, err
+	}
+	detections, err := s.countDetections(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	bucketsByDomain := make(map[string][]dto.AccessLogLatencyBucket)
+	for _, b := range buckets {
+		bucketsByDomain[b.Domain] = append(bucketsByDomain[b.Domain], b)
+	}
+	pathsByDomain := make(map[string][]dto.PathCount)
+	for _, p := range paths {
+		pathsByDomain[p.Domain] = append(pathsByDomain[p.Domain], dto.PathCount{Path: p.Path, Count: p.Count})
+	}
+
+	sites := make([]dto.SiteTrafficStats, 0, len(summaries))
+	for _, sum := range summaries {
+		stats := dto.SiteTrafficStats{
+			Domain:   sum.Domain,
+			Requests: sum.Total,
+			Bytes:    sum.Bytes,
+			Status: dto.StatusDistribution{
+				Status1xx: sum.Status1xx,
+				Status2xx: sum.Status2xx,
+				Status3xx: sum.Status3xx,
+				Status4xx: sum.Status4xx,
+				Status5xx: sum.Status5xx,
+			},
+			Latency:  latencyPercentiles(bucketsByDomain[sum.Domain]),
+			TopPaths: pathsByDomain[sum.Domain],
+		}
+		stats.Latency.Avg = sum.AvgRequestTime
+		if stats.TopPaths == nil {
+			stats.TopPaths = []dto.PathCount{}
+		}
+		if sum.Domain != "" {
+			stats.WAFDetections = detections[sum.Domain]
+			delete(detections, sum.Domain)
+		}
+		if stats.Requests > 0 {
+			stats.DetectionRate = float64(stats.WAFDetections) / float64(stats.Requests)
+		}
+		sites = append(sites, stats)
+	}
+	// 有检测但没有访问日志的站点，如接收器启用前产生的检测
+	for domain, count := range detections {
+		sites = append(sites, dto.SiteTrafficStats{Domain: domain, TopPaths: []dto.PathCount{}, WAFDetections: count})
+	}
+	sort.SliceStable(sites, func(i, j int) bool { return sites[i].Requests > sites[j].Requests })
+
+	return &dto.AccessLogAnalyticsResponse{
+		StartTime: req.StartTime,
+		EndTime:   req.EndTime,
+		Sites:     sites,
+		Receiver:  s.receiverStatus(),
+	}, nil
+}
+
+// countDetections 统计时间范围内各站点的 WAF 检测数，按站点主域名返回
+func (s *AccessLogServiceImpl) countDetections(ctx context.Context, req dto.AccessLogAnalyticsRequest) (map[string]int64, error) {
+	filter := bson.D{{Key: "createdAt", Value: bson.D{
+		{Key: "$gte", Value: req.StartTime.UTC()},
+		{Key: "$lte", Value: req.EndTime.UTC()},
+	}}}
+	counts, err := s.wafLogRepo.AggregateDomainPortCounts(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	sites, err := s.siteRepo.GetAllSites(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("获取站点列表失败: %w", err)
+	}
+
+	resolver := accesslog.NewSiteResolver(sites)
+	result := make(map[string]int64)
+	for _, c := range counts {
+		site := resolver.Resolve(c.DstPort, c.Domain)
+		if site == nil || (req.Domain != "" && site.Domain != req.Domain) {
+			continue
+		}
+		result[site.Domain] += c.Count
+	}
+	return result, nil
+}
+
+func (s *AccessLogServiceImpl) receiverStatus() dto.AccessLogReceiverStatus {
+	stats := s.receiver.Stats()
+	status := dto.AccessLogReceiverStatus{
+		State:            stats.State,
+		Address:          stats.Address,
+		MessagesReceived: stats.MessagesReceived,
+		LogsInserted:     stats.LogsInserted,
+		LogsSkipped:      stats.LogsSkipped,
+		LogsUnmatched:    stats.LogsUnmatched,
+		LogsDropped:      stats.LogsDropped,
+		LastLogAt:        timePtr(stats.LastLogAt),
+		LastError:        stats.LastError,
+	}
+	if status.State == "" {
+		status.State = accesslog.StateStopped
+	}
+	return status
+}
+
+// latencyPercentiles 根据按耗时升序排列的直方图计算近似分位数
+func latencyPercentiles(buckets []dto.AccessLogLatencyBucket) dto.LatencyPercentiles {
+	var total int64
+	for _, b := range buckets {
+		total += b.Count
+	}
+	if total == 0 {
+		return dto.LatencyPercentiles{}
+	}
+
+	percentile := func(p float64) int64 {
+		// 第 rank 个请求所在的格
+		rank := int64(p*float64(total) + 0.5)
+		if rank < 1 {
+			rank = 1
+		}
+		var seen int64
+		for _, b := range buckets {
+			seen += b.Count
+			if seen >= rank {
+				return b.Value
+			}
+		}
+		return buckets[len(buckets)-1].Value
+	}
+
+	return dto.LatencyPercentiles{
+		P50: percentile(0.50),
+		P90: percentile(0.90),
+		P99: percentile(0.99),
+	}
+}
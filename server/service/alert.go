@@ -0,0 +1,277 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/HUAHUAI23/simple-waf/server/config"
+	"github.com/HUAHUAI23/simple-waf/server/dto"
+	"github.com/HUAHUAI23/simple-waf/server/model"
+	"github.com/HUAHUAI23/simple-waf/server/repository"
+	"github.com/HUAHUAI23/simple-waf/server/service/daemon/alert"
+	"github.com/rs/zerolog"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+var (
+	ErrAlertRuleNotFound = errors.New("告警规则不存在")
+	ErrInvalidAlertRule  = errors.New("无效的告警规则")
+)
+
+// AlertService 告警服务接口
+type AlertService interface {
+	CreateAlertRule(ctx context.Context, req *dto.CreateAlertRuleRequest) (*model.AlertRule, error)
+	GetAlertRules(ctx context.Context, pageStr, sizeStr string) ([]model.AlertRule, int64, error)
+	GetAlertRuleByID(ctx context.Context, id bson.ObjectID) (*model.AlertRule, error)
+	UpdateAlertRule(ctx context.Context, id bson.ObjectID, req *dto.UpdateAlertRuleRequest) (*model.AlertRule, error)
+	DeleteAlertRule(ctx context.Context, id bson.ObjectID) error
+	GetAlertHistory(ctx context.Context, req dto.AlertHistoryRequest, page, pageSize int) (*dto.AlertHistoryResponse, error)
+	TestChannel(ctx context.Context, req *dto.AlertChannelTestRequest) error
+}
+
+// AlertServiceImpl 告警服务实现
+type AlertServiceImpl struct {
+	ruleRepo    repository.AlertRuleRepository
+	historyRepo repository.AlertHistoryRepository
+	notifier    alert.Notifier
+	logger      zerolog.Logger
+}
+
+// NewAlertService 创建告警服务
+func NewAlertService(ruleRepo repository.AlertRuleRepository, historyRepo repository.AlertHistoryRepository) AlertService {
+	logger := config.GetServiceLogger("alert")
+	return &AlertServiceImpl{
+		ruleRepo:    ruleRepo,
+		historyRepo: historyRepo,
+		notifier:    alert.NewNotifier(10 * time.Second),
+		logger:      logger,
+	}
+}
+
+// CreateAlertRule 创建告警规则
+func (s *AlertServiceImpl) CreateAlertRule(ctx context.Context, req *dto.CreateAlertRuleRequest) (*model.AlertRule, error) {
+	rule := model.NewAlertRule()
+	rule.Name = req.Name
+	rule.Description = req.Description
+	rule.Type = model.AlertRuleType(req.Type)
+	rule.Enabled = req.Enabled
+	rule.Threshold = req.Threshold
+	if req.WindowMinutes > 0 {
+		rule.WindowMinutes = req.WindowMinutes
+	}
+	rule.RuleID = req.RuleID
+	rule.Domain = req.Domain
+	rule.EngineState = req.EngineState
+	rule.ExpiryDays = req.ExpiryDays
+	if req.CooldownMinutes > 0 {
+		rule.CooldownMinutes = req.CooldownMinutes
+	}
+	rule.Channels = make([]model.AlertChannel, len(req.Channels))
+	for i, channel := range req.Channels {
+		rule.Channels[i] = channel.ToModel()
+	}
+
+	if err := model.ValidateAlertRule(rule); err != nil {
+		s.logger.Error().Err(err).Msg("告警规则验证失败")
+		return nil, errors.Join(ErrInvalidAlertRule, err)
+	}
+
+	if err := s.ruleRepo.CreateAlertRule(ctx, rule); err != nil {
+		s.logger.Error().Err(err).Msg("创建告警规则失败")
+		return nil, err
+	}
+
+	s.logger.Info().Str("name", rule.Name).Str("type", string(rule.Type)).Msg("告警规则创建成功")
+	return rule, nil
+}
+
+// GetAlertRules 获取告警规则列表
+func (s *AlertServiceImpl) GetAlertRules(ctx context.Context, pageStr, sizeStr string) ([]model.AlertRule, int64, error) {
+	page, err := strconv.ParseInt(pageStr, 10, 64)
+	if err != nil || page < 1 {
+		page = 1
+	}
+
+	size, err := strconv.ParseInt(sizeStr, 10, 64)
+	if err != nil || size < 1 {
+		size = 10
+	}
+
+	rules, total, err := s.ruleRepo.GetAlertRules(ctx, page, size)
+	if err != nil {
+		s.logger.Error().Err(err).Msg("获取告警规则列表失败")
+		return nil, 0, err
+	}
+
+	return rules, total, nil
+}
+
+// GetAlertRuleByID 根据ID获取告警规则
+func (s *AlertServiceImpl) GetAlertRuleByID(ctx context.Context, id bson.ObjectID) (*model.AlertRule, error) {
+	rule, err := s.ruleRepo.GetAlertRuleByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, repository.ErrAlertRuleNotFound) {
+			return nil, ErrAlertRuleNotFound
+		}
+		return nil, err
+	}
+	return rule, nil
+}
+
+// UpdateAlertRule 更新告警规则
+func (s *AlertServiceImpl) UpdateAlertRule(ctx context.Context, id bson.ObjectID, req *dto.UpdateAlertRuleRequest) (*model.AlertRule, error) {
+	rule, err := s.GetAlertRuleByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Name != "" {
+		rule.Name = req.Name
+	}
+	if req.Description != "" {
+		rule.Description = req.Description
+	}
+	if req.Type != "" {
+		rule.Type = model.AlertRuleType(req.Type)
+	}
+	if req.Enabled != nil {
+		rule.Enabled = *req.Enabled
+	}
+	if req.Threshold != 0 {
+		rule.Threshold = req.Threshold
+	}
+	if req.WindowMinutes != 0 {
+		rule.WindowMinutes = req.WindowMinutes
+	}
+	if req.RuleID != 0 {
+		rule.RuleID = req.RuleID
+	}
+	if req.Domain != nil {
+		rule.Domain = *req.Domain
+	}
+	if req.EngineState != "" {
+		rule.EngineState = req.EngineState
+	}
+	if req.ExpiryDays != 0 {
+		rule.ExpiryDays = req.ExpiryDays
+	}
+	if req.CooldownMinutes != nil {
+		rule.CooldownMinutes = *req.CooldownMinutes
+	}
+	if len(req.Channels) > 0 {
+		channels := make([]model.AlertChannel, len(req.Channels))
+		for i, channel := range req.Channels {
+			channels[i] = channel.ToModel()
+			keepSMTPPassword(channels[i].SMTP, rule.Channels)
+		}
+		rule.Channels = channels
+	}
+
+	if err := model.ValidateAlertRule(rule); err != nil {
+		s.logger.Error().Err(err).Msg("告警规则验证失败")
+		return nil, errors.Join(ErrInvalidAlertRule, err)
+	}
+
+	if err := s.ruleRepo.UpdateAlertRule(ctx, rule); err != nil {
+		if errors.Is(err, repository.ErrAlertRuleNotFound) {
+			return nil, ErrAlertRuleNotFound
+		}
+		s.logger.Error().Err(err).Str("id", id.Hex()).Msg("更新告警规则失败")
+		return nil, err
+	}
+
+	s.logger.Info().Str("id", id.Hex()).Str("name", rule.Name).Msg("告警规则更新成功")
+	return rule, nil
+}
+
+// keepSMTPPassword 响应中不返回 SMTP 密码，更新时密码为空则沿用原规则中同一服务器和用户的密码
+func keepSMTPPassword(cfg *model.SMTPConfig, previous []model.AlertChannel) {
+	if cfg == nil || cfg.Password != "" || cfg.Username == "" {
+		return
+	}
+	for _, channel := range previous {
+		if old := channel.SMTP; old != nil && old.Host == cfg.Host && old.Port == cfg.Port && old.Username == cfg.Username {
+			cfg.Password = old.Password
+			return
+		}
+	}
+}
+
+// DeleteAlertRule 删除告警规则
+func (s *AlertServiceImpl) DeleteAlertRule(ctx context.Context, id bson.ObjectID) error {
+	if err := s.ruleRepo.DeleteAlertRule(ctx, id); err != nil {
+		if errors.Is(err, repository.ErrAlertRuleNotFound) {
+			return ErrAlertRuleNotFound
+		}
+		s.logger.Error().Err(err).Str("id", id.Hex()).Msg("删除告警规则失败")
+		return err
+	}
+
+	s.logger.Info().Str("id", id.Hex()).Msg("告警规则删除成功")
+	return nil
+}
+
+// GetAlertHistory 分页查询告警投递历史
+func (s *AlertServiceImpl) GetAlertHistory(ctx context.Context, req dto.AlertHistoryRequest, page, pageSize int) (*dto.AlertHistoryResponse, error) {
+	filter := bson.D{}
+	if req.RuleID != "" {
+		ruleID, err := bson.ObjectIDFromHex(req.RuleID)
+		if err != nil {
+			return nil, errors.Join(ErrInvalidAlertRule, err)
+		}
+		filter = append(filter, bson.E{Key: "ruleId", Value: ruleID})
+	}
+	if req.Success != nil {
+		filter = append(filter, bson.E{Key: "success", Value: *req.Success})
+	}
+	timeFilter := bson.D{}
+	if !req.StartTime.IsZero() {
+		timeFilter = append(timeFilter, bson.E{Key: "$gte", Value: req.StartTime})
+	}
+	if !req.EndTime.IsZero() {
+		timeFilter = append(timeFilter, bson.E{Key: "$lte", Value: req.EndTime})
+	}
+	if len(timeFilter) > 0 {
+		filter = append(filter, bson.E{Key: "createdAt", Value: timeFilter})
+	}
+
+	totalCount, err := s.historyRepo.CountAlertHistory(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	skip := int64((page - 1) * pageSize)
+	results, err := s.historyRepo.FindAlertHistory(ctx, filter, skip, int64(pageSize))
+	if err != nil {
+		return nil, err
+	}
+
+	return &dto.AlertHistoryResponse{
+		Results:     results,
+		TotalCount:  totalCount,
+		PageSize:    pageSize,
+		CurrentPage: page,
+		TotalPages:  int(math.Ceil(float64(totalCount) / float64(pageSize))),
+	}, nil
+}
+
+// TestChannel 向指定通道发送测试告警，不记录投递历史
+func (s *AlertServiceImpl) TestChannel(ctx context.Context, req *dto.AlertChannelTestRequest) error {
+	channel := req.Channel.ToModel()
+	err := s.notifier.Send(ctx, channel, alert.Notification{
+		RuleName:  "test",
+		Title:     "[WAF] 告警通道测试",
+		Message:   "这是一条测试告警，收到说明通道配置正确。",
+		Timestamp: time.Now(),
+	})
+	if err != nil {
+		s.logger.Warn().Err(err).Str("channel", string(channel.Type)).Msg("告警通道测试失败")
+		return err
+	}
+
+	s.logger.Info().Str("channel", string(channel.Type)).Msg("告警通道测试成功")
+	return nil
+}
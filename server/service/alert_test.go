@@ -0,0 +1,60 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/HUAHUAI23/simple-waf/server/dto"
+	"github.com/HUAHUAI23/simple-waf/server/model"
+	"github.com/HUAHUAI23/simple-waf/server/repository"
+	"github.com/rs/zerolog"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// fakeAlertRuleRepo 内存中只保存一条规则的告警规则仓库
+type fakeAlertRuleRepo struct {
+	repository.AlertRuleRepository
+	rule model.AlertRule
+}
+
+func (r *fakeAlertRuleRepo) GetAlertRuleByID(ctx context.Context, id bson.ObjectID) (*model.AlertRule, error) {
+	if id != r.rule.ID {
+		return nil, repository.ErrAlertRuleNotFound
+	}
+	rule := r.rule
+	return &rule, nil
+}
+
+func (r *fakeAlertRuleRepo) UpdateAlertRule(ctx context.Context, rule *model.AlertRule) error {
+	r.rule = *rule
+	return nil
+}
+
+func TestUpdateAlertRuleEnabled(t *testing.T) {
+	repo := &fakeAlertRuleRepo{rule: model.AlertRule{
+		ID:            bson.NewObjectID(),
+		Name:          "ip-block-burst",
+		Type:          model.AlertRuleIPThreshold,
+		Enabled:       true,
+		Threshold:     100,
+		WindowMinutes: 5,
+	}}
+	s := &AlertServiceImpl{ruleRepo: repo, logger: zerolog.Nop()}
+	ctx := context.Background()
+
+	// 不传 enabled 时保持原状态
+	if _, err := s.UpdateAlertRule(ctx, repo.rule.ID, &dto.UpdateAlertRuleRequest{Threshold: 200}); err != nil {
+		t.Fatal(err)
+	}
+	if !repo.rule.Enabled || repo.rule.Threshold != 200 {
+		t.Errorf("rule = enabled %v threshold %d, want enabled true threshold 200", repo.rule.Enabled, repo.rule.Threshold)
+	}
+
+	disabled := false
+	if _, err := s.UpdateAlertRule(ctx, repo.rule.ID, &dto.UpdateAlertRuleRequest{Enabled: &disabled}); err != nil {
+		t.Fatal(err)
+	}
+	if repo.rule.Enabled || repo.rule.Threshold != 200 {
+		t.Errorf("rule = enabled %v threshold %d, want enabled false threshold 200", repo.rule.Enabled, repo.rule.Threshold)
+	}
+}
@@ -0,0 +1,86 @@
+package service
+
+import (
+	"context"
+	"math"
+
+	"github.com/HUAHUAI23/simple-waf/server/config"
+	"github.com/HUAHUAI23/simple-waf/server/dto"
+	"github.com/HUAHUAI23/simple-waf/server/model"
+	"github.com/HUAHUAI23/simple-waf/server/repository"
+	"github.com/rs/zerolog"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// AuditService 审计服务接口
+type AuditService interface {
+	Record(ctx context.Context, auditLog *model.AuditLog) error
+	GetAuditLogs(ctx context.Context, req dto.AuditLogRequest, page, pageSize int) (*dto.AuditLogResponse, error)
+}
+
+// AuditServiceImpl 审计服务实现
+type AuditServiceImpl struct {
+	auditRepo repository.AuditLogRepository
+	logger    zerolog.Logger
+}
+
+// NewAuditService 创建审计服务
+func NewAuditService(auditRepo repository.AuditLogRepository) AuditService {
+	logger := config.GetServiceLogger("audit")
+	return &AuditServiceImpl{
+		auditRepo: auditRepo,
+		logger:    logger,
+	}
+}
+
+// Record 写入一条审计记录
+func (s *AuditServiceImpl) Record(ctx context.Context, auditLog *model.AuditLog) error {
+	if err := s.auditRepo.CreateAuditLog(ctx, auditLog); err != nil {
+		s.logger.Error().Err(err).Str("action", auditLog.Action).Str("username", auditLog.Username).Msg("写入审计记录失败")
+		return err
+	}
+	return nil
+}
+
+// GetAuditLogs 分页查询审计记录
+func (s *AuditServiceImpl) GetAuditLogs(ctx context.Context, req dto.AuditLogRequest, page, pageSize int) (*dto.AuditLogResponse, error) {
+	filter := bson.D{}
+	if req.Username != "" {
+		filter = append(filter, bson.E{Key: "username", Value: req.Username})
+	}
+	if req.Action != "" {
+		filter = append(filter, bson.E{Key: "action", Value: req.Action})
+	}
+	timeFilter := bson.D{}
+	if !req.StartTime.IsZero() {
+		timeFilter = append(timeFilter, bson.E{Key: "$gte", Value: req.StartTime.UTC()})
+	}
+	if !req.EndTime.IsZero() {
+		timeFilter = append(timeFilter, bson.E{Key: "$lte", Value: req.EndTime.UTC()})
+	}
+	if len(timeFilter) > 0 {
+		filter = append(filter, bson.E{Key: "createdAt", Value: timeFilter})
+	}
+
+	totalCount, err := s.auditRepo.CountAuditLogs(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	skip := int64((page - 1) * pageSize)
+	results, err := s.auditRepo.FindAuditLogs(ctx, filter, skip, int64(pageSize))
+	if err != nil {
+		return nil, err
+	}
+	if results == nil {
+		results = []model.AuditLog{}
+	}
+
+	return &dto.AuditLogResponse{
+		Results:     results,
+		TotalCount:  totalCount,
+		PageSize:    pageSize,
+		CurrentPage: page,
+		TotalPages:  int(math.Ceil(float64(totalCount) / float64(pageSize))),
+	}, nil
+}
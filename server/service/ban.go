@@ -0,0 +1,319 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/HUAHUAI23/simple-waf/server/config"
+	"github.com/HUAHUAI23/simple-waf/server/dto"
+	"github.com/HUAHUAI23/simple-waf/server/model"
+	"github.com/HUAHUAI23/simple-waf/server/repository"
+	"github.com/HUAHUAI23/simple-waf/server/service/daemon/ban"
+	"github.com/rs/zerolog"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+var (
+	ErrBanPolicyNotFound = errors.New("封禁策略不存在")
+	ErrInvalidBanPolicy  = errors.New("无效的封禁策略")
+	ErrBanNotFound       = errors.New("封禁记录不存在或已失效")
+	ErrBanExists         = errors.New("该IP已被封禁")
+	ErrInvalidBanRequest = errors.New("无效的封禁请求")
+)
+
+// BanService 自动封禁服务接口
+type BanService interface {
+	CreateBanPolicy(ctx context.Context, req *dto.CreateBanPolicyRequest) (*model.BanPolicy, error)
+	GetBanPolicies(ctx context.Context, pageStr, sizeStr string) ([]model.BanPolicy, int64, error)
+	GetBanPolicyByID(ctx context.Context, id bson.ObjectID) (*model.BanPolicy, error)
+	UpdateBanPolicy(ctx context.Context, id bson.ObjectID, req *dto.UpdateBanPolicyRequest) (*model.BanPolicy, error)
+	DeleteBanPolicy(ctx context.Context, id bson.ObjectID) error
+	ListBans(ctx context.Context, req dto.BanListRequest, page, pageSize int) (*dto.BanListResponse, error)
+	GetBan(ctx context.Context, id bson.ObjectID) (*model.Ban, error)
+	CreateBan(ctx context.Context, req *dto.CreateBanRequest, username string) (*model.Ban, error)
+	RevokeBan(ctx context.Context, id bson.ObjectID, username string) (*model.Ban, error)
+}
+
+// BanServiceImpl 自动封禁服务实现
+type BanServiceImpl struct {
+	policyRepo repository.BanPolicyRepository
+	banRepo    repository.BanRepository
+	manager    ban.Manager
+	logger     zerolog.Logger
+}
+
+// NewBanService 创建自动封禁服务
+func NewBanService(policyRepo repository.BanPolicyRepository, banRepo repository.BanRepository, manager ban.Manager) BanService {
+	logger := config.GetServiceLogger("ban")
+	return &BanServiceImpl{
+		policyRepo: policyRepo,
+		banRepo:    banRepo,
+		manager:    manager,
+		logger:     logger,
+	}
+}
+
+// CreateBanPolicy 创建封禁策略
+func (s *BanServiceImpl) CreateBanPolicy(ctx context.Context, req *dto.CreateBanPolicyRequest) (*model.BanPolicy, error) {
+	policy := model.NewBanPolicy()
+	policy.Name = req.Name
+	policy.Description = req.Description
+	policy.Enabled = req.Enabled
+	policy.Source = req.Source
+	policy.Threshold = req.Threshold
+	policy.WindowSeconds = req.WindowSeconds
+	policy.Actions = req.Actions
+	policy.MaxSeverity = req.MaxSeverity
+	policy.Durations = req.Durations
+	if req.EscalationHours > 0 {
+		policy.EscalationHours = req.EscalationHours
+	}
+
+	if err := model.ValidateBanPolicy(policy); err != nil {
+		s.logger.Error().Err(err).Msg("封禁策略验证失败")
+		return nil, errors.Join(ErrInvalidBanPolicy, err)
+	}
+
+	if err := s.policyRepo.CreateBanPolicy(ctx, policy); err != nil {
+		s.logger.Error().Err(err).Msg("创建封禁策略失败")
+		return nil, err
+	}
+
+	s.logger.Info().Str("name", policy.Name).Str("source", policy.Source).Msg("封禁策略创建成功")
+	return policy, nil
+}
+
+// GetBanPolicies 获取封禁策略列表
+func (s *BanServiceImpl) GetBanPolicies(ctx context.Context, pageStr, sizeStr string) ([]model.BanPolicy, int64, error) {
+	page, err := strconv.ParseInt(pageStr, 10, 64)
+	if err != nil || page < 1 {
+		page = 1
+	}
+
+	size, err := strconv.ParseInt(sizeStr, 10, 64)
+	if err != nil || size < 1 {
+		size = 10
+	}
+
+	policies, total, err := s.policyRepo.GetBanPolicies(ctx, page, size)
+	if err != nil {
+		s.logger.Error().Err(err).Msg("获取封禁策略列表失败")
+		return nil, 0, err
+	}
+
+	return policies, total, nil
+}
+
+// GetBanPolicyByID 根据ID获取封禁策略
+func (s *BanServiceImpl) GetBanPolicyByID(ctx context.Context, id bson.ObjectID) (*model.BanPolicy, error) {
+	policy, err := s.policyRepo.GetBanPolicyByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, repository.ErrBanPolicyNotFound) {
+			return nil, ErrBanPolicyNotFound
+		}
+		return nil, err
+	}
+	return policy, nil
+}
+
+// UpdateBanPolicy 更新封禁策略，新的条件从下一轮评估开始生效
+func (s *BanServiceImpl) UpdateBanPolicy(ctx context.Context, id bson.ObjectID, req *dto.UpdateBanPolicyRequest) (*model.BanPolicy, error) {
+	policy, err := s.GetBanPolicyByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Name != nil {
+		policy.Name = *req.Name
+	}
+	if req.Description != nil {
+		policy.Description = *req.Description
+	}
+	if req.Enabled != nil {
+		policy.Enabled = *req.Enabled
+	}
+	if req.Source != nil {
+		policy.Source = *req.Source
+	}
+	if req.Threshold != nil {
+		policy.Threshold = *req.Threshold
+	}
+	if req.WindowSeconds != nil {
+		policy.WindowSeconds = *req.WindowSeconds
+	}
+	if req.Actions != nil {
+		policy.Actions = req.Actions
+	}
+	if req.MaxSeverity != nil {
+		policy.MaxSeverity = *req.MaxSeverity
+	}
+	if len(req.Durations) > 0 {
+		policy.Durations = req.Durations
+	}
+	if req.EscalationHours != nil {
+		policy.EscalationHours = *req.EscalationHours
+	}
+	policy.UpdatedAt = time.Now()
+
+	if err := model.ValidateBanPolicy(policy); err != nil {
+		s.logger.Error().Err(err).Msg("封禁策略验证失败")
+		return nil, errors.Join(ErrInvalidBanPolicy, err)
+	}
+
+	if err := s.policyRepo.UpdateBanPolicy(ctx, policy); err != nil {
+		if errors.Is(err, repository.ErrBanPolicyNotFound) {
+			return nil, ErrBanPolicyNotFound
+		}
+		s.logger.Error().Err(err).Str("id", id.Hex()).Msg("更新封禁策略失败")
+		return nil, err
+	}
+
+	s.logger.Info().Str("id", id.Hex()).Str("name", policy.Name).Msg("封禁策略更新成功")
+	return policy, nil
+}
+
+// DeleteBanPolicy 删除封禁策略，已产生的封禁记录保留到到期
+func (s *BanServiceImpl) DeleteBanPolicy(ctx context.Context, id bson.ObjectID) error {
+	if err := s.policyRepo.DeleteBanPolicy(ctx, id); err != nil {
+		if errors.Is(err, repository.ErrBanPolicyNotFound) {
+			return ErrBanPolicyNotFound
+		}
+		s.logger.Error().Err(err).Str("id", id.Hex()).Msg("删除封禁策略失败")
+		return err
+	}
+
+	s.logger.Info().Str("id", id.Hex()).Msg("封禁策略删除成功")
+	return nil
+}
+
+// ListBans 分页查询封禁记录
+func (s *BanServiceImpl) ListBans(ctx context.Context, req dto.BanListRequest, page, pageSize int) (*dto.BanListResponse, error) {
+	filter := bson.D{}
+	if req.IP != "" {
+		filter = append(filter, bson.E{Key: "ip", Value: req.IP})
+	}
+	if req.Source != "" {
+		filter = append(filter, bson.E{Key: "source", Value: req.Source})
+	}
+	if req.Active != nil {
+		filter = append(filter, bson.E{Key: "active", Value: *req.Active})
+	}
+	if req.PolicyID != "" {
+		policyID, err := bson.ObjectIDFromHex(req.PolicyID)
+		if err != nil {
+			return nil, errors.Join(ErrInvalidBanRequest, err)
+		}
+		filter = append(filter, bson.E{Key: "policyId", Value: policyID})
+	}
+	timeFilter := bson.D{}
+	if !req.StartTime.IsZero() {
+		timeFilter = append(timeFilter, bson.E{Key: "$gte", Value: req.StartTime})
+	}
+	if !req.EndTime.IsZero() {
+		timeFilter = append(timeFilter, bson.E{Key: "$lte", Value: req.EndTime})
+	}
+	if len(timeFilter) > 0 {
+		filter = append(filter, bson.E{Key: "startedAt", Value: timeFilter})
+	}
+
+	skip := int64((page - 1) * pageSize)
+	results, totalCount, err := s.banRepo.FindBans(ctx, filter, skip, int64(pageSize))
+	if err != nil {
+		return nil, err
+	}
+
+	return &dto.BanListResponse{
+		Results:     results,
+		TotalCount:  totalCount,
+		PageSize:    pageSize,
+		CurrentPage: page,
+		TotalPages:  int(math.Ceil(float64(totalCount) / float64(pageSize))),
+	}, nil
+}
+
+// GetBan 获取封禁记录详情，包含触发封禁的事件
+func (s *BanServiceImpl) GetBan(ctx context.Context, id bson.ObjectID) (*model.Ban, error) {
+	record, err := s.banRepo.GetBanByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, repository.ErrBanNotFound) {
+			return nil, ErrBanNotFound
+		}
+		return nil, err
+	}
+	return record, nil
+}
+
+// CreateBan 手动封禁IP或网段，不受封禁豁免网段限制
+func (s *BanServiceImpl) CreateBan(ctx context.Context, req *dto.CreateBanRequest, username string) (*model.Ban, error) {
+	ip, err := normalizeBanTarget(req.IP)
+	if err != nil {
+		return nil, errors.Join(ErrInvalidBanRequest, err)
+	}
+
+	now := time.Now()
+	record := &model.Ban{
+		IP:        ip,
+		Source:    model.BanSourceManual,
+		Reason:    req.Reason,
+		Level:     1,
+		Active:    true,
+		StartedAt: now,
+		CreatedBy: username,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if record.Reason == "" {
+		record.Reason = "手动封禁"
+	}
+	if req.DurationMinutes > 0 {
+		expiresAt := now.Add(time.Duration(req.DurationMinutes) * time.Minute)
+		record.ExpiresAt = &expiresAt
+	}
+
+	if err := s.banRepo.CreateBan(ctx, record); err != nil {
+		if errors.Is(err, repository.ErrBanExists) {
+			return nil, ErrBanExists
+		}
+		s.logger.Error().Err(err).Str("ip", ip).Msg("创建封禁记录失败")
+		return nil, err
+	}
+
+	s.manager.Notify()
+	s.logger.Info().Str("ip", ip).Str("user", username).Int("minutes", req.DurationMinutes).Msg("手动封禁成功")
+	return record, nil
+}
+
+// RevokeBan 手动解除生效中的封禁
+func (s *BanServiceImpl) RevokeBan(ctx context.Context, id bson.ObjectID, username string) (*model.Ban, error) {
+	record, err := s.banRepo.RevokeBan(ctx, id, username)
+	if err != nil {
+		if errors.Is(err, repository.ErrBanNotFound) {
+			return nil, ErrBanNotFound
+		}
+		s.logger.Error().Err(err).Str("id", id.Hex()).Msg("解除封禁失败")
+		return nil, err
+	}
+
+	s.manager.Notify()
+	s.logger.Info().Str("id", id.Hex()).Str("ip", record.IP).Str("user", username).Msg("解除封禁成功")
+	return record, nil
+}
+
+// normalizeBanTarget 将IP或网段转换为规范形式，与自动封禁写入的键保持一致
+func normalizeBanTarget(target string) (string, error) {
+	if ip := net.ParseIP(target); ip != nil {
+		if v4 := ip.To4(); v4 != nil {
+			return v4.String(), nil
+		}
+		return ip.String(), nil
+	}
+	_, network, err := net.ParseCIDR(target)
+	if err != nil {
+		return "", err
+	}
+	return network.String(), nil
+}
@@ -13,7 +13,10 @@ import (
 )
 
 var (
-	ErrConfigNotFound = errors.New("配置不存在")
+	ErrConfigNotFound           = errors.New("配置不存在")
+	ErrSIEMAddressRequired      = errors.New("启用SIEM转发时必须配置接收端地址")
+	ErrDuplicateRetentionPolicy = errors.New("同一集合只能配置一条保留策略")
+	ErrArchiveDirRequired       = errors.New("启用归档时必须配置归档目录")
 )
 
 // ConfigService 配置服务接口
@@ -132,6 +135,73 @@ func (s *ConfigServiceImpl) PatchConfig(ctx context.Context, req *dto.ConfigPatc
 		}
 	}
 
+	// 更新SIEM转发配置
+	if req.SIEM != nil {
+		if req.SIEM.Enabled != nil {
+			cfg.SIEM.Enabled = *req.SIEM.Enabled
+		}
+		if req.SIEM.Format != nil {
+			cfg.SIEM.Format = *req.SIEM.Format
+		}
+		if req.SIEM.Protocol != nil {
+			cfg.SIEM.Protocol = *req.SIEM.Protocol
+		}
+		if req.SIEM.Address != nil {
+			cfg.SIEM.Address = *req.SIEM.Address
+		}
+		if req.SIEM.TLSInsecure != nil {
+			cfg.SIEM.TLSInsecure = *req.SIEM.TLSInsecure
+		}
+		if req.SIEM.Facility != nil {
+			cfg.SIEM.Facility = *req.SIEM.Facility
+		}
+		if req.SIEM.BufferSize != nil {
+			cfg.SIEM.BufferSize = *req.SIEM.BufferSize
+		}
+		if req.SIEM.ForwardWAF != nil {
+			cfg.SIEM.ForwardWAF = *req.SIEM.ForwardWAF
+		}
+		if req.SIEM.ForwardIDS != nil {
+			cfg.SIEM.ForwardIDS = *req.SIEM.ForwardIDS
+		}
+		if cfg.SIEM.Enabled && cfg.SIEM.Address == "" {
+			return nil, ErrSIEMAddressRequired
+		}
+	}
+
+	// 更新日志保留配置
+	if req.Retention != nil {
+		if req.Retention.ArchiveDir != nil {
+			cfg.Retention.ArchiveDir = *req.Retention.ArchiveDir
+		}
+		if req.Retention.RestoreDays != nil {
+			cfg.Retention.RestoreDays = *req.Retention.RestoreDays
+		}
+		if req.Retention.Policies != nil {
+			seen := make(map[string]bool, len(req.Retention.Policies))
+			policies := make([]model.RetentionPolicy, 0, len(req.Retention.Policies))
+			for _, p := range req.Retention.Policies {
+				if seen[p.Collection] {
+					return nil, ErrDuplicateRetentionPolicy
+				}
+				seen[p.Collection] = true
+				policies = append(policies, model.RetentionPolicy{
+					Collection: p.Collection,
+					Days:       p.Days,
+					Archive:    p.Archive,
+				})
+			}
+			cfg.Retention.Policies = policies
+		}
+		if cfg.Retention.ArchiveDir == "" {
+			for _, p := range cfg.Retention.Policies {
+				if p.Archive {
+					return nil, ErrArchiveDirRequired
+				}
+			}
+		}
+	}
+
 	// 保存更新
 	err = s.configRepo.UpdateConfig(ctx, cfg)
 	if err != nil {
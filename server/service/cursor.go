@@ -0,0 +1,38 @@
+package service
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+var ErrInvalidCursor = errors.New("无效的分页游标")
+
+// pageCursor 游标分页位置，记录上一页最后一条记录的排序键
+type pageCursor struct {
+	Time    time.Time `json:"t"`
+	ID      string    `json:"i,omitempty"`
+	SrcIP   string    `json:"s,omitempty"`
+	DstPort int       `json:"p,omitempty"`
+	Domain  string    `json:"d,omitempty"`
+}
+
+// encodeCursor 将游标编码为 URL 安全的字符串
+func encodeCursor(c pageCursor) string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodeCursor 解析客户端传入的游标
+func decodeCursor(s string) (pageCursor, error) {
+	var c pageCursor
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return c, ErrInvalidCursor
+	}
+	if err := json.Unmarshal(data, &c); err != nil || c.Time.IsZero() {
+		return c, ErrInvalidCursor
+	}
+	return c, nil
+}
@@ -0,0 +1,41 @@
+package service
+
+import (
+	"encoding/base64"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	tests := []pageCursor{
+		{Time: time.Date(2024, 3, 17, 8, 0, 0, 123000000, time.UTC), ID: "65f1c2a4e4b0a1b2c3d4e5f6"},
+		{Time: time.Date(2024, 3, 17, 8, 0, 0, 0, time.UTC), SrcIP: "2001:db8::1", DstPort: 443, Domain: "example.com"},
+	}
+	for _, want := range tests {
+		encoded := encodeCursor(want)
+		got, err := decodeCursor(encoded)
+		if err != nil {
+			t.Fatalf("decodeCursor(%q): %v", encoded, err)
+		}
+		if !got.Time.Equal(want.Time) || got.ID != want.ID || got.SrcIP != want.SrcIP ||
+			got.DstPort != want.DstPort || got.Domain != want.Domain {
+			t.Errorf("decodeCursor(encodeCursor(%+v)) = %+v", want, got)
+		}
+	}
+}
+
+func TestDecodeCursorInvalid(t *testing.T) {
+	tests := map[string]string{
+		"not base64":   "!!!",
+		"padded":       base64.URLEncoding.EncodeToString([]byte(`{"t":"2024-03-17T08:00:00Z"}`)),
+		"not json":     base64.RawURLEncoding.EncodeToString([]byte("cursor")),
+		"missing time": base64.RawURLEncoding.EncodeToString([]byte(`{"i":"65f1c2a4e4b0a1b2c3d4e5f6"}`)),
+		"bad time":     base64.RawURLEncoding.EncodeToString([]byte(`{"t":"yesterday"}`)),
+	}
+	for name, cursor := range tests {
+		if _, err := decodeCursor(cursor); !errors.Is(err, ErrInvalidCursor) {
+			t.Errorf("%s: decodeCursor(%q) error = %v, want ErrInvalidCursor", name, cursor, err)
+		}
+	}
+}
@@ -0,0 +1,256 @@
+package accesslog
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/HUAHUAI23/simple-waf/pkg/utils/network"
+	"github.com/HUAHUAI23/simple-waf/server/model"
+	"github.com/HUAHUAI23/simple-waf/server/repository"
+	"github.com/rs/zerolog"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// ReceiverImpl 访问日志接收器实现
+//
+// HAProxy 每条日志发送一个 syslog 数据报，接收器逐条解析后缓冲，按批量大小或每秒写入 access_log。
+// syslog 无法反压，MongoDB 不可用时最多保留 maxPendingBatches 批日志，超出部分丢弃并计数。
+// 站点列表定期从数据库刷新，站点变化后最多 refreshInterval 内生效。
+type ReceiverImpl struct {
+	repo      repository.AccessLogRepository
+	siteRepo  repository.SiteRepository
+	address   string
+	enabled   bool
+	batchSize int
+	logger    zerolog.Logger
+
+	mu      sync.Mutex
+	running bool
+	conn    net.PacketConn
+	cancel  context.CancelFunc
+	done    chan struct{}
+
+	statsMu sync.RWMutex
+	stats   Stats
+
+	// 以下字段仅在接收协程中访问
+	batch       []model.AccessLog
+	lastFlush   time.Time
+	resolver    *SiteResolver
+	lastRefresh time.Time
+}
+
+// Start 监听接收地址并启动接收协程，未启用接收时直接返回
+func (m *ReceiverImpl) Start() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if !m.enabled {
+		m.updateStats(func(s *Stats) {
+			s.State = StateDisabled
+			s.Address = m.address
+		})
+		m.logger.Info().Msg("HAProxy 访问日志接收未启用")
+		return nil
+	}
+
+	if m.running {
+		return errors.New("access log receiver already running")
+	}
+
+	conn, err := listen(m.address)
+	if err != nil {
+		return fmt.Errorf("监听访问日志地址 %s 失败: %w", m.address, err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	m.conn = conn
+	m.cancel = cancel
+	m.done = make(chan struct{})
+	m.running = true
+	m.updateStats(func(s *Stats) {
+		s.State = StateRunning
+		s.Address = m.address
+		s.StartedAt = time.Now()
+	})
+
+	go m.loop(ctx)
+
+	m.logger.Info().Str("address", m.address).Msg("HAProxy 访问日志接收器已启动")
+	return nil
+}
+
+// Stop 停止接收协程，退出前尝试写入已缓冲的日志
+func (m *ReceiverImpl) Stop() error {
+	m.mu.Lock()
+	if !m.running {
+		m.mu.Unlock()
+		return nil
+	}
+	m.cancel()
+	m.conn.Close()
+	done := m.done
+	m.running = false
+	m.mu.Unlock()
+
+	<-done
+	if proto, path := network.NetworkAddressFromBind(m.address); strings.HasPrefix(proto, "unix") {
+		os.Remove(path)
+	}
+	m.updateStats(func(s *Stats) { s.State = StateStopped })
+	m.logger.Info().Msg("HAProxy 访问日志接收器已停止")
+	return nil
+}
+
+// Stats 返回接收状态快照
+func (m *ReceiverImpl) Stats() Stats {
+	m.statsMu.RLock()
+	defer m.statsMu.RUnlock()
+	return m.stats
+}
+
+func (m *ReceiverImpl) updateStats(fn func(s *Stats)) {
+	m.statsMu.Lock()
+	fn(&m.stats)
+	m.statsMu.Unlock()
+}
+
+func (m *ReceiverImpl) recordError(err error, msg string) {
+	m.logger.Error().Err(err).Str("address", m.address).Msg(msg)
+	m.updateStats(func(s *Stats) {
+		s.State = StateError
+		s.LastError = err.Error()
+		s.LastErrorAt = time.Now()
+	})
+}
+
+// listen 监听 udp://host:port 或 unix:///path，unix 套接字为数据报类型，允许 HAProxy 以其他用户写入
+func listen(address string) (net.PacketConn, error) {
+	proto, addr := network.NetworkAddressFromBind(address)
+	switch proto {
+	case "udp", "udp4", "udp6":
+		return net.ListenPacket(proto, addr)
+	case "unix", "unixgram":
+		// 上次退出时未删除的套接字文件会导致监听失败
+		os.Remove(addr)
+		conn, err := net.ListenPacket("unixgram", addr)
+		if err != nil {
+			return nil, err
+		}
+		if err := os.Chmod(addr, 0666); err != nil {
+			conn.Close()
+			return nil, err
+		}
+		return conn, nil
+	}
+	return nil, fmt.Errorf("不支持的访问日志接收协议: %s", proto)
+}
+
+func (m *ReceiverImpl) loop(ctx context.Context) {
+	defer close(m.done)
+	defer func() {
+		// 退出前使用独立的上下文写入剩余日志
+		flushCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		m.flush(flushCtx)
+	}()
+
+	m.lastFlush = time.Now()
+	m.refreshSites(ctx)
+
+	buf := make([]byte, maxMessageSize)
+	for ctx.Err() == nil {
+		m.conn.SetReadDeadline(time.Now().Add(readTimeout))
+		n, _, err := m.conn.ReadFrom(buf)
+		if n > 0 {
+			m.handleMessage(string(buf[:n]))
+		}
+		if err != nil && !errors.Is(err, os.ErrDeadlineExceeded) && ctx.Err() == nil {
+			m.recordError(err, "读取访问日志失败")
+			time.Sleep(readTimeout)
+		}
+
+		if time.Since(m.lastRefresh) >= refreshInterval {
+			m.refreshSites(ctx)
+		}
+		if len(m.batch) >= m.batchSize || time.Since(m.lastFlush) >= flushInterval {
+			m.flush(ctx)
+		}
+	}
+}
+
+// refreshSites 重新加载站点列表，失败时继续使用上次的结果
+func (m *ReceiverImpl) refreshSites(ctx context.Context) {
+	m.lastRefresh = time.Now()
+	sites, err := m.siteRepo.GetAllSites(ctx)
+	if err != nil {
+		if ctx.Err() == nil {
+			m.logger.Warn().Err(err).Msg("刷新站点列表失败")
+		}
+		return
+	}
+	m.resolver = NewSiteResolver(sites)
+}
+
+func (m *ReceiverImpl) handleMessage(message string) {
+	entry, ok := parseLine(message, time.Now())
+	site := m.resolver.Resolve(entry.ListenPort, entry.Host)
+	m.updateStats(func(s *Stats) {
+		s.MessagesReceived++
+		switch {
+		case !ok:
+			s.LogsSkipped++
+		case site == nil:
+			s.LogsUnmatched++
+			s.LastLogAt = entry.Timestamp
+		default:
+			s.LastLogAt = entry.Timestamp
+		}
+	})
+	if !ok {
+		return
+	}
+
+	if site != nil {
+		entry.SiteID = site.ID.Hex()
+		entry.Domain = site.Domain
+	}
+	entry.ID = bson.NewObjectID()
+	m.batch = append(m.batch, entry)
+}
+
+// flush 写入缓冲的日志，失败时保留到下次写入，超过上限后丢弃最早的日志
+func (m *ReceiverImpl) flush(ctx context.Context) {
+	m.lastFlush = time.Now()
+	if len(m.batch) == 0 {
+		return
+	}
+
+	if err := m.repo.InsertAccessLogs(ctx, m.batch); err != nil {
+		if ctx.Err() != nil {
+			return
+		}
+		m.updateStats(func(s *Stats) { s.InsertErrors++ })
+		m.recordError(err, "写入访问日志失败，等待重试")
+		if limit := m.batchSize * maxPendingBatches; len(m.batch) > limit {
+			dropped := len(m.batch) - limit
+			m.batch = append(m.batch[:0], m.batch[dropped:]...)
+			m.updateStats(func(s *Stats) { s.LogsDropped += uint64(dropped) })
+		}
+		return
+	}
+
+	inserted := len(m.batch)
+	m.batch = m.batch[:0]
+	m.updateStats(func(s *Stats) {
+		s.State = StateRunning
+		s.LogsInserted += uint64(inserted)
+		s.LastInsertAt = time.Now()
+	})
+}
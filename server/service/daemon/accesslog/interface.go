@@ -0,0 +1,76 @@
+package accesslog
+
+import (
+	"sync"
+	"time"
+
+	"github.com/HUAHUAI23/simple-waf/server/config"
+	"github.com/HUAHUAI23/simple-waf/server/repository"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+// 接收状态
+const (
+	StateDisabled = "disabled" // 未启用接收
+	StateStopped  = "stopped"  // 接收协程未运行
+	StateRunning  = "running"  // 正常接收
+	StateError    = "error"    // 写入失败，正在重试
+)
+
+// Receiver 以 syslog 方式接收 HAProxy 访问日志，解析后按站点归属批量写入 MongoDB
+type Receiver interface {
+	Start() error
+	Stop() error
+	Stats() Stats
+}
+
+// Stats 接收运行状态
+type Stats struct {
+	State            string
+	Address          string
+	MessagesReceived uint64
+	LogsInserted     uint64
+	LogsSkipped      uint64 // 不是 HTTP 访问日志的消息，如 tcplog 和 HAProxy 运行日志
+	LogsUnmatched    uint64 // 无法归属到站点的访问日志
+	LogsDropped      uint64 // 长时间写入失败后丢弃的访问日志
+	InsertErrors     uint64
+	LastLogAt        time.Time
+	LastInsertAt     time.Time
+	LastError        string
+	LastErrorAt      time.Time
+	StartedAt        time.Time
+}
+
+const (
+	readTimeout     = time.Second
+	flushInterval   = time.Second
+	refreshInterval = 30 * time.Second
+	// maxPendingBatches 写入失败时最多保留的批次数，超过后丢弃最早的日志，syslog 无法让 HAProxy 等待
+	maxPendingBatches = 10
+	maxMessageSize    = 64 * 1024
+)
+
+var (
+	instance Receiver
+	once     sync.Once
+)
+
+// GetReceiver 获取访问日志接收器单例，接收地址和批量大小取自环境配置
+func GetReceiver(db *mongo.Database) Receiver {
+	once.Do(func() {
+		logger := config.GetLogger().With().Str("component", "accesslog").Logger()
+		batchSize := config.Global.AccessLog.BatchSize
+		if batchSize <= 0 {
+			batchSize = 500
+		}
+		instance = &ReceiverImpl{
+			repo:      repository.NewAccessLogRepository(db),
+			siteRepo:  repository.NewSiteRepository(db),
+			address:   config.Global.AccessLog.Address,
+			enabled:   config.Global.AccessLog.Enabled,
+			batchSize: batchSize,
+			logger:    logger,
+		}
+	})
+	return instance
+}
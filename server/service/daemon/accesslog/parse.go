@@ -0,0 +1,123 @@
+package accesslog
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/HUAHUAI23/simple-waf/server/model"
+)
+
+// haproxyTimeLayout HAProxy %t/%tr 的时间格式，使用 HAProxy 所在主机的本地时区
+const haproxyTimeLayout = "02/Jan/2006:15:04:05.000"
+
+// httpLogPattern 匹配 option httplog 和端口前端自定义 log-format 的公共部分
+//
+// httplog 的计时字段为 %TR/%Tw/%Tc/%Tr/%Ta，自定义格式为 %Th/%Ti/%TR/%Tq/%Tw/%Tc/%Tr/%Tt，
+// 两者最后一个都是总耗时（option logasap 时带 + 前缀）、倒数第二个都是后端响应耗时。%hr/%hs 只在配置了捕获时出现，
+// 端口前端把 Host 捕获为第一个请求头。tcplog 等其他格式的日志不匹配。
+var httpLogPattern = regexp.MustCompile(`^(\S+):(\d+) \[([^\]]+)\] (\S+) ([^/\s]+)/(\S+) (-?\d+(?:/-?\d+){3,6}/\+?-?\d+) (-?\d+) \+?(-?\d+) \S+ \S+ (\S{4}) \d+/\d+/\d+/\d+/\+?\d+ \d+/\d+\s+(?:\{([^}]*)\}\s*)?(?:\{[^}]*\}\s*)?"([^"]*)"(.*)$`)
+
+// syslogPriPattern syslog 消息开头的 <PRI>
+var syslogPriPattern = regexp.MustCompile(`^<\d{1,3}>`)
+
+// parseLine 解析一条 HAProxy HTTP 访问日志，不是 HTTP 访问日志时返回 false
+// 生成的配置使用 format raw 发送，也兼容带 RFC 3164 头部的日志
+func parseLine(line string, received time.Time) (model.AccessLog, bool) {
+	line = strings.TrimSpace(syslogPriPattern.ReplaceAllString(strings.TrimSpace(line), ""))
+	m := httpLogPattern.FindStringSubmatch(line)
+	if m == nil {
+		// 跳过 "Oct 19 10:00:00 host haproxy[1]: " 形式的头部
+		if _, rest, ok := strings.Cut(line, ": "); ok {
+			m = httpLogPattern.FindStringSubmatch(rest)
+		}
+	}
+	if m == nil {
+		return model.AccessLog{}, false
+	}
+
+	entry := model.AccessLog{
+		Timestamp:   received,
+		ClientIP:    m[1],
+		Frontend:    strings.TrimSuffix(m[4], "~"),
+		Backend:     m[5],
+		Server:      m[6],
+		Termination: m[10],
+	}
+	entry.ClientPort, _ = strconv.Atoi(m[2])
+	if t, err := time.ParseInLocation(haproxyTimeLayout, m[3], time.Local); err == nil {
+		entry.Timestamp = t
+	}
+	entry.ListenPort = frontendPort(entry.Frontend)
+
+	timers := strings.Split(m[7], "/")
+	entry.RequestTime, _ = strconv.ParseInt(timers[len(timers)-1], 10, 64)
+	entry.ResponseTime, _ = strconv.ParseInt(timers[len(timers)-2], 10, 64)
+	entry.Status, _ = strconv.Atoi(m[8])
+	entry.Bytes, _ = strconv.ParseInt(m[9], 10, 64)
+
+	if m[11] != "" {
+		host, _, _ := strings.Cut(m[11], "|")
+		entry.Host = normalizeHost(host)
+	}
+
+	entry.Method, entry.Path, entry.Protocol = parseRequest(m[12])
+	// HTTP/2 和 HTTP/3 的 %r 为绝对地址，未捕获 Host 时从中取主机名
+	if scheme, rest, ok := strings.Cut(entry.Path, "://"); ok && (scheme == "http" || scheme == "https") {
+		authority, path, _ := strings.Cut(rest, "/")
+		if entry.Host == "" {
+			entry.Host = normalizeHost(authority)
+		}
+		entry.Path = "/" + path
+	}
+
+	// 自定义格式在请求行之后记录 Coraza 事务ID
+	if fields := strings.Fields(m[13]); len(fields) > 0 && fields[0] != "-" && !strings.HasSuffix(fields[0], ":") {
+		entry.WAFRequestID = fields[0]
+	}
+	return entry, true
+}
+
+// parseRequest 拆分 %r 请求行，路径去掉查询参数
+func parseRequest(request string) (method, path, protocol string) {
+	fields := strings.Fields(request)
+	if len(fields) > 0 {
+		method = fields[0]
+	}
+	if len(fields) > 1 {
+		path, _, _ = strings.Cut(fields[1], "?")
+	}
+	if len(fields) > 2 {
+		protocol = fields[2]
+	}
+	return method, path, protocol
+}
+
+// normalizeHost 去掉 Host 中的端口并转为小写，IPv6 地址去掉方括号
+func normalizeHost(host string) string {
+	host = strings.ToLower(strings.TrimSpace(host))
+	if strings.HasPrefix(host, "[") {
+		if end := strings.Index(host, "]"); end > 0 {
+			return host[1:end]
+		}
+	}
+	if h, _, ok := strings.Cut(host, ":"); ok {
+		return h
+	}
+	return host
+}
+
+// frontendPort 从 fe_<port>_http、fe_<port>_https 前端名称中解析端口
+func frontendPort(name string) int {
+	rest, ok := strings.CutPrefix(name, "fe_")
+	if !ok {
+		return 0
+	}
+	port, _, _ := strings.Cut(rest, "_")
+	n, err := strconv.Atoi(port)
+	if err != nil {
+		return 0
+	}
+	return n
+}
@@ -0,0 +1,149 @@
+package accesslog
+
+import (
+	"testing"
+	"time"
+
+	"github.com/HUAHUAI23/simple-waf/server/model"
+)
+
+func TestParseLine(t *testing.T) {
+	received := time.Date(2024, 3, 18, 0, 0, 0, 0, time.UTC)
+	logged := time.Date(2024, 3, 18, 8, 12, 33, 123000000, time.Local)
+
+	custom := `192.168.1.100:52134 [18/Mar/2024:08:12:33.123] fe_443_https~ be_example_com/srv1 0/0/1/1/0/1/12/14 200 1543 - - ---- 3/3/0/0/0 0/0 {Example.com:443} "GET /login.php?user=admin HTTP/1.1" a1b2c3d4e5f6 spoa-error: - waf-hit: -`
+
+	tests := []struct {
+		name string
+		line string
+		want model.AccessLog
+	}{
+		{
+			name: "custom format raw",
+			line: custom,
+			want: model.AccessLog{
+				Timestamp: logged, ClientIP: "192.168.1.100", ClientPort: 52134,
+				Frontend: "fe_443_https", Backend: "be_example_com", Server: "srv1", ListenPort: 443,
+				RequestTime: 14, ResponseTime: 12, Status: 200, Bytes: 1543, Termination: "----",
+				Host: "example.com", Method: "GET", Path: "/login.php", Protocol: "HTTP/1.1",
+				WAFRequestID: "a1b2c3d4e5f6",
+			},
+		},
+		{
+			name: "custom format with syslog priority",
+			line: "<134>" + custom,
+			want: model.AccessLog{
+				Timestamp: logged, ClientIP: "192.168.1.100", ClientPort: 52134,
+				Frontend: "fe_443_https", Backend: "be_example_com", Server: "srv1", ListenPort: 443,
+				RequestTime: 14, ResponseTime: 12, Status: 200, Bytes: 1543, Termination: "----",
+				Host: "example.com", Method: "GET", Path: "/login.php", Protocol: "HTTP/1.1",
+				WAFRequestID: "a1b2c3d4e5f6",
+			},
+		},
+		{
+			name: "custom format denied before a server was chosen",
+			line: `10.0.0.8:40000 [18/Mar/2024:08:12:33.123] fe_80_http fe_80_http/<NOSRV> 0/0/0/-1/-1/-1/-1/0 403 192 - - PR-- 1/1/0/0/0 0/0 {example.com} "POST /admin HTTP/1.1" - spoa-error: - waf-hit: -`,
+			want: model.AccessLog{
+				Timestamp: logged, ClientIP: "10.0.0.8", ClientPort: 40000,
+				Frontend: "fe_80_http", Backend: "fe_80_http", Server: "<NOSRV>", ListenPort: 80,
+				RequestTime: 0, ResponseTime: -1, Status: 403, Bytes: 192, Termination: "PR--",
+				Host: "example.com", Method: "POST", Path: "/admin", Protocol: "HTTP/1.1",
+			},
+		},
+		{
+			name: "httplog with RFC 3164 header",
+			line: `<134>Mar 18 08:12:33 waf haproxy[123]: 192.168.1.100:52134 [18/Mar/2024:08:12:33.123] fe_80_http be_example_com/srv1 10/0/30/69/109 200 2750 - - ---- 1/1/1/1/0 0/0 {example.com|Mozilla/5.0} "GET /index.html HTTP/1.1"`,
+			want: model.AccessLog{
+				Timestamp: logged, ClientIP: "192.168.1.100", ClientPort: 52134,
+				Frontend: "fe_80_http", Backend: "be_example_com", Server: "srv1", ListenPort: 80,
+				RequestTime: 109, ResponseTime: 69, Status: 200, Bytes: 2750, Termination: "----",
+				Host: "example.com", Method: "GET", Path: "/index.html", Protocol: "HTTP/1.1",
+			},
+		},
+		{
+			name: "httplog aborted transfer without captures",
+			line: `192.168.1.100:52134 [18/Mar/2024:08:12:33.123] fe_80_http be_example_com/srv1 10/0/30/69/+109 200 +2750 - - CD-- 1/1/1/1/0 0/0 "GET /big.iso HTTP/1.1"`,
+			want: model.AccessLog{
+				Timestamp: logged, ClientIP: "192.168.1.100", ClientPort: 52134,
+				Frontend: "fe_80_http", Backend: "be_example_com", Server: "srv1", ListenPort: 80,
+				RequestTime: 109, ResponseTime: 69, Status: 200, Bytes: 2750, Termination: "CD--",
+				Method: "GET", Path: "/big.iso", Protocol: "HTTP/1.1",
+			},
+		},
+		{
+			name: "HTTP/2 absolute-form request",
+			line: `192.168.1.100:52134 [18/Mar/2024:08:12:33.123] fe_8443_https~ be_api/srv2 0/0/1/1/0/1/5/7 404 120 - - ---- 1/1/0/0/0 0/0 "GET https://API.example.com:8443/v1/users?page=2 HTTP/2.0" 9f8e7d spoa-error: - waf-hit: -`,
+			want: model.AccessLog{
+				Timestamp: logged, ClientIP: "192.168.1.100", ClientPort: 52134,
+				Frontend: "fe_8443_https", Backend: "be_api", Server: "srv2", ListenPort: 8443,
+				RequestTime: 7, ResponseTime: 5, Status: 404, Bytes: 120, Termination: "----",
+				Host: "api.example.com", Method: "GET", Path: "/v1/users", Protocol: "HTTP/2.0",
+				WAFRequestID: "9f8e7d",
+			},
+		},
+		{
+			name: "IPv6 client and host",
+			line: `2001:db8::1:52134 [18/Mar/2024:08:12:33.123] fe_443_https~ be_example_com/srv1 0/0/1/1/0/1/12/14 200 1543 - - ---- 3/3/0/0/0 0/0 {[2001:DB8::10]:443} "GET / HTTP/1.1" - spoa-error: - waf-hit: -`,
+			want: model.AccessLog{
+				Timestamp: logged, ClientIP: "2001:db8::1", ClientPort: 52134,
+				Frontend: "fe_443_https", Backend: "be_example_com", Server: "srv1", ListenPort: 443,
+				RequestTime: 14, ResponseTime: 12, Status: 200, Bytes: 1543, Termination: "----",
+				Host: "2001:db8::10", Method: "GET", Path: "/", Protocol: "HTTP/1.1",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		got, ok := parseLine(tt.line, received)
+		if !ok {
+			t.Errorf("%s: parseLine rejected the line", tt.name)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%s: parseLine =\n%+v\nwant\n%+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestParseLineRejectsNonHTTP(t *testing.T) {
+	lines := []string{
+		// tcplog
+		`192.168.1.100:52134 [18/Mar/2024:08:12:33.123] fe_2222 be_ssh/srv1 1/0/5 1024 -- 1/1/1/1/0 0/0`,
+		`<134>Mar 18 08:12:33 waf haproxy[123]: 192.168.1.100:52134 [18/Mar/2024:08:12:33.123] fe_2222 be_ssh/srv1 1/-1/5003 0 sC 0/0/0/0/3 0/0`,
+		// 启动和健康检查消息
+		`<133>Mar 18 08:12:33 waf haproxy[123]: Proxy fe_80_http started.`,
+		`Server be_example_com/srv1 is DOWN, reason: Layer4 connection problem, info: "Connection refused", check duration: 0ms.`,
+		"",
+		"<134>",
+	}
+	for _, line := range lines {
+		if entry, ok := parseLine(line, time.Now()); ok {
+			t.Errorf("parseLine(%q) = %+v, want rejection", line, entry)
+		}
+	}
+}
+
+func TestParseLineKeepsReceivedTimeOnBadTimestamp(t *testing.T) {
+	received := time.Date(2024, 3, 18, 0, 0, 0, 0, time.UTC)
+	line := `192.168.1.100:52134 [18/Mar/2024:08:12:33] fe_80_http be_example_com/srv1 10/0/30/69/109 200 2750 - - ---- 1/1/1/1/0 0/0 "GET / HTTP/1.1"`
+	entry, ok := parseLine(line, received)
+	if !ok || !entry.Timestamp.Equal(received) {
+		t.Errorf("parseLine = %v, %v, want received time", entry.Timestamp, ok)
+	}
+}
+
+func TestFrontendPort(t *testing.T) {
+	tests := map[string]int{
+		"fe_80_http":     80,
+		"fe_8443_https":  8443,
+		"fe_2222":        2222,
+		"coraza-spoa":    0,
+		"fe_http":        0,
+		"stats_frontend": 0,
+	}
+	for name, want := range tests {
+		if got := frontendPort(name); got != want {
+			t.Errorf("frontendPort(%q) = %d, want %d", name, got, want)
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package accesslog
+
+import (
+	"strings"
+
+	"github.com/HUAHUAI23/simple-waf/server/model"
+)
+
+// SiteResolver 按监听端口和请求的 Host 把访问日志归属到站点，与 HAProxy 的主机名 ACL 规则一致
+type SiteResolver struct {
+	ports map[int][]model.Site
+}
+
+func NewSiteResolver(sites []model.Site) *SiteResolver {
+	r := &SiteResolver{ports: make(map[int][]model.Site)}
+	for _, site := range sites {
+		if !site.ActiveStatus || site.IsTCP() {
+			continue
+		}
+		r.ports[site.ListenPort] = append(r.ports[site.ListenPort], site)
+	}
+	return r
+}
+
+// Resolve 精确主机名优先，其次是后缀最长的通配符，找不到时返回 nil
+func (r *SiteResolver) Resolve(port int, host string) *model.Site {
+	if r == nil || host == "" {
+		return nil
+	}
+
+	var matched *model.Site
+	longest := 0
+	for i := range r.ports[port] {
+		site := &r.ports[port][i]
+		for _, pattern := range site.Hosts() {
+			pattern = strings.ToLower(pattern)
+			if !model.IsWildcardHost(pattern) {
+				if pattern == host {
+					return site
+				}
+				continue
+			}
+			if len(pattern) > longest && model.HostsOverlap(host, pattern) {
+				matched, longest = site, len(pattern)
+			}
+		}
+	}
+	return matched
+}
@@ -0,0 +1,343 @@
+package alert
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/HUAHUAI23/simple-waf/server/model"
+	"github.com/HUAHUAI23/simple-waf/server/repository"
+	"github.com/rs/zerolog"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// AlertEngineImpl 告警引擎实现
+type AlertEngineImpl struct {
+	ruleRepo    repository.AlertRuleRepository
+	historyRepo repository.AlertHistoryRepository
+	wafLogRepo  repository.WAFLogRepository
+	certRepo    repository.CertificateRepository
+	notifier    Notifier
+	stateFunc   StateFunc
+	interval    time.Duration
+	logger      zerolog.Logger
+
+	mu         sync.Mutex
+	running    bool
+	cancel     context.CancelFunc
+	done       chan struct{}
+	lastStates map[string]string // 规则ID -> 上次观察到的引擎状态
+}
+
+// alertEvent 一次规则评估产生的待投递事件
+type alertEvent struct {
+	fingerprint string
+	title       string
+	message     string
+	fields      map[string]any
+}
+
+// Start 启动后台评估循环
+func (e *AlertEngineImpl) Start() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.running {
+		return errors.New("alert engine already running")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	e.cancel = cancel
+	e.done = make(chan struct{})
+	e.running = true
+
+	go e.loop(ctx)
+
+	e.logger.Info().Dur("interval", e.interval).Msg("告警引擎已启动")
+	return nil
+}
+
+// Stop 停止后台评估循环并等待当前评估结束
+func (e *AlertEngineImpl) Stop() error {
+	e.mu.Lock()
+	if !e.running {
+		e.mu.Unlock()
+		return nil
+	}
+	e.cancel()
+	done := e.done
+	e.running = false
+	e.mu.Unlock()
+
+	<-done
+	e.logger.Info().Msg("告警引擎已停止")
+	return nil
+}
+
+func (e *AlertEngineImpl) loop(ctx context.Context) {
+	defer close(e.done)
+
+	ticker := time.NewTicker(e.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			evalCtx, cancel := context.WithTimeout(ctx, e.interval)
+			if err := e.Evaluate(evalCtx); err != nil && ctx.Err() == nil {
+				e.logger.Error().Err(err).Msg("评估告警规则失败")
+			}
+			cancel()
+		}
+	}
+}
+
+// Evaluate 评估所有启用的告警规则，并投递命中的告警
+func (e *AlertEngineImpl) Evaluate(ctx context.Context) error {
+	rules, err := e.ruleRepo.GetEnabledAlertRules(ctx)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	for i := range rules {
+		rule := &rules[i]
+
+		events, err := e.evaluateRule(ctx, rule, now)
+		if err != nil {
+			e.logger.Error().Err(err).Str("rule", rule.Name).Msg("评估告警规则出错")
+			continue
+		}
+
+		delivered := false
+		for _, event := range events {
+			if e.deliver(ctx, rule, event, now) {
+				delivered = true
+			}
+		}
+
+		if delivered {
+			if err := e.ruleRepo.UpdateLastTriggered(ctx, rule.ID, now); err != nil {
+				e.logger.Warn().Err(err).Str("rule", rule.Name).Msg("更新告警规则触发时间失败")
+			}
+		}
+	}
+
+	return nil
+}
+
+func (e *AlertEngineImpl) evaluateRule(ctx context.Context, rule *model.AlertRule, now time.Time) ([]alertEvent, error) {
+	switch rule.Type {
+	case model.AlertRuleIPThreshold:
+		return e.evaluateIPThreshold(ctx, rule, now)
+	case model.AlertRuleRuleFired:
+		return e.evaluateRuleFired(ctx, rule, now)
+	case model.AlertRuleEngineState:
+		return e.evaluateEngineState(rule), nil
+	case model.AlertRuleCertExpiry:
+		return e.evaluateCertExpiry(ctx, rule, now)
+	default:
+		return nil, fmt.Errorf("unknown alert rule type: %s", rule.Type)
+	}
+}
+
+// windowFilter 构建时间窗口与站点过滤条件
+func windowFilter(rule *model.AlertRule, now time.Time) bson.D {
+	filter := bson.D{
+		{Key: "createdAt", Value: bson.D{{Key: "$gte", Value: now.Add(-time.Duration(rule.WindowMinutes) * time.Minute)}}},
+	}
+	if rule.Domain != "" {
+		filter = append(filter, bson.E{Key: "domain", Value: rule.Domain})
+	}
+	return filter
+}
+
+func (e *AlertEngineImpl) evaluateIPThreshold(ctx context.Context, rule *model.AlertRule, now time.Time) ([]alertEvent, error) {
+	results, err := e.wafLogRepo.AggregateSrcIPCounts(ctx, windowFilter(rule, now), rule.Threshold)
+	if err != nil {
+		return nil, err
+	}
+
+	events := make([]alertEvent, 0, len(results))
+	for _, r := range results {
+		events = append(events, alertEvent{
+			fingerprint: fmt.Sprintf("%s:%s", rule.ID.Hex(), r.SrcIP),
+			title:       fmt.Sprintf("[WAF] IP %s 拦截次数超过阈值", r.SrcIP),
+			message: fmt.Sprintf("来源IP %s 在最近 %d 分钟内被拦截 %d 次（阈值 %d）%s，最近一次拦截时间 %s",
+				r.SrcIP, rule.WindowMinutes, r.Count, rule.Threshold, domainSuffix(rule.Domain), r.LastAttackTime.Format(time.RFC3339)),
+			fields: map[string]any{
+				"srcIp":          r.SrcIP,
+				"count":          r.Count,
+				"threshold":      rule.Threshold,
+				"windowMinutes":  rule.WindowMinutes,
+				"domain":         rule.Domain,
+				"lastAttackTime": r.LastAttackTime,
+			},
+		})
+	}
+	return events, nil
+}
+
+func (e *AlertEngineImpl) evaluateRuleFired(ctx context.Context, rule *model.AlertRule, now time.Time) ([]alertEvent, error) {
+	filter := append(windowFilter(rule, now), bson.E{Key: "ruleId", Value: rule.RuleID})
+	count, err := e.wafLogRepo.CountAttackLogs(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	if count == 0 {
+		return nil, nil
+	}
+
+	return []alertEvent{{
+		fingerprint: fmt.Sprintf("%s:%d:%s", rule.ID.Hex(), rule.RuleID, rule.Domain),
+		title:       fmt.Sprintf("[WAF] 规则 %d 已触发", rule.RuleID),
+		message: fmt.Sprintf("规则 %d 在最近 %d 分钟内触发 %d 次%s",
+			rule.RuleID, rule.WindowMinutes, count, domainSuffix(rule.Domain)),
+		fields: map[string]any{
+			"ruleId":        rule.RuleID,
+			"count":         count,
+			"windowMinutes": rule.WindowMinutes,
+			"domain":        rule.Domain,
+		},
+	}}, nil
+}
+
+// evaluateEngineState 仅在状态切换到目标状态时产生告警，引擎持续处于该状态不会重复告警
+func (e *AlertEngineImpl) evaluateEngineState(rule *model.AlertRule) []alertEvent {
+	if e.stateFunc == nil {
+		return nil
+	}
+
+	current := e.stateFunc()
+	key := rule.ID.Hex()
+
+	e.mu.Lock()
+	previous, seen := e.lastStates[key]
+	e.lastStates[key] = current
+	e.mu.Unlock()
+
+	if current != rule.EngineState || (seen && previous == current) {
+		return nil
+	}
+	if !seen {
+		previous = "unknown"
+	}
+
+	return []alertEvent{{
+		fingerprint: fmt.Sprintf("%s:%s", key, current),
+		title:       fmt.Sprintf("[WAF] 引擎状态变为 %s", current),
+		message:     fmt.Sprintf("WAF 引擎状态由 %s 变为 %s", previous, current),
+		fields: map[string]any{
+			"previousState": previous,
+			"currentState":  current,
+		},
+	}}
+}
+
+func (e *AlertEngineImpl) evaluateCertExpiry(ctx context.Context, rule *model.AlertRule, now time.Time) ([]alertEvent, error) {
+	certs, err := e.certRepo.GetExpiringCertificates(ctx, now.AddDate(0, 0, rule.ExpiryDays))
+	if err != nil {
+		return nil, err
+	}
+
+	events := make([]alertEvent, 0, len(certs))
+	for _, cert := range certs {
+		if rule.Domain != "" && !containsDomain(cert.Domains, rule.Domain) {
+			continue
+		}
+
+		days := int(cert.ExpireDate.Sub(now).Hours() / 24)
+		var message string
+		if days < 0 {
+			message = fmt.Sprintf("证书 %s（%s）已于 %s 过期", cert.Name, strings.Join(cert.Domains, ","), cert.ExpireDate.Format(time.RFC3339))
+		} else {
+			message = fmt.Sprintf("证书 %s（%s）将在 %d 天后过期，过期时间 %s", cert.Name, strings.Join(cert.Domains, ","), days, cert.ExpireDate.Format(time.RFC3339))
+		}
+
+		events = append(events, alertEvent{
+			fingerprint: fmt.Sprintf("%s:%s", rule.ID.Hex(), cert.ID.Hex()),
+			title:       fmt.Sprintf("[WAF] 证书 %s 即将过期", cert.Name),
+			message:     message,
+			fields: map[string]any{
+				"certificateId": cert.ID.Hex(),
+				"name":          cert.Name,
+				"domains":       cert.Domains,
+				"expireDate":    cert.ExpireDate,
+				"daysLeft":      days,
+			},
+		})
+	}
+	return events, nil
+}
+
+// deliver 在冷却期外向规则的所有通道投递告警，并记录每个通道的投递结果
+func (e *AlertEngineImpl) deliver(ctx context.Context, rule *model.AlertRule, event alertEvent, now time.Time) bool {
+	last, err := e.historyRepo.GetLastDeliveredAt(ctx, event.fingerprint)
+	if err != nil {
+		e.logger.Warn().Err(err).Str("fingerprint", event.fingerprint).Msg("查询告警投递记录失败")
+		return false
+	}
+	if !last.IsZero() && now.Sub(last) < time.Duration(rule.CooldownMinutes)*time.Minute {
+		return false
+	}
+
+	notification := Notification{
+		RuleName:  rule.Name,
+		RuleType:  rule.Type,
+		Title:     event.title,
+		Message:   event.message,
+		Fields:    event.fields,
+		Timestamp: now,
+	}
+
+	delivered := false
+	for _, channel := range rule.Channels {
+		sendErr := e.notifier.Send(ctx, channel, notification)
+
+		history := &model.AlertHistory{
+			RuleID:      rule.ID,
+			RuleName:    rule.Name,
+			RuleType:    rule.Type,
+			Fingerprint: event.fingerprint,
+			Title:       event.title,
+			Message:     event.message,
+			Channel:     channel.Type,
+			Target:      ChannelTarget(channel),
+			Success:     sendErr == nil,
+			CreatedAt:   time.Now(),
+		}
+		if sendErr != nil {
+			history.Error = sendErr.Error()
+			e.logger.Warn().Err(sendErr).Str("rule", rule.Name).Str("channel", string(channel.Type)).Msg("投递告警失败")
+		} else {
+			delivered = true
+		}
+
+		if err := e.historyRepo.CreateAlertHistory(ctx, history); err != nil {
+			e.logger.Warn().Err(err).Str("rule", rule.Name).Msg("写入告警投递记录失败")
+		}
+	}
+
+	return delivered
+}
+
+func domainSuffix(domain string) string {
+	if domain == "" {
+		return ""
+	}
+	return fmt.Sprintf("，站点 %s", domain)
+}
+
+func containsDomain(domains []string, domain string) bool {
+	for _, d := range domains {
+		if strings.EqualFold(d, domain) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,58 @@
+package alert
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/HUAHUAI23/simple-waf/server/config"
+	"github.com/HUAHUAI23/simple-waf/server/model"
+	"github.com/HUAHUAI23/simple-waf/server/repository"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+// AlertEngine 周期性评估告警规则并投递通知
+type AlertEngine interface {
+	Start() error
+	Stop() error
+	Evaluate(ctx context.Context) error
+}
+
+// Notifier 向单个通道投递一条通知
+type Notifier interface {
+	Send(ctx context.Context, channel model.AlertChannel, n Notification) error
+}
+
+// StateFunc 返回当前后台运行器状态（running/stopped/error）
+type StateFunc func() string
+
+const defaultEvalInterval = time.Minute
+
+// NewAlertEngine 创建告警引擎
+func NewAlertEngine(db *mongo.Database, stateFunc StateFunc, interval time.Duration) AlertEngine {
+	if interval <= 0 {
+		interval = defaultEvalInterval
+	}
+
+	logger := config.GetLogger().With().Str("component", "alert").Logger()
+
+	return &AlertEngineImpl{
+		ruleRepo:    repository.NewAlertRuleRepository(db),
+		historyRepo: repository.NewAlertHistoryRepository(db),
+		wafLogRepo:  repository.NewWAFLogRepository(db),
+		certRepo:    repository.NewCertificateRepository(db),
+		notifier:    NewNotifier(10 * time.Second),
+		stateFunc:   stateFunc,
+		interval:    interval,
+		lastStates:  make(map[string]string),
+		logger:      logger,
+	}
+}
+
+// NewNotifier 创建按通道类型分发的通知发送器，timeout 同时作用于 HTTP 请求和 SMTP 连接
+func NewNotifier(timeout time.Duration) Notifier {
+	return &NotifierImpl{
+		httpClient: &http.Client{Timeout: timeout},
+		timeout:    timeout,
+	}
+}
@@ -0,0 +1,200 @@
+package alert
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/HUAHUAI23/simple-waf/server/model"
+)
+
+// Notification 一条待投递的告警通知
+type Notification struct {
+	RuleName  string              `json:"ruleName"`
+	RuleType  model.AlertRuleType `json:"ruleType"`
+	Title     string              `json:"title"`
+	Message   string              `json:"message"`
+	Fields    map[string]any      `json:"fields,omitempty"`
+	Timestamp time.Time           `json:"timestamp"`
+}
+
+// NotifierImpl 按通道类型分发通知
+type NotifierImpl struct {
+	httpClient *http.Client
+	timeout    time.Duration
+}
+
+// Send 投递通知到指定通道
+func (n *NotifierImpl) Send(ctx context.Context, channel model.AlertChannel, notification Notification) error {
+	switch channel.Type {
+	case model.AlertChannelWebhook:
+		return n.sendWebhook(ctx, channel, notification)
+	case model.AlertChannelHTTP:
+		return n.sendHTTP(ctx, channel, notification)
+	case model.AlertChannelSMTP:
+		return n.sendSMTP(ctx, channel, notification)
+	default:
+		return fmt.Errorf("unsupported alert channel type: %s", channel.Type)
+	}
+}
+
+// ChannelTarget 返回通道的投递目标描述，用于历史记录
+func ChannelTarget(channel model.AlertChannel) string {
+	if channel.Type == model.AlertChannelSMTP && channel.SMTP != nil {
+		return strings.Join(channel.SMTP.To, ",")
+	}
+	return channel.URL
+}
+
+// sendWebhook 发送聊天机器人格式的文本消息，兼容 Slack / Mattermost / Rocket.Chat 等 incoming webhook
+func (n *NotifierImpl) sendWebhook(ctx context.Context, channel model.AlertChannel, notification Notification) error {
+	body, err := json.Marshal(map[string]any{
+		"text": fmt.Sprintf("%s\n%s", notification.Title, notification.Message),
+	})
+	if err != nil {
+		return fmt.Errorf("marshal webhook payload: %w", err)
+	}
+	return n.doHTTP(ctx, http.MethodPost, channel.URL, channel.Headers, body)
+}
+
+// sendHTTP 以通用 JSON 格式发送完整通知
+func (n *NotifierImpl) sendHTTP(ctx context.Context, channel model.AlertChannel, notification Notification) error {
+	body, err := json.Marshal(notification)
+	if err != nil {
+		return fmt.Errorf("marshal http payload: %w", err)
+	}
+	method := channel.Method
+	if method == "" {
+		method = http.MethodPost
+	}
+	return n.doHTTP(ctx, method, channel.URL, channel.Headers, body)
+}
+
+func (n *NotifierImpl) doHTTP(ctx context.Context, method, url string, headers map[string]string, body []byte) error {
+	if url == "" {
+		return fmt.Errorf("alert channel url is empty")
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("build request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := n.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		snippet, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("unexpected status %d: %s", resp.StatusCode, strings.TrimSpace(string(snippet)))
+	}
+
+	return nil
+}
+
+// sendSMTP 通过 SMTP 发送邮件，支持 STARTTLS 和 PLAIN 认证
+func (n *NotifierImpl) sendSMTP(ctx context.Context, channel model.AlertChannel, notification Notification) error {
+	cfg := channel.SMTP
+	if cfg == nil {
+		return fmt.Errorf("smtp config is empty")
+	}
+	if len(cfg.To) == 0 {
+		return fmt.Errorf("smtp recipients are empty")
+	}
+
+	addr := net.JoinHostPort(cfg.Host, strconv.Itoa(cfg.Port))
+	dialer := &net.Dialer{Timeout: n.timeout}
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return fmt.Errorf("dial smtp %s: %w", addr, err)
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	} else {
+		_ = conn.SetDeadline(time.Now().Add(n.timeout))
+	}
+
+	client, err := smtp.NewClient(conn, cfg.Host)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("smtp handshake: %w", err)
+	}
+	defer client.Close()
+
+	if cfg.StartTLS {
+		tlsConfig := &tls.Config{
+			ServerName:         cfg.Host,
+			InsecureSkipVerify: cfg.Insecure,
+		}
+		if err := client.StartTLS(tlsConfig); err != nil {
+			return fmt.Errorf("smtp starttls: %w", err)
+		}
+	}
+
+	if cfg.Username != "" {
+		if err := client.Auth(smtp.PlainAuth("", cfg.Username, cfg.Password, cfg.Host)); err != nil {
+			return fmt.Errorf("smtp auth: %w", err)
+		}
+	}
+
+	if err := client.Mail(cfg.From); err != nil {
+		return fmt.Errorf("smtp mail from: %w", err)
+	}
+	for _, rcpt := range cfg.To {
+		if err := client.Rcpt(rcpt); err != nil {
+			return fmt.Errorf("smtp rcpt %s: %w", rcpt, err)
+		}
+	}
+
+	w, err := client.Data()
+	if err != nil {
+		return fmt.Errorf("smtp data: %w", err)
+	}
+	if _, err := w.Write(buildMail(cfg, notification)); err != nil {
+		w.Close()
+		return fmt.Errorf("smtp write body: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("smtp close body: %w", err)
+	}
+
+	return client.Quit()
+}
+
+// buildMail 构建纯文本邮件内容
+func buildMail(cfg *model.SMTPConfig, notification Notification) []byte {
+	subject := notification.Title
+	if cfg.Subject != "" {
+		subject = cfg.Subject + " " + subject
+	}
+	// 主题中的非 ASCII 字符按 RFC 2047 编码，换行会破坏邮件头，替换为空格
+	subject = mime.BEncoding.Encode("UTF-8", strings.NewReplacer("\r", " ", "\n", " ").Replace(subject))
+
+	var buf bytes.Buffer
+	buf.WriteString("From: " + cfg.From + "\r\n")
+	buf.WriteString("To: " + strings.Join(cfg.To, ", ") + "\r\n")
+	buf.WriteString("Subject: " + subject + "\r\n")
+	buf.WriteString("Date: " + notification.Timestamp.Format(time.RFC1123Z) + "\r\n")
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	buf.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	buf.WriteString("\r\n")
+	buf.WriteString(strings.ReplaceAll(notification.Message, "\n", "\r\n"))
+	buf.WriteString("\r\n")
+	return buf.Bytes()
+}
@@ -0,0 +1,258 @@
+package alert
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/HUAHUAI23/simple-waf/server/model"
+)
+
+func testNotification() Notification {
+	return Notification{
+		RuleName:  "ip-block-burst",
+		RuleType:  model.AlertRuleIPThreshold,
+		Title:     "IP 拦截次数超过阈值",
+		Message:   "1.2.3.4 在 5 分钟内被拦截 120 次\n请检查",
+		Timestamp: time.Date(2024, 3, 17, 8, 0, 0, 0, time.UTC),
+	}
+}
+
+func TestSendWebhook(t *testing.T) {
+	var got struct {
+		Text string `json:"text"`
+	}
+	var header string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			t.Errorf("method = %s, want POST", r.Method)
+		}
+		header = r.Header.Get("X-Token")
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Errorf("decode body: %v", err)
+		}
+	}))
+	defer server.Close()
+
+	notifier := NewNotifier(5 * time.Second)
+	channel := model.AlertChannel{
+		Type:    model.AlertChannelWebhook,
+		URL:     server.URL,
+		Headers: map[string]string{"X-Token": "secret"},
+	}
+	n := testNotification()
+	if err := notifier.Send(context.Background(), channel, n); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	if want := n.Title + "\n" + n.Message; got.Text != want {
+		t.Errorf("text = %q, want %q", got.Text, want)
+	}
+	if header != "secret" {
+		t.Errorf("X-Token = %q, want secret", header)
+	}
+}
+
+func TestSendWebhookStatusError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "invalid token", http.StatusForbidden)
+	}))
+	defer server.Close()
+
+	notifier := NewNotifier(5 * time.Second)
+	err := notifier.Send(context.Background(), model.AlertChannel{Type: model.AlertChannelWebhook, URL: server.URL}, testNotification())
+	if err == nil {
+		t.Fatal("Send succeeded, want error for status 403")
+	}
+	if !strings.Contains(err.Error(), "403") || !strings.Contains(err.Error(), "invalid token") {
+		t.Errorf("error = %v, want status and response body", err)
+	}
+}
+
+func TestSendHTTP(t *testing.T) {
+	var got Notification
+	var method string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method = r.Method
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Errorf("decode body: %v", err)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	notifier := NewNotifier(5 * time.Second)
+	n := testNotification()
+	channel := model.AlertChannel{Type: model.AlertChannelHTTP, URL: server.URL, Method: http.MethodPut}
+	if err := notifier.Send(context.Background(), channel, n); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	if method != http.MethodPut {
+		t.Errorf("method = %s, want PUT", method)
+	}
+	if got.RuleName != n.RuleName || got.Title != n.Title || got.Message != n.Message || !got.Timestamp.Equal(n.Timestamp) {
+		t.Errorf("payload = %+v, want %+v", got, n)
+	}
+}
+
+// smtpSession 假 SMTP 服务器收到的一封邮件
+type smtpSession struct {
+	auth string
+	from string
+	rcpt []string
+	data string
+}
+
+// fakeSMTPServer 在本地监听并处理一次 SMTP 会话，会话结束后将收到的内容写入返回的通道
+func fakeSMTPServer(t *testing.T) (string, int, <-chan smtpSession) {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	sessions := make(chan smtpSession, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		r := bufio.NewReader(conn)
+		reply := func(line string) { io.WriteString(conn, line+"\r\n") }
+		var session smtpSession
+
+		reply("220 localhost ESMTP")
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			line = strings.TrimRight(line, "\r\n")
+			cmd := strings.ToUpper(line)
+			switch {
+			case strings.HasPrefix(cmd, "EHLO"):
+				reply("250-localhost")
+				reply("250 AUTH PLAIN")
+			case strings.HasPrefix(cmd, "AUTH PLAIN"):
+				session.auth = strings.TrimSpace(line[len("AUTH PLAIN"):])
+				reply("235 2.7.0 Authentication successful")
+			case strings.HasPrefix(cmd, "MAIL FROM:"):
+				session.from = line[len("MAIL FROM:"):]
+				reply("250 OK")
+			case strings.HasPrefix(cmd, "RCPT TO:"):
+				session.rcpt = append(session.rcpt, line[len("RCPT TO:"):])
+				reply("250 OK")
+			case cmd == "DATA":
+				reply("354 End data with <CR><LF>.<CR><LF>")
+				var data strings.Builder
+				for {
+					line, err := r.ReadString('\n')
+					if err != nil {
+						return
+					}
+					if line == ".\r\n" {
+						break
+					}
+					data.WriteString(line)
+				}
+				session.data = data.String()
+				reply("250 OK")
+			case cmd == "QUIT":
+				reply("221 Bye")
+				sessions <- session
+				return
+			default:
+				reply("502 Command not implemented")
+			}
+		}
+	}()
+
+	host, portStr, _ := net.SplitHostPort(ln.Addr().String())
+	port, _ := strconv.Atoi(portStr)
+	return host, port, sessions
+}
+
+func TestSendSMTP(t *testing.T) {
+	host, port, sessions := fakeSMTPServer(t)
+
+	notifier := NewNotifier(5 * time.Second)
+	channel := model.AlertChannel{
+		Type: model.AlertChannelSMTP,
+		SMTP: &model.SMTPConfig{
+			Host:     host,
+			Port:     port,
+			Username: "alert@example.com",
+			Password: "secret",
+			From:     "alert@example.com",
+			To:       []string{"ops@example.com", "sec@example.com"},
+			Subject:  "[WAF]",
+		},
+	}
+	n := testNotification()
+	if err := notifier.Send(context.Background(), channel, n); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	var session smtpSession
+	select {
+	case session = <-sessions:
+	case <-time.After(5 * time.Second):
+		t.Fatal("fake smtp server did not receive the mail")
+	}
+
+	if session.auth == "" {
+		t.Error("client did not authenticate")
+	}
+	if session.from != "<alert@example.com>" {
+		t.Errorf("MAIL FROM = %q", session.from)
+	}
+	if len(session.rcpt) != 2 || session.rcpt[0] != "<ops@example.com>" || session.rcpt[1] != "<sec@example.com>" {
+		t.Errorf("RCPT TO = %q", session.rcpt)
+	}
+
+	headers, body, _ := strings.Cut(session.data, "\r\n\r\n")
+	var subject string
+	for _, line := range strings.Split(headers, "\r\n") {
+		if value, ok := strings.CutPrefix(line, "Subject: "); ok {
+			subject = value
+		}
+	}
+	if !strings.HasPrefix(subject, "=?UTF-8?b?") {
+		t.Errorf("Subject = %q, want RFC 2047 encoded word", subject)
+	}
+	decoded, err := new(mime.WordDecoder).DecodeHeader(subject)
+	if err != nil {
+		t.Fatalf("decode subject: %v", err)
+	}
+	if want := "[WAF] " + n.Title; decoded != want {
+		t.Errorf("decoded subject = %q, want %q", decoded, want)
+	}
+	if want := strings.ReplaceAll(n.Message, "\n", "\r\n") + "\r\n"; body != want {
+		t.Errorf("body = %q, want %q", body, want)
+	}
+}
+
+func TestBuildMailASCIISubject(t *testing.T) {
+	cfg := &model.SMTPConfig{From: "alert@example.com", To: []string{"ops@example.com"}}
+	n := testNotification()
+	n.Title = "engine stopped\r\nBcc: evil@example.com"
+
+	mail := string(buildMail(cfg, n))
+	if !strings.Contains(mail, "Subject: engine stopped  Bcc: evil@example.com\r\n") {
+		t.Errorf("mail headers = %q, want line breaks removed from subject", mail)
+	}
+}
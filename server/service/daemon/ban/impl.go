@@ -0,0 +1,458 @@
+package ban
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"net"
+	"slices"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/HUAHUAI23/simple-waf/server/model"
+	"github.com/HUAHUAI23/simple-waf/server/repository"
+	"github.com/rs/zerolog"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// ManagerImpl 自动封禁管理器实现
+//
+// WAF 日志和 Suricata 告警按 _id 增量扫描，每个策略为每个来源IP维护一个滑动窗口，
+// 窗口内事件数达到阈值时创建封禁记录。扫描进度只保存在内存中，启动时从最长策略窗口之前开始扫描，
+// 早于该IP最近一次封禁的事件不再计数，因此重启不会重复封禁。
+type ManagerImpl struct {
+	policyRepo   repository.BanPolicyRepository
+	banRepo      repository.BanRepository
+	wafLogRepo   repository.WAFLogRepository
+	suricataRepo repository.SuricataRepository
+	enforcer     Enforcer
+	interval     time.Duration
+	exempt       []*net.IPNet
+	logger       zerolog.Logger
+
+	mu      sync.Mutex
+	running bool
+	cancel  context.CancelFunc
+	done    chan struct{}
+	notify  chan struct{}
+
+	forceSync atomic.Bool
+
+	// 以下字段只在持有 evalMu 时访问
+	evalMu      sync.Mutex
+	initialized bool
+	lastWAF     bson.ObjectID
+	lastIDS     bson.ObjectID
+	windows     map[bson.ObjectID]map[string][]model.BanEvent // 策略ID -> IP -> 窗口内事件
+	lastBan     map[string]time.Time
+	synced      map[string]string
+	syncedAt    time.Time
+}
+
+// candidate 一条待评估的事件
+type candidate struct {
+	ip     string
+	action string // WAF 拦截动作
+	event  model.BanEvent
+}
+
+// Start 启动后台评估循环
+func (m *ManagerImpl) Start() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.running {
+		return errors.New("ban manager already running")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	m.cancel = cancel
+	m.done = make(chan struct{})
+	m.running = true
+
+	go m.loop(ctx)
+
+	m.logger.Info().Dur("interval", m.interval).Int("exempt", len(m.exempt)).Msg("自动封禁管理器已启动")
+	return nil
+}
+
+// Stop 停止后台评估循环并等待当前评估结束
+func (m *ManagerImpl) Stop() error {
+	m.mu.Lock()
+	if !m.running {
+		m.mu.Unlock()
+		return nil
+	}
+	m.cancel()
+	done := m.done
+	m.running = false
+	m.mu.Unlock()
+
+	<-done
+	m.logger.Info().Msg("自动封禁管理器已停止")
+	return nil
+}
+
+// Notify 请求在下一轮评估中强制同步封禁列表，并立即触发一轮评估
+func (m *ManagerImpl) Notify() {
+	m.forceSync.Store(true)
+
+	select {
+	case m.notify <- struct{}{}:
+	default:
+	}
+}
+
+func (m *ManagerImpl) loop(ctx context.Context) {
+	defer close(m.done)
+
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-m.notify:
+		}
+		if err := m.Evaluate(ctx); err != nil && ctx.Err() == nil {
+			m.logger.Error().Err(err).Msg("评估封禁策略失败")
+		}
+	}
+}
+
+// Evaluate 处理到期封禁，按策略评估新增事件，并将生效中的封禁同步到 HAProxy
+func (m *ManagerImpl) Evaluate(ctx context.Context) error {
+	m.evalMu.Lock()
+	defer m.evalMu.Unlock()
+
+	now := time.Now()
+
+	policies, err := m.policyRepo.GetEnabledBanPolicies(ctx)
+	if err != nil {
+		return err
+	}
+	maxWindow := m.interval
+	for _, p := range policies {
+		if w := time.Duration(p.WindowSeconds) * time.Second; w > maxWindow {
+			maxWindow = w
+		}
+	}
+
+	if !m.initialized {
+		start := bson.NewObjectIDFromTimestamp(now.Add(-maxWindow))
+		m.lastWAF, m.lastIDS = start, start
+		m.windows = make(map[bson.ObjectID]map[string][]model.BanEvent)
+		m.initialized = true
+	}
+
+	expired, err := m.banRepo.ExpireBans(ctx, now)
+	if err != nil {
+		return err
+	}
+	if expired > 0 {
+		m.logger.Info().Int64("count", expired).Msg("封禁已到期")
+	}
+
+	active, err := m.banRepo.GetActiveBans(ctx)
+	if err != nil {
+		return err
+	}
+	banned := make(map[string]bool, len(active))
+	for _, b := range active {
+		banned[b.IP] = true
+	}
+
+	m.lastBan, err = m.banRepo.GetLastBanTimes(ctx, now.Add(-maxWindow))
+	if err != nil {
+		return err
+	}
+
+	created := 0
+	if len(policies) == 0 {
+		// 没有启用的策略时直接跳过积压事件，避免启用策略后按旧事件封禁
+		m.windows = make(map[bson.ObjectID]map[string][]model.BanEvent)
+		m.skipTo(bson.NewObjectIDFromTimestamp(now))
+	} else {
+		if created, err = m.scan(ctx, policies, banned); err != nil {
+			return err
+		}
+		m.prune(now, policies)
+	}
+
+	force := m.forceSync.Swap(false) || created > 0 || expired > 0
+	return m.sync(ctx, force)
+}
+
+// scan 读取上次位置之后的 WAF 日志和 Suricata 告警并逐条评估，返回新建的封禁数
+func (m *ManagerImpl) scan(ctx context.Context, policies []model.BanPolicy, banned map[string]bool) (int, error) {
+	created := 0
+
+	for {
+		logs, err := m.wafLogRepo.FindAttackLogsAfterID(ctx, m.lastWAF, batchSize)
+		if err != nil {
+			return created, err
+		}
+		for i := range logs {
+			log := &logs[i]
+			n, err := m.observe(ctx, policies, banned, candidate{
+				ip:     log.SrcIP,
+				action: log.Action,
+				event: model.BanEvent{
+					Source:   model.BanSourceWAF,
+					EventID:  log.ID,
+					Time:     log.CreatedAt,
+					RuleID:   log.RuleID,
+					Severity: log.Severity,
+					Message:  log.Message,
+					URI:      log.URI,
+					Host:     log.Domain,
+				},
+			})
+			if err != nil {
+				return created, err
+			}
+			created += n
+			m.lastWAF = log.ID
+		}
+		if len(logs) < batchSize {
+			break
+		}
+	}
+
+	for {
+		records, err := m.suricataRepo.FindEventsAfterID(ctx, m.lastIDS, batchSize)
+		if err != nil {
+			return created, err
+		}
+		for i := range records {
+			record := &records[i]
+			m.lastIDS = record.ID
+			if record.SignatureID == 0 {
+				continue
+			}
+			c := candidate{
+				ip: record.SrcIP,
+				event: model.BanEvent{
+					Source:  model.BanSourceIDS,
+					EventID: record.ID,
+					Time:    record.Timestamp,
+					RuleID:  record.SignatureID,
+					Message: record.Msg,
+				},
+			}
+			if record.Alert != nil {
+				c.event.Severity = record.Alert.Severity
+			} else {
+				c.event.Severity, _ = strconv.Atoi(record.Severity)
+			}
+			if record.HTTP != nil {
+				c.event.URI = record.HTTP.URL
+				c.event.Host = record.HTTP.Hostname
+			} else if record.TLS != nil {
+				c.event.Host = record.TLS.SNI
+			}
+			n, err := m.observe(ctx, policies, banned, c)
+			if err != nil {
+				return created, err
+			}
+			created += n
+		}
+		if len(records) < batchSize {
+			break
+		}
+	}
+
+	return created, nil
+}
+
+// observe 将事件加入匹配策略的滑动窗口，达到阈值时封禁来源IP
+func (m *ManagerImpl) observe(ctx context.Context, policies []model.BanPolicy, banned map[string]bool, c candidate) (int, error) {
+	ip := net.ParseIP(c.ip)
+	if ip == nil || m.isExempt(ip) {
+		return 0, nil
+	}
+	c.ip = ip.String()
+	if banned[c.ip] {
+		return 0, nil
+	}
+	// 上一次封禁之前的事件已经计入过封禁，不再重复计数
+	if last, ok := m.lastBan[c.ip]; ok && !c.event.Time.After(last) {
+		return 0, nil
+	}
+
+	for i := range policies {
+		p := &policies[i]
+		if !matches(p, &c) {
+			continue
+		}
+
+		ipWindows := m.windows[p.ID]
+		if ipWindows == nil {
+			ipWindows = make(map[string][]model.BanEvent)
+			m.windows[p.ID] = ipWindows
+		}
+
+		cutoff := c.event.Time.Add(-time.Duration(p.WindowSeconds) * time.Second)
+		events := slices.DeleteFunc(append(ipWindows[c.ip], c.event), func(e model.BanEvent) bool {
+			return !e.Time.After(cutoff)
+		})
+
+		if len(events) < p.Threshold {
+			ipWindows[c.ip] = events
+			continue
+		}
+
+		if err := m.ban(ctx, p, c.ip, events); err != nil {
+			return 0, err
+		}
+		banned[c.ip] = true
+		for _, w := range m.windows {
+			delete(w, c.ip)
+		}
+		return 1, nil
+	}
+	return 0, nil
+}
+
+// ban 按阶梯级别计算封禁时长并写入封禁记录
+func (m *ManagerImpl) ban(ctx context.Context, p *model.BanPolicy, ip string, events []model.BanEvent) error {
+	now := time.Now()
+
+	count, err := m.banRepo.CountBansSince(ctx, ip, now.Add(-time.Duration(p.EscalationHours)*time.Hour))
+	if err != nil {
+		return err
+	}
+	level := int(count) + 1
+
+	kept := events
+	if len(kept) > model.MaxBanEvents {
+		kept = kept[len(kept)-model.MaxBanEvents:]
+	}
+
+	record := &model.Ban{
+		IP:         ip,
+		Source:     p.Source,
+		PolicyID:   p.ID,
+		PolicyName: p.Name,
+		Reason:     fmt.Sprintf("%d 秒内命中 %d 条 %s 事件", p.WindowSeconds, len(events), p.Source),
+		Level:      level,
+		Active:     true,
+		StartedAt:  now,
+		EventCount: len(events),
+		Events:     slices.Clone(kept),
+		CreatedBy:  model.BanSystemUser,
+	}
+	if d := p.Duration(level); d > 0 {
+		expiresAt := now.Add(d)
+		record.ExpiresAt = &expiresAt
+	}
+
+	if err := m.banRepo.CreateBan(ctx, record); err != nil {
+		if errors.Is(err, repository.ErrBanExists) {
+			return nil
+		}
+		return err
+	}
+
+	m.lastBan[ip] = now
+	event := m.logger.Warn().
+		Str("ip", ip).
+		Str("policy", p.Name).
+		Int("level", level).
+		Int("events", len(events))
+	if record.ExpiresAt != nil {
+		event = event.Time("expiresAt", *record.ExpiresAt)
+	}
+	event.Msg("自动封禁IP")
+	return nil
+}
+
+// sync 将生效中的封禁同步到 HAProxy，封禁集合未变化时每 resyncInterval 兜底同步一次
+func (m *ManagerImpl) sync(ctx context.Context, force bool) error {
+	if m.enforcer == nil {
+		return nil
+	}
+
+	active, err := m.banRepo.GetActiveBans(ctx)
+	if err != nil {
+		return err
+	}
+	entries := make(map[string]string, len(active))
+	for _, b := range active {
+		entries[b.IP] = b.ID.Hex()
+	}
+
+	if !force && maps.Equal(entries, m.synced) && time.Since(m.syncedAt) < resyncInterval {
+		return nil
+	}
+	if err := m.enforcer.SyncBlocklist(entries); err != nil {
+		return fmt.Errorf("同步封禁列表失败: %w", err)
+	}
+	if !maps.Equal(entries, m.synced) {
+		m.logger.Info().Int("count", len(entries)).Msg("封禁列表已同步到 HAProxy")
+	}
+	m.synced = entries
+	m.syncedAt = time.Now()
+	return nil
+}
+
+// prune 删除已滑出窗口的事件和已停用策略的窗口
+func (m *ManagerImpl) prune(now time.Time, policies []model.BanPolicy) {
+	enabled := make(map[bson.ObjectID]time.Duration, len(policies))
+	for _, p := range policies {
+		enabled[p.ID] = time.Duration(p.WindowSeconds) * time.Second
+	}
+
+	for id, ipWindows := range m.windows {
+		window, ok := enabled[id]
+		if !ok {
+			delete(m.windows, id)
+			continue
+		}
+		cutoff := now.Add(-window)
+		for ip, events := range ipWindows {
+			if len(events) == 0 || !events[len(events)-1].Time.After(cutoff) {
+				delete(ipWindows, ip)
+			}
+		}
+	}
+}
+
+// skipTo 将扫描位置前移到 id，不会后退
+func (m *ManagerImpl) skipTo(id bson.ObjectID) {
+	if bytes.Compare(id[:], m.lastWAF[:]) > 0 {
+		m.lastWAF = id
+	}
+	if bytes.Compare(id[:], m.lastIDS[:]) > 0 {
+		m.lastIDS = id
+	}
+}
+
+func (m *ManagerImpl) isExempt(ip net.IP) bool {
+	for _, network := range m.exempt {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// matches 判断事件是否满足策略的来源和过滤条件
+func matches(p *model.BanPolicy, c *candidate) bool {
+	if p.Source != c.event.Source {
+		return false
+	}
+	switch p.Source {
+	case model.BanSourceWAF:
+		return len(p.Actions) == 0 || slices.Contains(p.Actions, c.action)
+	case model.BanSourceIDS:
+		return p.MaxSeverity == 0 || (c.event.Severity >= 1 && c.event.Severity <= p.MaxSeverity)
+	}
+	return false
+}
@@ -0,0 +1,150 @@
+package ban
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/HUAHUAI23/simple-waf/server/model"
+	"github.com/HUAHUAI23/simple-waf/server/repository"
+	"github.com/rs/zerolog"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// fakeBanRepo 记录创建的封禁，previous 为回溯期内的历史封禁次数
+type fakeBanRepo struct {
+	repository.BanRepository
+	previous int64
+	bans     []*model.Ban
+}
+
+func (r *fakeBanRepo) CreateBan(ctx context.Context, ban *model.Ban) error {
+	r.bans = append(r.bans, ban)
+	return nil
+}
+
+func (r *fakeBanRepo) CountBansSince(ctx context.Context, ip string, since time.Time) (int64, error) {
+	return r.previous, nil
+}
+
+func newTestManager(repo repository.BanRepository, exempt ...string) *ManagerImpl {
+	m := &ManagerImpl{
+		banRepo: repo,
+		logger:  zerolog.Nop(),
+		windows: make(map[bson.ObjectID]map[string][]model.BanEvent),
+		lastBan: make(map[string]time.Time),
+	}
+	for _, cidr := range exempt {
+		_, network, _ := net.ParseCIDR(cidr)
+		m.exempt = append(m.exempt, network)
+	}
+	return m
+}
+
+func wafCandidate(ip string, at time.Time) candidate {
+	return candidate{
+		ip:     ip,
+		action: "deny",
+		event:  model.BanEvent{Source: model.BanSourceWAF, EventID: bson.NewObjectID(), Time: at, RuleID: 942100},
+	}
+}
+
+func TestObserveBansAtThreshold(t *testing.T) {
+	repo := &fakeBanRepo{previous: 1}
+	m := newTestManager(repo)
+	policies := []model.BanPolicy{{
+		ID: bson.NewObjectID(), Name: "sqli", Source: model.BanSourceWAF,
+		Threshold: 3, WindowSeconds: 60, Durations: []int{10, 60}, EscalationHours: 24,
+	}}
+	banned := map[string]bool{}
+	start := time.Now()
+
+	// 第一个事件滑出窗口后不再计数
+	for i, offset := range []time.Duration{0, 61 * time.Second, 70 * time.Second} {
+		n, err := m.observe(context.Background(), policies, banned, wafCandidate("1.2.3.4", start.Add(offset)))
+		if err != nil || n != 0 {
+			t.Fatalf("event %d: observe = %d, %v, want no ban", i, n, err)
+		}
+	}
+	n, err := m.observe(context.Background(), policies, banned, wafCandidate("1.2.3.4", start.Add(80*time.Second)))
+	if err != nil || n != 1 {
+		t.Fatalf("observe = %d, %v, want a ban", n, err)
+	}
+
+	if len(repo.bans) != 1 {
+		t.Fatalf("bans = %d, want 1", len(repo.bans))
+	}
+	ban := repo.bans[0]
+	if ban.IP != "1.2.3.4" || ban.Level != 2 || ban.EventCount != 3 || ban.ExpiresAt == nil {
+		t.Errorf("ban = %+v", ban)
+	}
+	// 第二次封禁使用第二级时长
+	if d := ban.ExpiresAt.Sub(ban.StartedAt); d != time.Hour {
+		t.Errorf("ban duration = %s, want 1h", d)
+	}
+	if !banned["1.2.3.4"] {
+		t.Error("banned set not updated")
+	}
+
+	// 已封禁的IP不再重复封禁
+	if n, _ := m.observe(context.Background(), policies, banned, wafCandidate("1.2.3.4", start.Add(90*time.Second))); n != 0 {
+		t.Error("observed an already banned IP")
+	}
+}
+
+func TestObserveSkipsExemptAndFilteredEvents(t *testing.T) {
+	repo := &fakeBanRepo{}
+	m := newTestManager(repo, "10.0.0.0/8")
+	policies := []model.BanPolicy{{
+		ID: bson.NewObjectID(), Source: model.BanSourceWAF, Actions: []string{"deny"},
+		Threshold: 1, WindowSeconds: 60, Durations: []int{0},
+	}}
+	now := time.Now()
+
+	exempt := wafCandidate("10.1.2.3", now)
+	logged := wafCandidate("1.2.3.4", now)
+	logged.action = "pass"
+	invalid := wafCandidate("not-an-ip", now)
+	for _, c := range []candidate{exempt, logged, invalid} {
+		if n, err := m.observe(context.Background(), policies, map[string]bool{}, c); n != 0 || err != nil {
+			t.Errorf("observe(%s, %s) = %d, %v, want skipped", c.ip, c.action, n, err)
+		}
+	}
+
+	// 上一次封禁之前的事件不再计数
+	m.lastBan["1.2.3.4"] = now
+	if n, _ := m.observe(context.Background(), policies, map[string]bool{}, wafCandidate("1.2.3.4", now.Add(-time.Second))); n != 0 {
+		t.Error("counted an event older than the last ban")
+	}
+
+	// 阈值为 1 时永久封禁
+	if n, _ := m.observe(context.Background(), policies, map[string]bool{}, wafCandidate("1.2.3.4", now.Add(time.Second))); n != 1 {
+		t.Fatal("expected a ban")
+	}
+	if repo.bans[0].ExpiresAt != nil {
+		t.Errorf("ExpiresAt = %v, want permanent ban", repo.bans[0].ExpiresAt)
+	}
+}
+
+func TestMatches(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy model.BanPolicy
+		c      candidate
+		want   bool
+	}{
+		{"waf any action", model.BanPolicy{Source: model.BanSourceWAF}, candidate{action: "drop", event: model.BanEvent{Source: model.BanSourceWAF}}, true},
+		{"waf action filtered", model.BanPolicy{Source: model.BanSourceWAF, Actions: []string{"deny"}}, candidate{action: "drop", event: model.BanEvent{Source: model.BanSourceWAF}}, false},
+		{"source mismatch", model.BanPolicy{Source: model.BanSourceIDS}, candidate{event: model.BanEvent{Source: model.BanSourceWAF}}, false},
+		{"ids any severity", model.BanPolicy{Source: model.BanSourceIDS}, candidate{event: model.BanEvent{Source: model.BanSourceIDS, Severity: 3}}, true},
+		{"ids severity within", model.BanPolicy{Source: model.BanSourceIDS, MaxSeverity: 2}, candidate{event: model.BanEvent{Source: model.BanSourceIDS, Severity: 1}}, true},
+		{"ids severity too low", model.BanPolicy{Source: model.BanSourceIDS, MaxSeverity: 2}, candidate{event: model.BanEvent{Source: model.BanSourceIDS, Severity: 3}}, false},
+		{"ids unknown severity", model.BanPolicy{Source: model.BanSourceIDS, MaxSeverity: 2}, candidate{event: model.BanEvent{Source: model.BanSourceIDS}}, false},
+	}
+	for _, tt := range tests {
+		if got := matches(&tt.policy, &tt.c); got != tt.want {
+			t.Errorf("%s: matches = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
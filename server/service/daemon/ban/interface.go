@@ -0,0 +1,81 @@
+package ban
+
+import (
+	"context"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/HUAHUAI23/simple-waf/server/config"
+	"github.com/HUAHUAI23/simple-waf/server/repository"
+	"github.com/HUAHUAI23/simple-waf/server/service/daemon"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+// Manager 自动封禁管理器，按封禁策略评估新增的 WAF 日志和 Suricata 告警，
+// 维护封禁到期并将生效中的封禁同步到 HAProxy
+type Manager interface {
+	Start() error
+	Stop() error
+	Evaluate(ctx context.Context) error
+	// Notify 在封禁记录被外部修改（手动封禁或解封）后调用，请求尽快同步到 HAProxy
+	Notify()
+}
+
+// Enforcer 封禁列表的执行端
+type Enforcer interface {
+	SyncBlocklist(entries map[string]string) error
+}
+
+const (
+	batchSize       = 1000
+	resyncInterval  = time.Minute
+	defaultInterval = 10 * time.Second
+)
+
+var (
+	instance Manager
+	once     sync.Once
+)
+
+// GetManager 获取封禁管理器单例，封禁列表通过服务运行器同步到 HAProxy
+func GetManager(db *mongo.Database) Manager {
+	once.Do(func() {
+		logger := config.GetLogger().With().Str("component", "ban").Logger()
+
+		var enforcer Enforcer
+		if runner, err := daemon.GetRunnerService(); err != nil {
+			logger.Error().Err(err).Msg("获取服务运行器失败，封禁不会同步到 HAProxy")
+		} else {
+			enforcer = runner
+		}
+
+		interval := time.Duration(config.Global.Ban.IntervalSec) * time.Second
+		if interval <= 0 {
+			interval = defaultInterval
+		}
+
+		var exempt []*net.IPNet
+		for _, cidr := range config.Global.Ban.ExemptCIDRs {
+			_, network, err := net.ParseCIDR(cidr)
+			if err != nil {
+				logger.Warn().Err(err).Str("cidr", cidr).Msg("忽略无效的封禁豁免网段")
+				continue
+			}
+			exempt = append(exempt, network)
+		}
+
+		instance = &ManagerImpl{
+			policyRepo:   repository.NewBanPolicyRepository(db),
+			banRepo:      repository.NewBanRepository(db),
+			wafLogRepo:   repository.NewWAFLogRepository(db),
+			suricataRepo: repository.NewSuricataRepository(db),
+			enforcer:     enforcer,
+			interval:     interval,
+			exempt:       exempt,
+			logger:       logger,
+			notify:       make(chan struct{}, 1),
+		}
+	})
+	return instance
+}
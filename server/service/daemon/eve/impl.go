@@ -0,0 +1,340 @@
+package eve
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/HUAHUAI23/simple-waf/server/model"
+	"github.com/HUAHUAI23/simple-waf/server/repository"
+	"github.com/rs/zerolog"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// IngestorImpl EVE 日志采集器实现
+//
+// 采集器逐行读取 eve.json，解析 alert、http、tls、dns、flow 事件后批量写入 suricata_events，
+// 写入成功后才推进检查点，因此进程重启或 MongoDB 不可用时不会丢失事件（至少一次语义）。
+// 事件 _id 在解析时生成，写入失败重试时重复的 _id 会被忽略。
+// 文件轮转同时支持 mv（inode 变化）和 copytruncate（文件变小）两种方式。
+type IngestorImpl struct {
+	repo      repository.SuricataRepository
+	path      string
+	enabled   bool
+	batchSize int
+	logger    zerolog.Logger
+
+	mu      sync.Mutex
+	running bool
+	cancel  context.CancelFunc
+	done    chan struct{}
+
+	statsMu sync.RWMutex
+	stats   Stats
+
+	// 以下字段仅在采集协程中访问
+	tailer         *tailer
+	batch          []model.SuricataEvent
+	lastFlush      time.Time
+	lastCheckpoint time.Time
+	savedOffset    int64
+}
+
+// Start 启动采集协程，未启用采集时直接返回
+func (m *IngestorImpl) Start() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if !m.enabled {
+		m.updateStats(func(s *Stats) {
+			s.State = StateDisabled
+			s.File = m.path
+		})
+		m.logger.Info().Msg("Suricata EVE 采集未启用")
+		return nil
+	}
+
+	if m.running {
+		return errors.New("eve ingestor already running")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	m.cancel = cancel
+	m.done = make(chan struct{})
+	m.running = true
+	m.updateStats(func(s *Stats) {
+		s.State = StateWaiting
+		s.File = m.path
+		s.StartedAt = time.Now()
+	})
+
+	go m.loop(ctx)
+
+	m.logger.Info().Str("file", m.path).Msg("Suricata EVE 采集器已启动")
+	return nil
+}
+
+// Stop 停止采集协程，退出前尝试写入已缓冲的事件
+func (m *IngestorImpl) Stop() error {
+	m.mu.Lock()
+	if !m.running {
+		m.mu.Unlock()
+		return nil
+	}
+	m.cancel()
+	done := m.done
+	m.running = false
+	m.mu.Unlock()
+
+	<-done
+	m.updateStats(func(s *Stats) { s.State = StateStopped })
+	m.logger.Info().Msg("Suricata EVE 采集器已停止")
+	return nil
+}
+
+// Stats 返回采集状态快照
+func (m *IngestorImpl) Stats() Stats {
+	m.statsMu.RLock()
+	defer m.statsMu.RUnlock()
+	return m.stats
+}
+
+func (m *IngestorImpl) updateStats(fn func(s *Stats)) {
+	m.statsMu.Lock()
+	fn(&m.stats)
+	m.statsMu.Unlock()
+}
+
+func (m *IngestorImpl) recordError(err error, msg string) {
+	m.logger.Error().Err(err).Str("file", m.path).Msg(msg)
+	m.updateStats(func(s *Stats) {
+		s.State = StateError
+		s.LastError = err.Error()
+		s.LastErrorAt = time.Now()
+	})
+}
+
+func (m *IngestorImpl) loop(ctx context.Context) {
+	defer close(m.done)
+	defer m.closeTailer()
+	defer func() {
+		// 退出前使用独立的上下文写入剩余事件
+		flushCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		m.flush(flushCtx, true)
+	}()
+
+	for ctx.Err() == nil {
+		if m.tailer == nil {
+			if err := m.open(ctx); err != nil {
+				if os.IsNotExist(err) {
+					m.updateStats(func(s *Stats) { s.State = StateWaiting })
+				} else {
+					m.recordError(err, "打开 EVE 日志文件失败")
+				}
+				m.sleep(ctx, pollInterval)
+				continue
+			}
+		}
+
+		line, err := m.tailer.ReadLine()
+		switch {
+		case err == nil:
+			m.handleLine(line)
+			if len(m.batch) >= m.batchSize || time.Since(m.lastFlush) >= flushInterval {
+				m.flush(ctx, false)
+			}
+		case errors.Is(err, io.EOF):
+			m.flush(ctx, false)
+			m.checkFile(ctx)
+			m.sleep(ctx, pollInterval)
+		default:
+			m.recordError(err, "读取 EVE 日志失败")
+			m.flush(ctx, true)
+			m.closeTailer()
+			m.sleep(ctx, pollInterval)
+		}
+	}
+}
+
+// open 打开日志文件，从 MongoDB 中的检查点恢复读取位置
+func (m *IngestorImpl) open(ctx context.Context) error {
+	checkpoint, err := m.repo.GetIngestCheckpoint(ctx, checkpointSource)
+	if err != nil {
+		return err
+	}
+
+	t, err := openTailer(m.path, checkpoint)
+	if err != nil {
+		return err
+	}
+	m.tailer = t
+	m.savedOffset = t.offset
+	m.lastFlush = time.Now()
+
+	m.updateStats(func(s *Stats) {
+		s.State = StateRunning
+		s.Inode = t.inode
+		s.Offset = t.offset
+	})
+	m.logger.Info().Str("file", m.path).Uint64("inode", t.inode).Int64("offset", t.offset).Msg("开始采集 EVE 日志")
+	return nil
+}
+
+func (m *IngestorImpl) closeTailer() {
+	if m.tailer != nil {
+		m.tailer.Close()
+		m.tailer = nil
+	}
+}
+
+func (m *IngestorImpl) handleLine(line []byte) {
+	if len(line) == 0 {
+		return
+	}
+
+	event, ok, err := parseEVE(line)
+	m.updateStats(func(s *Stats) {
+		s.LinesRead++
+		switch {
+		case err != nil:
+			s.ParseErrors++
+		case !ok:
+			s.EventsSkipped++
+		default:
+			s.LastEventAt = event.Timestamp
+		}
+	})
+	if err != nil {
+		m.logger.Debug().Err(err).Msg("解析 EVE 日志行失败")
+		return
+	}
+	if !ok {
+		return
+	}
+
+	event.ID = bson.NewObjectID()
+	m.batch = append(m.batch, event)
+}
+
+// flush 写入缓冲的事件并推进检查点；写入失败时按指数退避重试，直到成功或上下文取消
+func (m *IngestorImpl) flush(ctx context.Context, force bool) bool {
+	m.lastFlush = time.Now()
+	if m.tailer == nil {
+		return true
+	}
+
+	if len(m.batch) > 0 {
+		backoff := minRetryBackoff
+		for {
+			err := m.repo.InsertEvents(ctx, m.batch)
+			if err == nil {
+				break
+			}
+			if ctx.Err() != nil {
+				return false
+			}
+			m.updateStats(func(s *Stats) { s.InsertErrors++ })
+			m.recordError(err, "写入 Suricata 事件失败，等待重试")
+			if !m.sleep(ctx, backoff) {
+				return false
+			}
+			backoff *= 2
+			if backoff > maxRetryBackoff {
+				backoff = maxRetryBackoff
+			}
+		}
+
+		inserted := len(m.batch)
+		m.batch = m.batch[:0]
+		force = true
+		m.updateStats(func(s *Stats) {
+			s.State = StateRunning
+			s.EventsInserted += uint64(inserted)
+			s.LastInsertAt = time.Now()
+		})
+	}
+
+	checkpoint := m.tailer.Checkpoint(checkpointSource)
+	if checkpoint.Offset == m.savedOffset && !force {
+		return true
+	}
+	if !force && time.Since(m.lastCheckpoint) < checkpointInterval {
+		return true
+	}
+	if err := m.repo.SaveIngestCheckpoint(ctx, checkpoint); err != nil {
+		if ctx.Err() == nil {
+			m.recordError(err, "保存采集检查点失败")
+		}
+		return false
+	}
+	m.savedOffset = checkpoint.Offset
+	m.lastCheckpoint = time.Now()
+	m.updateStats(func(s *Stats) {
+		s.Inode = checkpoint.Inode
+		s.Offset = checkpoint.Offset
+	})
+	return true
+}
+
+// checkFile 在读到文件末尾时检查截断和轮转
+func (m *IngestorImpl) checkFile(ctx context.Context) {
+	if size, err := m.tailer.Size(); err == nil {
+		m.updateStats(func(s *Stats) { s.FileSize = size })
+	}
+
+	truncated, err := m.tailer.Truncated()
+	if err != nil {
+		m.recordError(err, "检查 EVE 日志文件失败")
+		return
+	}
+	if truncated {
+		m.logger.Info().Str("file", m.path).Msg("EVE 日志文件被截断，从头开始读取")
+		m.flush(ctx, true)
+		return
+	}
+
+	if !m.tailer.Rotated() {
+		return
+	}
+
+	// 轮转前写入旧文件的剩余内容需要先读完
+	for {
+		line, err := m.tailer.ReadLine()
+		if err != nil {
+			break
+		}
+		m.handleLine(line)
+	}
+	if !m.flush(ctx, true) {
+		return
+	}
+
+	m.logger.Info().Str("file", m.path).Msg("EVE 日志文件已轮转，切换到新文件")
+	m.closeTailer()
+
+	t, err := openTailer(m.path, nil)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			m.recordError(err, "打开轮转后的 EVE 日志文件失败")
+		}
+		return
+	}
+	m.tailer = t
+	m.savedOffset = -1
+	m.updateStats(func(s *Stats) { s.FileSize = 0 })
+	m.flush(ctx, true)
+}
+
+// sleep 等待指定时间，上下文取消时返回 false
+func (m *IngestorImpl) sleep(ctx context.Context, d time.Duration) bool {
+	select {
+	case <-ctx.Done():
+		return false
+	case <-time.After(d):
+		return true
+	}
+}
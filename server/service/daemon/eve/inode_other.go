@@ -0,0 +1,10 @@
+//go:build !unix
+
+package eve
+
+import "os"
+
+// fileInode 非 unix 平台无法获取 inode，轮转检测仅依赖 os.SameFile
+func fileInode(os.FileInfo) uint64 {
+	return 0
+}
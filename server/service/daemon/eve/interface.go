@@ -0,0 +1,78 @@
+package eve
+
+import (
+	"sync"
+	"time"
+
+	"github.com/HUAHUAI23/simple-waf/server/config"
+	"github.com/HUAHUAI23/simple-waf/server/repository"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+// 采集状态
+const (
+	StateDisabled = "disabled" // 未启用采集
+	StateStopped  = "stopped"  // 采集协程未运行
+	StateWaiting  = "waiting"  // 等待日志文件出现
+	StateRunning  = "running"  // 正常采集
+	StateError    = "error"    // 读取或写入失败，正在重试
+)
+
+// Ingestor 增量读取 Suricata EVE JSON 日志并批量写入 MongoDB
+type Ingestor interface {
+	Start() error
+	Stop() error
+	Stats() Stats
+}
+
+// Stats 采集运行状态
+type Stats struct {
+	State          string
+	File           string
+	Inode          uint64
+	Offset         int64
+	FileSize       int64
+	LinesRead      uint64
+	EventsInserted uint64
+	EventsSkipped  uint64
+	ParseErrors    uint64
+	InsertErrors   uint64
+	LastEventAt    time.Time
+	LastInsertAt   time.Time
+	LastError      string
+	LastErrorAt    time.Time
+	StartedAt      time.Time
+}
+
+const (
+	checkpointSource   = "suricata_eve"
+	pollInterval       = time.Second
+	flushInterval      = time.Second
+	checkpointInterval = 5 * time.Second
+	minRetryBackoff    = time.Second
+	maxRetryBackoff    = 30 * time.Second
+)
+
+var (
+	instance Ingestor
+	once     sync.Once
+)
+
+// GetIngestor 获取 EVE 采集器单例，采集文件和批量大小取自环境配置
+func GetIngestor(db *mongo.Database) Ingestor {
+	once.Do(func() {
+		logger := config.GetLogger().With().Str("component", "eve").Logger()
+		batchSize := config.Global.Suricata.BatchSize
+		if batchSize <= 0 {
+			batchSize = 500
+		}
+		instance = &IngestorImpl{
+			repo:      repository.NewSuricataRepository(db),
+			path:      config.Global.Suricata.EveFile,
+			enabled:   config.Global.Suricata.IngestEnabled,
+			batchSize: batchSize,
+			logger:    logger,
+		}
+	})
+	return instance
+}
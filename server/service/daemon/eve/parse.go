@@ -0,0 +1,210 @@
+package eve
+
+import (
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"github.com/HUAHUAI23/simple-waf/server/model"
+)
+
+// eveTimeLayout Suricata EVE 时间格式，如 2024-03-18T08:12:33.123456+0000
+const eveTimeLayout = "2006-01-02T15:04:05.999999-0700"
+
+// eveRecord EVE JSON 日志行，字段名与 Suricata 输出保持一致
+type eveRecord struct {
+	Timestamp string    `json:"timestamp"`
+	FlowID    int64     `json:"flow_id"`
+	InIface   string    `json:"in_iface"`
+	EventType string    `json:"event_type"`
+	SrcIP     string    `json:"src_ip"`
+	SrcPort   int       `json:"src_port"`
+	DestIP    string    `json:"dest_ip"`
+	DestPort  int       `json:"dest_port"`
+	Proto     string    `json:"proto"`
+	AppProto  string    `json:"app_proto"`
+	Alert     *eveAlert `json:"alert"`
+	HTTP      *eveHTTP  `json:"http"`
+	TLS       *eveTLS   `json:"tls"`
+	DNS       *eveDNS   `json:"dns"`
+	Flow      *eveFlow  `json:"flow"`
+}
+
+type eveAlert struct {
+	Action      string `json:"action"`
+	GID         int    `json:"gid"`
+	SignatureID int    `json:"signature_id"`
+	Rev         int    `json:"rev"`
+	Signature   string `json:"signature"`
+	Category    string `json:"category"`
+	Severity    int    `json:"severity"`
+}
+
+type eveHTTP struct {
+	Hostname        string `json:"hostname"`
+	URL             string `json:"url"`
+	HTTPUserAgent   string `json:"http_user_agent"`
+	HTTPContentType string `json:"http_content_type"`
+	HTTPMethod      string `json:"http_method"`
+	Protocol        string `json:"protocol"`
+	Status          int    `json:"status"`
+	Length          int64  `json:"length"`
+}
+
+type eveTLS struct {
+	Subject     string `json:"subject"`
+	IssuerDN    string `json:"issuerdn"`
+	Serial      string `json:"serial"`
+	Fingerprint string `json:"fingerprint"`
+	SNI         string `json:"sni"`
+	Version     string `json:"version"`
+	NotBefore   string `json:"notbefore"`
+	NotAfter    string `json:"notafter"`
+	JA3         *struct {
+		Hash string `json:"hash"`
+	} `json:"ja3"`
+}
+
+// eveDNS 兼容 EVE DNS v2 格式，answers 为对象数组
+type eveDNS struct {
+	Type    string `json:"type"`
+	ID      int    `json:"id"`
+	RRName  string `json:"rrname"`
+	RRType  string `json:"rrtype"`
+	RCode   string `json:"rcode"`
+	Answers []struct {
+		RData string `json:"rdata"`
+	} `json:"answers"`
+}
+
+type eveFlow struct {
+	PktsToServer  int64  `json:"pkts_toserver"`
+	PktsToClient  int64  `json:"pkts_toclient"`
+	BytesToServer int64  `json:"bytes_toserver"`
+	BytesToClient int64  `json:"bytes_toclient"`
+	Start         string `json:"start"`
+	End           string `json:"end"`
+	Age           int64  `json:"age"`
+	State         string `json:"state"`
+	Reason        string `json:"reason"`
+	Alerted       bool   `json:"alerted"`
+}
+
+// parseEVE 解析一行 EVE JSON，不采集的事件类型返回 false
+func parseEVE(line []byte) (model.SuricataEvent, bool, error) {
+	var record eveRecord
+	if err := json.Unmarshal(line, &record); err != nil {
+		return model.SuricataEvent{}, false, err
+	}
+
+	switch record.EventType {
+	case model.SuricataEventAlert, model.SuricataEventHTTP, model.SuricataEventTLS,
+		model.SuricataEventDNS, model.SuricataEventFlow:
+	default:
+		return model.SuricataEvent{}, false, nil
+	}
+
+	ts, err := parseTime(record.Timestamp)
+	if err != nil {
+		return model.SuricataEvent{}, false, err
+	}
+
+	event := model.SuricataEvent{
+		Timestamp: ts,
+		EventType: record.EventType,
+		FlowID:    record.FlowID,
+		InIface:   record.InIface,
+		AppProto:  record.AppProto,
+		SrcIP:     record.SrcIP,
+		SrcPort:   record.SrcPort,
+		DstIP:     record.DestIP,
+		DstPort:   record.DestPort,
+		Proto:     record.Proto,
+	}
+
+	if a := record.Alert; a != nil {
+		event.Alert = &model.SuricataAlert{
+			Action:      a.Action,
+			GID:         a.GID,
+			SignatureID: a.SignatureID,
+			Rev:         a.Rev,
+			Signature:   a.Signature,
+			Category:    a.Category,
+			Severity:    a.Severity,
+		}
+		// 告警字段展开到顶层，与已有查询接口和 SIEM 转发保持兼容
+		event.Severity = strconv.Itoa(a.Severity)
+		event.SignatureID = a.SignatureID
+		event.Msg = a.Signature
+	}
+
+	if h := record.HTTP; h != nil {
+		event.HTTP = &model.SuricataHTTP{
+			Hostname:        h.Hostname,
+			URL:             h.URL,
+			HTTPUserAgent:   h.HTTPUserAgent,
+			HTTPContentType: h.HTTPContentType,
+			HTTPMethod:      h.HTTPMethod,
+			Protocol:        h.Protocol,
+			Status:          h.Status,
+			Length:          h.Length,
+		}
+	}
+
+	if t := record.TLS; t != nil {
+		event.TLS = &model.SuricataTLS{
+			Subject:     t.Subject,
+			IssuerDN:    t.IssuerDN,
+			Serial:      t.Serial,
+			Fingerprint: t.Fingerprint,
+			SNI:         t.SNI,
+			Version:     t.Version,
+			NotBefore:   t.NotBefore,
+			NotAfter:    t.NotAfter,
+		}
+		if t.JA3 != nil {
+			event.TLS.JA3Hash = t.JA3.Hash
+		}
+	}
+
+	if d := record.DNS; d != nil {
+		event.DNS = &model.SuricataDNS{
+			Type:   d.Type,
+			ID:     d.ID,
+			RRName: d.RRName,
+			RRType: d.RRType,
+			RCode:  d.RCode,
+		}
+		for _, answer := range d.Answers {
+			if answer.RData != "" {
+				event.DNS.Answers = append(event.DNS.Answers, answer.RData)
+			}
+		}
+	}
+
+	if f := record.Flow; f != nil {
+		event.Flow = &model.SuricataFlow{
+			PktsToServer:  f.PktsToServer,
+			PktsToClient:  f.PktsToClient,
+			BytesToServer: f.BytesToServer,
+			BytesToClient: f.BytesToClient,
+			Age:           f.Age,
+			State:         f.State,
+			Reason:        f.Reason,
+			Alerted:       f.Alerted,
+		}
+		// flow 的起止时间格式与 timestamp 相同，解析失败时保留零值
+		event.Flow.Start, _ = parseTime(f.Start)
+		event.Flow.End, _ = parseTime(f.End)
+	}
+
+	return event, true, nil
+}
+
+func parseTime(value string) (time.Time, error) {
+	ts, err := time.Parse(eveTimeLayout, value)
+	if err != nil {
+		return time.Parse(time.RFC3339Nano, value)
+	}
+	return ts, nil
+}
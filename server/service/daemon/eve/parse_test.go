@@ -0,0 +1,104 @@
+package eve
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/HUAHUAI23/simple-waf/server/model"
+)
+
+func TestParseEVEAlert(t *testing.T) {
+	line := `{"timestamp":"2024-03-18T08:12:33.123456+0800","flow_id":1234567890123456,"in_iface":"eth0","event_type":"alert",` +
+		`"src_ip":"192.168.1.100","src_port":52134,"dest_ip":"10.0.0.1","dest_port":80,"proto":"TCP","app_proto":"http",` +
+		`"alert":{"action":"allowed","gid":1,"signature_id":2010935,"rev":3,"signature":"ET SCAN Suspicious inbound to MSSQL port 1433","category":"Potentially Bad Traffic","severity":2},` +
+		`"http":{"hostname":"example.com","url":"/login.php","http_user_agent":"sqlmap/1.7","http_method":"POST","protocol":"HTTP/1.1","status":403,"length":162}}`
+
+	event, ok, err := parseEVE([]byte(line))
+	if err != nil || !ok {
+		t.Fatalf("parseEVE = %v, %v", ok, err)
+	}
+
+	wantTime := time.Date(2024, 3, 18, 0, 12, 33, 123456000, time.UTC)
+	if !event.Timestamp.Equal(wantTime) {
+		t.Errorf("Timestamp = %s, want %s", event.Timestamp, wantTime)
+	}
+	if event.EventType != model.SuricataEventAlert || event.FlowID != 1234567890123456 || event.InIface != "eth0" {
+		t.Errorf("event header = %+v", event)
+	}
+	if event.SrcIP != "192.168.1.100" || event.SrcPort != 52134 || event.DstIP != "10.0.0.1" || event.DstPort != 80 {
+		t.Errorf("addresses = %s:%d -> %s:%d", event.SrcIP, event.SrcPort, event.DstIP, event.DstPort)
+	}
+	if event.Alert == nil || event.Alert.SignatureID != 2010935 || event.Alert.Category != "Potentially Bad Traffic" {
+		t.Fatalf("Alert = %+v", event.Alert)
+	}
+	// 告警字段展开到顶层
+	if event.SignatureID != 2010935 || event.Severity != "2" || event.Msg != event.Alert.Signature {
+		t.Errorf("flattened alert = %d %q %q", event.SignatureID, event.Severity, event.Msg)
+	}
+	if event.HTTP == nil || event.HTTP.Hostname != "example.com" || event.HTTP.Status != 403 || event.HTTP.HTTPUserAgent != "sqlmap/1.7" {
+		t.Errorf("HTTP = %+v", event.HTTP)
+	}
+}
+
+func TestParseEVEProtocols(t *testing.T) {
+	tls := `{"timestamp":"2024-03-18T08:12:33.000000+0000","event_type":"tls","src_ip":"2001:db8::1","src_port":50000,"dest_ip":"2001:db8::2","dest_port":443,"proto":"TCP",` +
+		`"tls":{"subject":"CN=example.com","issuerdn":"CN=R3","serial":"04:AB","fingerprint":"aa:bb","sni":"example.com","version":"TLS 1.3","notbefore":"2024-01-01T00:00:00","notafter":"2024-04-01T00:00:00","ja3":{"hash":"e7d705a3286e19ea42f587b344ee6865","string":"771,4865"}}}`
+	event, ok, err := parseEVE([]byte(tls))
+	if err != nil || !ok {
+		t.Fatalf("parseEVE(tls) = %v, %v", ok, err)
+	}
+	if event.TLS == nil || event.TLS.SNI != "example.com" || event.TLS.JA3Hash != "e7d705a3286e19ea42f587b344ee6865" || event.SrcIP != "2001:db8::1" {
+		t.Errorf("TLS = %+v", event.TLS)
+	}
+
+	dns := `{"timestamp":"2024-03-18T08:12:33.5+0000","event_type":"dns","src_ip":"10.0.0.2","src_port":53,"dest_ip":"10.0.0.1","dest_port":41000,"proto":"UDP",` +
+		`"dns":{"version":2,"type":"answer","id":4242,"rrname":"example.com","rrtype":"A","rcode":"NOERROR","answers":[{"rrname":"example.com","rrtype":"A","ttl":300,"rdata":"93.184.216.34"},{"rrname":"example.com","rrtype":"A","ttl":300,"rdata":""}]}}`
+	event, ok, err = parseEVE([]byte(dns))
+	if err != nil || !ok {
+		t.Fatalf("parseEVE(dns) = %v, %v", ok, err)
+	}
+	if event.DNS == nil || event.DNS.ID != 4242 || event.DNS.RCode != "NOERROR" || !slices.Equal(event.DNS.Answers, []string{"93.184.216.34"}) {
+		t.Errorf("DNS = %+v", event.DNS)
+	}
+
+	flow := `{"timestamp":"2024-03-18T08:13:00.000000+0000","event_type":"flow","src_ip":"10.0.0.5","src_port":40000,"dest_ip":"10.0.0.1","dest_port":22,"proto":"TCP",` +
+		`"flow":{"pkts_toserver":12,"pkts_toclient":10,"bytes_toserver":2048,"bytes_toclient":4096,"start":"2024-03-18T08:12:00.000000+0000","end":"2024-03-18T08:12:59.000000+0000","age":59,"state":"closed","reason":"timeout","alerted":true}}`
+	event, ok, err = parseEVE([]byte(flow))
+	if err != nil || !ok {
+		t.Fatalf("parseEVE(flow) = %v, %v", ok, err)
+	}
+	if event.Flow == nil || event.Flow.BytesToClient != 4096 || !event.Flow.Alerted ||
+		!event.Flow.Start.Equal(time.Date(2024, 3, 18, 8, 12, 0, 0, time.UTC)) || event.Flow.End.Sub(event.Flow.Start) != 59*time.Second {
+		t.Errorf("Flow = %+v", event.Flow)
+	}
+}
+
+func TestParseEVESkipsAndErrors(t *testing.T) {
+	skipped := []string{
+		`{"timestamp":"2024-03-18T08:12:33.000000+0000","event_type":"stats","stats":{"uptime":60}}`,
+		`{"timestamp":"2024-03-18T08:12:33.000000+0000","event_type":"fileinfo","src_ip":"10.0.0.1"}`,
+	}
+	for _, line := range skipped {
+		if _, ok, err := parseEVE([]byte(line)); ok || err != nil {
+			t.Errorf("parseEVE(%s) = %v, %v, want skipped", line, ok, err)
+		}
+	}
+
+	invalid := []string{
+		`{"timestamp":"2024-03-18T08:12:33`,
+		`{"timestamp":"18/Mar/2024:08:12:33","event_type":"alert"}`,
+	}
+	for _, line := range invalid {
+		if _, _, err := parseEVE([]byte(line)); err == nil {
+			t.Errorf("parseEVE(%s) succeeded, want error", line)
+		}
+	}
+}
+
+func TestParseTimeRFC3339(t *testing.T) {
+	ts, err := parseTime("2024-03-18T08:12:33.123Z")
+	if err != nil || !ts.Equal(time.Date(2024, 3, 18, 8, 12, 33, 123000000, time.UTC)) {
+		t.Errorf("parseTime = %s, %v", ts, err)
+	}
+}
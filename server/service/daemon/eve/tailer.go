@@ -0,0 +1,121 @@
+package eve
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"os"
+
+	"github.com/HUAHUAI23/simple-waf/server/model"
+)
+
+// tailer 按行增量读取不断追加的日志文件，只有遇到换行符的完整行才会返回
+type tailer struct {
+	path    string
+	file    *os.File
+	info    os.FileInfo
+	reader  *bufio.Reader
+	inode   uint64
+	offset  int64 // 已返回的完整行之后的偏移量
+	partial []byte
+}
+
+// openTailer 打开日志文件；检查点属于同一文件且未被截断时从检查点偏移量继续读取
+func openTailer(path string, checkpoint *model.IngestCheckpoint) (*tailer, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	t := &tailer{
+		path:  path,
+		file:  file,
+		info:  info,
+		inode: fileInode(info),
+	}
+
+	if checkpoint != nil && checkpoint.Path == path && checkpoint.Inode == t.inode && checkpoint.Offset <= info.Size() {
+		if _, err := file.Seek(checkpoint.Offset, io.SeekStart); err != nil {
+			file.Close()
+			return nil, err
+		}
+		t.offset = checkpoint.Offset
+	}
+	t.reader = bufio.NewReaderSize(file, 64*1024)
+	return t, nil
+}
+
+// ReadLine 读取下一行完整的日志，没有完整行时返回 io.EOF
+func (t *tailer) ReadLine() ([]byte, error) {
+	data, err := t.reader.ReadBytes('\n')
+	if err != nil {
+		if err == io.EOF {
+			t.partial = append(t.partial, data...)
+		}
+		return nil, err
+	}
+
+	line := data
+	if len(t.partial) > 0 {
+		line = append(t.partial, data...)
+		t.partial = nil
+	}
+	t.offset += int64(len(line))
+	return bytes.TrimRight(line, "\r\n"), nil
+}
+
+// Size 返回当前文件大小
+func (t *tailer) Size() (int64, error) {
+	info, err := t.file.Stat()
+	if err != nil {
+		return 0, err
+	}
+	return info.Size(), nil
+}
+
+// Rotated 判断路径是否已指向新文件（mv 方式轮转）
+func (t *tailer) Rotated() bool {
+	info, err := os.Stat(t.path)
+	if err != nil {
+		return false
+	}
+	return !os.SameFile(t.info, info)
+}
+
+// Truncated 判断文件是否被截断（copytruncate 方式轮转），截断后从头读取
+func (t *tailer) Truncated() (bool, error) {
+	size, err := t.Size()
+	if err != nil {
+		return false, err
+	}
+	if size >= t.offset+int64(len(t.partial)) {
+		return false, nil
+	}
+
+	if _, err := t.file.Seek(0, io.SeekStart); err != nil {
+		return false, err
+	}
+	t.reader.Reset(t.file)
+	t.offset = 0
+	t.partial = nil
+	return true, nil
+}
+
+// Checkpoint 返回当前读取位置
+func (t *tailer) Checkpoint(source string) *model.IngestCheckpoint {
+	return &model.IngestCheckpoint{
+		ID:     source,
+		Path:   t.path,
+		Inode:  t.inode,
+		Offset: t.offset,
+	}
+}
+
+func (t *tailer) Close() error {
+	return t.file.Close()
+}
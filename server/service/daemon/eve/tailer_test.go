@@ -0,0 +1,138 @@
+package eve
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func appendFile(t *testing.T, path, data string) {
+	t.Helper()
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if _, err := f.WriteString(data); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func readLines(t *testing.T, tl *tailer) []string {
+	t.Helper()
+	var lines []string
+	for {
+		line, err := tl.ReadLine()
+		if err == io.EOF {
+			return lines
+		}
+		if err != nil {
+			t.Fatalf("ReadLine: %v", err)
+		}
+		lines = append(lines, string(line))
+	}
+}
+
+func TestTailerPartialLineAndCheckpoint(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "eve.json")
+	appendFile(t, path, "{\"a\":1}\n{\"b\":")
+
+	tl, err := openTailer(path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tl.Close()
+
+	if lines := readLines(t, tl); len(lines) != 1 || lines[0] != `{"a":1}` {
+		t.Fatalf("lines = %q", lines)
+	}
+	// 未写完的行不返回，检查点停在完整行之后
+	if cp := tl.Checkpoint("eve"); cp.Offset != 8 {
+		t.Errorf("offset = %d, want 8", cp.Offset)
+	}
+
+	appendFile(t, path, "2}\r\n")
+	if lines := readLines(t, tl); len(lines) != 1 || lines[0] != `{"b":2}` {
+		t.Fatalf("lines = %q", lines)
+	}
+	checkpoint := tl.Checkpoint("eve")
+	if checkpoint.Offset != 17 {
+		t.Errorf("offset = %d, want 17", checkpoint.Offset)
+	}
+
+	// 从检查点重新打开时跳过已读取的行
+	appendFile(t, path, "{\"c\":3}\n")
+	reopened, err := openTailer(path, checkpoint)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reopened.Close()
+	if lines := readLines(t, reopened); len(lines) != 1 || lines[0] != `{"c":3}` {
+		t.Errorf("lines after reopen = %q", lines)
+	}
+}
+
+func TestTailerIgnoresForeignCheckpoint(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "eve.json")
+	appendFile(t, path, "{\"a\":1}\n")
+
+	tl, err := openTailer(path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkpoint := tl.Checkpoint("eve")
+	checkpoint.Offset = 1 << 20
+	tl.Close()
+
+	// 偏移量超过文件大小说明文件已被替换或截断，从头读取
+	tl, err = openTailer(path, checkpoint)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tl.Close()
+	if lines := readLines(t, tl); len(lines) != 1 {
+		t.Errorf("lines = %q, want the whole file", lines)
+	}
+}
+
+func TestTailerTruncatedAndRotated(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "eve.json")
+	appendFile(t, path, "{\"a\":1}\n{\"b\":2}\n")
+
+	tl, err := openTailer(path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tl.Close()
+	readLines(t, tl)
+
+	if truncated, err := tl.Truncated(); err != nil || truncated {
+		t.Fatalf("Truncated = %v, %v before truncation", truncated, err)
+	}
+
+	// copytruncate
+	if err := os.Truncate(path, 0); err != nil {
+		t.Fatal(err)
+	}
+	appendFile(t, path, "{\"c\":3}\n")
+	if truncated, err := tl.Truncated(); err != nil || !truncated {
+		t.Fatalf("Truncated = %v, %v, want true", truncated, err)
+	}
+	if lines := readLines(t, tl); len(lines) != 1 || lines[0] != `{"c":3}` {
+		t.Errorf("lines after truncation = %q", lines)
+	}
+
+	// mv 方式轮转
+	if tl.Rotated() {
+		t.Fatal("Rotated before rotation")
+	}
+	if err := os.Rename(path, path+".1"); err != nil {
+		t.Fatal(err)
+	}
+	appendFile(t, path, "")
+	if !tl.Rotated() {
+		t.Error("Rotated = false after the path was replaced")
+	}
+}
@@ -0,0 +1,52 @@
+package haproxy
+
+import (
+	"net"
+	"strings"
+
+	"github.com/HUAHUAI23/simple-waf/pkg/utils/network"
+	"github.com/HUAHUAI23/simple-waf/server/config"
+	"github.com/haproxytech/client-native/v6/models"
+)
+
+// hostCaptureLen 访问日志中记录的 Host 最大长度
+const hostCaptureLen = 128
+
+// accessLogTarget 将访问日志接收地址转换为 HAProxy log 指令的目标，未启用接收时返回空
+// udp://127.0.0.1:5140 转换为 127.0.0.1:5140，IPv6 地址加 ipv6@ 前缀，unix:///path 转换为套接字路径
+func accessLogTarget() string {
+	cfg := config.Global.AccessLog
+	if !cfg.Enabled || cfg.Address == "" {
+		return ""
+	}
+
+	proto, addr := network.NetworkAddressFromBind(cfg.Address)
+	switch proto {
+	case "unix", "unixgram":
+		return addr
+	case "udp", "udp4", "udp6":
+		host, port, err := net.SplitHostPort(addr)
+		if err != nil {
+			return ""
+		}
+		// 接收器监听所有地址时，HAProxy 发送到本机
+		if ip := net.ParseIP(host); host == "" || (ip != nil && ip.IsUnspecified()) {
+			host = "127.0.0.1"
+		}
+		// HAProxy 以最后一个冒号分隔端口，IPv6 地址不加方括号
+		if strings.Contains(host, ":") {
+			return "ipv6@" + host + ":" + port
+		}
+		return net.JoinHostPort(host, port)
+	}
+	return ""
+}
+
+// newHostCaptureRule 将请求的 Host 捕获为第一个请求头，访问日志接收器据此把日志归属到站点
+func newHostCaptureRule() *models.HTTPRequestRule {
+	return &models.HTTPRequestRule{
+		Type:          "capture",
+		CaptureSample: "req.hdr(host)",
+		CaptureLen:    hostCaptureLen,
+	}
+}
@@ -0,0 +1,310 @@
+package haproxy
+
+import (
+	"fmt"
+	"net"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/HUAHUAI23/simple-waf/server/model"
+	"github.com/haproxytech/client-native/v6/models"
+)
+
+// ServerHealth 后端服务器的实时状态，来自运行时 API 的 show stat
+type ServerHealth struct {
+	Backend     string `json:"backend"`               // 所在后端
+	Server      string `json:"server"`                // 服务器名称
+	Address     string `json:"address"`               // 地址和端口
+	Status      string `json:"status"`                // UP/DOWN/MAINT/DRAIN/NOLB，未启用健康检查时为 no check
+	CheckStatus string `json:"checkStatus,omitempty"` // 最近一次健康检查结果，如 L4OK、L7STS
+	CheckCode   int64  `json:"checkCode,omitempty"`   // HTTP 健康检查返回的状态码
+	CheckDesc   string `json:"checkDesc,omitempty"`   // 健康检查结果说明
+	Weight      int64  `json:"weight"`                // 当前生效的权重
+	Backup      bool   `json:"backup"`                // 是否为备用服务器
+	Sessions    int64  `json:"sessions"`              // 当前连接数
+	Queued      int64  `json:"queued"`                // 排队中的请求数
+	LastChange  int64  `json:"lastChange"`            // 距上次状态变化的秒数
+}
+
+// GetSiteHealth 读取站点默认后端和路径路由后端中全部服务器的实时状态
+func (s *HAProxyServiceImpl) GetSiteHealth(site model.Site) ([]ServerHealth, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.GetStatus() != StatusRunning {
+		return nil, fmt.Errorf("HAProxy 未运行")
+	}
+	if err := s.ensureRuntimeClient(); err != nil {
+		return nil, err
+	}
+
+	stats := s.runtimeClient.GetStats()
+	if stats.Error != "" {
+		return nil, fmt.Errorf("获取运行时统计失败: %s", stats.Error)
+	}
+
+	backendName, prefix := siteBackend(site)
+	locationPrefix := locationBackendPrefix(getDashDomain(site.Domain))
+
+	result := make([]ServerHealth, 0)
+	for _, stat := range stats.Stats {
+		if stat == nil || stat.Type != "server" || stat.Stats == nil {
+			continue
+		}
+		isSiteServer := stat.BackendName == backendName && strings.HasPrefix(stat.Name, prefix)
+		if !isSiteServer && !(!isIPAddress(site.Domain) && strings.HasPrefix(stat.BackendName, locationPrefix)) {
+			continue
+		}
+		result = append(result, serverHealth(stat))
+	}
+	return result, nil
+}
+
+// serverHealth 转换 show stat 中服务器的一行
+func serverHealth(stat *models.NativeStat) ServerHealth {
+	return ServerHealth{
+		Backend:     stat.BackendName,
+		Server:      stat.Name,
+		Address:     stat.Stats.Addr,
+		Status:      stat.Stats.Status,
+		CheckStatus: stat.Stats.CheckStatus,
+		CheckCode:   GetSafeInt64(stat.Stats.CheckCode),
+		CheckDesc:   stat.Stats.CheckDesc,
+		Weight:      GetSafeInt64(stat.Stats.Weight),
+		Backup:      GetSafeInt64(stat.Stats.Bck) > 0,
+		Sessions:    GetSafeInt64(stat.Stats.Scur),
+		Queued:      GetSafeInt64(stat.Stats.Qcur),
+		LastChange:  GetSafeInt64(stat.Stats.Lastchg),
+	}
+}
+
+// ensureBackendOptions 使已有后端的负载均衡、健康检查和会话保持设置与站点一致，后端的其他设置保持不变
+func (s *HAProxyServiceImpl) ensureBackendOptions(name string, backend model.Backend, txID string, diff *siteDiff) error {
+	_, current, err := s.confClient.GetBackend(name, txID)
+	if err != nil {
+		return fmt.Errorf("获取后端失败: %v", err)
+	}
+
+	want := *current
+	applyBackendOptions(&want.BackendBase, backend)
+	if !sameBackendOptions(&current.BackendBase, &want.BackendBase) {
+		if err := s.confClient.EditBackend(name, &want, txID, 0); err != nil {
+			return fmt.Errorf("修改后端失败: %v", err)
+		}
+		diff.configChanged = true
+	}
+
+	_, checks, err := s.confClient.GetHTTPChecks("backend", name, txID)
+	if err != nil {
+		return fmt.Errorf("获取健康检查规则失败: %v", err)
+	}
+	if wantChecks := backendHTTPChecks(backend); !slices.EqualFunc(checks, wantChecks, sameHTTPCheck) {
+		if err := s.confClient.ReplaceHTTPChecks("backend", name, wantChecks, txID, 0); err != nil {
+			return fmt.Errorf("修改健康检查规则失败: %v", err)
+		}
+		diff.configChanged = true
+	}
+	return nil
+}
+
+// applyBackendOptions 将站点后端的负载均衡、健康检查和会话保持设置写入后端配置
+// 未设置负载均衡算法时不写 balance，使用 HAProxy 默认的 roundrobin
+func applyBackendOptions(base *models.BackendBase, backend model.Backend) {
+	base.Balance = nil
+	if backend.Balance != "" {
+		base.Balance = &models.Balance{Algorithm: StringP(string(backend.Balance))}
+	}
+
+	base.AdvCheck = ""
+	base.HttpchkParams = nil
+	if check := backend.HealthCheck; check != nil && check.Type == model.HealthCheckHTTP {
+		base.AdvCheck = "httpchk"
+		base.HttpchkParams = &models.HttpchkParams{Method: "GET", URI: check.Path}
+	}
+
+	base.Cookie = nil
+	if sticky := backend.StickySession; sticky != nil {
+		base.Cookie = &models.Cookie{
+			Name:     StringP(sticky.CookieName),
+			Type:     "insert",
+			Indirect: true,
+			Nocache:  true,
+		}
+	}
+}
+
+// backendHTTPChecks 生成 HTTP 健康检查的期望状态码规则，未指定状态码时 2xx 和 3xx 都视为健康
+func backendHTTPChecks(backend model.Backend) models.HTTPChecks {
+	check := backend.HealthCheck
+	if check == nil || check.Type != model.HealthCheckHTTP || check.ExpectStatus == 0 {
+		return nil
+	}
+	return models.HTTPChecks{{
+		Type:    "expect",
+		Match:   "status",
+		Pattern: strconv.Itoa(check.ExpectStatus),
+	}}
+}
+
+// sameBackendOptions 比较站点关心的后端设置
+func sameBackendOptions(a, b *models.BackendBase) bool {
+	return sameBalance(a.Balance, b.Balance) &&
+		a.AdvCheck == b.AdvCheck &&
+		sameHttpchkParams(a.HttpchkParams, b.HttpchkParams) &&
+		sameCookie(a.Cookie, b.Cookie)
+}
+
+func sameBalance(a, b *models.Balance) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return GetSafeString(a.Algorithm) == GetSafeString(b.Algorithm)
+}
+
+func sameHttpchkParams(a, b *models.HttpchkParams) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Method == b.Method && a.URI == b.URI && a.Version == b.Version
+}
+
+func sameCookie(a, b *models.Cookie) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return GetSafeString(a.Name) == GetSafeString(b.Name) &&
+		a.Type == b.Type &&
+		a.Indirect == b.Indirect &&
+		a.Nocache == b.Nocache
+}
+
+func sameHTTPCheck(a, b *models.HTTPCheck) bool {
+	return a.Type == b.Type && a.Match == b.Match && a.Pattern == b.Pattern && a.ExclamationMark == b.ExclamationMark
+}
+
+// newBackendServer 构建站点后端服务器配置，健康检查参数和会话保持 Cookie 来自服务器所在的后端
+func newBackendServer(name string, server model.Server, backend model.Backend) *models.Server {
+	srv := &models.Server{
+		Name:    name,
+		Address: server.Host,
+		Port:    Int64P(int64(server.Port)),
+	}
+
+	if server.IsSSL {
+		srv.Ssl = "enabled"
+		// srv.SslCafile = ""
+		srv.Verify = "none" // 不验证证书
+	}
+
+	if check := backend.HealthCheck; check != nil {
+		srv.Check = "enabled"
+		srv.Inter = Int64P(int64(check.Interval) * 1000)
+		srv.Rise = Int64P(int64(check.Rise))
+		srv.Fall = Int64P(int64(check.Fall))
+	}
+	if server.Weight > 0 {
+		srv.Weight = Int64P(int64(server.Weight))
+	}
+	// 配置文件中没有 drain 状态，以权重 0 表示，效果与运行时的 drain 相同
+	switch server.State {
+	case model.ServerStateDrain:
+		srv.Weight = Int64P(0)
+	case model.ServerStateMaint:
+		srv.Maintenance = "enabled"
+	}
+	if server.Backup {
+		srv.Backup = "enabled"
+	}
+	if server.MaxConn > 0 {
+		srv.Maxconn = Int64P(int64(server.MaxConn))
+	}
+	if backend.StickySession != nil {
+		srv.Cookie = name
+	}
+
+	return srv
+}
+
+// sameServer 比较站点关心的服务器字段
+func sameServer(a, b *models.Server) bool {
+	return a.Address == b.Address &&
+		GetSafeInt64(a.Port) == GetSafeInt64(b.Port) &&
+		weightOf(a) == weightOf(b) &&
+		a.Maintenance == b.Maintenance &&
+		sameServerParams(a, b)
+}
+
+// sameServerParams 比较运行时无法修改的服务器参数，参数相同时地址、权重和管理状态可以通过运行时 API 直接修改
+func sameServerParams(a, b *models.Server) bool {
+	return a.Ssl == b.Ssl &&
+		a.Verify == b.Verify &&
+		a.Check == b.Check &&
+		GetSafeInt64(a.Inter) == GetSafeInt64(b.Inter) &&
+		GetSafeInt64(a.Rise) == GetSafeInt64(b.Rise) &&
+		GetSafeInt64(a.Fall) == GetSafeInt64(b.Fall) &&
+		a.Backup == b.Backup &&
+		GetSafeInt64(a.Maxconn) == GetSafeInt64(b.Maxconn) &&
+		a.Cookie == b.Cookie &&
+		a.SendProxy == b.SendProxy &&
+		a.SendProxyV2 == b.SendProxyV2
+}
+
+// weightOf 返回服务器配置的权重，未配置时为 HAProxy 默认的 1
+func weightOf(server *models.Server) int64 {
+	if server.Weight == nil {
+		return 1
+	}
+	return *server.Weight
+}
+
+// runtimeAdminState 返回服务器配置对应的运行时管理状态
+func runtimeAdminState(server *models.Server) string {
+	switch {
+	case server.Maintenance == "enabled":
+		return models.RuntimeServerAdminStateMaint
+	case weightOf(server) == 0:
+		return models.RuntimeServerAdminStateDrain
+	default:
+		return models.RuntimeServerAdminStateReady
+	}
+}
+
+// SiteServerName 返回站点默认后端中第 index 个服务器在 HAProxy 中的名称
+func SiteServerName(site model.Site, index int) string {
+	_, prefix := siteBackend(site)
+	return fmt.Sprintf("%s%d", prefix, index)
+}
+
+// runtimeServerAttrs 生成运行时 add server 命令的服务器参数
+func runtimeServerAttrs(server *models.Server) string {
+	attrs := []string{net.JoinHostPort(server.Address, strconv.FormatInt(GetSafeInt64(server.Port), 10))}
+	if server.Ssl == "enabled" {
+		attrs = append(attrs, "ssl", "verify", server.Verify)
+	}
+	if server.Check == "enabled" {
+		attrs = append(attrs, "check",
+			"inter", strconv.FormatInt(GetSafeInt64(server.Inter), 10),
+			"rise", strconv.FormatInt(GetSafeInt64(server.Rise), 10),
+			"fall", strconv.FormatInt(GetSafeInt64(server.Fall), 10))
+	}
+	if server.Weight != nil && *server.Weight > 0 {
+		attrs = append(attrs, "weight", strconv.FormatInt(*server.Weight, 10))
+	}
+	if server.Backup == "enabled" {
+		attrs = append(attrs, "backup")
+	}
+	if server.Maxconn != nil {
+		attrs = append(attrs, "maxconn", strconv.FormatInt(*server.Maxconn, 10))
+	}
+	if server.Cookie != "" {
+		attrs = append(attrs, "cookie", server.Cookie)
+	}
+	if server.SendProxy == "enabled" {
+		attrs = append(attrs, "send-proxy")
+	}
+	if server.SendProxyV2 == "enabled" {
+		attrs = append(attrs, "send-proxy-v2")
+	}
+	return strings.Join(attrs, " ")
+}
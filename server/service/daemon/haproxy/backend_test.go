@@ -0,0 +1,70 @@
+package haproxy
+
+import (
+	"testing"
+
+	"github.com/HUAHUAI23/simple-waf/server/model"
+	"github.com/haproxytech/client-native/v6/models"
+)
+
+func TestApplyBackendOptions(t *testing.T) {
+	backend := model.Backend{
+		Balance:       model.BalanceLeastConn,
+		HealthCheck:   &model.HealthCheck{Type: model.HealthCheckHTTP, Path: "/healthz", ExpectStatus: 204},
+		StickySession: &model.StickySession{CookieName: "SRV"},
+	}
+	var base models.BackendBase
+	applyBackendOptions(&base, backend)
+
+	if base.Balance == nil || *base.Balance.Algorithm != "leastconn" {
+		t.Errorf("balance = %+v, want leastconn", base.Balance)
+	}
+	if base.AdvCheck != "httpchk" || base.HttpchkParams == nil || base.HttpchkParams.URI != "/healthz" {
+		t.Errorf("check = %s %+v, want httpchk /healthz", base.AdvCheck, base.HttpchkParams)
+	}
+	if base.Cookie == nil || *base.Cookie.Name != "SRV" || base.Cookie.Type != "insert" || !base.Cookie.Indirect || !base.Cookie.Nocache {
+		t.Errorf("cookie = %+v, want insert indirect nocache SRV", base.Cookie)
+	}
+	checks := backendHTTPChecks(backend)
+	if len(checks) != 1 || checks[0].Type != "expect" || checks[0].Match != "status" || checks[0].Pattern != "204" {
+		t.Errorf("http checks = %+v, want expect status 204", checks)
+	}
+
+	// 去掉所有选项后清除之前写入的配置
+	applyBackendOptions(&base, model.Backend{HealthCheck: &model.HealthCheck{Type: model.HealthCheckTCP}})
+	if base.Balance != nil || base.AdvCheck != "" || base.HttpchkParams != nil || base.Cookie != nil {
+		t.Errorf("base = %+v, want options cleared", base)
+	}
+	if checks := backendHTTPChecks(model.Backend{HealthCheck: &model.HealthCheck{Type: model.HealthCheckHTTP}}); checks != nil {
+		t.Errorf("http checks without expected status = %+v, want nil", checks)
+	}
+}
+
+func TestNewBackendServer(t *testing.T) {
+	backend := model.Backend{
+		HealthCheck:   &model.HealthCheck{Type: model.HealthCheckTCP, Interval: 5, Rise: 2, Fall: 3},
+		StickySession: &model.StickySession{CookieName: "SRV"},
+	}
+	srv := newBackendServer("a_com_1", model.Server{Host: "10.0.0.2", Port: 443, IsSSL: true, Weight: 10, Backup: true, MaxConn: 100}, backend)
+
+	if srv.Address != "10.0.0.2" || GetSafeInt64(srv.Port) != 443 {
+		t.Errorf("address = %s:%d", srv.Address, GetSafeInt64(srv.Port))
+	}
+	if srv.Ssl != "enabled" || srv.Verify != "none" {
+		t.Errorf("ssl = %s verify %s", srv.Ssl, srv.Verify)
+	}
+	if srv.Check != "enabled" || GetSafeInt64(srv.Inter) != 5000 || GetSafeInt64(srv.Rise) != 2 || GetSafeInt64(srv.Fall) != 3 {
+		t.Errorf("check = %s inter %d rise %d fall %d", srv.Check, GetSafeInt64(srv.Inter), GetSafeInt64(srv.Rise), GetSafeInt64(srv.Fall))
+	}
+	if GetSafeInt64(srv.Weight) != 10 || srv.Backup != "enabled" || GetSafeInt64(srv.Maxconn) != 100 {
+		t.Errorf("weight %d backup %s maxconn %d", GetSafeInt64(srv.Weight), srv.Backup, GetSafeInt64(srv.Maxconn))
+	}
+	if srv.Cookie != "a_com_1" {
+		t.Errorf("cookie = %q, want server name", srv.Cookie)
+	}
+
+	plain := newBackendServer("a_com_0", model.Server{Host: "10.0.0.1", Port: 80}, model.Backend{})
+	if plain.Ssl != "" || plain.Check != "" || plain.Weight != nil || plain.Backup != "" || plain.Maxconn != nil || plain.Cookie != "" {
+		t.Errorf("plain server = %+v, want defaults", plain.ServerParams)
+	}
+}
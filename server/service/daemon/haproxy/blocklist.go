@@ -0,0 +1,104 @@
+package haproxy
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// SyncBlocklist 将封禁列表写入 map 文件，HAProxy 运行中时再通过运行时 API 增量同步，无需重载
+//
+// entries 的键为IP或网段，值为写入 map 的说明（封禁ID）。map 文件保证 HAProxy 重启或热加载后封禁仍然生效，
+// 运行时同步保证新增和解除的封禁立即生效。
+func (s *HAProxyServiceImpl) SyncBlocklist(entries map[string]string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if err := writeBlocklistFile(s.BlocklistFile, entries); err != nil {
+		return fmt.Errorf("写入封禁列表失败: %v", err)
+	}
+
+	if s.GetStatus() != StatusRunning {
+		return nil
+	}
+	if err := s.ensureRuntimeClient(); err != nil {
+		return err
+	}
+
+	// 没有站点时前端不会引用封禁列表，运行时中不存在该 map
+	maps, err := s.runtimeClient.ShowMaps()
+	if err != nil {
+		return fmt.Errorf("获取运行时 map 列表失败: %v", err)
+	}
+	name := ""
+	for _, m := range maps {
+		if m.File == s.BlocklistFile {
+			name = strings.TrimSuffix(filepath.Base(m.File), filepath.Ext(m.File))
+			break
+		}
+	}
+	if name == "" {
+		return nil
+	}
+
+	current, err := s.runtimeClient.ShowMapEntries(name)
+	if err != nil {
+		return fmt.Errorf("获取运行时封禁列表失败: %v", err)
+	}
+
+	loaded := make(map[string]string, len(current))
+	for _, entry := range current {
+		loaded[entry.Key] = entry.Value
+	}
+
+	for key := range loaded {
+		if _, ok := entries[key]; ok {
+			continue
+		}
+		if err := s.runtimeClient.DeleteMapEntry(name, key); err != nil {
+			return fmt.Errorf("删除封禁条目 %s 失败: %v", key, err)
+		}
+	}
+	for key, value := range entries {
+		old, ok := loaded[key]
+		switch {
+		case !ok:
+			err = s.runtimeClient.AddMapEntry(name, key, value)
+		case old != value:
+			err = s.runtimeClient.SetMapEntry(name, key, value)
+		default:
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("写入封禁条目 %s 失败: %v", key, err)
+		}
+	}
+
+	return nil
+}
+
+// writeBlocklistFile 按键排序写入 map 文件，先写临时文件再重命名
+func writeBlocklistFile(path string, entries map[string]string) error {
+	keys := make([]string, 0, len(entries))
+	for key := range entries {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var buf bytes.Buffer
+	for _, key := range keys {
+		fmt.Fprintf(&buf, "%s %s\n", key, entries[key])
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, buf.Bytes(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
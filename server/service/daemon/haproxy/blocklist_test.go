@@ -0,0 +1,41 @@
+package haproxy
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteBlocklistFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "maps", "blocklist.map")
+
+	entries := map[string]string{
+		"10.0.0.0/8":    "65f1c2a4e4b0a1b2c3d4e5f7",
+		"192.168.1.100": "65f1c2a4e4b0a1b2c3d4e5f6",
+		"2001:db8::1":   "65f1c2a4e4b0a1b2c3d4e5f8",
+	}
+	if err := writeBlocklistFile(path, entries); err != nil {
+		t.Fatalf("writeBlocklistFile: %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "10.0.0.0/8 65f1c2a4e4b0a1b2c3d4e5f7\n" +
+		"192.168.1.100 65f1c2a4e4b0a1b2c3d4e5f6\n" +
+		"2001:db8::1 65f1c2a4e4b0a1b2c3d4e5f8\n"
+	if string(data) != want {
+		t.Errorf("blocklist =\n%s\nwant\n%s", data, want)
+	}
+
+	// 清空封禁后保留空文件，HAProxy 引用的 map 文件必须存在
+	if err := writeBlocklistFile(path, nil); err != nil {
+		t.Fatalf("writeBlocklistFile: %v", err)
+	}
+	if data, err := os.ReadFile(path); err != nil || len(data) != 0 {
+		t.Errorf("empty blocklist = %q, %v", data, err)
+	}
+	if _, err := os.Stat(path + ".tmp"); !os.IsNotExist(err) {
+		t.Errorf("temporary file left behind: %v", err)
+	}
+}
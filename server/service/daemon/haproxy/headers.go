@@ -0,0 +1,141 @@
+package haproxy
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/HUAHUAI23/simple-waf/server/model"
+	"github.com/haproxytech/client-native/v6/models"
+)
+
+// headerRuleTypes 头部改写生成的规则类型，后端中这些类型的规则都由站点的头部设置维护
+var headerRuleTypes = []string{"add-header", "set-header", "del-header"}
+
+// headerPresetRules 预置规则展开后的头部改写规则，值为 HAProxy log-format 格式
+var headerPresetRules = map[model.HeaderPreset][]headerRule{
+	model.HeaderPresetCSP: {
+		{model.HeaderDirectionResponse, model.HeaderActionSet, "Content-Security-Policy", "default-src 'self'; object-src 'none'; base-uri 'self'; frame-ancestors 'self'"},
+	},
+	model.HeaderPresetFrameOptions: {
+		{model.HeaderDirectionResponse, model.HeaderActionSet, "X-Frame-Options", "SAMEORIGIN"},
+	},
+	model.HeaderPresetContentTypeOptions: {
+		{model.HeaderDirectionResponse, model.HeaderActionSet, "X-Content-Type-Options", "nosniff"},
+	},
+	model.HeaderPresetReferrerPolicy: {
+		{model.HeaderDirectionResponse, model.HeaderActionSet, "Referrer-Policy", "strict-origin-when-cross-origin"},
+	},
+	model.HeaderPresetPermissionsPolicy: {
+		{model.HeaderDirectionResponse, model.HeaderActionSet, "Permissions-Policy", "camera=(), microphone=(), geolocation=(), payment=(), usb=()"},
+	},
+	model.HeaderPresetHideServer: {
+		{model.HeaderDirectionResponse, model.HeaderActionDel, "Server", ""},
+		{model.HeaderDirectionResponse, model.HeaderActionDel, "X-Powered-By", ""},
+	},
+	model.HeaderPresetForwardedProto: {
+		// 客户端到端口 HTTPS 前端的连接为 TLS 时是 https
+		{model.HeaderDirectionRequest, model.HeaderActionSet, "X-Forwarded-Proto", "%[ssl_fc,iif(https,http)]"},
+	},
+}
+
+// headerRule 展开后的头部改写规则，format 已是 log-format 格式
+type headerRule struct {
+	direction model.HeaderDirection
+	action    model.HeaderAction
+	name      string
+	format    string
+}
+
+// siteHeaderRules 按预置规则在前、自定义规则在后的顺序生成站点后端的头部改写规则
+func siteHeaderRules(site model.Site) ([]*models.HTTPRequestRule, []*models.HTTPResponseRule) {
+	if site.Headers == nil {
+		return nil, nil
+	}
+
+	var rules []headerRule
+	for _, preset := range site.Headers.Presets {
+		rules = append(rules, headerPresetRules[preset]...)
+	}
+	for _, rule := range site.Headers.Rules {
+		// 自定义的值按原样发送，% 需要转义
+		rules = append(rules, headerRule{rule.Direction, rule.Action, rule.Name, escapeLogFormat(rule.Value)})
+	}
+
+	var requestRules []*models.HTTPRequestRule
+	var responseRules []*models.HTTPResponseRule
+	for _, rule := range rules {
+		typ := fmt.Sprintf("%s-header", rule.action)
+		format := ""
+		if rule.action != model.HeaderActionDel {
+			format = `"` + rule.format + `"`
+		}
+		if rule.direction == model.HeaderDirectionRequest {
+			requestRules = append(requestRules, &models.HTTPRequestRule{Type: typ, HdrName: rule.name, HdrFormat: format})
+		} else {
+			responseRules = append(responseRules, &models.HTTPResponseRule{Type: typ, HdrName: rule.name, HdrFormat: format})
+		}
+	}
+	return requestRules, responseRules
+}
+
+// ensureBackendHeaders 将站点的头部改写规则同步到后端，不一致时删除后端中全部头部规则再按顺序追加
+func (s *HAProxyServiceImpl) ensureBackendHeaders(backend string, site model.Site, txID string, diff *siteDiff) error {
+	wantRequest, wantResponse := siteHeaderRules(site)
+
+	_, requestRules, err := s.confClient.GetHTTPRequestRules("backend", backend, txID)
+	if err != nil {
+		return fmt.Errorf("获取HTTP请求规则失败: %v", err)
+	}
+	_, responseRules, err := s.confClient.GetHTTPResponseRules("backend", backend, txID)
+	if err != nil {
+		return fmt.Errorf("获取HTTP响应规则失败: %v", err)
+	}
+
+	var currentRequest []*models.HTTPRequestRule
+	var currentResponse []*models.HTTPResponseRule
+	var requestIndexes, responseIndexes []int
+	for i, rule := range requestRules {
+		if slices.Contains(headerRuleTypes, rule.Type) {
+			currentRequest = append(currentRequest, rule)
+			requestIndexes = append(requestIndexes, i)
+		}
+	}
+	for i, rule := range responseRules {
+		if slices.Contains(headerRuleTypes, rule.Type) {
+			currentResponse = append(currentResponse, rule)
+			responseIndexes = append(responseIndexes, i)
+		}
+	}
+
+	if slices.EqualFunc(currentRequest, wantRequest, sameRequestRule) &&
+		slices.EqualFunc(currentResponse, wantResponse, sameResponseHeaderRule) {
+		return nil
+	}
+
+	for _, i := range slices.Backward(requestIndexes) {
+		if err := s.confClient.DeleteHTTPRequestRule(int64(i), "backend", backend, txID, 0); err != nil {
+			return fmt.Errorf("删除HTTP请求规则失败: %v", err)
+		}
+	}
+	for _, i := range slices.Backward(responseIndexes) {
+		if err := s.confClient.DeleteHTTPResponseRule(int64(i), "backend", backend, txID, 0); err != nil {
+			return fmt.Errorf("删除HTTP响应规则失败: %v", err)
+		}
+	}
+	// 其他规则保持原位，头部规则依次追加到末尾
+	requestIndex := len(requestRules) - len(requestIndexes)
+	for i, rule := range wantRequest {
+		if err := s.confClient.CreateHTTPRequestRule(int64(requestIndex+i), "backend", backend, rule, txID, 0); err != nil {
+			return fmt.Errorf("创建HTTP请求规则失败: %v", err)
+		}
+	}
+	responseIndex := len(responseRules) - len(responseIndexes)
+	for i, rule := range wantResponse {
+		if err := s.confClient.CreateHTTPResponseRule(int64(responseIndex+i), "backend", backend, rule, txID, 0); err != nil {
+			return fmt.Errorf("创建HTTP响应规则失败: %v", err)
+		}
+	}
+
+	diff.configChanged = true
+	return nil
+}
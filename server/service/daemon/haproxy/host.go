@@ -0,0 +1,146 @@
+package haproxy
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/HUAHUAI23/simple-waf/server/model"
+	"github.com/haproxytech/client-native/v6/models"
+)
+
+const (
+	// hostCriterion 去掉 Host 请求头中的端口后匹配，a.com:8080 与 a.com 等价
+	hostCriterion = "req.hdr(host),field(1,:)"
+	sniCriterion  = "ssl_fc_sni"
+)
+
+// hostACLs 生成匹配一组主机名的 ACL，同名 ACL 之间为或的关系
+// 精确域名完全匹配，*.a.com 匹配以 .a.com 结尾的主机名，不匹配 a.com 本身
+func hostACLs(aclName, criterion string, hosts []string) []*models.ACL {
+	var exact, wildcard []string
+	for _, host := range hosts {
+		if model.IsWildcardHost(host) {
+			wildcard = append(wildcard, strings.TrimPrefix(host, "*"))
+		} else {
+			exact = append(exact, host)
+		}
+	}
+
+	var acls []*models.ACL
+	if len(exact) > 0 {
+		acls = append(acls, &models.ACL{ACLName: aclName, Criterion: criterion + " -i", Value: strings.Join(exact, " ")})
+	}
+	if len(wildcard) > 0 {
+		acls = append(acls, &models.ACL{ACLName: aclName, Criterion: criterion + " -i -m end", Value: strings.Join(wildcard, " ")})
+	}
+	return acls
+}
+
+// ensureACLs 使前端中名为 aclName 的 ACL 与期望一致，有差异时在原位置整体替换
+func (s *HAProxyServiceImpl) ensureACLs(frontend, aclName string, want []*models.ACL, txID string, diff *siteDiff) error {
+	_, aclList, err := s.confClient.GetACLs("frontend", frontend, txID)
+	if err != nil {
+		return fmt.Errorf("获取 ACL 失败: %v", err)
+	}
+
+	var current []*models.ACL
+	var indexes []int
+	for i, acl := range aclList {
+		if acl.ACLName == aclName {
+			current = append(current, acl)
+			indexes = append(indexes, i)
+		}
+	}
+	if slices.EqualFunc(current, want, sameACL) {
+		return nil
+	}
+
+	index := len(aclList) - len(indexes)
+	if len(indexes) > 0 {
+		index = indexes[0]
+	}
+	for _, i := range slices.Backward(indexes) {
+		if err := s.confClient.DeleteACL(int64(i), "frontend", frontend, txID, 0); err != nil {
+			return fmt.Errorf("删除 ACL 失败: %v", err)
+		}
+	}
+	for i, acl := range want {
+		if err := s.confClient.CreateACL(int64(index+i), "frontend", frontend, acl, txID, 0); err != nil {
+			return fmt.Errorf("创建 ACL 失败: %v", err)
+		}
+	}
+
+	diff.configChanged = true
+	return nil
+}
+
+// ensureSNICheck 确保 HTTPS 前端校验客户端 SNI 属于站点的主机名
+//
+// Host 请求头命中站点但 SNI 不属于站点时返回 421，客户端会为该主机名建立新连接；
+// 未发送 SNI 的客户端不做校验。
+func (s *HAProxyServiceImpl) ensureSNICheck(site model.Site, txID string, diff *siteDiff) error {
+	feHTTPS := fmt.Sprintf("fe_%d_https", site.ListenPort)
+	dash := getDashDomain(site.Domain)
+	sniACL := fmt.Sprintf("sni_%s", dash)
+
+	if err := s.ensureACLs(feHTTPS, sniACL, hostACLs(sniACL, sniCriterion, site.Hosts()), txID, diff); err != nil {
+		return err
+	}
+
+	want := misdirectedRule(dash)
+	_, requestRules, err := s.confClient.GetHTTPRequestRules("frontend", feHTTPS, txID)
+	if err != nil {
+		return fmt.Errorf("获取HTTP请求规则失败: %v", err)
+	}
+	if slices.ContainsFunc(requestRules, func(rule *models.HTTPRequestRule) bool { return sameMisdirectedRule(rule, want) }) {
+		return nil
+	}
+	if err := s.confClient.CreateHTTPRequestRule(int64(len(requestRules)), "frontend", feHTTPS, want, txID, 0); err != nil {
+		return fmt.Errorf("创建HTTP请求规则失败: %v", err)
+	}
+	diff.configChanged = true
+	return nil
+}
+
+// removeSNICheck 删除站点在 HTTPS 前端中的 SNI 校验规则和 ACL
+func (s *HAProxyServiceImpl) removeSNICheck(site model.Site, txID string, diff *siteDiff) error {
+	feHTTPS := fmt.Sprintf("fe_%d_https", site.ListenPort)
+	if _, _, err := s.confClient.GetFrontend(feHTTPS, txID); err != nil {
+		return nil
+	}
+	dash := getDashDomain(site.Domain)
+	want := misdirectedRule(dash)
+
+	_, requestRules, err := s.confClient.GetHTTPRequestRules("frontend", feHTTPS, txID)
+	if err != nil {
+		return fmt.Errorf("获取HTTP请求规则失败: %v", err)
+	}
+	for i := len(requestRules) - 1; i >= 0; i-- {
+		if !sameMisdirectedRule(requestRules[i], want) {
+			continue
+		}
+		if err := s.confClient.DeleteHTTPRequestRule(int64(i), "frontend", feHTTPS, txID, 0); err != nil {
+			return fmt.Errorf("删除HTTP请求规则失败: %v", err)
+		}
+		diff.configChanged = true
+	}
+
+	return s.ensureACLs(feHTTPS, fmt.Sprintf("sni_%s", dash), nil, txID, diff)
+}
+
+// misdirectedRule Host 请求头属于站点而 SNI 不属于站点时返回 421
+func misdirectedRule(dash string) *models.HTTPRequestRule {
+	return &models.HTTPRequestRule{
+		Type:             "return",
+		ReturnStatusCode: Int64P(421),
+		Cond:             "if",
+		CondTest:         fmt.Sprintf("host_%s !sni_%s { ssl_fc_has_sni }", dash, dash),
+	}
+}
+
+func sameMisdirectedRule(a, b *models.HTTPRequestRule) bool {
+	return a.Type == b.Type &&
+		GetSafeInt64(a.ReturnStatusCode) == GetSafeInt64(b.ReturnStatusCode) &&
+		a.Cond == b.Cond && a.CondTest == b.CondTest
+}
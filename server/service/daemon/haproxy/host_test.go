@@ -0,0 +1,44 @@
+package haproxy
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestHostACLs(t *testing.T) {
+	tests := []struct {
+		name      string
+		criterion string
+		hosts     []string
+		want      []string
+	}{
+		{"exact", hostCriterion, []string{"a.com"}, []string{"host_a_com req.hdr(host),field(1,:) -i a.com"}},
+		{"aliases", hostCriterion, []string{"a.com", "www.a.com", "b.com"}, []string{"host_a_com req.hdr(host),field(1,:) -i a.com www.a.com b.com"}},
+		// 通配符只匹配子域名，与精确域名分成两条同名 ACL
+		{"wildcard", hostCriterion, []string{"a.com", "*.a.com", "*.b.com"}, []string{
+			"host_a_com req.hdr(host),field(1,:) -i a.com",
+			"host_a_com req.hdr(host),field(1,:) -i -m end .a.com .b.com",
+		}},
+		{"only wildcard", sniCriterion, []string{"*.a.com"}, []string{"host_a_com ssl_fc_sni -i -m end .a.com"}},
+	}
+	for _, tt := range tests {
+		got := mapStrings(hostACLs("host_a_com", tt.criterion, tt.hosts), aclString)
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("%s: acls =\n%q\nwant\n%q", tt.name, got, tt.want)
+		}
+	}
+	if acls := hostACLs("host_a_com", hostCriterion, nil); len(acls) != 0 {
+		t.Errorf("no hosts produced %d acls", len(acls))
+	}
+}
+
+func TestMisdirectedRule(t *testing.T) {
+	rule := misdirectedRule("a_com")
+	if rule.Type != "return" || GetSafeInt64(rule.ReturnStatusCode) != 421 {
+		t.Errorf("rule = %s %d, want return 421", rule.Type, GetSafeInt64(rule.ReturnStatusCode))
+	}
+	// 只有带 SNI 且 SNI 不属于站点的请求返回 421
+	if want := "host_a_com !sni_a_com { ssl_fc_has_sni }"; rule.CondTest != want {
+		t.Errorf("cond = %q, want %q", rule.CondTest, want)
+	}
+}
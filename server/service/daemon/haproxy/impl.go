@@ -31,6 +31,12 @@ import (
 	"github.com/rs/zerolog"
 )
 
+// SPOE 消息组，由前端的 send-spoe-group 规则触发
+const (
+	spoeRequestGroup  = "coraza-req-group"
+	spoeResponseGroup = "coraza-res-group"
+)
+
 type HAProxyStatus int32
 
 const (
@@ -43,7 +49,7 @@ type HAProxyServiceImpl struct {
 	ConfigBaseDir      string
 	HAProxyConfigFile  string // 配置文件路径
 	HaproxyBin         string // HAProxy二进制文件路径
-	BackupsNumber      int
+	BackupsNumber      int    // 保留的配置版本数量
 	CertDir            string // 证书目录
 	TransactionDir     string // 事务目录
 	SpoeDir            string // SPOE目录
@@ -53,6 +59,9 @@ type HAProxyServiceImpl struct {
 	SpoeConfigFile     string // SPOE配置文件路径
 	SpoeAgentAddress   string // SPOE代理地址
 	SpoeAgentPort      int64  // SPOE代理端口
+	BlocklistFile      string // IP封禁列表 map 文件路径，热加载时保留
+	VersionDir         string // 已生效配置的版本目录，热加载时保留
+	AccessLogTarget    string // 访问日志 syslog 目标，如 127.0.0.1:5140 或 /run/access.sock，为空时只输出到标准输出
 
 	// internal field
 	haproxyCmd      *exec.Cmd                   // HAProxy进程命令
@@ -91,6 +100,10 @@ func (s *HAProxyServiceImpl) Start() error {
 		return fmt.Errorf("HAProxy已经在运行")
 	}
 
+	if err := s.validateConfig(s.HAProxyConfigFile); err != nil {
+		return err
+	}
+
 	// 启动HAProxy进程
 	args := []string{
 		"-f", s.HAProxyConfigFile,
@@ -139,6 +152,7 @@ func (s *HAProxyServiceImpl) Start() error {
 	}
 
 	s.status.Store(int32(StatusRunning))
+	s.recordVersion(VersionReasonStart, 0)
 
 	return nil
 }
@@ -161,13 +175,17 @@ func (s *HAProxyServiceImpl) AddSiteConfig(site model.Site) error {
 	if err := s.ensureConfClient(); err != nil {
 		return err
 	}
-	if _, err := s.getFeCombined(site.ListenPort); err != nil {
-		err = s.createFeCombined(site.ListenPort, site.EnableHTTPS)
-		if err != nil {
-			return fmt.Errorf("创建前端组合失败: %v", err)
-		}
+
+	// TCP 站点使用独立的前端和后端，与增量同步生成的配置一致
+	if site.IsTCP() {
+		_, err := s.inTransaction(func(txID string) (*siteDiff, error) {
+			return s.reconcileTCPSite(site, txID)
+		})
+		return err
 	}
 
+	_, feErr := s.getFeCombined(site.ListenPort)
+
 	version, err := s.confClient.GetVersion("")
 	if err != nil {
 		return fmt.Errorf("获取版本失败: %v", err)
@@ -177,6 +195,25 @@ func (s *HAProxyServiceImpl) AddSiteConfig(site model.Site) error {
 		return fmt.Errorf("启动事务失败: %v", err)
 	}
 
+	// 新端口的端口级配置与站点配置在同一个事务中提交
+	if feErr != nil {
+		if err := s.addFeCombined(site.ListenPort, site.EnableHTTPS, transaction.ID); err != nil {
+			s.confClient.DeleteTransaction(transaction.ID)
+			return fmt.Errorf("创建前端组合失败: %v", err)
+		}
+	}
+
+	// 端口前端创建时监听全部 IPv4 地址，按站点的监听地址调整
+	err = s.ensureListenBinds(fmt.Sprintf("fe_%d_combined", site.ListenPort), fmt.Sprintf("combined_%d", site.ListenPort), site, transaction.ID, &siteDiff{})
+	if err != nil {
+		s.confClient.DeleteTransaction(transaction.ID)
+		return err
+	}
+	if err := s.ensureAcceptProxy(site, transaction.ID, &siteDiff{}); err != nil {
+		s.confClient.DeleteTransaction(transaction.ID)
+		return err
+	}
+
 	// handle http
 	if isIPAddress(site.Domain) {
 		// IP address handling
@@ -185,8 +222,13 @@ func (s *HAProxyServiceImpl) AddSiteConfig(site model.Site) error {
 			return fmt.Errorf("删除后端服务器失败: %v", err)
 		}
 
+		// IP 站点的负载均衡和健康检查设置写在端口默认后端上
+		if err := s.ensureBackendOptions(fmt.Sprintf("p%d_backend", site.ListenPort), site.Backend, transaction.ID, &siteDiff{}); err != nil {
+			return err
+		}
+
 		for index, server := range site.Backend.Servers {
-			err = s.createBackendServer(fmt.Sprintf("s%s_%d", getDashDomain(site.Domain), index), server.Host, server.Port, transaction.ID, fmt.Sprintf("p%d_backend", site.ListenPort), server.IsSSL)
+			err = s.createBackendServer(fmt.Sprintf("s%s_%d", getDashDomain(site.Domain), index), server, site, transaction.ID, fmt.Sprintf("p%d_backend", site.ListenPort))
 			if err != nil {
 				return fmt.Errorf("创建后端服务器失败: %v", err)
 			}
@@ -198,16 +240,13 @@ func (s *HAProxyServiceImpl) AddSiteConfig(site model.Site) error {
 			return fmt.Errorf("获取 ACL 失败: %v", err)
 		}
 		aclIndex := len(aclList)
-		acl_http := &models.ACL{
-			ACLName:   fmt.Sprintf("host_%s", getDashDomain(site.Domain)), // 使用ACLName字段
-			Criterion: "hdr(host) -i -m end",
-			Value:     site.Domain, // 使用Value字段
-			// Criterion: "hdr(host) -i",                                     // 使用Criterion字段
-			// Value:     site.Domain,                                        // 使用Value字段
-		}
-		err = s.confClient.CreateACL(int64(aclIndex), "frontend", fmt.Sprintf("fe_%d_http", site.ListenPort), acl_http, transaction.ID, 0)
-		if err != nil {
-			return fmt.Errorf("创建 ACL 失败: %v", err)
+		hostACLName := fmt.Sprintf("host_%s", getDashDomain(site.Domain))
+		// 主域名和别名共用一个 ACL 名称
+		for i, acl_http := range hostACLs(hostACLName, hostCriterion, site.Hosts()) {
+			err = s.confClient.CreateACL(int64(aclIndex+i), "frontend", fmt.Sprintf("fe_%d_http", site.ListenPort), acl_http, transaction.ID, 0)
+			if err != nil {
+				return fmt.Errorf("创建 ACL 失败: %v", err)
+			}
 		}
 
 		backend_http := &models.Backend{
@@ -222,10 +261,17 @@ func (s *HAProxyServiceImpl) AddSiteConfig(site model.Site) error {
 				},
 			},
 		}
+		applyBackendOptions(&backend_http.BackendBase, site.Backend)
 		err = s.confClient.CreateBackend(backend_http, transaction.ID, 0)
 		if err != nil {
 			return fmt.Errorf("创建后端失败: %v", err)
 		}
+		if checks := backendHTTPChecks(site.Backend); len(checks) > 0 {
+			err = s.confClient.ReplaceHTTPChecks("backend", backend_http.Name, checks, transaction.ID, 0)
+			if err != nil {
+				return fmt.Errorf("创建健康检查规则失败: %v", err)
+			}
+		}
 
 		_, switchingRules, err := s.confClient.GetBackendSwitchingRules(fmt.Sprintf("fe_%d_http", site.ListenPort), "")
 		if err != nil {
@@ -236,7 +282,7 @@ func (s *HAProxyServiceImpl) AddSiteConfig(site model.Site) error {
 		httpUseBackendRule := &models.BackendSwitchingRule{
 			Name:     backend_http.Name,
 			Cond:     "if",
-			CondTest: acl_http.ACLName,
+			CondTest: hostACLName,
 		}
 		err = s.confClient.CreateBackendSwitchingRule(int64(switchingRuleIndex), fmt.Sprintf("fe_%d_http", site.ListenPort), httpUseBackendRule, transaction.ID, 0)
 		if err != nil {
@@ -244,11 +290,15 @@ func (s *HAProxyServiceImpl) AddSiteConfig(site model.Site) error {
 		}
 
 		for index, server := range site.Backend.Servers {
-			err = s.createBackendServer(fmt.Sprintf("%s_%d", getDashDomain(site.Domain), index), server.Host, server.Port, transaction.ID, backend_http.Name, server.IsSSL)
+			err = s.createBackendServer(fmt.Sprintf("%s_%d", getDashDomain(site.Domain), index), server, site, transaction.ID, backend_http.Name)
 			if err != nil {
 				return fmt.Errorf("创建后端服务器失败: %v", err)
 			}
 		}
+
+		if err := s.ensureBackendHeaders(backend_http.Name, site, transaction.ID, &siteDiff{}); err != nil {
+			return err
+		}
 	}
 
 	// handle https
@@ -258,70 +308,94 @@ func (s *HAProxyServiceImpl) AddSiteConfig(site model.Site) error {
 		if err != nil {
 			return fmt.Errorf("添加证书失败: %v", err)
 		}
-		// cert load
-		crtLoad := &models.CrtLoad{
-			Certificate: site.Domain + ".crt",
-			Key:         site.Domain + ".key",
-			Alias:       fmt.Sprintf("%s_cert", getDashDomain(site.Domain)),
-		}
-		err = s.confClient.CreateCrtLoad("sites", crtLoad, transaction.ID, 0)
-		if err != nil {
-			return fmt.Errorf("创建证书加载失败: %v", err)
-		}
+		if usesCrtList(site) {
+			// 带 TLS 策略或开启 HTTP/2、HTTP/3 的站点通过端口的 crt-list 加载证书
+			if err := s.ensureSiteTLS(site, transaction.ID, &siteDiff{}); err != nil {
+				return fmt.Errorf("配置站点 TLS 策略失败: %v", err)
+			}
+		} else {
+			// cert load
+			crtLoad := &models.CrtLoad{
+				Certificate: site.Domain + ".crt",
+				Key:         site.Domain + ".key",
+				Alias:       fmt.Sprintf("%s_cert", getDashDomain(site.Domain)),
+			}
+			err = s.confClient.CreateCrtLoad("sites", crtLoad, transaction.ID, 0)
+			if err != nil {
+				return fmt.Errorf("创建证书加载失败: %v", err)
+			}
 
-		// change bind
-		_, https_bind, err := s.confClient.GetBind("internal_https", "frontend", fmt.Sprintf("fe_%d_https", site.ListenPort), "")
-		if err != nil {
-			return fmt.Errorf("获取绑定失败: %v", err)
-		}
+			// change bind
+			_, https_bind, err := s.confClient.GetBind("internal_https", "frontend", fmt.Sprintf("fe_%d_https", site.ListenPort), "")
+			if err != nil {
+				return fmt.Errorf("获取绑定失败: %v", err)
+			}
 
-		if len(https_bind.BindParams.DefaultCrtList) > 0 {
-			https_bind.BindParams.DefaultCrtList = append(https_bind.BindParams.DefaultCrtList, fmt.Sprintf("@sites/%s_cert", getDashDomain(site.Domain)))
-		} else {
-			https_bind.BindParams.DefaultCrtList = []string{
-				fmt.Sprintf("@sites/%s_cert", getDashDomain(site.Domain)),
+			if len(https_bind.BindParams.DefaultCrtList) > 0 {
+				https_bind.BindParams.DefaultCrtList = append(https_bind.BindParams.DefaultCrtList, fmt.Sprintf("@sites/%s_cert", getDashDomain(site.Domain)))
+			} else {
+				https_bind.BindParams.DefaultCrtList = []string{
+					fmt.Sprintf("@sites/%s_cert", getDashDomain(site.Domain)),
+				}
 			}
-		}
-		https_bind.Ssl = true
+			https_bind.Ssl = true
 
-		err = s.confClient.EditBind("internal_https", "frontend", fmt.Sprintf("fe_%d_https", site.ListenPort), https_bind, transaction.ID, 0)
-		if err != nil {
-			return fmt.Errorf("修改绑定失败: %v", err)
+			err = s.confClient.EditBind("internal_https", "frontend", fmt.Sprintf("fe_%d_https", site.ListenPort), https_bind, transaction.ID, 0)
+			if err != nil {
+				return fmt.Errorf("修改绑定失败: %v", err)
+			}
 		}
 
-		_, aclList, err := s.confClient.GetACLs("frontend", fmt.Sprintf("fe_%d_https", site.ListenPort), "")
-		if err != nil {
-			return fmt.Errorf("获取 ACL 失败: %v", err)
-		}
-		aclIndex := len(aclList)
-		// add ack and rule backend
-		acl_https := &models.ACL{
-			ACLName:   fmt.Sprintf("host_%s", getDashDomain(site.Domain)), // 使用ACLName字段
-			Criterion: "hdr(host) -i -m end",                              // 修改Criterion字段使用-m end
-			Value:     site.Domain,                                        // 在域名前加上点号
-			// Criterion: "hdr(host) -i",                                     // 使用Criterion字段
-			// Value:     site.Domain,                                        // 使用Value字段
-		}
-		err = s.confClient.CreateACL(int64(aclIndex), "frontend", fmt.Sprintf("fe_%d_https", site.ListenPort), acl_https, transaction.ID, 0)
-		if err != nil {
-			return fmt.Errorf("创建 ACL 失败: %v", err)
-		}
+		// IP 站点通过端口默认后端转发，没有 be_<domain> 后端，不需要按主机名切换
+		if !isIPAddress(site.Domain) {
+			_, aclList, err := s.confClient.GetACLs("frontend", fmt.Sprintf("fe_%d_https", site.ListenPort), "")
+			if err != nil {
+				return fmt.Errorf("获取 ACL 失败: %v", err)
+			}
+			aclIndex := len(aclList)
+			// add ack and rule backend
+			hostACLName := fmt.Sprintf("host_%s", getDashDomain(site.Domain))
+			for i, acl_https := range hostACLs(hostACLName, hostCriterion, site.Hosts()) {
+				err = s.confClient.CreateACL(int64(aclIndex+i), "frontend", fmt.Sprintf("fe_%d_https", site.ListenPort), acl_https, transaction.ID, 0)
+				if err != nil {
+					return fmt.Errorf("创建 ACL 失败: %v", err)
+				}
+			}
 
-		_, switchingRules, err := s.confClient.GetBackendSwitchingRules(fmt.Sprintf("fe_%d_https", site.ListenPort), "")
-		if err != nil {
-			return fmt.Errorf("获取后端切换规则失败: %v", err)
-		}
-		switchingRuleIndex := len(switchingRules)
-		httpsUseBackendRule := &models.BackendSwitchingRule{
-			Name:     fmt.Sprintf("be_%s", getDashDomain(site.Domain)),
-			Cond:     "if",
-			CondTest: acl_https.ACLName,
+			_, switchingRules, err := s.confClient.GetBackendSwitchingRules(fmt.Sprintf("fe_%d_https", site.ListenPort), "")
+			if err != nil {
+				return fmt.Errorf("获取后端切换规则失败: %v", err)
+			}
+			switchingRuleIndex := len(switchingRules)
+			httpsUseBackendRule := &models.BackendSwitchingRule{
+				Name:     fmt.Sprintf("be_%s", getDashDomain(site.Domain)),
+				Cond:     "if",
+				CondTest: hostACLName,
+			}
+			err = s.confClient.CreateBackendSwitchingRule(int64(switchingRuleIndex), fmt.Sprintf("fe_%d_https", site.ListenPort), httpsUseBackendRule, transaction.ID, 0)
+			if err != nil {
+				return fmt.Errorf("创建后端切换规则失败: %v", err)
+			}
+
+			if err := s.ensureSNICheck(site, transaction.ID, &siteDiff{}); err != nil {
+				return fmt.Errorf("创建 SNI 校验规则失败: %v", err)
+			}
 		}
-		err = s.confClient.CreateBackendSwitchingRule(int64(switchingRuleIndex), fmt.Sprintf("fe_%d_https", site.ListenPort), httpsUseBackendRule, transaction.ID, 0)
-		if err != nil {
-			return fmt.Errorf("创建后端切换规则失败: %v", err)
+
+	}
+
+	// 路径路由的切换规则插入到主机名规则之前
+	if !isIPAddress(site.Domain) && len(site.Locations) > 0 {
+		if err := s.ensureLocations(site, transaction.ID, &siteDiff{}); err != nil {
+			return fmt.Errorf("创建路径路由失败: %v", err)
 		}
+	}
 
+	// 跳转和改写规则追加到前端请求规则的末尾
+	if !isIPAddress(site.Domain) && (len(site.Redirects) > 0 || len(site.Rewrites) > 0) {
+		if err := s.ensureRedirects(site, transaction.ID, &siteDiff{}); err != nil {
+			return fmt.Errorf("创建跳转和改写规则失败: %v", err)
+		}
 	}
 
 	transaction, err = s.confClient.CommitTransaction(transaction.ID)
@@ -342,11 +416,20 @@ func (s *HAProxyServiceImpl) Stop() error {
 
 }
 
+// Reload 校验已提交的配置并重载 HAProxy，失败时将配置文件和证书目录恢复为最近一次生效的版本
 func (s *HAProxyServiceImpl) Reload() error {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 
-	return s.reloadHAProxy()
+	if err := s.reloadHAProxy(); err != nil {
+		if restoreErr := s.restoreLatestVersion(); restoreErr != nil {
+			s.logger.Error().Err(restoreErr).Msg("恢复最近生效的 HAProxy 配置版本失败")
+			return err
+		}
+		return fmt.Errorf("%v，已恢复到最近生效的配置", err)
+	}
+	s.recordVersion(VersionReasonReload, 0)
+	return nil
 }
 
 func (s *HAProxyServiceImpl) HotReloadRemoveConfig() error {
@@ -510,6 +593,7 @@ func (s *HAProxyServiceImpl) InitHAProxyConfig() error {
 		s.SpoeDir,
 		s.SpoeTransactionDir,
 		s.CertDir,
+		filepath.Dir(s.BlocklistFile),
 	}
 
 	for _, dir := range dirs {
@@ -526,6 +610,13 @@ func (s *HAProxyServiceImpl) InitHAProxyConfig() error {
 		return fmt.Errorf("检查 haproxy 配置文件时出错: %v", err)
 	}
 
+	// 封禁列表由封禁管理器维护，这里只保证文件存在，不覆盖已有内容
+	blocklist, err := os.OpenFile(s.BlocklistFile, os.O_CREATE|os.O_RDONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to create blocklist file: %v", err)
+	}
+	blocklist.Close()
+
 	username := os.Getenv("USER")
 	if username == "" {
 		username = "haproxy"
@@ -535,7 +626,8 @@ func (s *HAProxyServiceImpl) InitHAProxyConfig() error {
 	configTemplate := `# _version = 1
 global
     log stdout format raw local0
-{{if gt .Thread 0}}    nbthread {{.Thread}} # 线程数
+{{if .AccessLogTarget}}    log {{.AccessLogTarget}} len 8192 format raw local1 info # 内置访问日志接收器
+{{end}}{{if gt .Thread 0}}    nbthread {{.Thread}} # 线程数
 {{end}} 
     # user {{.Username}}
     # group {{.Username}}
@@ -565,11 +657,13 @@ frontend stats from http
 	fmt.Println("s.thread", s.thread)
 	// 准备模板数据
 	data := struct {
-		Username string
-		Thread   int
+		Username        string
+		Thread          int
+		AccessLogTarget string
 	}{
-		Username: username,
-		Thread:   s.thread,
+		Username:        username,
+		Thread:          s.thread,
+		AccessLogTarget: s.AccessLogTarget,
 	}
 
 	// 解析模板
@@ -637,12 +731,12 @@ func (s *HAProxyServiceImpl) InitSpoeConfig() error {
 
 	agent := &models.SpoeAgent{
 		Name: StringP("coraza-agent"),
-		// 根据 isResponseCheck 决定是否包含响应处理
-		Messages: func() string {
+		// 消息通过前端的 send-spoe-group 规则触发，根据 isResponseCheck 决定是否包含响应处理
+		Groups: func() string {
 			if s.isResponseCheck {
-				return "coraza-req coraza-res"
+				return spoeRequestGroup + " " + spoeResponseGroup
 			}
-			return "coraza-req"
+			return spoeRequestGroup
 		}(),
 		OptionVarPrefix:   "coraza",
 		OptionSetOnError:  "error",
@@ -659,14 +753,10 @@ func (s *HAProxyServiceImpl) InitSpoeConfig() error {
 		return fmt.Errorf("创建 SPOE 代理错误: %v", err)
 	}
 
-	// 创建 coraza-req 消息
-	reqEvent := &models.SpoeMessageEvent{
-		Name: StringP("on-frontend-http-request"),
-	}
+	// 创建 coraza-req 消息，不绑定事件，由 send-spoe-group 规则按条件发送
 	reqMsg := &models.SpoeMessage{
-		Name:  StringP("coraza-req"),
-		Event: reqEvent,
-		Args:  "app=str(coraza) src-ip=src src-port=src_port dst-ip=dst dst-port=dst_port method=method path=path query=query version=req.ver headers=req.hdrs body=req.body",
+		Name: StringP("coraza-req"),
+		Args: "app=str(coraza) src-ip=src src-port=src_port dst-ip=dst dst-port=dst_port method=method path=path query=query version=req.ver headers=req.hdrs body=req.body",
 	}
 
 	// 在 coraza section 下创建 message
@@ -676,15 +766,17 @@ func (s *HAProxyServiceImpl) InitSpoeConfig() error {
 		return fmt.Errorf("创建 SPOE 请求消息错误: %v", err)
 	}
 
+	err = singleSpoe.CreateGroup(string(scopeName), &models.SpoeGroup{Name: StringP(spoeRequestGroup), Messages: "coraza-req"}, transaction.ID, 0)
+	if err != nil {
+		singleSpoe.Transaction.DeleteTransaction(transaction.ID)
+		return fmt.Errorf("创建 SPOE 请求消息组错误: %v", err)
+	}
+
 	// 创建 coraza-res 消息
 	if s.isResponseCheck {
-		resEvent := &models.SpoeMessageEvent{
-			Name: StringP("on-http-response"),
-		}
 		resMsg := &models.SpoeMessage{
-			Name:  StringP("coraza-res"),
-			Event: resEvent,
-			Args:  "app=str(coraza) id=var(txn.coraza.id) version=res.ver status=status headers=res.hdrs body=res.body",
+			Name: StringP("coraza-res"),
+			Args: "app=str(coraza) id=var(txn.coraza.id) version=res.ver status=status headers=res.hdrs body=res.body",
 		}
 
 		err = singleSpoe.CreateMessage(string(scopeName), resMsg, transaction.ID, 0)
@@ -693,6 +785,12 @@ func (s *HAProxyServiceImpl) InitSpoeConfig() error {
 			return fmt.Errorf("创建 SPOE 响应消息错误: %v", err)
 		}
 
+		err = singleSpoe.CreateGroup(string(scopeName), &models.SpoeGroup{Name: StringP(spoeResponseGroup), Messages: "coraza-res"}, transaction.ID, 0)
+		if err != nil {
+			singleSpoe.Transaction.DeleteTransaction(transaction.ID)
+			return fmt.Errorf("创建 SPOE 响应消息组错误: %v", err)
+		}
+
 	}
 
 	_, err = singleSpoe.Transaction.CommitTransaction(transaction.ID)
@@ -810,6 +908,7 @@ func (s *HAProxyServiceImpl) Reset() error {
 	}
 
 	s.thread = appConfig.Haproxy.Thread
+	s.BackupsNumber = appConfig.Haproxy.BackupsNumber
 	s.isResponseCheck = appConfig.IsResponseCheck
 	s.isDebug = appConfig.IsDebug
 
@@ -1007,7 +1106,11 @@ func (s *HAProxyServiceImpl) stopHAProxy() error {
 	return nil
 }
 
+// reloadHAProxy 校验配置文件后通知 HAProxy 重新加载，校验失败时保持当前运行的配置
 func (s *HAProxyServiceImpl) reloadHAProxy() error {
+	if err := s.validateConfig(s.HAProxyConfigFile); err != nil {
+		return err
+	}
 
 	if err := s.ensureRuntimeClient(); err != nil {
 		return fmt.Errorf("初始化运行时客户端失败: %v", err)
@@ -1128,19 +1231,9 @@ func (s *HAProxyServiceImpl) getFeCombined(port int) (string, error) {
 	return frontend.Name, nil
 }
 
-func (s *HAProxyServiceImpl) createFeCombined(port int, isHttpsRedirect bool) error {
-	// 确保配置客户端初始化
-	if err := s.ensureConfClient(); err != nil {
-		return fmt.Errorf("初始化配置客户端失败: %v", err)
-	}
-	version, err := s.confClient.GetVersion("")
-	if err != nil {
-		return fmt.Errorf("获取版本失败: %v", err)
-	}
-	transaction, err := s.confClient.StartTransaction(version)
-	if err != nil {
-		return fmt.Errorf("启动事务失败: %v", err)
-	}
+// addFeCombined 在事务中创建端口级的组合前端、HTTP/HTTPS 前端和后端
+func (s *HAProxyServiceImpl) addFeCombined(port int, isHttpsRedirect bool, txID string) error {
+	var err error
 
 	// 创建 fe_(port)_combined
 	fe_combined := &models.Frontend{
@@ -1152,7 +1245,7 @@ func (s *HAProxyServiceImpl) createFeCombined(port int, isHttpsRedirect bool) er
 			From:           "tcp",
 		},
 	}
-	err = s.confClient.CreateFrontend(fe_combined, transaction.ID, 0)
+	err = s.confClient.CreateFrontend(fe_combined, txID, 0)
 
 	if err != nil {
 		return fmt.Errorf("创建前端失败: %v", err)
@@ -1165,18 +1258,30 @@ func (s *HAProxyServiceImpl) createFeCombined(port int, isHttpsRedirect bool) er
 		Address: "*",
 		Port:    Int64P(int64(port)),
 	}
-	err = s.confClient.CreateBind("frontend", fe_combined.Name, bind, transaction.ID, 0)
+	err = s.confClient.CreateBind("frontend", fe_combined.Name, bind, txID, 0)
 	if err != nil {
 		return fmt.Errorf("创建绑定失败: %v", err)
 	}
 
 	// rule
 
+	// 在建立连接时拒绝封禁列表中的来源IP，封禁列表通过运行时 API 更新
+	tcpRejectBanned := &models.TCPRequestRule{
+		Type:     "connection",
+		Action:   "reject",
+		Cond:     "if",
+		CondTest: fmt.Sprintf("{ src,map_ip(%s) -m found }", s.BlocklistFile),
+	}
+	err = s.confClient.CreateTCPRequestRule(0, "frontend", fe_combined.Name, tcpRejectBanned, txID, 0)
+	if err != nil {
+		return fmt.Errorf("创建TCP请求规则失败: %v", err)
+	}
+
 	tcpInspectDelay := &models.TCPRequestRule{
 		Type:    "inspect-delay",
 		Timeout: Int64P(2),
 	}
-	err = s.confClient.CreateTCPRequestRule(0, "frontend", fe_combined.Name, tcpInspectDelay, transaction.ID, 0)
+	err = s.confClient.CreateTCPRequestRule(1, "frontend", fe_combined.Name, tcpInspectDelay, txID, 0)
 
 	if err != nil {
 		return fmt.Errorf("创建TCP请求规则失败: %v", err)
@@ -1189,7 +1294,7 @@ func (s *HAProxyServiceImpl) createFeCombined(port int, isHttpsRedirect bool) er
 		CondTest: "HTTP",
 	}
 
-	err = s.confClient.CreateTCPRequestRule(1, "frontend", fe_combined.Name, tcpAcceptHTTP, transaction.ID, 0)
+	err = s.confClient.CreateTCPRequestRule(2, "frontend", fe_combined.Name, tcpAcceptHTTP, txID, 0)
 	if err != nil {
 		return fmt.Errorf("创建TCP请求规则失败: %v", err)
 	}
@@ -1200,7 +1305,7 @@ func (s *HAProxyServiceImpl) createFeCombined(port int, isHttpsRedirect bool) er
 		Cond:     "if",
 		CondTest: "{ req.ssl_hello_type 1 }",
 	}
-	err = s.confClient.CreateTCPRequestRule(2, "frontend", fe_combined.Name, tcpAcceptSSL, transaction.ID, 0)
+	err = s.confClient.CreateTCPRequestRule(3, "frontend", fe_combined.Name, tcpAcceptSSL, txID, 0)
 	if err != nil {
 		return fmt.Errorf("创建TCP请求规则失败: %v", err)
 	}
@@ -1211,7 +1316,7 @@ func (s *HAProxyServiceImpl) createFeCombined(port int, isHttpsRedirect bool) er
 		Cond:     "if",
 		CondTest: "HTTP",
 	}
-	err = s.confClient.CreateBackendSwitchingRule(0, fe_combined.Name, useBackendRule, transaction.ID, 0)
+	err = s.confClient.CreateBackendSwitchingRule(0, fe_combined.Name, useBackendRule, txID, 0)
 	if err != nil {
 		return fmt.Errorf("创建后端切换规则失败: %v", err)
 	}
@@ -1225,7 +1330,7 @@ func (s *HAProxyServiceImpl) createFeCombined(port int, isHttpsRedirect bool) er
 			From:    "tcp",
 		},
 	}
-	err = s.confClient.CreateBackend(be_http, transaction.ID, 0)
+	err = s.confClient.CreateBackend(be_http, txID, 0)
 	if err != nil {
 		return fmt.Errorf("创建后端失败: %v", err)
 	}
@@ -1239,7 +1344,7 @@ func (s *HAProxyServiceImpl) createFeCombined(port int, isHttpsRedirect bool) er
 		Port:    Int64P(1000),
 	}
 
-	err = s.confClient.CreateServer("backend", be_http.Name, serverHTTP, transaction.ID, 0)
+	err = s.confClient.CreateServer("backend", be_http.Name, serverHTTP, txID, 0)
 	if err != nil {
 		return fmt.Errorf("创建服务器失败: %v", err)
 	}
@@ -1252,7 +1357,7 @@ func (s *HAProxyServiceImpl) createFeCombined(port int, isHttpsRedirect bool) er
 			From:    "tcp",
 		},
 	}
-	err = s.confClient.CreateBackend(be_https, transaction.ID, 0)
+	err = s.confClient.CreateBackend(be_https, txID, 0)
 	if err != nil {
 		return fmt.Errorf("创建后端失败: %v", err)
 	}
@@ -1266,7 +1371,7 @@ func (s *HAProxyServiceImpl) createFeCombined(port int, isHttpsRedirect bool) er
 		Port:    Int64P(1000),
 	}
 
-	err = s.confClient.CreateServer("backend", be_https.Name, serverHTTPS, transaction.ID, 0)
+	err = s.confClient.CreateServer("backend", be_https.Name, serverHTTPS, txID, 0)
 	if err != nil {
 		return fmt.Errorf("创建服务器失败: %v", err)
 	}
@@ -1288,7 +1393,7 @@ func (s *HAProxyServiceImpl) createFeCombined(port int, isHttpsRedirect bool) er
 			},
 		},
 	}
-	err = s.confClient.CreateFrontend(fe_http, transaction.ID, 0)
+	err = s.confClient.CreateFrontend(fe_http, txID, 0)
 	if err != nil {
 		return fmt.Errorf("创建前端失败: %v", err)
 	}
@@ -1302,7 +1407,7 @@ func (s *HAProxyServiceImpl) createFeCombined(port int, isHttpsRedirect bool) er
 		Address: fmt.Sprintf("abns@haproxy-%d-http", port),
 	}
 
-	err = s.confClient.CreateBind("frontend", fe_http.Name, fe_http_bind, transaction.ID, 0)
+	err = s.confClient.CreateBind("frontend", fe_http.Name, fe_http_bind, txID, 0)
 	if err != nil {
 		return fmt.Errorf("创建绑定失败: %v", err)
 	}
@@ -1313,7 +1418,7 @@ func (s *HAProxyServiceImpl) createFeCombined(port int, isHttpsRedirect bool) er
 		SpoeEngine: "coraza",         // SPOE引擎名称
 		SpoeConfig: s.SpoeConfigFile, // 使用配置文件的标准路径
 	}
-	err = s.confClient.CreateFilter(0, "frontend", fe_http.Name, fe_http_filter, transaction.ID, 0)
+	err = s.confClient.CreateFilter(0, "frontend", fe_http.Name, fe_http_filter, txID, 0)
 	if err != nil {
 		return fmt.Errorf("创建过滤器失败: %v", err)
 	}
@@ -1400,12 +1505,24 @@ func (s *HAProxyServiceImpl) createFeCombined(port int, isHttpsRedirect bool) er
 	}
 
 	for i, item := range fe_http_request_rule {
-		err = s.confClient.CreateHTTPRequestRule(item.index, "frontend", fe_http.Name, item.rule, transaction.ID, 0)
+		err = s.confClient.CreateHTTPRequestRule(item.index, "frontend", fe_http.Name, item.rule, txID, 0)
 		if err != nil {
 			return fmt.Errorf("添加HTTP请求规则 #%d 错误: %v", i, err)
 		}
 	}
 
+	// WAF 检测排在处理规则之前，路径路由关闭 WAF 的规则插入到它前面
+	err = s.confClient.CreateHTTPRequestRule(0, "frontend", fe_http.Name, newWAFRequestRule(), txID, 0)
+	if err != nil {
+		return fmt.Errorf("添加 WAF 检测规则错误: %v", err)
+	}
+
+	// Host 捕获排在最前，被重定向和拦截的请求也能归属到站点
+	err = s.confClient.CreateHTTPRequestRule(0, "frontend", fe_http.Name, newHostCaptureRule(), txID, 0)
+	if err != nil {
+		return fmt.Errorf("添加 Host 捕获规则错误: %v", err)
+	}
+
 	// 添加HTTP响应规则 - 确保HTTP响应规则结构正确
 	fe_http_response_rule := []struct {
 		index int64
@@ -1441,12 +1558,19 @@ func (s *HAProxyServiceImpl) createFeCombined(port int, isHttpsRedirect bool) er
 	}
 
 	for i, item := range fe_http_response_rule {
-		err = s.confClient.CreateHTTPResponseRule(item.index, "frontend", fe_http.Name, item.rule, transaction.ID, 0)
+		err = s.confClient.CreateHTTPResponseRule(item.index, "frontend", fe_http.Name, item.rule, txID, 0)
 		if err != nil {
 			return fmt.Errorf("添加HTTP响应规则 #%d 错误: %v", i, err)
 		}
 	}
 
+	if s.isResponseCheck {
+		err = s.confClient.CreateHTTPResponseRule(0, "frontend", fe_http.Name, newWAFResponseRule(), txID, 0)
+		if err != nil {
+			return fmt.Errorf("添加 WAF 响应检测规则错误: %v", err)
+		}
+	}
+
 	// fe_(port)_https
 	fe_https := &models.Frontend{
 		FrontendBase: models.FrontendBase{
@@ -1462,7 +1586,7 @@ func (s *HAProxyServiceImpl) createFeCombined(port int, isHttpsRedirect bool) er
 			},
 		},
 	}
-	err = s.confClient.CreateFrontend(fe_https, transaction.ID, 0)
+	err = s.confClient.CreateFrontend(fe_https, txID, 0)
 	if err != nil {
 		return fmt.Errorf("创建前端失败: %v", err)
 	}
@@ -1474,7 +1598,7 @@ func (s *HAProxyServiceImpl) createFeCombined(port int, isHttpsRedirect bool) er
 		Port:    Int64P(1000),
 		Address: fmt.Sprintf("abns@haproxy-%d-https", port),
 	}
-	err = s.confClient.CreateBind("frontend", fe_https.Name, fe_https_bind, transaction.ID, 0)
+	err = s.confClient.CreateBind("frontend", fe_https.Name, fe_https_bind, txID, 0)
 	if err != nil {
 		return fmt.Errorf("创建绑定失败: %v", err)
 	}
@@ -1485,7 +1609,7 @@ func (s *HAProxyServiceImpl) createFeCombined(port int, isHttpsRedirect bool) er
 		SpoeEngine: "coraza",         // SPOE引擎名称
 		SpoeConfig: s.SpoeConfigFile, // 使用配置文件的标准路径
 	}
-	err = s.confClient.CreateFilter(0, "frontend", fe_https.Name, fe_https_filter, transaction.ID, 0)
+	err = s.confClient.CreateFilter(0, "frontend", fe_https.Name, fe_https_filter, txID, 0)
 	if err != nil {
 		return fmt.Errorf("创建过滤器失败: %v", err)
 	}
@@ -1525,12 +1649,24 @@ func (s *HAProxyServiceImpl) createFeCombined(port int, isHttpsRedirect bool) er
 	}
 
 	for i, item := range fe_https_request_rule {
-		err = s.confClient.CreateHTTPRequestRule(item.index, "frontend", fe_https.Name, item.rule, transaction.ID, 0)
+		err = s.confClient.CreateHTTPRequestRule(item.index, "frontend", fe_https.Name, item.rule, txID, 0)
 		if err != nil {
 			return fmt.Errorf("添加HTTP请求规则 #%d 错误: %v", i, err)
 		}
 	}
 
+	// WAF 检测排在处理规则之前，路径路由关闭 WAF 的规则插入到它前面
+	err = s.confClient.CreateHTTPRequestRule(0, "frontend", fe_https.Name, newWAFRequestRule(), txID, 0)
+	if err != nil {
+		return fmt.Errorf("添加 WAF 检测规则错误: %v", err)
+	}
+
+	// Host 捕获排在最前，被重定向和拦截的请求也能归属到站点
+	err = s.confClient.CreateHTTPRequestRule(0, "frontend", fe_https.Name, newHostCaptureRule(), txID, 0)
+	if err != nil {
+		return fmt.Errorf("添加 Host 捕获规则错误: %v", err)
+	}
+
 	// 添加HTTPs响应规则 - 确保HTTP响应规则结构正确
 	fe_https_response_rule := []struct {
 		index int64
@@ -1566,12 +1702,19 @@ func (s *HAProxyServiceImpl) createFeCombined(port int, isHttpsRedirect bool) er
 	}
 
 	for i, item := range fe_https_response_rule {
-		err = s.confClient.CreateHTTPResponseRule(item.index, "frontend", fe_https.Name, item.rule, transaction.ID, 0)
+		err = s.confClient.CreateHTTPResponseRule(item.index, "frontend", fe_https.Name, item.rule, txID, 0)
 		if err != nil {
 			return fmt.Errorf("添加HTTP响应规则 #%d 错误: %v", i, err)
 		}
 	}
 
+	if s.isResponseCheck {
+		err = s.confClient.CreateHTTPResponseRule(0, "frontend", fe_https.Name, newWAFResponseRule(), txID, 0)
+		if err != nil {
+			return fmt.Errorf("添加 WAF 响应检测规则错误: %v", err)
+		}
+	}
+
 	// default backend
 	be_default := &models.Backend{
 		BackendBase: models.BackendBase{
@@ -1585,7 +1728,7 @@ func (s *HAProxyServiceImpl) createFeCombined(port int, isHttpsRedirect bool) er
 			},
 		},
 	}
-	err = s.confClient.CreateBackend(be_default, transaction.ID, 0)
+	err = s.confClient.CreateBackend(be_default, txID, 0)
 	if err != nil {
 		return fmt.Errorf("创建后端失败: %v", err)
 	}
@@ -1595,39 +1738,40 @@ func (s *HAProxyServiceImpl) createFeCombined(port int, isHttpsRedirect bool) er
 		Address: "httpbin.org",
 		Port:    Int64P(80),
 	}
-	err = s.confClient.CreateServer("backend", be_default.Name, be_default_server, transaction.ID, 0)
+	err = s.confClient.CreateServer("backend", be_default.Name, be_default_server, txID, 0)
 	if err != nil {
 		return fmt.Errorf("创建后端服务器失败: %v", err)
 	}
 
-	transaction, err = s.confClient.CommitTransaction(transaction.ID)
-	if err != nil {
-		return fmt.Errorf("提交事务失败: %v", err)
-	}
-
-	s.confClient.DeleteTransaction(transaction.ID)
-
 	return nil
-
 }
 
-func (s *HAProxyServiceImpl) createBackendServer(name, address string, port int, transactionID string, backendName string, isSsl bool) error {
-	server := &models.Server{
-		Name:    name,
-		Address: address,
-		Port:    Int64P(int64(port)),
+// newWAFRequestRule 发送请求检测消息组，txn.waf_off 为 true 时跳过
+func newWAFRequestRule() *models.HTTPRequestRule {
+	return &models.HTTPRequestRule{
+		Type:       "send-spoe-group",
+		SpoeEngine: "coraza",
+		SpoeGroup:  spoeRequestGroup,
+		Cond:       "unless",
+		CondTest:   fmt.Sprintf("{ var(txn.%s) -m bool }", wafOffVar),
 	}
+}
 
-	if isSsl {
-		server.ServerParams = models.ServerParams{
-			Ssl: "enabled",
-			// SslCafile: "",
-			Verify: "none", // 不验证证书
-		}
+// newWAFResponseRule 发送响应检测消息组，跳过请求检测的事务同样跳过响应检测
+func newWAFResponseRule() *models.HTTPResponseRule {
+	return &models.HTTPResponseRule{
+		Type:       "send-spoe-group",
+		SpoeEngine: "coraza",
+		SpoeGroup:  spoeResponseGroup,
+		Cond:       "unless",
+		CondTest:   fmt.Sprintf("{ var(txn.%s) -m bool }", wafOffVar),
 	}
+}
 
-	return s.confClient.CreateServer("backend", backendName, server, transactionID, 0)
-
+func (s *HAProxyServiceImpl) createBackendServer(name string, server model.Server, site model.Site, transactionID string, backendName string) error {
+	srv := newBackendServer(name, server, site.Backend)
+	applyProxyProtocol(srv, site)
+	return s.confClient.CreateServer("backend", backendName, srv, transactionID, 0)
 }
 
 // Int64P 返回指向int64的指针
@@ -1657,8 +1801,8 @@ func GetSafeInt64(ptr *int64) int64 {
 }
 
 func getDashDomain(domain string) string {
-	// 将域名中的点号替换为下划线
-	dashDomain := strings.ReplaceAll(domain, ".", "_")
+	// 将域名中的点号、通配符和 IPv6 地址中的冒号替换为下划线，*.a.com 转换为 __a_com，2001:db8::1 转换为 2001_db8__1
+	dashDomain := strings.NewReplacer(".", "_", "*", "_", ":", "_").Replace(domain)
 	return dashDomain
 }
 
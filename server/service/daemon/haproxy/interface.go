@@ -18,11 +18,19 @@ type HAProxyService interface {
 	InitHAProxyConfig() error
 	AddCorazaBackend() error
 	AddSiteConfig(site model.Site) error
+	UpdateSiteConfig(site model.Site) (bool, error)
+	RemoveSiteConfig(site model.Site) (bool, error)
 	Start() error
 	Reload() error
 	Stop() error
 	GetStatus() HAProxyStatus
 	Reset() error
+	SyncBlocklist(entries map[string]string) error
+	ListConfigVersions() ([]ConfigVersion, error)
+	GetConfigVersion(version int) (*ConfigVersion, string, error)
+	RollbackConfig(version int) error
+	GetSiteHealth(site model.Site) ([]ServerHealth, error)
+	GetStats(sites []model.Site) (*RuntimeStats, error)
 }
 
 // NewHAProxyService 创建一个新的HAProxy服务实例
@@ -58,7 +66,7 @@ func NewHAProxyService(configBaseDir, haproxyBin string, ctx context.Context) (H
 		ConfigBaseDir:      configBaseDir,
 		HAProxyConfigFile:  filepath.Join(configBaseDir, "/haproxy/conf/haproxy.cfg"),
 		HaproxyBin:         haproxyBin,
-		BackupsNumber:      3,
+		BackupsNumber:      appConfig.Haproxy.BackupsNumber,
 		CertDir:            filepath.Join(configBaseDir, "/haproxy/cert"),
 		TransactionDir:     filepath.Join(configBaseDir, "/haproxy/conf/transaction"),
 		SpoeDir:            filepath.Join(configBaseDir, "/haproxy/spoe"),
@@ -66,6 +74,9 @@ func NewHAProxyService(configBaseDir, haproxyBin string, ctx context.Context) (H
 		SocketFile:         filepath.Join(configBaseDir, "/haproxy/conf/haproxy-master.sock"),
 		PidFile:            filepath.Join(configBaseDir, "/haproxy/conf/haproxy.pid"),
 		SpoeConfigFile:     filepath.Join(configBaseDir, "/haproxy/spoe/coraza-spoa.yaml"),
+		BlocklistFile:      filepath.Join(configBaseDir, "/haproxy/maps/blocklist.map"),
+		VersionDir:         filepath.Join(configBaseDir, "/haproxy/versions"),
+		AccessLogTarget:    accessLogTarget(),
 		SpoeAgentAddress:   "127.0.0.1",
 		SpoeAgentPort:      2342,
 		isResponseCheck:    false,
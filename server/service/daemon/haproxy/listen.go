@@ -0,0 +1,79 @@
+package haproxy
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/HUAHUAI23/simple-waf/server/model"
+	"github.com/haproxytech/client-native/v6/models"
+)
+
+// listenBinds 生成站点端口的监听绑定，第一个绑定沿用 <bindName>，其余依次为 <bindName>_1、<bindName>_2
+//
+// 未设置监听地址时监听全部 IPv4 地址；:: 使用 v4v6 同时接收 IPv4 和 IPv6 连接，
+// 不依赖系统 bindv6only 设置。
+func listenBinds(bindName string, port int, addresses []string) []*models.Bind {
+	if len(addresses) == 0 {
+		addresses = []string{"*"}
+	}
+	binds := make([]*models.Bind, 0, len(addresses))
+	for i, address := range addresses {
+		bind := &models.Bind{
+			BindParams: models.BindParams{Name: bindName},
+			Address:    address,
+			Port:       Int64P(int64(port)),
+		}
+		if i > 0 {
+			bind.Name = fmt.Sprintf("%s_%d", bindName, i)
+		}
+		if address == "::" {
+			bind.V4v6 = true
+		}
+		binds = append(binds, bind)
+	}
+	return binds
+}
+
+// ensureListenBinds 使前端的监听绑定与站点的监听地址一致，有差异时整体替换，新的监听需要重载才能生效
+func (s *HAProxyServiceImpl) ensureListenBinds(frontend, bindName string, site model.Site, txID string, diff *siteDiff) error {
+	return s.ensureBinds(frontend, bindName, listenBinds(bindName, site.ListenPort, site.ListenAddrs), txID, diff)
+}
+
+// ensureBinds 使前端中名称为 <bindName> 或以 <bindName>_ 开头的绑定与 want 一致，want 为空时全部删除
+func (s *HAProxyServiceImpl) ensureBinds(frontend, bindName string, want []*models.Bind, txID string, diff *siteDiff) error {
+	_, binds, err := s.confClient.GetBinds("frontend", frontend, txID)
+	if err != nil {
+		return fmt.Errorf("获取绑定失败: %v", err)
+	}
+
+	var current []*models.Bind
+	for _, bind := range binds {
+		if bind.Name == bindName || strings.HasPrefix(bind.Name, bindName+"_") {
+			current = append(current, bind)
+		}
+	}
+	if slices.EqualFunc(current, want, sameListenBind) {
+		return nil
+	}
+
+	for _, bind := range current {
+		if err := s.confClient.DeleteBind(bind.Name, "frontend", frontend, txID, 0); err != nil {
+			return fmt.Errorf("删除绑定失败: %v", err)
+		}
+	}
+	for _, bind := range want {
+		if err := s.confClient.CreateBind("frontend", frontend, bind, txID, 0); err != nil {
+			return fmt.Errorf("创建绑定失败: %v", err)
+		}
+	}
+	diff.configChanged = true
+	return nil
+}
+
+func sameListenBind(a, b *models.Bind) bool {
+	return a.Name == b.Name && a.Address == b.Address &&
+		GetSafeInt64(a.Port) == GetSafeInt64(b.Port) &&
+		a.V4v6 == b.V4v6 && a.Ssl == b.Ssl &&
+		a.CrtList == b.CrtList && a.Alpn == b.Alpn
+}
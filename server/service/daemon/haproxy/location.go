@@ -0,0 +1,432 @@
+package haproxy
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/HUAHUAI23/simple-waf/server/model"
+	"github.com/haproxytech/client-native/v6/models"
+)
+
+const (
+	locationVar = "location" // 记录命中的路径路由，按顺序只记录第一个命中的规则
+	wafOffVar   = "waf_off"  // 为 true 时跳过 WAF 检测
+)
+
+// locationRoute 站点路径路由在一个前端中的配置
+type locationRoute struct {
+	acls         []*models.ACL
+	rules        []*models.BackendSwitchingRule
+	requestRules []*models.HTTPRequestRule // 关闭 WAF 检测的规则，需要排在 send-spoe-group 之前
+}
+
+// locationBackend 路径路由的后端配置
+type locationBackend struct {
+	backend       *models.Backend
+	servers       []*models.Server
+	checks        models.HTTPChecks          // 健康检查的期望状态码
+	requestRules  []*models.HTTPRequestRule  // 转发前的路径改写和请求头改写
+	responseRules []*models.HTTPResponseRule // 响应头改写
+}
+
+// ensureLocations 将站点的路径路由同步到前端和后端，任何差异都需要重载
+func (s *HAProxyServiceImpl) ensureLocations(site model.Site, txID string, diff *siteDiff) error {
+	route := buildLocationRoute(site)
+	if err := s.ensureLocationRoute(fmt.Sprintf("fe_%d_http", site.ListenPort), site, route, txID, diff); err != nil {
+		return err
+	}
+	httpsRoute := locationRoute{}
+	if site.EnableHTTPS {
+		httpsRoute = route
+	}
+	if err := s.ensureLocationRoute(fmt.Sprintf("fe_%d_https", site.ListenPort), site, httpsRoute, txID, diff); err != nil {
+		return err
+	}
+
+	desired := make(map[string]locationBackend, len(site.Locations))
+	for i := range site.Locations {
+		lb := buildLocationBackend(site, i)
+		desired[lb.backend.Name] = lb
+	}
+
+	_, backends, err := s.confClient.GetBackends(txID)
+	if err != nil {
+		return fmt.Errorf("获取后端失败: %v", err)
+	}
+	prefix := locationBackendPrefix(getDashDomain(site.Domain))
+	for _, backend := range backends {
+		if !strings.HasPrefix(backend.Name, prefix) {
+			continue
+		}
+		if lb, ok := desired[backend.Name]; ok {
+			same, err := s.sameLocationBackend(lb, txID)
+			if err != nil {
+				return err
+			}
+			if same {
+				delete(desired, backend.Name)
+				continue
+			}
+		}
+		if err := s.confClient.DeleteBackend(backend.Name, txID, 0); err != nil {
+			return fmt.Errorf("删除后端失败: %v", err)
+		}
+		diff.configChanged = true
+	}
+
+	for i := range site.Locations {
+		lb, ok := desired[locationBackendName(getDashDomain(site.Domain), i)]
+		if !ok {
+			continue
+		}
+		if err := s.createLocationBackend(lb, txID); err != nil {
+			return err
+		}
+		diff.configChanged = true
+	}
+
+	return nil
+}
+
+// removeLocations 删除站点的全部路径路由配置
+func (s *HAProxyServiceImpl) removeLocations(site model.Site, txID string, diff *siteDiff) error {
+	site.Locations = nil
+	return s.ensureLocations(site, txID, diff)
+}
+
+// ensureLocationRoute 比较前端中站点的路径路由配置，不一致时整体删除后按顺序重建
+// 后端切换规则和请求规则插入到最前面，先于站点的主机名规则和 WAF 检测生效
+func (s *HAProxyServiceImpl) ensureLocationRoute(frontend string, site model.Site, route locationRoute, txID string, diff *siteDiff) error {
+	if _, _, err := s.confClient.GetFrontend(frontend, txID); err != nil {
+		return nil
+	}
+	dash := getDashDomain(site.Domain)
+	aclPrefix := locationACLPrefix(dash)
+	backendPrefix := locationBackendPrefix(dash)
+
+	_, aclList, err := s.confClient.GetACLs("frontend", frontend, txID)
+	if err != nil {
+		return fmt.Errorf("获取 ACL 失败: %v", err)
+	}
+	_, rules, err := s.confClient.GetBackendSwitchingRules(frontend, txID)
+	if err != nil {
+		return fmt.Errorf("获取后端切换规则失败: %v", err)
+	}
+	_, requestRules, err := s.confClient.GetHTTPRequestRules("frontend", frontend, txID)
+	if err != nil {
+		return fmt.Errorf("获取HTTP请求规则失败: %v", err)
+	}
+
+	var current locationRoute
+	var aclIndexes, ruleIndexes, requestRuleIndexes []int
+	for i, acl := range aclList {
+		if strings.HasPrefix(acl.ACLName, aclPrefix) {
+			current.acls = append(current.acls, acl)
+			aclIndexes = append(aclIndexes, i)
+		}
+	}
+	for i, rule := range rules {
+		if strings.HasPrefix(rule.Name, backendPrefix) {
+			current.rules = append(current.rules, rule)
+			ruleIndexes = append(ruleIndexes, i)
+		}
+	}
+	for i, rule := range requestRules {
+		if rule.Type == "set-var" && (rule.VarName == locationVar || rule.VarName == wafOffVar) && strings.Contains(rule.CondTest, aclPrefix) {
+			current.requestRules = append(current.requestRules, rule)
+			requestRuleIndexes = append(requestRuleIndexes, i)
+		}
+	}
+
+	if slices.EqualFunc(current.acls, route.acls, sameACL) &&
+		slices.EqualFunc(current.rules, route.rules, sameSwitchingRule) &&
+		slices.EqualFunc(current.requestRules, route.requestRules, sameRequestRule) {
+		return nil
+	}
+
+	// 按索引倒序删除避免索引偏移
+	for _, i := range slices.Backward(requestRuleIndexes) {
+		if err := s.confClient.DeleteHTTPRequestRule(int64(i), "frontend", frontend, txID, 0); err != nil {
+			return fmt.Errorf("删除HTTP请求规则失败: %v", err)
+		}
+	}
+	for _, i := range slices.Backward(ruleIndexes) {
+		if err := s.confClient.DeleteBackendSwitchingRule(int64(i), frontend, txID, 0); err != nil {
+			return fmt.Errorf("删除后端切换规则失败: %v", err)
+		}
+	}
+	for _, i := range slices.Backward(aclIndexes) {
+		if err := s.confClient.DeleteACL(int64(i), "frontend", frontend, txID, 0); err != nil {
+			return fmt.Errorf("删除 ACL 失败: %v", err)
+		}
+	}
+
+	aclIndex := len(aclList) - len(aclIndexes)
+	for i, acl := range route.acls {
+		if err := s.confClient.CreateACL(int64(aclIndex+i), "frontend", frontend, acl, txID, 0); err != nil {
+			return fmt.Errorf("创建 ACL 失败: %v", err)
+		}
+	}
+	for i, rule := range route.rules {
+		if err := s.confClient.CreateBackendSwitchingRule(int64(i), frontend, rule, txID, 0); err != nil {
+			return fmt.Errorf("创建后端切换规则失败: %v", err)
+		}
+	}
+	for i, rule := range route.requestRules {
+		if err := s.confClient.CreateHTTPRequestRule(int64(i), "frontend", frontend, rule, txID, 0); err != nil {
+			return fmt.Errorf("创建HTTP请求规则失败: %v", err)
+		}
+	}
+
+	diff.configChanged = true
+	return nil
+}
+
+// createLocationBackend 创建路径路由的后端、服务器、路径改写和头部改写规则
+func (s *HAProxyServiceImpl) createLocationBackend(lb locationBackend, txID string) error {
+	if err := s.confClient.CreateBackend(lb.backend, txID, 0); err != nil {
+		return fmt.Errorf("创建后端失败: %v", err)
+	}
+	if len(lb.checks) > 0 {
+		if err := s.confClient.ReplaceHTTPChecks("backend", lb.backend.Name, lb.checks, txID, 0); err != nil {
+			return fmt.Errorf("创建健康检查规则失败: %v", err)
+		}
+	}
+	for _, server := range lb.servers {
+		if err := s.confClient.CreateServer("backend", lb.backend.Name, server, txID, 0); err != nil {
+			return fmt.Errorf("创建后端服务器失败: %v", err)
+		}
+	}
+	for i, rule := range lb.requestRules {
+		if err := s.confClient.CreateHTTPRequestRule(int64(i), "backend", lb.backend.Name, rule, txID, 0); err != nil {
+			return fmt.Errorf("创建HTTP请求规则失败: %v", err)
+		}
+	}
+	for i, rule := range lb.responseRules {
+		if err := s.confClient.CreateHTTPResponseRule(int64(i), "backend", lb.backend.Name, rule, txID, 0); err != nil {
+			return fmt.Errorf("创建HTTP响应规则失败: %v", err)
+		}
+	}
+	return nil
+}
+
+// sameLocationBackend 比较已有后端的设置、服务器、路径改写和头部改写规则与期望是否一致
+func (s *HAProxyServiceImpl) sameLocationBackend(lb locationBackend, txID string) (bool, error) {
+	_, backend, err := s.confClient.GetBackend(lb.backend.Name, txID)
+	if err != nil {
+		return false, fmt.Errorf("获取后端失败: %v", err)
+	}
+	_, checks, err := s.confClient.GetHTTPChecks("backend", lb.backend.Name, txID)
+	if err != nil {
+		return false, fmt.Errorf("获取健康检查规则失败: %v", err)
+	}
+	if !sameBackendOptions(&backend.BackendBase, &lb.backend.BackendBase) || !slices.EqualFunc(checks, lb.checks, sameHTTPCheck) {
+		return false, nil
+	}
+	_, servers, err := s.confClient.GetServers("backend", lb.backend.Name, txID)
+	if err != nil {
+		return false, fmt.Errorf("获取后端服务器失败: %v", err)
+	}
+	_, rules, err := s.confClient.GetHTTPRequestRules("backend", lb.backend.Name, txID)
+	if err != nil {
+		return false, fmt.Errorf("获取HTTP请求规则失败: %v", err)
+	}
+	_, responseRules, err := s.confClient.GetHTTPResponseRules("backend", lb.backend.Name, txID)
+	if err != nil {
+		return false, fmt.Errorf("获取HTTP响应规则失败: %v", err)
+	}
+	return slices.EqualFunc(servers, lb.servers, func(a, b *models.Server) bool {
+		return a.Name == b.Name && sameServer(a, b)
+	}) && slices.EqualFunc(rules, lb.requestRules, sameRequestRule) &&
+		slices.EqualFunc(responseRules, lb.responseRules, sameResponseHeaderRule), nil
+}
+
+// buildLocationRoute 生成站点路径路由的 ACL、后端切换规则和关闭 WAF 的规则
+//
+// 每条路由的 ACL 命名为 loc_<domain>:<index>_<kind>，切换条件总是包含站点的主机名 ACL。
+// 存在关闭 WAF 的路由时，按顺序把第一个命中的路由记录到 txn.location，再据此设置 txn.waf_off，
+// 避免排在前面的路由被后面关闭 WAF 的路由覆盖。
+func buildLocationRoute(site model.Site) locationRoute {
+	var route locationRoute
+	if len(site.Locations) == 0 {
+		return route
+	}
+
+	dash := getDashDomain(site.Domain)
+	hostACL := fmt.Sprintf("host_%s", dash)
+	disableWAF := slices.ContainsFunc(site.Locations, func(loc model.Location) bool { return loc.DisableWAF })
+
+	var wafOff []*models.HTTPRequestRule
+	for i, loc := range site.Locations {
+		name := fmt.Sprintf("%s%d", locationACLPrefix(dash), i)
+		conds := []string{hostACL}
+
+		pathACL := name + "_path"
+		switch loc.MatchType {
+		case model.LocationMatchExact:
+			route.acls = append(route.acls, &models.ACL{ACLName: pathACL, Criterion: "path", Value: quote(loc.Path)})
+			conds = append(conds, pathACL)
+		case model.LocationMatchRegex:
+			route.acls = append(route.acls, &models.ACL{ACLName: pathACL, Criterion: "path_reg", Value: quote(loc.Path)})
+			conds = append(conds, pathACL)
+		default:
+			// 前缀 /api 匹配 /api 本身和 /api/ 下的路径，不匹配 /apix；前缀为 / 时匹配所有路径
+			if prefix := strings.TrimSuffix(loc.Path, "/"); prefix != "" {
+				route.acls = append(route.acls,
+					&models.ACL{ACLName: pathACL, Criterion: "path", Value: quote(prefix)},
+					&models.ACL{ACLName: pathACL, Criterion: "path_beg", Value: quote(prefix + "/")},
+				)
+				conds = append(conds, pathACL)
+			}
+		}
+
+		if len(loc.Methods) > 0 {
+			methodACL := name + "_method"
+			route.acls = append(route.acls, &models.ACL{ACLName: methodACL, Criterion: "method", Value: strings.Join(loc.Methods, " ")})
+			conds = append(conds, methodACL)
+		}
+
+		for k, header := range loc.Headers {
+			headerACL := fmt.Sprintf("%s_hdr%d", name, k)
+			acl := &models.ACL{ACLName: headerACL, Criterion: fmt.Sprintf("req.hdr(%s) -m found", header.Name)}
+			if header.Value != "" {
+				acl.Criterion = fmt.Sprintf("req.hdr(%s) -m str", header.Name)
+				acl.Value = quote(header.Value)
+			}
+			route.acls = append(route.acls, acl)
+			conds = append(conds, headerACL)
+		}
+
+		condTest := strings.Join(conds, " ")
+		route.rules = append(route.rules, &models.BackendSwitchingRule{
+			Name:     locationBackendName(dash, i),
+			Cond:     "if",
+			CondTest: condTest,
+		})
+
+		if disableWAF {
+			route.requestRules = append(route.requestRules, &models.HTTPRequestRule{
+				Type:     "set-var",
+				VarScope: "txn",
+				VarName:  locationVar,
+				VarExpr:  fmt.Sprintf("str(%s)", name),
+				Cond:     "if",
+				CondTest: fmt.Sprintf("!{ var(txn.%s) -m found } %s", locationVar, condTest),
+			})
+		}
+		if loc.DisableWAF {
+			wafOff = append(wafOff, &models.HTTPRequestRule{
+				Type:     "set-var",
+				VarScope: "txn",
+				VarName:  wafOffVar,
+				VarExpr:  "bool(true)",
+				Cond:     "if",
+				CondTest: fmt.Sprintf("{ var(txn.%s) -m str %s }", locationVar, name),
+			})
+		}
+	}
+	route.requestRules = append(route.requestRules, wafOff...)
+
+	return route
+}
+
+// buildLocationBackend 生成第 index 条路径路由的后端
+func buildLocationBackend(site model.Site, index int) locationBackend {
+	dash := getDashDomain(site.Domain)
+	loc := site.Locations[index]
+	name := locationBackendName(dash, index)
+
+	lb := locationBackend{
+		backend: &models.Backend{
+			BackendBase: models.BackendBase{
+				Name:    name,
+				Mode:    "http",
+				Enabled: true,
+				From:    "http",
+				Forwardfor: &models.Forwardfor{
+					Enabled: StringP("enabled"),
+				},
+			},
+		},
+	}
+	applyBackendOptions(&lb.backend.BackendBase, loc.Backend)
+	lb.checks = backendHTTPChecks(loc.Backend)
+	for i, server := range loc.Backend.Servers {
+		srv := newBackendServer(fmt.Sprintf("%s:loc%d_%d", dash, index, i), server, loc.Backend)
+		applyProxyProtocol(srv, site)
+		lb.servers = append(lb.servers, srv)
+	}
+	if rule := locationRewriteRule(loc); rule != nil {
+		lb.requestRules = append(lb.requestRules, rule)
+	}
+	// 站点的头部改写同样作用于路径路由的后端
+	requestRules, responseRules := siteHeaderRules(site)
+	lb.requestRules = append(lb.requestRules, requestRules...)
+	lb.responseRules = responseRules
+	return lb
+}
+
+// locationRewriteRule 生成转发前的路径改写规则，不需要改写时返回 nil
+func locationRewriteRule(loc model.Location) *models.HTTPRequestRule {
+	if !loc.StripPrefix && loc.RewritePath == "" {
+		return nil
+	}
+
+	switch loc.MatchType {
+	case model.LocationMatchExact:
+		return &models.HTTPRequestRule{Type: "set-path", PathFmt: quote(escapeLogFormat(loc.RewritePath))}
+	case model.LocationMatchRegex:
+		return &models.HTTPRequestRule{Type: "replace-path", PathMatch: quote(loc.Path), PathFmt: quote(escapeLogFormat(loc.RewritePath))}
+	}
+
+	// 前缀匹配：去掉前缀等同于把前缀改写为 /
+	prefix := regexp.QuoteMeta(strings.TrimSuffix(loc.Path, "/"))
+	target := strings.TrimSuffix(loc.RewritePath, "/")
+	if loc.StripPrefix || target == "" {
+		return &models.HTTPRequestRule{Type: "replace-path", PathMatch: quote("^" + prefix + "/?(.*)$"), PathFmt: quote(`/\1`)}
+	}
+	return &models.HTTPRequestRule{Type: "replace-path", PathMatch: quote("^" + prefix + "(/.*)?$"), PathFmt: quote(escapeLogFormat(target) + `\1`)}
+}
+
+// 路径路由的 ACL 和后端以冒号分隔域名和序号，域名中不会出现冒号，避免 a.com 与 a.com.cn 的前缀互相匹配
+func locationACLPrefix(dash string) string {
+	return fmt.Sprintf("loc_%s:", dash)
+}
+
+func locationBackendPrefix(dash string) string {
+	return fmt.Sprintf("be_%s:loc", dash)
+}
+
+func locationBackendName(dash string, index int) string {
+	return fmt.Sprintf("%s%d", locationBackendPrefix(dash), index)
+}
+
+// quote 用单引号包裹配置参数，HAProxy 不处理单引号内的转义和注释符
+func quote(value string) string {
+	return "'" + value + "'"
+}
+
+// escapeLogFormat 转义 log-format 中的 %
+func escapeLogFormat(value string) string {
+	return strings.ReplaceAll(value, "%", "%%")
+}
+
+// sameACL 比较 ACL，解析配置文件时条件和参数的拆分方式可能与写入时不同
+func sameACL(a, b *models.ACL) bool {
+	return a.ACLName == b.ACLName &&
+		slices.Equal(strings.Fields(a.Criterion+" "+a.Value), strings.Fields(b.Criterion+" "+b.Value))
+}
+
+func sameSwitchingRule(a, b *models.BackendSwitchingRule) bool {
+	return a.Name == b.Name && a.Cond == b.Cond && a.CondTest == b.CondTest
+}
+
+func sameRequestRule(a, b *models.HTTPRequestRule) bool {
+	return a.Type == b.Type &&
+		a.VarScope == b.VarScope && a.VarName == b.VarName && a.VarExpr == b.VarExpr &&
+		a.PathMatch == b.PathMatch && a.PathFmt == b.PathFmt &&
+		strings.EqualFold(a.HdrName, b.HdrName) && a.HdrFormat == b.HdrFormat &&
+		a.Cond == b.Cond && a.CondTest == b.CondTest
+}
@@ -0,0 +1,133 @@
+package haproxy
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+
+	"github.com/HUAHUAI23/simple-waf/server/model"
+	"github.com/haproxytech/client-native/v6/models"
+)
+
+func aclString(acl *models.ACL) string {
+	return fmt.Sprintf("%s %s %s", acl.ACLName, acl.Criterion, acl.Value)
+}
+
+func switchingRuleString(rule *models.BackendSwitchingRule) string {
+	return fmt.Sprintf("%s %s %s", rule.Name, rule.Cond, rule.CondTest)
+}
+
+func requestRuleString(rule *models.HTTPRequestRule) string {
+	s := rule.Type
+	for _, field := range []string{rule.VarScope, rule.VarName, rule.VarExpr, rule.PathMatch, rule.PathFmt, rule.HdrName, rule.HdrFormat} {
+		if field != "" {
+			s += " " + field
+		}
+	}
+	if rule.Cond != "" {
+		s += " " + rule.Cond + " " + rule.CondTest
+	}
+	return s
+}
+
+func mapStrings[T any](items []T, fn func(T) string) []string {
+	out := make([]string, 0, len(items))
+	for _, item := range items {
+		out = append(out, fn(item))
+	}
+	return out
+}
+
+func TestBuildLocationRoute(t *testing.T) {
+	site := model.Site{
+		Domain: "a.com",
+		Locations: []model.Location{
+			{Path: "/api/", MatchType: model.LocationMatchPrefix, Methods: []string{"GET", "POST"}},
+			{Path: "/health", MatchType: model.LocationMatchExact, DisableWAF: true},
+			{Path: `^/static/.*\.css$`, MatchType: model.LocationMatchRegex,
+				Headers: []model.HeaderMatch{{Name: "X-Canary"}, {Name: "X-Env", Value: "beta"}}},
+			{Path: "/", MatchType: model.LocationMatchPrefix},
+		},
+	}
+	route := buildLocationRoute(site)
+
+	wantACLs := []string{
+		"loc_a_com:0_path path '/api'",
+		"loc_a_com:0_path path_beg '/api/'",
+		"loc_a_com:0_method method GET POST",
+		"loc_a_com:1_path path '/health'",
+		`loc_a_com:2_path path_reg '^/static/.*\.css$'`,
+		"loc_a_com:2_hdr0 req.hdr(X-Canary) -m found ",
+		"loc_a_com:2_hdr1 req.hdr(X-Env) -m str 'beta'",
+	}
+	if got := mapStrings(route.acls, aclString); !slices.Equal(got, wantACLs) {
+		t.Errorf("acls =\n%q\nwant\n%q", got, wantACLs)
+	}
+
+	// 前缀为 / 的路由只按主机名匹配
+	wantRules := []string{
+		"be_a_com:loc0 if host_a_com loc_a_com:0_path loc_a_com:0_method",
+		"be_a_com:loc1 if host_a_com loc_a_com:1_path",
+		"be_a_com:loc2 if host_a_com loc_a_com:2_path loc_a_com:2_hdr0 loc_a_com:2_hdr1",
+		"be_a_com:loc3 if host_a_com",
+	}
+	if got := mapStrings(route.rules, switchingRuleString); !slices.Equal(got, wantRules) {
+		t.Errorf("rules =\n%q\nwant\n%q", got, wantRules)
+	}
+
+	// 先按顺序记录第一个命中的路由，再据此关闭 WAF
+	wantRequestRules := []string{
+		"set-var txn location str(loc_a_com:0) if !{ var(txn.location) -m found } host_a_com loc_a_com:0_path loc_a_com:0_method",
+		"set-var txn location str(loc_a_com:1) if !{ var(txn.location) -m found } host_a_com loc_a_com:1_path",
+		"set-var txn location str(loc_a_com:2) if !{ var(txn.location) -m found } host_a_com loc_a_com:2_path loc_a_com:2_hdr0 loc_a_com:2_hdr1",
+		"set-var txn location str(loc_a_com:3) if !{ var(txn.location) -m found } host_a_com",
+		"set-var txn waf_off bool(true) if { var(txn.location) -m str loc_a_com:1 }",
+	}
+	if got := mapStrings(route.requestRules, requestRuleString); !slices.Equal(got, wantRequestRules) {
+		t.Errorf("requestRules =\n%q\nwant\n%q", got, wantRequestRules)
+	}
+}
+
+func TestBuildLocationRouteWithoutDisableWAF(t *testing.T) {
+	site := model.Site{
+		Domain:    "a.com",
+		Locations: []model.Location{{Path: "/api", MatchType: model.LocationMatchPrefix}},
+	}
+	route := buildLocationRoute(site)
+	if len(route.rules) != 1 || len(route.requestRules) != 0 {
+		t.Errorf("route = %d rules, %d request rules, want 1 and 0", len(route.rules), len(route.requestRules))
+	}
+	if route := buildLocationRoute(model.Site{Domain: "a.com"}); len(route.acls) != 0 || len(route.rules) != 0 {
+		t.Errorf("site without locations produced %d acls", len(route.acls))
+	}
+}
+
+func TestLocationRewriteRule(t *testing.T) {
+	tests := []struct {
+		name string
+		loc  model.Location
+		want string
+	}{
+		{"no rewrite", model.Location{Path: "/api", MatchType: model.LocationMatchPrefix}, ""},
+		{"strip prefix", model.Location{Path: "/api/", MatchType: model.LocationMatchPrefix, StripPrefix: true},
+			`replace-path '^/api/?(.*)$' '/\1'`},
+		{"rewrite prefix", model.Location{Path: "/v1.0", MatchType: model.LocationMatchPrefix, RewritePath: "/v2/"},
+			`replace-path '^/v1\.0(/.*)?$' '/v2\1'`},
+		{"rewrite prefix to root", model.Location{Path: "/api", MatchType: model.LocationMatchPrefix, RewritePath: "/"},
+			`replace-path '^/api/?(.*)$' '/\1'`},
+		{"rewrite exact", model.Location{Path: "/old", MatchType: model.LocationMatchExact, RewritePath: "/new%20page"},
+			"set-path '/new%%20page'"},
+		{"rewrite regex", model.Location{Path: `^/u/(\d+)$`, MatchType: model.LocationMatchRegex, RewritePath: `/users/\1`},
+			`replace-path '^/u/(\d+)$' '/users/\1'`},
+	}
+	for _, tt := range tests {
+		rule := locationRewriteRule(tt.loc)
+		got := ""
+		if rule != nil {
+			got = requestRuleString(rule)
+		}
+		if got != tt.want {
+			t.Errorf("%s: rule = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
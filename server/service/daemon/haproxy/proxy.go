@@ -0,0 +1,89 @@
+package haproxy
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/HUAHUAI23/simple-waf/server/model"
+	"github.com/haproxytech/client-native/v6/models"
+)
+
+// expectProxyRule 只对可信来源的连接解析 PROXY 协议头，trusted 为空时返回 nil
+//
+// 连接阶段的规则在解析 PROXY 协议头之前执行，src 仍是负载均衡的地址；
+// 内容阶段的规则在解析之后执行，src 为协议头中的客户端地址，并通过内部 PROXY 协议传给 HTTP 前端和 SPOE。
+// client-native 会把 tcp-request session reject 读成 accept，因此按客户端地址过滤的规则放在内容阶段。
+func expectProxyRule(trusted []string) *models.TCPRequestRule {
+	if len(trusted) == 0 {
+		return nil
+	}
+	return &models.TCPRequestRule{
+		Type:     "connection",
+		Action:   "expect-proxy",
+		Cond:     "if",
+		CondTest: fmt.Sprintf("{ src %s }", strings.Join(trusted, " ")),
+	}
+}
+
+// ensureAcceptProxy 使端口组合前端按站点设置接受可信来源的 PROXY 协议头
+//
+// 接受 PROXY 协议时在内容阶段按客户端地址再检查一次封禁列表，连接阶段的检查只能看到负载均衡的地址；
+// 规则排在最前面，先于按协议接受连接的内容规则执行。
+func (s *HAProxyServiceImpl) ensureAcceptProxy(site model.Site, txID string, diff *siteDiff) error {
+	feName := fmt.Sprintf("fe_%d_combined", site.ListenPort)
+	blocked := fmt.Sprintf("{ src,map_ip(%s) -m found }", s.BlocklistFile)
+
+	var want []*models.TCPRequestRule
+	if rule := expectProxyRule(site.TrustedProxies()); rule != nil {
+		want = append(want, rule, &models.TCPRequestRule{
+			Type:     "content",
+			Action:   "reject",
+			Cond:     "if",
+			CondTest: blocked,
+		})
+	}
+
+	_, rules, err := s.confClient.GetTCPRequestRules("frontend", feName, txID)
+	if err != nil {
+		return fmt.Errorf("获取TCP请求规则失败: %v", err)
+	}
+	var current []*models.TCPRequestRule
+	var indexes []int
+	for i, rule := range rules {
+		if isExpectProxyRule(rule) || (rule.Type == "content" && rule.Action == "reject" && rule.CondTest == blocked) {
+			current = append(current, rule)
+			indexes = append(indexes, i)
+		}
+	}
+	if slices.EqualFunc(current, want, sameTCPRequestRule) {
+		return nil
+	}
+
+	for _, i := range slices.Backward(indexes) {
+		if err := s.confClient.DeleteTCPRequestRule(int64(i), "frontend", feName, txID, 0); err != nil {
+			return fmt.Errorf("删除TCP请求规则失败: %v", err)
+		}
+	}
+	for i, rule := range want {
+		if err := s.confClient.CreateTCPRequestRule(int64(i), "frontend", feName, rule, txID, 0); err != nil {
+			return fmt.Errorf("创建TCP请求规则失败: %v", err)
+		}
+	}
+	diff.configChanged = true
+	return nil
+}
+
+// applyProxyProtocol 按站点设置向后端服务器发送 PROXY 协议头
+func applyProxyProtocol(srv *models.Server, site model.Site) {
+	switch site.SendProxyVersion() {
+	case model.ProxyProtocolV1:
+		srv.SendProxy = "enabled"
+	case model.ProxyProtocolV2:
+		srv.SendProxyV2 = "enabled"
+	}
+}
+
+func isExpectProxyRule(rule *models.TCPRequestRule) bool {
+	return rule.Type == "connection" && rule.Action == "expect-proxy"
+}
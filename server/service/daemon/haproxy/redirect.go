@@ -0,0 +1,212 @@
+package haproxy
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/HUAHUAI23/simple-waf/server/model"
+	"github.com/haproxytech/client-native/v6/models"
+)
+
+// redirectVar 记录命中的跳转规则，同一条规则的路径替换和跳转据此生效
+const redirectVar = "redirect"
+
+// redirectRoute 前端中站点的跳转和改写配置
+type redirectRoute struct {
+	acls         []*models.ACL
+	requestRules []*models.HTTPRequestRule
+}
+
+// ensureRedirects 将站点的跳转和改写规则同步到端口的 HTTP 和 HTTPS 前端，任何差异都需要重载
+func (s *HAProxyServiceImpl) ensureRedirects(site model.Site, txID string, diff *siteDiff) error {
+	if err := s.ensureRedirectRoute(fmt.Sprintf("fe_%d_http", site.ListenPort), site, buildRedirectRoute(site, false), txID, diff); err != nil {
+		return err
+	}
+	httpsRoute := redirectRoute{}
+	if site.EnableHTTPS {
+		httpsRoute = buildRedirectRoute(site, true)
+	}
+	return s.ensureRedirectRoute(fmt.Sprintf("fe_%d_https", site.ListenPort), site, httpsRoute, txID, diff)
+}
+
+// removeRedirects 删除站点的全部跳转和改写配置
+func (s *HAProxyServiceImpl) removeRedirects(site model.Site, txID string, diff *siteDiff) error {
+	site.Redirects = nil
+	site.Rewrites = nil
+	return s.ensureRedirects(site, txID, diff)
+}
+
+// ensureRedirectRoute 比较前端中站点的跳转和改写配置，不一致时整体删除后按顺序追加到末尾
+// 规则排在 WAF 检测之后、后端切换之前，路径路由按改写后的路径匹配
+func (s *HAProxyServiceImpl) ensureRedirectRoute(frontend string, site model.Site, route redirectRoute, txID string, diff *siteDiff) error {
+	if _, _, err := s.confClient.GetFrontend(frontend, txID); err != nil {
+		return nil
+	}
+	dash := getDashDomain(site.Domain)
+	prefixes := []string{redirectACLPrefix(dash), rewriteACLPrefix(dash)}
+	// 按完整的名称比较，避免 a.com 与 b.redir.a.com 之类的域名互相包含
+	owned := func(name string) bool {
+		return slices.ContainsFunc(prefixes, func(prefix string) bool { return strings.HasPrefix(name, prefix) })
+	}
+
+	_, aclList, err := s.confClient.GetACLs("frontend", frontend, txID)
+	if err != nil {
+		return fmt.Errorf("获取 ACL 失败: %v", err)
+	}
+	_, requestRules, err := s.confClient.GetHTTPRequestRules("frontend", frontend, txID)
+	if err != nil {
+		return fmt.Errorf("获取HTTP请求规则失败: %v", err)
+	}
+
+	var current redirectRoute
+	var aclIndexes, requestRuleIndexes []int
+	for i, acl := range aclList {
+		if owned(acl.ACLName) {
+			current.acls = append(current.acls, acl)
+			aclIndexes = append(aclIndexes, i)
+		}
+	}
+	for i, rule := range requestRules {
+		// 记录命中规则的 set-var 条件可能只有站点的主机名 ACL，按写入的值识别
+		if slices.ContainsFunc(strings.Fields(rule.CondTest), owned) ||
+			(rule.Type == "set-var" && rule.VarName == redirectVar && owned(strings.TrimPrefix(rule.VarExpr, "str("))) {
+			current.requestRules = append(current.requestRules, rule)
+			requestRuleIndexes = append(requestRuleIndexes, i)
+		}
+	}
+
+	if slices.EqualFunc(current.acls, route.acls, sameACL) &&
+		slices.EqualFunc(current.requestRules, route.requestRules, sameRedirectRule) {
+		return nil
+	}
+
+	// 按索引倒序删除避免索引偏移
+	for _, i := range slices.Backward(requestRuleIndexes) {
+		if err := s.confClient.DeleteHTTPRequestRule(int64(i), "frontend", frontend, txID, 0); err != nil {
+			return fmt.Errorf("删除HTTP请求规则失败: %v", err)
+		}
+	}
+	for _, i := range slices.Backward(aclIndexes) {
+		if err := s.confClient.DeleteACL(int64(i), "frontend", frontend, txID, 0); err != nil {
+			return fmt.Errorf("删除 ACL 失败: %v", err)
+		}
+	}
+
+	aclIndex := len(aclList) - len(aclIndexes)
+	for i, acl := range route.acls {
+		if err := s.confClient.CreateACL(int64(aclIndex+i), "frontend", frontend, acl, txID, 0); err != nil {
+			return fmt.Errorf("创建 ACL 失败: %v", err)
+		}
+	}
+	ruleIndex := len(requestRules) - len(requestRuleIndexes)
+	for i, rule := range route.requestRules {
+		if err := s.confClient.CreateHTTPRequestRule(int64(ruleIndex+i), "frontend", frontend, rule, txID, 0); err != nil {
+			return fmt.Errorf("创建HTTP请求规则失败: %v", err)
+		}
+	}
+
+	diff.configChanged = true
+	return nil
+}
+
+// buildRedirectRoute 生成站点的跳转和改写规则，https 为 true 时生成 HTTPS 前端的规则
+//
+// 跳转规则命名为 redir_<domain>:<index>，命中时先把规则名记录到 txn.redirect，
+// location 跳转再用 replace-path 按正则替换路径，最后以替换后的路径和原查询串跳转。
+// 跳转会结束请求处理，因此同一请求只会命中一条跳转规则。
+// 改写规则命名为 rewrite_<domain>:<index>，依次作用于站点的请求。
+func buildRedirectRoute(site model.Site, https bool) redirectRoute {
+	var route redirectRoute
+	dash := getDashDomain(site.Domain)
+	hostACL := fmt.Sprintf("host_%s", dash)
+
+	for i, rule := range site.Redirects {
+		// HTTPS 前端中的请求已经是 HTTPS
+		if https && rule.Type == model.RedirectHTTPS {
+			continue
+		}
+		name := fmt.Sprintf("%s%d", redirectACLPrefix(dash), i)
+		conds := []string{hostACL}
+		if len(rule.Hosts) > 0 {
+			route.acls = append(route.acls, hostACLs(name+"_host", hostCriterion, rule.Hosts)...)
+			conds[0] = name + "_host"
+		}
+		if rule.Path != "" {
+			route.acls = append(route.acls, &models.ACL{ACLName: name + "_path", Criterion: "path_reg", Value: quote(rule.Path)})
+			conds = append(conds, name+"_path")
+		}
+
+		matched := fmt.Sprintf("{ var(txn.%s) -m str %s }", redirectVar, name)
+		route.requestRules = append(route.requestRules, &models.HTTPRequestRule{
+			Type:     "set-var",
+			VarScope: "txn",
+			VarName:  redirectVar,
+			VarExpr:  fmt.Sprintf("str(%s)", name),
+			Cond:     "if",
+			CondTest: strings.Join(conds, " "),
+		})
+
+		redirect := &models.HTTPRequestRule{
+			Type:      "redirect",
+			RedirCode: Int64P(int64(rule.Code)),
+			Cond:      "if",
+			CondTest:  matched,
+		}
+		switch rule.Type {
+		case model.RedirectHTTPS:
+			redirect.RedirType = "scheme"
+			redirect.RedirValue = "https"
+		case model.RedirectPrefix:
+			redirect.RedirType = "prefix"
+			redirect.RedirValue = quote(escapeLogFormat(rule.Target))
+		case model.RedirectLocation:
+			origin, path := rule.SplitTarget()
+			route.requestRules = append(route.requestRules, &models.HTTPRequestRule{
+				Type:      "replace-path",
+				PathMatch: quote(rule.Path),
+				PathFmt:   quote(escapeLogFormat(path)),
+				Cond:      "if",
+				CondTest:  matched,
+			})
+			redirect.RedirType = "location"
+			redirect.RedirValue = quote(escapeLogFormat(origin) + "%[pathq]")
+		}
+		route.requestRules = append(route.requestRules, redirect)
+	}
+
+	for i, rule := range site.Rewrites {
+		name := fmt.Sprintf("%s%d", rewriteACLPrefix(dash), i)
+		acl := &models.ACL{ACLName: name + "_path", Criterion: "path_reg", Value: quote(rule.Path)}
+		typ := "replace-path"
+		if rule.Query {
+			acl.Criterion = "pathq -m reg"
+			typ = "replace-pathq"
+		}
+		route.acls = append(route.acls, acl)
+		route.requestRules = append(route.requestRules, &models.HTTPRequestRule{
+			Type:      typ,
+			PathMatch: quote(rule.Path),
+			PathFmt:   quote(escapeLogFormat(rule.Target)),
+			Cond:      "if",
+			CondTest:  fmt.Sprintf("%s %s", hostACL, acl.ACLName),
+		})
+	}
+	return route
+}
+
+// 跳转和改写规则的 ACL 以冒号分隔域名和序号，与路径路由相同
+func redirectACLPrefix(dash string) string {
+	return fmt.Sprintf("redir_%s:", dash)
+}
+
+func rewriteACLPrefix(dash string) string {
+	return fmt.Sprintf("rewrite_%s:", dash)
+}
+
+// sameRedirectRule 在 sameRequestRule 的基础上比较跳转的方式、目标和状态码
+func sameRedirectRule(a, b *models.HTTPRequestRule) bool {
+	return sameRequestRule(a, b) &&
+		a.RedirType == b.RedirType && a.RedirValue == b.RedirValue &&
+		(a.RedirCode == nil) == (b.RedirCode == nil) && (a.RedirCode == nil || *a.RedirCode == *b.RedirCode)
+}
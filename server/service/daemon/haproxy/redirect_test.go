@@ -0,0 +1,83 @@
+package haproxy
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+
+	"github.com/HUAHUAI23/simple-waf/server/model"
+	"github.com/haproxytech/client-native/v6/models"
+)
+
+func redirectRuleString(rule *models.HTTPRequestRule) string {
+	if rule.Type != "redirect" {
+		return requestRuleString(rule)
+	}
+	return fmt.Sprintf("redirect %s %s code %d %s %s", rule.RedirType, rule.RedirValue, GetSafeInt64(rule.RedirCode), rule.Cond, rule.CondTest)
+}
+
+func TestBuildRedirectRoute(t *testing.T) {
+	site := model.Site{
+		Domain:  "a.com",
+		Aliases: []string{"www.a.com", "*.b.com"},
+		Redirects: []model.RedirectRule{
+			{Type: model.RedirectHTTPS, Code: 301},
+			{Type: model.RedirectPrefix, Hosts: []string{"www.a.com", "*.b.com"}, Target: "https://a.com", Code: 308},
+			{Type: model.RedirectLocation, Path: `^/blog/(\d+)$`, Target: `https://blog.a.com/posts/\1/100%`, Code: 302},
+		},
+		Rewrites: []model.RewriteRule{
+			{Path: `^/v1/(.*)$`, Target: `/api/v1/\1`},
+			{Path: `^/search\?q=(.*)$`, Target: `/find?term=\1`, Query: true},
+		},
+	}
+
+	route := buildRedirectRoute(site, false)
+	wantACLs := []string{
+		"redir_a_com:1_host req.hdr(host),field(1,:) -i www.a.com",
+		"redir_a_com:1_host req.hdr(host),field(1,:) -i -m end .b.com",
+		`redir_a_com:2_path path_reg '^/blog/(\d+)$'`,
+		`rewrite_a_com:0_path path_reg '^/v1/(.*)$'`,
+		`rewrite_a_com:1_path pathq -m reg '^/search\?q=(.*)$'`,
+	}
+	if got := mapStrings(route.acls, aclString); !slices.Equal(got, wantACLs) {
+		t.Errorf("acls =\n%q\nwant\n%q", got, wantACLs)
+	}
+
+	// 每条跳转先记录规则名，location 跳转替换路径后以替换后的路径和原查询串跳转
+	wantRules := []string{
+		"set-var txn redirect str(redir_a_com:0) if host_a_com",
+		"redirect scheme https code 301 if { var(txn.redirect) -m str redir_a_com:0 }",
+		"set-var txn redirect str(redir_a_com:1) if redir_a_com:1_host",
+		"redirect prefix 'https://a.com' code 308 if { var(txn.redirect) -m str redir_a_com:1 }",
+		"set-var txn redirect str(redir_a_com:2) if host_a_com redir_a_com:2_path",
+		`replace-path '^/blog/(\d+)$' '/posts/\1/100%%' if { var(txn.redirect) -m str redir_a_com:2 }`,
+		"redirect location 'https://blog.a.com%[pathq]' code 302 if { var(txn.redirect) -m str redir_a_com:2 }",
+		`replace-path '^/v1/(.*)$' '/api/v1/\1' if host_a_com rewrite_a_com:0_path`,
+		`replace-pathq '^/search\?q=(.*)$' '/find?term=\1' if host_a_com rewrite_a_com:1_path`,
+	}
+	if got := mapStrings(route.requestRules, redirectRuleString); !slices.Equal(got, wantRules) {
+		t.Errorf("requestRules =\n%q\nwant\n%q", got, wantRules)
+	}
+
+	// HTTPS 前端不需要跳转到 HTTPS
+	route = buildRedirectRoute(site, true)
+	if got := mapStrings(route.requestRules, redirectRuleString); !slices.Equal(got, wantRules[2:]) {
+		t.Errorf("https requestRules =\n%q\nwant\n%q", got, wantRules[2:])
+	}
+}
+
+func TestBuildRedirectRouteRelativeLocation(t *testing.T) {
+	site := model.Site{
+		Domain:    "a.com",
+		Redirects: []model.RedirectRule{{Type: model.RedirectLocation, Path: "^/old$", Target: "/new", Code: 301}},
+	}
+	route := buildRedirectRoute(site, true)
+	want := []string{
+		"set-var txn redirect str(redir_a_com:0) if host_a_com redir_a_com:0_path",
+		"replace-path '^/old$' '/new' if { var(txn.redirect) -m str redir_a_com:0 }",
+		"redirect location '%[pathq]' code 301 if { var(txn.redirect) -m str redir_a_com:0 }",
+	}
+	if got := mapStrings(route.requestRules, redirectRuleString); !slices.Equal(got, want) {
+		t.Errorf("requestRules =\n%q\nwant\n%q", got, want)
+	}
+}
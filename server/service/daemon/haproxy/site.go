@@ -0,0 +1,703 @@
+package haproxy
+
+import (
+	"bytes"
+	"cmp"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/HUAHUAI23/simple-waf/server/model"
+	"github.com/haproxytech/client-native/v6/models"
+)
+
+// opOrder 运行时服务器变更的执行顺序
+var opOrder = map[string]int{"add": 0, "edit": 1, "delete": 2}
+
+// serverChange 一次后端服务器变更，提交配置后尝试通过运行时 API 生效
+type serverChange struct {
+	op      string // add/edit/delete
+	backend string
+	server  *models.Server
+	old     *models.Server
+}
+
+// siteDiff 记录一次站点同步中的配置变更
+type siteDiff struct {
+	configChanged bool           // 除服务器列表外的配置发生变化，需要重载
+	servers       []serverChange // 已有后端中的服务器变更
+}
+
+func (d *siteDiff) changed() bool {
+	return d.configChanged || len(d.servers) > 0
+}
+
+// UpdateSiteConfig 将站点的期望配置与当前 HAProxy 配置比较，只修改有差异的前端 ACL、后端、服务器和证书
+//
+// 所有修改在同一个事务中提交。只有服务器列表变化时通过运行时 API 增删改服务器，不需要重载；
+// 返回值表示是否需要重载 HAProxy 才能生效。站点未激活时等同于 RemoveSiteConfig。
+func (s *HAProxyServiceImpl) UpdateSiteConfig(site model.Site) (bool, error) {
+	if err := model.ValidateSite(&site); err != nil {
+		return false, fmt.Errorf("site config invalid: %v", err)
+	}
+	if !site.ActiveStatus {
+		return s.RemoveSiteConfig(site)
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.logger.Info().Msgf("同步站点配置 %s", site.Domain)
+
+	if err := s.ensureConfClient(); err != nil {
+		return false, err
+	}
+
+	// 新端口需要先创建端口级前端和后端，新监听只能通过重载生效；TCP 站点的前端在同步时创建
+	// 端口级配置与站点配置在同一个事务中提交，失败时不会留下没有站点的前端
+	newPort := false
+	reconcile := s.reconcileSite
+	if site.IsTCP() {
+		reconcile = s.reconcileTCPSite
+	} else if _, err := s.getFeCombined(site.ListenPort); err != nil {
+		newPort = true
+	}
+
+	diff, err := s.inTransaction(func(txID string) (*siteDiff, error) {
+		if newPort {
+			if err := s.addFeCombined(site.ListenPort, site.EnableHTTPS, txID); err != nil {
+				return nil, fmt.Errorf("创建前端组合失败: %v", err)
+			}
+		}
+		diff, err := reconcile(site, txID)
+		if err != nil {
+			return nil, err
+		}
+		diff.configChanged = diff.configChanged || newPort
+		return diff, nil
+	})
+	if err != nil {
+		return false, err
+	}
+
+	if diff.configChanged {
+		return true, nil
+	}
+	if len(diff.servers) == 0 {
+		return false, nil
+	}
+	if err := s.applyServerChanges(diff.servers); err != nil {
+		s.logger.Warn().Err(err).Str("domain", site.Domain).Msg("运行时更新后端服务器失败，需要重载")
+		return true, nil
+	}
+	s.logger.Info().Str("domain", site.Domain).Int("changes", len(diff.servers)).Msg("后端服务器已通过运行时 API 更新")
+	return false, nil
+}
+
+// RemoveSiteConfig 删除站点的 ACL、后端切换规则、后端、服务器和证书，端口上没有其他站点时一并删除端口级前端
+//
+// 返回值表示是否需要重载 HAProxy 才能生效，站点配置不存在时返回 false。
+func (s *HAProxyServiceImpl) RemoveSiteConfig(site model.Site) (bool, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if err := s.ensureConfClient(); err != nil {
+		return false, err
+	}
+	remove := s.removeSite
+	if site.IsTCP() {
+		remove = s.removeTCPSite
+		if _, _, err := s.confClient.GetFrontend(tcpFrontendName(site.ListenPort), ""); err != nil {
+			return false, nil
+		}
+	} else if _, err := s.getFeCombined(site.ListenPort); err != nil {
+		return false, nil
+	}
+
+	s.logger.Info().Msgf("删除站点配置 %s", site.Domain)
+
+	diff, err := s.inTransaction(func(txID string) (*siteDiff, error) {
+		return remove(site, txID)
+	})
+	if err != nil {
+		return false, err
+	}
+	return diff.changed(), nil
+}
+
+// inTransaction 在一个配置事务中执行 fn，没有变更时丢弃事务
+func (s *HAProxyServiceImpl) inTransaction(fn func(txID string) (*siteDiff, error)) (*siteDiff, error) {
+	version, err := s.confClient.GetVersion("")
+	if err != nil {
+		return nil, fmt.Errorf("获取版本失败: %v", err)
+	}
+	transaction, err := s.confClient.StartTransaction(version)
+	if err != nil {
+		return nil, fmt.Errorf("启动事务失败: %v", err)
+	}
+
+	diff, err := fn(transaction.ID)
+	if err != nil || !diff.changed() {
+		s.confClient.DeleteTransaction(transaction.ID)
+		return diff, err
+	}
+
+	if _, err := s.confClient.CommitTransaction(transaction.ID); err != nil {
+		s.confClient.DeleteTransaction(transaction.ID)
+		return nil, fmt.Errorf("提交事务失败: %v", err)
+	}
+	s.confClient.DeleteTransaction(transaction.ID)
+	return diff, nil
+}
+
+// reconcileSite 在事务中把站点配置调整为期望状态，生成的配置与 AddSiteConfig 一致
+func (s *HAProxyServiceImpl) reconcileSite(site model.Site, txID string) (*siteDiff, error) {
+	diff := &siteDiff{}
+	dash := getDashDomain(site.Domain)
+	aclName := fmt.Sprintf("host_%s", dash)
+	feHTTP := fmt.Sprintf("fe_%d_http", site.ListenPort)
+	feHTTPS := fmt.Sprintf("fe_%d_https", site.ListenPort)
+	backendName, _ := siteBackend(site)
+
+	if err := s.ensureListenBinds(fmt.Sprintf("fe_%d_combined", site.ListenPort), fmt.Sprintf("combined_%d", site.ListenPort), site, txID, diff); err != nil {
+		return nil, err
+	}
+	if err := s.ensureAcceptProxy(site, txID, diff); err != nil {
+		return nil, err
+	}
+
+	if !isIPAddress(site.Domain) {
+		if err := s.ensureHostRoute(feHTTP, aclName, site.Hosts(), backendName, txID, diff); err != nil {
+			return nil, err
+		}
+
+		if _, _, err := s.confClient.GetBackend(backendName, txID); err != nil {
+			backend := &models.Backend{
+				BackendBase: models.BackendBase{
+					Name:    backendName,
+					Mode:    "http",
+					Enabled: true,
+					From:    "http",
+					Forwardfor: &models.Forwardfor{
+						Enabled: StringP("enabled"),
+					},
+				},
+			}
+			if err := s.confClient.CreateBackend(backend, txID, 0); err != nil {
+				return nil, fmt.Errorf("创建后端失败: %v", err)
+			}
+			// 新后端在运行时中不存在，服务器只能随重载生效
+			diff.configChanged = true
+		}
+	}
+
+	// 后端设置变化需要重载，先于服务器比较，使服务器变更随重载一起生效
+	if err := s.ensureBackendOptions(backendName, site.Backend, txID, diff); err != nil {
+		return nil, err
+	}
+
+	if err := s.reconcileServers(site, txID, diff); err != nil {
+		return nil, err
+	}
+
+	if !isIPAddress(site.Domain) {
+		if err := s.ensureBackendHeaders(backendName, site, txID, diff); err != nil {
+			return nil, err
+		}
+		if err := s.ensureLocations(site, txID, diff); err != nil {
+			return nil, err
+		}
+		if err := s.ensureRedirects(site, txID, diff); err != nil {
+			return nil, err
+		}
+	}
+
+	if site.EnableHTTPS {
+		if err := s.ensureSiteCert(site, txID, diff); err != nil {
+			return nil, err
+		}
+		// IP 站点通过端口默认后端转发，不需要按主机名切换
+		if !isIPAddress(site.Domain) {
+			if err := s.ensureHostRoute(feHTTPS, aclName, site.Hosts(), backendName, txID, diff); err != nil {
+				return nil, err
+			}
+			if err := s.ensureSNICheck(site, txID, diff); err != nil {
+				return nil, err
+			}
+		}
+	} else {
+		if err := s.removeSiteCertConfig(site, txID, diff); err != nil {
+			return nil, err
+		}
+		if err := s.removeSNICheck(site, txID, diff); err != nil {
+			return nil, err
+		}
+		if err := s.removeHostRoute(feHTTPS, aclName, txID, diff); err != nil {
+			return nil, err
+		}
+	}
+
+	return diff, nil
+}
+
+// removeSite 在事务中删除站点配置，端口上没有其他站点时删除端口级前端和后端
+func (s *HAProxyServiceImpl) removeSite(site model.Site, txID string) (*siteDiff, error) {
+	diff := &siteDiff{}
+	dash := getDashDomain(site.Domain)
+	aclName := fmt.Sprintf("host_%s", dash)
+	backendName, prefix := siteBackend(site)
+
+	if err := s.removeLocations(site, txID, diff); err != nil {
+		return nil, err
+	}
+	if err := s.removeRedirects(site, txID, diff); err != nil {
+		return nil, err
+	}
+	if err := s.removeSNICheck(site, txID, diff); err != nil {
+		return nil, err
+	}
+
+	for _, fe := range []string{fmt.Sprintf("fe_%d_http", site.ListenPort), fmt.Sprintf("fe_%d_https", site.ListenPort)} {
+		if err := s.removeHostRoute(fe, aclName, txID, diff); err != nil {
+			return nil, err
+		}
+	}
+
+	if isIPAddress(site.Domain) {
+		_, servers, err := s.confClient.GetServers("backend", backendName, txID)
+		if err != nil {
+			return nil, fmt.Errorf("获取后端服务器失败: %v", err)
+		}
+		remaining := 0
+		for _, server := range servers {
+			if !strings.HasPrefix(server.Name, prefix) {
+				remaining++
+				continue
+			}
+			if err := s.confClient.DeleteServer(server.Name, "backend", backendName, txID, 0); err != nil {
+				return nil, fmt.Errorf("删除后端服务器失败: %v", err)
+			}
+			diff.configChanged = true
+		}
+		if remaining == 0 {
+			if err := s.confClient.CreateServer("backend", backendName, defaultLoopbackServer(), txID, 0); err != nil {
+				return nil, fmt.Errorf("创建后端服务器失败: %v", err)
+			}
+			// 端口默认后端恢复为不带负载均衡和健康检查设置的占位后端
+			if err := s.ensureBackendOptions(backendName, model.Backend{}, txID, diff); err != nil {
+				return nil, err
+			}
+			diff.configChanged = true
+		}
+	} else if _, _, err := s.confClient.GetBackend(backendName, txID); err == nil {
+		if err := s.confClient.DeleteBackend(backendName, txID, 0); err != nil {
+			return nil, fmt.Errorf("删除后端失败: %v", err)
+		}
+		diff.configChanged = true
+	}
+
+	if err := s.removeSiteCertConfig(site, txID, diff); err != nil {
+		return nil, err
+	}
+
+	if err := s.removePortIfUnused(site.ListenPort, txID, diff); err != nil {
+		return nil, err
+	}
+
+	return diff, nil
+}
+
+// ensureHostRoute 确保前端中存在匹配站点主域名和别名的 ACL 和对应的后端切换规则
+func (s *HAProxyServiceImpl) ensureHostRoute(frontend, aclName string, hosts []string, backendName, txID string, diff *siteDiff) error {
+	if err := s.ensureACLs(frontend, aclName, hostACLs(aclName, hostCriterion, hosts), txID, diff); err != nil {
+		return err
+	}
+
+	_, rules, err := s.confClient.GetBackendSwitchingRules(frontend, txID)
+	if err != nil {
+		return fmt.Errorf("获取后端切换规则失败: %v", err)
+	}
+	if !slices.ContainsFunc(rules, func(r *models.BackendSwitchingRule) bool {
+		return r.Name == backendName && r.CondTest == aclName
+	}) {
+		rule := &models.BackendSwitchingRule{
+			Name:     backendName,
+			Cond:     "if",
+			CondTest: aclName,
+		}
+		if err := s.confClient.CreateBackendSwitchingRule(int64(len(rules)), frontend, rule, txID, 0); err != nil {
+			return fmt.Errorf("创建后端切换规则失败: %v", err)
+		}
+		diff.configChanged = true
+	}
+
+	return nil
+}
+
+// removeHostRoute 删除前端中站点的 ACL 和引用它的后端切换规则，按索引倒序删除避免索引偏移
+func (s *HAProxyServiceImpl) removeHostRoute(frontend, aclName, txID string, diff *siteDiff) error {
+	if _, _, err := s.confClient.GetFrontend(frontend, txID); err != nil {
+		return nil
+	}
+
+	_, rules, err := s.confClient.GetBackendSwitchingRules(frontend, txID)
+	if err != nil {
+		return fmt.Errorf("获取后端切换规则失败: %v", err)
+	}
+	for i := len(rules) - 1; i >= 0; i-- {
+		if rules[i].CondTest != aclName {
+			continue
+		}
+		if err := s.confClient.DeleteBackendSwitchingRule(int64(i), frontend, txID, 0); err != nil {
+			return fmt.Errorf("删除后端切换规则失败: %v", err)
+		}
+		diff.configChanged = true
+	}
+
+	_, aclList, err := s.confClient.GetACLs("frontend", frontend, txID)
+	if err != nil {
+		return fmt.Errorf("获取 ACL 失败: %v", err)
+	}
+	for i := len(aclList) - 1; i >= 0; i-- {
+		if aclList[i].ACLName != aclName {
+			continue
+		}
+		if err := s.confClient.DeleteACL(int64(i), "frontend", frontend, txID, 0); err != nil {
+			return fmt.Errorf("删除 ACL 失败: %v", err)
+		}
+		diff.configChanged = true
+	}
+
+	return nil
+}
+
+// reconcileServers 比较站点后端中的服务器与期望列表，已有后端中的变更同时记录下来用于运行时更新
+func (s *HAProxyServiceImpl) reconcileServers(site model.Site, txID string, diff *siteDiff) error {
+	backendName, prefix := siteBackend(site)
+	runtimeApplicable := !diff.configChanged
+
+	_, servers, err := s.confClient.GetServers("backend", backendName, txID)
+	if err != nil {
+		return fmt.Errorf("获取后端服务器失败: %v", err)
+	}
+	current := make(map[string]*models.Server, len(servers))
+	for _, server := range servers {
+		if strings.HasPrefix(server.Name, prefix) || (isIPAddress(site.Domain) && !site.IsTCP() && server.Name == "loopback-for-default") {
+			current[server.Name] = server
+		}
+	}
+
+	var changes []serverChange
+	desired := make(map[string]bool, len(site.Backend.Servers))
+	for index, server := range site.Backend.Servers {
+		want := newBackendServer(fmt.Sprintf("%s%d", prefix, index), server, site.Backend)
+		applyProxyProtocol(want, site)
+		desired[want.Name] = true
+
+		old, ok := current[want.Name]
+		switch {
+		case !ok:
+			if err := s.confClient.CreateServer("backend", backendName, want, txID, 0); err != nil {
+				return fmt.Errorf("创建后端服务器失败: %v", err)
+			}
+			changes = append(changes, serverChange{op: "add", backend: backendName, server: want})
+		case !sameServer(old, want):
+			if err := s.confClient.EditServer(want.Name, "backend", backendName, want, txID, 0); err != nil {
+				return fmt.Errorf("修改后端服务器失败: %v", err)
+			}
+			changes = append(changes, serverChange{op: "edit", backend: backendName, server: want, old: old})
+		}
+	}
+
+	for name, old := range current {
+		if desired[name] {
+			continue
+		}
+		if err := s.confClient.DeleteServer(name, "backend", backendName, txID, 0); err != nil {
+			return fmt.Errorf("删除后端服务器失败: %v", err)
+		}
+		changes = append(changes, serverChange{op: "delete", backend: backendName, server: old})
+	}
+
+	if runtimeApplicable {
+		diff.servers = append(diff.servers, changes...)
+	} else if len(changes) > 0 {
+		diff.configChanged = true
+	}
+	return nil
+}
+
+// ensureSiteCert 确保证书文件、证书加载项和 HTTPS 绑定中的证书引用与站点一致，证书内容变化需要重载
+func (s *HAProxyServiceImpl) ensureSiteCert(site model.Site, txID string, diff *siteDiff) error {
+	dash := getDashDomain(site.Domain)
+
+	certPath := filepath.Join(s.CertDir, site.Domain+".crt")
+	keyPath := filepath.Join(s.CertDir, site.Domain+".key")
+	oldCert, _ := os.ReadFile(certPath)
+	oldKey, _ := os.ReadFile(keyPath)
+	if !bytes.Equal(oldCert, []byte(site.Certificate.PublicKey)) || !bytes.Equal(oldKey, []byte(site.Certificate.PrivateKey)) {
+		if err := s.addSiteCert(site); err != nil {
+			return fmt.Errorf("添加证书失败: %v", err)
+		}
+		diff.configChanged = true
+	}
+
+	if usesCrtList(site) {
+		return s.ensureSiteTLS(site, txID, diff)
+	}
+	if err := s.removeSiteTLS(site, txID, diff); err != nil {
+		return err
+	}
+
+	if _, _, err := s.confClient.GetCrtLoad(site.Domain+".crt", "sites", txID); err != nil {
+		crtLoad := &models.CrtLoad{
+			Certificate: site.Domain + ".crt",
+			Key:         site.Domain + ".key",
+			Alias:       fmt.Sprintf("%s_cert", dash),
+		}
+		if err := s.confClient.CreateCrtLoad("sites", crtLoad, txID, 0); err != nil {
+			return fmt.Errorf("创建证书加载失败: %v", err)
+		}
+		diff.configChanged = true
+	}
+
+	feHTTPS := fmt.Sprintf("fe_%d_https", site.ListenPort)
+	_, bind, err := s.confClient.GetBind("internal_https", "frontend", feHTTPS, txID)
+	if err != nil {
+		return fmt.Errorf("获取绑定失败: %v", err)
+	}
+	crt := fmt.Sprintf("@sites/%s_cert", dash)
+	if !bind.Ssl || !slices.Contains(bind.DefaultCrtList, crt) {
+		if !slices.Contains(bind.DefaultCrtList, crt) {
+			bind.DefaultCrtList = append(bind.DefaultCrtList, crt)
+		}
+		bind.Ssl = true
+		if err := s.confClient.EditBind("internal_https", "frontend", feHTTPS, bind, txID, 0); err != nil {
+			return fmt.Errorf("修改绑定失败: %v", err)
+		}
+		diff.configChanged = true
+	}
+
+	return nil
+}
+
+// removeSiteCertConfig 删除站点的证书引用、证书加载项、TLS 策略和证书文件，HTTPS 绑定不再有证书时关闭 SSL
+func (s *HAProxyServiceImpl) removeSiteCertConfig(site model.Site, txID string, diff *siteDiff) error {
+	if err := s.removeSiteTLS(site, txID, diff); err != nil {
+		return err
+	}
+	if err := s.removeSiteCrtLoad(site, txID, diff); err != nil {
+		return err
+	}
+
+	if _, err := os.Stat(filepath.Join(s.CertDir, site.Domain+".crt")); err == nil {
+		if err := s.removeSiteCert(site); err != nil {
+			return fmt.Errorf("删除证书失败: %v", err)
+		}
+		diff.configChanged = true
+	}
+
+	return nil
+}
+
+// removeSiteCrtLoad 删除站点在 default-crt 中的证书引用和证书加载项
+func (s *HAProxyServiceImpl) removeSiteCrtLoad(site model.Site, txID string, diff *siteDiff) error {
+	dash := getDashDomain(site.Domain)
+
+	feHTTPS := fmt.Sprintf("fe_%d_https", site.ListenPort)
+	if _, bind, err := s.confClient.GetBind("internal_https", "frontend", feHTTPS, txID); err == nil {
+		crt := fmt.Sprintf("@sites/%s_cert", dash)
+		if slices.Contains(bind.DefaultCrtList, crt) {
+			bind.DefaultCrtList = slices.DeleteFunc(bind.DefaultCrtList, func(c string) bool { return c == crt })
+			if len(bind.DefaultCrtList) == 0 {
+				bind.DefaultCrtList = nil
+				bind.Ssl = bind.CrtList != ""
+			}
+			if err := s.confClient.EditBind("internal_https", "frontend", feHTTPS, bind, txID, 0); err != nil {
+				return fmt.Errorf("修改绑定失败: %v", err)
+			}
+			diff.configChanged = true
+		}
+	}
+
+	if _, _, err := s.confClient.GetCrtLoad(site.Domain+".crt", "sites", txID); err == nil {
+		if err := s.confClient.DeleteCrtLoad(site.Domain+".crt", "sites", txID, 0); err != nil {
+			return fmt.Errorf("删除证书加载失败: %v", err)
+		}
+		diff.configChanged = true
+	}
+
+	return nil
+}
+
+// removePortIfUnused 端口上没有任何站点时删除端口级前端和后端，释放监听端口
+func (s *HAProxyServiceImpl) removePortIfUnused(port int, txID string, diff *siteDiff) error {
+	for _, fe := range []string{fmt.Sprintf("fe_%d_http", port), fmt.Sprintf("fe_%d_https", port)} {
+		_, aclList, err := s.confClient.GetACLs("frontend", fe, txID)
+		if err != nil {
+			return fmt.Errorf("获取 ACL 失败: %v", err)
+		}
+		if len(aclList) > 0 {
+			return nil
+		}
+	}
+	_, servers, err := s.confClient.GetServers("backend", fmt.Sprintf("p%d_backend", port), txID)
+	if err != nil {
+		return fmt.Errorf("获取后端服务器失败: %v", err)
+	}
+	if len(servers) != 1 || servers[0].Name != "loopback-for-default" {
+		return nil
+	}
+
+	for _, fe := range []string{"fe_%d_combined", "fe_%d_http", "fe_%d_https"} {
+		if err := s.confClient.DeleteFrontend(fmt.Sprintf(fe, port), txID, 0); err != nil {
+			return fmt.Errorf("删除前端失败: %v", err)
+		}
+	}
+	for _, be := range []string{"be_%d_http", "be_%d_https", "p%d_backend"} {
+		if err := s.confClient.DeleteBackend(fmt.Sprintf(be, port), txID, 0); err != nil {
+			return fmt.Errorf("删除后端失败: %v", err)
+		}
+	}
+	diff.configChanged = true
+
+	s.logger.Info().Int("port", port).Msg("端口上已没有站点，删除端口前端")
+	return nil
+}
+
+// applyServerChanges 通过运行时 API 应用服务器变更，配置文件已在事务中更新
+//
+// 运行时只接受IP地址，域名服务器或运行时命令失败时返回错误，由调用方重载。
+// 先添加和修改再删除，避免后端在切换过程中没有可用服务器。
+func (s *HAProxyServiceImpl) applyServerChanges(changes []serverChange) error {
+	if s.GetStatus() != StatusRunning {
+		return fmt.Errorf("HAProxy 未运行")
+	}
+	for _, c := range changes {
+		if needsRuntimeAddress(c) && !isIPAddress(c.server.Address) {
+			return fmt.Errorf("服务器地址 %s 不是IP，运行时无法解析", c.server.Address)
+		}
+	}
+	if err := s.ensureRuntimeClient(); err != nil {
+		return err
+	}
+
+	ordered := slices.Clone(changes)
+	slices.SortStableFunc(ordered, func(a, b serverChange) int {
+		return cmp.Compare(opOrder[a.op], opOrder[b.op])
+	})
+
+	for _, c := range ordered {
+		name := c.server.Name
+		switch c.op {
+		case "add":
+			if err := s.runtimeAddServer(c.backend, c.server); err != nil {
+				return err
+			}
+		case "edit":
+			if sameServerParams(c.old, c.server) {
+				if err := s.runtimeEditServer(c.backend, c.old, c.server); err != nil {
+					return err
+				}
+				continue
+			}
+			if err := s.runtimeDeleteServer(c.backend, name); err != nil {
+				return err
+			}
+			if err := s.runtimeAddServer(c.backend, c.server); err != nil {
+				return err
+			}
+		case "delete":
+			if err := s.runtimeDeleteServer(c.backend, name); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// runtimeAddServer 动态添加服务器，新服务器默认处于维护状态且不执行健康检查，需要显式启用
+func (s *HAProxyServiceImpl) runtimeAddServer(backend string, server *models.Server) error {
+	if err := s.runtimeClient.AddServer(backend, server.Name, runtimeServerAttrs(server)); err != nil {
+		return fmt.Errorf("添加服务器 %s/%s 失败: %v", backend, server.Name, err)
+	}
+	if server.Check == "enabled" {
+		if err := s.runtimeClient.EnableServerHealth(backend, server.Name); err != nil {
+			return fmt.Errorf("启用服务器 %s/%s 健康检查失败: %v", backend, server.Name, err)
+		}
+	}
+	if state := runtimeAdminState(server); state != models.RuntimeServerAdminStateMaint {
+		if err := s.runtimeClient.SetServerState(backend, server.Name, state); err != nil {
+			return fmt.Errorf("设置服务器 %s/%s 状态失败: %v", backend, server.Name, err)
+		}
+	}
+	return nil
+}
+
+// runtimeEditServer 通过运行时 API 修改服务器的地址、权重和管理状态，只修改有变化的部分
+// drain 在配置中以权重 0 表示，运行时使用 drain 状态并保留原权重，恢复 ready 时不需要再设置权重
+func (s *HAProxyServiceImpl) runtimeEditServer(backend string, old, server *models.Server) error {
+	name := server.Name
+	if old.Address != server.Address || GetSafeInt64(old.Port) != GetSafeInt64(server.Port) {
+		if err := s.runtimeClient.SetServerAddr(backend, name, server.Address, int(GetSafeInt64(server.Port))); err != nil {
+			return fmt.Errorf("修改服务器 %s/%s 地址失败: %v", backend, name, err)
+		}
+	}
+	if weight := weightOf(server); weight > 0 && weight != weightOf(old) {
+		if err := s.runtimeClient.SetServerWeight(backend, name, strconv.FormatInt(weight, 10)); err != nil {
+			return fmt.Errorf("修改服务器 %s/%s 权重失败: %v", backend, name, err)
+		}
+	}
+	if state := runtimeAdminState(server); state != runtimeAdminState(old) {
+		if err := s.runtimeClient.SetServerState(backend, name, state); err != nil {
+			return fmt.Errorf("设置服务器 %s/%s 状态失败: %v", backend, name, err)
+		}
+	}
+	return nil
+}
+
+// needsRuntimeAddress 变更是否需要运行时解析服务器地址，只修改权重和状态时不需要
+func needsRuntimeAddress(c serverChange) bool {
+	switch c.op {
+	case "add":
+		return true
+	case "edit":
+		return c.old.Address != c.server.Address || GetSafeInt64(c.old.Port) != GetSafeInt64(c.server.Port) || !sameServerParams(c.old, c.server)
+	}
+	return false
+}
+
+// runtimeDeleteServer 先将服务器置为维护状态再删除，仍有连接时 HAProxy 会拒绝删除
+func (s *HAProxyServiceImpl) runtimeDeleteServer(backend, name string) error {
+	if err := s.runtimeClient.SetServerState(backend, name, models.RuntimeServerAdminStateMaint); err != nil {
+		return fmt.Errorf("设置服务器 %s/%s 维护状态失败: %v", backend, name, err)
+	}
+	if err := s.runtimeClient.DeleteServer(backend, name); err != nil {
+		return fmt.Errorf("删除服务器 %s/%s 失败: %v", backend, name, err)
+	}
+	return nil
+}
+
+// siteBackend 返回站点使用的后端和服务器名前缀，IP 站点的服务器挂在端口默认后端上
+func siteBackend(site model.Site) (string, string) {
+	dash := getDashDomain(site.Domain)
+	if site.IsTCP() {
+		return tcpBackendName(site.ListenPort), fmt.Sprintf("%s_", dash)
+	}
+	if isIPAddress(site.Domain) {
+		return fmt.Sprintf("p%d_backend", site.ListenPort), fmt.Sprintf("s%s_", dash)
+	}
+	return fmt.Sprintf("be_%s", dash), fmt.Sprintf("%s_", dash)
+}
+
+// defaultLoopbackServer 端口默认后端的占位服务器
+func defaultLoopbackServer() *models.Server {
+	return &models.Server{
+		Name:    "loopback-for-default",
+		Address: "httpbin.org",
+		Port:    Int64P(80),
+	}
+}
@@ -0,0 +1,188 @@
+package haproxy
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+
+	"github.com/HUAHUAI23/simple-waf/server/model"
+	"github.com/haproxytech/client-native/v6/models"
+	runtime_api "github.com/haproxytech/client-native/v6/runtime"
+)
+
+// fakeRuntime 记录运行时 API 调用
+type fakeRuntime struct {
+	runtime_api.Runtime
+	calls []string
+}
+
+func (r *fakeRuntime) AddServer(backend, name, attributes string) error {
+	r.calls = append(r.calls, fmt.Sprintf("add %s/%s %s", backend, name, attributes))
+	return nil
+}
+
+func (r *fakeRuntime) EnableServerHealth(backend, server string) error {
+	r.calls = append(r.calls, fmt.Sprintf("health %s/%s", backend, server))
+	return nil
+}
+
+func (r *fakeRuntime) SetServerWeight(backend, server string, weight string) error {
+	r.calls = append(r.calls, fmt.Sprintf("weight %s/%s %s", backend, server, weight))
+	return nil
+}
+
+func (r *fakeRuntime) SetServerAddr(backend, server string, ip string, port int) error {
+	r.calls = append(r.calls, fmt.Sprintf("addr %s/%s %s:%d", backend, server, ip, port))
+	return nil
+}
+
+func (r *fakeRuntime) SetServerState(backend, server string, state string) error {
+	r.calls = append(r.calls, fmt.Sprintf("state %s/%s %s", backend, server, state))
+	return nil
+}
+
+func (r *fakeRuntime) DeleteServer(backend, name string) error {
+	r.calls = append(r.calls, fmt.Sprintf("del %s/%s", backend, name))
+	return nil
+}
+
+func newRuntimeTestService(runtime *fakeRuntime) *HAProxyServiceImpl {
+	s := &HAProxyServiceImpl{runtimeClient: runtime}
+	s.status.Store(int32(StatusRunning))
+	return s
+}
+
+func testServer(name, address string, port int64, ssl string) *models.Server {
+	server := &models.Server{Name: name, Address: address, Port: Int64P(port)}
+	if ssl == "enabled" {
+		server.ServerParams.Ssl = ssl
+		server.ServerParams.Verify = "none"
+	}
+	return server
+}
+
+func TestApplyServerChangesOrder(t *testing.T) {
+	runtime := &fakeRuntime{}
+	s := newRuntimeTestService(runtime)
+
+	changes := []serverChange{
+		{op: "delete", backend: "be_a_com", server: testServer("a_com_2", "10.0.0.2", 80, "")},
+		{op: "edit", backend: "be_a_com", server: testServer("a_com_0", "10.0.0.10", 8080, ""), old: testServer("a_com_0", "10.0.0.1", 80, "")},
+		{op: "edit", backend: "be_a_com", server: testServer("a_com_1", "10.0.0.3", 443, "enabled"), old: testServer("a_com_1", "10.0.0.3", 80, "")},
+		{op: "add", backend: "be_a_com", server: testServer("a_com_3", "10.0.0.4", 80, "")},
+	}
+	if err := s.applyServerChanges(changes); err != nil {
+		t.Fatalf("applyServerChanges: %v", err)
+	}
+
+	// 先添加和修改再删除；TLS 设置变化时删除后重新添加
+	want := []string{
+		"add be_a_com/a_com_3 10.0.0.4:80",
+		"state be_a_com/a_com_3 ready",
+		"addr be_a_com/a_com_0 10.0.0.10:8080",
+		"state be_a_com/a_com_1 maint",
+		"del be_a_com/a_com_1",
+		"add be_a_com/a_com_1 10.0.0.3:443 ssl verify none",
+		"state be_a_com/a_com_1 ready",
+		"state be_a_com/a_com_2 maint",
+		"del be_a_com/a_com_2",
+	}
+	if !slices.Equal(runtime.calls, want) {
+		t.Errorf("calls =\n%q\nwant\n%q", runtime.calls, want)
+	}
+}
+
+func TestApplyServerChangesState(t *testing.T) {
+	runtime := &fakeRuntime{}
+	s := newRuntimeTestService(runtime)
+
+	backend := model.Backend{HealthCheck: &model.HealthCheck{Type: model.HealthCheckTCP, Interval: 2, Rise: 2, Fall: 3}}
+	ready := newBackendServer("a_com_0", model.Server{Host: "10.0.0.1", Port: 80}, backend)
+	drain := newBackendServer("a_com_0", model.Server{Host: "10.0.0.1", Port: 80, State: model.ServerStateDrain}, backend)
+	weighted := newBackendServer("a_com_1", model.Server{Host: "10.0.0.2", Port: 80, Weight: 5}, backend)
+	maint := newBackendServer("a_com_1", model.Server{Host: "10.0.0.2", Port: 80, Weight: 5, State: model.ServerStateMaint}, backend)
+	added := newBackendServer("a_com_2", model.Server{Host: "backend.internal", Port: 80, State: model.ServerStateMaint}, backend)
+
+	// 只修改状态和权重时不需要解析地址，维护状态的新服务器保持 HAProxy 添加后的默认状态
+	changes := []serverChange{
+		{op: "edit", backend: "be_a_com", server: drain, old: ready},
+		{op: "edit", backend: "be_a_com", server: maint, old: newBackendServer("a_com_1", model.Server{Host: "10.0.0.2", Port: 80}, backend)},
+	}
+	if err := s.applyServerChanges(changes); err != nil {
+		t.Fatalf("applyServerChanges: %v", err)
+	}
+	want := []string{
+		"state be_a_com/a_com_0 drain",
+		"weight be_a_com/a_com_1 5",
+		"state be_a_com/a_com_1 maint",
+	}
+	if !slices.Equal(runtime.calls, want) {
+		t.Errorf("calls =\n%q\nwant\n%q", runtime.calls, want)
+	}
+
+	runtime.calls = nil
+	added.Address = "10.0.0.3"
+	if err := s.applyServerChanges([]serverChange{{op: "add", backend: "be_a_com", server: added}, {op: "edit", backend: "be_a_com", server: weighted, old: maint}}); err != nil {
+		t.Fatalf("applyServerChanges: %v", err)
+	}
+	want = []string{
+		"add be_a_com/a_com_2 10.0.0.3:80 check inter 2000 rise 2 fall 3",
+		"health be_a_com/a_com_2",
+		"state be_a_com/a_com_1 ready",
+	}
+	if !slices.Equal(runtime.calls, want) {
+		t.Errorf("calls =\n%q\nwant\n%q", runtime.calls, want)
+	}
+}
+
+func TestApplyServerChangesRejectsHostnames(t *testing.T) {
+	runtime := &fakeRuntime{}
+	s := newRuntimeTestService(runtime)
+
+	// 运行时无法解析域名，任何变更都不应执行，由调用方重载
+	changes := []serverChange{
+		{op: "add", backend: "be_a_com", server: testServer("a_com_0", "10.0.0.1", 80, "")},
+		{op: "add", backend: "be_a_com", server: testServer("a_com_1", "backend.internal", 80, "")},
+	}
+	if err := s.applyServerChanges(changes); err == nil {
+		t.Error("applyServerChanges accepted a hostname")
+	}
+	if len(runtime.calls) != 0 {
+		t.Errorf("calls = %q, want none", runtime.calls)
+	}
+
+	s.status.Store(int32(StatusStopped))
+	if err := s.applyServerChanges(changes[:1]); err == nil {
+		t.Error("applyServerChanges succeeded while HAProxy is stopped")
+	}
+}
+
+func TestSiteBackend(t *testing.T) {
+	tests := []struct {
+		site           model.Site
+		backend, names string
+	}{
+		{model.Site{Domain: "a.com", ListenPort: 80}, "be_a_com", "a_com_"},
+		{model.Site{Domain: "api.a.com", ListenPort: 443}, "be_api_a_com", "api_a_com_"},
+		// IP 站点的服务器挂在端口默认后端上
+		{model.Site{Domain: "192.168.1.10", ListenPort: 8080}, "p8080_backend", "s192_168_1_10_"},
+	}
+	for _, tt := range tests {
+		backend, names := siteBackend(tt.site)
+		if backend != tt.backend || names != tt.names {
+			t.Errorf("siteBackend(%s) = %s, %s, want %s, %s", tt.site.Domain, backend, names, tt.backend, tt.names)
+		}
+	}
+}
+
+func TestSiteDiffChanged(t *testing.T) {
+	if (&siteDiff{}).changed() {
+		t.Error("empty diff reported as changed")
+	}
+	if !(&siteDiff{configChanged: true}).changed() {
+		t.Error("config change not reported")
+	}
+	if !(&siteDiff{servers: []serverChange{{op: "add"}}}).changed() {
+		t.Error("server change not reported")
+	}
+}
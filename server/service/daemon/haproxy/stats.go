@@ -0,0 +1,220 @@
+package haproxy
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/HUAHUAI23/simple-waf/server/model"
+	"github.com/haproxytech/client-native/v6/models"
+)
+
+// RuntimeStats HAProxy 进程、端口前端和站点的实时统计，来自运行时 API 的 show info 和 show stat
+type RuntimeStats struct {
+	Process   ProcessStats    `json:"process"`   // 进程信息
+	Frontends []FrontendStats `json:"frontends"` // 端口前端
+	Sites     []SiteStats     `json:"sites"`     // 站点，按传入的站点顺序排列
+}
+
+// ProcessStats HAProxy 进程信息
+type ProcessStats struct {
+	Version        string `json:"version"`        // HAProxy 版本
+	Pid            int64  `json:"pid"`            // 工作进程ID
+	Uptime         int64  `json:"uptime"`         // 运行时间（秒）
+	Threads        int64  `json:"threads"`        // 线程数
+	CurrentConns   int64  `json:"currentConns"`   // 当前连接数
+	MaxConn        int64  `json:"maxConn"`        // 最大连接数
+	ConnRate       int64  `json:"connRate"`       // 每秒新建连接数
+	SessionRate    int64  `json:"sessionRate"`    // 每秒新建会话数
+	TotalConns     int64  `json:"totalConns"`     // 累计连接数
+	TotalRequests  int64  `json:"totalRequests"`  // 累计 HTTP 请求数
+	CurrentSSLConn int64  `json:"currentSslConn"` // 当前 SSL 连接数
+}
+
+// TrafficStats 前端或站点的流量计数，计数从 HAProxy 启动或重载后开始
+type TrafficStats struct {
+	Sessions      int64 `json:"sessions"`      // 当前会话数
+	SessionsTotal int64 `json:"sessionsTotal"` // 累计会话数
+	SessionRate   int64 `json:"sessionRate"`   // 每秒新建会话数
+	RequestsTotal int64 `json:"requestsTotal"` // 累计 HTTP 请求数
+	BytesIn       int64 `json:"bytesIn"`       // 接收字节数
+	BytesOut      int64 `json:"bytesOut"`      // 发送字节数
+	Responses4xx  int64 `json:"responses4xx"`  // 4xx 响应数
+	Responses5xx  int64 `json:"responses5xx"`  // 5xx 响应数
+}
+
+// FrontendStats 端口前端的统计
+type FrontendStats struct {
+	Name        string `json:"name"`        // 前端名称
+	Port        int    `json:"port"`        // 监听端口，从前端名称解析，内部前端为 0
+	Status      string `json:"status"`      // OPEN/STOP
+	RequestRate int64  `json:"requestRate"` // 每秒 HTTP 请求数
+	Denied      int64  `json:"denied"`      // 被规则拒绝的请求数
+	TrafficStats
+}
+
+// SiteStats 站点的统计，由站点独占的后端汇总；IP 站点共用端口默认后端，按站点的服务器汇总
+type SiteStats struct {
+	ID         string         `json:"id"`         // 站点ID
+	Domain     string         `json:"domain"`     // 主域名
+	ListenPort int            `json:"listenPort"` // 监听端口
+	Mode       string         `json:"mode"`       // 站点类型
+	Status     string         `json:"status"`     // 站点默认后端状态 UP/DOWN，IP 站点有服务器 UP 时为 UP
+	Queued     int64          `json:"queued"`     // 排队中的请求数
+	Servers    []ServerHealth `json:"servers"`    // 站点默认后端和路径路由后端中的服务器
+	TrafficStats
+}
+
+// GetStats 读取进程信息和全部统计，并按站点的后端和服务器汇总
+func (s *HAProxyServiceImpl) GetStats(sites []model.Site) (*RuntimeStats, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.GetStatus() != StatusRunning {
+		return nil, fmt.Errorf("HAProxy 未运行")
+	}
+	if err := s.ensureRuntimeClient(); err != nil {
+		return nil, err
+	}
+
+	info, err := s.runtimeClient.GetInfo()
+	if err != nil {
+		return nil, fmt.Errorf("获取运行时信息失败: %v", err)
+	}
+	if info.Error != "" {
+		return nil, fmt.Errorf("获取运行时信息失败: %s", info.Error)
+	}
+	stats := s.runtimeClient.GetStats()
+	if stats.Error != "" {
+		return nil, fmt.Errorf("获取运行时统计失败: %s", stats.Error)
+	}
+
+	result := &RuntimeStats{
+		Frontends: make([]FrontendStats, 0),
+		Sites:     make([]SiteStats, 0, len(sites)),
+	}
+	if item := info.Info; item != nil {
+		result.Process = ProcessStats{
+			Version:        item.Version,
+			Pid:            GetSafeInt64(item.Pid),
+			Uptime:         GetSafeInt64(item.Uptime),
+			Threads:        GetSafeInt64(item.Nbthread),
+			CurrentConns:   GetSafeInt64(item.CurrConns),
+			MaxConn:        GetSafeInt64(item.MaxConn),
+			ConnRate:       GetSafeInt64(item.ConnRate),
+			SessionRate:    GetSafeInt64(item.SessRate),
+			TotalConns:     GetSafeInt64(item.CumConns),
+			TotalRequests:  GetSafeInt64(item.CumReq),
+			CurrentSSLConn: GetSafeInt64(item.CurrSslConns),
+		}
+	}
+
+	for _, stat := range stats.Stats {
+		if stat == nil || stat.Type != "frontend" || stat.Stats == nil {
+			continue
+		}
+		result.Frontends = append(result.Frontends, FrontendStats{
+			Name:         stat.Name,
+			Port:         frontendPort(stat.Name),
+			Status:       stat.Stats.Status,
+			RequestRate:  GetSafeInt64(stat.Stats.ReqRate),
+			Denied:       GetSafeInt64(stat.Stats.Dreq),
+			TrafficStats: trafficStats(stat.Stats),
+		})
+	}
+
+	for _, site := range sites {
+		result.Sites = append(result.Sites, siteStats(site, stats.Stats))
+	}
+	return result, nil
+}
+
+// siteStats 汇总站点的后端统计和服务器状态
+//
+// 域名站点和 TCP 站点独占默认后端和路径路由后端，直接累加后端的计数；
+// IP 站点共用端口默认后端，只累加属于站点的服务器的计数，没有服务器处理的请求不计入。
+func siteStats(site model.Site, stats []*models.NativeStat) SiteStats {
+	backendName, prefix := siteBackend(site)
+	shared := !site.IsTCP() && isIPAddress(site.Domain)
+	locationPrefix := locationBackendPrefix(getDashDomain(site.Domain))
+	ownsBackend := func(name string) bool {
+		if shared {
+			return false
+		}
+		return name == backendName || (!site.IsTCP() && strings.HasPrefix(name, locationPrefix))
+	}
+
+	result := SiteStats{
+		ID:         site.ID.Hex(),
+		Domain:     site.Domain,
+		ListenPort: site.ListenPort,
+		Mode:       string(site.Mode),
+		Status:     "DOWN",
+		Servers:    make([]ServerHealth, 0),
+	}
+	if result.Mode == "" {
+		result.Mode = string(model.SiteModeHTTP)
+	}
+
+	for _, stat := range stats {
+		if stat == nil || stat.Stats == nil {
+			continue
+		}
+		switch {
+		case stat.Type == "backend" && ownsBackend(stat.Name):
+			result.TrafficStats.add(trafficStats(stat.Stats))
+			result.Queued += GetSafeInt64(stat.Stats.Qcur)
+			if stat.Name == backendName {
+				result.Status = stat.Stats.Status
+			}
+		case stat.Type == "server" && (ownsBackend(stat.BackendName) || (shared && stat.BackendName == backendName && strings.HasPrefix(stat.Name, prefix))):
+			result.Servers = append(result.Servers, serverHealth(stat))
+			if shared {
+				result.TrafficStats.add(trafficStats(stat.Stats))
+				result.Queued += GetSafeInt64(stat.Stats.Qcur)
+				if stat.Stats.Status == "UP" {
+					result.Status = "UP"
+				}
+			}
+		}
+	}
+	return result
+}
+
+// frontendPort 从 fe_<port>_combined、fe_<port>_http 等前端名称中解析端口
+func frontendPort(name string) int {
+	rest, ok := strings.CutPrefix(name, "fe_")
+	if !ok {
+		return 0
+	}
+	port, _, _ := strings.Cut(rest, "_")
+	n, err := strconv.Atoi(port)
+	if err != nil {
+		return 0
+	}
+	return n
+}
+
+func trafficStats(stat *models.NativeStatStats) TrafficStats {
+	return TrafficStats{
+		Sessions:      GetSafeInt64(stat.Scur),
+		SessionsTotal: GetSafeInt64(stat.Stot),
+		SessionRate:   GetSafeInt64(stat.Rate),
+		RequestsTotal: GetSafeInt64(stat.ReqTot),
+		BytesIn:       GetSafeInt64(stat.Bin),
+		BytesOut:      GetSafeInt64(stat.Bout),
+		Responses4xx:  GetSafeInt64(stat.Hrsp4xx),
+		Responses5xx:  GetSafeInt64(stat.Hrsp5xx),
+	}
+}
+
+func (t *TrafficStats) add(other TrafficStats) {
+	t.Sessions += other.Sessions
+	t.SessionsTotal += other.SessionsTotal
+	t.SessionRate += other.SessionRate
+	t.RequestsTotal += other.RequestsTotal
+	t.BytesIn += other.BytesIn
+	t.BytesOut += other.BytesOut
+	t.Responses4xx += other.Responses4xx
+	t.Responses5xx += other.Responses5xx
+}
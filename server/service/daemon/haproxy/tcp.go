@@ -0,0 +1,168 @@
+package haproxy
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/HUAHUAI23/simple-waf/server/model"
+	"github.com/haproxytech/client-native/v6/models"
+)
+
+const (
+	tcpAllowACL = "tcp_allow"
+	tcpDenyACL  = "tcp_deny"
+)
+
+// tcpFrontendName TCP 站点独占端口的前端名称
+func tcpFrontendName(port int) string {
+	return fmt.Sprintf("fe_%d_tcp", port)
+}
+
+// tcpBackendName TCP 站点的后端名称
+func tcpBackendName(port int) string {
+	return fmt.Sprintf("be_%d_tcp", port)
+}
+
+// reconcileTCPSite 在事务中把 TCP 站点的前端、来源过滤、后端和服务器调整为期望状态
+//
+// TCP 前端直接监听站点的监听地址和端口，不经过 fe_<port>_combined 和 Coraza；新建前端需要重载才能监听。
+func (s *HAProxyServiceImpl) reconcileTCPSite(site model.Site, txID string) (*siteDiff, error) {
+	diff := &siteDiff{}
+	feName := tcpFrontendName(site.ListenPort)
+	beName := tcpBackendName(site.ListenPort)
+
+	want := models.Frontend{
+		FrontendBase: models.FrontendBase{
+			Name:           feName,
+			Mode:           "tcp",
+			DefaultBackend: beName,
+			Enabled:        true,
+			From:           "tcp",
+		},
+	}
+	if site.TCP.MaxConn > 0 {
+		want.Maxconn = Int64P(int64(site.TCP.MaxConn))
+	}
+
+	if _, current, err := s.confClient.GetFrontend(feName, txID); err != nil {
+		if err := s.confClient.CreateFrontend(&want, txID, 0); err != nil {
+			return nil, fmt.Errorf("创建前端失败: %v", err)
+		}
+		diff.configChanged = true
+	} else if GetSafeInt64(current.Maxconn) != GetSafeInt64(want.Maxconn) || current.DefaultBackend != beName {
+		edited := *current
+		edited.Maxconn = want.Maxconn
+		edited.DefaultBackend = beName
+		if err := s.confClient.EditFrontend(feName, &edited, txID, 0); err != nil {
+			return nil, fmt.Errorf("修改前端失败: %v", err)
+		}
+		diff.configChanged = true
+	}
+
+	if err := s.ensureListenBinds(feName, fmt.Sprintf("tcp_%d", site.ListenPort), site, txID, diff); err != nil {
+		return nil, err
+	}
+	if err := s.ensureTCPFilter(site, txID, diff); err != nil {
+		return nil, err
+	}
+
+	if _, _, err := s.confClient.GetBackend(beName, txID); err != nil {
+		backend := &models.Backend{
+			BackendBase: models.BackendBase{
+				Name:    beName,
+				Mode:    "tcp",
+				Enabled: true,
+				From:    "tcp",
+			},
+		}
+		if err := s.confClient.CreateBackend(backend, txID, 0); err != nil {
+			return nil, fmt.Errorf("创建后端失败: %v", err)
+		}
+		// 新后端在运行时中不存在，服务器只能随重载生效
+		diff.configChanged = true
+	}
+
+	if err := s.ensureBackendOptions(beName, site.Backend, txID, diff); err != nil {
+		return nil, err
+	}
+	if err := s.reconcileServers(site, txID, diff); err != nil {
+		return nil, err
+	}
+
+	return diff, nil
+}
+
+// removeTCPSite 删除 TCP 站点的前端和后端
+func (s *HAProxyServiceImpl) removeTCPSite(site model.Site, txID string) (*siteDiff, error) {
+	diff := &siteDiff{}
+	if _, _, err := s.confClient.GetFrontend(tcpFrontendName(site.ListenPort), txID); err == nil {
+		if err := s.confClient.DeleteFrontend(tcpFrontendName(site.ListenPort), txID, 0); err != nil {
+			return nil, fmt.Errorf("删除前端失败: %v", err)
+		}
+		diff.configChanged = true
+	}
+	if _, _, err := s.confClient.GetBackend(tcpBackendName(site.ListenPort), txID); err == nil {
+		if err := s.confClient.DeleteBackend(tcpBackendName(site.ListenPort), txID, 0); err != nil {
+			return nil, fmt.Errorf("删除后端失败: %v", err)
+		}
+		diff.configChanged = true
+	}
+	return diff, nil
+}
+
+// ensureTCPFilter 确保 TCP 前端拒绝封禁列表、拒绝列表中和允许列表外的来源地址
+func (s *HAProxyServiceImpl) ensureTCPFilter(site model.Site, txID string, diff *siteDiff) error {
+	feName := tcpFrontendName(site.ListenPort)
+
+	var allowACLs, denyACLs []*models.ACL
+	if len(site.TCP.Allow) > 0 {
+		allowACLs = append(allowACLs, &models.ACL{ACLName: tcpAllowACL, Criterion: "src", Value: strings.Join(site.TCP.Allow, " ")})
+	}
+	if len(site.TCP.Deny) > 0 {
+		denyACLs = append(denyACLs, &models.ACL{ACLName: tcpDenyACL, Criterion: "src", Value: strings.Join(site.TCP.Deny, " ")})
+	}
+	if err := s.ensureACLs(feName, tcpAllowACL, allowACLs, txID, diff); err != nil {
+		return err
+	}
+	if err := s.ensureACLs(feName, tcpDenyACL, denyACLs, txID, diff); err != nil {
+		return err
+	}
+
+	// 接受 PROXY 协议时在内容阶段按协议头中的客户端地址过滤
+	var rules models.TCPRequestRules
+	filterType := "connection"
+	if rule := expectProxyRule(site.TrustedProxies()); rule != nil {
+		rules = append(rules, rule)
+		filterType = "content"
+	}
+	rules = append(rules, &models.TCPRequestRule{
+		Type:     filterType,
+		Action:   "reject",
+		Cond:     "if",
+		CondTest: fmt.Sprintf("{ src,map_ip(%s) -m found }", s.BlocklistFile),
+	})
+	if len(denyACLs) > 0 {
+		rules = append(rules, &models.TCPRequestRule{Type: filterType, Action: "reject", Cond: "if", CondTest: tcpDenyACL})
+	}
+	if len(allowACLs) > 0 {
+		rules = append(rules, &models.TCPRequestRule{Type: filterType, Action: "reject", Cond: "unless", CondTest: tcpAllowACL})
+	}
+
+	_, current, err := s.confClient.GetTCPRequestRules("frontend", feName, txID)
+	if err != nil {
+		return fmt.Errorf("获取TCP请求规则失败: %v", err)
+	}
+	if slices.EqualFunc(current, rules, sameTCPRequestRule) {
+		return nil
+	}
+	if err := s.confClient.ReplaceTCPRequestRules("frontend", feName, rules, txID, 0); err != nil {
+		return fmt.Errorf("修改TCP请求规则失败: %v", err)
+	}
+	diff.configChanged = true
+	return nil
+}
+
+func sameTCPRequestRule(a, b *models.TCPRequestRule) bool {
+	return a.Type == b.Type && a.Action == b.Action && a.Cond == b.Cond && a.CondTest == b.CondTest
+}
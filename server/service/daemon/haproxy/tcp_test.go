@@ -0,0 +1,124 @@
+package haproxy
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/HUAHUAI23/simple-waf/server/model"
+	"github.com/haproxytech/client-native/v6/configuration"
+	"github.com/haproxytech/client-native/v6/models"
+)
+
+// fakeConfiguration 内存中的前端 ACL 和 TCP 请求规则
+type fakeConfiguration struct {
+	configuration.Configuration
+	acls     map[string]models.Acls
+	tcpRules map[string]models.TCPRequestRules
+}
+
+func newFakeConfiguration() *fakeConfiguration {
+	return &fakeConfiguration{acls: map[string]models.Acls{}, tcpRules: map[string]models.TCPRequestRules{}}
+}
+
+func (c *fakeConfiguration) GetACLs(parentType, parentName string, transactionID string, aclName ...string) (int64, models.Acls, error) {
+	return 0, slices.Clone(c.acls[parentName]), nil
+}
+
+func (c *fakeConfiguration) DeleteACL(id int64, parentType string, parentName string, transactionID string, version int64) error {
+	c.acls[parentName] = slices.Delete(c.acls[parentName], int(id), int(id)+1)
+	return nil
+}
+
+func (c *fakeConfiguration) CreateACL(id int64, parentType string, parentName string, data *models.ACL, transactionID string, version int64) error {
+	c.acls[parentName] = slices.Insert(c.acls[parentName], int(id), data)
+	return nil
+}
+
+func (c *fakeConfiguration) GetTCPRequestRules(parentType, parentName string, transactionID string) (int64, models.TCPRequestRules, error) {
+	return 0, c.tcpRules[parentName], nil
+}
+
+func (c *fakeConfiguration) ReplaceTCPRequestRules(parentType string, parentName string, data models.TCPRequestRules, transactionID string, version int64) error {
+	c.tcpRules[parentName] = data
+	return nil
+}
+
+func tcpRuleString(rule *models.TCPRequestRule) string {
+	return rule.Type + " " + rule.Action + " " + rule.Cond + " " + rule.CondTest
+}
+
+func TestEnsureTCPFilter(t *testing.T) {
+	conf := newFakeConfiguration()
+	s := &HAProxyServiceImpl{confClient: conf, BlocklistFile: "/etc/haproxy/maps/blocklist.map"}
+	site := model.Site{
+		Mode:       model.SiteModeTCP,
+		ListenPort: 2222,
+		TCP:        &model.TCPOptions{Allow: []string{"10.0.0.0/8", "192.168.1.10"}, Deny: []string{"10.0.0.66"}},
+	}
+
+	diff := &siteDiff{}
+	if err := s.ensureTCPFilter(site, "tx", diff); err != nil {
+		t.Fatal(err)
+	}
+	if !diff.configChanged {
+		t.Error("first apply reported no change")
+	}
+	wantACLs := []string{"tcp_allow src 10.0.0.0/8 192.168.1.10", "tcp_deny src 10.0.0.66"}
+	if got := mapStrings(conf.acls["fe_2222_tcp"], aclString); !slices.Equal(got, wantACLs) {
+		t.Errorf("acls = %q, want %q", got, wantACLs)
+	}
+	// 封禁列表先于拒绝和允许列表
+	wantRules := []string{
+		"connection reject if { src,map_ip(/etc/haproxy/maps/blocklist.map) -m found }",
+		"connection reject if tcp_deny",
+		"connection reject unless tcp_allow",
+	}
+	if got := mapStrings(conf.tcpRules["fe_2222_tcp"], tcpRuleString); !slices.Equal(got, wantRules) {
+		t.Errorf("rules = %q, want %q", got, wantRules)
+	}
+
+	diff = &siteDiff{}
+	if err := s.ensureTCPFilter(site, "tx", diff); err != nil {
+		t.Fatal(err)
+	}
+	if diff.configChanged {
+		t.Error("unchanged filter reported a change")
+	}
+
+	// 去掉允许列表后删除对应的 ACL 和规则
+	site.TCP.Allow = nil
+	diff = &siteDiff{}
+	if err := s.ensureTCPFilter(site, "tx", diff); err != nil {
+		t.Fatal(err)
+	}
+	if got := mapStrings(conf.acls["fe_2222_tcp"], aclString); !slices.Equal(got, wantACLs[1:]) {
+		t.Errorf("acls = %q, want %q", got, wantACLs[1:])
+	}
+	if got := mapStrings(conf.tcpRules["fe_2222_tcp"], tcpRuleString); !slices.Equal(got, wantRules[:2]) {
+		t.Errorf("rules = %q, want %q", got, wantRules[:2])
+	}
+	if !diff.configChanged {
+		t.Error("removing the allow list reported no change")
+	}
+}
+
+func TestApplyProxyProtocol(t *testing.T) {
+	tests := []struct {
+		site           model.Site
+		proxy, proxyV2 string
+	}{
+		{model.Site{Mode: model.SiteModeTCP, TCP: &model.TCPOptions{ProxyProtocol: model.ProxyProtocolV1}}, "enabled", ""},
+		{model.Site{Mode: model.SiteModeTCP, TCP: &model.TCPOptions{ProxyProtocol: model.ProxyProtocolV2}}, "", "enabled"},
+		{model.Site{Mode: model.SiteModeTCP, TCP: &model.TCPOptions{}}, "", ""},
+		// HTTP 站点忽略 TCP 设置
+		{model.Site{Mode: model.SiteModeHTTP, TCP: &model.TCPOptions{ProxyProtocol: model.ProxyProtocolV2}}, "", ""},
+		{model.Site{Mode: model.SiteModeHTTP, Proxy: &model.ProxyOptions{Send: model.ProxyProtocolV1}}, "enabled", ""},
+	}
+	for i, tt := range tests {
+		srv := &models.Server{}
+		applyProxyProtocol(srv, tt.site)
+		if srv.SendProxy != tt.proxy || srv.SendProxyV2 != tt.proxyV2 {
+			t.Errorf("case %d: send-proxy = %q, send-proxy-v2 = %q", i, srv.SendProxy, srv.SendProxyV2)
+		}
+	}
+}
@@ -0,0 +1,417 @@
+package haproxy
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/HUAHUAI23/simple-waf/server/model"
+	"github.com/haproxytech/client-native/v6/models"
+)
+
+const (
+	hstsVar      = "hsts" // 记录命中 HSTS 的站点，响应阶段据此添加响应头
+	hstsHeader   = "Strict-Transport-Security"
+	altSvcVar    = "altsvc" // 记录开启 HTTP/3 的站点，响应阶段据此添加 alt-svc
+	altSvcHeader = "alt-svc"
+	altSvcMaxAge = 86400
+)
+
+// usesCrtList 带 TLS 策略或开启 HTTP/2、HTTP/3 的站点需要按 SNI 设置 TLS 参数，通过端口的 crt-list 加载证书
+func usesCrtList(site model.Site) bool {
+	return site.TLS != nil || site.HTTP2 || site.HTTP3
+}
+
+// ensureSiteTLS 为带 TLS 策略或开启 HTTP/2、HTTP/3 的站点生成 crt-list 条目、HSTS 和 alt-svc 规则
+//
+// 共享的 HTTPS 绑定无法按站点设置 TLS 参数，这类站点改为通过端口的 crt-list 加载证书，
+// 协议版本、密码套件、ALPN 和 OCSP 装订写在条目上，按客户端 SNI 匹配证书中的域名生效。
+// 站点不再使用证书存储和 default-crt，任何变化都需要重载。
+func (s *HAProxyServiceImpl) ensureSiteTLS(site model.Site, txID string, diff *siteDiff) error {
+	bundle := siteCertBundle(site)
+	bundlePath := s.siteBundleFile(site.Domain)
+	if old, _ := os.ReadFile(bundlePath); !bytes.Equal(old, bundle) {
+		if err := os.MkdirAll(s.CertDir, 0755); err != nil {
+			return fmt.Errorf("创建证书目录失败: %v", err)
+		}
+		if err := os.WriteFile(bundlePath, bundle, 0600); err != nil {
+			return fmt.Errorf("写入证书文件失败: %v", err)
+		}
+		diff.configChanged = true
+	}
+
+	if err := s.removeSiteCrtLoad(site, txID, diff); err != nil {
+		return err
+	}
+
+	changed, err := updateCrtList(s.crtListFile(site.ListenPort), bundlePath, s.crtListEntry(site, false))
+	if err != nil {
+		return err
+	}
+	diff.configChanged = diff.configChanged || changed
+
+	quicEntry := ""
+	if site.HTTP3 {
+		quicEntry = s.crtListEntry(site, true)
+	}
+	changed, err = updateCrtList(s.quicCrtListFile(site.ListenPort), bundlePath, quicEntry)
+	if err != nil {
+		return err
+	}
+	diff.configChanged = diff.configChanged || changed
+
+	if err := s.ensureBindCrtList(site, txID, diff); err != nil {
+		return err
+	}
+	if err := s.ensureHSTS(site, txID, diff); err != nil {
+		return err
+	}
+	return s.ensureAltSvc(site, txID, diff)
+}
+
+// removeSiteTLS 删除站点的 crt-list 条目、证书文件、HSTS 和 alt-svc 规则，crt-list 为空时从绑定中移除
+func (s *HAProxyServiceImpl) removeSiteTLS(site model.Site, txID string, diff *siteDiff) error {
+	bundlePath := s.siteBundleFile(site.Domain)
+	changed, err := updateCrtList(s.crtListFile(site.ListenPort), bundlePath, "")
+	if err != nil {
+		return err
+	}
+	diff.configChanged = diff.configChanged || changed
+
+	changed, err = updateCrtList(s.quicCrtListFile(site.ListenPort), bundlePath, "")
+	if err != nil {
+		return err
+	}
+	diff.configChanged = diff.configChanged || changed
+
+	if err := s.ensureBindCrtList(site, txID, diff); err != nil {
+		return err
+	}
+
+	if _, err := os.Stat(bundlePath); err == nil {
+		if err := os.Remove(bundlePath); err != nil {
+			return fmt.Errorf("删除证书文件失败: %v", err)
+		}
+		diff.configChanged = true
+	}
+
+	site.TLS = nil
+	site.HTTP3 = false
+	if err := s.ensureHSTS(site, txID, diff); err != nil {
+		return err
+	}
+	return s.ensureAltSvc(site, txID, diff)
+}
+
+// ensureBindCrtList crt-list 文件存在时由端口的 HTTPS 绑定引用，没有任何证书时关闭 SSL
+// 端口有站点开启 HTTP/3 时（QUIC crt-list 存在）同时维护端口的 QUIC 绑定
+func (s *HAProxyServiceImpl) ensureBindCrtList(site model.Site, txID string, diff *siteDiff) error {
+	feHTTPS := fmt.Sprintf("fe_%d_https", site.ListenPort)
+	_, bind, err := s.confClient.GetBind("internal_https", "frontend", feHTTPS, txID)
+	if err != nil {
+		// 端口前端不存在时没有需要修改的绑定
+		return nil
+	}
+
+	crtList := s.crtListFile(site.ListenPort)
+	if _, err := os.Stat(crtList); err != nil {
+		crtList = ""
+	}
+	ssl := crtList != "" || len(bind.DefaultCrtList) > 0
+	if bind.CrtList != crtList || bind.Ssl != ssl {
+		bind.CrtList = crtList
+		bind.Ssl = ssl
+		if err := s.confClient.EditBind("internal_https", "frontend", feHTTPS, bind, txID, 0); err != nil {
+			return fmt.Errorf("修改绑定失败: %v", err)
+		}
+		diff.configChanged = true
+	}
+
+	var quic []*models.Bind
+	quicCrtList := s.quicCrtListFile(site.ListenPort)
+	if _, err := os.Stat(quicCrtList); err == nil {
+		quic = quicBinds(site, quicCrtList)
+	}
+	if err := s.ensureBinds(feHTTPS, fmt.Sprintf("quic_%d", site.ListenPort), quic, txID, diff); err != nil {
+		return err
+	}
+	return s.ensureQUICBlocklist(feHTTPS, len(quic) > 0, txID, diff)
+}
+
+// ensureQUICBlocklist QUIC 连接不经过端口组合前端的封禁检查，有 QUIC 绑定时在 HTTPS 前端拒绝封禁列表中的来源
+// 经组合前端转入的连接来源地址来自内部 PROXY 协议，已经检查过，重复检查不影响结果
+func (s *HAProxyServiceImpl) ensureQUICBlocklist(feHTTPS string, enabled bool, txID string, diff *siteDiff) error {
+	blocked := fmt.Sprintf("{ src,map_ip(%s) -m found }", s.BlocklistFile)
+	_, rules, err := s.confClient.GetHTTPRequestRules("frontend", feHTTPS, txID)
+	if err != nil {
+		return fmt.Errorf("获取HTTP请求规则失败: %v", err)
+	}
+	index := slices.IndexFunc(rules, func(rule *models.HTTPRequestRule) bool {
+		return rule.Type == "reject" && rule.CondTest == blocked
+	})
+	switch {
+	case enabled && index < 0:
+		rule := &models.HTTPRequestRule{Type: "reject", Cond: "if", CondTest: blocked}
+		if err := s.confClient.CreateHTTPRequestRule(0, "frontend", feHTTPS, rule, txID, 0); err != nil {
+			return fmt.Errorf("创建HTTP请求规则失败: %v", err)
+		}
+	case !enabled && index >= 0:
+		if err := s.confClient.DeleteHTTPRequestRule(int64(index), "frontend", feHTTPS, txID, 0); err != nil {
+			return fmt.Errorf("删除HTTP请求规则失败: %v", err)
+		}
+	default:
+		return nil
+	}
+	diff.configChanged = true
+	return nil
+}
+
+// quicBinds 按站点的监听地址生成 HTTP/3 使用的 UDP 绑定，证书来自端口的 QUIC crt-list
+// QUIC crt-list 只包含开启 HTTP/3 的站点，客户端只会在收到 alt-svc 后尝试 HTTP/3
+func quicBinds(site model.Site, crtList string) []*models.Bind {
+	binds := listenBinds(fmt.Sprintf("quic_%d", site.ListenPort), site.ListenPort, site.ListenAddrs)
+	for _, bind := range binds {
+		switch {
+		case bind.Address == "*":
+			bind.Address = "quic4@"
+		case model.IsIPv6Addr(bind.Address):
+			bind.Address = "quic6@" + bind.Address
+		default:
+			bind.Address = "quic4@" + bind.Address
+		}
+		bind.Ssl = true
+		bind.CrtList = crtList
+		bind.Alpn = "h3"
+	}
+	return binds
+}
+
+// ensureHSTS 在端口的 HTTPS 前端中为站点添加或删除 Strict-Transport-Security 响应头
+func (s *HAProxyServiceImpl) ensureHSTS(site model.Site, txID string, diff *siteDiff) error {
+	value := ""
+	if site.EnableHTTPS && site.TLS != nil && site.TLS.HSTS != nil {
+		value = site.TLS.HSTS.HeaderValue()
+	}
+	return s.ensureSiteResponseHeader(site, hstsVar, hstsHeader, value, txID, diff)
+}
+
+// ensureAltSvc 开启 HTTP/3 的站点通过 alt-svc 响应头告知客户端可以使用同一端口的 QUIC
+func (s *HAProxyServiceImpl) ensureAltSvc(site model.Site, txID string, diff *siteDiff) error {
+	value := ""
+	if site.EnableHTTPS && site.HTTP3 {
+		value = fmt.Sprintf(`h3=":%d"; ma=%d`, site.ListenPort, altSvcMaxAge)
+	}
+	return s.ensureSiteResponseHeader(site, altSvcVar, altSvcHeader, value, txID, diff)
+}
+
+// ensureSiteResponseHeader 在端口的 HTTPS 前端中只给该站点的响应设置响应头，value 为空时删除
+// 请求阶段按主机名 ACL 把站点记录到 txn.<varName>，响应阶段只给记录的站点设置响应头
+func (s *HAProxyServiceImpl) ensureSiteResponseHeader(site model.Site, varName, header, value, txID string, diff *siteDiff) error {
+	feHTTPS := fmt.Sprintf("fe_%d_https", site.ListenPort)
+	if _, _, err := s.confClient.GetFrontend(feHTTPS, txID); err != nil {
+		return nil
+	}
+
+	dash := getDashDomain(site.Domain)
+	aclName := fmt.Sprintf("host_%s", dash)
+	responseCond := fmt.Sprintf("{ var(txn.%s) -m str %s }", varName, dash)
+
+	var wantRequest []*models.HTTPRequestRule
+	var wantResponse []*models.HTTPResponseRule
+	if value != "" {
+		wantRequest = append(wantRequest, &models.HTTPRequestRule{
+			Type:     "set-var",
+			VarScope: "txn",
+			VarName:  varName,
+			VarExpr:  fmt.Sprintf("str(%s)", dash),
+			Cond:     "if",
+			CondTest: aclName,
+		})
+		wantResponse = append(wantResponse, &models.HTTPResponseRule{
+			Type:      "set-header",
+			HdrName:   header,
+			HdrFormat: fmt.Sprintf("%q", value),
+			Cond:      "if",
+			CondTest:  responseCond,
+		})
+	}
+
+	_, requestRules, err := s.confClient.GetHTTPRequestRules("frontend", feHTTPS, txID)
+	if err != nil {
+		return fmt.Errorf("获取HTTP请求规则失败: %v", err)
+	}
+	_, responseRules, err := s.confClient.GetHTTPResponseRules("frontend", feHTTPS, txID)
+	if err != nil {
+		return fmt.Errorf("获取HTTP响应规则失败: %v", err)
+	}
+
+	var currentRequest []*models.HTTPRequestRule
+	var currentResponse []*models.HTTPResponseRule
+	var requestIndexes, responseIndexes []int
+	for i, rule := range requestRules {
+		if rule.Type == "set-var" && rule.VarName == varName && rule.CondTest == aclName {
+			currentRequest = append(currentRequest, rule)
+			requestIndexes = append(requestIndexes, i)
+		}
+	}
+	for i, rule := range responseRules {
+		if rule.Type == "set-header" && strings.EqualFold(rule.HdrName, header) && rule.CondTest == responseCond {
+			currentResponse = append(currentResponse, rule)
+			responseIndexes = append(responseIndexes, i)
+		}
+	}
+
+	if slices.EqualFunc(currentRequest, wantRequest, sameRequestRule) &&
+		slices.EqualFunc(currentResponse, wantResponse, sameResponseHeaderRule) {
+		return nil
+	}
+
+	for _, i := range slices.Backward(requestIndexes) {
+		if err := s.confClient.DeleteHTTPRequestRule(int64(i), "frontend", feHTTPS, txID, 0); err != nil {
+			return fmt.Errorf("删除HTTP请求规则失败: %v", err)
+		}
+	}
+	for _, i := range slices.Backward(responseIndexes) {
+		if err := s.confClient.DeleteHTTPResponseRule(int64(i), "frontend", feHTTPS, txID, 0); err != nil {
+			return fmt.Errorf("删除HTTP响应规则失败: %v", err)
+		}
+	}
+
+	for i, rule := range wantRequest {
+		index := len(requestRules) - len(requestIndexes) + i
+		if err := s.confClient.CreateHTTPRequestRule(int64(index), "frontend", feHTTPS, rule, txID, 0); err != nil {
+			return fmt.Errorf("创建HTTP请求规则失败: %v", err)
+		}
+	}
+	for i, rule := range wantResponse {
+		index := len(responseRules) - len(responseIndexes) + i
+		if err := s.confClient.CreateHTTPResponseRule(int64(index), "frontend", feHTTPS, rule, txID, 0); err != nil {
+			return fmt.Errorf("创建HTTP响应规则失败: %v", err)
+		}
+	}
+
+	diff.configChanged = true
+	return nil
+}
+
+// crtListEntry 生成站点在 crt-list 中的条目，证书只用于 SNI 属于站点主域名和别名的连接
+// 没有 TLS 策略的站点只在条目上设置 ALPN。quic 为 true 时生成 QUIC crt-list 的条目，
+// QUIC 固定使用 TLS 1.3 且只协商 h3，不写协议版本和 TLS 1.2 密码套件
+func (s *HAProxyServiceImpl) crtListEntry(site model.Site, quic bool) string {
+	var options []string
+	if tls := site.TLS; tls != nil {
+		if tls.MinVersion != "" && !quic {
+			options = append(options, "ssl-min-ver", tls.MinVersion)
+		}
+		if tls.MaxVersion != "" && !quic {
+			options = append(options, "ssl-max-ver", tls.MaxVersion)
+		}
+		if tls.Ciphers != "" && !quic {
+			options = append(options, "ciphers", tls.Ciphers)
+		}
+		if tls.Ciphersuites != "" {
+			options = append(options, "ciphersuites", tls.Ciphersuites)
+		}
+	}
+	alpn := site.ALPN()
+	if quic {
+		alpn = []string{"h3"}
+	}
+	if len(alpn) > 0 {
+		options = append(options, "alpn", strings.Join(alpn, ","))
+	}
+	if site.TLS != nil && site.TLS.OCSPStapling {
+		options = append(options, "ocsp-update", "on")
+	}
+
+	entry := s.siteBundleFile(site.Domain)
+	if len(options) > 0 {
+		entry += " [" + strings.Join(options, " ") + "]"
+	}
+	return entry + " " + strings.Join(site.Hosts(), " ")
+}
+
+// updateCrtList 替换 crt-list 中证书路径为 certPath 的条目，entry 为空时删除，没有条目时删除文件
+// 返回文件内容是否变化
+func updateCrtList(path, certPath, entry string) (bool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return false, fmt.Errorf("读取 crt-list 失败: %v", err)
+	}
+
+	var lines []string
+	replaced := false
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		if fields[0] != certPath {
+			lines = append(lines, line)
+			continue
+		}
+		if entry != "" && !replaced {
+			lines = append(lines, entry)
+			replaced = true
+		}
+	}
+	if entry != "" && !replaced {
+		lines = append(lines, entry)
+	}
+
+	if len(lines) == 0 {
+		if data == nil {
+			return false, nil
+		}
+		if err := os.Remove(path); err != nil {
+			return false, fmt.Errorf("删除 crt-list 失败: %v", err)
+		}
+		return true, nil
+	}
+
+	content := []byte(strings.Join(lines, "\n") + "\n")
+	if bytes.Equal(content, data) {
+		return false, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return false, fmt.Errorf("创建证书目录失败: %v", err)
+	}
+	if err := os.WriteFile(path, content, 0644); err != nil {
+		return false, fmt.Errorf("写入 crt-list 失败: %v", err)
+	}
+	return true, nil
+}
+
+// siteCertBundle 将证书链和私钥合并为 crt-list 使用的 PEM 文件内容
+func siteCertBundle(site model.Site) []byte {
+	var buf bytes.Buffer
+	buf.WriteString(strings.TrimSpace(site.Certificate.PublicKey))
+	buf.WriteString("\n")
+	buf.WriteString(strings.TrimSpace(site.Certificate.PrivateKey))
+	buf.WriteString("\n")
+	return buf.Bytes()
+}
+
+// crtListFile 端口 HTTPS 绑定引用的 crt-list 文件
+func (s *HAProxyServiceImpl) crtListFile(port int) string {
+	return filepath.Join(s.CertDir, fmt.Sprintf("p%d.crtlist", port))
+}
+
+// quicCrtListFile 端口 QUIC 绑定引用的 crt-list 文件，只包含开启 HTTP/3 的站点
+func (s *HAProxyServiceImpl) quicCrtListFile(port int) string {
+	return filepath.Join(s.CertDir, fmt.Sprintf("p%d.quic.crtlist", port))
+}
+
+// siteBundleFile 站点在 crt-list 中使用的证书文件
+func (s *HAProxyServiceImpl) siteBundleFile(domain string) string {
+	return filepath.Join(s.CertDir, domain+".pem")
+}
+
+func sameResponseHeaderRule(a, b *models.HTTPResponseRule) bool {
+	return a.Type == b.Type &&
+		strings.EqualFold(a.HdrName, b.HdrName) && a.HdrFormat == b.HdrFormat &&
+		a.Cond == b.Cond && a.CondTest == b.CondTest
+}
@@ -0,0 +1,91 @@
+package haproxy
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/HUAHUAI23/simple-waf/server/model"
+)
+
+func TestCrtListEntry(t *testing.T) {
+	s := &HAProxyServiceImpl{CertDir: "/etc/haproxy/certs"}
+	tests := []struct {
+		name string
+		tls  model.TLSConfig
+		quic bool
+		want string
+	}{
+		{"no options", model.TLSConfig{Profile: model.TLSProfileCustom}, false, "/etc/haproxy/certs/a.com.pem a.com *.a.com b.com"},
+		{"modern", model.TLSConfig{
+			Profile:      model.TLSProfileModern,
+			MinVersion:   model.TLSVersion13,
+			Ciphersuites: "TLS_AES_128_GCM_SHA256:TLS_AES_256_GCM_SHA384",
+			ALPN:         []string{"h2", "http/1.1"},
+			OCSPStapling: true,
+		}, false, "/etc/haproxy/certs/a.com.pem [ssl-min-ver TLSv1.3 ciphersuites TLS_AES_128_GCM_SHA256:TLS_AES_256_GCM_SHA384 alpn h2,http/1.1 ocsp-update on] a.com *.a.com b.com"},
+		{"custom", model.TLSConfig{
+			Profile:    model.TLSProfileCustom,
+			MinVersion: model.TLSVersion11,
+			MaxVersion: model.TLSVersion12,
+			Ciphers:    "ECDHE-RSA-AES128-GCM-SHA256",
+			ALPN:       []string{"http/1.1"},
+		}, false, "/etc/haproxy/certs/a.com.pem [ssl-min-ver TLSv1.1 ssl-max-ver TLSv1.2 ciphers ECDHE-RSA-AES128-GCM-SHA256 alpn http/1.1] a.com *.a.com b.com"},
+		// QUIC 条目只协商 h3，不写协议版本和 TLS 1.2 密码套件
+		{"quic", model.TLSConfig{
+			Profile:      model.TLSProfileCustom,
+			MinVersion:   model.TLSVersion12,
+			Ciphers:      "ECDHE-RSA-AES128-GCM-SHA256",
+			Ciphersuites: "TLS_AES_128_GCM_SHA256",
+			ALPN:         []string{"h2", "http/1.1"},
+		}, true, "/etc/haproxy/certs/a.com.pem [ciphersuites TLS_AES_128_GCM_SHA256 alpn h3] a.com *.a.com b.com"},
+	}
+	for _, tt := range tests {
+		// 证书只用于站点的主域名和别名
+		site := model.Site{Domain: "a.com", Aliases: []string{"*.a.com", "b.com"}, TLS: &tt.tls}
+		if got := s.crtListEntry(site, tt.quic); got != tt.want {
+			t.Errorf("%s: entry =\n%s\nwant\n%s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestUpdateCrtList(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "p443.crtlist")
+	a, b := filepath.Join(dir, "a.com.pem"), filepath.Join(dir, "b.com.pem")
+
+	steps := []struct {
+		certPath, entry string
+		changed         bool
+		want            string // 为空表示文件不存在
+	}{
+		// 删除不存在的文件中的条目不做任何修改
+		{a, "", false, ""},
+		{a, a + " [alpn h2]", true, a + " [alpn h2]\n"},
+		{b, b, true, a + " [alpn h2]\n" + b + "\n"},
+		{b, b, false, a + " [alpn h2]\n" + b + "\n"},
+		// 原位置替换，保持证书顺序
+		{a, a + " [ssl-min-ver TLSv1.3]", true, a + " [ssl-min-ver TLSv1.3]\n" + b + "\n"},
+		{a, "", true, b + "\n"},
+		{b, "", true, ""},
+	}
+	for i, step := range steps {
+		changed, err := updateCrtList(path, step.certPath, step.entry)
+		if err != nil {
+			t.Fatalf("step %d: %v", i, err)
+		}
+		if changed != step.changed {
+			t.Errorf("step %d: changed = %v, want %v", i, changed, step.changed)
+		}
+		data, err := os.ReadFile(path)
+		if step.want == "" {
+			if !os.IsNotExist(err) {
+				t.Errorf("step %d: crt-list exists: %q", i, data)
+			}
+			continue
+		}
+		if string(data) != step.want {
+			t.Errorf("step %d: crt-list =\n%s\nwant\n%s", i, data, step.want)
+		}
+	}
+}
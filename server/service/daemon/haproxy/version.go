@@ -0,0 +1,454 @@
+package haproxy
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/HUAHUAI23/simple-waf/server/utils/diff"
+)
+
+// 配置版本的产生原因
+const (
+	VersionReasonStart    = "start"    // 启动 HAProxy
+	VersionReasonReload   = "reload"   // 提交事务后重载
+	VersionReasonRollback = "rollback" // 回滚到历史版本
+)
+
+// ErrConfigVersionNotFound 配置版本不存在或已被清理
+var ErrConfigVersionNotFound = errors.New("配置版本不存在")
+
+const (
+	versionFilePrefix = "haproxy.cfg."
+	// versionCertSuffix 版本对应的证书目录快照，与版本文件同名加后缀
+	versionCertSuffix = ".certs"
+)
+
+// ConfigVersion 一个已生效的 HAProxy 配置版本
+// 配置引用的 crt-list 和证书文件随版本一起保存，热重载清理证书目录后仍可回滚
+type ConfigVersion struct {
+	Version      int       `json:"version"`                // 版本号，单调递增
+	CreatedAt    time.Time `json:"createdAt"`              // 生效时间
+	Reason       string    `json:"reason"`                 // 产生原因 start/reload/rollback
+	RollbackOf   int       `json:"rollbackOf,omitempty"`   // 回滚的目标版本
+	Checksum     string    `json:"checksum"`               // 配置内容的 sha256
+	CertChecksum string    `json:"certChecksum,omitempty"` // 证书目录内容的 sha256
+	Size         int       `json:"size"`                   // 配置文件大小（字节）
+	Diff         string    `json:"diff,omitempty"`         // 相对上一个版本的统一格式差异
+}
+
+// certFile 证书目录中的文件内容和权限
+type certFile struct {
+	data []byte
+	mode os.FileMode
+}
+
+// ListConfigVersions 列出保留的配置版本，按版本号倒序，不包含差异内容
+func (s *HAProxyServiceImpl) ListConfigVersions() ([]ConfigVersion, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	versions, err := s.readVersions()
+	if err != nil {
+		return nil, err
+	}
+	for i := range versions {
+		versions[i].Diff = ""
+	}
+	slices.Reverse(versions)
+	return versions, nil
+}
+
+// GetConfigVersion 获取配置版本的元数据和完整配置内容
+func (s *HAProxyServiceImpl) GetConfigVersion(version int) (*ConfigVersion, string, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.readVersion(version)
+}
+
+// RollbackConfig 将 haproxy.cfg 和证书目录回滚到指定版本
+// 目标版本先通过 haproxy -c 校验，再以重命名方式替换配置文件；校验失败或 HAProxy 运行中重载失败时恢复原配置
+func (s *HAProxyServiceImpl) RollbackConfig(version int) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	_, content, err := s.readVersion(version)
+	if err != nil {
+		return err
+	}
+
+	current, err := os.ReadFile(s.HAProxyConfigFile)
+	if err != nil {
+		return fmt.Errorf("读取当前配置失败: %v", err)
+	}
+	currentCerts, err := readCertFiles(s.CertDir)
+	if err != nil {
+		return fmt.Errorf("读取当前证书目录失败: %v", err)
+	}
+
+	// 恢复回滚前的配置文件和证书目录
+	restore := func() {
+		if err := writeCertFiles(s.CertDir, currentCerts); err != nil {
+			s.logger.Error().Err(err).Msg("恢复回滚前的证书目录失败")
+		}
+		if err := s.replaceConfig(current, false); err != nil {
+			s.logger.Error().Err(err).Msg("恢复回滚前的配置失败")
+		}
+		s.resetClients()
+	}
+
+	if err := s.restoreVersionCerts(version); err != nil {
+		restore()
+		return fmt.Errorf("回滚到版本 %d 失败: %v", version, err)
+	}
+	if err := s.replaceConfig([]byte(content), true); err != nil {
+		restore()
+		return fmt.Errorf("回滚到版本 %d 失败: %v", version, err)
+	}
+
+	// 回滚后已缓存的配置客户端不再可信，下次使用时重新解析
+	s.resetClients()
+
+	if s.GetStatus() == StatusRunning {
+		if err := s.reloadHAProxy(); err != nil {
+			restore()
+			return fmt.Errorf("回滚到版本 %d 后重载失败，已恢复原配置: %v", version, err)
+		}
+	}
+
+	s.recordVersion(VersionReasonRollback, version)
+	s.logger.Info().Int("version", version).Msg("HAProxy 配置已回滚")
+	return nil
+}
+
+// restoreLatestVersion 将配置文件和证书目录恢复为最近一次生效的版本
+// 已提交的配置校验或重载失败时调用，避免后续的修改和重载基于无效的配置
+func (s *HAProxyServiceImpl) restoreLatestVersion() error {
+	versions, err := s.readVersions()
+	if err != nil {
+		return err
+	}
+	if len(versions) == 0 {
+		return errors.New("没有可恢复的配置版本")
+	}
+	latest := versions[len(versions)-1].Version
+
+	_, content, err := s.readVersion(latest)
+	if err != nil {
+		return err
+	}
+	if err := s.restoreVersionCerts(latest); err != nil {
+		return err
+	}
+	if err := s.replaceConfig([]byte(content), false); err != nil {
+		return err
+	}
+	s.resetClients()
+
+	s.logger.Warn().Int("version", latest).Msg("已恢复到最近生效的 HAProxy 配置版本")
+	return nil
+}
+
+// restoreVersionCerts 将证书目录替换为版本保存的快照，旧版本没有快照时保持证书目录不变
+func (s *HAProxyServiceImpl) restoreVersionCerts(version int) error {
+	dir := s.versionFile(version) + versionCertSuffix
+	if _, err := os.Stat(dir); err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("读取版本 %d 的证书失败: %v", version, err)
+	}
+	files, err := readCertFiles(dir)
+	if err != nil {
+		return fmt.Errorf("读取版本 %d 的证书失败: %v", version, err)
+	}
+	if err := writeCertFiles(s.CertDir, files); err != nil {
+		return fmt.Errorf("恢复版本 %d 的证书失败: %v", version, err)
+	}
+	return nil
+}
+
+// validateConfig 使用 haproxy -c 校验配置文件
+func (s *HAProxyServiceImpl) validateConfig(path string) error {
+	var out bytes.Buffer
+	cmd := exec.Command(s.HaproxyBin, "-W", "-c", "-f", path)
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("HAProxy 配置校验失败: %v: %s", err, strings.TrimSpace(out.String()))
+	}
+	return nil
+}
+
+// replaceConfig 先写入同目录下的临时文件，再重命名覆盖 haproxy.cfg，保证配置文件不会处于半写入状态
+func (s *HAProxyServiceImpl) replaceConfig(content []byte, validate bool) error {
+	tmp, err := os.CreateTemp(filepath.Dir(s.HAProxyConfigFile), "haproxy.cfg.tmp-*")
+	if err != nil {
+		return fmt.Errorf("创建临时配置文件失败: %v", err)
+	}
+	tmpName := tmp.Name()
+	defer os.Remove(tmpName)
+
+	if _, err := tmp.Write(content); err != nil {
+		tmp.Close()
+		return fmt.Errorf("写入临时配置文件失败: %v", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("写入临时配置文件失败: %v", err)
+	}
+	if err := os.Chmod(tmpName, 0644); err != nil {
+		return fmt.Errorf("设置临时配置文件权限失败: %v", err)
+	}
+
+	if validate {
+		if err := s.validateConfig(tmpName); err != nil {
+			return err
+		}
+	}
+
+	if err := os.Rename(tmpName, s.HAProxyConfigFile); err != nil {
+		return fmt.Errorf("替换配置文件失败: %v", err)
+	}
+	return nil
+}
+
+// recordVersion 将当前生效的 haproxy.cfg 和证书目录保存为新版本，内容与最新版本相同时不重复保存
+// 版本记录失败不影响配置生效，只记录日志
+func (s *HAProxyServiceImpl) recordVersion(reason string, rollbackOf int) {
+	if err := s.saveVersion(reason, rollbackOf); err != nil {
+		s.logger.Error().Err(err).Str("reason", reason).Msg("保存 HAProxy 配置版本失败")
+	}
+}
+
+func (s *HAProxyServiceImpl) saveVersion(reason string, rollbackOf int) error {
+	content, err := os.ReadFile(s.HAProxyConfigFile)
+	if err != nil {
+		return fmt.Errorf("读取配置文件失败: %v", err)
+	}
+	if err := os.MkdirAll(s.VersionDir, 0755); err != nil {
+		return fmt.Errorf("创建版本目录失败: %v", err)
+	}
+
+	versions, err := s.readVersions()
+	if err != nil {
+		return err
+	}
+
+	certs, err := readCertFiles(s.CertDir)
+	if err != nil {
+		return fmt.Errorf("读取证书目录失败: %v", err)
+	}
+
+	sum := sha256.Sum256(content)
+	checksum := hex.EncodeToString(sum[:])
+
+	next := ConfigVersion{
+		Version:      1,
+		CreatedAt:    time.Now(),
+		Reason:       reason,
+		RollbackOf:   rollbackOf,
+		Checksum:     checksum,
+		CertChecksum: certChecksum(certs),
+		Size:         len(content),
+	}
+
+	if len(versions) > 0 {
+		latest := versions[len(versions)-1]
+		if latest.Checksum == checksum && latest.CertChecksum == next.CertChecksum {
+			return nil
+		}
+		next.Version = latest.Version + 1
+
+		previous, err := os.ReadFile(s.versionFile(latest.Version))
+		if err != nil {
+			return fmt.Errorf("读取版本 %d 失败: %v", latest.Version, err)
+		}
+		next.Diff = diff.Unified(
+			fmt.Sprintf("haproxy.cfg@%d", latest.Version),
+			fmt.Sprintf("haproxy.cfg@%d", next.Version),
+			string(previous), string(content), diff.DefaultContext,
+		)
+	}
+
+	meta, err := json.MarshalIndent(next, "", "  ")
+	if err != nil {
+		return fmt.Errorf("序列化版本信息失败: %v", err)
+	}
+	if err := writeCertFiles(s.versionFile(next.Version)+versionCertSuffix, certs); err != nil {
+		return fmt.Errorf("写入版本证书失败: %v", err)
+	}
+	if err := os.WriteFile(s.versionFile(next.Version), content, 0644); err != nil {
+		return fmt.Errorf("写入版本文件失败: %v", err)
+	}
+	if err := os.WriteFile(s.versionFile(next.Version)+".json", meta, 0644); err != nil {
+		return fmt.Errorf("写入版本信息失败: %v", err)
+	}
+
+	s.logger.Info().Int("version", next.Version).Str("reason", reason).Msg("HAProxy 配置版本已保存")
+
+	// 只保留最近 BackupsNumber 个版本
+	keep := max(s.BackupsNumber, 1)
+	versions = append(versions, next)
+	for _, v := range versions[:max(len(versions)-keep, 0)] {
+		os.Remove(s.versionFile(v.Version))
+		os.Remove(s.versionFile(v.Version) + ".json")
+		os.RemoveAll(s.versionFile(v.Version) + versionCertSuffix)
+	}
+
+	return nil
+}
+
+// readVersions 读取所有保留的版本元数据，按版本号升序
+func (s *HAProxyServiceImpl) readVersions() ([]ConfigVersion, error) {
+	entries, err := os.ReadDir(s.VersionDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("读取版本目录失败: %v", err)
+	}
+
+	var versions []ConfigVersion
+	for _, entry := range entries {
+		name := entry.Name()
+		if !strings.HasPrefix(name, versionFilePrefix) || !strings.HasSuffix(name, ".json") {
+			continue
+		}
+		number, err := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(name, versionFilePrefix), ".json"))
+		if err != nil {
+			continue
+		}
+		meta, err := s.readVersionMeta(number)
+		if err != nil {
+			s.logger.Warn().Err(err).Str("file", name).Msg("跳过无法解析的配置版本")
+			continue
+		}
+		versions = append(versions, *meta)
+	}
+
+	slices.SortFunc(versions, func(a, b ConfigVersion) int {
+		return a.Version - b.Version
+	})
+	return versions, nil
+}
+
+// readVersion 读取单个版本的元数据和配置内容
+func (s *HAProxyServiceImpl) readVersion(version int) (*ConfigVersion, string, error) {
+	meta, err := s.readVersionMeta(version)
+	if err != nil {
+		return nil, "", err
+	}
+	content, err := os.ReadFile(s.versionFile(version))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, "", ErrConfigVersionNotFound
+		}
+		return nil, "", fmt.Errorf("读取版本 %d 失败: %v", version, err)
+	}
+	return meta, string(content), nil
+}
+
+func (s *HAProxyServiceImpl) readVersionMeta(version int) (*ConfigVersion, error) {
+	data, err := os.ReadFile(s.versionFile(version) + ".json")
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, ErrConfigVersionNotFound
+		}
+		return nil, fmt.Errorf("读取版本 %d 信息失败: %v", version, err)
+	}
+	var meta ConfigVersion
+	if err := json.Unmarshal(data, &meta); err != nil {
+		return nil, fmt.Errorf("解析版本 %d 信息失败: %v", version, err)
+	}
+	return &meta, nil
+}
+
+func (s *HAProxyServiceImpl) versionFile(version int) string {
+	return filepath.Join(s.VersionDir, versionFilePrefix+strconv.Itoa(version))
+}
+
+// readCertFiles 读取目录中的全部文件，目录不存在时返回空
+func readCertFiles(dir string) (map[string]certFile, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	files := make(map[string]certFile, len(entries))
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return nil, err
+		}
+		data, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		files[entry.Name()] = certFile{data: data, mode: info.Mode().Perm()}
+	}
+	return files, nil
+}
+
+// writeCertFiles 将目录内容替换为 files，删除目录中不在 files 里的文件
+func writeCertFiles(dir string, files map[string]certFile) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	existing, err := readCertFiles(dir)
+	if err != nil {
+		return err
+	}
+	for name := range existing {
+		if _, ok := files[name]; !ok {
+			if err := os.Remove(filepath.Join(dir, name)); err != nil {
+				return err
+			}
+		}
+	}
+	for name, file := range files {
+		if old, ok := existing[name]; ok && bytes.Equal(old.data, file.data) && old.mode == file.mode {
+			continue
+		}
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, file.data, file.mode); err != nil {
+			return err
+		}
+		if err := os.Chmod(path, file.mode); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// certChecksum 按文件名顺序计算证书目录内容的 sha256，目录为空时返回空字符串
+func certChecksum(files map[string]certFile) string {
+	if len(files) == 0 {
+		return ""
+	}
+	h := sha256.New()
+	for _, name := range slices.Sorted(maps.Keys(files)) {
+		h.Write([]byte(name))
+		h.Write([]byte{0})
+		h.Write(files[name].data)
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,404 @@
+package incident
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	pkgmodel "github.com/HUAHUAI23/simple-waf/pkg/model"
+	"github.com/HUAHUAI23/simple-waf/server/model"
+	"github.com/HUAHUAI23/simple-waf/server/repository"
+	"github.com/rs/zerolog"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// CorrelatorImpl 安全事件关联器实现
+//
+// WAF 日志、Suricata 告警和 4xx 访问日志分别按 _id 增量扫描，扫描进度保存在 incident_checkpoint 中。
+// 同一来源IP针对同一目标的事件，若与未关闭安全事件的最近事件间隔不超过时间窗口则合并，否则新建安全事件；
+// 已标记为误报或已解决的安全事件不再合并新的事件。4xx 访问日志量大且多为正常的客户端错误，
+// 只合并到已有安全事件，不单独新建安全事件。
+//
+// 每批事件以来源和批次最后一条记录ID作为批次标识写入安全事件，合并时跳过已记录该批次的安全事件，
+// 保存扫描进度前退出时，下次重放同一批次不会重复计数。
+type CorrelatorImpl struct {
+	repo          repository.IncidentRepository
+	wafLogRepo    repository.WAFLogRepository
+	suricataRepo  repository.SuricataRepository
+	accessLogRepo repository.AccessLogRepository
+	window        time.Duration
+	interval      time.Duration
+	logger        zerolog.Logger
+
+	mu      sync.Mutex
+	running bool
+	cancel  context.CancelFunc
+	done    chan struct{}
+}
+
+// event 参与关联的单条事件
+type event struct {
+	source string
+	srcIP  string
+	target string
+	time   time.Time
+	ruleID int
+}
+
+// pending 一次扫描中待写入的安全事件
+type pending struct {
+	incident   *model.Incident // 新建的安全事件，合并到已有安全事件时为 nil
+	id         bson.ObjectID   // 已有安全事件ID
+	windowEnd  time.Time       // 用于判断下一个事件是否仍在窗口内
+	delta      repository.IncidentDelta
+	ruleIDs    map[int]bool
+	signatures map[int]bool
+}
+
+// Start 启动后台关联循环
+func (c *CorrelatorImpl) Start() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.running {
+		return errors.New("incident correlator already running")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	c.cancel = cancel
+	c.done = make(chan struct{})
+	c.running = true
+
+	go c.loop(ctx)
+
+	c.logger.Info().Dur("window", c.window).Dur("interval", c.interval).Msg("安全事件关联器已启动")
+	return nil
+}
+
+// Stop 停止后台关联循环并等待当前扫描结束
+func (c *CorrelatorImpl) Stop() error {
+	c.mu.Lock()
+	if !c.running {
+		c.mu.Unlock()
+		return nil
+	}
+	c.cancel()
+	done := c.done
+	c.running = false
+	c.mu.Unlock()
+
+	<-done
+	c.logger.Info().Msg("安全事件关联器已停止")
+	return nil
+}
+
+func (c *CorrelatorImpl) loop(ctx context.Context) {
+	defer close(c.done)
+
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := c.Correlate(ctx); err != nil && ctx.Err() == nil {
+				c.logger.Error().Err(err).Msg("关联安全事件失败")
+			}
+		}
+	}
+}
+
+// Correlate 扫描上次进度之后写入的事件并关联为安全事件
+func (c *CorrelatorImpl) Correlate(ctx context.Context) error {
+	if err := c.scan(ctx, model.IncidentSourceWAF, c.fetchWAF); err != nil {
+		return err
+	}
+	if err := c.scan(ctx, model.IncidentSourceIDS, c.fetchIDS); err != nil {
+		return err
+	}
+	// 访问日志只合并到已有安全事件，放在最后扫描，以便先创建本轮 WAF 和 IDS 事件对应的安全事件
+	return c.scan(ctx, model.IncidentSourceAccess, c.fetchAccess)
+}
+
+// fetchFunc 读取 afterID 之后的一批记录，返回参与关联的事件、批次最后一条记录ID和读取的记录数
+type fetchFunc func(ctx context.Context, afterID bson.ObjectID) ([]event, bson.ObjectID, int, error)
+
+func (c *CorrelatorImpl) scan(ctx context.Context, source string, fetch fetchFunc) error {
+	lastID, err := c.repo.GetCheckpoint(ctx, source)
+	if err != nil {
+		return err
+	}
+	if lastID.IsZero() {
+		// 首次运行只关联最近一个时间窗口内的事件
+		lastID = bson.NewObjectIDFromTimestamp(time.Now().Add(-c.window))
+	}
+
+	for {
+		events, nextID, n, err := fetch(ctx, lastID)
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			return nil
+		}
+
+		if err := c.apply(ctx, source+":"+nextID.Hex(), events); err != nil {
+			return err
+		}
+		if err := c.repo.SaveCheckpoint(ctx, source, nextID); err != nil {
+			return err
+		}
+		lastID = nextID
+
+		if n < batchSize {
+			return nil
+		}
+	}
+}
+
+func (c *CorrelatorImpl) fetchWAF(ctx context.Context, afterID bson.ObjectID) ([]event, bson.ObjectID, int, error) {
+	logs, err := c.wafLogRepo.FindAttackLogsAfterID(ctx, afterID, batchSize)
+	if err != nil || len(logs) == 0 {
+		return nil, afterID, 0, err
+	}
+
+	events := make([]event, 0, len(logs))
+	for i := range logs {
+		log := &logs[i]
+		if log.SrcIP == "" {
+			continue
+		}
+		events = append(events, event{
+			source: model.IncidentSourceWAF,
+			srcIP:  log.SrcIP,
+			target: WAFTarget(log),
+			time:   log.CreatedAt,
+			ruleID: log.RuleID,
+		})
+	}
+	return events, logs[len(logs)-1].ID, len(logs), nil
+}
+
+func (c *CorrelatorImpl) fetchIDS(ctx context.Context, afterID bson.ObjectID) ([]event, bson.ObjectID, int, error) {
+	records, err := c.suricataRepo.FindEventsAfterID(ctx, afterID, batchSize)
+	if err != nil || len(records) == 0 {
+		return nil, afterID, 0, err
+	}
+
+	events := make([]event, 0, len(records))
+	for i := range records {
+		record := &records[i]
+		// 只关联告警事件，http/tls/dns/flow 等元数据事件在时间线中不单独展示
+		if record.SignatureID == 0 || record.SrcIP == "" {
+			continue
+		}
+		events = append(events, event{
+			source: model.IncidentSourceIDS,
+			srcIP:  record.SrcIP,
+			target: IDSTarget(record),
+			time:   record.Timestamp,
+			ruleID: record.SignatureID,
+		})
+	}
+	return events, records[len(records)-1].ID, len(records), nil
+}
+
+func (c *CorrelatorImpl) fetchAccess(ctx context.Context, afterID bson.ObjectID) ([]event, bson.ObjectID, int, error) {
+	logs, err := c.accessLogRepo.FindClientErrorsAfterID(ctx, afterID, batchSize)
+	if err != nil || len(logs) == 0 {
+		return nil, afterID, 0, err
+	}
+
+	events := make([]event, 0, len(logs))
+	for i := range logs {
+		log := &logs[i]
+		target := AccessTarget(log)
+		if log.ClientIP == "" || target == "" {
+			continue
+		}
+		events = append(events, event{
+			source: model.IncidentSourceAccess,
+			srcIP:  log.ClientIP,
+			target: target,
+			time:   log.Timestamp,
+		})
+	}
+	return events, logs[len(logs)-1].ID, len(logs), nil
+}
+
+// apply 按来源IP和目标分组，组内按时间顺序合并到安全事件，batch 为本批事件的批次标识
+func (c *CorrelatorImpl) apply(ctx context.Context, batch string, events []event) error {
+	groups := make(map[[2]string][]event)
+	for _, e := range events {
+		key := [2]string{e.srcIP, e.target}
+		groups[key] = append(groups[key], e)
+	}
+
+	for key, group := range groups {
+		sort.Slice(group, func(i, j int) bool { return group[i].time.Before(group[j].time) })
+
+		var current *pending
+		for i, e := range group {
+			// 组内事件按时间升序，第一个事件窗口内没有未关闭的安全事件时，后续事件也不会有
+			if i == 0 {
+				existing, err := c.repo.FindActive(ctx, key[0], key[1], e.time.Add(-c.window))
+				if err != nil {
+					return err
+				}
+				if existing != nil {
+					current = newPending(nil, existing.ID, existing.LastSeen)
+				}
+			}
+
+			if current != nil && e.time.After(current.windowEnd.Add(c.window)) {
+				if err := c.flush(ctx, batch, current); err != nil {
+					return err
+				}
+				current = nil
+			}
+
+			if current == nil {
+				if e.source == model.IncidentSourceAccess {
+					continue
+				}
+				current = newPending(&model.Incident{
+					SrcIP:  e.srcIP,
+					Target: e.target,
+					Status: model.IncidentStatusOpen,
+				}, bson.ObjectID{}, e.time)
+			}
+			current.add(e)
+		}
+
+		if err := c.flush(ctx, batch, current); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *CorrelatorImpl) flush(ctx context.Context, batch string, p *pending) error {
+	// 已有安全事件在本批中没有合并到任何事件时无需更新
+	if p == nil || p.delta.FirstSeen.IsZero() {
+		return nil
+	}
+	p.delta.RuleIDs = sortedKeys(p.ruleIDs)
+	p.delta.SignatureIDs = sortedKeys(p.signatures)
+
+	if p.incident == nil {
+		return c.repo.MergeEvents(ctx, p.id, batch, p.delta)
+	}
+
+	incident := p.incident
+	incident.FirstSeen = p.delta.FirstSeen
+	incident.LastSeen = p.delta.LastSeen
+	incident.WAFEvents = p.delta.WAFEvents
+	incident.IDSEvents = p.delta.IDSEvents
+	incident.AccessEvents = p.delta.AccessEvents
+	incident.RuleIDs = p.delta.RuleIDs
+	incident.SignatureIDs = p.delta.SignatureIDs
+	created, err := c.repo.Create(ctx, incident, batch)
+	if err != nil || !created {
+		return err
+	}
+
+	c.logger.Info().
+		Str("id", incident.ID.Hex()).
+		Str("srcIp", incident.SrcIP).
+		Str("target", incident.Target).
+		Msg("新建安全事件")
+	return nil
+}
+
+func newPending(incident *model.Incident, id bson.ObjectID, windowEnd time.Time) *pending {
+	return &pending{
+		incident:   incident,
+		id:         id,
+		windowEnd:  windowEnd,
+		ruleIDs:    make(map[int]bool),
+		signatures: make(map[int]bool),
+	}
+}
+
+func (p *pending) add(e event) {
+	if p.delta.FirstSeen.IsZero() || e.time.Before(p.delta.FirstSeen) {
+		p.delta.FirstSeen = e.time
+	}
+	if e.time.After(p.delta.LastSeen) {
+		p.delta.LastSeen = e.time
+	}
+	if e.time.After(p.windowEnd) {
+		p.windowEnd = e.time
+	}
+
+	switch e.source {
+	case model.IncidentSourceWAF:
+		p.delta.WAFEvents++
+		if e.ruleID != 0 {
+			p.ruleIDs[e.ruleID] = true
+		}
+	case model.IncidentSourceIDS:
+		p.delta.IDSEvents++
+		p.signatures[e.ruleID] = true
+	case model.IncidentSourceAccess:
+		p.delta.AccessEvents++
+	}
+}
+
+func sortedKeys(m map[int]bool) []int {
+	if len(m) == 0 {
+		return nil
+	}
+	keys := make([]int, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Ints(keys)
+	return keys
+}
+
+// WAFTarget 返回 WAF 日志的关联目标，优先使用站点域名
+func WAFTarget(log *pkgmodel.WAFLog) string {
+	if host := normalizeHost(log.Domain); host != "" {
+		return host
+	}
+	return log.DstIP
+}
+
+// IDSTarget 返回 Suricata 告警的关联目标，优先使用 HTTP Host 和 TLS SNI，以便与 WAF 日志的站点域名对齐
+func IDSTarget(record *model.SuricataEvent) string {
+	if record.HTTP != nil {
+		if host := normalizeHost(record.HTTP.Hostname); host != "" {
+			return host
+		}
+	}
+	if record.TLS != nil {
+		if host := normalizeHost(record.TLS.SNI); host != "" {
+			return host
+		}
+	}
+	return record.DstIP
+}
+
+// AccessTarget 返回访问日志的关联目标，优先使用站点主域名，与 WAF 日志的站点域名对齐
+func AccessTarget(log *model.AccessLog) string {
+	if host := normalizeHost(log.Domain); host != "" {
+		return host
+	}
+	return normalizeHost(log.Host)
+}
+
+// normalizeHost 转为小写并去掉端口
+func normalizeHost(host string) string {
+	host = strings.ToLower(strings.TrimSpace(host))
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return strings.Trim(host, "[]")
+}
@@ -0,0 +1,152 @@
+package incident
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/HUAHUAI23/simple-waf/server/model"
+	"github.com/HUAHUAI23/simple-waf/server/repository"
+	"github.com/rs/zerolog"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// fakeIncidentRepo 内存中的安全事件仓库，按 MongoIncidentRepository 的批次语义去重
+type fakeIncidentRepo struct {
+	repository.IncidentRepository
+	incidents []*model.Incident
+}
+
+func (r *fakeIncidentRepo) Create(ctx context.Context, incident *model.Incident, batch string) (bool, error) {
+	for _, existing := range r.incidents {
+		if existing.SrcIP == incident.SrcIP && existing.Target == incident.Target &&
+			existing.FirstSeen.Equal(incident.FirstSeen) && slices.Contains(existing.Batches, batch) {
+			return false, nil
+		}
+	}
+	incident.ID = bson.NewObjectID()
+	incident.Batches = []string{batch}
+	r.incidents = append(r.incidents, incident)
+	return true, nil
+}
+
+func (r *fakeIncidentRepo) FindActive(ctx context.Context, srcIP, target string, since time.Time) (*model.Incident, error) {
+	var found *model.Incident
+	for _, incident := range r.incidents {
+		if incident.SrcIP == srcIP && incident.Target == target && incident.Status.IsActive() &&
+			!incident.LastSeen.Before(since) && (found == nil || incident.LastSeen.After(found.LastSeen)) {
+			found = incident
+		}
+	}
+	if found == nil {
+		return nil, nil
+	}
+	copied := *found
+	return &copied, nil
+}
+
+func (r *fakeIncidentRepo) MergeEvents(ctx context.Context, id bson.ObjectID, batch string, delta repository.IncidentDelta) error {
+	for _, incident := range r.incidents {
+		if incident.ID != id || slices.Contains(incident.Batches, batch) {
+			continue
+		}
+		incident.WAFEvents += delta.WAFEvents
+		incident.IDSEvents += delta.IDSEvents
+		incident.AccessEvents += delta.AccessEvents
+		if delta.FirstSeen.Before(incident.FirstSeen) {
+			incident.FirstSeen = delta.FirstSeen
+		}
+		if delta.LastSeen.After(incident.LastSeen) {
+			incident.LastSeen = delta.LastSeen
+		}
+		incident.Batches = append(incident.Batches, batch)
+	}
+	return nil
+}
+
+func newTestCorrelator(repo repository.IncidentRepository) *CorrelatorImpl {
+	return &CorrelatorImpl{repo: repo, window: 30 * time.Minute, logger: zerolog.Nop()}
+}
+
+func TestApplyReplayedBatch(t *testing.T) {
+	repo := &fakeIncidentRepo{}
+	c := newTestCorrelator(repo)
+	ctx := context.Background()
+	start := time.Date(2024, 3, 18, 8, 0, 0, 0, time.UTC)
+
+	first := []event{
+		{source: model.IncidentSourceWAF, srcIP: "1.2.3.4", target: "example.com", time: start, ruleID: 942100},
+		{source: model.IncidentSourceWAF, srcIP: "1.2.3.4", target: "example.com", time: start.Add(time.Minute), ruleID: 942100},
+	}
+	second := []event{
+		{source: model.IncidentSourceIDS, srcIP: "1.2.3.4", target: "example.com", time: start.Add(2 * time.Minute), ruleID: 2010935},
+	}
+
+	// 第二批合并后在保存扫描进度前退出，重启后两批都会被重放
+	for _, batch := range []struct {
+		id     string
+		events []event
+	}{{"waf:1", first}, {"ids:1", second}, {"waf:1", first}, {"ids:1", second}} {
+		if err := c.apply(ctx, batch.id, batch.events); err != nil {
+			t.Fatalf("apply %s: %v", batch.id, err)
+		}
+	}
+
+	if len(repo.incidents) != 1 {
+		t.Fatalf("incidents = %d, want 1", len(repo.incidents))
+	}
+	incident := repo.incidents[0]
+	if incident.WAFEvents != 2 || incident.IDSEvents != 1 {
+		t.Errorf("events = waf %d ids %d, want waf 2 ids 1", incident.WAFEvents, incident.IDSEvents)
+	}
+	if !incident.FirstSeen.Equal(start) || !incident.LastSeen.Equal(start.Add(2*time.Minute)) {
+		t.Errorf("seen = %s - %s", incident.FirstSeen, incident.LastSeen)
+	}
+}
+
+func TestApplyAccessEventsOnlyMerge(t *testing.T) {
+	repo := &fakeIncidentRepo{}
+	c := newTestCorrelator(repo)
+	ctx := context.Background()
+	start := time.Date(2024, 3, 18, 8, 0, 0, 0, time.UTC)
+
+	// 没有安全事件时 4xx 访问日志不新建安全事件
+	scan := []event{
+		{source: model.IncidentSourceAccess, srcIP: "1.2.3.4", target: "example.com", time: start},
+		{source: model.IncidentSourceAccess, srcIP: "1.2.3.4", target: "example.com", time: start.Add(time.Second)},
+	}
+	if err := c.apply(ctx, "access:1", scan); err != nil {
+		t.Fatalf("apply: %v", err)
+	}
+	if len(repo.incidents) != 0 {
+		t.Fatalf("incidents = %d, want 0", len(repo.incidents))
+	}
+
+	attack := []event{{source: model.IncidentSourceWAF, srcIP: "1.2.3.4", target: "example.com", time: start.Add(time.Minute), ruleID: 930120}}
+	if err := c.apply(ctx, "waf:1", attack); err != nil {
+		t.Fatalf("apply: %v", err)
+	}
+
+	// 窗口内的访问日志合并到已有安全事件，超出窗口的不再合并
+	access := []event{
+		{source: model.IncidentSourceAccess, srcIP: "1.2.3.4", target: "example.com", time: start.Add(2 * time.Minute)},
+		{source: model.IncidentSourceAccess, srcIP: "1.2.3.4", target: "example.com", time: start.Add(3 * time.Minute)},
+		{source: model.IncidentSourceAccess, srcIP: "1.2.3.4", target: "example.com", time: start.Add(2 * time.Hour)},
+		{source: model.IncidentSourceAccess, srcIP: "5.6.7.8", target: "example.com", time: start.Add(2 * time.Minute)},
+	}
+	if err := c.apply(ctx, "access:2", access); err != nil {
+		t.Fatalf("apply: %v", err)
+	}
+
+	if len(repo.incidents) != 1 {
+		t.Fatalf("incidents = %d, want 1", len(repo.incidents))
+	}
+	incident := repo.incidents[0]
+	if incident.WAFEvents != 1 || incident.AccessEvents != 2 {
+		t.Errorf("events = waf %d access %d, want waf 1 access 2", incident.WAFEvents, incident.AccessEvents)
+	}
+	if !incident.LastSeen.Equal(start.Add(3 * time.Minute)) {
+		t.Errorf("lastSeen = %s, want %s", incident.LastSeen, start.Add(3*time.Minute))
+	}
+}
@@ -0,0 +1,45 @@
+package incident
+
+import (
+	"context"
+	"time"
+
+	"github.com/HUAHUAI23/simple-waf/server/config"
+	"github.com/HUAHUAI23/simple-waf/server/repository"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+// Correlator 周期性扫描新写入的 WAF 日志、Suricata 告警和 4xx 访问日志，按来源IP、目标和时间窗口关联为安全事件
+type Correlator interface {
+	Start() error
+	Stop() error
+	Correlate(ctx context.Context) error
+}
+
+const (
+	defaultWindow   = 30 * time.Minute
+	defaultInterval = 30 * time.Second
+	batchSize       = 1000
+)
+
+// NewCorrelator 创建安全事件关联器，window 为同一安全事件内相邻事件的最大间隔
+func NewCorrelator(db *mongo.Database, window, interval time.Duration) Correlator {
+	if window <= 0 {
+		window = defaultWindow
+	}
+	if interval <= 0 {
+		interval = defaultInterval
+	}
+
+	logger := config.GetLogger().With().Str("component", "incident").Logger()
+
+	return &CorrelatorImpl{
+		repo:          repository.NewIncidentRepository(db),
+		wafLogRepo:    repository.NewWAFLogRepository(db),
+		suricataRepo:  repository.NewSuricataRepository(db),
+		accessLogRepo: repository.NewAccessLogRepository(db),
+		window:        window,
+		interval:      interval,
+		logger:        logger,
+	}
+}
@@ -0,0 +1,220 @@
+package retention
+
+import (
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+const (
+	archiveExt        = ".ndjson.gz"
+	archiveTimeLayout = "20060102T150405Z"
+	// RestoredSuffix 恢复数据写入的集合后缀，避免被原集合的 TTL 索引立即删除
+	RestoredSuffix = "_restored"
+)
+
+// timeFields 各集合用于 TTL 过期和归档分段的时间字段
+var timeFields = map[string]string{
+	"waf_log":         "createdAt",
+	"suricata_events": "timestamp",
+	"alert_history":   "createdAt",
+	"audit_log":       "createdAt",
+	"access_log":      "timestamp",
+}
+
+// TimeField 返回集合的时间字段，不支持的集合返回 false
+func TimeField(collection string) (string, bool) {
+	field, ok := timeFields[collection]
+	return field, ok
+}
+
+// ArchiveInfo 归档文件信息
+type ArchiveInfo struct {
+	Collection string
+	Name       string
+	From       time.Time
+	To         time.Time
+	Size       int64
+}
+
+// archiveName 归档文件名：<collection>-<from>-<to>.ndjson.gz，时间为 UTC
+func archiveName(collection string, from, to time.Time) string {
+	return fmt.Sprintf("%s-%s-%s%s", collection,
+		from.UTC().Format(archiveTimeLayout), to.UTC().Format(archiveTimeLayout), archiveExt)
+}
+
+// parseArchiveName 解析归档文件名，返回覆盖的时间范围
+func parseArchiveName(collection, name string) (time.Time, time.Time, bool) {
+	rest, ok := strings.CutPrefix(name, collection+"-")
+	if !ok {
+		return time.Time{}, time.Time{}, false
+	}
+	rest, ok = strings.CutSuffix(rest, archiveExt)
+	if !ok {
+		return time.Time{}, time.Time{}, false
+	}
+	fromStr, toStr, ok := strings.Cut(rest, "-")
+	if !ok {
+		return time.Time{}, time.Time{}, false
+	}
+	from, err := time.Parse(archiveTimeLayout, fromStr)
+	if err != nil {
+		return time.Time{}, time.Time{}, false
+	}
+	to, err := time.Parse(archiveTimeLayout, toStr)
+	if err != nil {
+		return time.Time{}, time.Time{}, false
+	}
+	return from, to, true
+}
+
+// ListArchives 列出集合在归档目录下的归档文件，目录不存在时返回空列表
+func ListArchives(dir, collection string) ([]ArchiveInfo, error) {
+	entries, err := os.ReadDir(filepath.Join(dir, collection))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var archives []ArchiveInfo
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		from, to, ok := parseArchiveName(collection, entry.Name())
+		if !ok {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		archives = append(archives, ArchiveInfo{
+			Collection: collection,
+			Name:       entry.Name(),
+			From:       from,
+			To:         to,
+			Size:       info.Size(),
+		})
+	}
+	return archives, nil
+}
+
+// ArchivePath 返回归档文件的完整路径，文件名不合法时返回 false
+func ArchivePath(dir, collection, name string) (string, bool) {
+	if name != filepath.Base(name) {
+		return "", false
+	}
+	if _, _, ok := parseArchiveName(collection, name); !ok {
+		return "", false
+	}
+	return filepath.Join(dir, collection, name), true
+}
+
+// ReadArchive 逐行读取归档文件，每行为一个 canonical Extended JSON 文档
+func ReadArchive(path string, fn func(bson.D) error) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	gz, err := gzip.NewReader(file)
+	if err != nil {
+		return err
+	}
+	defer gz.Close()
+
+	scanner := bufio.NewScanner(gz)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+		var doc bson.D
+		if err := bson.UnmarshalExtJSON(line, true, &doc); err != nil {
+			return err
+		}
+		if err := fn(doc); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+// archiveWriter 将文档写入临时文件，Commit 时原子重命名为最终归档文件
+type archiveWriter struct {
+	path  string
+	file  *os.File
+	gz    *gzip.Writer
+	buf   *bufio.Writer
+	count int64
+}
+
+func newArchiveWriter(path string) (*archiveWriter, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+	file, err := os.CreateTemp(filepath.Dir(path), ".archive-*.tmp")
+	if err != nil {
+		return nil, err
+	}
+	gz := gzip.NewWriter(file)
+	return &archiveWriter{
+		path: path,
+		file: file,
+		gz:   gz,
+		buf:  bufio.NewWriter(gz),
+	}, nil
+}
+
+func (w *archiveWriter) Write(doc bson.Raw) error {
+	line, err := bson.MarshalExtJSON(doc, true, false)
+	if err != nil {
+		return err
+	}
+	if _, err := w.buf.Write(line); err != nil {
+		return err
+	}
+	if err := w.buf.WriteByte('\n'); err != nil {
+		return err
+	}
+	w.count++
+	return nil
+}
+
+// Commit 刷新并同步数据后将临时文件重命名为归档文件
+func (w *archiveWriter) Commit() error {
+	if err := w.buf.Flush(); err != nil {
+		w.Abort()
+		return err
+	}
+	if err := w.gz.Close(); err != nil {
+		w.Abort()
+		return err
+	}
+	if err := w.file.Sync(); err != nil {
+		w.Abort()
+		return err
+	}
+	if err := w.file.Close(); err != nil {
+		os.Remove(w.file.Name())
+		return err
+	}
+	return os.Rename(w.file.Name(), w.path)
+}
+
+// Abort 丢弃临时文件
+func (w *archiveWriter) Abort() {
+	w.file.Close()
+	os.Remove(w.file.Name())
+}
@@ -0,0 +1,123 @@
+package retention
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+func TestArchiveNameRoundTrip(t *testing.T) {
+	from := time.Date(2024, 3, 17, 8, 0, 0, 0, time.FixedZone("CST", 8*3600))
+	to := time.Date(2024, 3, 18, 0, 0, 0, 0, time.UTC)
+
+	name := archiveName("waf_log", from, to)
+	if want := "waf_log-20240317T000000Z-20240318T000000Z.ndjson.gz"; name != want {
+		t.Fatalf("archiveName = %q, want %q", name, want)
+	}
+	gotFrom, gotTo, ok := parseArchiveName("waf_log", name)
+	if !ok || !gotFrom.Equal(from) || !gotTo.Equal(to) {
+		t.Errorf("parseArchiveName(%q) = %s, %s, %v", name, gotFrom, gotTo, ok)
+	}
+}
+
+func TestParseArchiveNameInvalid(t *testing.T) {
+	tests := []struct {
+		collection string
+		name       string
+	}{
+		{"waf_log", "audit_log-20240317T000000Z-20240318T000000Z.ndjson.gz"},
+		{"waf_log", "waf_log-20240317T000000Z-20240318T000000Z.ndjson"},
+		{"waf_log", "waf_log-20240317T000000Z.ndjson.gz"},
+		{"waf_log", "waf_log-20240317-20240318.ndjson.gz"},
+		{"waf_log", "waf_log-20240317T000000Z-../../etc.ndjson.gz"},
+		{"waf_log", ".archive-123.tmp"},
+		{"audit", "audit_log-20240317T000000Z-20240318T000000Z.ndjson.gz"},
+	}
+	for _, tt := range tests {
+		if _, _, ok := parseArchiveName(tt.collection, tt.name); ok {
+			t.Errorf("parseArchiveName(%q, %q) accepted an invalid name", tt.collection, tt.name)
+		}
+	}
+}
+
+func TestArchivePath(t *testing.T) {
+	dir := "/var/lib/simple-waf/archive"
+	valid := "waf_log-20240317T000000Z-20240318T000000Z.ndjson.gz"
+
+	path, ok := ArchivePath(dir, "waf_log", valid)
+	if !ok || path != filepath.Join(dir, "waf_log", valid) {
+		t.Errorf("ArchivePath(%q) = %q, %v", valid, path, ok)
+	}
+
+	for _, name := range []string{
+		"../" + valid,
+		"../../waf_log/" + valid,
+		"sub/" + valid,
+		"/etc/" + valid,
+		"..",
+		".",
+		"",
+		"waf_log-20240317T000000Z-20240318T000000Z.ndjson.gz/..",
+	} {
+		if path, ok := ArchivePath(dir, "waf_log", name); ok {
+			t.Errorf("ArchivePath(%q) = %q, want rejection", name, path)
+		}
+	}
+}
+
+func TestArchiveWriteListRead(t *testing.T) {
+	dir := t.TempDir()
+	from := time.Date(2024, 3, 17, 0, 0, 0, 0, time.UTC)
+	name := archiveName("audit_log", from, from.Add(24*time.Hour))
+
+	w, err := newArchiveWriter(filepath.Join(dir, "audit_log", name))
+	if err != nil {
+		t.Fatalf("newArchiveWriter: %v", err)
+	}
+	for i := range 3 {
+		doc, err := bson.Marshal(bson.D{{Key: "seq", Value: int32(i)}, {Key: "createdAt", Value: from}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := w.Write(doc); err != nil {
+			t.Fatalf("Write: %v", err)
+		}
+	}
+	if err := w.Commit(); err != nil {
+		t.Fatalf("Commit: %v", err)
+	}
+
+	// 未提交的临时文件和其他集合的文件不出现在列表中
+	if err := os.WriteFile(filepath.Join(dir, "audit_log", ".archive-1.tmp"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	archives, err := ListArchives(dir, "audit_log")
+	if err != nil {
+		t.Fatalf("ListArchives: %v", err)
+	}
+	if len(archives) != 1 || archives[0].Name != name || !archives[0].From.Equal(from) || archives[0].Size == 0 {
+		t.Fatalf("archives = %+v", archives)
+	}
+	if archives, err := ListArchives(dir, "waf_log"); err != nil || len(archives) != 0 {
+		t.Errorf("ListArchives(missing dir) = %+v, %v", archives, err)
+	}
+
+	var seqs []int32
+	err = ReadArchive(filepath.Join(dir, "audit_log", name), func(doc bson.D) error {
+		for _, e := range doc {
+			if e.Key == "seq" {
+				seqs = append(seqs, e.Value.(int32))
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ReadArchive: %v", err)
+	}
+	if len(seqs) != 3 || seqs[0] != 0 || seqs[2] != 2 {
+		t.Errorf("seqs = %v, want [0 1 2]", seqs)
+	}
+}
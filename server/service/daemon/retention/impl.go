@@ -0,0 +1,242 @@
+package retention
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/HUAHUAI23/simple-waf/pkg/model"
+	"github.com/HUAHUAI23/simple-waf/server/repository"
+	"github.com/rs/zerolog"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// ManagerImpl 日志保留管理器实现
+//
+// 每个周期读取保留配置：开启归档的集合先把即将过期的文档按时间段写入压缩的 NDJSON 文件，
+// 再同步 TTL 索引的过期时间。归档比过期提前 min(24h, 保留期/2)，归档进度记录在
+// retention_checkpoint 集合中，重启后从上次的位置继续。
+type ManagerImpl struct {
+	configRepo    repository.ConfigRepository
+	retentionRepo repository.RetentionRepository
+	interval      time.Duration
+	logger        zerolog.Logger
+
+	mu      sync.Mutex
+	running bool
+	cancel  context.CancelFunc
+	done    chan struct{}
+}
+
+// Start 启动保留管理协程，启动后立即执行一次
+func (m *ManagerImpl) Start() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.running {
+		return errors.New("retention manager already running")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	m.cancel = cancel
+	m.done = make(chan struct{})
+	m.running = true
+
+	go m.loop(ctx)
+
+	m.logger.Info().Msg("日志保留管理器已启动")
+	return nil
+}
+
+// Stop 停止保留管理协程，正在写入的归档文件会被丢弃
+func (m *ManagerImpl) Stop() error {
+	m.mu.Lock()
+	if !m.running {
+		m.mu.Unlock()
+		return nil
+	}
+	m.cancel()
+	done := m.done
+	m.running = false
+	m.mu.Unlock()
+
+	<-done
+	m.logger.Info().Msg("日志保留管理器已停止")
+	return nil
+}
+
+func (m *ManagerImpl) loop(ctx context.Context) {
+	defer close(m.done)
+
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+
+	for {
+		if err := m.run(ctx); err != nil && ctx.Err() == nil {
+			m.logger.Error().Err(err).Msg("执行日志保留策略失败")
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (m *ManagerImpl) run(ctx context.Context) error {
+	cfg, err := m.configRepo.GetConfig(ctx)
+	if err != nil {
+		return err
+	}
+
+	policies := cfg.Retention.Policies
+	// 访问日志量远大于其他日志，升级前创建的配置没有对应策略时使用默认保留天数
+	if !hasPolicy(policies, "access_log") {
+		policies = append(policies, model.RetentionPolicy{Collection: "access_log", Days: defaultAccessLogDays})
+	}
+
+	for _, policy := range policies {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		m.apply(ctx, cfg.Retention.ArchiveDir, policy)
+	}
+	return nil
+}
+
+func hasPolicy(policies []model.RetentionPolicy, collection string) bool {
+	for _, policy := range policies {
+		if policy.Collection == collection {
+			return true
+		}
+	}
+	return false
+}
+
+// apply 执行单个集合的保留策略，先归档再同步 TTL 索引
+//
+// 开启归档时 TTL 索引不会删除尚未归档的文档：归档失败且没有任何进度时不同步 TTL 索引，
+// 归档进度落后于过期时间时按落后的时长延长 TTL，待归档追上后在后续周期恢复为保留期。
+func (m *ManagerImpl) apply(ctx context.Context, archiveDir string, policy model.RetentionPolicy) {
+	field, ok := TimeField(policy.Collection)
+	if !ok {
+		m.logger.Warn().Str("collection", policy.Collection).Msg("不支持的保留策略集合")
+		return
+	}
+
+	retention := time.Duration(policy.Days) * 24 * time.Hour
+	expireAfter := retention
+
+	if policy.Archive && policy.Days > 0 && archiveDir != "" {
+		archivedUntil, err := m.archive(ctx, archiveDir, policy.Collection, field, retention)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			m.logger.Error().Err(err).Str("collection", policy.Collection).Msg("归档集合失败")
+			if archivedUntil.IsZero() {
+				m.logger.Warn().Str("collection", policy.Collection).Msg("集合尚未归档，暂不同步 TTL 索引")
+				return
+			}
+		}
+		if lag := time.Since(archivedUntil); !archivedUntil.IsZero() && lag > expireAfter {
+			expireAfter = lag.Truncate(time.Hour) + time.Hour
+			m.logger.Warn().
+				Str("collection", policy.Collection).
+				Time("archivedUntil", archivedUntil).
+				Dur("expireAfter", expireAfter).
+				Msg("归档进度落后于过期时间，延长 TTL 索引的过期时间")
+		}
+	}
+
+	if err := m.retentionRepo.EnsureTTLIndex(ctx, policy.Collection, field, expireAfter); err != nil && ctx.Err() == nil {
+		m.logger.Error().Err(err).Str("collection", policy.Collection).Msg("同步 TTL 索引失败")
+	}
+}
+
+// archive 将 [归档进度, 过期时间 + 提前量) 范围内的文档按时间段写入归档文件
+// 返回已归档到的时间点，出错时返回出错前的进度；集合为空且从未归档时返回零值
+func (m *ManagerImpl) archive(ctx context.Context, dir, collection, field string, retention time.Duration) (time.Time, error) {
+	lead := retention / 2
+	if lead > maxArchiveLead {
+		lead = maxArchiveLead
+	}
+	until := time.Now().UTC().Add(-retention + lead).Truncate(time.Hour)
+
+	from, err := m.retentionRepo.GetArchiveCheckpoint(ctx, collection)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if from.IsZero() {
+		oldest, err := m.retentionRepo.GetOldestTime(ctx, collection, field)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if oldest.IsZero() {
+			return time.Time{}, nil
+		}
+		// 最早的文档之前没有需要归档的数据
+		from = oldest.Truncate(time.Hour)
+	}
+	from = from.UTC()
+
+	for from.Before(until) {
+		if ctx.Err() != nil {
+			return from, ctx.Err()
+		}
+
+		to := from.Add(archiveChunk)
+		if to.After(until) {
+			to = until
+		}
+
+		count, err := m.archiveRange(ctx, dir, collection, field, from, to)
+		if err != nil {
+			return from, err
+		}
+		if err := m.retentionRepo.SetArchiveCheckpoint(ctx, collection, to); err != nil {
+			return from, err
+		}
+		if count > 0 {
+			m.logger.Info().
+				Str("collection", collection).
+				Time("from", from).
+				Time("to", to).
+				Int64("count", count).
+				Msg("已归档文档")
+		}
+		from = to
+	}
+	return from, nil
+}
+
+// archiveRange 归档单个时间段，没有文档时不生成文件
+func (m *ManagerImpl) archiveRange(ctx context.Context, dir, collection, field string, from, to time.Time) (int64, error) {
+	path := filepath.Join(dir, collection, archiveName(collection, from, to))
+
+	var writer *archiveWriter
+	err := m.retentionRepo.StreamRange(ctx, collection, field, from, to, func(doc bson.Raw) error {
+		if writer == nil {
+			w, err := newArchiveWriter(path)
+			if err != nil {
+				return err
+			}
+			writer = w
+		}
+		return writer.Write(doc)
+	})
+	if writer == nil {
+		return 0, err
+	}
+	if err != nil {
+		writer.Abort()
+		return 0, err
+	}
+	if err := writer.Commit(); err != nil {
+		return 0, err
+	}
+	return writer.count, nil
+}
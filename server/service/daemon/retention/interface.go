@@ -0,0 +1,37 @@
+package retention
+
+import (
+	"time"
+
+	"github.com/HUAHUAI23/simple-waf/server/config"
+	"github.com/HUAHUAI23/simple-waf/server/repository"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+// Manager 按保留策略维护 TTL 索引，并在文档过期前将其归档到本地磁盘
+type Manager interface {
+	Start() error
+	Stop() error
+}
+
+const (
+	defaultInterval = 10 * time.Minute
+	// maxArchiveLead 归档相对过期时间的最大提前量
+	maxArchiveLead = 24 * time.Hour
+	// archiveChunk 单个归档文件覆盖的最大时间跨度
+	archiveChunk = 24 * time.Hour
+	// defaultAccessLogDays 未配置访问日志保留策略时的保留天数
+	defaultAccessLogDays = 7
+)
+
+// NewManager 创建日志保留管理器，保留配置从配置集合中读取，修改后在下一个周期生效
+func NewManager(db *mongo.Database) Manager {
+	logger := config.GetLogger().With().Str("component", "retention").Logger()
+
+	return &ManagerImpl{
+		configRepo:    repository.NewConfigRepository(db),
+		retentionRepo: repository.NewRetentionRepository(db),
+		interval:      defaultInterval,
+		logger:        logger,
+	}
+}
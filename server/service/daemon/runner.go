@@ -13,6 +13,7 @@ import (
 	"github.com/HUAHUAI23/simple-waf/server/repository"
 	"github.com/HUAHUAI23/simple-waf/server/service/daemon/engine"
 	"github.com/HUAHUAI23/simple-waf/server/service/daemon/haproxy"
+	"github.com/HUAHUAI23/simple-waf/server/service/daemon/suricata"
 	"github.com/rs/zerolog"
 )
 
@@ -24,6 +25,20 @@ const (
 	ServiceError
 )
 
+// String 返回服务状态的字符串表示
+func (s ServiceState) String() string {
+	switch s {
+	case ServiceRunning:
+		return "running"
+	case ServiceStopped:
+		return "stopped"
+	case ServiceError:
+		return "error"
+	default:
+		return "unknown"
+	}
+}
+
 type ServiceRunner interface {
 	StartServices() error
 	StopServices() error
@@ -31,12 +46,20 @@ type ServiceRunner interface {
 	Restart() error
 	HotReload() error
 	GetState() ServiceState
+	SyncBlocklist(entries map[string]string) error
+	ListConfigVersions() ([]haproxy.ConfigVersion, error)
+	GetConfigVersion(version int) (*haproxy.ConfigVersion, string, error)
+	RollbackConfig(version int) error
+	GetSiteHealth(site model.Site) ([]haproxy.ServerHealth, error)
+	GetStats(sites []model.Site) (*haproxy.RuntimeStats, error)
+	ApplySite(site model.Site) error
 }
 
 // ServiceRunner 负责管理和协调所有后台服务
 type ServiceRunnerImpl struct {
 	haproxyService haproxy.HAProxyService
 	engineService  engine.EngineService
+	suricataClient *suricata.Client
 	ctx            context.Context
 	cancel         context.CancelFunc
 	logger         *zerolog.Logger
@@ -44,6 +67,19 @@ type ServiceRunnerImpl struct {
 	haproxyDone    chan struct{} // 通知HAProxy服务已停止
 	engineDone     chan struct{} // 通知Engine服务已停止
 	state          ServiceState
+
+	// mu 串行化对 HAProxy 配置的修改，保护 appliedSites 和 appliedSettings
+	// 热重载、单站点应用、回滚和启动时的站点写入可能来自不同的请求和协程
+	mu              sync.Mutex
+	appliedSites    map[string]model.Site // 已写入 HAProxy 配置的站点，按站点ID索引
+	appliedSettings *haproxySettings      // 最近一次全量重建时的应用设置
+}
+
+// haproxySettings 影响 HAProxy 全局配置和 SPOE 配置的应用设置，变化时只能全量重建
+type haproxySettings struct {
+	thread        int
+	responseCheck bool
+	debug         bool
 }
 
 // 单例模式实现
@@ -82,6 +118,7 @@ func newServiceRunner() (ServiceRunner, error) {
 	return &ServiceRunnerImpl{
 		haproxyService: haproxyService,
 		engineService:  engineService,
+		suricataClient: suricata.NewClient(config.Global.Suricata.Socket, 2*time.Minute),
 		logger:         &logger,
 		state:          ServiceStopped,
 	}, nil
@@ -156,19 +193,20 @@ func (r *ServiceRunnerImpl) StartServices() error {
 			return
 		}
 
-		for i, site := range siteList {
-			if err := r.haproxyService.AddSiteConfig(site); err != nil {
-				r.logger.Error().Err(err).Msgf("添加站点配置失败 %d", i)
-				// 继续处理其他站点，不返回错误
-			}
-		}
-
-		if err := r.haproxyService.Start(); err != nil {
+		// 启动时 HAProxy 服务尚未从数据库加载全部应用设置，首次热重载总是全量重建
+		r.mu.Lock()
+		r.appliedSites = r.addSites(siteList)
+		r.appliedSettings = nil
+		err = r.haproxyService.Start()
+		r.mu.Unlock()
+		if err != nil {
 			r.logger.Error().Err(err).Msg("HAProxy服务启动失败")
 			r.errChan <- err
 			return
 		}
 
+		r.syncSuricataCapture(siteList)
+
 		// 等待停止信号
 		<-r.ctx.Done()
 		r.logger.Info().Msg("收到停止信号，停止HAProxy服务")
@@ -288,6 +326,9 @@ func (r *ServiceRunnerImpl) StopServices() error {
 	r.errChan = nil
 	r.haproxyDone = nil
 	r.engineDone = nil
+	r.mu.Lock()
+	r.appliedSites = nil
+	r.mu.Unlock()
 
 	// 更新状态
 	r.state = ServiceStopped
@@ -336,6 +377,9 @@ func (r *ServiceRunnerImpl) ForceStop() {
 	r.errChan = nil
 	r.haproxyDone = nil
 	r.engineDone = nil
+	r.mu.Lock()
+	r.appliedSites = nil
+	r.mu.Unlock()
 
 	// 更新状态
 	r.state = ServiceStopped
@@ -357,20 +401,35 @@ func (r *ServiceRunnerImpl) Restart() error {
 	return r.StartServices()
 }
 
+// HotReload 热重载站点配置
+//
+// 应用设置未变化时逐个站点比较差异，只修改变化的部分，服务器列表变化通过运行时 API 生效；
+// 只有配置结构变化时才重载 HAProxy。应用设置变化或增量同步失败时删除配置全量重建。
 func (r *ServiceRunnerImpl) HotReload() error {
 	// 检查服务是否正在运行
 	if r.state != ServiceRunning {
 		return fmt.Errorf("服务未在运行中，无法热重载")
 	}
-	r.logger.Info().Msg("开始热重载...")
 
-	// // reload haproxy config
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.hotReload()
+}
 
-	// 进行HAProxy服务重置
-	if err := r.haproxyService.Reset(); err != nil {
-		r.logger.Error().Err(err).Msg("重置HAProxy服务失败")
+// hotReload 执行热重载，调用方需持有 r.mu
+func (r *ServiceRunnerImpl) hotReload() error {
+	r.logger.Info().Msg("开始热重载...")
+
+	appConfig, err := config.GetAppConfig()
+	if err != nil {
+		r.logger.Error().Err(err).Msg("获取应用配置失败")
 		return err
 	}
+	settings := haproxySettings{
+		thread:        appConfig.Haproxy.Thread,
+		responseCheck: appConfig.IsResponseCheck,
+		debug:         appConfig.IsDebug,
+	}
 
 	client, err := mongodb.Connect(config.Global.DBConfig.URI)
 	if err != nil {
@@ -388,53 +447,258 @@ func (r *ServiceRunnerImpl) HotReload() error {
 		return err
 	}
 
+	incremental := false
+	if r.appliedSites != nil && r.appliedSettings != nil && *r.appliedSettings == settings {
+		if err := r.updateSites(siteList); err != nil {
+			r.logger.Warn().Err(err).Msg("增量同步站点配置失败，改为全量重建")
+		} else {
+			incremental = true
+		}
+	}
+
+	if !incremental {
+		if err := r.rebuildHAProxy(siteList); err != nil {
+			return err
+		}
+		r.appliedSettings = &settings
+	}
+
+	r.syncSuricataCapture(siteList)
+
+	// reload engine config
+
+	if err := r.engineService.Reload(); err != nil {
+		r.logger.Error().Err(err).Msg("热加载Engine配置失败")
+		return err
+	}
+
+	r.logger.Info().Bool("incremental", incremental).Msg("热重载成功")
+
+	return nil
+}
+
+// rebuildHAProxy 删除 HAProxy 配置后按站点列表全量重建并重载，调用方需持有 r.mu
+func (r *ServiceRunnerImpl) rebuildHAProxy(siteList []model.Site) error {
+	// 进行HAProxy服务重置
+	if err := r.haproxyService.Reset(); err != nil {
+		r.logger.Error().Err(err).Msg("重置HAProxy服务失败")
+		return err
+	}
+
 	r.logger.Info().Msg("开始热加载HAProxy配置...")
-	if err = r.haproxyService.HotReloadRemoveConfig(); err != nil {
+	if err := r.haproxyService.HotReloadRemoveConfig(); err != nil {
 		r.logger.Error().Err(err).Msg("热加载删除HAProxy配置失败")
 		return err
 	}
+	// 配置已删除，重建失败时下次热重载也必须全量重建
+	r.appliedSites = nil
 
-	if err = r.haproxyService.InitSpoeConfig(); err != nil {
+	if err := r.haproxyService.InitSpoeConfig(); err != nil {
 		r.logger.Error().Err(err).Msg("初始化HAProxy SPOE配置失败")
 		return err
 	}
 
-	if err = r.haproxyService.InitHAProxyConfig(); err != nil {
+	if err := r.haproxyService.InitHAProxyConfig(); err != nil {
 		r.logger.Error().Err(err).Msg("初始化HAProxy配置失败")
 		return err
 	}
 
-	if err = r.haproxyService.AddCorazaBackend(); err != nil {
+	if err := r.haproxyService.AddCorazaBackend(); err != nil {
 		r.logger.Error().Err(err).Msg("添加Coraza后端失败")
 		return err
 	}
 
-	if err = r.haproxyService.CreateHAProxyCrtStore(); err != nil {
+	if err := r.haproxyService.CreateHAProxyCrtStore(); err != nil {
 		r.logger.Error().Err(err).Msg("创建HAProxy证书存储失败")
 		return err
 	}
 
+	applied := r.addSites(siteList)
+
+	if err := r.haproxyService.Reload(); err != nil {
+		r.logger.Error().Err(err).Msg("热加载HAProxy配置失败")
+		return err
+	}
+
+	r.appliedSites = applied
+	return nil
+}
+
+// addSites 将站点逐个写入 HAProxy 配置，返回成功写入的激活站点
+func (r *ServiceRunnerImpl) addSites(siteList []model.Site) map[string]model.Site {
+	applied := make(map[string]model.Site, len(siteList))
 	for i, site := range siteList {
 		if err := r.haproxyService.AddSiteConfig(site); err != nil {
 			r.logger.Error().Err(err).Msgf("添加站点配置失败 %d", i)
 			// 继续处理其他站点，不返回错误
+			continue
+		}
+		if site.ActiveStatus {
+			applied[site.ID.Hex()] = site
 		}
 	}
+	return applied
+}
 
-	if err := r.haproxyService.Reload(); err != nil {
-		r.logger.Error().Err(err).Msg("热加载HAProxy配置失败")
+// updateSites 按站点差异增量更新 HAProxy 配置，只在配置结构变化时重载，调用方需持有 r.mu
+//
+// 已删除、停用或修改了域名、端口和站点类型的站点先删除旧配置，再逐个同步当前激活的站点。
+// 无效的站点与全量重建一样跳过。
+func (r *ServiceRunnerImpl) updateSites(siteList []model.Site) error {
+	desired := make(map[string]model.Site, len(siteList))
+	for i, site := range siteList {
+		if !site.ActiveStatus {
+			continue
+		}
+		if err := model.ValidateSite(&site); err != nil {
+			r.logger.Error().Err(err).Msgf("站点配置无效 %d", i)
+			continue
+		}
+		desired[site.ID.Hex()] = site
+	}
+
+	needReload := false
+	for id, old := range r.appliedSites {
+		if site, ok := desired[id]; ok && site.Domain == old.Domain && site.ListenPort == old.ListenPort && site.IsTCP() == old.IsTCP() {
+			continue
+		}
+		reload, err := r.haproxyService.RemoveSiteConfig(old)
+		if err != nil {
+			return fmt.Errorf("删除站点配置 %s 失败: %w", old.Domain, err)
+		}
+		delete(r.appliedSites, id)
+		needReload = needReload || reload
+	}
+
+	for _, item := range siteList {
+		site, ok := desired[item.ID.Hex()]
+		if !ok {
+			continue
+		}
+		reload, err := r.haproxyService.UpdateSiteConfig(site)
+		if err != nil {
+			return fmt.Errorf("同步站点配置 %s 失败: %w", site.Domain, err)
+		}
+		r.appliedSites[site.ID.Hex()] = site
+		needReload = needReload || reload
+	}
+
+	if needReload {
+		if err := r.haproxyService.Reload(); err != nil {
+			return fmt.Errorf("热加载HAProxy配置失败: %w", err)
+		}
+	} else {
+		r.logger.Info().Msg("站点配置没有需要重载的变化，跳过 HAProxy 重载")
+	}
+
+	return nil
+}
+
+// syncSuricataCapture 根据站点监听端口更新 Suricata 抓包过滤和端口组，并通过命令套接字重新加载
+// 端口组随 reload-rules 生效，BPF 过滤由 Suricata 容器的入口脚本检测到文件变化后重启 Suricata 生效
+// Suricata 不可用时只记录日志，不影响 WAF 的启动和热重载
+func (r *ServiceRunnerImpl) syncSuricataCapture(siteList []model.Site) {
+	ports := suricata.SitePorts(siteList)
+	changed, err := suricata.WriteCaptureConfig(config.Global.Suricata.ConfigDir, ports)
+	if err != nil {
+		r.logger.Error().Err(err).Str("dir", config.Global.Suricata.ConfigDir).Msg("写入 Suricata 抓包过滤配置失败")
+		return
+	}
+	if !changed {
+		return
+	}
+
+	r.logger.Info().Ints("ports", ports).Str("bpf", suricata.BuildBPF(ports)).Msg("Suricata 抓包端口已更新")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+	if err := r.suricataClient.ReloadRules(ctx); err != nil {
+		r.logger.Warn().Err(err).Msg("通知 Suricata 重新加载失败")
+	}
+}
+
+// SyncBlocklist 同步IP封禁列表到 HAProxy，HAProxy 未运行时只写入 map 文件，启动后自动加载
+func (r *ServiceRunnerImpl) SyncBlocklist(entries map[string]string) error {
+	return r.haproxyService.SyncBlocklist(entries)
+}
+
+// ListConfigVersions 列出保留的 HAProxy 配置版本
+func (r *ServiceRunnerImpl) ListConfigVersions() ([]haproxy.ConfigVersion, error) {
+	return r.haproxyService.ListConfigVersions()
+}
+
+// GetConfigVersion 获取 HAProxy 配置版本详情
+func (r *ServiceRunnerImpl) GetConfigVersion(version int) (*haproxy.ConfigVersion, string, error) {
+	return r.haproxyService.GetConfigVersion(version)
+}
+
+// RollbackConfig 回滚 HAProxy 配置到指定版本
+// 回滚后的配置与数据库中的站点不再一致，下一次热重载会按数据库全量重建配置
+func (r *ServiceRunnerImpl) RollbackConfig(version int) error {
+	if r.state != ServiceRunning {
+		return fmt.Errorf("服务未在运行中，无法回滚配置")
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := r.haproxyService.RollbackConfig(version); err != nil {
 		return err
 	}
+	r.appliedSettings = nil
+	r.appliedSites = nil
+	return nil
+}
 
-	// reload engine config
+// GetSiteHealth 获取站点后端服务器的实时健康状态
+func (r *ServiceRunnerImpl) GetSiteHealth(site model.Site) ([]haproxy.ServerHealth, error) {
+	if r.state != ServiceRunning {
+		return nil, fmt.Errorf("服务未在运行中，无法获取服务器状态")
+	}
+	return r.haproxyService.GetSiteHealth(site)
+}
 
-	if err := r.engineService.Reload(); err != nil {
-		r.logger.Error().Err(err).Msg("热加载Engine配置失败")
+// GetStats 获取 HAProxy 进程、端口前端和站点的实时统计
+func (r *ServiceRunnerImpl) GetStats(sites []model.Site) (*haproxy.RuntimeStats, error) {
+	if r.state != ServiceRunning {
+		return nil, fmt.Errorf("服务未在运行中，无法获取运行时统计")
+	}
+	return r.haproxyService.GetStats(sites)
+}
+
+// ApplySite 立即把单个站点的当前配置同步到运行中的 HAProxy，服务器权重和管理状态的变化通过运行时 API 生效
+// 站点尚未写入配置、域名、端口或站点类型变化、站点停用以及配置需要全量重建时改为执行一次热重载
+func (r *ServiceRunnerImpl) ApplySite(site model.Site) error {
+	if r.state != ServiceRunning {
+		return fmt.Errorf("服务未在运行中，无法应用站点配置")
+	}
+
+	// 与热重载串行执行，避免并发修改配置和已应用站点
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	id := site.ID.Hex()
+	old, ok := r.appliedSites[id]
+	if !ok || !site.ActiveStatus || old.Domain != site.Domain || old.ListenPort != site.ListenPort || old.IsTCP() != site.IsTCP() {
+		return r.hotReload()
+	}
+	if err := model.ValidateSite(&site); err != nil {
 		return err
 	}
 
-	r.logger.Info().Msg("热重载成功")
+	reload, err := r.haproxyService.UpdateSiteConfig(site)
+	if err != nil {
+		return fmt.Errorf("同步站点配置 %s 失败: %w", site.Domain, err)
+	}
+	r.appliedSites[id] = site
 
+	if reload {
+		if err := r.haproxyService.Reload(); err != nil {
+			// 重载失败时配置已恢复到最近生效的版本，与已应用站点不再一致，下次热重载全量重建
+			r.appliedSites = nil
+			return fmt.Errorf("热加载HAProxy配置失败: %w", err)
+		}
+	}
+	r.logger.Info().Str("domain", site.Domain).Bool("reload", reload).Msg("站点配置已应用")
 	return nil
 }
 
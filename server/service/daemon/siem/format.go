@@ -0,0 +1,223 @@
+package siem
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	pkgmodel "github.com/HUAHUAI23/simple-waf/pkg/model"
+	"github.com/HUAHUAI23/simple-waf/server/model"
+)
+
+const (
+	deviceVendor  = "Simple-WAF"
+	deviceVersion = "1.0"
+	productWAF    = "WAF"
+	productIDS    = "IDS"
+)
+
+// Event 归一化后的安全事件
+//
+// 字段映射：
+//
+//	Event 字段    WAF (waf_log)      IDS (suricata_events)   CEF            LEEF
+//	SignatureID   ruleId             signature_id            头部 SignatureID 头部 EventID
+//	Name          message            msg                     头部 Name        msg
+//	Severity      severity 映射      severity 映射           头部 Severity    sev
+//	SrcIP         srcIp              src_ip                  src            src
+//	SrcPort       srcPort            src_port                spt            srcPort
+//	DstIP         dstIp              dst_ip                  dst            dst
+//	DstPort       dstPort            dst_port                dpt            dstPort
+//	Proto         TCP                proto                   proto          proto
+//	Host          domain             -                       dhost          dstHost
+//	URI           uri                -                       request        url
+//	Action        blocked            alert                   act            action
+//	RequestID     requestId          -                       externalId     externalId
+//	Time          createdAt          timestamp               rt             devTime
+//
+// Severity 统一映射到 CEF 的 0-10（10 最严重）：WAF 使用 Coraza 的 0(EMERGENCY)-7(DEBUG)，
+// IDS 使用 Suricata 的优先级 1(高)-3(低)。
+type Event struct {
+	Product     string
+	SignatureID int
+	Name        string
+	Severity    int
+	SrcIP       string
+	SrcPort     int
+	DstIP       string
+	DstPort     int
+	Proto       string
+	Host        string
+	URI         string
+	Action      string
+	RequestID   string
+	Time        time.Time
+}
+
+// FromWAFLog 将 WAF 日志转换为归一化事件
+func FromWAFLog(log pkgmodel.WAFLog) Event {
+	name := log.Message
+	if name == "" {
+		name = "WAF rule " + strconv.Itoa(log.RuleID)
+	}
+	return Event{
+		Product:     productWAF,
+		SignatureID: log.RuleID,
+		Name:        name,
+		Severity:    wafSeverity(log.Severity),
+		SrcIP:       log.SrcIP,
+		SrcPort:     log.SrcPort,
+		DstIP:       log.DstIP,
+		DstPort:     log.DstPort,
+		Proto:       "TCP",
+		Host:        log.Domain,
+		URI:         log.URI,
+		Action:      "blocked",
+		RequestID:   log.RequestID,
+		Time:        log.CreatedAt,
+	}
+}
+
+// FromSuricataEvent 将 Suricata 事件转换为归一化事件
+func FromSuricataEvent(event model.SuricataEvent) Event {
+	return Event{
+		Product:     productIDS,
+		SignatureID: event.SignatureID,
+		Name:        event.Msg,
+		Severity:    idsSeverity(event.Severity),
+		SrcIP:       event.SrcIP,
+		SrcPort:     event.SrcPort,
+		DstIP:       event.DstIP,
+		DstPort:     event.DstPort,
+		Proto:       event.Proto,
+		Action:      "alert",
+		Time:        event.Timestamp,
+	}
+}
+
+// wafSeverity Coraza 严重级别 0-7 映射为 CEF 0-10
+func wafSeverity(severity int) int {
+	levels := []int{10, 9, 8, 7, 5, 3, 2, 1}
+	if severity < 0 || severity >= len(levels) {
+		return 5
+	}
+	return levels[severity]
+}
+
+// idsSeverity Suricata 优先级 1-3 映射为 CEF 0-10
+func idsSeverity(severity string) int {
+	switch strings.TrimSpace(severity) {
+	case "1":
+		return 9
+	case "2":
+		return 6
+	case "3":
+		return 3
+	default:
+		return 5
+	}
+}
+
+// syslogSeverity 将 CEF 严重级别映射为 syslog 严重级别
+func syslogSeverity(severity int) int {
+	switch {
+	case severity >= 9:
+		return 2 // critical
+	case severity >= 7:
+		return 3 // error
+	case severity >= 4:
+		return 4 // warning
+	default:
+		return 5 // notice
+	}
+}
+
+// FormatCEF 按 ArcSight CEF:0 格式编码事件
+func FormatCEF(e Event) string {
+	var ext []string
+	add := func(key, value string) {
+		if value != "" {
+			ext = append(ext, key+"="+escapeCEFValue(value))
+		}
+	}
+	addInt := func(key string, value int) {
+		if value != 0 {
+			ext = append(ext, key+"="+strconv.Itoa(value))
+		}
+	}
+
+	if !e.Time.IsZero() {
+		add("rt", strconv.FormatInt(e.Time.UnixMilli(), 10))
+	}
+	add("src", e.SrcIP)
+	addInt("spt", e.SrcPort)
+	add("dst", e.DstIP)
+	addInt("dpt", e.DstPort)
+	add("proto", e.Proto)
+	add("dhost", e.Host)
+	add("request", e.URI)
+	add("act", e.Action)
+	add("externalId", e.RequestID)
+
+	return fmt.Sprintf("CEF:0|%s|%s|%s|%d|%s|%d|%s",
+		escapeCEFHeader(deviceVendor),
+		escapeCEFHeader(e.Product),
+		escapeCEFHeader(deviceVersion),
+		e.SignatureID,
+		escapeCEFHeader(e.Name),
+		e.Severity,
+		strings.Join(ext, " "),
+	)
+}
+
+// FormatLEEF 按 IBM QRadar LEEF:1.0 格式编码事件，属性以 tab 分隔
+func FormatLEEF(e Event) string {
+	var attrs []string
+	add := func(key, value string) {
+		if value != "" {
+			attrs = append(attrs, key+"="+escapeLEEFValue(value))
+		}
+	}
+	addInt := func(key string, value int) {
+		if value != 0 {
+			attrs = append(attrs, key+"="+strconv.Itoa(value))
+		}
+	}
+
+	if !e.Time.IsZero() {
+		// 未指定 devTimeFormat 时 devTime 按毫秒时间戳解析
+		add("devTime", strconv.FormatInt(e.Time.UnixMilli(), 10))
+	}
+	addInt("sev", e.Severity)
+	add("src", e.SrcIP)
+	addInt("srcPort", e.SrcPort)
+	add("dst", e.DstIP)
+	addInt("dstPort", e.DstPort)
+	add("proto", e.Proto)
+	add("dstHost", e.Host)
+	add("url", e.URI)
+	add("action", e.Action)
+	add("externalId", e.RequestID)
+	add("msg", e.Name)
+
+	return fmt.Sprintf("LEEF:1.0|%s|%s|%s|%d|%s",
+		escapeLEEFHeader(deviceVendor),
+		escapeLEEFHeader(e.Product),
+		escapeLEEFHeader(deviceVersion),
+		e.SignatureID,
+		strings.Join(attrs, "\t"),
+	)
+}
+
+var (
+	cefHeaderEscaper  = strings.NewReplacer(`\`, `\\`, `|`, `\|`, "\r", " ", "\n", " ")
+	cefValueEscaper   = strings.NewReplacer(`\`, `\\`, `=`, `\=`, "\r\n", `\n`, "\n", `\n`, "\r", `\r`)
+	leefHeaderEscaper = strings.NewReplacer(`|`, `\|`, "\r", " ", "\n", " ")
+	leefValueEscaper  = strings.NewReplacer("\t", " ", "\r", " ", "\n", " ")
+)
+
+func escapeCEFHeader(s string) string  { return cefHeaderEscaper.Replace(s) }
+func escapeCEFValue(s string) string   { return cefValueEscaper.Replace(s) }
+func escapeLEEFHeader(s string) string { return leefHeaderEscaper.Replace(s) }
+func escapeLEEFValue(s string) string  { return leefValueEscaper.Replace(s) }
@@ -0,0 +1,112 @@
+package siem
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	pkgmodel "github.com/HUAHUAI23/simple-waf/pkg/model"
+)
+
+func TestFormatCEF(t *testing.T) {
+	e := Event{
+		Product:     productWAF,
+		SignatureID: 942100,
+		Name:        "SQL Injection | via libinjection",
+		Severity:    8,
+		SrcIP:       "192.168.1.100",
+		SrcPort:     52134,
+		DstIP:       "10.0.0.1",
+		DstPort:     443,
+		Proto:       "TCP",
+		Host:        "example.com",
+		URI:         `/search?q=a=b\c`,
+		Action:      "blocked",
+		RequestID:   "a1b2c3",
+		Time:        time.UnixMilli(1710662400123),
+	}
+
+	want := `CEF:0|Simple-WAF|WAF|1.0|942100|SQL Injection \| via libinjection|8|` +
+		`rt=1710662400123 src=192.168.1.100 spt=52134 dst=10.0.0.1 dpt=443 proto=TCP dhost=example.com ` +
+		`request=/search?q\=a\=b\\c act=blocked externalId=a1b2c3`
+	if got := FormatCEF(e); got != want {
+		t.Errorf("FormatCEF =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestFormatCEFOmitsEmptyFields(t *testing.T) {
+	got := FormatCEF(Event{Product: productIDS, SignatureID: 2010935, Name: "ET SCAN", Severity: 6, SrcIP: "1.2.3.4"})
+	if want := "CEF:0|Simple-WAF|IDS|1.0|2010935|ET SCAN|6|src=1.2.3.4"; got != want {
+		t.Errorf("FormatCEF = %q, want %q", got, want)
+	}
+}
+
+func TestFormatLEEF(t *testing.T) {
+	e := Event{
+		Product:     productIDS,
+		SignatureID: 2010935,
+		Name:        "ET SCAN\tSuspicious\r\nInbound",
+		Severity:    9,
+		SrcIP:       "1.2.3.4",
+		SrcPort:     40000,
+		DstIP:       "10.0.0.1",
+		DstPort:     22,
+		Proto:       "TCP",
+		Action:      "alert",
+		Time:        time.UnixMilli(1710662400000),
+	}
+
+	want := "LEEF:1.0|Simple-WAF|IDS|1.0|2010935|" + strings.Join([]string{
+		"devTime=1710662400000", "sev=9", "src=1.2.3.4", "srcPort=40000", "dst=10.0.0.1",
+		"dstPort=22", "proto=TCP", "action=alert", "msg=ET SCAN Suspicious  Inbound",
+	}, "\t")
+	if got := FormatLEEF(e); got != want {
+		t.Errorf("FormatLEEF =\n%q\nwant\n%q", got, want)
+	}
+}
+
+func TestEscape(t *testing.T) {
+	tests := []struct {
+		name   string
+		escape func(string) string
+		in     string
+		want   string
+	}{
+		{"cef header pipe and backslash", escapeCEFHeader, `a|b\c`, `a\|b\\c`},
+		{"cef header newline", escapeCEFHeader, "a\r\nb", "a  b"},
+		{"cef value equals", escapeCEFValue, `k=v`, `k\=v`},
+		{"cef value backslash", escapeCEFValue, `C:\tmp`, `C:\\tmp`},
+		{"cef value newlines", escapeCEFValue, "a\r\nb\nc\rd", `a\nb\nc\rd`},
+		{"cef value keeps pipe", escapeCEFValue, "a|b", "a|b"},
+		{"leef header pipe", escapeLEEFHeader, "a|b\nc", `a\|b c`},
+		{"leef value tab", escapeLEEFValue, "a\tb\r\nc", "a b  c"},
+		{"leef value keeps equals", escapeLEEFValue, "a=b", "a=b"},
+	}
+	for _, tt := range tests {
+		if got := tt.escape(tt.in); got != tt.want {
+			t.Errorf("%s: escape(%q) = %q, want %q", tt.name, tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestSeverityMapping(t *testing.T) {
+	wafTests := map[int]int{0: 10, 2: 8, 4: 5, 7: 1, -1: 5, 8: 5}
+	for in, want := range wafTests {
+		if got := wafSeverity(in); got != want {
+			t.Errorf("wafSeverity(%d) = %d, want %d", in, got, want)
+		}
+	}
+	idsTests := map[string]int{"1": 9, " 2 ": 6, "3": 3, "": 5, "4": 5}
+	for in, want := range idsTests {
+		if got := idsSeverity(in); got != want {
+			t.Errorf("idsSeverity(%q) = %d, want %d", in, got, want)
+		}
+	}
+}
+
+func TestFromWAFLogDefaultName(t *testing.T) {
+	e := FromWAFLog(pkgmodel.WAFLog{RuleID: 930120, Severity: 2})
+	if e.Name != "WAF rule 930120" || e.Severity != 8 || e.Product != productWAF {
+		t.Errorf("FromWAFLog = %+v", e)
+	}
+}
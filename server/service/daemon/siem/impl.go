@@ -0,0 +1,273 @@
+package siem
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/HUAHUAI23/simple-waf/pkg/model"
+	servermodel "github.com/HUAHUAI23/simple-waf/server/model"
+	"github.com/HUAHUAI23/simple-waf/server/repository"
+	"github.com/rs/zerolog"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// ForwarderImpl SIEM 转发器实现
+//
+// 转发器按 _id 增量轮询 waf_log 和 suricata_events，启用时从当前最新事件之后开始转发，
+// 不回放历史数据。发送队列已满时停止推进游标，待发送器恢复后从 MongoDB 继续读取。
+// 游标记录已放入发送队列的位置，发送器关闭时回退到最后一条写入成功的事件，
+// 配置变化或停止时队列中未发送的事件会在下次发送时重新读取。
+type ForwarderImpl struct {
+	configRepo   repository.ConfigRepository
+	wafLogRepo   repository.WAFLogRepository
+	suricataRepo repository.SuricataRepository
+	interval     time.Duration
+	batchSize    int64
+	logger       zerolog.Logger
+
+	mu      sync.Mutex
+	running bool
+	cancel  context.CancelFunc
+	done    chan struct{}
+
+	// 以下字段仅在轮询协程中访问
+	current   model.SIEMConfig
+	sender    *syslogSender
+	wafCursor *bson.ObjectID
+	idsCursor *bson.ObjectID
+	wafSent   *bson.ObjectID
+	idsSent   *bson.ObjectID
+}
+
+// Start 启动转发协程
+func (f *ForwarderImpl) Start() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.running {
+		return errors.New("siem forwarder already running")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	f.cancel = cancel
+	f.done = make(chan struct{})
+	f.running = true
+
+	go f.loop(ctx)
+
+	f.logger.Info().Msg("SIEM 转发器已启动")
+	return nil
+}
+
+// Stop 停止转发协程并关闭发送器
+func (f *ForwarderImpl) Stop() error {
+	f.mu.Lock()
+	if !f.running {
+		f.mu.Unlock()
+		return nil
+	}
+	f.cancel()
+	done := f.done
+	f.running = false
+	f.mu.Unlock()
+
+	<-done
+	f.logger.Info().Msg("SIEM 转发器已停止")
+	return nil
+}
+
+func (f *ForwarderImpl) loop(ctx context.Context) {
+	defer close(f.done)
+	defer f.closeSender()
+
+	ticker := time.NewTicker(f.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := f.poll(ctx); err != nil && ctx.Err() == nil {
+				f.logger.Error().Err(err).Msg("SIEM 事件转发失败")
+			}
+		}
+	}
+}
+
+func (f *ForwarderImpl) poll(ctx context.Context) error {
+	cfg, err := f.configRepo.GetConfig(ctx)
+	if err != nil {
+		return err
+	}
+	f.applyConfig(normalizeConfig(cfg.SIEM))
+
+	if f.sender == nil {
+		return nil
+	}
+
+	if f.current.ForwardWAF {
+		if err := f.forwardWAF(ctx); err != nil {
+			return err
+		}
+	} else {
+		f.wafCursor, f.wafSent = nil, nil
+	}
+
+	if f.current.ForwardIDS {
+		if err := f.forwardIDS(ctx); err != nil {
+			return err
+		}
+	} else {
+		f.idsCursor, f.idsSent = nil, nil
+	}
+
+	return nil
+}
+
+// applyConfig 配置变化时重建发送器，关闭转发时重置游标
+func (f *ForwarderImpl) applyConfig(cfg model.SIEMConfig) {
+	if cfg == f.current && (f.sender != nil || !cfg.Enabled) {
+		return
+	}
+
+	f.closeSender()
+	f.current = cfg
+
+	if !cfg.Enabled || cfg.Address == "" {
+		f.wafCursor, f.wafSent = nil, nil
+		f.idsCursor, f.idsSent = nil, nil
+		return
+	}
+
+	f.sender = newSyslogSender(cfg, f.logger)
+	f.sender.Start()
+	f.logger.Info().
+		Str("format", cfg.Format).
+		Str("protocol", cfg.Protocol).
+		Str("address", cfg.Address).
+		Msg("SIEM 转发配置已生效")
+}
+
+// closeSender 关闭发送器，并将游标回退到最后一条已发送的事件
+func (f *ForwarderImpl) closeSender() {
+	if f.sender == nil {
+		return
+	}
+	f.sender.Close()
+
+	if id, ok := f.sender.LastSent(sourceWAF); ok {
+		f.wafSent = &id
+	}
+	if id, ok := f.sender.LastSent(sourceIDS); ok {
+		f.idsSent = &id
+	}
+	f.wafCursor = f.wafSent
+	f.idsCursor = f.idsSent
+	f.sender = nil
+}
+
+func (f *ForwarderImpl) forwardWAF(ctx context.Context) error {
+	if f.wafCursor == nil {
+		latest, err := f.wafLogRepo.GetLatestAttackLogID(ctx)
+		if err != nil {
+			return err
+		}
+		f.wafCursor = &latest
+		f.wafSent = &latest
+	}
+
+	for {
+		logs, err := f.wafLogRepo.FindAttackLogsAfterID(ctx, *f.wafCursor, f.batchSize)
+		if err != nil {
+			return err
+		}
+
+		for _, log := range logs {
+			if !f.sender.Enqueue(f.message(sourceWAF, log.ID, FromWAFLog(log))) {
+				return nil
+			}
+			id := log.ID
+			f.wafCursor = &id
+		}
+
+		if int64(len(logs)) < f.batchSize {
+			return nil
+		}
+	}
+}
+
+func (f *ForwarderImpl) forwardIDS(ctx context.Context) error {
+	if f.idsCursor == nil {
+		latest, err := f.suricataRepo.GetLatestEventID(ctx)
+		if err != nil {
+			return err
+		}
+		f.idsCursor = &latest
+		f.idsSent = &latest
+	}
+
+	for {
+		events, err := f.suricataRepo.FindEventsAfterID(ctx, *f.idsCursor, f.batchSize)
+		if err != nil {
+			return err
+		}
+
+		for _, event := range events {
+			// 只转发告警，http/tls/dns/flow 等元数据事件直接跳过
+			if event.EventType != "" && event.EventType != servermodel.SuricataEventAlert {
+				id := event.ID
+				f.idsCursor = &id
+				continue
+			}
+			if !f.sender.Enqueue(f.message(sourceIDS, event.ID, FromSuricataEvent(event))) {
+				return nil
+			}
+			id := event.ID
+			f.idsCursor = &id
+		}
+
+		if int64(len(events)) < f.batchSize {
+			return nil
+		}
+	}
+}
+
+func (f *ForwarderImpl) message(source string, id bson.ObjectID, e Event) syslogMessage {
+	body := FormatCEF(e)
+	if f.current.Format == "leef" {
+		body = FormatLEEF(e)
+	}
+
+	ts := e.Time
+	if ts.IsZero() {
+		ts = time.Now()
+	}
+
+	return syslogMessage{
+		source:   source,
+		id:       id,
+		severity: syslogSeverity(e.Severity),
+		body:     body,
+		ts:       ts,
+	}
+}
+
+// normalizeConfig 为旧版本配置中缺失的字段补全默认值
+func normalizeConfig(cfg model.SIEMConfig) model.SIEMConfig {
+	if cfg.Format != "leef" {
+		cfg.Format = "cef"
+	}
+	if cfg.Protocol == "" {
+		cfg.Protocol = "udp"
+	}
+	if cfg.Facility <= 0 || cfg.Facility > 23 {
+		cfg.Facility = 16
+	}
+	if cfg.BufferSize <= 0 {
+		cfg.BufferSize = defaultBufferSize
+	}
+	return cfg
+}
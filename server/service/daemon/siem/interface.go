@@ -0,0 +1,35 @@
+package siem
+
+import (
+	"time"
+
+	"github.com/HUAHUAI23/simple-waf/server/config"
+	"github.com/HUAHUAI23/simple-waf/server/repository"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+// Forwarder 将新写入的 WAF 和 IDS 事件转发到 SIEM
+type Forwarder interface {
+	Start() error
+	Stop() error
+}
+
+const (
+	defaultPollInterval = 2 * time.Second
+	defaultBatchSize    = 500
+	defaultBufferSize   = 10000
+)
+
+// NewForwarder 创建 SIEM 转发器，转发配置从配置集合中读取，修改后在下一个轮询周期生效
+func NewForwarder(db *mongo.Database) Forwarder {
+	logger := config.GetLogger().With().Str("component", "siem").Logger()
+
+	return &ForwarderImpl{
+		configRepo:   repository.NewConfigRepository(db),
+		wafLogRepo:   repository.NewWAFLogRepository(db),
+		suricataRepo: repository.NewSuricataRepository(db),
+		interval:     defaultPollInterval,
+		batchSize:    defaultBatchSize,
+		logger:       logger,
+	}
+}
@@ -0,0 +1,228 @@
+package siem
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/HUAHUAI23/simple-waf/pkg/model"
+	"github.com/rs/zerolog"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+const (
+	dialTimeout  = 5 * time.Second
+	writeTimeout = 5 * time.Second
+	minBackoff   = time.Second
+	maxBackoff   = 30 * time.Second
+	drainTimeout = 5 * time.Second
+	appName      = "simple-waf"
+)
+
+// 事件来源，发送器按来源记录最后一条已发送事件
+const (
+	sourceWAF = "waf"
+	sourceIDS = "ids"
+)
+
+// syslogMessage 待发送的 syslog 消息，source 和 id 标识消息对应的事件
+type syslogMessage struct {
+	source   string
+	id       bson.ObjectID
+	severity int
+	body     string
+	ts       time.Time
+}
+
+// syslogSender 带缓冲和自动重连的 syslog 发送器
+//
+// 消息先进入有界队列，由单独的 goroutine 发送；连接断开后按指数退避重连，
+// 发送失败的消息会在重连后重试，不会丢弃。每个来源最后一条写入成功的事件记录在 lastSent 中，
+// 关闭后转发器据此回退游标，队列中未发送的事件会重新读取。
+type syslogSender struct {
+	cfg      model.SIEMConfig
+	hostname string
+	queue    chan syslogMessage
+	stop     chan struct{}
+	done     chan struct{}
+	conn     net.Conn
+	sent     atomic.Uint64
+	logger   zerolog.Logger
+
+	mu       sync.Mutex
+	lastSent map[string]bson.ObjectID
+}
+
+func newSyslogSender(cfg model.SIEMConfig, logger zerolog.Logger) *syslogSender {
+	hostname, err := os.Hostname()
+	if err != nil || hostname == "" {
+		hostname = "-"
+	}
+	return &syslogSender{
+		cfg:      cfg,
+		hostname: hostname,
+		queue:    make(chan syslogMessage, cfg.BufferSize),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+		logger:   logger,
+		lastSent: make(map[string]bson.ObjectID),
+	}
+}
+
+// Start 启动发送协程
+func (s *syslogSender) Start() {
+	go s.run()
+}
+
+// Enqueue 非阻塞地将消息放入发送队列，队列已满时返回 false
+func (s *syslogSender) Enqueue(msg syslogMessage) bool {
+	select {
+	case s.queue <- msg:
+		return true
+	default:
+		return false
+	}
+}
+
+// Close 在 drainTimeout 内尽量发送队列中剩余的消息，然后停止发送协程并关闭连接
+// 仍未发送的消息由转发器按 LastSent 回退游标后重新读取
+func (s *syslogSender) Close() {
+	close(s.stop)
+	<-s.done
+	if pending := len(s.queue); pending > 0 {
+		s.logger.Warn().Int("pending", pending).Msg("SIEM 发送器关闭，未发送的事件将重新读取")
+	}
+}
+
+// LastSent 返回来源最后一条写入成功的事件 ID
+func (s *syslogSender) LastSent(source string) (bson.ObjectID, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	id, ok := s.lastSent[source]
+	return id, ok
+}
+
+// markSent 记录写入成功的消息
+func (s *syslogSender) markSent(msg syslogMessage) {
+	s.sent.Add(1)
+	s.mu.Lock()
+	s.lastSent[msg.source] = msg.id
+	s.mu.Unlock()
+}
+
+// drain 关闭前按顺序发送队列中剩余的消息，写入失败或超时后停止，不再重试
+func (s *syslogSender) drain() {
+	deadline := time.Now().Add(drainTimeout)
+	for time.Now().Before(deadline) {
+		select {
+		case msg := <-s.queue:
+			if err := s.write(s.frame(msg)); err != nil {
+				// 失败的消息已出队，但游标停在上一条已发送事件，仍会重新读取
+				s.logger.Warn().Err(err).Str("address", s.cfg.Address).Msg("关闭前发送 SIEM 事件失败")
+				return
+			}
+			s.markSent(msg)
+		default:
+			return
+		}
+	}
+}
+
+func (s *syslogSender) run() {
+	defer close(s.done)
+	defer s.closeConn()
+
+	backoff := minBackoff
+	for {
+		var msg syslogMessage
+		select {
+		case <-s.stop:
+			s.drain()
+			return
+		case msg = <-s.queue:
+		}
+
+		frame := s.frame(msg)
+		for {
+			err := s.write(frame)
+			if err == nil {
+				s.markSent(msg)
+				backoff = minBackoff
+				break
+			}
+
+			s.logger.Warn().Err(err).Str("address", s.cfg.Address).Dur("retry", backoff).Msg("发送 SIEM 事件失败，等待重连")
+			s.closeConn()
+
+			select {
+			case <-s.stop:
+				return
+			case <-time.After(backoff):
+			}
+			backoff *= 2
+			if backoff > maxBackoff {
+				backoff = maxBackoff
+			}
+		}
+	}
+}
+
+// frame 按 RFC 5424 构建 syslog 报文；TCP/TLS 使用换行分隔（RFC 6587 non-transparent framing）
+func (s *syslogSender) frame(msg syslogMessage) []byte {
+	pri := s.cfg.Facility*8 + msg.severity
+	line := fmt.Sprintf("<%d>1 %s %s %s - - - %s",
+		pri, msg.ts.UTC().Format(time.RFC3339Nano), s.hostname, appName, msg.body)
+	if s.cfg.Protocol != "udp" {
+		line += "\n"
+	}
+	return []byte(line)
+}
+
+func (s *syslogSender) write(frame []byte) error {
+	if s.conn == nil {
+		conn, err := s.dial()
+		if err != nil {
+			return err
+		}
+		s.conn = conn
+		s.logger.Info().Str("address", s.cfg.Address).Str("protocol", s.cfg.Protocol).Msg("已连接 SIEM 接收端")
+	}
+
+	if err := s.conn.SetWriteDeadline(time.Now().Add(writeTimeout)); err != nil {
+		return err
+	}
+	_, err := s.conn.Write(frame)
+	return err
+}
+
+func (s *syslogSender) dial() (net.Conn, error) {
+	dialer := &net.Dialer{Timeout: dialTimeout}
+	switch s.cfg.Protocol {
+	case "udp":
+		return dialer.Dial("udp", s.cfg.Address)
+	case "tcp":
+		return dialer.Dial("tcp", s.cfg.Address)
+	case "tls":
+		host, _, err := net.SplitHostPort(s.cfg.Address)
+		if err != nil {
+			return nil, err
+		}
+		return tls.DialWithDialer(dialer, "tcp", s.cfg.Address, &tls.Config{
+			ServerName:         host,
+			InsecureSkipVerify: s.cfg.TLSInsecure,
+		})
+	default:
+		return nil, fmt.Errorf("unsupported syslog protocol: %s", s.cfg.Protocol)
+	}
+}
+
+func (s *syslogSender) closeConn() {
+	if s.conn != nil {
+		s.conn.Close()
+		s.conn = nil
+	}
+}
@@ -0,0 +1,95 @@
+package suricata
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/HUAHUAI23/simple-waf/server/model"
+)
+
+const (
+	// CaptureBPFFile 抓包过滤表达式文件，供 suricata -F 使用
+	CaptureBPFFile = "capture.bpf"
+	// CaptureIncludeFile suricata.yaml 的 include 文件，定义 WAF_PORTS 端口组
+	CaptureIncludeFile = "capture.yaml"
+)
+
+// SitePorts 返回已激活站点的监听端口，去重后升序排列
+// TCP 站点只在开启 Suricata 检测时纳入抓包范围
+func SitePorts(sites []model.Site) []int {
+	seen := make(map[int]bool, len(sites))
+	ports := make([]int, 0, len(sites))
+	for _, site := range sites {
+		if !site.ActiveStatus || site.ListenPort <= 0 || seen[site.ListenPort] {
+			continue
+		}
+		if site.IsTCP() && (site.TCP == nil || !site.TCP.Inspect) {
+			continue
+		}
+		seen[site.ListenPort] = true
+		ports = append(ports, site.ListenPort)
+	}
+	sort.Ints(ports)
+	return ports
+}
+
+// BuildBPF 根据端口生成 BPF 过滤表达式，如 "tcp port 80 or tcp port 443"
+//
+// 没有站点时返回空字符串，Suricata 会抓取全部流量。
+func BuildBPF(ports []int) string {
+	parts := make([]string, 0, len(ports))
+	for _, port := range ports {
+		parts = append(parts, "tcp port "+strconv.Itoa(port))
+	}
+	return strings.Join(parts, " or ")
+}
+
+// WriteCaptureConfig 写入抓包过滤文件和 include 文件，内容未变化时不改写并返回 false
+//
+// BPF 过滤只在 Suricata 启动时通过 -F 读取，容器入口脚本 suricata-reload.sh 检测到过滤文件变化后重启 Suricata；
+// include 中的 WAF_PORTS 端口组在 reload-rules 时重新读取，规则中引用 $WAF_PORTS 即可随站点变化覆盖新端口。
+func WriteCaptureConfig(dir string, ports []int) (bool, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return false, err
+	}
+
+	bpf := BuildBPF(ports)
+
+	portGroup := "any"
+	if len(ports) > 0 {
+		items := make([]string, 0, len(ports))
+		for _, port := range ports {
+			items = append(items, strconv.Itoa(port))
+		}
+		portGroup = "[" + strings.Join(items, ",") + "]"
+	}
+
+	var include bytes.Buffer
+	include.WriteString("%YAML 1.1\n---\n")
+	include.WriteString("# 由 simple-waf 根据站点监听端口生成，请勿手动修改\n")
+	include.WriteString("vars:\n  port-groups:\n")
+	fmt.Fprintf(&include, "    WAF_PORTS: %q\n", portGroup)
+
+	files := map[string][]byte{
+		CaptureBPFFile:     []byte(bpf + "\n"),
+		CaptureIncludeFile: include.Bytes(),
+	}
+
+	changed := false
+	for name, data := range files {
+		path := filepath.Join(dir, name)
+		if current, err := os.ReadFile(path); err == nil && bytes.Equal(current, data) {
+			continue
+		}
+		if err := writeFileAtomic(path, data); err != nil {
+			return changed, err
+		}
+		changed = true
+	}
+	return changed, nil
+}
@@ -0,0 +1,87 @@
+package suricata
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/HUAHUAI23/simple-waf/server/model"
+)
+
+func TestSitePorts(t *testing.T) {
+	sites := []model.Site{
+		{Domain: "a.example.com", ListenPort: 443, ActiveStatus: true},
+		{Domain: "b.example.com", ListenPort: 80, ActiveStatus: true},
+		{Domain: "c.example.com", ListenPort: 443, ActiveStatus: true},
+		{Domain: "d.example.com", ListenPort: 8080, ActiveStatus: false},
+		{Domain: "e.example.com", ListenPort: 0, ActiveStatus: true},
+	}
+	if got := SitePorts(sites); !slices.Equal(got, []int{80, 443}) {
+		t.Errorf("SitePorts = %v, want [80 443]", got)
+	}
+	if got := SitePorts(nil); len(got) != 0 {
+		t.Errorf("SitePorts(nil) = %v, want empty", got)
+	}
+}
+
+func TestBuildBPF(t *testing.T) {
+	tests := []struct {
+		ports []int
+		want  string
+	}{
+		{nil, ""},
+		{[]int{80}, "tcp port 80"},
+		{[]int{80, 443, 8443}, "tcp port 80 or tcp port 443 or tcp port 8443"},
+	}
+	for _, tt := range tests {
+		if got := BuildBPF(tt.ports); got != tt.want {
+			t.Errorf("BuildBPF(%v) = %q, want %q", tt.ports, got, tt.want)
+		}
+	}
+}
+
+func TestWriteCaptureConfig(t *testing.T) {
+	dir := t.TempDir()
+
+	changed, err := WriteCaptureConfig(dir, []int{80, 443})
+	if err != nil || !changed {
+		t.Fatalf("WriteCaptureConfig = %v, %v, want changed", changed, err)
+	}
+	bpf, _ := os.ReadFile(filepath.Join(dir, CaptureBPFFile))
+	if string(bpf) != "tcp port 80 or tcp port 443\n" {
+		t.Errorf("capture.bpf = %q", bpf)
+	}
+	include, _ := os.ReadFile(filepath.Join(dir, CaptureIncludeFile))
+	if !strings.Contains(string(include), `WAF_PORTS: "[80,443]"`) {
+		t.Errorf("capture.yaml = %s", include)
+	}
+
+	// 内容未变化时不改写
+	if changed, err := WriteCaptureConfig(dir, []int{80, 443}); err != nil || changed {
+		t.Errorf("WriteCaptureConfig(same ports) = %v, %v, want unchanged", changed, err)
+	}
+
+	// 没有站点时抓取全部流量
+	if changed, err := WriteCaptureConfig(dir, nil); err != nil || !changed {
+		t.Fatalf("WriteCaptureConfig(nil) = %v, %v", changed, err)
+	}
+	bpf, _ = os.ReadFile(filepath.Join(dir, CaptureBPFFile))
+	include, _ = os.ReadFile(filepath.Join(dir, CaptureIncludeFile))
+	if string(bpf) != "\n" || !strings.Contains(string(include), `WAF_PORTS: "any"`) {
+		t.Errorf("capture files without ports = %q, %s", bpf, include)
+	}
+}
+
+func TestSitePortsTCP(t *testing.T) {
+	sites := []model.Site{
+		{Domain: "web.example.com", ListenPort: 443, ActiveStatus: true},
+		{Name: "ssh", Mode: model.SiteModeTCP, ListenPort: 2222, ActiveStatus: true, TCP: &model.TCPOptions{Inspect: true}},
+		{Name: "db", Mode: model.SiteModeTCP, ListenPort: 5432, ActiveStatus: true, TCP: &model.TCPOptions{}},
+		{Name: "legacy", Mode: model.SiteModeTCP, ListenPort: 3306, ActiveStatus: true},
+	}
+	if got := SitePorts(sites); !slices.Equal(got, []int{443, 2222}) {
+		t.Errorf("SitePorts = %v, want [443 2222]", got)
+	}
+}
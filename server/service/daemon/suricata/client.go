@@ -0,0 +1,116 @@
+package suricata
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+)
+
+// protocolVersion Suricata unix 命令套接字协议版本
+const protocolVersion = "0.2"
+
+// ErrCommandFailed Suricata 返回 NOK
+var ErrCommandFailed = errors.New("suricata command failed")
+
+// RulesetStats 单个检测引擎的规则加载统计
+type RulesetStats struct {
+	ID           int `json:"id"`
+	RulesLoaded  int `json:"rules_loaded"`
+	RulesFailed  int `json:"rules_failed"`
+	RulesSkipped int `json:"rules_skipped"`
+}
+
+// Client Suricata unix 命令套接字客户端，每条命令使用一个独立连接
+type Client struct {
+	socket  string
+	timeout time.Duration
+}
+
+// NewClient 创建命令套接字客户端
+func NewClient(socket string, timeout time.Duration) *Client {
+	return &Client{socket: socket, timeout: timeout}
+}
+
+type commandRequest struct {
+	Command   string `json:"command,omitempty"`
+	Version   string `json:"version,omitempty"`
+	Arguments any    `json:"arguments,omitempty"`
+}
+
+type commandResponse struct {
+	Return  string          `json:"return"`
+	Message json.RawMessage `json:"message"`
+}
+
+// ReloadRules 通知 Suricata 重新加载规则，返回前规则已完成加载
+func (c *Client) ReloadRules(ctx context.Context) error {
+	_, err := c.call(ctx, "reload-rules")
+	return err
+}
+
+// RulesetStats 获取各检测引擎的规则加载统计
+func (c *Client) RulesetStats(ctx context.Context) ([]RulesetStats, error) {
+	message, err := c.call(ctx, "ruleset-stats")
+	if err != nil {
+		return nil, err
+	}
+
+	var stats []RulesetStats
+	if err := json.Unmarshal(message, &stats); err != nil {
+		return nil, fmt.Errorf("decode ruleset-stats: %w", err)
+	}
+	return stats, nil
+}
+
+// call 完成版本协商后发送命令，返回响应中的 message 字段
+func (c *Client) call(ctx context.Context, command string) (json.RawMessage, error) {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "unix", c.socket)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	deadline := time.Now().Add(c.timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	if err := conn.SetDeadline(deadline); err != nil {
+		return nil, err
+	}
+
+	encoder := json.NewEncoder(conn)
+	decoder := json.NewDecoder(conn)
+
+	if _, err := exchange(encoder, decoder, commandRequest{Version: protocolVersion}); err != nil {
+		return nil, fmt.Errorf("version negotiation: %w", err)
+	}
+
+	message, err := exchange(encoder, decoder, commandRequest{Command: command})
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", command, err)
+	}
+	return message, nil
+}
+
+func exchange(encoder *json.Encoder, decoder *json.Decoder, req commandRequest) (json.RawMessage, error) {
+	if err := encoder.Encode(req); err != nil {
+		return nil, err
+	}
+
+	var resp commandResponse
+	if err := decoder.Decode(&resp); err != nil {
+		return nil, err
+	}
+	if resp.Return != "OK" {
+		var msg string
+		if json.Unmarshal(resp.Message, &msg) != nil {
+			msg = string(resp.Message)
+		}
+		return nil, fmt.Errorf("%w: %s", ErrCommandFailed, msg)
+	}
+	return resp.Message, nil
+}
@@ -0,0 +1,183 @@
+package suricata
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Rule 解析后的单条 Suricata 规则
+type Rule struct {
+	SID     int
+	Rev     int
+	Msg     string
+	Action  string
+	Enabled bool   // 规则集中被注释掉的规则为 false
+	Text    string // 去掉注释前缀后的规则原文
+	File    string // 规则所在的规则集文件
+}
+
+var validActions = map[string]bool{
+	"alert":      true,
+	"pass":       true,
+	"drop":       true,
+	"reject":     true,
+	"rejectsrc":  true,
+	"rejectdst":  true,
+	"rejectboth": true,
+}
+
+var validDirections = map[string]bool{
+	"->": true,
+	"<>": true,
+	"=>": true,
+}
+
+// ParseRule 对单条规则做语法预检查，只覆盖常见错误，最终以 Suricata 加载结果为准
+func ParseRule(text string) (*Rule, error) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return nil, errors.New("规则不能为空")
+	}
+	if strings.ContainsAny(text, "\r\n") {
+		return nil, errors.New("规则不能包含换行")
+	}
+
+	open := strings.IndexByte(text, '(')
+	if open < 0 || !strings.HasSuffix(text, ")") {
+		return nil, errors.New("规则选项必须包含在括号中")
+	}
+
+	header := strings.Fields(text[:open])
+	if len(header) != 7 {
+		return nil, fmt.Errorf("规则头应为 \"动作 协议 源地址 源端口 方向 目的地址 目的端口\"，实际有 %d 个字段", len(header))
+	}
+	if !validActions[header[0]] {
+		return nil, fmt.Errorf("不支持的规则动作 %q", header[0])
+	}
+	if !validDirections[header[4]] {
+		return nil, fmt.Errorf("不支持的方向 %q", header[4])
+	}
+
+	options, err := splitOptions(text[open+1 : len(text)-1])
+	if err != nil {
+		return nil, err
+	}
+
+	rule := &Rule{Action: header[0], Enabled: true, Text: text}
+	for _, option := range options {
+		name, value, _ := strings.Cut(option, ":")
+		name = strings.TrimSpace(name)
+		value = strings.TrimSpace(value)
+		switch name {
+		case "sid":
+			sid, err := strconv.Atoi(value)
+			if err != nil || sid <= 0 {
+				return nil, fmt.Errorf("无效的 sid %q", value)
+			}
+			if rule.SID != 0 {
+				return nil, errors.New("sid 重复定义")
+			}
+			rule.SID = sid
+		case "rev":
+			rev, err := strconv.Atoi(value)
+			if err != nil || rev < 0 {
+				return nil, fmt.Errorf("无效的 rev %q", value)
+			}
+			rule.Rev = rev
+		case "msg":
+			rule.Msg = unquote(value)
+		}
+	}
+
+	if rule.SID == 0 {
+		return nil, errors.New("规则缺少 sid")
+	}
+	if rule.Msg == "" {
+		return nil, errors.New("规则缺少 msg")
+	}
+	return rule, nil
+}
+
+// parseRulesetLine 解析规则集文件中的一行，以 "#" 注释掉的规则视为禁用，其余注释和空行返回 nil
+func parseRulesetLine(line string) *Rule {
+	line = strings.TrimSpace(line)
+	enabled := true
+	if strings.HasPrefix(line, "#") {
+		enabled = false
+		line = strings.TrimSpace(strings.TrimLeft(line, "#"))
+	}
+	if line == "" {
+		return nil
+	}
+
+	action, _, _ := strings.Cut(line, " ")
+	if !validActions[action] {
+		return nil
+	}
+
+	rule, err := ParseRule(line)
+	if err != nil {
+		return nil
+	}
+	rule.Enabled = enabled
+	return rule
+}
+
+// splitOptions 按分号拆分规则选项，忽略引号内和转义的分号
+func splitOptions(body string) ([]string, error) {
+	var (
+		options []string
+		current strings.Builder
+		quoted  bool
+		escaped bool
+	)
+
+	for _, r := range body {
+		switch {
+		case escaped:
+			escaped = false
+		case r == '\\':
+			escaped = true
+		case r == '"':
+			quoted = !quoted
+		case r == ';' && !quoted:
+			if option := strings.TrimSpace(current.String()); option != "" {
+				options = append(options, option)
+			}
+			current.Reset()
+			continue
+		}
+		current.WriteRune(r)
+	}
+
+	if quoted {
+		return nil, errors.New("规则选项中的引号未闭合")
+	}
+	if strings.TrimSpace(current.String()) != "" {
+		return nil, errors.New("规则选项必须以分号结尾")
+	}
+	if len(options) == 0 {
+		return nil, errors.New("规则缺少选项")
+	}
+	return options, nil
+}
+
+// unquote 去掉选项值两端的引号并还原转义字符
+func unquote(value string) string {
+	if len(value) >= 2 && value[0] == '"' && value[len(value)-1] == '"' {
+		value = value[1 : len(value)-1]
+	}
+	var b strings.Builder
+	escaped := false
+	for _, r := range value {
+		if r == '\\' && !escaped {
+			escaped = true
+			continue
+		}
+		escaped = false
+		b.WriteRune(r)
+	}
+	return b.String()
+}
@@ -0,0 +1,338 @@
+package suricata
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/HUAHUAI23/simple-waf/server/model"
+)
+
+const (
+	// rulesetDir 导入的原始规则集目录，位于规则目录下
+	rulesetDir = ".ruleset"
+	// RulesetFile 渲染后的规则集文件，禁用的规则以 "# " 注释
+	RulesetFile = "suricata.rules"
+	// LocalFile 渲染后的自定义规则文件
+	LocalFile = "local.rules"
+
+	maxRuleFileSize = 64 << 20
+	maxImportSize   = 512 << 20
+	maxLineSize     = 1 << 20
+)
+
+// ErrInvalidTarball 导入的文件不是有效的规则集压缩包
+var ErrInvalidTarball = errors.New("无效的规则集压缩包")
+
+// Store 管理规则目录中的规则文件
+//
+// 导入的规则集原样保存在 .ruleset 目录，启用状态覆盖和自定义规则只保存在 MongoDB 中，
+// 每次变更后重新渲染 suricata.rules 和 local.rules，因此重新导入规则集不会丢失覆盖。
+type Store struct {
+	dir string
+
+	mu        sync.Mutex
+	cached    []Rule
+	cachedMod time.Time
+}
+
+// NewStore 创建规则文件存储
+func NewStore(dir string) *Store {
+	return &Store{dir: dir}
+}
+
+// Dir 返回规则目录
+func (s *Store) Dir() string {
+	return s.dir
+}
+
+// Ruleset 返回导入的规则集，结果按规则集目录的修改时间缓存；尚未导入时返回空列表
+func (s *Store) Ruleset() ([]Rule, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	dir := filepath.Join(s.dir, rulesetDir)
+	info, err := os.Stat(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if s.cached != nil && info.ModTime().Equal(s.cachedMod) {
+		return s.cached, nil
+	}
+
+	rules, err := loadRuleset(dir)
+	if err != nil {
+		return nil, err
+	}
+	s.cached = rules
+	s.cachedMod = info.ModTime()
+	return rules, nil
+}
+
+// ImportTarball 从 tar 或 tar.gz 中导入 .rules 文件，替换当前规则集
+//
+// 只提取普通的 .rules 文件并去掉目录部分，避免路径穿越；validate 返回错误或导入失败时不影响现有规则集。
+// 替换前的规则集保留在 .ruleset.old 中，直到下一次导入，Suricata 拒绝新规则集时可通过 RollbackImport 恢复。
+func (s *Store) ImportTarball(r io.Reader, validate func([]Rule) error) (files int, rules int, err error) {
+	if err := os.MkdirAll(s.dir, 0o755); err != nil {
+		return 0, 0, err
+	}
+
+	staging, err := os.MkdirTemp(s.dir, ".import-")
+	if err != nil {
+		return 0, 0, err
+	}
+	defer os.RemoveAll(staging)
+
+	reader := bufio.NewReader(io.LimitReader(r, maxImportSize))
+	var source io.Reader = reader
+	if magic, _ := reader.Peek(2); len(magic) == 2 && magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(reader)
+		if err != nil {
+			return 0, 0, fmt.Errorf("%w: %v", ErrInvalidTarball, err)
+		}
+		defer gz.Close()
+		source = gz
+	}
+
+	tr := tar.NewReader(source)
+	var total int64
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return 0, 0, fmt.Errorf("%w: %v", ErrInvalidTarball, err)
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		name := filepath.Base(filepath.Clean("/" + header.Name))
+		if !strings.HasSuffix(name, ".rules") || strings.HasPrefix(name, ".") {
+			continue
+		}
+		if header.Size > maxRuleFileSize {
+			return 0, 0, fmt.Errorf("%w: %s 超过大小限制", ErrInvalidTarball, header.Name)
+		}
+		total += header.Size
+		if total > maxImportSize {
+			return 0, 0, fmt.Errorf("%w: 解压后超过大小限制", ErrInvalidTarball)
+		}
+
+		data, err := io.ReadAll(io.LimitReader(tr, maxRuleFileSize+1))
+		if err != nil {
+			return 0, 0, fmt.Errorf("%w: %v", ErrInvalidTarball, err)
+		}
+		if err := os.WriteFile(filepath.Join(staging, name), data, 0o644); err != nil {
+			return 0, 0, err
+		}
+		files++
+	}
+
+	if files == 0 {
+		return 0, 0, fmt.Errorf("%w: 未找到 .rules 文件", ErrInvalidTarball)
+	}
+
+	parsed, err := loadRuleset(staging)
+	if err != nil {
+		return 0, 0, err
+	}
+	seen := make(map[int]string, len(parsed))
+	for _, rule := range parsed {
+		if file, ok := seen[rule.SID]; ok {
+			return 0, 0, fmt.Errorf("%w: SID %d 在 %s 和 %s 中重复", ErrInvalidTarball, rule.SID, file, rule.File)
+		}
+		seen[rule.SID] = rule.File
+	}
+	if validate != nil {
+		if err := validate(parsed); err != nil {
+			return 0, 0, err
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	target := filepath.Join(s.dir, rulesetDir)
+	backup := target + ".old"
+	os.RemoveAll(backup)
+	if err := os.Rename(target, backup); err != nil && !os.IsNotExist(err) {
+		return 0, 0, err
+	}
+	if err := os.Rename(staging, target); err != nil {
+		os.Rename(backup, target)
+		return 0, 0, err
+	}
+	s.cached = nil
+
+	return files, len(parsed), nil
+}
+
+// RollbackImport 恢复最近一次导入前的规则集，导入前没有规则集时删除导入的规则集
+// 只能在 ImportTarball 成功后、下一次导入前调用
+func (s *Store) RollbackImport() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	target := filepath.Join(s.dir, rulesetDir)
+	backup := target + ".old"
+	s.cached = nil
+
+	if _, err := os.Stat(backup); err != nil {
+		if !os.IsNotExist(err) {
+			return err
+		}
+		return os.RemoveAll(target)
+	}
+	if err := os.RemoveAll(target); err != nil {
+		return err
+	}
+	return os.Rename(backup, target)
+}
+
+// RenderedFiles 渲染后的规则文件内容，文件不存在时为 nil
+type RenderedFiles map[string][]byte
+
+// Rendered 读取当前渲染的规则文件，重新加载失败时用于恢复
+func (s *Store) Rendered() (RenderedFiles, error) {
+	files := make(RenderedFiles, 2)
+	for _, name := range []string{RulesetFile, LocalFile} {
+		data, err := os.ReadFile(filepath.Join(s.dir, name))
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+		files[name] = data
+	}
+	return files, nil
+}
+
+// RestoreRendered 将规则文件恢复为 Rendered 读取时的内容
+func (s *Store) RestoreRendered(files RenderedFiles) error {
+	for name, data := range files {
+		path := filepath.Join(s.dir, name)
+		if data == nil {
+			if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+			continue
+		}
+		if err := writeFileAtomic(path, data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Render 根据启用状态覆盖和自定义规则重新生成 suricata.rules 和 local.rules
+func (s *Store) Render(rules []Rule, overrides map[int]bool, local []model.SuricataLocalRule) error {
+	if err := os.MkdirAll(s.dir, 0o755); err != nil {
+		return err
+	}
+
+	var ruleset bytes.Buffer
+	ruleset.WriteString("# 由 simple-waf 生成，请勿手动修改\n")
+	for _, rule := range rules {
+		enabled := rule.Enabled
+		if override, ok := overrides[rule.SID]; ok {
+			enabled = override
+		}
+		if !enabled {
+			ruleset.WriteString("# ")
+		}
+		ruleset.WriteString(rule.Text)
+		ruleset.WriteByte('\n')
+	}
+
+	var locals bytes.Buffer
+	locals.WriteString("# 由 simple-waf 生成，请勿手动修改\n")
+	for _, rule := range local {
+		if rule.Description != "" {
+			locals.WriteString("# " + strings.ReplaceAll(rule.Description, "\n", " ") + "\n")
+		}
+		if !rule.Enabled {
+			locals.WriteString("# ")
+		}
+		locals.WriteString(strings.TrimSpace(rule.Rule))
+		locals.WriteByte('\n')
+	}
+
+	if err := writeFileAtomic(filepath.Join(s.dir, RulesetFile), ruleset.Bytes()); err != nil {
+		return err
+	}
+	return writeFileAtomic(filepath.Join(s.dir, LocalFile), locals.Bytes())
+}
+
+// loadRuleset 按文件名顺序解析目录中的全部 .rules 文件
+func loadRuleset(dir string) ([]Rule, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		if entry.Type().IsRegular() && strings.HasSuffix(entry.Name(), ".rules") {
+			names = append(names, entry.Name())
+		}
+	}
+	sort.Strings(names)
+
+	var rules []Rule
+	for _, name := range names {
+		file, err := os.Open(filepath.Join(dir, name))
+		if err != nil {
+			return nil, err
+		}
+		scanner := bufio.NewScanner(file)
+		scanner.Buffer(make([]byte, 64*1024), maxLineSize)
+		for scanner.Scan() {
+			if rule := parseRulesetLine(scanner.Text()); rule != nil {
+				rule.File = name
+				rules = append(rules, *rule)
+			}
+		}
+		err = scanner.Err()
+		file.Close()
+		if err != nil {
+			return nil, fmt.Errorf("read %s: %w", name, err)
+		}
+	}
+	return rules, nil
+}
+
+// writeFileAtomic 先写临时文件再重命名，避免 Suricata 读到写了一半的规则文件
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(0o644); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
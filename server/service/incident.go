@@ -0,0 +1,325 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/HUAHUAI23/simple-waf/server/config"
+	"github.com/HUAHUAI23/simple-waf/server/dto"
+	"github.com/HUAHUAI23/simple-waf/server/model"
+	"github.com/HUAHUAI23/simple-waf/server/repository"
+	"github.com/HUAHUAI23/simple-waf/server/service/daemon/incident"
+	"github.com/rs/zerolog"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+var (
+	ErrIncidentNotFound = errors.New("安全事件不存在")
+	ErrAssigneeNotFound = errors.New("负责人不存在")
+)
+
+// IncidentService 安全事件服务接口
+type IncidentService interface {
+	ListIncidents(ctx context.Context, req dto.IncidentListRequest) (*dto.IncidentListResponse, error)
+	GetIncident(ctx context.Context, id bson.ObjectID) (*model.Incident, error)
+	GetTimeline(ctx context.Context, id bson.ObjectID, limit int) (*dto.IncidentTimelineResponse, error)
+	UpdateIncident(ctx context.Context, id bson.ObjectID, req *dto.IncidentUpdateRequest) (*model.Incident, error)
+	AddComment(ctx context.Context, id bson.ObjectID, userID, username, content string) (*model.Incident, error)
+}
+
+// IncidentServiceImpl 安全事件服务实现
+type IncidentServiceImpl struct {
+	incidentRepo  repository.IncidentRepository
+	wafLogRepo    repository.WAFLogRepository
+	suricataRepo  repository.SuricataRepository
+	accessLogRepo repository.AccessLogRepository
+	userRepo      repository.UserRepository
+	logger        zerolog.Logger
+}
+
+// NewIncidentService 创建安全事件服务
+func NewIncidentService(
+	incidentRepo repository.IncidentRepository,
+	wafLogRepo repository.WAFLogRepository,
+	suricataRepo repository.SuricataRepository,
+	accessLogRepo repository.AccessLogRepository,
+	userRepo repository.UserRepository,
+) IncidentService {
+	return &IncidentServiceImpl{
+		incidentRepo:  incidentRepo,
+		wafLogRepo:    wafLogRepo,
+		suricataRepo:  suricataRepo,
+		accessLogRepo: accessLogRepo,
+		userRepo:      userRepo,
+		logger:        config.GetServiceLogger("incident"),
+	}
+}
+
+// ListIncidents 分页查询安全事件
+func (s *IncidentServiceImpl) ListIncidents(ctx context.Context, req dto.IncidentListRequest) (*dto.IncidentListResponse, error) {
+	page := req.Page
+	if page <= 0 {
+		page = 1
+	}
+	pageSize := req.PageSize
+	if pageSize <= 0 {
+		pageSize = 10
+	}
+
+	filter := bson.D{}
+	if req.Status != "" {
+		filter = append(filter, bson.E{Key: "status", Value: req.Status})
+	}
+	if req.SrcIP != "" {
+		filter = append(filter, bson.E{Key: "srcIp", Value: req.SrcIP})
+	}
+	if req.Target != "" {
+		filter = append(filter, bson.E{Key: "target", Value: strings.ToLower(req.Target)})
+	}
+	if req.Assignee != "" {
+		filter = append(filter, bson.E{Key: "assignee", Value: req.Assignee})
+	}
+	timeRange := bson.D{}
+	if !req.StartTime.IsZero() {
+		timeRange = append(timeRange, bson.E{Key: "$gte", Value: req.StartTime})
+	}
+	if !req.EndTime.IsZero() {
+		timeRange = append(timeRange, bson.E{Key: "$lte", Value: req.EndTime})
+	}
+	if len(timeRange) > 0 {
+		filter = append(filter, bson.E{Key: "lastSeen", Value: timeRange})
+	}
+
+	incidents, total, err := s.incidentRepo.List(ctx, filter, int64((page-1)*pageSize), int64(pageSize))
+	if err != nil {
+		return nil, err
+	}
+
+	return &dto.IncidentListResponse{
+		Results:     incidents,
+		TotalCount:  total,
+		PageSize:    pageSize,
+		CurrentPage: page,
+		TotalPages:  int(math.Ceil(float64(total) / float64(pageSize))),
+	}, nil
+}
+
+// GetIncident 获取安全事件详情
+func (s *IncidentServiceImpl) GetIncident(ctx context.Context, id bson.ObjectID) (*model.Incident, error) {
+	incident, err := s.incidentRepo.GetByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, repository.ErrIncidentNotFound) {
+			return nil, ErrIncidentNotFound
+		}
+		return nil, err
+	}
+	return incident, nil
+}
+
+// GetTimeline 合并安全事件时间范围内的 WAF 日志、Suricata 告警和 4xx 访问日志，按时间升序返回
+func (s *IncidentServiceImpl) GetTimeline(ctx context.Context, id bson.ObjectID, limit int) (*dto.IncidentTimelineResponse, error) {
+	if limit <= 0 {
+		limit = 200
+	}
+
+	inc, err := s.GetIncident(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	// 主机名可能带端口或大小写不同，查询时放宽匹配，再按关联器的归一化规则精确过滤
+	hostPattern := bson.Regex{Pattern: "^" + regexp.QuoteMeta(inc.Target) + "(:[0-9]+)?$", Options: "i"}
+
+	wafFilter := bson.D{
+		{Key: "srcIp", Value: inc.SrcIP},
+		{Key: "createdAt", Value: bson.D{{Key: "$gte", Value: inc.FirstSeen}, {Key: "$lte", Value: inc.LastSeen}}},
+		{Key: "$or", Value: bson.A{
+			bson.D{{Key: "domain", Value: hostPattern}},
+			bson.D{{Key: "dstIp", Value: inc.Target}},
+		}},
+	}
+	logs, err := s.wafLogRepo.FindAttackLogs(ctx, wafFilter, 0, int64(limit)*2)
+	if err != nil {
+		return nil, err
+	}
+
+	idsFilter := bson.D{
+		{Key: "src_ip", Value: inc.SrcIP},
+		{Key: "timestamp", Value: bson.D{{Key: "$gte", Value: inc.FirstSeen}, {Key: "$lte", Value: inc.LastSeen}}},
+		{Key: "signature_id", Value: bson.D{{Key: "$gt", Value: 0}}},
+		{Key: "$or", Value: bson.A{
+			bson.D{{Key: "http.hostname", Value: hostPattern}},
+			bson.D{{Key: "tls.sni", Value: hostPattern}},
+			bson.D{{Key: "dst_ip", Value: inc.Target}},
+		}},
+	}
+	records, err := s.suricataRepo.FindEvents(ctx, idsFilter, int64(limit)*2)
+	if err != nil {
+		return nil, err
+	}
+
+	accessFilter := bson.D{
+		{Key: "clientIp", Value: inc.SrcIP},
+		{Key: "timestamp", Value: bson.D{{Key: "$gte", Value: inc.FirstSeen}, {Key: "$lte", Value: inc.LastSeen}}},
+		{Key: "status", Value: bson.D{{Key: "$gte", Value: 400}, {Key: "$lt", Value: 500}}},
+		{Key: "$or", Value: bson.A{
+			bson.D{{Key: "domain", Value: hostPattern}},
+			bson.D{{Key: "host", Value: hostPattern}},
+		}},
+	}
+	accessLogs, err := s.accessLogRepo.FindAccessLogs(ctx, accessFilter, int64(limit)*2)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]dto.IncidentTimelineEntry, 0, len(logs)+len(records)+len(accessLogs))
+	for i := range logs {
+		log := &logs[i]
+		if incident.WAFTarget(log) != inc.Target {
+			continue
+		}
+		entries = append(entries, dto.IncidentTimelineEntry{
+			Time:      log.CreatedAt,
+			Source:    model.IncidentSourceWAF,
+			EventID:   log.ID.Hex(),
+			SrcIP:     log.SrcIP,
+			SrcPort:   log.SrcPort,
+			DstIP:     log.DstIP,
+			DstPort:   log.DstPort,
+			Target:    inc.Target,
+			RuleID:    log.RuleID,
+			Message:   log.Message,
+			Severity:  log.Severity,
+			Action:    log.Action,
+			URI:       log.URI,
+			RequestID: log.RequestID,
+		})
+	}
+	for i := range records {
+		record := &records[i]
+		if incident.IDSTarget(record) != inc.Target {
+			continue
+		}
+		entry := dto.IncidentTimelineEntry{
+			Time:    record.Timestamp,
+			Source:  model.IncidentSourceIDS,
+			EventID: record.ID.Hex(),
+			SrcIP:   record.SrcIP,
+			SrcPort: record.SrcPort,
+			DstIP:   record.DstIP,
+			DstPort: record.DstPort,
+			Target:  inc.Target,
+			RuleID:  record.SignatureID,
+			Message: record.Msg,
+		}
+		if record.Alert != nil {
+			entry.Severity = record.Alert.Severity
+			entry.Action = record.Alert.Action
+			entry.Category = record.Alert.Category
+		} else {
+			entry.Severity, _ = strconv.Atoi(record.Severity)
+		}
+		if record.HTTP != nil {
+			entry.URI = record.HTTP.URL
+		}
+		entries = append(entries, entry)
+	}
+	for i := range accessLogs {
+		log := &accessLogs[i]
+		if incident.AccessTarget(log) != inc.Target {
+			continue
+		}
+		entries = append(entries, dto.IncidentTimelineEntry{
+			Time:      log.Timestamp,
+			Source:    model.IncidentSourceAccess,
+			EventID:   log.ID.Hex(),
+			SrcIP:     log.ClientIP,
+			SrcPort:   log.ClientPort,
+			DstPort:   log.ListenPort,
+			Target:    inc.Target,
+			Message:   log.Method + " " + log.Path,
+			URI:       log.Path,
+			Status:    log.Status,
+			RequestID: log.WAFRequestID,
+		})
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].Time.Before(entries[j].Time) })
+
+	result := &dto.IncidentTimelineResponse{Entries: entries}
+	if len(entries) > limit {
+		result.Entries = entries[len(entries)-limit:]
+		result.Truncated = true
+	}
+	return result, nil
+}
+
+// UpdateIncident 修改安全事件的处理状态或负责人
+func (s *IncidentServiceImpl) UpdateIncident(ctx context.Context, id bson.ObjectID, req *dto.IncidentUpdateRequest) (*model.Incident, error) {
+	set := bson.D{}
+
+	if req.Status != nil {
+		status := model.IncidentStatus(*req.Status)
+		set = append(set, bson.E{Key: "status", Value: status})
+		if status.IsActive() {
+			set = append(set, bson.E{Key: "closedAt", Value: nil})
+		} else {
+			set = append(set, bson.E{Key: "closedAt", Value: time.Now()})
+		}
+	}
+
+	if req.Assignee != nil {
+		assignee := strings.TrimSpace(*req.Assignee)
+		if assignee != "" {
+			user, err := s.userRepo.FindByUsername(ctx, assignee)
+			if err != nil {
+				return nil, err
+			}
+			if user == nil {
+				return nil, ErrAssigneeNotFound
+			}
+		}
+		set = append(set, bson.E{Key: "assignee", Value: assignee})
+	}
+
+	if len(set) == 0 {
+		return s.GetIncident(ctx, id)
+	}
+
+	incident, err := s.incidentRepo.Update(ctx, id, set)
+	if err != nil {
+		if errors.Is(err, repository.ErrIncidentNotFound) {
+			return nil, ErrIncidentNotFound
+		}
+		return nil, err
+	}
+
+	s.logger.Info().Str("id", id.Hex()).Str("status", string(incident.Status)).Str("assignee", incident.Assignee).Msg("更新安全事件")
+	return incident, nil
+}
+
+// AddComment 添加处理备注
+func (s *IncidentServiceImpl) AddComment(ctx context.Context, id bson.ObjectID, userID, username, content string) (*model.Incident, error) {
+	comment := model.IncidentComment{
+		ID:        bson.NewObjectID(),
+		UserID:    userID,
+		Username:  username,
+		Content:   strings.TrimSpace(content),
+		CreatedAt: time.Now(),
+	}
+
+	incident, err := s.incidentRepo.AddComment(ctx, id, comment)
+	if err != nil {
+		if errors.Is(err, repository.ErrIncidentNotFound) {
+			return nil, ErrIncidentNotFound
+		}
+		return nil, err
+	}
+	return incident, nil
+}
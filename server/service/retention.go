@@ -0,0 +1,161 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/HUAHUAI23/simple-waf/server/config"
+	"github.com/HUAHUAI23/simple-waf/server/dto"
+	"github.com/HUAHUAI23/simple-waf/server/repository"
+	"github.com/HUAHUAI23/simple-waf/server/service/daemon/retention"
+	"github.com/rs/zerolog"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+var (
+	ErrArchiveDirNotConfigured = errors.New("未配置归档目录")
+	ErrInvalidArchiveName      = errors.New("无效的归档文件名")
+	ErrArchiveNotFound         = errors.New("归档文件不存在")
+)
+
+const (
+	restoreBatchSize   = 1000
+	defaultRestoreDays = 7
+	restoredAtField    = "restoredAt"
+)
+
+// RetentionService 日志保留服务接口
+type RetentionService interface {
+	ListArchives(ctx context.Context, collection string) ([]dto.ArchiveDTO, error)
+	RestoreArchive(ctx context.Context, req dto.ArchiveRestoreRequest) (*dto.ArchiveRestoreResponse, error)
+}
+
+// RetentionServiceImpl 日志保留服务实现
+type RetentionServiceImpl struct {
+	configRepo    repository.ConfigRepository
+	retentionRepo repository.RetentionRepository
+	logger        zerolog.Logger
+}
+
+// NewRetentionService 创建日志保留服务
+func NewRetentionService(configRepo repository.ConfigRepository, retentionRepo repository.RetentionRepository) RetentionService {
+	logger := config.GetServiceLogger("retention")
+	return &RetentionServiceImpl{
+		configRepo:    configRepo,
+		retentionRepo: retentionRepo,
+		logger:        logger,
+	}
+}
+
+// ListArchives 列出集合的归档文件，按时间升序排列
+func (s *RetentionServiceImpl) ListArchives(ctx context.Context, collection string) ([]dto.ArchiveDTO, error) {
+	cfg, err := s.configRepo.GetConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Retention.ArchiveDir == "" {
+		return nil, ErrArchiveDirNotConfigured
+	}
+
+	archives, err := retention.ListArchives(cfg.Retention.ArchiveDir, collection)
+	if err != nil {
+		s.logger.Error().Err(err).Str("collection", collection).Msg("读取归档目录失败")
+		return nil, err
+	}
+
+	sort.Slice(archives, func(i, j int) bool {
+		return archives[i].From.Before(archives[j].From)
+	})
+
+	results := make([]dto.ArchiveDTO, len(archives))
+	for i, a := range archives {
+		results[i] = dto.ArchiveDTO{
+			Collection: a.Collection,
+			Name:       a.Name,
+			From:       a.From,
+			To:         a.To,
+			Size:       a.Size,
+		}
+	}
+	return results, nil
+}
+
+// RestoreArchive 将归档文件重新导入到 <collection>_restored 集合
+//
+// 恢复的数据写入独立集合，并按 restoredAt 设置 TTL，避免被原集合的保留策略立即删除。
+// 文档保留原始 _id，重复恢复同一归档不会产生重复数据。
+func (s *RetentionServiceImpl) RestoreArchive(ctx context.Context, req dto.ArchiveRestoreRequest) (*dto.ArchiveRestoreResponse, error) {
+	cfg, err := s.configRepo.GetConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Retention.ArchiveDir == "" {
+		return nil, ErrArchiveDirNotConfigured
+	}
+
+	path, ok := retention.ArchivePath(cfg.Retention.ArchiveDir, req.Collection, req.Name)
+	if !ok {
+		return nil, ErrInvalidArchiveName
+	}
+	if _, err := os.Stat(path); err != nil {
+		if os.IsNotExist(err) {
+			return nil, ErrArchiveNotFound
+		}
+		return nil, err
+	}
+
+	restoreDays := cfg.Retention.RestoreDays
+	if restoreDays <= 0 {
+		restoreDays = defaultRestoreDays
+	}
+	expireAfter := time.Duration(restoreDays) * 24 * time.Hour
+	target := req.Collection + retention.RestoredSuffix
+
+	if err := s.retentionRepo.EnsureTTLIndex(ctx, target, restoredAtField, expireAfter); err != nil {
+		s.logger.Error().Err(err).Str("collection", target).Msg("创建恢复集合 TTL 索引失败")
+		return nil, err
+	}
+
+	restoredAt := time.Now()
+	result := &dto.ArchiveRestoreResponse{
+		TargetCollection: target,
+		ExpireAt:         restoredAt.Add(expireAfter),
+	}
+
+	batch := make([]any, 0, restoreBatchSize)
+	flush := func() error {
+		inserted, err := s.retentionRepo.InsertDocuments(ctx, target, batch)
+		result.Inserted += inserted
+		batch = batch[:0]
+		return err
+	}
+
+	err = retention.ReadArchive(path, func(doc bson.D) error {
+		doc = append(doc, bson.E{Key: restoredAtField, Value: restoredAt})
+		batch = append(batch, doc)
+		result.Total++
+		if len(batch) >= restoreBatchSize {
+			return flush()
+		}
+		return nil
+	})
+	if err == nil && len(batch) > 0 {
+		err = flush()
+	}
+	if err != nil {
+		s.logger.Error().Err(err).Str("archive", req.Name).Int64("inserted", result.Inserted).Msg("恢复归档失败")
+		return nil, err
+	}
+
+	s.logger.Info().
+		Str("archive", req.Name).
+		Str("collection", target).
+		Int64("total", result.Total).
+		Int64("inserted", result.Inserted).
+		Msg("归档恢复完成")
+
+	return result, nil
+}
@@ -4,17 +4,19 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"os/exec"
 
-	"github.com/kwrum1/server/config"
-	"github.com/kwrum1/server/service/daemon"
+	"github.com/HUAHUAI23/simple-waf/server/config"
+	"github.com/HUAHUAI23/simple-waf/server/model"
+	"github.com/HUAHUAI23/simple-waf/server/service/daemon"
+	"github.com/HUAHUAI23/simple-waf/server/service/daemon/haproxy"
 	"github.com/rs/zerolog"
 )
 
 // 定义错误
 var (
-	ErrRunnerNotRunning     = errors.New("运行器未在运行")
-	ErrRunnerAlreadyRunning = errors.New("运行器已在运行")
+	ErrRunnerNotRunning      = errors.New("运行器未在运行")
+	ErrRunnerAlreadyRunning  = errors.New("运行器已在运行")
+	ErrConfigVersionNotFound = errors.New("HAProxy 配置版本不存在")
 )
 
 // RunnerService 运行器服务接口
@@ -28,6 +30,18 @@ type RunnerService interface {
 	Restart(ctx context.Context) error
 	ForceStop(ctx context.Context) error
 	Reload(ctx context.Context) error
+
+	// HAProxy 配置版本
+	ListConfigVersions(ctx context.Context) ([]haproxy.ConfigVersion, error)
+	GetConfigVersion(ctx context.Context, version int) (*haproxy.ConfigVersion, string, error)
+	RollbackConfig(ctx context.Context, version int) error
+
+	// 站点后端服务器的实时状态
+	GetSiteHealth(ctx context.Context, site model.Site) ([]haproxy.ServerHealth, error)
+	ApplySite(ctx context.Context, site model.Site) error
+
+	// HAProxy 运行时统计
+	GetStats(ctx context.Context, sites []model.Site) (*haproxy.RuntimeStats, error)
 }
 
 // RunnerServiceImpl 运行器服务实现
@@ -111,29 +125,101 @@ func (s *RunnerServiceImpl) ForceStop(ctx context.Context) error {
 	return nil
 }
 
-// Reload 热重载运行器 且同步 Suricata 端口过滤规则
-type reloadScript = "/usr/local/bin/suricata-reload.sh"
+// Reload 热重载运行器，Suricata 抓包端口随站点配置在 HotReload 中同步
 func (s *RunnerServiceImpl) Reload(ctx context.Context) error {
 	// 检查当前状态
 	if s.runner.GetState() != daemon.ServiceRunning {
 		return ErrRunnerNotRunning
 	}
 
-	// 1) 热重载 WAF (HAProxy/Coraza)
+	// 热重载服务
 	err := s.runner.HotReload()
 	if err != nil {
 		s.logger.Error().Err(err).Msg("热重载运行器失败")
 		return fmt.Errorf("热重载运行器失败: %w", err)
 	}
 
-	// 2) 同步 Suricata 端口过滤并热重载 Suricata
-	cmd := exec.CommandContext(ctx, "sh", "-c", reloadScript)
-	output, err := cmd.CombinedOutput()
+	return nil
+}
+
+// ListConfigVersions 列出保留的 HAProxy 配置版本
+func (s *RunnerServiceImpl) ListConfigVersions(ctx context.Context) ([]haproxy.ConfigVersion, error) {
+	versions, err := s.runner.ListConfigVersions()
+	if err != nil {
+		s.logger.Error().Err(err).Msg("获取 HAProxy 配置版本列表失败")
+		return nil, err
+	}
+	return versions, nil
+}
+
+// GetConfigVersion 获取 HAProxy 配置版本详情
+func (s *RunnerServiceImpl) GetConfigVersion(ctx context.Context, version int) (*haproxy.ConfigVersion, string, error) {
+	meta, content, err := s.runner.GetConfigVersion(version)
+	if err != nil {
+		if errors.Is(err, haproxy.ErrConfigVersionNotFound) {
+			return nil, "", ErrConfigVersionNotFound
+		}
+		s.logger.Error().Err(err).Int("version", version).Msg("获取 HAProxy 配置版本失败")
+		return nil, "", err
+	}
+	return meta, content, nil
+}
+
+// RollbackConfig 回滚 HAProxy 配置到指定版本，只能在运行器运行时执行
+func (s *RunnerServiceImpl) RollbackConfig(ctx context.Context, version int) error {
+	if s.runner.GetState() != daemon.ServiceRunning {
+		return ErrRunnerNotRunning
+	}
+
+	if err := s.runner.RollbackConfig(version); err != nil {
+		if errors.Is(err, haproxy.ErrConfigVersionNotFound) {
+			return ErrConfigVersionNotFound
+		}
+		s.logger.Error().Err(err).Int("version", version).Msg("回滚 HAProxy 配置失败")
+		return fmt.Errorf("回滚 HAProxy 配置失败: %w", err)
+	}
+
+	s.logger.Info().Int("version", version).Msg("HAProxy 配置回滚成功")
+	return nil
+}
+
+// GetSiteHealth 获取站点后端服务器的实时状态，只能在运行器运行时获取
+func (s *RunnerServiceImpl) GetSiteHealth(ctx context.Context, site model.Site) ([]haproxy.ServerHealth, error) {
+	if s.runner.GetState() != daemon.ServiceRunning {
+		return nil, ErrRunnerNotRunning
+	}
+
+	servers, err := s.runner.GetSiteHealth(site)
 	if err != nil {
-		s.logger.Error().Str("output", string(output)).Err(err).Msg("Suricata 热重载脚本执行失败")
-		return fmt.Errorf("suricata reload failed: %w", err)
+		s.logger.Error().Err(err).Str("domain", site.Domain).Msg("获取站点服务器状态失败")
+		return nil, fmt.Errorf("获取站点服务器状态失败: %w", err)
+	}
+	return servers, nil
+}
+
+// ApplySite 将站点的当前配置立即应用到运行中的 HAProxy，运行器未运行时配置会在下次启动时生效
+func (s *RunnerServiceImpl) ApplySite(ctx context.Context, site model.Site) error {
+	if s.runner.GetState() != daemon.ServiceRunning {
+		return ErrRunnerNotRunning
+	}
+
+	if err := s.runner.ApplySite(site); err != nil {
+		s.logger.Error().Err(err).Str("domain", site.Domain).Msg("应用站点配置失败")
+		return fmt.Errorf("应用站点配置失败: %w", err)
 	}
-	
-	s.logger.Info().Str("output", string(output)).Msg("Suricata 配置同步并热重载成功")
 	return nil
 }
+
+// GetStats 获取 HAProxy 运行时统计并按站点汇总，只能在运行器运行时获取
+func (s *RunnerServiceImpl) GetStats(ctx context.Context, sites []model.Site) (*haproxy.RuntimeStats, error) {
+	if s.runner.GetState() != daemon.ServiceRunning {
+		return nil, ErrRunnerNotRunning
+	}
+
+	stats, err := s.runner.GetStats(sites)
+	if err != nil {
+		s.logger.Error().Err(err).Msg("获取 HAProxy 运行时统计失败")
+		return nil, fmt.Errorf("获取 HAProxy 运行时统计失败: %w", err)
+	}
+	return stats, nil
+}
@@ -2,6 +2,8 @@ package service
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"strconv"
 
 	"github.com/HUAHUAI23/simple-waf/server/config"
@@ -12,12 +14,19 @@ import (
 	"go.mongodb.org/mongo-driver/v2/bson"
 )
 
+// 定义错误
+var (
+	ErrInvalidSite    = errors.New("无效的站点配置")
+	ErrServerNotFound = errors.New("后端服务器不存在")
+)
+
 type SiteService interface {
 	CreateSite(ctx context.Context, req *dto.CreateSiteRequest) (*model.Site, error)
 	GetSites(ctx context.Context, pageStr, sizeStr string) ([]model.Site, int64, error)
 	GetSiteByID(ctx context.Context, id bson.ObjectID) (*model.Site, error)
 	UpdateSite(ctx context.Context, id bson.ObjectID, req *dto.UpdateSiteRequest) (*model.Site, error)
 	DeleteSite(ctx context.Context, id bson.ObjectID) error
+	UpdateServerState(ctx context.Context, id bson.ObjectID, index int, req *dto.UpdateServerStateRequest, apply func(site model.Site) error) (*model.Site, error)
 }
 
 // SiteService 站点服务
@@ -41,19 +50,25 @@ func (s *SiteServiceImpl) CreateSite(ctx context.Context, req *dto.CreateSiteReq
 	site := model.NewSite()
 	site.Name = req.Name
 	site.Domain = req.Domain
+	site.Aliases = req.Aliases
 	site.ListenPort = req.ListenPort
+	site.ListenAddrs = req.ListenAddrs
+	site.Proxy = toModelProxy(req.Proxy)
+	site.Mode = model.SiteMode(req.Mode)
+	site.TCP = toModelTCP(req.TCP)
 	site.EnableHTTPS = req.EnableHTTPS
+	site.HTTP2 = req.HTTP2
+	site.HTTP3 = req.HTTP3
 	site.WAFEnabled = req.WAFEnabled
 	site.WAFMode = model.WAFModeFromString(req.WAFMode)
 	site.ActiveStatus = req.ActiveStatus
 	// 设置后端服务器
-	site.Backend.Servers = make([]model.Server, len(req.Backend.Servers))
-	for i, server := range req.Backend.Servers {
-		site.Backend.Servers[i] = model.Server{
-			Host: server.Host,
-			Port: server.Port,
-		}
-	}
+	site.Backend = toModelBackend(req.Backend)
+	site.Locations = toModelLocations(req.Locations)
+	site.Headers = toModelHeaders(req.Headers)
+	site.Redirects = toModelRedirects(req.Redirects)
+	site.Rewrites = toModelRewrites(req.Rewrites)
+	site.TLS = toModelTLS(req.TLS)
 
 	// 如果启用HTTPS，设置证书信息
 	if req.EnableHTTPS && req.Certificate != nil {
@@ -70,7 +85,7 @@ func (s *SiteServiceImpl) CreateSite(ctx context.Context, req *dto.CreateSiteReq
 	// 验证站点配置
 	if err := model.ValidateSite(site); err != nil {
 		s.logger.Error().Err(err).Msg("站点验证失败")
-		return nil, err
+		return nil, errors.Join(ErrInvalidSite, err)
 	}
 
 	// 检查域名和端口是否已存在
@@ -123,17 +138,6 @@ func (s *SiteServiceImpl) GetSiteByID(ctx context.Context, id bson.ObjectID) (*m
 
 // UpdateSite 更新站点
 func (s *SiteServiceImpl) UpdateSite(ctx context.Context, id bson.ObjectID, req *dto.UpdateSiteRequest) (*model.Site, error) {
-
-	err := s.siteRepo.CheckDomainPortConflict(ctx, &model.Site{
-		ID:         id,
-		Domain:     req.Domain,
-		ListenPort: req.ListenPort,
-	})
-
-	if err != nil {
-		return nil, err
-	}
-
 	// 获取现有站点
 	site, err := s.siteRepo.GetSiteByID(ctx, id)
 	if err != nil {
@@ -147,12 +151,29 @@ func (s *SiteServiceImpl) UpdateSite(ctx context.Context, id bson.ObjectID, req
 	if req.Domain != "" {
 		site.Domain = req.Domain
 	}
+	if req.Aliases != nil {
+		site.Aliases = *req.Aliases
+	}
 	if req.ListenPort != 0 {
 		site.ListenPort = req.ListenPort
 	}
+	if req.ListenAddrs != nil {
+		site.ListenAddrs = *req.ListenAddrs
+	}
+	if req.Proxy != nil {
+		site.Proxy = toModelProxy(req.Proxy)
+	}
+	if req.Mode != "" {
+		site.Mode = model.SiteMode(req.Mode)
+	}
+	if req.TCP != nil {
+		site.TCP = toModelTCP(req.TCP)
+	}
 
 	// 更新HTTPS设置
 	site.EnableHTTPS = req.EnableHTTPS
+	site.HTTP2 = req.HTTP2
+	site.HTTP3 = req.HTTP3
 	site.WAFEnabled = req.WAFEnabled
 	if req.WAFMode != "" {
 		site.WAFMode = model.WAFModeFromString(req.WAFMode)
@@ -161,14 +182,30 @@ func (s *SiteServiceImpl) UpdateSite(ctx context.Context, id bson.ObjectID, req
 
 	// 更新后端服务器
 	if req.Backend != nil && len(req.Backend.Servers) > 0 {
-		site.Backend.Servers = make([]model.Server, len(req.Backend.Servers))
-		for i, server := range req.Backend.Servers {
-			site.Backend.Servers[i] = model.Server{
-				Host:  server.Host,
-				Port:  server.Port,
-				IsSSL: server.IsSSL,
-			}
-		}
+		site.Backend = toModelBackend(*req.Backend)
+	}
+
+	// 更新路径路由规则
+	if req.Locations != nil {
+		site.Locations = toModelLocations(*req.Locations)
+	}
+
+	// 更新头部改写规则
+	if req.Headers != nil {
+		site.Headers = toModelHeaders(req.Headers)
+	}
+
+	// 更新跳转和改写规则
+	if req.Redirects != nil {
+		site.Redirects = toModelRedirects(*req.Redirects)
+	}
+	if req.Rewrites != nil {
+		site.Rewrites = toModelRewrites(*req.Rewrites)
+	}
+
+	// 更新 TLS 策略
+	if req.TLS != nil {
+		site.TLS = toModelTLS(req.TLS)
 	}
 
 	// 更新证书信息
@@ -186,6 +223,11 @@ func (s *SiteServiceImpl) UpdateSite(ctx context.Context, id bson.ObjectID, req
 	// 验证站点配置
 	if err := model.ValidateSite(site); err != nil {
 		s.logger.Error().Err(err).Msg("站点验证失败")
+		return nil, errors.Join(ErrInvalidSite, err)
+	}
+
+	// 检查合并后的域名和别名是否与同端口的其他站点冲突
+	if err := s.siteRepo.CheckDomainPortConflict(ctx, site); err != nil {
 		return nil, err
 	}
 
@@ -218,3 +260,207 @@ func (s *SiteServiceImpl) DeleteSite(ctx context.Context, id bson.ObjectID) erro
 	s.logger.Info().Str("id", id.Hex()).Str("name", site.Name).Msg("站点删除成功")
 	return nil
 }
+
+// UpdateServerState 修改站点默认后端中第 index 个服务器的管理状态和权重，保存后调用 apply 应用到运行中的 HAProxy
+// apply 失败时恢复保存前的服务器配置，避免数据库与运行时配置不一致
+func (s *SiteServiceImpl) UpdateServerState(ctx context.Context, id bson.ObjectID, index int, req *dto.UpdateServerStateRequest, apply func(site model.Site) error) (*model.Site, error) {
+	site, err := s.siteRepo.GetSiteByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if index < 0 || index >= len(site.Backend.Servers) {
+		return nil, ErrServerNotFound
+	}
+
+	server := &site.Backend.Servers[index]
+	previous := *server
+	if req.State != "" {
+		server.State = model.ServerState(req.State)
+	}
+	if req.Weight != nil {
+		server.Weight = *req.Weight
+	}
+
+	if err := model.ValidateSite(site); err != nil {
+		s.logger.Error().Err(err).Msg("站点验证失败")
+		return nil, errors.Join(ErrInvalidSite, err)
+	}
+
+	if err := s.siteRepo.UpdateSite(ctx, site); err != nil {
+		s.logger.Error().Err(err).Str("id", id.Hex()).Msg("更新站点失败")
+		return nil, err
+	}
+
+	if err := apply(*site); err != nil {
+		*server = previous
+		if restoreErr := s.siteRepo.UpdateSite(ctx, site); restoreErr != nil {
+			s.logger.Error().Err(restoreErr).Str("id", id.Hex()).Int("server", index).Msg("恢复后端服务器状态失败")
+			return nil, errors.Join(err, fmt.Errorf("恢复后端服务器状态失败: %w", restoreErr))
+		}
+		return nil, err
+	}
+
+	s.logger.Info().Str("id", id.Hex()).Int("server", index).Str("state", string(server.State)).Int("weight", server.Weight).Msg("后端服务器状态已更新")
+	return site, nil
+}
+
+// toModelBackend 转换后端配置，健康检查参数的默认值在 model.ValidateSite 中补齐
+func toModelBackend(backend dto.BackendDTO) model.Backend {
+	result := model.Backend{
+		Servers: toModelServers(backend.Servers),
+		Balance: model.BalanceMethod(backend.Balance),
+	}
+	if check := backend.HealthCheck; check != nil {
+		result.HealthCheck = &model.HealthCheck{
+			Type:         model.HealthCheckType(check.Type),
+			Path:         check.Path,
+			ExpectStatus: check.ExpectStatus,
+			Interval:     check.Interval,
+			Rise:         check.Rise,
+			Fall:         check.Fall,
+		}
+	}
+	if sticky := backend.StickySession; sticky != nil {
+		result.StickySession = &model.StickySession{CookieName: sticky.CookieName}
+	}
+	return result
+}
+
+// toModelTCP 转换 TCP 站点连接设置
+func toModelTCP(tcp *dto.TCPOptionsDTO) *model.TCPOptions {
+	if tcp == nil {
+		return nil
+	}
+	return &model.TCPOptions{
+		MaxConn:       tcp.MaxConn,
+		Allow:         tcp.Allow,
+		Deny:          tcp.Deny,
+		ProxyProtocol: tcp.ProxyProtocol,
+		Inspect:       tcp.Inspect,
+	}
+}
+
+// toModelProxy 转换 PROXY 协议设置，没有任何设置时在 model.ValidateSite 中清空
+func toModelProxy(proxy *dto.ProxyOptionsDTO) *model.ProxyOptions {
+	if proxy == nil {
+		return nil
+	}
+	return &model.ProxyOptions{
+		Trusted: proxy.Trusted,
+		Send:    proxy.Send,
+	}
+}
+
+// toModelTLS 转换 TLS 策略，default 模板表示不使用站点 TLS 策略
+func toModelTLS(tls *dto.TLSConfigDTO) *model.TLSConfig {
+	if tls == nil || tls.Profile == "default" {
+		return nil
+	}
+	result := &model.TLSConfig{
+		Profile:      model.TLSProfile(tls.Profile),
+		MinVersion:   tls.MinVersion,
+		MaxVersion:   tls.MaxVersion,
+		Ciphers:      tls.Ciphers,
+		Ciphersuites: tls.Ciphersuites,
+		ALPN:         tls.ALPN,
+		OCSPStapling: tls.OCSPStapling,
+	}
+	if tls.HSTS != nil {
+		result.HSTS = &model.HSTSConfig{
+			MaxAge:            tls.HSTS.MaxAge,
+			IncludeSubDomains: tls.HSTS.IncludeSubDomains,
+			Preload:           tls.HSTS.Preload,
+		}
+	}
+	return result
+}
+
+// toModelServers 转换后端服务器列表
+func toModelServers(servers []dto.ServerDTO) []model.Server {
+	result := make([]model.Server, len(servers))
+	for i, server := range servers {
+		result[i] = model.Server{
+			Host:    server.Host,
+			Port:    server.Port,
+			IsSSL:   server.IsSSL,
+			Weight:  server.Weight,
+			Backup:  server.Backup,
+			MaxConn: server.MaxConn,
+			State:   model.ServerState(server.State),
+		}
+	}
+	return result
+}
+
+// toModelHeaders 转换头部改写配置，空配置由站点校验清除
+func toModelHeaders(headers *dto.HeaderConfigDTO) *model.HeaderConfig {
+	if headers == nil {
+		return nil
+	}
+	result := &model.HeaderConfig{}
+	for _, preset := range headers.Presets {
+		result.Presets = append(result.Presets, model.HeaderPreset(preset))
+	}
+	for _, rule := range headers.Rules {
+		result.Rules = append(result.Rules, model.HeaderRule{
+			Direction: model.HeaderDirection(rule.Direction),
+			Action:    model.HeaderAction(rule.Action),
+			Name:      rule.Name,
+			Value:     rule.Value,
+		})
+	}
+	return result
+}
+
+// toModelRedirects 转换跳转规则，保持请求中的顺序
+func toModelRedirects(redirects []dto.RedirectRuleDTO) []model.RedirectRule {
+	if len(redirects) == 0 {
+		return nil
+	}
+	result := make([]model.RedirectRule, len(redirects))
+	for i, rule := range redirects {
+		result[i] = model.RedirectRule{
+			Type:   model.RedirectType(rule.Type),
+			Hosts:  rule.Hosts,
+			Path:   rule.Path,
+			Target: rule.Target,
+			Code:   rule.Code,
+		}
+	}
+	return result
+}
+
+// toModelRewrites 转换路径改写规则，保持请求中的顺序
+func toModelRewrites(rewrites []dto.RewriteRuleDTO) []model.RewriteRule {
+	if len(rewrites) == 0 {
+		return nil
+	}
+	result := make([]model.RewriteRule, len(rewrites))
+	for i, rule := range rewrites {
+		result[i] = model.RewriteRule{Path: rule.Path, Target: rule.Target, Query: rule.Query}
+	}
+	return result
+}
+
+// toModelLocations 转换路径路由规则，保持请求中的顺序
+func toModelLocations(locations []dto.LocationDTO) []model.Location {
+	if len(locations) == 0 {
+		return nil
+	}
+	result := make([]model.Location, len(locations))
+	for i, loc := range locations {
+		result[i] = model.Location{
+			Path:        loc.Path,
+			MatchType:   model.LocationMatchType(loc.MatchType),
+			Methods:     loc.Methods,
+			Backend:     toModelBackend(loc.Backend),
+			StripPrefix: loc.StripPrefix,
+			RewritePath: loc.RewritePath,
+			DisableWAF:  loc.DisableWAF,
+		}
+		for _, header := range loc.Headers {
+			result[i].Headers = append(result[i].Headers, model.HeaderMatch{Name: header.Name, Value: header.Value})
+		}
+	}
+	return result
+}
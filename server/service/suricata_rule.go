@@ -0,0 +1,454 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/HUAHUAI23/simple-waf/server/config"
+	"github.com/HUAHUAI23/simple-waf/server/dto"
+	"github.com/HUAHUAI23/simple-waf/server/model"
+	"github.com/HUAHUAI23/simple-waf/server/repository"
+	"github.com/HUAHUAI23/simple-waf/server/service/daemon/suricata"
+	"github.com/rs/zerolog"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+var (
+	ErrInvalidSuricataRule    = errors.New("无效的 Suricata 规则")
+	ErrSuricataRuleNotFound   = errors.New("Suricata 规则不存在")
+	ErrSuricataSIDConflict    = errors.New("规则SID与已有规则冲突")
+	ErrSuricataReloadFailed   = errors.New("规则已保存，但 Suricata 重新加载失败")
+	ErrSuricataReloadRejected = errors.New("Suricata 拒绝重新加载规则，已回滚本次修改")
+	ErrSuricataSocketUnusable = errors.New("无法连接 Suricata 命令套接字")
+)
+
+// SuricataRuleService Suricata 规则管理服务接口
+type SuricataRuleService interface {
+	ListRules(ctx context.Context, req dto.SuricataRuleListRequest) (*dto.SuricataRuleListResponse, error)
+	SetRuleState(ctx context.Context, sid int, enabled bool) error
+	CreateLocalRule(ctx context.Context, req *dto.SuricataLocalRuleRequest) (*model.SuricataLocalRule, error)
+	UpdateLocalRule(ctx context.Context, id bson.ObjectID, req *dto.SuricataLocalRuleRequest) (*model.SuricataLocalRule, error)
+	DeleteLocalRule(ctx context.Context, id bson.ObjectID) error
+	ImportRuleset(ctx context.Context, r io.Reader) (*dto.SuricataRulesetImportResult, error)
+	Reload(ctx context.Context) error
+	GetStats(ctx context.Context) (*dto.SuricataRulesetStats, error)
+}
+
+// SuricataRuleServiceImpl Suricata 规则管理服务实现
+//
+// 规则变更先写入 MongoDB，再重新渲染规则文件并通过命令套接字通知 Suricata 重新加载；
+// Suricata 拒绝重新加载时撤销本次修改并恢复上次的规则文件。写操作串行执行，避免并发渲染覆盖彼此的结果。
+type SuricataRuleServiceImpl struct {
+	repo   repository.SuricataRuleRepository
+	store  *suricata.Store
+	client *suricata.Client
+	logger zerolog.Logger
+
+	mu sync.Mutex
+}
+
+// NewSuricataRuleService 创建 Suricata 规则管理服务，规则目录和套接字路径取自环境配置
+func NewSuricataRuleService(repo repository.SuricataRuleRepository) SuricataRuleService {
+	return &SuricataRuleServiceImpl{
+		repo:   repo,
+		store:  suricata.NewStore(config.Global.Suricata.RulesDir),
+		client: suricata.NewClient(config.Global.Suricata.Socket, 2*time.Minute),
+		logger: config.GetServiceLogger("suricata_rule"),
+	}
+}
+
+// ListRules 分页查询导入规则集和自定义规则
+func (s *SuricataRuleServiceImpl) ListRules(ctx context.Context, req dto.SuricataRuleListRequest) (*dto.SuricataRuleListResponse, error) {
+	page := req.Page
+	if page <= 0 {
+		page = 1
+	}
+	pageSize := req.PageSize
+	if pageSize <= 0 {
+		pageSize = 20
+	}
+
+	var all []dto.SuricataRuleDTO
+	if req.Source != dto.SuricataRuleSourceLocal {
+		rules, err := s.store.Ruleset()
+		if err != nil {
+			return nil, err
+		}
+		overrides, err := s.repo.ListOverrides(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, rule := range rules {
+			item := dto.SuricataRuleDTO{
+				SID:     rule.SID,
+				Rev:     rule.Rev,
+				Msg:     rule.Msg,
+				Action:  rule.Action,
+				Source:  dto.SuricataRuleSourceRuleset,
+				File:    rule.File,
+				Enabled: rule.Enabled,
+				Default: rule.Enabled,
+				Rule:    rule.Text,
+			}
+			if enabled, ok := overrides[rule.SID]; ok {
+				item.Enabled = enabled
+				item.Overridden = enabled != rule.Enabled
+			}
+			all = append(all, item)
+		}
+	}
+
+	if req.Source != dto.SuricataRuleSourceRuleset {
+		locals, err := s.repo.ListLocalRules(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, local := range locals {
+			item := dto.SuricataRuleDTO{
+				SID:     local.SID,
+				Source:  dto.SuricataRuleSourceLocal,
+				ID:      local.ID.Hex(),
+				Enabled: local.Enabled,
+				Default: local.Enabled,
+				Rule:    local.Rule,
+			}
+			// 自定义规则保存前已经过校验，这里只用于补全展示字段
+			if parsed, err := suricata.ParseRule(local.Rule); err == nil {
+				item.Rev = parsed.Rev
+				item.Msg = parsed.Msg
+				item.Action = parsed.Action
+			}
+			all = append(all, item)
+		}
+	}
+
+	query := strings.ToLower(req.Query)
+	filtered := all[:0]
+	for _, item := range all {
+		if req.SID != 0 && item.SID != req.SID {
+			continue
+		}
+		if req.Enabled != nil && item.Enabled != *req.Enabled {
+			continue
+		}
+		if query != "" && !strings.Contains(strings.ToLower(item.Msg), query) {
+			continue
+		}
+		filtered = append(filtered, item)
+	}
+	sort.SliceStable(filtered, func(i, j int) bool { return filtered[i].SID < filtered[j].SID })
+
+	total := len(filtered)
+	start := (page - 1) * pageSize
+	if start > total {
+		start = total
+	}
+	end := start + pageSize
+	if end > total {
+		end = total
+	}
+
+	return &dto.SuricataRuleListResponse{
+		Results:     filtered[start:end],
+		TotalCount:  int64(total),
+		PageSize:    pageSize,
+		CurrentPage: page,
+		TotalPages:  int(math.Ceil(float64(total) / float64(pageSize))),
+	}, nil
+}
+
+// SetRuleState 按 SID 启用或禁用规则，自定义规则直接修改，规则集规则保存为覆盖
+func (s *SuricataRuleServiceImpl) SetRuleState(ctx context.Context, sid int, enabled bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var undo func() error
+	local, err := s.repo.GetLocalRuleBySID(ctx, sid)
+	switch {
+	case err == nil:
+		previous := local.Enabled
+		local.Enabled = enabled
+		if err := s.repo.UpdateLocalRule(ctx, local); err != nil {
+			return err
+		}
+		undo = func() error {
+			local.Enabled = previous
+			return s.repo.UpdateLocalRule(ctx, local)
+		}
+	case errors.Is(err, repository.ErrSuricataRuleNotFound):
+		rules, err := s.store.Ruleset()
+		if err != nil {
+			return err
+		}
+		index := slices.IndexFunc(rules, func(rule suricata.Rule) bool { return rule.SID == sid })
+		if index < 0 {
+			return ErrSuricataRuleNotFound
+		}
+		overrides, err := s.repo.ListOverrides(ctx)
+		if err != nil {
+			return err
+		}
+		if err := s.repo.SetOverride(ctx, sid, enabled); err != nil {
+			return err
+		}
+		// 没有覆盖时恢复为规则集中的默认状态，效果相同
+		previous, ok := overrides[sid]
+		if !ok {
+			previous = rules[index].Enabled
+		}
+		undo = func() error { return s.repo.SetOverride(ctx, sid, previous) }
+	default:
+		return err
+	}
+
+	s.logger.Info().Int("sid", sid).Bool("enabled", enabled).Msg("修改 Suricata 规则启用状态")
+	return s.apply(ctx, undo)
+}
+
+// CreateLocalRule 创建自定义规则
+func (s *SuricataRuleServiceImpl) CreateLocalRule(ctx context.Context, req *dto.SuricataLocalRuleRequest) (*model.SuricataLocalRule, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	parsed, err := s.checkLocalRule(req.Rule)
+	if err != nil {
+		return nil, err
+	}
+
+	rule := &model.SuricataLocalRule{
+		SID:         parsed.SID,
+		Rule:        parsed.Text,
+		Description: req.Description,
+		Enabled:     req.Enabled == nil || *req.Enabled,
+	}
+	if err := s.repo.CreateLocalRule(ctx, rule); err != nil {
+		if errors.Is(err, repository.ErrDuplicateSID) {
+			return nil, fmt.Errorf("%w: sid %d", ErrSuricataSIDConflict, rule.SID)
+		}
+		return nil, err
+	}
+
+	s.logger.Info().Int("sid", rule.SID).Msg("创建 Suricata 自定义规则")
+	return rule, s.apply(ctx, func() error { return s.repo.DeleteLocalRule(ctx, rule.ID) })
+}
+
+// UpdateLocalRule 更新自定义规则
+func (s *SuricataRuleServiceImpl) UpdateLocalRule(ctx context.Context, id bson.ObjectID, req *dto.SuricataLocalRuleRequest) (*model.SuricataLocalRule, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rule, err := s.repo.GetLocalRuleByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, repository.ErrSuricataRuleNotFound) {
+			return nil, ErrSuricataRuleNotFound
+		}
+		return nil, err
+	}
+
+	parsed, err := s.checkLocalRule(req.Rule)
+	if err != nil {
+		return nil, err
+	}
+
+	previous := *rule
+	rule.SID = parsed.SID
+	rule.Rule = parsed.Text
+	rule.Description = req.Description
+	if req.Enabled != nil {
+		rule.Enabled = *req.Enabled
+	}
+	if err := s.repo.UpdateLocalRule(ctx, rule); err != nil {
+		switch {
+		case errors.Is(err, repository.ErrDuplicateSID):
+			return nil, fmt.Errorf("%w: sid %d", ErrSuricataSIDConflict, rule.SID)
+		case errors.Is(err, repository.ErrSuricataRuleNotFound):
+			return nil, ErrSuricataRuleNotFound
+		}
+		return nil, err
+	}
+
+	s.logger.Info().Int("sid", rule.SID).Str("id", id.Hex()).Msg("更新 Suricata 自定义规则")
+	return rule, s.apply(ctx, func() error { return s.repo.UpdateLocalRule(ctx, &previous) })
+}
+
+// DeleteLocalRule 删除自定义规则
+func (s *SuricataRuleServiceImpl) DeleteLocalRule(ctx context.Context, id bson.ObjectID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rule, err := s.repo.GetLocalRuleByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, repository.ErrSuricataRuleNotFound) {
+			return ErrSuricataRuleNotFound
+		}
+		return err
+	}
+	if err := s.repo.DeleteLocalRule(ctx, id); err != nil {
+		if errors.Is(err, repository.ErrSuricataRuleNotFound) {
+			return ErrSuricataRuleNotFound
+		}
+		return err
+	}
+
+	s.logger.Info().Str("id", id.Hex()).Msg("删除 Suricata 自定义规则")
+	return s.apply(ctx, func() error { return s.repo.CreateLocalRule(ctx, rule) })
+}
+
+// ImportRuleset 从 tar/tar.gz 导入规则集，替换当前规则集，已有的启用状态覆盖继续生效
+func (s *SuricataRuleServiceImpl) ImportRuleset(ctx context.Context, r io.Reader) (*dto.SuricataRulesetImportResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	locals, err := s.repo.ListLocalRules(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	files, count, err := s.store.ImportTarball(r, func(rules []suricata.Rule) error {
+		for _, local := range locals {
+			if containsSID(rules, local.SID) {
+				return fmt.Errorf("%w: 自定义规则 sid %d 与导入的规则集重复", ErrSuricataSIDConflict, local.SID)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.logger.Info().Int("files", files).Int("rules", count).Msg("导入 Suricata 规则集")
+
+	result := &dto.SuricataRulesetImportResult{Files: files, Rules: count}
+	if err := s.apply(ctx, s.store.RollbackImport); err != nil {
+		return result, err
+	}
+	result.Reloaded = true
+	return result, nil
+}
+
+// Reload 重新渲染规则文件并通知 Suricata 重新加载
+func (s *SuricataRuleServiceImpl) Reload(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.apply(ctx, nil)
+}
+
+// GetStats 获取规则加载统计
+func (s *SuricataRuleServiceImpl) GetStats(ctx context.Context) (*dto.SuricataRulesetStats, error) {
+	engines, err := s.client.RulesetStats(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrSuricataSocketUnusable, err)
+	}
+
+	rules, err := s.store.Ruleset()
+	if err != nil {
+		return nil, err
+	}
+	locals, err := s.repo.ListLocalRules(ctx)
+	if err != nil {
+		return nil, err
+	}
+	overrides, err := s.repo.ListOverrides(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	stats := &dto.SuricataRulesetStats{
+		Engines:      make([]dto.SuricataEngineStats, 0, len(engines)),
+		RulesetRules: len(rules),
+		LocalRules:   len(locals),
+		Overrides:    len(overrides),
+	}
+	for _, engine := range engines {
+		stats.Engines = append(stats.Engines, dto.SuricataEngineStats{
+			ID:           engine.ID,
+			RulesLoaded:  engine.RulesLoaded,
+			RulesFailed:  engine.RulesFailed,
+			RulesSkipped: engine.RulesSkipped,
+		})
+	}
+	return stats, nil
+}
+
+// checkLocalRule 语法预检查，并确认 SID 不与导入的规则集冲突；自定义规则之间的冲突由唯一索引保证
+func (s *SuricataRuleServiceImpl) checkLocalRule(text string) (*suricata.Rule, error) {
+	parsed, err := suricata.ParseRule(text)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidSuricataRule, err)
+	}
+
+	rules, err := s.store.Ruleset()
+	if err != nil {
+		return nil, err
+	}
+	if containsSID(rules, parsed.SID) {
+		return nil, fmt.Errorf("%w: sid %d 已存在于导入的规则集", ErrSuricataSIDConflict, parsed.SID)
+	}
+	return parsed, nil
+}
+
+// apply 渲染规则文件并通知 Suricata 重新加载，调用方需持有写锁
+//
+// Suricata 返回 NOK 时调用 undo 撤销本次修改并恢复上次的规则文件，Suricata 仍使用重新加载前的规则；
+// 命令套接字不可用时保留修改，Suricata 启动或下次重新加载时读取新的规则文件。
+func (s *SuricataRuleServiceImpl) apply(ctx context.Context, undo func() error) error {
+	previous, err := s.store.Rendered()
+	if err != nil {
+		return err
+	}
+
+	rules, err := s.store.Ruleset()
+	if err != nil {
+		return err
+	}
+	overrides, err := s.repo.ListOverrides(ctx)
+	if err != nil {
+		return err
+	}
+	locals, err := s.repo.ListLocalRules(ctx)
+	if err != nil {
+		return err
+	}
+
+	if err := s.store.Render(rules, overrides, locals); err != nil {
+		s.logger.Error().Err(err).Str("dir", s.store.Dir()).Msg("写入 Suricata 规则文件失败")
+		return err
+	}
+
+	if err := s.client.ReloadRules(ctx); err != nil {
+		s.logger.Error().Err(err).Msg("Suricata 重新加载规则失败")
+		if !errors.Is(err, suricata.ErrCommandFailed) {
+			return fmt.Errorf("%w: %v", ErrSuricataReloadFailed, err)
+		}
+		if undo != nil {
+			if undoErr := undo(); undoErr != nil {
+				s.logger.Error().Err(undoErr).Msg("撤销 Suricata 规则修改失败")
+				return fmt.Errorf("%w: %v", ErrSuricataReloadFailed, err)
+			}
+		}
+		if restoreErr := s.store.RestoreRendered(previous); restoreErr != nil {
+			s.logger.Error().Err(restoreErr).Str("dir", s.store.Dir()).Msg("恢复 Suricata 规则文件失败")
+		}
+		return fmt.Errorf("%w: %v", ErrSuricataReloadRejected, err)
+	}
+
+	s.logger.Info().Int("ruleset", len(rules)).Int("local", len(locals)).Msg("Suricata 规则已重新加载")
+	return nil
+}
+
+func containsSID(rules []suricata.Rule, sid int) bool {
+	for _, rule := range rules {
+		if rule.SID == sid {
+			return true
+		}
+	}
+	return false
+}
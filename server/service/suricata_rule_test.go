@@ -0,0 +1,379 @@
+package service
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/HUAHUAI23/simple-waf/server/dto"
+	"github.com/HUAHUAI23/simple-waf/server/model"
+	"github.com/HUAHUAI23/simple-waf/server/repository"
+	"github.com/HUAHUAI23/simple-waf/server/service/daemon/suricata"
+	"github.com/rs/zerolog"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// fakeSuricataRuleRepo 内存中的 Suricata 规则仓库
+type fakeSuricataRuleRepo struct {
+	locals    map[bson.ObjectID]model.SuricataLocalRule
+	overrides map[int]bool
+}
+
+func newFakeSuricataRuleRepo() *fakeSuricataRuleRepo {
+	return &fakeSuricataRuleRepo{
+		locals:    make(map[bson.ObjectID]model.SuricataLocalRule),
+		overrides: make(map[int]bool),
+	}
+}
+
+func (r *fakeSuricataRuleRepo) CreateLocalRule(ctx context.Context, rule *model.SuricataLocalRule) error {
+	for _, local := range r.locals {
+		if local.SID == rule.SID {
+			return repository.ErrDuplicateSID
+		}
+	}
+	if rule.ID.IsZero() {
+		rule.ID = bson.NewObjectID()
+	}
+	r.locals[rule.ID] = *rule
+	return nil
+}
+
+func (r *fakeSuricataRuleRepo) UpdateLocalRule(ctx context.Context, rule *model.SuricataLocalRule) error {
+	if _, ok := r.locals[rule.ID]; !ok {
+		return repository.ErrSuricataRuleNotFound
+	}
+	r.locals[rule.ID] = *rule
+	return nil
+}
+
+func (r *fakeSuricataRuleRepo) DeleteLocalRule(ctx context.Context, id bson.ObjectID) error {
+	if _, ok := r.locals[id]; !ok {
+		return repository.ErrSuricataRuleNotFound
+	}
+	delete(r.locals, id)
+	return nil
+}
+
+func (r *fakeSuricataRuleRepo) GetLocalRuleByID(ctx context.Context, id bson.ObjectID) (*model.SuricataLocalRule, error) {
+	rule, ok := r.locals[id]
+	if !ok {
+		return nil, repository.ErrSuricataRuleNotFound
+	}
+	return &rule, nil
+}
+
+func (r *fakeSuricataRuleRepo) GetLocalRuleBySID(ctx context.Context, sid int) (*model.SuricataLocalRule, error) {
+	for _, rule := range r.locals {
+		if rule.SID == sid {
+			return &rule, nil
+		}
+	}
+	return nil, repository.ErrSuricataRuleNotFound
+}
+
+func (r *fakeSuricataRuleRepo) ListLocalRules(ctx context.Context) ([]model.SuricataLocalRule, error) {
+	rules := make([]model.SuricataLocalRule, 0, len(r.locals))
+	for _, rule := range r.locals {
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+func (r *fakeSuricataRuleRepo) SetOverride(ctx context.Context, sid int, enabled bool) error {
+	r.overrides[sid] = enabled
+	return nil
+}
+
+func (r *fakeSuricataRuleRepo) ListOverrides(ctx context.Context) (map[int]bool, error) {
+	overrides := make(map[int]bool, len(r.overrides))
+	for sid, enabled := range r.overrides {
+		overrides[sid] = enabled
+	}
+	return overrides, nil
+}
+
+// fakeSuricataSocket 模拟 Suricata unix 命令套接字，记录收到的命令
+type fakeSuricataSocket struct {
+	path string
+
+	mu       sync.Mutex
+	commands []string
+	reject   bool
+}
+
+func newFakeSuricataSocket(t *testing.T) *fakeSuricataSocket {
+	t.Helper()
+
+	// unix 套接字路径长度有限，不使用测试名称生成的临时目录
+	dir, err := os.MkdirTemp("", "suricata")
+	if err != nil {
+		t.Fatalf("create socket dir: %v", err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	socket := &fakeSuricataSocket{path: filepath.Join(dir, "command.socket")}
+	ln, err := net.Listen("unix", socket.path)
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go socket.serve(conn)
+		}
+	}()
+	return socket
+}
+
+func (f *fakeSuricataSocket) serve(conn net.Conn) {
+	defer conn.Close()
+
+	decoder := json.NewDecoder(conn)
+	encoder := json.NewEncoder(conn)
+	for {
+		var req struct {
+			Command string `json:"command"`
+			Version string `json:"version"`
+		}
+		if err := decoder.Decode(&req); err != nil {
+			return
+		}
+
+		f.mu.Lock()
+		if req.Version != "" {
+			f.commands = append(f.commands, "version "+req.Version)
+		} else {
+			f.commands = append(f.commands, req.Command)
+		}
+		reject := f.reject && req.Command == "reload-rules"
+		f.mu.Unlock()
+
+		resp := map[string]any{"return": "OK", "message": "done"}
+		if reject {
+			resp = map[string]any{"return": "NOK", "message": "loading rules failed"}
+		}
+		if err := encoder.Encode(resp); err != nil {
+			return
+		}
+	}
+}
+
+func (f *fakeSuricataSocket) setReject(reject bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.reject = reject
+}
+
+func (f *fakeSuricataSocket) takeCommands() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	commands := f.commands
+	f.commands = nil
+	return commands
+}
+
+func newTestSuricataRuleService(t *testing.T, socket string) (*SuricataRuleServiceImpl, *fakeSuricataRuleRepo) {
+	t.Helper()
+
+	repo := newFakeSuricataRuleRepo()
+	return &SuricataRuleServiceImpl{
+		repo:   repo,
+		store:  suricata.NewStore(t.TempDir()),
+		client: suricata.NewClient(socket, 5*time.Second),
+		logger: zerolog.Nop(),
+	}, repo
+}
+
+func readLocalRules(t *testing.T, s *SuricataRuleServiceImpl) string {
+	t.Helper()
+
+	data, err := os.ReadFile(filepath.Join(s.store.Dir(), suricata.LocalFile))
+	if err != nil {
+		t.Fatalf("read local rules: %v", err)
+	}
+	return string(data)
+}
+
+const (
+	testRuleA = `alert http any any -> any any (msg:"test a"; content:"a"; sid:9000001; rev:1;)`
+	testRuleB = `alert http any any -> any any (msg:"test b"; content:"b"; sid:9000002; rev:1;)`
+)
+
+func TestSuricataRuleCreateReloadsRules(t *testing.T) {
+	socket := newFakeSuricataSocket(t)
+	s, repo := newTestSuricataRuleService(t, socket.path)
+	ctx := context.Background()
+
+	rule, err := s.CreateLocalRule(ctx, &dto.SuricataLocalRuleRequest{Rule: testRuleA})
+	if err != nil {
+		t.Fatalf("CreateLocalRule: %v", err)
+	}
+
+	if got, want := socket.takeCommands(), []string{"version 0.2", "reload-rules"}; strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("commands = %q, want %q", got, want)
+	}
+	if _, ok := repo.locals[rule.ID]; !ok {
+		t.Error("rule was not saved")
+	}
+	if local := readLocalRules(t, s); !strings.Contains(local, testRuleA) {
+		t.Errorf("local.rules = %q, want the new rule", local)
+	}
+}
+
+func TestSuricataRuleCreateRollsBackWhenReloadRejected(t *testing.T) {
+	socket := newFakeSuricataSocket(t)
+	s, repo := newTestSuricataRuleService(t, socket.path)
+	ctx := context.Background()
+
+	if _, err := s.CreateLocalRule(ctx, &dto.SuricataLocalRuleRequest{Rule: testRuleA}); err != nil {
+		t.Fatalf("CreateLocalRule: %v", err)
+	}
+	before := readLocalRules(t, s)
+	socket.takeCommands()
+
+	socket.setReject(true)
+	_, err := s.CreateLocalRule(ctx, &dto.SuricataLocalRuleRequest{Rule: testRuleB})
+	if !errors.Is(err, ErrSuricataReloadRejected) {
+		t.Fatalf("CreateLocalRule error = %v, want ErrSuricataReloadRejected", err)
+	}
+
+	if got := socket.takeCommands(); len(got) == 0 || got[len(got)-1] != "reload-rules" {
+		t.Errorf("commands = %q, want reload-rules", got)
+	}
+	if len(repo.locals) != 1 {
+		t.Errorf("saved rules = %d, want the rejected rule removed", len(repo.locals))
+	}
+	if after := readLocalRules(t, s); after != before {
+		t.Errorf("local.rules = %q, want restored %q", after, before)
+	}
+}
+
+func TestSuricataRuleUpdateRollsBackWhenReloadRejected(t *testing.T) {
+	socket := newFakeSuricataSocket(t)
+	s, repo := newTestSuricataRuleService(t, socket.path)
+	ctx := context.Background()
+
+	rule, err := s.CreateLocalRule(ctx, &dto.SuricataLocalRuleRequest{Rule: testRuleA, Description: "a"})
+	if err != nil {
+		t.Fatalf("CreateLocalRule: %v", err)
+	}
+	before := readLocalRules(t, s)
+
+	socket.setReject(true)
+	_, err = s.UpdateLocalRule(ctx, rule.ID, &dto.SuricataLocalRuleRequest{Rule: testRuleB, Description: "b"})
+	if !errors.Is(err, ErrSuricataReloadRejected) {
+		t.Fatalf("UpdateLocalRule error = %v, want ErrSuricataReloadRejected", err)
+	}
+
+	saved := repo.locals[rule.ID]
+	if saved.SID != 9000001 || saved.Description != "a" {
+		t.Errorf("saved rule = %+v, want the previous rule", saved)
+	}
+	if after := readLocalRules(t, s); after != before {
+		t.Errorf("local.rules = %q, want restored %q", after, before)
+	}
+}
+
+func TestSuricataRuleDeleteRollsBackWhenReloadRejected(t *testing.T) {
+	socket := newFakeSuricataSocket(t)
+	s, repo := newTestSuricataRuleService(t, socket.path)
+	ctx := context.Background()
+
+	rule, err := s.CreateLocalRule(ctx, &dto.SuricataLocalRuleRequest{Rule: testRuleA})
+	if err != nil {
+		t.Fatalf("CreateLocalRule: %v", err)
+	}
+
+	socket.setReject(true)
+	if err := s.DeleteLocalRule(ctx, rule.ID); !errors.Is(err, ErrSuricataReloadRejected) {
+		t.Fatalf("DeleteLocalRule error = %v, want ErrSuricataReloadRejected", err)
+	}
+	if _, ok := repo.locals[rule.ID]; !ok {
+		t.Error("deleted rule was not restored")
+	}
+	if local := readLocalRules(t, s); !strings.Contains(local, testRuleA) {
+		t.Errorf("local.rules = %q, want the rule restored", local)
+	}
+}
+
+func TestSuricataRuleKeptWhenSocketUnavailable(t *testing.T) {
+	s, repo := newTestSuricataRuleService(t, filepath.Join(t.TempDir(), "missing.socket"))
+	ctx := context.Background()
+
+	_, err := s.CreateLocalRule(ctx, &dto.SuricataLocalRuleRequest{Rule: testRuleA})
+	if !errors.Is(err, ErrSuricataReloadFailed) {
+		t.Fatalf("CreateLocalRule error = %v, want ErrSuricataReloadFailed", err)
+	}
+	if len(repo.locals) != 1 {
+		t.Errorf("saved rules = %d, want the rule kept for the next Suricata start", len(repo.locals))
+	}
+	if local := readLocalRules(t, s); !strings.Contains(local, testRuleA) {
+		t.Errorf("local.rules = %q, want the new rule", local)
+	}
+}
+
+func rulesTarball(t *testing.T, name, content string) *bytes.Buffer {
+	t.Helper()
+
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	if err := tw.WriteHeader(&tar.Header{Name: "rules/" + name, Mode: 0o644, Size: int64(len(content)), Typeflag: tar.TypeReg}); err != nil {
+		t.Fatalf("write tar header: %v", err)
+	}
+	if _, err := tw.Write([]byte(content)); err != nil {
+		t.Fatalf("write tar content: %v", err)
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatalf("close tar: %v", err)
+	}
+	return &buf
+}
+
+func TestSuricataRulesetImportRollsBackWhenReloadRejected(t *testing.T) {
+	socket := newFakeSuricataSocket(t)
+	s, _ := newTestSuricataRuleService(t, socket.path)
+	ctx := context.Background()
+
+	if _, err := s.ImportRuleset(ctx, rulesTarball(t, "a.rules", testRuleA+"\n")); err != nil {
+		t.Fatalf("ImportRuleset: %v", err)
+	}
+
+	socket.setReject(true)
+	result, err := s.ImportRuleset(ctx, rulesTarball(t, "b.rules", testRuleB+"\n"))
+	if !errors.Is(err, ErrSuricataReloadRejected) {
+		t.Fatalf("ImportRuleset error = %v, want ErrSuricataReloadRejected", err)
+	}
+	if result.Reloaded {
+		t.Error("result.Reloaded = true, want false")
+	}
+
+	rules, err := s.store.Ruleset()
+	if err != nil {
+		t.Fatalf("Ruleset: %v", err)
+	}
+	if len(rules) != 1 || rules[0].SID != 9000001 {
+		t.Errorf("ruleset = %+v, want the previous ruleset", rules)
+	}
+	data, err := os.ReadFile(filepath.Join(s.store.Dir(), suricata.RulesetFile))
+	if err != nil {
+		t.Fatalf("read ruleset: %v", err)
+	}
+	if !strings.Contains(string(data), testRuleA) || strings.Contains(string(data), testRuleB) {
+		t.Errorf("suricata.rules = %q, want the previous ruleset", data)
+	}
+}
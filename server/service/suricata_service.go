@@ -1,40 +1,83 @@
 package service
 
 import (
-    "context"
-    "time"
+	"context"
+	"time"
 
-    "github.com/HUAHUAI23/simple-waf/server/model"
-    "github.com/HUAHUAI23/simple-waf/server/repository"
-    "go.mongodb.org/mongo-driver/bson"
+	"github.com/HUAHUAI23/simple-waf/server/dto"
+	"github.com/HUAHUAI23/simple-waf/server/model"
+	"github.com/HUAHUAI23/simple-waf/server/repository"
+	"github.com/HUAHUAI23/simple-waf/server/service/daemon/eve"
+	"go.mongodb.org/mongo-driver/v2/bson"
 )
 
 type SuricataService interface {
-    GetEvents(ctx context.Context, severity, srcIP, dstIP string, start, end time.Time, limit int64) ([]model.SuricataEvent, error)
+	GetEvents(ctx context.Context, severity, srcIP, dstIP, eventType string, start, end time.Time, limit int64) ([]model.SuricataEvent, error)
+	GetIngestHealth(ctx context.Context) *dto.SuricataIngestHealth
 }
 
 type suricataServiceImpl struct {
-    repo repository.SuricataRepository
+	repo     repository.SuricataRepository
+	ingestor eve.Ingestor
 }
 
-func NewSuricataService(repo repository.SuricataRepository) SuricataService {
-    return &suricataServiceImpl{repo: repo}
+func NewSuricataService(repo repository.SuricataRepository, ingestor eve.Ingestor) SuricataService {
+	return &suricataServiceImpl{repo: repo, ingestor: ingestor}
 }
 
-func (s *suricataServiceImpl) GetEvents(ctx context.Context, severity, srcIP, dstIP string, start, end time.Time, limit int64) ([]model.SuricataEvent, error) {
-    filter := bson.M{
-        "timestamp": bson.M{"$gte": start, "$lte": end},
-    }
-
-    if severity != "" {
-        filter["severity"] = severity
-    }
-    if srcIP != "" {
-        filter["src_ip"] = srcIP
-    }
-    if dstIP != "" {
-        filter["dst_ip"] = dstIP
-    }
-
-    return s.repo.FindEvents(ctx, filter, limit)
+func (s *suricataServiceImpl) GetEvents(ctx context.Context, severity, srcIP, dstIP, eventType string, start, end time.Time, limit int64) ([]model.SuricataEvent, error) {
+	filter := bson.D{
+		{Key: "timestamp", Value: bson.D{{Key: "$gte", Value: start}, {Key: "$lte", Value: end}}},
+	}
+
+	if severity != "" {
+		filter = append(filter, bson.E{Key: "severity", Value: severity})
+	}
+	if srcIP != "" {
+		filter = append(filter, bson.E{Key: "src_ip", Value: srcIP})
+	}
+	if dstIP != "" {
+		filter = append(filter, bson.E{Key: "dst_ip", Value: dstIP})
+	}
+	if eventType != "" {
+		filter = append(filter, bson.E{Key: "event_type", Value: eventType})
+	}
+
+	return s.repo.FindEvents(ctx, filter, limit)
+}
+
+// GetIngestHealth 返回 EVE 采集器的运行状态
+func (s *suricataServiceImpl) GetIngestHealth(ctx context.Context) *dto.SuricataIngestHealth {
+	stats := s.ingestor.Stats()
+
+	health := &dto.SuricataIngestHealth{
+		State:          stats.State,
+		File:           stats.File,
+		Offset:         stats.Offset,
+		FileSize:       stats.FileSize,
+		LinesRead:      stats.LinesRead,
+		EventsInserted: stats.EventsInserted,
+		EventsSkipped:  stats.EventsSkipped,
+		ParseErrors:    stats.ParseErrors,
+		InsertErrors:   stats.InsertErrors,
+		LastError:      stats.LastError,
+		LastEventAt:    timePtr(stats.LastEventAt),
+		LastInsertAt:   timePtr(stats.LastInsertAt),
+		LastErrorAt:    timePtr(stats.LastErrorAt),
+		StartedAt:      timePtr(stats.StartedAt),
+	}
+	if health.State == "" {
+		health.State = eve.StateStopped
+	}
+	if stats.FileSize > stats.Offset {
+		health.Lag = stats.FileSize - stats.Offset
+	}
+	return health
+}
+
+func timePtr(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
 }
@@ -0,0 +1,76 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/HUAHUAI23/simple-waf/server/config"
+	"github.com/HUAHUAI23/simple-waf/server/dto"
+	"github.com/HUAHUAI23/simple-waf/server/model"
+	"github.com/HUAHUAI23/simple-waf/server/repository"
+	"github.com/HUAHUAI23/simple-waf/server/service/daemon"
+	"github.com/rs/zerolog"
+)
+
+// SystemService 系统状态服务接口
+type SystemService interface {
+	GetStatus(ctx context.Context) (*dto.SystemStatusResponse, error)
+}
+
+// SystemServiceImpl 系统状态服务实现
+type SystemServiceImpl struct {
+	siteRepo      repository.SiteRepository
+	runnerService RunnerService
+	logger        zerolog.Logger
+}
+
+// NewSystemService 创建系统状态服务，runnerService 为空时只返回停止状态
+func NewSystemService(siteRepo repository.SiteRepository, runnerService RunnerService) SystemService {
+	logger := config.GetServiceLogger("system")
+	return &SystemServiceImpl{
+		siteRepo:      siteRepo,
+		runnerService: runnerService,
+		logger:        logger,
+	}
+}
+
+// GetStatus 获取运行器状态，运行时附带 HAProxy 运行时统计，站点统计只包含启用的站点
+func (s *SystemServiceImpl) GetStatus(ctx context.Context) (*dto.SystemStatusResponse, error) {
+	result := &dto.SystemStatusResponse{State: daemon.ServiceStopped.String()}
+	if s.runnerService == nil {
+		return result, nil
+	}
+
+	state, err := s.runnerService.GetStatus(ctx)
+	if err != nil {
+		return nil, err
+	}
+	result.State = state.String()
+	if state != daemon.ServiceRunning {
+		return result, nil
+	}
+
+	sites, err := s.siteRepo.GetAllSites(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("获取站点列表失败: %w", err)
+	}
+	active := make([]model.Site, 0, len(sites))
+	for _, site := range sites {
+		if site.ActiveStatus {
+			active = append(active, site)
+		}
+	}
+
+	stats, err := s.runnerService.GetStats(ctx, active)
+	if err != nil {
+		// 运行器可能在读取统计前停止
+		if errors.Is(err, ErrRunnerNotRunning) {
+			return result, nil
+		}
+		return nil, err
+	}
+	result.Running = true
+	result.HAProxy = stats
+	return result, nil
+}
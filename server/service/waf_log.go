@@ -2,8 +2,16 @@ package service
 
 import (
 	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/HUAHUAI23/simple-waf/pkg/model"
 	"github.com/HUAHUAI23/simple-waf/server/dto"
@@ -15,6 +23,40 @@ import (
 type WAFLogService interface {
 	GetAttackEvents(ctx context.Context, req dto.AttackEventRequset, page, pageSize int) (*dto.AttackEventResponse, error)
 	GetAttackLogs(ctx context.Context, req dto.AttackLogRequest, page, pageSize int) (*dto.AttackLogResponse, error)
+	ExportAttackLogs(ctx context.Context, req dto.AttackLogExportRequest, w io.Writer) (int64, error)
+}
+
+var ErrInvalidExportColumn = errors.New("无效的导出列")
+
+// exportColumns maps CSV column names to value extractors
+var exportColumns = map[string]func(*model.WAFLog) string{
+	"id":         func(l *model.WAFLog) string { return l.ID.Hex() },
+	"createdAt":  func(l *model.WAFLog) string { return l.CreatedAt.UTC().Format(time.RFC3339Nano) },
+	"requestId":  func(l *model.WAFLog) string { return l.RequestID },
+	"ruleId":     func(l *model.WAFLog) string { return strconv.Itoa(l.RuleID) },
+	"severity":   func(l *model.WAFLog) string { return strconv.Itoa(l.Severity) },
+	"phase":      func(l *model.WAFLog) string { return strconv.Itoa(l.Phase) },
+	"accuracy":   func(l *model.WAFLog) string { return strconv.Itoa(l.Accuracy) },
+	"secMark":    func(l *model.WAFLog) string { return l.SecMark },
+	"srcIp":      func(l *model.WAFLog) string { return l.SrcIP },
+	"srcPort":    func(l *model.WAFLog) string { return strconv.Itoa(l.SrcPort) },
+	"dstIp":      func(l *model.WAFLog) string { return l.DstIP },
+	"dstPort":    func(l *model.WAFLog) string { return strconv.Itoa(l.DstPort) },
+	"clientIp":   func(l *model.WAFLog) string { return l.ClientIP },
+	"serverIp":   func(l *model.WAFLog) string { return l.ServerIP },
+	"domain":     func(l *model.WAFLog) string { return l.Domain },
+	"uri":        func(l *model.WAFLog) string { return l.URI },
+	"message":    func(l *model.WAFLog) string { return l.Message },
+	"action":     func(l *model.WAFLog) string { return l.Action },
+	"payload":    func(l *model.WAFLog) string { return l.Payload },
+	"secLangRaw": func(l *model.WAFLog) string { return l.SecLangRaw },
+	"request":    func(l *model.WAFLog) string { return l.Request },
+	"response":   func(l *model.WAFLog) string { return l.Response },
+}
+
+var defaultExportColumns = []string{
+	"createdAt", "requestId", "ruleId", "severity", "srcIp", "srcPort",
+	"dstIp", "dstPort", "domain", "uri", "message", "payload",
 }
 
 type WAFLogServiceImpl struct {
@@ -69,13 +111,20 @@ func (s *WAFLogServiceImpl) GetAttackEvents(
 		}},
 	}
 
-	// Sort by lastAttackTime (most recent first)
+	// Sort by lastAttackTime (most recent first), the group key keeps the order stable
 	sortStage := bson.D{
 		{Key: "$sort", Value: bson.D{
 			{Key: "lastAttackTime", Value: -1},
+			{Key: "srcIp", Value: 1},
+			{Key: "dstPort", Value: 1},
+			{Key: "domain", Value: 1},
 		}},
 	}
 
+	if req.UseCursor() {
+		return s.getAttackEventsByCursor(ctx, mongo.Pipeline{matchStage, groupStage, projectStage, sortStage}, req.Cursor, pageSize)
+	}
+
 	// Build count pipeline
 	countPipeline := mongo.Pipeline{matchStage, groupStage, projectStage}
 
@@ -130,6 +179,10 @@ func (s *WAFLogServiceImpl) GetAttackLogs(
 	// Build filter
 	filter := s.buildAttackLogFilter(req)
 
+	if req.UseCursor() {
+		return s.getAttackLogsByCursor(ctx, filter, req.Cursor, pageSize)
+	}
+
 	// Get total count
 	totalCount, err := s.wafLogRepository.CountAttackLogs(ctx, filter)
 	if err != nil {
@@ -165,15 +218,109 @@ func (s *WAFLogServiceImpl) GetAttackLogs(
 	return response, nil
 }
 
+// getAttackEventsByCursor pages through aggregated events using the last group of the
+// previous page as the keyset position, skipping the total count
+func (s *WAFLogServiceImpl) getAttackEventsByCursor(
+	ctx context.Context,
+	pipeline mongo.Pipeline,
+	cursor string,
+	pageSize int,
+) (*dto.AttackEventResponse, error) {
+	if cursor != "" {
+		c, err := decodeCursor(cursor)
+		if err != nil {
+			return nil, err
+		}
+		pipeline = append(pipeline, bson.D{{Key: "$match", Value: bson.D{{Key: "$or", Value: bson.A{
+			bson.D{{Key: "lastAttackTime", Value: bson.D{{Key: "$lt", Value: c.Time}}}},
+			bson.D{{Key: "lastAttackTime", Value: c.Time}, {Key: "srcIp", Value: bson.D{{Key: "$gt", Value: c.SrcIP}}}},
+			bson.D{{Key: "lastAttackTime", Value: c.Time}, {Key: "srcIp", Value: c.SrcIP}, {Key: "dstPort", Value: bson.D{{Key: "$gt", Value: c.DstPort}}}},
+			bson.D{{Key: "lastAttackTime", Value: c.Time}, {Key: "srcIp", Value: c.SrcIP}, {Key: "dstPort", Value: c.DstPort}, {Key: "domain", Value: bson.D{{Key: "$gt", Value: c.Domain}}}},
+		}}}}})
+	}
+	pipeline = append(pipeline, bson.D{{Key: "$limit", Value: pageSize + 1}})
+
+	results, err := s.wafLogRepository.AggregateAttackEvents(ctx, pipeline)
+	if err != nil {
+		return nil, fmt.Errorf("error getting aggregated events: %w", err)
+	}
+
+	response := &dto.AttackEventResponse{
+		Results:  results,
+		PageSize: pageSize,
+	}
+	if len(results) > pageSize {
+		response.Results = results[:pageSize]
+		response.HasMore = true
+		last := response.Results[pageSize-1]
+		response.NextCursor = encodeCursor(pageCursor{
+			Time:    last.LastAttackTime,
+			SrcIP:   last.SrcIP,
+			DstPort: last.DstPort,
+			Domain:  last.Domain,
+		})
+	}
+	if response.Results == nil {
+		response.Results = []dto.AttackEventAggregateResult{}
+	}
+
+	return response, nil
+}
+
+// getAttackLogsByCursor pages through attack logs by (createdAt, _id) descending,
+// skipping the total count so deep pages stay cheap on large collections
+func (s *WAFLogServiceImpl) getAttackLogsByCursor(
+	ctx context.Context,
+	filter bson.D,
+	cursor string,
+	pageSize int,
+) (*dto.AttackLogResponse, error) {
+	if cursor != "" {
+		c, err := decodeCursor(cursor)
+		if err != nil {
+			return nil, err
+		}
+		id, err := bson.ObjectIDFromHex(c.ID)
+		if err != nil {
+			return nil, ErrInvalidCursor
+		}
+		filter = append(filter, bson.E{Key: "$or", Value: bson.A{
+			bson.D{{Key: "createdAt", Value: bson.D{{Key: "$lt", Value: c.Time}}}},
+			bson.D{{Key: "createdAt", Value: c.Time}, {Key: "_id", Value: bson.D{{Key: "$lt", Value: id}}}},
+		}})
+	}
+
+	results, err := s.wafLogRepository.FindAttackLogs(ctx, filter, 0, int64(pageSize)+1)
+	if err != nil {
+		return nil, fmt.Errorf("error finding attack logs: %w", err)
+	}
+
+	response := &dto.AttackLogResponse{
+		Results:  results,
+		PageSize: pageSize,
+	}
+	if len(results) > pageSize {
+		response.Results = results[:pageSize]
+		response.HasMore = true
+		last := response.Results[pageSize-1]
+		response.NextCursor = encodeCursor(pageCursor{Time: last.CreatedAt, ID: last.ID.Hex()})
+	}
+	if response.Results == nil {
+		response.Results = []model.WAFLog{}
+	}
+
+	return response, nil
+}
+
 // buildAttackEventFilter builds the filter for attack event queries
 func (s *WAFLogServiceImpl) buildAttackEventFilter(req dto.AttackEventRequset) bson.D {
 	filter := bson.D{}
 
-	if req.SrcIP != "" {
-		filter = append(filter, bson.E{Key: "srcIp", Value: req.SrcIP})
+	if v := matchAny(req.SrcIP, req.SrcIPs); v != nil {
+		filter = append(filter, bson.E{Key: "srcIp", Value: v})
 	}
-	if req.DstIP != "" {
-		filter = append(filter, bson.E{Key: "dstIp", Value: req.DstIP})
+	if v := matchAny(req.DstIP, req.DstIPs); v != nil {
+		filter = append(filter, bson.E{Key: "dstIp", Value: v})
 	}
 	if req.SrcPort > 0 {
 		filter = append(filter, bson.E{Key: "srcPort", Value: req.SrcPort})
@@ -184,6 +331,10 @@ func (s *WAFLogServiceImpl) buildAttackEventFilter(req dto.AttackEventRequset) b
 	if req.Domain != "" {
 		filter = append(filter, bson.E{Key: "domain", Value: req.Domain})
 	}
+	if v := matchAny(0, req.RuleIDs); v != nil {
+		filter = append(filter, bson.E{Key: "ruleId", Value: v})
+	}
+	filter = appendFilterOptions(filter, req.AttackFilterOptions)
 
 	// Add time range filter if provided
 	timeFilter := bson.D{}
@@ -204,11 +355,11 @@ func (s *WAFLogServiceImpl) buildAttackEventFilter(req dto.AttackEventRequset) b
 func (s *WAFLogServiceImpl) buildAttackLogFilter(req dto.AttackLogRequest) bson.D {
 	filter := bson.D{}
 
-	if req.SrcIP != "" {
-		filter = append(filter, bson.E{Key: "srcIp", Value: req.SrcIP})
+	if v := matchAny(req.SrcIP, req.SrcIPs); v != nil {
+		filter = append(filter, bson.E{Key: "srcIp", Value: v})
 	}
-	if req.DstIP != "" {
-		filter = append(filter, bson.E{Key: "dstIp", Value: req.DstIP})
+	if v := matchAny(req.DstIP, req.DstIPs); v != nil {
+		filter = append(filter, bson.E{Key: "dstIp", Value: v})
 	}
 	if req.SrcPort > 0 {
 		filter = append(filter, bson.E{Key: "srcPort", Value: req.SrcPort})
@@ -219,9 +370,13 @@ func (s *WAFLogServiceImpl) buildAttackLogFilter(req dto.AttackLogRequest) bson.
 	if req.Domain != "" {
 		filter = append(filter, bson.E{Key: "domain", Value: req.Domain})
 	}
-	if req.RuleID > 0 {
-		filter = append(filter, bson.E{Key: "ruleId", Value: req.RuleID})
+	if v := matchAny(req.RuleID, req.RuleIDs); v != nil {
+		filter = append(filter, bson.E{Key: "ruleId", Value: v})
+	}
+	if req.RequestID != "" {
+		filter = append(filter, bson.E{Key: "requestId", Value: req.RequestID})
 	}
+	filter = appendFilterOptions(filter, req.AttackFilterOptions)
 
 	// Add time range filter if provided
 	timeFilter := bson.D{}
@@ -237,3 +392,192 @@ func (s *WAFLogServiceImpl) buildAttackLogFilter(req dto.AttackLogRequest) bson.
 
 	return filter
 }
+
+// matchAny merges a single-value filter with its multi-value counterpart,
+// returning nil when neither is set, the value itself for one match, or an $in clause
+func matchAny[T comparable](single T, multi []T) any {
+	var zero T
+	values := make([]T, 0, len(multi)+1)
+	seen := make(map[T]bool, len(multi)+1)
+	add := func(v T) {
+		if v != zero && !seen[v] {
+			seen[v] = true
+			values = append(values, v)
+		}
+	}
+	add(single)
+	for _, v := range multi {
+		add(v)
+	}
+
+	switch len(values) {
+	case 0:
+		return nil
+	case 1:
+		return values[0]
+	default:
+		return bson.D{{Key: "$in", Value: values}}
+	}
+}
+
+// appendFilterOptions adds the extended filters shared by attack event and attack log queries
+func appendFilterOptions(filter bson.D, opts dto.AttackFilterOptions) bson.D {
+	severity := bson.D{}
+	if opts.MinSeverity != nil {
+		severity = append(severity, bson.E{Key: "$gte", Value: *opts.MinSeverity})
+	}
+	if opts.MaxSeverity != nil {
+		severity = append(severity, bson.E{Key: "$lte", Value: *opts.MaxSeverity})
+	}
+	if len(severity) > 0 {
+		filter = append(filter, bson.E{Key: "severity", Value: severity})
+	}
+	if opts.Phase > 0 {
+		filter = append(filter, bson.E{Key: "phase", Value: opts.Phase})
+	}
+	if opts.Message != "" {
+		filter = append(filter, bson.E{Key: "message", Value: containsRegex(opts.Message)})
+	}
+	if opts.Payload != "" {
+		filter = append(filter, bson.E{Key: "payload", Value: containsRegex(opts.Payload)})
+	}
+	if opts.URIPrefix != "" {
+		// An anchored, case-sensitive prefix regex can use an index on uri
+		filter = append(filter, bson.E{Key: "uri", Value: bson.Regex{Pattern: "^" + regexp.QuoteMeta(opts.URIPrefix)}})
+	}
+	if opts.Action != "" {
+		filter = append(filter, bson.E{Key: "action", Value: opts.Action})
+	}
+	return filter
+}
+
+// containsRegex builds a case-insensitive substring match with the user input escaped
+func containsRegex(text string) bson.Regex {
+	return bson.Regex{Pattern: regexp.QuoteMeta(text), Options: "i"}
+}
+
+// ExportAttackLogs streams every attack log matching the request filters to w
+// in CSV, NDJSON or JSON format and returns the number of records written
+func (s *WAFLogServiceImpl) ExportAttackLogs(
+	ctx context.Context,
+	req dto.AttackLogExportRequest,
+	w io.Writer,
+) (int64, error) {
+	filter := s.buildAttackLogFilter(req.AttackLogRequest)
+
+	switch req.Format {
+	case "ndjson":
+		return s.exportJSON(ctx, filter, w, false)
+	case "json":
+		return s.exportJSON(ctx, filter, w, true)
+	default:
+		columns, err := ParseExportColumns(req.Columns)
+		if err != nil {
+			return 0, err
+		}
+		return s.exportCSV(ctx, filter, w, columns)
+	}
+}
+
+// ParseExportColumns validates a comma separated column list, falling back to the default columns
+func ParseExportColumns(columns string) ([]string, error) {
+	if strings.TrimSpace(columns) == "" {
+		return defaultExportColumns, nil
+	}
+
+	var result []string
+	for _, column := range strings.Split(columns, ",") {
+		column = strings.TrimSpace(column)
+		if column == "" {
+			continue
+		}
+		if _, ok := exportColumns[column]; !ok {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidExportColumn, column)
+		}
+		result = append(result, column)
+	}
+	if len(result) == 0 {
+		return defaultExportColumns, nil
+	}
+	return result, nil
+}
+
+func (s *WAFLogServiceImpl) exportCSV(ctx context.Context, filter bson.D, w io.Writer, columns []string) (int64, error) {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(columns); err != nil {
+		return 0, err
+	}
+
+	var count int64
+	row := make([]string, len(columns))
+	err := s.wafLogRepository.StreamAttackLogs(ctx, filter, func(wafLog *model.WAFLog) error {
+		for i, column := range columns {
+			row[i] = sanitizeCSVCell(exportColumns[column](wafLog))
+		}
+		if err := writer.Write(row); err != nil {
+			return err
+		}
+		count++
+		return nil
+	})
+
+	writer.Flush()
+	if err == nil {
+		err = writer.Error()
+	}
+	return count, err
+}
+
+func (s *WAFLogServiceImpl) exportJSON(ctx context.Context, filter bson.D, w io.Writer, array bool) (int64, error) {
+	if array {
+		if _, err := io.WriteString(w, "["); err != nil {
+			return 0, err
+		}
+	}
+
+	var count int64
+	err := s.wafLogRepository.StreamAttackLogs(ctx, filter, func(wafLog *model.WAFLog) error {
+		data, err := json.Marshal(wafLog)
+		if err != nil {
+			return err
+		}
+		if array && count > 0 {
+			if _, err := io.WriteString(w, ","); err != nil {
+				return err
+			}
+		}
+		if _, err := w.Write(data); err != nil {
+			return err
+		}
+		if !array {
+			if _, err := io.WriteString(w, "\n"); err != nil {
+				return err
+			}
+		}
+		count++
+		return nil
+	})
+	if err != nil {
+		return count, err
+	}
+
+	if array {
+		if _, err := io.WriteString(w, "]"); err != nil {
+			return count, err
+		}
+	}
+	return count, nil
+}
+
+// sanitizeCSVCell prefixes cells that spreadsheet software would evaluate as formulas,
+// since payloads and URIs are attacker controlled
+func sanitizeCSVCell(value string) string {
+	if value == "" {
+		return value
+	}
+	switch value[0] {
+	case '=', '+', '-', '@', '\t', '\r':
+		return "'" + value
+	}
+	return value
+}
@@ -0,0 +1,199 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/HUAHUAI23/simple-waf/pkg/model"
+	"github.com/HUAHUAI23/simple-waf/server/dto"
+	"github.com/HUAHUAI23/simple-waf/server/repository"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// fakeWAFLogRepo 按顺序返回固定的攻击日志
+type fakeWAFLogRepo struct {
+	repository.WAFLogRepository
+	logs []model.WAFLog
+}
+
+func (r *fakeWAFLogRepo) StreamAttackLogs(ctx context.Context, filter bson.D, fn func(*model.WAFLog) error) error {
+	for i := range r.logs {
+		if err := fn(&r.logs[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func testAttackLogs() []model.WAFLog {
+	return []model.WAFLog{
+		{
+			RuleID:    942100,
+			Severity:  2,
+			SrcIP:     "192.168.1.100",
+			Domain:    "example.com",
+			URI:       "/login?user=admin",
+			Message:   "SQL Injection, detected",
+			Payload:   "=cmd|' /C calc'!A0",
+			CreatedAt: time.Date(2024, 3, 17, 8, 0, 0, 0, time.UTC),
+		},
+		{
+			RuleID:    941100,
+			Severity:  3,
+			SrcIP:     "10.0.0.8",
+			Domain:    "example.com",
+			URI:       "/search",
+			Message:   "XSS",
+			CreatedAt: time.Date(2024, 3, 17, 9, 0, 0, 0, time.UTC),
+		},
+	}
+}
+
+func TestParseExportColumns(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    []string
+		wantErr bool
+	}{
+		{in: "", want: defaultExportColumns},
+		{in: " , ", want: defaultExportColumns},
+		{in: "srcIp, ruleId,uri", want: []string{"srcIp", "ruleId", "uri"}},
+		{in: "srcIp,password", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseExportColumns(tt.in)
+		if tt.wantErr {
+			if !errors.Is(err, ErrInvalidExportColumn) {
+				t.Errorf("ParseExportColumns(%q) error = %v, want ErrInvalidExportColumn", tt.in, err)
+			}
+			continue
+		}
+		if err != nil || !slices.Equal(got, tt.want) {
+			t.Errorf("ParseExportColumns(%q) = %v, %v, want %v", tt.in, got, err, tt.want)
+		}
+	}
+}
+
+func TestSanitizeCSVCell(t *testing.T) {
+	tests := map[string]string{
+		"":              "",
+		"/login":        "/login",
+		"=1+1":          "'=1+1",
+		"+cmd":          "'+cmd",
+		"-2":            "'-2",
+		"@SUM(A1)":      "'@SUM(A1)",
+		"\tTAB":         "'\tTAB",
+		"a=b":           "a=b",
+		"SQL Injection": "SQL Injection",
+	}
+	for in, want := range tests {
+		if got := sanitizeCSVCell(in); got != want {
+			t.Errorf("sanitizeCSVCell(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestExportAttackLogsCSV(t *testing.T) {
+	svc := &WAFLogServiceImpl{wafLogRepository: &fakeWAFLogRepo{logs: testAttackLogs()}}
+
+	var buf bytes.Buffer
+	req := dto.AttackLogExportRequest{Format: "csv", Columns: "createdAt,ruleId,message,payload"}
+	count, err := svc.ExportAttackLogs(context.Background(), req, &buf)
+	if err != nil {
+		t.Fatalf("ExportAttackLogs: %v", err)
+	}
+	if count != 2 {
+		t.Errorf("count = %d, want 2", count)
+	}
+
+	records, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatalf("read csv: %v", err)
+	}
+	want := [][]string{
+		{"createdAt", "ruleId", "message", "payload"},
+		{"2024-03-17T08:00:00Z", "942100", "SQL Injection, detected", "'=cmd|' /C calc'!A0"},
+		{"2024-03-17T09:00:00Z", "941100", "XSS", ""},
+	}
+	if len(records) != len(want) {
+		t.Fatalf("records = %q, want %q", records, want)
+	}
+	for i := range want {
+		if !slices.Equal(records[i], want[i]) {
+			t.Errorf("row %d = %q, want %q", i, records[i], want[i])
+		}
+	}
+}
+
+func TestExportAttackLogsJSON(t *testing.T) {
+	svc := &WAFLogServiceImpl{wafLogRepository: &fakeWAFLogRepo{logs: testAttackLogs()}}
+
+	var buf bytes.Buffer
+	count, err := svc.ExportAttackLogs(context.Background(), dto.AttackLogExportRequest{Format: "json"}, &buf)
+	if err != nil || count != 2 {
+		t.Fatalf("ExportAttackLogs = %d, %v", count, err)
+	}
+	var logs []model.WAFLog
+	if err := json.Unmarshal(buf.Bytes(), &logs); err != nil {
+		t.Fatalf("output is not a JSON array: %v\n%s", err, buf.String())
+	}
+	if len(logs) != 2 || logs[1].RuleID != 941100 {
+		t.Errorf("logs = %+v", logs)
+	}
+
+	buf.Reset()
+	count, err = svc.ExportAttackLogs(context.Background(), dto.AttackLogExportRequest{Format: "ndjson"}, &buf)
+	if err != nil || count != 2 {
+		t.Fatalf("ExportAttackLogs = %d, %v", count, err)
+	}
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("ndjson lines = %d, want 2", len(lines))
+	}
+	for _, line := range lines {
+		var log model.WAFLog
+		if err := json.Unmarshal([]byte(line), &log); err != nil {
+			t.Errorf("line %q: %v", line, err)
+		}
+	}
+}
+
+func TestExportAttackLogsEmptyJSON(t *testing.T) {
+	svc := &WAFLogServiceImpl{wafLogRepository: &fakeWAFLogRepo{}}
+
+	var buf bytes.Buffer
+	if _, err := svc.ExportAttackLogs(context.Background(), dto.AttackLogExportRequest{Format: "json"}, &buf); err != nil {
+		t.Fatalf("ExportAttackLogs: %v", err)
+	}
+	if buf.String() != "[]" {
+		t.Errorf("output = %q, want []", buf.String())
+	}
+}
+
+func TestMatchAny(t *testing.T) {
+	if got := matchAny("", nil); got != nil {
+		t.Errorf("matchAny(empty) = %v, want nil", got)
+	}
+	if got := matchAny("1.1.1.1", nil); got != "1.1.1.1" {
+		t.Errorf("matchAny(single) = %v, want 1.1.1.1", got)
+	}
+	if got := matchAny("", []string{"", "2.2.2.2", "2.2.2.2"}); got != "2.2.2.2" {
+		t.Errorf("matchAny(duplicate multi) = %v, want 2.2.2.2", got)
+	}
+
+	got := matchAny(942100, []int{941100, 942100, 0})
+	in, ok := got.(bson.D)
+	if !ok || len(in) != 1 || in[0].Key != "$in" {
+		t.Fatalf("matchAny(single+multi) = %v, want $in clause", got)
+	}
+	if values := in[0].Value.([]int); !slices.Equal(values, []int{942100, 941100}) {
+		t.Errorf("$in values = %v, want [942100 941100]", values)
+	}
+}
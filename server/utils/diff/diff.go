@@ -0,0 +1,212 @@
+package diff
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// DefaultContext 统一格式差异默认保留的上下文行数
+const DefaultContext = 3
+
+// maxEdits 最短编辑脚本的长度上限，回溯记录的内存随编辑数平方增长，
+// 超过上限时不再搜索，按整体替换输出差异
+const maxEdits = 1000
+
+type opKind int
+
+const (
+	opEqual opKind = iota
+	opDelete
+	opInsert
+)
+
+// edit 一行编辑操作，aPos/bPos 为操作前在新旧文本中的行号（从0开始）
+type edit struct {
+	kind opKind
+	text string
+	aPos int
+	bPos int
+}
+
+// Unified 按行比较两段文本，返回统一格式（unified diff）的差异，文本相同时返回空字符串
+func Unified(oldName, newName, oldText, newText string, context int) string {
+	if oldText == newText {
+		return ""
+	}
+	if context < 0 {
+		context = DefaultContext
+	}
+
+	oldLines, newLines := splitLines(oldText), splitLines(newText)
+	edits, ok := myers(oldLines, newLines, maxEdits)
+	if !ok {
+		edits = replaceAll(oldLines, newLines)
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "--- %s\n+++ %s\n", oldName, newName)
+
+	i := 0
+	for i < len(edits) {
+		// 找到下一处变化
+		for i < len(edits) && edits[i].kind == opEqual {
+			i++
+		}
+		if i == len(edits) {
+			break
+		}
+
+		start := max(i-context, 0)
+
+		// 相邻变化之间的相同行不超过 2*context 时合并到同一个区块
+		end := i
+		for {
+			for end < len(edits) && edits[end].kind != opEqual {
+				end++
+			}
+			run := end
+			for run < len(edits) && edits[run].kind == opEqual {
+				run++
+			}
+			if run == len(edits) || run-end > 2*context {
+				end = min(end+context, len(edits))
+				break
+			}
+			end = run
+		}
+
+		writeHunk(&sb, edits[start:end])
+		i = end
+	}
+
+	return sb.String()
+}
+
+// writeHunk 输出一个差异区块
+func writeHunk(sb *strings.Builder, hunk []edit) {
+	oldStart, newStart := hunk[0].aPos+1, hunk[0].bPos+1
+	oldCount, newCount := 0, 0
+	for _, e := range hunk {
+		if e.kind != opInsert {
+			oldCount++
+		}
+		if e.kind != opDelete {
+			newCount++
+		}
+	}
+	// 空范围按照惯例使用前一行的行号
+	if oldCount == 0 {
+		oldStart--
+	}
+	if newCount == 0 {
+		newStart--
+	}
+
+	fmt.Fprintf(sb, "@@ -%d,%d +%d,%d @@\n", oldStart, oldCount, newStart, newCount)
+	for _, e := range hunk {
+		switch e.kind {
+		case opEqual:
+			sb.WriteString(" ")
+		case opDelete:
+			sb.WriteString("-")
+		case opInsert:
+			sb.WriteString("+")
+		}
+		sb.WriteString(e.text)
+		// 与 diff 工具一致，标出文件末尾缺少的换行
+		if !strings.HasSuffix(e.text, "\n") {
+			sb.WriteString("\n\\ No newline at end of file\n")
+		}
+	}
+}
+
+// splitLines 按行拆分文本，每行保留末尾的换行，文件末尾缺少换行的最后一行与有换行的不相同
+func splitLines(s string) []string {
+	lines := strings.SplitAfter(s, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// replaceAll 删除全部旧行再插入全部新行
+func replaceAll(a, b []string) []edit {
+	edits := make([]edit, 0, len(a)+len(b))
+	for i, line := range a {
+		edits = append(edits, edit{kind: opDelete, text: line, aPos: i, bPos: 0})
+	}
+	for i, line := range b {
+		edits = append(edits, edit{kind: opInsert, text: line, aPos: len(a), bPos: i})
+	}
+	return edits
+}
+
+// myers 使用 Myers 算法计算最短编辑脚本，编辑数超过 limit 时返回 false
+//
+// 第 d 轮只会读取对角线 k ∈ [-d-1, d+1] 上的值，回溯记录只保存这一段，
+// 内存为 O(D²) 而不是 O(D·(n+m))
+func myers(a, b []string, limit int) ([]edit, bool) {
+	n, m := len(a), len(b)
+	offset := n + m + 1
+	v := make([]int, 2*offset+1)
+	var trace [][]int
+
+search:
+	for d := 0; ; d++ {
+		if d > limit {
+			return nil, false
+		}
+		trace = append(trace, slices.Clone(v[offset-d-1:offset+d+2]))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				break search
+			}
+		}
+	}
+
+	// 从终点回溯每一轮的选择，trace[d][i] 对应对角线 k = i-d-1
+	var edits []edit
+	x, y := n, m
+	for d := len(trace) - 1; d >= 0; d-- {
+		v := trace[d]
+		k := x - y
+		var prevK int
+		if k == -d || (k != d && v[k+d] < v[k+d+2]) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := v[prevK+d+1]
+		prevY := prevX - prevK
+
+		for x > prevX && y > prevY {
+			x--
+			y--
+			edits = append(edits, edit{kind: opEqual, text: a[x], aPos: x, bPos: y})
+		}
+		if d > 0 {
+			if x == prevX {
+				edits = append(edits, edit{kind: opInsert, text: b[prevY], aPos: prevX, bPos: prevY})
+			} else {
+				edits = append(edits, edit{kind: opDelete, text: a[prevX], aPos: prevX, bPos: prevY})
+			}
+		}
+		x, y = prevX, prevY
+	}
+
+	slices.Reverse(edits)
+	return edits, true
+}
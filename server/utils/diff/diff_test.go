@@ -0,0 +1,73 @@
+package diff
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestUnified(t *testing.T) {
+	tests := []struct {
+		name     string
+		old, new string
+		context  int
+		want     string
+	}{
+		{"identical", "a\nb\n", "a\nb\n", 3, ""},
+		{"insert into empty", "", "a\nb\n", 3, "@@ -0,0 +1,2 @@\n+a\n+b\n"},
+		{"insert", "1\n2\n3\n", "1\n2\nX\n3\n", 3, "@@ -1,3 +1,4 @@\n 1\n 2\n+X\n 3\n"},
+		{"delete all", "a\n", "", 3, "@@ -1,1 +0,0 @@\n-a\n"},
+		{"delete", "1\n2\n3\n", "1\n3\n", 3, "@@ -1,3 +1,2 @@\n 1\n-2\n 3\n"},
+		{"context trimmed", "1\n2\n3\n4\n5\n", "1\n2\n3\n4\nX\n", 1, "@@ -4,2 +4,2 @@\n 4\n-5\n+X\n"},
+		// 相邻变化之间正好 2*context 行相同时合并，多一行时拆成两个区块
+		{"merged hunks", "A\nb\nc\nD\n", "A2\nb\nc\nD2\n", 1,
+			"@@ -1,4 +1,4 @@\n-A\n+A2\n b\n c\n-D\n+D2\n"},
+		{"split hunks", "A\nb\nc\nd\nE\n", "A2\nb\nc\nd\nE2\n", 1,
+			"@@ -1,2 +1,2 @@\n-A\n+A2\n b\n@@ -4,2 +4,2 @@\n d\n-E\n+E2\n"},
+		{"missing trailing newline", "a\nb\n", "a\nb", 3,
+			"@@ -1,2 +1,2 @@\n a\n-b\n+b\n\\ No newline at end of file\n"},
+	}
+	for _, tt := range tests {
+		want := tt.want
+		if want != "" {
+			want = "--- old\n+++ new\n" + want
+		}
+		if got := Unified("old", "new", tt.old, tt.new, tt.context); got != want {
+			t.Errorf("%s: diff =\n%s\nwant\n%s", tt.name, got, want)
+		}
+	}
+}
+
+func TestUnifiedReplacesWhenTooManyEdits(t *testing.T) {
+	var oldText, newText strings.Builder
+	for i := range maxEdits {
+		fmt.Fprintf(&oldText, "old %d\n", i)
+		fmt.Fprintf(&newText, "new %d\n", i)
+	}
+
+	got := Unified("old", "new", oldText.String(), newText.String(), DefaultContext)
+	header := fmt.Sprintf("--- old\n+++ new\n@@ -1,%d +1,%d @@\n-old 0\n", maxEdits, maxEdits)
+	if !strings.HasPrefix(got, header) || strings.Count(got, "@@ -") != 1 {
+		t.Errorf("diff starts with\n%.80s\nwant a single hunk starting with\n%s", got, header)
+	}
+	if !strings.HasSuffix(got, fmt.Sprintf("+new %d\n", maxEdits-1)) {
+		t.Errorf("diff does not end with the last new line")
+	}
+}
+
+func TestMyersLimit(t *testing.T) {
+	a, b := []string{"a\n", "b\n", "c\n"}, []string{"a\n", "x\n", "c\n"}
+	if _, ok := myers(a, b, 1); ok {
+		t.Error("myers succeeded with 2 edits over a limit of 1")
+	}
+	edits, ok := myers(a, b, 2)
+	if !ok || len(edits) != 4 {
+		t.Fatalf("myers = %d edits, %v, want 4 edits", len(edits), ok)
+	}
+	kinds := []opKind{opEqual, opDelete, opInsert, opEqual}
+	for i, e := range edits {
+		if e.kind != kinds[i] {
+			t.Errorf("edit %d = %v %q, want kind %v", i, e.kind, e.text, kinds[i])
+		}
+	}
+}
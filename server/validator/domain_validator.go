@@ -4,6 +4,7 @@ package validator
 import (
 	"net"
 	"regexp"
+	"strings"
 
 	"github.com/go-playground/validator/v10"
 )
@@ -11,8 +12,16 @@ import (
 // 初始化字符串相关验证器
 func init() {
 	Register("domain", DomainOrIPValidator)
+	Register("site_host", SiteHostValidator)
 }
 
+// 域名正则表达式
+// 规则:
+// 1. 由字母、数字、连字符组成，连字符不能在开头或结尾
+// 2. 每个标签(点之间的部分)长度不超过63个字符
+// 3. 至少有一个点，最后一个部分至少2个字符(TLD)
+var domainRegex = regexp.MustCompile(`^([a-zA-Z0-9]([a-zA-Z0-9\-]{0,61}[a-zA-Z0-9])?\.)+[a-zA-Z]{2,}$`)
+
 // DomainOrIPValidator 验证字符串是否为有效的域名或IP地址
 var DomainOrIPValidator validator.Func = func(fl validator.FieldLevel) bool {
 	value, ok := fl.Field().Interface().(string)
@@ -26,12 +35,26 @@ var DomainOrIPValidator validator.Func = func(fl validator.FieldLevel) bool {
 	}
 
 	// 2. 检查是否为有效的域名
-	// 域名正则表达式
-	// 规则:
-	// 1. 由字母、数字、连字符组成，连字符不能在开头或结尾
-	// 2. 每个标签(点之间的部分)长度不超过63个字符
-	// 3. 至少有一个点，最后一个部分至少2个字符(TLD)
-	domainRegex := regexp.MustCompile(`^([a-zA-Z0-9]([a-zA-Z0-9\-]{0,61}[a-zA-Z0-9])?\.)+[a-zA-Z]{2,}$`)
+	return domainRegex.MatchString(value)
+}
 
+// SiteHostValidator 验证字符串是否为有效的站点主机名：IP 地址、域名或 *.a.com 形式的通配符域名
+// IPv6 地址可以写成 [2001:db8::1] 的形式
+var SiteHostValidator validator.Func = func(fl validator.FieldLevel) bool {
+	value, ok := fl.Field().Interface().(string)
+	if !ok {
+		return false
+	}
+
+	if domain, found := strings.CutPrefix(value, "*."); found {
+		return domainRegex.MatchString(domain)
+	}
+	if ip := net.ParseIP(value); ip != nil {
+		return true
+	}
+	if inner, found := strings.CutPrefix(value, "["); found && strings.HasSuffix(inner, "]") {
+		ip := net.ParseIP(strings.TrimSuffix(inner, "]"))
+		return ip != nil && ip.To4() == nil
+	}
 	return domainRegex.MatchString(value)
 }
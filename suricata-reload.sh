@@ -1,40 +1,76 @@
 #!/bin/sh
 #
 # suricata-reload.sh
-# 1. 动态提取 WAF 反代端口，更新 Suricata 的 BPF 过滤表达式
-# 2. 触发已运行 Suricata 进程重读最新配置（热重载）
-
-# —— 第 1 步：从 HAProxy 配置中提取所有 bind 端口 —— 
-# 假设你的 HAProxy 配置挂载在 /etc/haproxy/haproxy.cfg
-ports=$(grep -E '^\s*bind\s+' /etc/haproxy/haproxy.cfg \
-  | grep -oE ':[0-9]+' | tr -d ':' | sort -u)
-
-# —— 第 2 步：拼接成 BPF 过滤表达式 —— 
-# 例如：tcp port 80 or tcp port 8080 or tcp port 8443
-bpf=""
-IFS=','; for p in $ports; do
-  if [ -n "$bpf" ]; then
-    bpf="$bpf or "
-  fi
-  bpf="${bpf}tcp port ${p}"
-done
-unset IFS
+# Suricata 容器入口脚本
+# 1. 使用 simple-waf 根据站点监听端口生成的 BPF 过滤表达式启动 Suricata
+# 2. 站点变化后，simple-waf 会重写过滤文件和 WAF_PORTS 端口组，并通过 unix 命令套接字执行 reload-rules
+# 3. BPF 过滤只在启动时通过 -F 读取，脚本检测到过滤文件变化后重启 Suricata 使新端口生效
+
+CONF_DIR=${SURICATA_CONFIG_DIR:-/var/lib/suricata/conf}
+BPF_FILE="$CONF_DIR/capture.bpf"
+IFACE=${SURICATA_IFACE:-eth0}
+WATCH_INTERVAL=${SURICATA_WATCH_INTERVAL:-5}
+
+mkdir -p /var/run/suricata "$CONF_DIR"
 
-# —— 第 3 步：更新 suricata.yaml 中 bpf-filter 条目 —— 
-# 假设你在 suricata.yaml 中预留了一行：
-#     bpf-filter: ""
-# 下面这条 sed 会把它替换成实际的过滤串
-sed -i "s#^\(\s*bpf-filter:\).*#\1 \"${bpf}\"#g" /etc/suricata/suricata.yaml
+# —— 第 1 步：等待 simple-waf 生成抓包过滤文件 ——
+# 首次启动时 simple-waf 可能尚未应用站点配置，最多等待 60 秒，超时后抓取全部流量
+i=0
+while [ ! -f "$BPF_FILE" ] && [ $i -lt 60 ]; do
+  sleep 1
+  i=$((i + 1))
+done
 
-# —— 第 4 步：触发 Suricata 热重载 —— 
-# 如果 suricata.yaml 中开启了 unix-command
+# —— 第 2 步：启动 Suricata ——
+# suricata.yaml 中需要：
+#    include: /var/lib/suricata/conf/capture.yaml
 #    unix-command:
 #      enabled: yes
-#      filename: /var/run/suricata-command.socket
-# 则可以通过 socket 下发 reload-config
-if [ -S /var/run/suricata-command.socket ]; then
-  echo 'reload-config' | socat - UNIX-CONNECT:/var/run/suricata-command.socket
-else
-  # 否则退回到发送 HUP 信号
-  pid=$(pidof suricata)
-  [ -n "$
+#      filename: /var/run/suricata/suricata-command.socket
+#    rule-files:
+#      - suricata.rules
+#      - local.rules
+start_suricata() {
+  if [ -s "$BPF_FILE" ] && [ -n "$(tr -d '[:space:]' < "$BPF_FILE")" ]; then
+    echo "使用 BPF 过滤: $(cat "$BPF_FILE")"
+    suricata -c /etc/suricata/suricata.yaml --af-packet="$IFACE" -F "$BPF_FILE" &
+  else
+    echo "未找到 BPF 过滤表达式，抓取全部流量"
+    suricata -c /etc/suricata/suricata.yaml --af-packet="$IFACE" &
+  fi
+  SURICATA_PID=$!
+}
+
+stop_suricata() {
+  kill -TERM "$SURICATA_PID" 2>/dev/null
+  wait "$SURICATA_PID" 2>/dev/null
+}
+
+bpf_checksum() {
+  cksum "$BPF_FILE" 2>/dev/null
+}
+
+trap 'stop_suricata; exit 0' TERM INT
+
+start_suricata
+CURRENT=$(bpf_checksum)
+
+# —— 第 3 步：监视抓包过滤文件 ——
+# Suricata 退出时脚本随之退出，由容器的重启策略拉起
+while true; do
+  sleep "$WATCH_INTERVAL" &
+  wait $!
+
+  if ! kill -0 "$SURICATA_PID" 2>/dev/null; then
+    wait "$SURICATA_PID"
+    exit $?
+  fi
+
+  NEXT=$(bpf_checksum)
+  if [ "$NEXT" != "$CURRENT" ]; then
+    echo "抓包过滤已变化，重启 Suricata"
+    stop_suricata
+    start_suricata
+    CURRENT=$NEXT
+  fi
+done